@@ -0,0 +1,12 @@
+// Package api holds the OpenAPI document of the public API. The Go and TypeScript SDKs in
+// ../sdk are generated from it; after changing it run, from the backend directory:
+//
+//	go run ./tools/sdkgen
+package api
+
+import _ "embed"
+
+// OpenAPISpec is openapi.json, served at GET /api/openapi.json
+//
+//go:embed openapi.json
+var OpenAPISpec []byte
@@ -0,0 +1,36 @@
+// Package cache stores short-lived shared state (QR codes, session statuses, cached analysis
+// results) outside process memory, so it survives restarts and is visible to every instance.
+package cache
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Store is a key/value cache with per-key expiry
+type Store interface {
+	// Get returns the value and true, or false when the key is missing or expired
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores the value; ttl <= 0 keeps it until deleted
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+	// Name identifies the backend in logs and health output ("memory" or "redis")
+	Name() string
+}
+
+// New returns the Redis store when REDIS_URL is set, the in-memory store otherwise.
+// An invalid REDIS_URL falls back to memory so the server still starts.
+func New() Store {
+	redisURL := os.Getenv("REDIS_URL")
+	if redisURL == "" {
+		return NewMemory()
+	}
+	store, err := NewRedis(redisURL)
+	if err != nil {
+		slog.Warn("Invalid REDIS_URL, using in-memory cache", "error", err)
+		return NewMemory()
+	}
+	return store
+}
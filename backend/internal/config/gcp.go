@@ -0,0 +1,114 @@
+package config
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// gcpMetadataTokenURL returns an access token for the service account of the GCE/GKE/Cloud Run instance
+const gcpMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// GCPSecretsProvider reads secrets from Google Secret Manager:
+// gcpsm://projects/<project>/secrets/<name>[/versions/<version>][#<json key>] (default version: latest)
+type GCPSecretsProvider struct {
+	endpoint    string
+	accessToken string
+	client      *http.Client
+}
+
+// NewGCPSecretsProvider authenticates with GCP_ACCESS_TOKEN when set, otherwise with the instance
+// service account from the metadata server. GCP_SECRET_MANAGER_ENDPOINT overrides the API endpoint.
+func NewGCPSecretsProvider() *GCPSecretsProvider {
+	endpoint := strings.TrimRight(os.Getenv("GCP_SECRET_MANAGER_ENDPOINT"), "/")
+	if endpoint == "" {
+		endpoint = "https://secretmanager.googleapis.com"
+	}
+	return &GCPSecretsProvider{
+		endpoint:    endpoint,
+		accessToken: os.Getenv("GCP_ACCESS_TOKEN"),
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Scheme implements SecretProvider
+func (p *GCPSecretsProvider) Scheme() string {
+	return "gcpsm"
+}
+
+// Fetch implements SecretProvider
+func (p *GCPSecretsProvider) Fetch(ctx context.Context, ref string) (string, error) {
+	name := strings.Trim(ref, "/")
+	if !strings.HasPrefix(name, "projects/") || !strings.Contains(name, "/secrets/") {
+		return "", fmt.Errorf("invalid secret name %q (projects/<project>/secrets/<name>)", ref)
+	}
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+	token, err := p.token(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.endpoint+"/v1/"+name+":access", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("secret manager request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("secret manager returned %d for %s", resp.StatusCode, name)
+	}
+
+	var payload struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "", fmt.Errorf("invalid secret manager response for %s: %v", name, err)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(payload.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("invalid secret payload for %s: %v", name, err)
+	}
+	return string(decoded), nil
+}
+
+// token returns GCP_ACCESS_TOKEN or a fresh token from the metadata server
+func (p *GCPSecretsProvider) token(ctx context.Context) (string, error) {
+	if p.accessToken != "" {
+		return p.accessToken, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcpMetadataTokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: gcpsm (set GCP_ACCESS_TOKEN or run on GCP): %v", ErrNoSecretProvider, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server returned %d for the access token", resp.StatusCode)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("invalid access token from the metadata server")
+	}
+	return token.AccessToken, nil
+}
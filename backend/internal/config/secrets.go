@@ -0,0 +1,228 @@
+// Package config resolves configuration secrets kept in external secret managers. An environment
+// variable whose value is a secret reference (vault://, awssm:// or gcpsm://) is replaced with the
+// secret at startup, so the rest of the application keeps reading plain environment variables.
+// References are fetched again periodically to pick up rotated secrets.
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretProvider fetches secrets from one secret manager
+type SecretProvider interface {
+	// Scheme is the reference prefix handled by the provider ("vault" for vault://...)
+	Scheme() string
+	// Fetch returns the secret at ref, the part of the reference between "<scheme>://" and "#"
+	Fetch(ctx context.Context, ref string) (string, error)
+}
+
+// ErrNoSecretProvider is returned for a reference whose secret manager is not configured
+var ErrNoSecretProvider = errors.New("secret provider not configured")
+
+// secretRef is a parsed "<scheme>://<ref>#<field>" reference; field selects a key of a JSON secret
+type secretRef struct {
+	scheme string
+	ref    string
+	field  string
+}
+
+func parseSecretRef(value string) (secretRef, bool) {
+	scheme, rest, ok := strings.Cut(strings.TrimSpace(value), "://")
+	if !ok || scheme == "" || strings.ContainsAny(scheme, " /:") {
+		return secretRef{}, false
+	}
+	ref, field, _ := strings.Cut(rest, "#")
+	return secretRef{scheme: strings.ToLower(scheme), ref: ref, field: field}, true
+}
+
+// SecretManager keeps the environment variables that reference secrets and their current values
+type SecretManager struct {
+	providers map[string]SecretProvider
+
+	mu        sync.Mutex
+	refs      map[string]secretRef // environment variable -> reference
+	values    map[string]string
+	listeners []func(changed []string)
+}
+
+// NewSecretManager creates a manager resolving the schemes of the given providers
+func NewSecretManager(providers ...SecretProvider) *SecretManager {
+	m := &SecretManager{
+		providers: make(map[string]SecretProvider),
+		refs:      make(map[string]secretRef),
+		values:    make(map[string]string),
+	}
+	for _, p := range providers {
+		m.providers[p.Scheme()] = p
+	}
+	return m
+}
+
+// LoadSecrets resolves every secret reference in the environment with the secret managers
+// configured through the environment (see DefaultSecretProviders)
+func LoadSecrets(ctx context.Context) (*SecretManager, error) {
+	m := NewSecretManager(DefaultSecretProviders()...)
+	if err := m.Resolve(ctx); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// DefaultSecretProviders returns the providers of the known secret managers. Each one only
+// fails when a reference actually uses it without being configured.
+func DefaultSecretProviders() []SecretProvider {
+	return []SecretProvider{NewVaultProvider(), NewAWSSecretsProvider(), NewGCPSecretsProvider()}
+}
+
+// Resolve finds the environment variables holding a reference with a known scheme and replaces
+// them with the secret. Values with other schemes (e.g. redis:// URLs) are left alone.
+func (m *SecretManager) Resolve(ctx context.Context) error {
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		ref, ok := parseSecretRef(value)
+		if !ok {
+			continue
+		}
+		if _, known := m.providers[ref.scheme]; !known {
+			continue
+		}
+		m.refs[name] = ref
+	}
+
+	var failed []string
+	for _, name := range m.names() {
+		secret, err := m.fetch(ctx, m.refs[name])
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		m.values[name] = secret
+		os.Setenv(name, secret)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to resolve secrets: %s", strings.Join(failed, "; "))
+	}
+	if len(m.refs) > 0 {
+		slog.Info("Loaded secrets from secret managers", "variables", m.names())
+	}
+	return nil
+}
+
+// Refresh fetches every reference again and updates the variables whose secret changed.
+// A secret that cannot be fetched keeps its previous value.
+func (m *SecretManager) Refresh(ctx context.Context) ([]string, error) {
+	m.mu.Lock()
+	var changed, failed []string
+	for _, name := range m.names() {
+		secret, err := m.fetch(ctx, m.refs[name])
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		if secret != m.values[name] {
+			m.values[name] = secret
+			os.Setenv(name, secret)
+			changed = append(changed, name)
+		}
+	}
+	listeners := append([]func([]string){}, m.listeners...)
+	m.mu.Unlock()
+
+	if len(changed) > 0 {
+		for _, listener := range listeners {
+			listener(changed)
+		}
+	}
+	if len(failed) > 0 {
+		return changed, fmt.Errorf("failed to refresh secrets: %s", strings.Join(failed, "; "))
+	}
+	return changed, nil
+}
+
+// OnChange registers fn to be called with the names of the variables whose secret was rotated
+func (m *SecretManager) OnChange(fn func(changed []string)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, fn)
+}
+
+// StartRefreshWorker refreshes the secrets every SECRETS_REFRESH_SECONDS (default 300, 0 disables)
+func (m *SecretManager) StartRefreshWorker() {
+	interval := 300 * time.Second
+	if raw := strings.TrimSpace(os.Getenv("SECRETS_REFRESH_SECONDS")); raw != "" {
+		seconds, err := strconv.Atoi(raw)
+		if err != nil || seconds < 0 {
+			slog.Warn("Invalid SECRETS_REFRESH_SECONDS, using the default", "value", raw)
+		} else {
+			interval = time.Duration(seconds) * time.Second
+		}
+	}
+	if interval == 0 || len(m.refs) == 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			changed, err := m.Refresh(ctx)
+			cancel()
+			if err != nil {
+				slog.Warn("Secret refresh incomplete", "error", err)
+			}
+			if len(changed) > 0 {
+				slog.Info("Rotated secrets picked up", "variables", changed)
+			}
+		}
+	}()
+}
+
+// names returns the variables holding references, sorted
+func (m *SecretManager) names() []string {
+	names := make([]string, 0, len(m.refs))
+	for name := range m.refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fetch returns the secret of ref, or the field of it when the secret is a JSON object
+func (m *SecretManager) fetch(ctx context.Context, ref secretRef) (string, error) {
+	provider, ok := m.providers[ref.scheme]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNoSecretProvider, ref.scheme)
+	}
+	secret, err := provider.Fetch(ctx, ref.ref)
+	if err != nil {
+		return "", err
+	}
+	if ref.field == "" {
+		return secret, nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		return "", fmt.Errorf("secret %s://%s is not a JSON object, cannot select #%s", ref.scheme, ref.ref, ref.field)
+	}
+	value, ok := fields[ref.field]
+	if !ok {
+		return "", fmt.Errorf("secret %s://%s has no field %q", ref.scheme, ref.ref, ref.field)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	raw, _ := json.Marshal(value)
+	return string(raw), nil
+}
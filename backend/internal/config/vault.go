@@ -0,0 +1,97 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// VaultProvider reads secrets from HashiCorp Vault over its HTTP API: vault://<path>#<field>,
+// e.g. vault://secret/data/cekwa#jwt_secret for the KV v2 engine mounted at secret/
+type VaultProvider struct {
+	addr      string
+	token     string
+	tokenFile string
+	namespace string
+	client    *http.Client
+}
+
+// NewVaultProvider configures Vault from VAULT_ADDR, VAULT_TOKEN or VAULT_TOKEN_FILE (re-read on
+// every fetch, e.g. written by a Vault agent) and the optional VAULT_NAMESPACE
+func NewVaultProvider() *VaultProvider {
+	return &VaultProvider{
+		addr:      strings.TrimRight(os.Getenv("VAULT_ADDR"), "/"),
+		token:     os.Getenv("VAULT_TOKEN"),
+		tokenFile: os.Getenv("VAULT_TOKEN_FILE"),
+		namespace: os.Getenv("VAULT_NAMESPACE"),
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Scheme implements SecretProvider
+func (p *VaultProvider) Scheme() string {
+	return "vault"
+}
+
+// Fetch implements SecretProvider. The secret is returned as a JSON object of its fields.
+func (p *VaultProvider) Fetch(ctx context.Context, ref string) (string, error) {
+	if p.addr == "" {
+		return "", fmt.Errorf("%w: vault (set VAULT_ADDR)", ErrNoSecretProvider)
+	}
+	token, err := p.currentToken()
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.addr+"/v1/"+strings.TrimLeft(ref, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned %d for %s", resp.StatusCode, ref)
+	}
+
+	var payload struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "", fmt.Errorf("invalid vault response for %s: %v", ref, err)
+	}
+	// KV v2 nests the fields under data.data next to data.metadata; KV v1 returns them in data
+	if nested, ok := payload.Data["data"]; ok {
+		if _, versioned := payload.Data["metadata"]; versioned {
+			return string(nested), nil
+		}
+	}
+	raw, _ := json.Marshal(payload.Data)
+	return string(raw), nil
+}
+
+func (p *VaultProvider) currentToken() (string, error) {
+	if p.tokenFile != "" {
+		raw, err := os.ReadFile(p.tokenFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read VAULT_TOKEN_FILE: %v", err)
+		}
+		return strings.TrimSpace(string(raw)), nil
+	}
+	if p.token == "" {
+		return "", fmt.Errorf("%w: vault (set VAULT_TOKEN or VAULT_TOKEN_FILE)", ErrNoSecretProvider)
+	}
+	return p.token, nil
+}
@@ -0,0 +1,157 @@
+// Package contentscan classifies message text into sensitive content categories (gambling, fraud,
+// adult content) with a configurable ruleset of keywords and regular expressions. Only the matched
+// categories leave the package: callers keep counts, never the text itself.
+package contentscan
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Built-in categories
+const (
+	CategoryGambling = "gambling"
+	CategoryFraud    = "fraud"
+	CategoryAdult    = "adult"
+)
+
+// Rule matches one category: a message matches when it contains any keyword (whole words,
+// case-insensitive) or any pattern matches
+type Rule struct {
+	Category string   `json:"category"`
+	Keywords []string `json:"keywords,omitempty"`
+	Patterns []string `json:"patterns,omitempty"` // Go regular expressions, matched against the original text
+}
+
+// Ruleset is a compiled set of rules; it is safe for concurrent use
+type Ruleset struct {
+	categories []string
+	keywords   map[string][]string // category -> normalized keywords
+	patterns   map[string][]*regexp.Regexp
+}
+
+// DefaultRules is the ruleset used when no rules file is configured, tuned for Indonesian chats
+var DefaultRules = []Rule{
+	{
+		Category: CategoryGambling,
+		Keywords: []string{"judi", "judol", "judi online", "slot gacor", "togel", "toto gelap", "casino", "kasino",
+			"taruhan", "sabung ayam", "bandar bola", "maxwin", "deposit pulsa", "link alternatif", "rtp live"},
+		Patterns: []string{`(?i)\bslot\s*\d{2,}\b`, `(?i)\bwd\s+(cair|lancar)\b`},
+	},
+	{
+		Category: CategoryFraud,
+		Keywords: []string{"pinjol", "pinjaman online tanpa jaminan", "kode otp", "kirim otp", "hadiah undian", "anda menang",
+			"transfer biaya admin", "rekening diblokir", "akun diblokir", "investasi bodong", "profit harian",
+			"double uang", "klik link berikut", "apk undangan", "cek resi apk"},
+		Patterns: []string{`(?i)https?://(bit\.ly|s\.id|tinyurl\.com)/\S+`, `(?i)\.apk\b`},
+	},
+	{
+		Category: CategoryAdult,
+		Keywords: []string{"bokep", "open bo", "openbo", "vcs", "video call sex", "bugil", "porno", "sange", "colmek"},
+		Patterns: []string{`(?i)\bbo\s+(murah|ready)\b`},
+	},
+}
+
+// NewRuleset compiles rules. Rules with the same category are merged.
+func NewRuleset(rules []Rule) (*Ruleset, error) {
+	rs := &Ruleset{keywords: make(map[string][]string), patterns: make(map[string][]*regexp.Regexp)}
+	for i, rule := range rules {
+		category := strings.ToLower(strings.TrimSpace(rule.Category))
+		if category == "" {
+			return nil, fmt.Errorf("rule %d: category is required", i)
+		}
+		if _, seen := rs.keywords[category]; !seen {
+			rs.keywords[category] = nil
+			rs.categories = append(rs.categories, category)
+		}
+		for _, keyword := range rule.Keywords {
+			if normalized := normalize(keyword); normalized != "" {
+				rs.keywords[category] = append(rs.keywords[category], normalized)
+			}
+		}
+		for _, pattern := range rule.Patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d (%s): invalid pattern %q: %v", i, category, pattern, err)
+			}
+			rs.patterns[category] = append(rs.patterns[category], re)
+		}
+		if len(rs.keywords[category]) == 0 && len(rs.patterns[category]) == 0 {
+			return nil, fmt.Errorf("rule %d (%s): no keywords or patterns", i, category)
+		}
+	}
+	sort.Strings(rs.categories)
+	return rs, nil
+}
+
+// LoadRuleset reads a JSON array of rules from path
+func LoadRuleset(path string) (*Ruleset, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		return nil, fmt.Errorf("invalid rules file %s: %v", path, err)
+	}
+	return NewRuleset(rules)
+}
+
+// Categories returns the categories of the ruleset, sorted
+func (rs *Ruleset) Categories() []string {
+	return append([]string(nil), rs.categories...)
+}
+
+// Classify returns the categories text matches, in the order of Categories (nil when none)
+func (rs *Ruleset) Classify(text string) []string {
+	if rs == nil || strings.TrimSpace(text) == "" {
+		return nil
+	}
+	normalized := " " + normalize(text) + " "
+
+	var matched []string
+	for _, category := range rs.categories {
+		if rs.matches(category, text, normalized) {
+			matched = append(matched, category)
+		}
+	}
+	return matched
+}
+
+func (rs *Ruleset) matches(category, text, normalized string) bool {
+	for _, keyword := range rs.keywords[category] {
+		if strings.Contains(normalized, " "+keyword+" ") {
+			return true
+		}
+	}
+	for _, re := range rs.patterns[category] {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize lowercases s and turns every run of non letters/digits into one space,
+// so keywords match whole words regardless of punctuation
+func normalize(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteRune(r)
+			space = false
+			continue
+		}
+		space = true
+	}
+	return b.String()
+}
@@ -3,7 +3,10 @@ package database
 import (
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"sync/atomic"
+	"time"
 
 	"back_wa/internal/models"
 
@@ -14,10 +17,32 @@ import (
 	"gorm.io/gorm/logger"
 )
 
-var DB *gorm.DB
+// current is the shared connection pool. It is swapped atomically by Reopen while requests are
+// reading it; read it with GetDB or WithContext.
+var current atomic.Pointer[gorm.DB]
 
 // InitDatabase initializes the database connection
 func InitDatabase() {
+	db, err := openDatabase()
+	if err != nil {
+		slog.Error("Failed to connect to database", "error", err)
+		os.Exit(1)
+	}
+	current.Store(db)
+
+	// Auto migrate tables
+	err = migrateTables(db)
+	if err != nil {
+		slog.Error("Failed to migrate tables", "error", err)
+		os.Exit(1)
+	}
+
+	slog.Info("Database connected and migrated successfully!")
+}
+
+// openDatabase connects to the configured database and registers the GORM callbacks
+func openDatabase() (*gorm.DB, error) {
+	var db *gorm.DB
 	var err error
 
 	// Check environment for database type
@@ -28,26 +53,24 @@ func InitDatabase() {
 
 	switch dbType {
 	case "mysql":
-		DB, err = connectMySQL()
+		db, err = connectMySQL()
 	case "postgres", "postgresql":
-		DB, err = connectPostgreSQL()
+		db, err = connectPostgreSQL()
 	case "sqlite":
-		DB, err = connectSQLite()
+		db, err = connectSQLite()
 	default:
-		log.Fatal("Unsupported database type:", dbType)
+		return nil, fmt.Errorf("unsupported database type: %s", dbType)
 	}
-
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		return nil, err
 	}
 
-	// Auto migrate tables
-	err = migrateTables(DB)
-	if err != nil {
-		log.Fatal("Failed to migrate tables:", err)
-	}
+	// Enforce tenant isolation on scoped contexts
+	registerTenantCallbacks(db)
+	// Never delete rows under legal hold
+	registerLegalHoldCallbacks(db)
 
-	log.Println("Database connected and migrated successfully!")
+	return db, nil
 }
 
 // connectMySQL connects to MySQL database
@@ -62,9 +85,7 @@ func connectMySQL() (*gorm.DB, error) {
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local&timeout=10s&readTimeout=30s&writeTimeout=30s",
 		user, password, host, port, dbName)
 
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	})
+	db, err := gorm.Open(mysql.Open(dsn), gormConfig())
 	if err != nil {
 		return nil, err
 	}
@@ -76,9 +97,7 @@ func connectMySQL() (*gorm.DB, error) {
 	}
 
 	// Set connection pool settings
-	sqlDB.SetMaxIdleConns(10)
-	sqlDB.SetMaxOpenConns(100)
-	sqlDB.SetConnMaxLifetime(3600) // 1 hour
+	applyPool(sqlDB, LoadPoolConfig())
 
 	return db, nil
 }
@@ -95,9 +114,7 @@ func connectPostgreSQL() (*gorm.DB, error) {
 	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable TimeZone=Asia/Jakarta",
 		host, port, user, password, dbName)
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	})
+	db, err := gorm.Open(postgres.Open(dsn), gormConfig())
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to PostgreSQL: %v", err)
 	}
@@ -109,18 +126,14 @@ func connectPostgreSQL() (*gorm.DB, error) {
 	}
 
 	// Set connection pool settings
-	sqlDB.SetMaxIdleConns(10)
-	sqlDB.SetMaxOpenConns(100)
-	sqlDB.SetConnMaxLifetime(3600) // 1 hour
+	applyPool(sqlDB, LoadPoolConfig())
 
 	return db, nil
 }
 
 // connectSQLite connects to SQLite database (fallback)
 func connectSQLite() (*gorm.DB, error) {
-	return gorm.Open(sqlite.Open("whatsapp.db"), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	})
+	return gorm.Open(sqlite.Open("whatsapp.db"), gormConfig())
 }
 
 // migrateTables creates/updates database tables
@@ -129,10 +142,44 @@ func migrateTables(db *gorm.DB) error {
         &models.User{},
         &models.WhatsAppSession{},
         &models.AnalysisResult{},
+        &models.AnalysisGroup{},
+        &models.AnalysisContact{},
         &models.ScanHistory{},
         &models.Transaction{},
         &models.PaymentMethod{},
         &models.PaymentCategory{},
+        &models.Tenant{},
+        &models.PartnerUsage{},
+        &models.UserUsage{},
+        &models.Announcement{},
+        &models.AnalysisFeedback{},
+        &models.Plan{},
+        &models.Subscription{},
+        &models.DataAccessRequest{},
+        &models.DataAccessLog{},
+        &models.UserWebhook{},
+        &models.WebhookDelivery{},
+        &models.LegalConsent{},
+        &models.DataEncryptionKey{},
+        &models.AnalysisShareLink{},
+        &models.Notification{},
+        &models.PushToken{},
+        &models.PushPreference{},
+        &models.WhatsAppSessionEvent{},
+        &models.AccountMerge{},
+        &models.WhatsAppChat{},
+        &models.MessageScanConsent{},
+        &models.AnalysisJob{},
+        &models.BulkScan{},
+        &models.ScanSchedule{},
+        &models.ScoringConfig{},
+        &models.AuthSession{},
+        &models.UserGoal{},
+        &models.ProductLimit{},
+        &models.WebhookEvent{},
+        &models.Refund{},
+        &models.Coupon{},
+        &models.CouponRedemption{},
     ); err != nil {
         return err
     }
@@ -190,15 +237,94 @@ func migrateTables(db *gorm.DB) error {
         }
         
         if err := db.Exec(alterSQL).Error; err != nil {
-            log.Println("warning: failed to add phone_number column:", err)
+            slog.Warn("failed to add phone_number column", "error", err)
         } else {
-            log.Println("added phone_number column to transactions table")
+            slog.Info("added phone_number column to transactions table")
+        }
+    }
+
+    // One pending transaction per user + phone + category, enforced by the database so it holds
+    // across instances. Phone numbers are encrypted with a random nonce, so the index is on their
+    // blind index.
+    if dbType != "mysql" {
+        if err := db.Exec("DROP INDEX IF EXISTS idx_transactions_one_pending").Error; err != nil {
+            slog.Warn("failed to drop plaintext one-pending transaction index", "error", err)
+        }
+        if err := db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_one_pending_phone ON transactions (user_id, phone_number_hash, description) WHERE status = 'pending' AND phone_number_hash <> ''").Error; err != nil {
+            slog.Warn("failed to create one-pending transaction index (resolve duplicate pending rows first)", "error", err)
+        }
+    } else {
+        // MySQL has no partial indexes: a generated column holds the key only while the row is
+        // pending (NULL otherwise, and NULLs never collide in a unique index)
+        if !db.Migrator().HasColumn("transactions", "pending_key") {
+            if err := db.Exec("ALTER TABLE transactions ADD COLUMN pending_key CHAR(64) GENERATED ALWAYS AS (IF(status = 'pending' AND phone_number_hash <> '', SHA2(CONCAT_WS('|', user_id, phone_number_hash, description), 256), NULL)) STORED").Error; err != nil {
+                slog.Warn("failed to add transactions.pending_key", "error", err)
+            }
+        }
+        if !db.Migrator().HasIndex("transactions", "idx_transactions_one_pending_key") {
+            if err := db.Exec("CREATE UNIQUE INDEX idx_transactions_one_pending_key ON transactions (pending_key)").Error; err != nil {
+                slog.Warn("failed to create one-pending transaction index (resolve duplicate pending rows first)", "error", err)
+            }
         }
     }
 
+    // QR images used to be stored in whatsapp_sessions.qr_code; only qr_expires_at is kept now
+    if db.Migrator().HasColumn(&models.WhatsAppSession{}, "qr_code") {
+        if err := db.Migrator().DropColumn(&models.WhatsAppSession{}, "qr_code"); err != nil {
+            slog.Warn("failed to drop whatsapp_sessions.qr_code", "error", err)
+        }
+    }
+
+    // The status check was widened (connecting, banned) under a new name; drop the old one
+    if db.Migrator().HasConstraint(&models.WhatsAppSession{}, "chk_whatsapp_sessions_status") {
+        if err := db.Migrator().DropConstraint(&models.WhatsAppSession{}, "chk_whatsapp_sessions_status"); err != nil {
+            slog.Warn("failed to drop old whatsapp_sessions status check", "error", err)
+        }
+    }
+
+    // Accounts from before login tracking start their inactivity clock at their last update
+    if err := db.Model(&models.User{}).Where("last_login_at IS NULL").UpdateColumn("last_login_at", gorm.Expr("updated_at")).Error; err != nil {
+        slog.Warn("failed to backfill users.last_login_at", "error", err)
+    }
+
+    // Seal analysis results created before checksums existed
+    backfillAnalysisChecksums(db)
+
     return nil
 }
 
+// backfillAnalysisChecksums computes checksums for legacy analysis rows (hooks are skipped)
+func backfillAnalysisChecksums(db *gorm.DB) {
+	var legacy []models.AnalysisResult
+	if err := db.Where("checksum IS NULL OR checksum = ''").Find(&legacy).Error; err != nil {
+		slog.Warn("failed to load analysis results for checksum backfill", "error", err)
+		return
+	}
+	for i := range legacy {
+		checksum := legacy[i].ComputeChecksum()
+		if err := db.Model(&legacy[i]).UpdateColumn("checksum", checksum).Error; err != nil {
+			slog.Warn("failed to backfill analysis checksum", "error", err)
+		}
+	}
+	if len(legacy) > 0 {
+		slog.Info(fmt.Sprintf("backfilled checksums for %d analysis results", len(legacy)))
+	}
+}
+
+// gormConfig returns the GORM settings shared by all drivers. Prepared statements are cached per
+// connection (DB_PREPARE_STMT, default true) so hot queries such as the per-analyze payment check
+// and the token user lookup skip re-parsing on every request. SQL is logged through the standard
+// logger, so it goes through the same phone number redaction as every other log line.
+func gormConfig() *gorm.Config {
+	return &gorm.Config{
+		Logger: logger.New(log.Default(), logger.Config{
+			SlowThreshold: 200 * time.Millisecond,
+			LogLevel:      logger.Info,
+		}),
+		PrepareStmt: getEnv("DB_PREPARE_STMT", "true") != "false",
+	}
+}
+
 // getEnv gets environment variable with fallback
 func getEnv(key, fallback string) string {
 	if value := os.Getenv(key); value != "" {
@@ -209,32 +335,29 @@ func getEnv(key, fallback string) string {
 
 // GetDB returns the database instance
 func GetDB() *gorm.DB {
-	return DB
+	return current.Load()
 }
 
-// CheckAndReconnect checks if database connection is alive and reconnects if needed
+// CheckAndReconnect checks if the database is reachable. The pool is never replaced here:
+// database/sql drops broken connections and dials new ones by itself, so once the database is
+// back the next ping (and every query) gets a fresh connection from the same pool.
 func CheckAndReconnect() error {
-	if DB == nil {
+	db := current.Load()
+	if db == nil {
 		return fmt.Errorf("database not initialized")
 	}
 
-	sqlDB, err := DB.DB()
-	if err != nil {
-		return err
+	if err := pingDB(db); err != nil {
+		if Health().ConsecutiveFailures == 0 {
+			slog.Warn("Database connection lost, waiting for it to come back", "error", err)
+		}
+		recordHealth(err)
+		return fmt.Errorf("database unavailable: %v", err)
 	}
 
-	// Ping the database to check connection
-	if err := sqlDB.Ping(); err != nil {
-		log.Printf("Database connection lost, attempting to reconnect...")
-
-		// Close the old connection
-		sqlDB.Close()
-
-		// Reinitialize the database
-		InitDatabase()
-
-		return nil
+	if Health().ConsecutiveFailures > 0 {
+		slog.Info("Database reconnected")
 	}
-
+	recordHealth(nil)
 	return nil
 }
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// HealthStatus describes database availability as seen by CheckAndReconnect
+type HealthStatus struct {
+	Degraded            bool       `json:"degraded"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	DegradedSince       *time.Time `json:"degraded_since,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+var (
+	healthMu sync.RWMutex
+	health   HealthStatus
+)
+
+// degradedAfterFailures is how many failed checks in a row switch the API to read-only mode
+func degradedAfterFailures() int {
+	if v, err := strconv.Atoi(os.Getenv("DB_DEGRADED_AFTER_FAILURES")); err == nil && v > 0 {
+		return v
+	}
+	return 3
+}
+
+// recordHealth updates the degraded state after a connection check
+func recordHealth(err error) {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+
+	if err == nil {
+		if health.Degraded {
+			slog.Info(fmt.Sprintf("Database recovered after %s, leaving read-only mode", time.Since(*health.DegradedSince).Round(time.Second)))
+		}
+		health = HealthStatus{}
+		return
+	}
+
+	health.ConsecutiveFailures++
+	health.LastError = err.Error()
+	if !health.Degraded && health.ConsecutiveFailures >= degradedAfterFailures() {
+		now := time.Now()
+		health.Degraded = true
+		health.DegradedSince = &now
+		slog.Warn(fmt.Sprintf("Database unavailable (%d failed checks), API switched to read-only mode", health.ConsecutiveFailures), "error", err)
+	}
+}
+
+// IsDegraded reports whether the API is in read-only mode because the database is down
+func IsDegraded() bool {
+	healthMu.RLock()
+	defer healthMu.RUnlock()
+	return health.Degraded
+}
+
+// Health returns the current database health
+func Health() HealthStatus {
+	healthMu.RLock()
+	defer healthMu.RUnlock()
+	return health
+}
+
+// pingDB checks that the pool can reach the database
+func pingDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Ping()
+}
+
+// StartHealthMonitor runs CheckAndReconnect every DB_HEALTH_CHECK_SECONDS (default 10) so the
+// degraded mode is entered and left without waiting for a request to hit the database
+func StartHealthMonitor() {
+	interval := 10 * time.Second
+	if v, err := strconv.Atoi(os.Getenv("DB_HEALTH_CHECK_SECONDS")); err == nil && v > 0 {
+		interval = time.Duration(v) * time.Second
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := CheckAndReconnect(); err != nil {
+				slog.Warn("Database health check failed", "error", err)
+			}
+		}
+	}()
+}
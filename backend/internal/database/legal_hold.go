@@ -0,0 +1,61 @@
+package database
+
+import (
+	"log/slog"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// registerLegalHoldCallbacks makes every DELETE (soft or hard) on a model with a legal
+// hold skip rows whose hold is still active, so no delete path or purge job can remove them
+func registerLegalHoldCallbacks(db *gorm.DB) {
+	if err := db.Callback().Delete().Before("gorm:delete").Register("legal_hold:protect_delete", excludeHeldRows); err != nil {
+		slog.Warn("failed to register legal hold delete guard", "error", err)
+	}
+}
+
+func excludeHeldRows(db *gorm.DB) {
+	stmt := db.Statement
+	if stmt.Schema == nil {
+		return
+	}
+	if _, ok := stmt.Schema.FieldsByDBName["on_hold"]; !ok {
+		return
+	}
+	// Leave statements without conditions alone so GORM's global delete protection still applies
+	if _, hasWhere := stmt.Clauses["WHERE"]; !hasWhere && !hasPrimaryKeyValue(db) {
+		return
+	}
+
+	stmt.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Expr{
+			SQL: "NOT (? = ? AND (? IS NULL OR ? > ?))",
+			Vars: []interface{}{
+				clause.Column{Table: stmt.Schema.Table, Name: "on_hold"}, true,
+				clause.Column{Table: stmt.Schema.Table, Name: "hold_until"},
+				clause.Column{Table: stmt.Schema.Table, Name: "hold_until"}, time.Now(),
+			},
+		},
+	}})
+}
+
+// hasPrimaryKeyValue reports whether the statement's model carries a primary key,
+// which GORM turns into a WHERE condition inside gorm:delete
+func hasPrimaryKeyValue(db *gorm.DB) bool {
+	stmt := db.Statement
+	field := stmt.Schema.PrioritizedPrimaryField
+	if field == nil {
+		return false
+	}
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Struct:
+		_, isZero := field.ValueOf(stmt.Context, stmt.ReflectValue)
+		return !isZero
+	case reflect.Slice, reflect.Array:
+		return stmt.ReflectValue.Len() > 0
+	}
+	return false
+}
@@ -3,6 +3,7 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"log/slog"
 )
 
 // CreateTransactionsTable creates the transactions table
@@ -82,9 +83,9 @@ func CreateTransactionsTable(db *sql.DB) error {
             alterQuery := `ALTER TABLE transactions ADD COLUMN phone_number VARCHAR(50);`
             if _, err := db.Exec(alterQuery); err != nil {
                 // Log but don't fail startup
-                fmt.Printf("warning: failed to add phone_number column: %v\n", err)
+                slog.Warn("failed to add phone_number column", "error", err)
             } else {
-                fmt.Println("Added phone_number column to transactions table")
+                slog.Info("Added phone_number column to transactions table")
             }
         }
     } else {
@@ -92,7 +93,7 @@ func CreateTransactionsTable(db *sql.DB) error {
         _ = colName
     }
 
-	fmt.Println("Transactions table created successfully")
+	slog.Info("Transactions table created successfully")
 	return nil
 }
 
@@ -137,7 +138,7 @@ func CreatePaymentMethodsTable(db *sql.DB) error {
 		return fmt.Errorf("failed to insert default payment methods: %v", err)
 	}
 
-	fmt.Println("Payment methods table created successfully")
+	slog.Info("Payment methods table created successfully")
 	return nil
 }
 
@@ -172,6 +173,6 @@ func CreatePaymentCategoriesTable(db *sql.DB) error {
 		return fmt.Errorf("failed to insert default payment categories: %v", err)
 	}
 
-	fmt.Println("Payment categories table created successfully")
+	slog.Info("Payment categories table created successfully")
 	return nil
 }
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PoolConfig holds the connection pool settings (DB_MAX_IDLE_CONNS, DB_MAX_OPEN_CONNS,
+// DB_CONN_MAX_LIFETIME, DB_CONN_MAX_IDLE_TIME)
+type PoolConfig struct {
+	MaxIdleConns    int           `json:"max_idle_conns"`
+	MaxOpenConns    int           `json:"max_open_conns"`
+	ConnMaxLifetime time.Duration `json:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `json:"conn_max_idle_time"`
+}
+
+// PoolStats is a snapshot of the connection pool for the metrics endpoint
+type PoolStats struct {
+	Config            PoolConfig `json:"config"`
+	OpenConnections   int        `json:"open_connections"`
+	InUse             int        `json:"in_use"`
+	Idle              int        `json:"idle"`
+	WaitCount         int64      `json:"wait_count"`
+	WaitDurationMs    int64      `json:"wait_duration_ms"`
+	MaxIdleClosed     int64      `json:"max_idle_closed"`
+	MaxIdleTimeClosed int64      `json:"max_idle_time_closed"`
+	MaxLifetimeClosed int64      `json:"max_lifetime_closed"`
+}
+
+// LoadPoolConfig reads the pool settings from the environment
+func LoadPoolConfig() PoolConfig {
+	return PoolConfig{
+		MaxIdleConns:    envPoolInt("DB_MAX_IDLE_CONNS", 10),
+		MaxOpenConns:    envPoolInt("DB_MAX_OPEN_CONNS", 100),
+		ConnMaxLifetime: envPoolDuration("DB_CONN_MAX_LIFETIME", time.Hour),
+		ConnMaxIdleTime: envPoolDuration("DB_CONN_MAX_IDLE_TIME", 10*time.Minute),
+	}
+}
+
+// applyPool configures sqlDB with the given settings
+func applyPool(sqlDB *sql.DB, cfg PoolConfig) {
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+}
+
+// Pool returns the current pool statistics of the shared database
+func Pool() PoolStats {
+	stats := PoolStats{Config: LoadPoolConfig()}
+	db := current.Load()
+	if db == nil {
+		return stats
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return stats
+	}
+
+	s := sqlDB.Stats()
+	stats.OpenConnections = s.OpenConnections
+	stats.InUse = s.InUse
+	stats.Idle = s.Idle
+	stats.WaitCount = s.WaitCount
+	stats.WaitDurationMs = s.WaitDuration.Milliseconds()
+	stats.MaxIdleClosed = s.MaxIdleClosed
+	stats.MaxIdleTimeClosed = s.MaxIdleTimeClosed
+	stats.MaxLifetimeClosed = s.MaxLifetimeClosed
+	return stats
+}
+
+// envPoolInt parses a non-negative integer setting (0 = unlimited for DB_MAX_OPEN_CONNS)
+func envPoolInt(key string, fallback int) int {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return fallback
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < 0 {
+		slog.Warn(fmt.Sprintf("invalid %s=%q, using %d", key, raw, fallback))
+		return fallback
+	}
+	return v
+}
+
+// envPoolDuration parses a Go duration ("30m", "1h30m"); a bare number is taken as seconds.
+// 0 disables the limit.
+func envPoolDuration(key string, fallback time.Duration) time.Duration {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return fallback
+	}
+	if seconds, err := strconv.Atoi(raw); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		slog.Warn(fmt.Sprintf("invalid %s=%q, using %s", key, raw, fallback))
+		return fallback
+	}
+	return d
+}
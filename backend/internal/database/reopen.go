@@ -0,0 +1,50 @@
+package database
+
+import (
+	"log/slog"
+	"time"
+)
+
+// reopenCloseDelay is how long the replaced pool stays open for queries that already hold it
+const reopenCloseDelay = 30 * time.Second
+
+// Reopen replaces the connection pool with one opened from the current environment, e.g. after
+// DB_PASSWORD was rotated in the secret manager. The old pool is kept when the new one cannot connect.
+func Reopen() error {
+	db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	if err := pingDB(db); err != nil {
+		if sqlDB, closeErr := db.DB(); closeErr == nil {
+			sqlDB.Close()
+		}
+		return err
+	}
+
+	old := current.Swap(db)
+	slog.Info("Database connection pool reopened")
+	if old != nil {
+		if sqlDB, err := old.DB(); err == nil {
+			time.AfterFunc(reopenCloseDelay, func() { sqlDB.Close() })
+		}
+	}
+	return nil
+}
+
+// Connect opens the connection pool without migrating, for commands that only need to reach the
+// database (e.g. the self-check). Unlike InitDatabase it returns the error instead of exiting.
+func Connect() error {
+	db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	if err := pingDB(db); err != nil {
+		if sqlDB, closeErr := db.DB(); closeErr == nil {
+			sqlDB.Close()
+		}
+		return err
+	}
+	current.Store(db)
+	return nil
+}
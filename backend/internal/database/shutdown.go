@@ -0,0 +1,14 @@
+package database
+
+// Close closes the connection pool; called last during shutdown, after pending writes
+func Close() error {
+	db := current.Load()
+	if db == nil {
+		return nil
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
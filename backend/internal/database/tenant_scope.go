@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type tenantScopeKey struct{}
+
+// tenantScope is stored in request contexts; a nil TenantID means the default (first-party) brand
+type tenantScope struct {
+	TenantID *uint
+}
+
+// WithTenantScope marks ctx as tenant-scoped. Every GORM statement executed with this
+// context against a model that has a tenant_id column is automatically filtered by it.
+// tenantID == nil scopes to the default brand (tenant_id IS NULL).
+func WithTenantScope(ctx context.Context, tenantID *uint) context.Context {
+	return context.WithValue(ctx, tenantScopeKey{}, tenantScope{TenantID: tenantID})
+}
+
+// TenantIDFromContext returns the tenant scope carried by ctx.
+// ok is false for unscoped (system/background) contexts.
+func TenantIDFromContext(ctx context.Context) (tenantID *uint, ok bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	scope, ok := ctx.Value(tenantScopeKey{}).(tenantScope)
+	if !ok {
+		return nil, false
+	}
+	return scope.TenantID, true
+}
+
+// WithContext returns the shared DB bound to ctx so tenant scopes apply
+func WithContext(ctx context.Context) *gorm.DB {
+	db := current.Load()
+	if db == nil || ctx == nil {
+		return db
+	}
+	return db.WithContext(ctx)
+}
+
+// TenantScopeFor returns a GORM scope filtering column by the tenant in ctx.
+// Use it for Table()/Raw joins that the automatic callbacks cannot see (no model schema).
+func TenantScopeFor(ctx context.Context, column string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		tenantID, ok := TenantIDFromContext(ctx)
+		if !ok {
+			return db
+		}
+		if tenantID == nil {
+			return db.Where(column + " IS NULL")
+		}
+		return db.Where(column+" = ?", *tenantID)
+	}
+}
+
+// registerTenantCallbacks installs the GORM callbacks enforcing tenant isolation
+func registerTenantCallbacks(db *gorm.DB) {
+	cb := db.Callback()
+	if err := cb.Query().Before("gorm:query").Register("tenant:scope_query", applyTenantWhere); err != nil {
+		slog.Warn("failed to register tenant query scope", "error", err)
+	}
+	if err := cb.Update().Before("gorm:update").Register("tenant:scope_update", applyTenantWhere); err != nil {
+		slog.Warn("failed to register tenant update scope", "error", err)
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("tenant:scope_delete", applyTenantWhere); err != nil {
+		slog.Warn("failed to register tenant delete scope", "error", err)
+	}
+	if err := cb.Create().Before("gorm:create").Register("tenant:assign_create", assignTenantOnCreate); err != nil {
+		slog.Warn("failed to register tenant create scope", "error", err)
+	}
+}
+
+// applyTenantWhere adds "tenant_id = ?" (or IS NULL for the default brand) to scoped statements
+func applyTenantWhere(db *gorm.DB) {
+	stmt := db.Statement
+	tenantID, ok := TenantIDFromContext(stmt.Context)
+	if !ok || stmt.Schema == nil {
+		return
+	}
+	if _, hasColumn := stmt.Schema.FieldsByDBName["tenant_id"]; !hasColumn {
+		return
+	}
+
+	column := clause.Column{Table: stmt.Schema.Table, Name: "tenant_id"}
+	var value interface{}
+	if tenantID != nil {
+		value = *tenantID
+	}
+	stmt.AddClause(clause.Where{Exprs: []clause.Expression{clause.Eq{Column: column, Value: value}}})
+}
+
+// assignTenantOnCreate stamps new rows with the tenant from the context when not already set
+func assignTenantOnCreate(db *gorm.DB) {
+	stmt := db.Statement
+	tenantID, ok := TenantIDFromContext(stmt.Context)
+	if !ok || tenantID == nil || stmt.Schema == nil {
+		return
+	}
+	field := stmt.Schema.LookUpField("TenantID")
+	if field == nil {
+		return
+	}
+
+	id := *tenantID
+	setIfZero := func(rv reflect.Value) {
+		if _, isZero := field.ValueOf(stmt.Context, rv); isZero {
+			_ = field.Set(stmt.Context, rv, &id)
+		}
+	}
+
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Struct:
+		setIfZero(stmt.ReflectValue)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			setIfZero(reflect.Indirect(stmt.ReflectValue.Index(i)))
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package fieldcrypt
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// wrapAAD binds wrapped data keys to their purpose
+var wrapAAD = []byte("fieldcrypt data key")
+
+// ErrUnknownMasterKey is returned when a data key was wrapped by a master key the provider does not hold
+var ErrUnknownMasterKey = errors.New("unknown master key")
+
+// KeyProvider wraps and unwraps data keys with a master key. A KMS-backed provider implements it
+// by calling the KMS encrypt/decrypt API; StaticKeyProvider keeps master keys in memory.
+type KeyProvider interface {
+	// KeyID identifies the master key new data keys are wrapped with
+	KeyID() string
+	// Wrap encrypts a data key with the active master key
+	Wrap(dataKey []byte) ([]byte, error)
+	// Unwrap decrypts a data key wrapped by the master key masterKeyID
+	Unwrap(masterKeyID string, wrapped []byte) ([]byte, error)
+}
+
+// StaticKeyProvider holds the master keys in memory (e.g. read from the environment).
+// Rotation: add the new key, make it active and rewrap the data keys; drop the old key afterwards.
+type StaticKeyProvider struct {
+	activeID string
+	keys     map[string][]byte
+}
+
+// NewStaticKeyProvider creates a provider wrapping with keys[activeID]
+func NewStaticKeyProvider(activeID string, keys map[string][]byte) (*StaticKeyProvider, error) {
+	for id, key := range keys {
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("master key %s must be %d bytes, got %d", id, KeySize, len(key))
+		}
+	}
+	if _, ok := keys[activeID]; !ok {
+		return nil, fmt.Errorf("active master key %q is not configured", activeID)
+	}
+	return &StaticKeyProvider{activeID: activeID, keys: keys}, nil
+}
+
+// KeyID implements KeyProvider
+func (p *StaticKeyProvider) KeyID() string {
+	return p.activeID
+}
+
+// Wrap implements KeyProvider
+func (p *StaticKeyProvider) Wrap(dataKey []byte) ([]byte, error) {
+	aead, err := newAEAD(p.keys[p.activeID])
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, dataKey, wrapAAD), nil
+}
+
+// Unwrap implements KeyProvider
+func (p *StaticKeyProvider) Unwrap(masterKeyID string, wrapped []byte) ([]byte, error) {
+	key, ok := p.keys[masterKeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMasterKey, masterKeyID)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, ErrMalformedValue
+	}
+	dataKey, err := aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], wrapAAD)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key with master key %s: %v", masterKeyID, err)
+	}
+	return dataKey, nil
+}
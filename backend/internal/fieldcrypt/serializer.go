@@ -0,0 +1,43 @@
+package fieldcrypt
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+func init() {
+	schema.RegisterSerializer("encrypted", Serializer{})
+}
+
+// Serializer is the GORM serializer behind `gorm:"serializer:encrypted"` on string fields.
+// Values are sealed for the field's column on write and opened on read. Note that map based
+// Updates bypass serializers: seal those values with Seal.
+type Serializer struct{}
+
+// Scan implements schema.SerializerInterface
+func (Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var raw string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+	default:
+		return fmt.Errorf("unsupported encrypted value %T for %s", dbValue, field.DBName)
+	}
+	plaintext, err := Open(field.DBName, raw)
+	if err != nil {
+		return fmt.Errorf("%s: %w", field.DBName, err)
+	}
+	return field.Set(ctx, dst, plaintext)
+}
+
+// Value implements schema.SerializerValuerInterface
+func (Serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	plaintext, _ := fieldValue.(string)
+	return Seal(field.DBName, plaintext)
+}
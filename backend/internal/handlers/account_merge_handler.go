@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"back_wa/internal/middleware"
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+	"back_wa/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+type AccountMergeHandler struct {
+	mergeService *services.AccountMergeService
+}
+
+func NewAccountMergeHandler(repos *repository.Repositories) *AccountMergeHandler {
+	return &AccountMergeHandler{
+		mergeService: services.NewAccountMergeService(repos.Merges),
+	}
+}
+
+// MergeAccounts handles POST /api/admin/users/merge
+func (mh *AccountMergeHandler) MergeAccounts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims := middleware.ClaimsFromContext(r.Context())
+
+	var req services.MergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.SourceUserID == 0 || req.TargetUserID == 0 {
+		http.Error(w, "source_user_id and target_user_id are required", http.StatusBadRequest)
+		return
+	}
+
+	merge, err := mh.mergeService.WithContext(r.Context()).Merge(req, claims.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrMergeUserNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, services.ErrMergeSessionActive), errors.Is(err, services.ErrMergePhoneMismatch),
+			errors.Is(err, services.ErrMergeTenantMismatch), errors.Is(err, services.ErrMergeTargetInactive):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, services.ErrMergeSameUser), errors.Is(err, services.ErrMergeReasonRequired):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "Failed to merge accounts: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	mh.writeMerge(w, merge)
+}
+
+// RollbackMerge handles POST /api/admin/users/merges/{id}/rollback
+func (mh *AccountMergeHandler) RollbackMerge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims := middleware.ClaimsFromContext(r.Context())
+
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	merge, err := mh.mergeService.WithContext(r.Context()).Rollback(uint(id), claims.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrMergeNotFound):
+			http.Error(w, "Merge not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrMergeNotRollbackable):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, "Failed to roll back merge: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	mh.writeMerge(w, merge)
+}
+
+// ListMerges handles GET /api/admin/users/merges?user_id=&limit=
+func (mh *AccountMergeHandler) ListMerges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, _ := strconv.ParseUint(r.URL.Query().Get("user_id"), 10, 32)
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	merges, err := mh.mergeService.WithContext(r.Context()).ListMerges(uint(userID), limit)
+	if err != nil {
+		http.Error(w, "Failed to load merges", http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]map[string]interface{}, 0, len(merges))
+	for i := range merges {
+		items = append(items, mergeView(&merges[i]))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"merges":  items,
+	})
+}
+
+func (mh *AccountMergeHandler) writeMerge(w http.ResponseWriter, merge *models.AccountMerge) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"merge":   mergeView(merge),
+	})
+}
+
+// mergeView adds the moved row counts to the audit record
+func mergeView(merge *models.AccountMerge) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                merge.ID,
+		"source_user_id":    merge.SourceUserID,
+		"target_user_id":    merge.TargetUserID,
+		"admin_id":          merge.AdminID,
+		"reason":            merge.Reason,
+		"status":            merge.Status,
+		"source_was_active": merge.SourceWasActive,
+		"moved":             merge.MovedCounts(),
+		"rolled_back_by":    merge.RolledBackBy,
+		"rolled_back_at":    merge.RolledBackAt,
+		"created_at":        merge.CreatedAt,
+	}
+}
@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/middleware"
+	"back_wa/internal/repository"
+	"back_wa/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+type AdminHandler struct {
+	authService    *services.AuthService
+	adminService   *services.AdminService
+	lockoutService *services.LoginLockoutService
+}
+
+func NewAdminHandler(repos *repository.Repositories) *AdminHandler {
+	return &AdminHandler{
+		authService:    services.NewAuthService(repos.Users, repos.AuthSessions),
+		adminService:   services.NewAdminService(repos),
+		lockoutService: services.NewLoginLockoutService(repos.Users, repos.Tenants),
+	}
+}
+
+// ListUsers handles GET /api/admin/users?q=&role=&active=&page=&limit=
+func (ah *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := services.AdminUserFilter{Query: query.Get("q"), Role: query.Get("role")}
+	if raw := query.Get("active"); raw != "" {
+		active, err := strconv.ParseBool(raw)
+		if err != nil {
+			http.Error(w, "active must be true or false", http.StatusBadRequest)
+			return
+		}
+		filter.Active = &active
+	}
+
+	page := adminPage(r)
+	users, total, err := ah.adminService.WithContext(r.Context()).ListUsers(filter, page)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to list users", "error", err)
+		http.Error(w, "Failed to list users", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    users,
+		"total":   total,
+		"page":    page.Page,
+		"limit":   page.Limit,
+	})
+}
+
+// DeactivateUser handles POST /api/admin/users/{id}/deactivate
+func (ah *AdminHandler) DeactivateUser(w http.ResponseWriter, r *http.Request) {
+	ah.setUserActive(w, r, false)
+}
+
+// ActivateUser handles POST /api/admin/users/{id}/activate
+func (ah *AdminHandler) ActivateUser(w http.ResponseWriter, r *http.Request) {
+	ah.setUserActive(w, r, true)
+}
+
+func (ah *AdminHandler) setUserActive(w http.ResponseWriter, r *http.Request, active bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims := middleware.ClaimsFromContext(r.Context())
+
+	userID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+	if !active && uint(userID) == claims.UserID {
+		http.Error(w, "Admins cannot deactivate their own account", http.StatusBadRequest)
+		return
+	}
+
+	user, err := ah.adminService.WithContext(r.Context()).SetUserActive(uint(userID), active)
+	if errors.Is(err, services.ErrAdminUserNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error(fmt.Sprintf("Failed to update user %d", userID), "error", err, "user_id", userID)
+		http.Error(w, "Failed to update user", http.StatusInternalServerError)
+		return
+	}
+	logging.FromContext(r.Context()).Info(fmt.Sprintf("Admin %d set user %d active=%t", claims.UserID, userID, active), "admin_id", claims.UserID, "user_id", userID)
+	// A deactivated account is signed out at once instead of when its tokens expire
+	if !active {
+		if _, err := ah.authService.WithContext(r.Context()).RevokeSessions(uint(userID), ""); err != nil {
+			logging.FromContext(r.Context()).Warn("Failed to revoke sessions of deactivated user", "user_id", userID, "error", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    user,
+	})
+}
+
+// UserLockout handles GET /api/admin/users/{id}/lockout (failed-login state) and
+// DELETE (clear the count and lift a backoff or lockout)
+func (ah *AdminHandler) UserLockout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims := middleware.ClaimsFromContext(r.Context())
+
+	userID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	lockout := ah.lockoutService.WithContext(r.Context())
+	state, err := lockout.State(uint(userID), time.Now())
+	if err == nil && r.Method == http.MethodDelete {
+		if err = lockout.Reset(uint(userID)); err == nil {
+			logging.FromContext(r.Context()).Info(fmt.Sprintf("Admin %d cleared the login lockout of user %d", claims.UserID, userID), "audit", true, "admin_id", claims.UserID, "user_id", userID)
+			state = &services.LoginLockoutState{UserID: uint(userID)}
+		}
+	}
+	if errors.Is(err, services.ErrAdminUserNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error(fmt.Sprintf("Failed to load login lockout of user %d", userID), "error", err, "user_id", userID)
+		http.Error(w, "Failed to load login lockout", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    state,
+	})
+}
+
+// ListTransactions handles GET /api/admin/transactions?status=&user_id=&page=&limit=
+func (ah *AdminHandler) ListTransactions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter := services.AdminTransactionFilter{Status: r.URL.Query().Get("status")}
+	if raw := r.URL.Query().Get("user_id"); raw != "" {
+		userID, err := strconv.Atoi(raw)
+		if err != nil {
+			http.Error(w, "Invalid user_id", http.StatusBadRequest)
+			return
+		}
+		filter.UserID = userID
+	}
+
+	page := adminPage(r)
+	transactions, total, err := ah.adminService.WithContext(r.Context()).ListTransactions(filter, page)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to list transactions", "error", err)
+		http.Error(w, "Failed to list transactions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    transactions,
+		"total":   total,
+		"page":    page.Page,
+		"limit":   page.Limit,
+	})
+}
+
+// UserAnalyses handles GET /api/admin/users/{id}/analyses?page=&limit= (requires a data access request)
+func (ah *AdminHandler) UserAnalyses(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	page := adminPage(r)
+	results, total, err := ah.adminService.WithContext(r.Context()).ListUserAnalyses(uint(userID), page)
+	if err != nil {
+		logging.FromContext(r.Context()).Error(fmt.Sprintf("Failed to list analyses of user %d", userID), "error", err, "user_id", userID)
+		http.Error(w, "Failed to list analyses", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    results,
+		"total":   total,
+		"page":    page.Page,
+		"limit":   page.Limit,
+	})
+}
+
+// ListSessions handles GET /api/admin/sessions?status=&page=&limit=
+func (ah *AdminHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	page := adminPage(r)
+	sessions, total, err := ah.adminService.WithContext(r.Context()).ListSessions(r.URL.Query().Get("status"), page)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to list sessions", "error", err)
+		http.Error(w, "Failed to list sessions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    sessions,
+		"total":   total,
+		"page":    page.Page,
+		"limit":   page.Limit,
+	})
+}
+
+// ChurnReport handles GET /api/admin/reports/churn?days=90&idle_days=14&limit=100&format=csv|json.
+// The CSV lists the at-risk users only, for importing into retention campaigns.
+func (ah *AdminHandler) ChurnReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	opts := services.ChurnReportOptions{}
+	for name, target := range map[string]*int{"days": &opts.Days, "idle_days": &opts.IdleDays, "limit": &opts.Limit} {
+		raw := q.Get(name)
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 {
+			http.Error(w, name+" must be a positive number", http.StatusBadRequest)
+			return
+		}
+		*target = v
+	}
+	if opts.Limit > 5000 {
+		opts.Limit = 5000
+	}
+
+	report, err := ah.adminService.WithContext(r.Context()).ChurnReport(opts)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to build churn report", "error", err)
+		http.Error(w, "Failed to build churn report", http.StatusInternalServerError)
+		return
+	}
+
+	if strings.ToLower(q.Get("format")) == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"churn_risk_%s.csv\"", report.GeneratedAt.Format("20060102")))
+		stream := newCSVStream(w, []string{"user_id", "username", "email", "phone_number", "paid_at", "amount",
+			"days_since_payment", "scans_since_payment", "last_scan_at", "lowest_rating", "reasons"})
+		for _, user := range report.AtRisk {
+			lastScan, rating := "", ""
+			if user.LastScanAt != nil {
+				lastScan = user.LastScanAt.Format(time.RFC3339)
+			}
+			if user.LowestRating != nil {
+				rating = strconv.Itoa(*user.LowestRating)
+			}
+			stream.Write([]string{
+				strconv.FormatUint(uint64(user.UserID), 10),
+				user.Username,
+				user.Email,
+				user.PhoneNumber,
+				user.PaidAt.Format(time.RFC3339),
+				strconv.FormatFloat(user.Amount, 'f', 2, 64),
+				strconv.Itoa(user.DaysSincePayment),
+				strconv.FormatInt(user.ScansSincePayment, 10),
+				lastScan,
+				rating,
+				strings.Join(user.Reasons, ";"),
+			})
+		}
+		stream.Flush()
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    report,
+	})
+}
+
+// adminPage reads ?page= (default 1) and ?limit= (default 50, max 200)
+func adminPage(r *http.Request) services.AdminPage {
+	page := services.AdminPage{Page: 1, Limit: 50}
+	if v, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && v > 0 {
+		page.Page = v
+	}
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 && v <= 200 {
+		page.Limit = v
+	}
+	return page
+}
+
+// LogRedaction handles GET/PUT /api/admin/log-redaction. PUT {"reveal_minutes": 30} logs full phone numbers
+// for a limited time (max 240 minutes); {"reveal_minutes": 0} masks them again.
+func (ah *AdminHandler) LogRedaction(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.ClaimsFromContext(r.Context())
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req struct {
+			RevealMinutes int `json:"reveal_minutes"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.RevealMinutes < 0 || time.Duration(req.RevealMinutes)*time.Minute > services.MaxPhoneRevealDuration {
+			http.Error(w, fmt.Sprintf("reveal_minutes must be between 0 and %d", int(services.MaxPhoneRevealDuration.Minutes())), http.StatusBadRequest)
+			return
+		}
+		services.RevealPhonesInLogs(time.Duration(req.RevealMinutes) * time.Minute)
+		logging.FromContext(r.Context()).Info(fmt.Sprintf("Admin %d set phone number reveal in logs to %d minutes", claims.UserID, req.RevealMinutes), "admin_id", claims.UserID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"redacted":     services.PhoneRedactionActive(),
+			"reveal_until": services.PhoneRevealUntil(),
+		},
+	})
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/middleware"
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+	"back_wa/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+type AnnouncementHandler struct {
+	authService         *services.AuthService
+	announcementService *services.AnnouncementService
+	entitlements        *services.EntitlementService
+}
+
+func NewAnnouncementHandler(repos *repository.Repositories) *AnnouncementHandler {
+	return &AnnouncementHandler{
+		authService:         services.NewAuthService(repos.Users, repos.AuthSessions),
+		announcementService: services.NewAnnouncementService(repos.Announcements),
+		entitlements:        services.NewEntitlementService(repos),
+	}
+}
+
+// ListCurrent handles GET /api/announcements (public). Anonymous callers get the banners for
+// everyone; with a Bearer token the banners for the user's tier (paid or trial) are included.
+func (ah *AnnouncementHandler) ListCurrent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	audiences := []string{models.AnnouncementAudienceAll}
+	authHeader := r.Header.Get("Authorization")
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if authHeader != "" && tokenString != authHeader {
+		if claims, err := ah.authService.ValidateToken(tokenString); err == nil {
+			tier, err := ah.entitlements.WithContext(r.Context()).Tier(claims.UserID)
+			if err != nil {
+				logging.FromContext(r.Context()).Warn("Failed to resolve tier for announcements", "user_id", claims.UserID, "error", err)
+			} else {
+				audiences = append(audiences, tier)
+			}
+		}
+	}
+
+	announcements, err := ah.announcementService.WithContext(r.Context()).Current(audiences)
+	if err != nil {
+		http.Error(w, "Failed to get announcements", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    announcements,
+	})
+}
+
+// AdminList handles GET /api/admin/announcements, including inactive and expired banners
+func (ah *AnnouncementHandler) AdminList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	announcements, err := ah.announcementService.WithContext(r.Context()).List()
+	if err != nil {
+		http.Error(w, "Failed to get announcements", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    announcements,
+	})
+}
+
+// Create handles POST /api/admin/announcements
+// ({"title", "message", "kind", "audience", "link", "starts_at", "ends_at", "active"}; active defaults to true)
+func (ah *AnnouncementHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims := middleware.ClaimsFromContext(r.Context())
+
+	announcement := models.Announcement{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&announcement); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := ah.announcementService.WithContext(r.Context()).Create(&announcement, claims.UserID); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logging.FromContext(r.Context()).Info(fmt.Sprintf("Admin %d published announcement %d (%s, audience %s)", claims.UserID, announcement.ID, announcement.Kind, announcement.Audience), "admin_id", claims.UserID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    announcement,
+	})
+}
+
+// Update handles PUT /api/admin/announcements/{id} with the full announcement
+func (ah *AnnouncementHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims := middleware.ClaimsFromContext(r.Context())
+
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	changes := models.Announcement{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	announcement, err := ah.announcementService.WithContext(r.Context()).Update(uint(id), &changes)
+	if err != nil {
+		if errors.Is(err, services.ErrAnnouncementNotFound) {
+			http.Error(w, "Announcement not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logging.FromContext(r.Context()).Info(fmt.Sprintf("Admin %d updated announcement %d", claims.UserID, announcement.ID), "admin_id", claims.UserID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    announcement,
+	})
+}
+
+// Delete handles DELETE /api/admin/announcements/{id}
+func (ah *AnnouncementHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims := middleware.ClaimsFromContext(r.Context())
+
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := ah.announcementService.WithContext(r.Context()).Delete(uint(id)); err != nil {
+		if errors.Is(err, services.ErrAnnouncementNotFound) {
+			http.Error(w, "Announcement not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete announcement", http.StatusInternalServerError)
+		return
+	}
+	logging.FromContext(r.Context()).Info(fmt.Sprintf("Admin %d deleted announcement %d", claims.UserID, id), "admin_id", claims.UserID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "id": id})
+}
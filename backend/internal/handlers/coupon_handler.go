@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/middleware"
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+	"back_wa/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+type CouponHandler struct {
+	authService   *services.AuthService
+	couponService *services.CouponService
+}
+
+func NewCouponHandler(repos *repository.Repositories) *CouponHandler {
+	return &CouponHandler{
+		authService:   services.NewAuthService(repos.Users, repos.AuthSessions),
+		couponService: services.NewCouponService(repos.Coupons),
+	}
+}
+
+// validateCouponRequest is the body of POST /api/coupons/validate
+type validateCouponRequest struct {
+	Code   string  `json:"code"`
+	Amount float64 `json:"amount"`
+}
+
+// ValidateCoupon handles POST /api/coupons/validate: the discount a code gives on an amount,
+// without using it. Invalid codes are answered with 422 and the reason in error_type.
+func (ch *CouponHandler) ValidateCoupon(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := ch.claims(r); !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req validateCouponRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Code) == "" || req.Amount <= 0 {
+		http.Error(w, "code and a positive amount are required", http.StatusBadRequest)
+		return
+	}
+
+	quote, err := ch.couponService.WithContext(r.Context()).Quote(req.Code, req.Amount)
+	if errorType := services.CouponErrorType(err); errorType != "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":    false,
+			"error":      services.CouponMessage(err),
+			"error_type": errorType,
+		})
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to validate coupon", "error", err)
+		http.Error(w, "Failed to validate coupon", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    quote,
+	})
+}
+
+// ListCoupons handles GET /api/admin/coupons
+func (ch *CouponHandler) ListCoupons(w http.ResponseWriter, r *http.Request) {
+	coupons, err := ch.couponService.WithContext(r.Context()).ListCoupons()
+	if err != nil {
+		http.Error(w, "Failed to get coupons", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    coupons,
+	})
+}
+
+// CreateCoupon handles POST /api/admin/coupons
+func (ch *CouponHandler) CreateCoupon(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.ClaimsFromContext(r.Context())
+
+	// New coupons can be used right away unless the body says otherwise
+	coupon := models.Coupon{IsActive: true}
+	if err := json.NewDecoder(r.Body).Decode(&coupon); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := coupon.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := ch.couponService.WithContext(r.Context()).CreateCoupon(&coupon); err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "duplicate") || strings.Contains(strings.ToLower(err.Error()), "unique") {
+			http.Error(w, "A coupon with this code already exists", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to create coupon", http.StatusInternalServerError)
+		return
+	}
+	logging.FromContext(r.Context()).Info(fmt.Sprintf("Admin %d created coupon %s", claims.UserID, coupon.Code),
+		"audit", true, "admin_id", claims.UserID, "coupon_id", coupon.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    coupon,
+	})
+}
+
+// UpdateCoupon handles PUT /api/admin/coupons/{id}
+func (ch *CouponHandler) UpdateCoupon(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.ClaimsFromContext(r.Context())
+
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid coupon ID", http.StatusBadRequest)
+		return
+	}
+
+	changes := models.Coupon{IsActive: true}
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := changes.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	coupon, err := ch.couponService.WithContext(r.Context()).UpdateCoupon(uint(id), &changes)
+	if err != nil {
+		if errors.Is(err, services.ErrCouponNotFound) {
+			http.Error(w, "Coupon not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to update coupon", http.StatusInternalServerError)
+		return
+	}
+	logging.FromContext(r.Context()).Info(fmt.Sprintf("Admin %d updated coupon %s", claims.UserID, coupon.Code),
+		"audit", true, "admin_id", claims.UserID, "coupon_id", coupon.ID, "is_active", coupon.IsActive)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    coupon,
+	})
+}
+
+// claims validates the bearer token
+func (ch *CouponHandler) claims(r *http.Request) (*services.JWTClaims, bool) {
+	authHeader := r.Header.Get("Authorization")
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if authHeader == "" || tokenString == authHeader {
+		return nil, false
+	}
+	claims, err := ch.authService.ValidateToken(tokenString)
+	return claims, err == nil
+}
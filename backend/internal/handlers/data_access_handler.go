@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/middleware"
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+	"back_wa/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+type DataAccessHandler struct {
+	dataAccessService *services.DataAccessService
+}
+
+func NewDataAccessHandler(repos *repository.Repositories) *DataAccessHandler {
+	return &DataAccessHandler{
+		dataAccessService: services.NewDataAccessService(repos.DataAccess, repos.Users),
+	}
+}
+
+// CreateRequest handles POST /api/admin/data-access-requests
+// ({"user_id": 42, "reason": "Ticket #123: refund dispute", "duration_minutes": 60})
+func (dh *DataAccessHandler) CreateRequest(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.ClaimsFromContext(r.Context())
+
+	var req models.CreateDataAccessRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	request, err := dh.dataAccessService.WithContext(r.Context()).Open(claims.UserID, req)
+	if errors.Is(err, services.ErrAdminUserNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to create data access request", "error", err)
+		http.Error(w, "Failed to create data access request", http.StatusInternalServerError)
+		return
+	}
+	logging.FromContext(r.Context()).Info(fmt.Sprintf("Admin %d opened data access request %d for user %d until %s", claims.UserID, request.ID, request.UserID, request.ExpiresAt.Format("2006-01-02 15:04")), "admin_id", claims.UserID, "user_id", request.UserID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    request,
+	})
+}
+
+// ListRequests handles GET /api/admin/data-access-requests?user_id=&admin_id=&page=&limit=
+func (dh *DataAccessHandler) ListRequests(w http.ResponseWriter, r *http.Request) {
+	var ids [2]uint
+	for i, name := range []string{"user_id", "admin_id"} {
+		raw := r.URL.Query().Get(name)
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			http.Error(w, "Invalid "+name, http.StatusBadRequest)
+			return
+		}
+		ids[i] = uint(v)
+	}
+
+	page := adminPage(r)
+	requests, total, err := dh.dataAccessService.WithContext(r.Context()).List(ids[0], ids[1], page)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to list data access requests", "error", err)
+		http.Error(w, "Failed to list data access requests", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    requests,
+		"total":   total,
+		"page":    page.Page,
+		"limit":   page.Limit,
+	})
+}
+
+// RevokeRequest handles POST /api/admin/data-access-requests/{id}/revoke (own requests only)
+func (dh *DataAccessHandler) RevokeRequest(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.ClaimsFromContext(r.Context())
+
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid request id", http.StatusBadRequest)
+		return
+	}
+
+	request, err := dh.dataAccessService.WithContext(r.Context()).Revoke(uint(id), claims.UserID)
+	if errors.Is(err, services.ErrDataAccessRequestNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error(fmt.Sprintf("Failed to revoke data access request %d", id), "error", err)
+		http.Error(w, "Failed to revoke data access request", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    request,
+	})
+}
+
+// GetAuditLog handles GET /api/admin/data-access-requests/{id}/log
+func (dh *DataAccessHandler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid request id", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := dh.dataAccessService.WithContext(r.Context()).AuditLog(uint(id))
+	if err != nil {
+		logging.FromContext(r.Context()).Error(fmt.Sprintf("Failed to read data access log %d", id), "error", err)
+		http.Error(w, "Failed to read data access log", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    entries,
+	})
+}
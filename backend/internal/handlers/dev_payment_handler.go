@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+	"back_wa/internal/services"
+)
+
+// simulatedPaymentChannel marks transactions paid through SimulatePayment
+const simulatedPaymentChannel = "SIMULATED"
+
+// SimulatePayment handles POST /api/dev/simulate-payment (ENVIRONMENT=development only): marks one
+// of the caller's pending transactions as paid through UpdateTransactionStatus, exactly like a
+// gateway webhook, so subscriptions, entitlements and notifications can be tested without paying.
+// Body (optional): {"external_id": "..."}; without it the newest pending transaction is paid.
+func (ph *PaymentHandler) SimulatePayment(w http.ResponseWriter, r *http.Request) {
+	if !services.IsDevelopment() {
+		http.NotFound(w, r)
+		return
+	}
+
+	userID := ph.getUserIDFromToken(r)
+	if userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		ExternalID string `json:"external_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	payments := ph.paymentService.WithContext(r.Context())
+	var transaction *models.Transaction
+	if req.ExternalID != "" {
+		found, err := payments.GetTransactionByExternalID(req.ExternalID)
+		if err != nil || found.UserID != userID {
+			http.Error(w, "Transaction not found", http.StatusNotFound)
+			return
+		}
+		transaction = found
+	} else {
+		// Newest pending transaction
+		transactions, _, err := payments.GetUserTransactions(userID, services.TransactionQuery{
+			ListQuery: repository.ListQuery{Limit: 1},
+			Statuses:  []string{"pending"},
+		})
+		if err != nil {
+			http.Error(w, "Failed to load transactions", http.StatusInternalServerError)
+			return
+		}
+		if len(transactions) == 0 {
+			http.Error(w, "No pending transaction", http.StatusNotFound)
+			return
+		}
+		transaction = &transactions[0]
+	}
+	if transaction.Status != "pending" {
+		http.Error(w, fmt.Sprintf("Transaction is %s, only pending transactions can be paid", transaction.Status), http.StatusConflict)
+		return
+	}
+
+	if err := payments.UpdateTransactionStatus(transaction.ExternalID, "PAID", simulatedPaymentChannel); err != nil {
+		logging.FromContext(r.Context()).Error(fmt.Sprintf("Failed to simulate payment of %s", transaction.ExternalID), "error", err, "external_id", transaction.ExternalID)
+		http.Error(w, "Failed to update transaction", http.StatusInternalServerError)
+		return
+	}
+	logging.FromContext(r.Context()).Warn(fmt.Sprintf("Simulated payment of transaction %s", transaction.ExternalID), "external_id", transaction.ExternalID, "user_id", userID)
+
+	updated, err := payments.GetTransactionByExternalID(transaction.ExternalID)
+	if err != nil {
+		http.Error(w, "Failed to load transaction", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    updated,
+	})
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+	"back_wa/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+type FeedbackHandler struct {
+	authService     *services.AuthService
+	feedbackService *services.FeedbackService
+}
+
+func NewFeedbackHandler(repos *repository.Repositories) *FeedbackHandler {
+	return &FeedbackHandler{
+		authService:     services.NewAuthService(repos.Users, repos.AuthSessions),
+		feedbackService: services.NewFeedbackService(repos.Feedback, repos.Analyses),
+	}
+}
+
+// SubmitFeedback handles POST /api/analysis/{id}/feedback ({"rating": 1-5, "comment": "..."})
+func (fh *FeedbackHandler) SubmitFeedback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	authHeader := r.Header.Get("Authorization")
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if authHeader == "" || tokenString == authHeader {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	claims, err := fh.authService.ValidateToken(tokenString)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	analysisID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid analysis ID", http.StatusBadRequest)
+		return
+	}
+
+	var feedback models.AnalysisFeedback
+	if err := json.NewDecoder(r.Body).Decode(&feedback); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := fh.feedbackService.WithContext(r.Context()).Submit(claims.UserID, uint(analysisID), &feedback); err != nil {
+		if errors.Is(err, services.ErrAnalysisNotFound) {
+			http.Error(w, "Analysis not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, services.ErrInvalidFeedback) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logging.FromContext(r.Context()).Error(fmt.Sprintf("Failed to save feedback for analysis %d", analysisID), "user_id", claims.UserID, "error", err)
+		http.Error(w, "Failed to save feedback", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    feedback,
+	})
+}
+
+// GetSummary handles GET /api/admin/feedback/summary?days=30 (default: all feedback)
+func (fh *FeedbackHandler) GetSummary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var since *time.Time
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		days, err := strconv.Atoi(raw)
+		if err != nil || days <= 0 {
+			http.Error(w, "days must be a positive number", http.StatusBadRequest)
+			return
+		}
+		from := time.Now().AddDate(0, 0, -days)
+		since = &from
+	}
+
+	summary, err := fh.feedbackService.WithContext(r.Context()).Summary(since)
+	if err != nil {
+		http.Error(w, "Failed to get feedback summary", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    summary,
+	})
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/repository"
+	"back_wa/internal/services"
+)
+
+type GoalHandler struct {
+	authService *services.AuthService
+	goalService *services.GoalService
+}
+
+func NewGoalHandler(repos *repository.Repositories) *GoalHandler {
+	return &GoalHandler{
+		authService: services.NewAuthService(repos.Users, repos.AuthSessions),
+		goalService: services.NewGoalService(repos.Goals, repos.Analyses),
+	}
+}
+
+// Goals handles GET, POST and DELETE /api/user/goals.
+// POST body: {"target_strength": "Baik", "parameter_goals": [{"key": "total_contacts", "target": 200}]}
+func (gh *GoalHandler) Goals(w http.ResponseWriter, r *http.Request) {
+	claims := gh.claimsFromRequest(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	goals := gh.goalService.WithContext(r.Context())
+
+	switch r.Method {
+	case http.MethodGet:
+		progress, err := goals.Progress(claims.UserID)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Failed to load goal", "user_id", claims.UserID, "error", err)
+			http.Error(w, "Failed to load goal", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": progress})
+
+	case http.MethodPost:
+		var req services.GoalRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		progress, err := goals.SetGoal(claims.UserID, req)
+		if errors.Is(err, services.ErrGoalEmpty) || errors.Is(err, services.ErrInvalidGoal) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Failed to save goal", "user_id", claims.UserID, "error", err)
+			http.Error(w, "Failed to save goal", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": progress})
+
+	case http.MethodDelete:
+		deleted, err := goals.DeleteGoal(claims.UserID)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Failed to delete goal", "user_id", claims.UserID, "error", err)
+			http.Error(w, "Failed to delete goal", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "deleted": deleted})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (gh *GoalHandler) claimsFromRequest(r *http.Request) *services.JWTClaims {
+	authHeader := r.Header.Get("Authorization")
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if authHeader == "" || tokenString == authHeader {
+		return nil
+	}
+	claims, err := gh.authService.ValidateToken(tokenString)
+	if err != nil {
+		return nil
+	}
+	return claims
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/middleware"
+	"back_wa/internal/repository"
+	"back_wa/internal/services"
+)
+
+type LegalHandler struct {
+	authService  *services.AuthService
+	legalService *services.LegalService
+}
+
+func NewLegalHandler(repos *repository.Repositories) *LegalHandler {
+	return &LegalHandler{
+		authService:  services.NewAuthService(repos.Users, repos.AuthSessions),
+		legalService: services.NewLegalService(repos.Legal),
+	}
+}
+
+// GetDocuments handles GET /api/legal (public): current ToS and privacy policy versions
+func (lh *LegalHandler) GetDocuments(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    services.LegalDocuments(),
+	})
+}
+
+// GetConsent handles GET /api/legal/consent: the user's consent state per document
+func (lh *LegalHandler) GetConsent(w http.ResponseWriter, r *http.Request) {
+	claims := lh.claimsFromRequest(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	statuses, err := lh.legalService.WithContext(r.Context()).Status(claims.UserID)
+	if err != nil {
+		http.Error(w, "Failed to get consent status", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    statuses,
+	})
+}
+
+// AcceptConsent handles POST /api/legal/consent ({"documents": {"tos": "2.0", "privacy": "1.3"}})
+func (lh *LegalHandler) AcceptConsent(w http.ResponseWriter, r *http.Request) {
+	claims := lh.claimsFromRequest(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		Documents map[string]string `json:"documents"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// The client address as seen through TRUSTED_PROXY_CIDRS, not the proxy's
+	statuses, err := lh.legalService.WithContext(r.Context()).Accept(claims.UserID, payload.Documents, middleware.ClientIP(r), r.UserAgent())
+	if errors.Is(err, services.ErrUnknownLegalVersion) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to record consent", "user_id", claims.UserID, "error", err)
+		http.Error(w, "Failed to record consent", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    statuses,
+	})
+}
+
+func (lh *LegalHandler) claimsFromRequest(r *http.Request) *services.JWTClaims {
+	authHeader := r.Header.Get("Authorization")
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if authHeader == "" || tokenString == authHeader {
+		return nil
+	}
+	claims, err := lh.authService.ValidateToken(tokenString)
+	if err != nil {
+		return nil
+	}
+	return claims
+}
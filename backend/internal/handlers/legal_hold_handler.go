@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"back_wa/internal/middleware"
+	"back_wa/internal/repository"
+	"back_wa/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+type LegalHoldHandler struct {
+	holdService *services.LegalHoldService
+}
+
+func NewLegalHoldHandler(repos *repository.Repositories) *LegalHoldHandler {
+	return &LegalHoldHandler{
+		holdService: services.NewLegalHoldService(repos.Legal),
+	}
+}
+
+type holdRequest struct {
+	Reason string     `json:"reason"`
+	Until  *time.Time `json:"until"` // optional RFC3339 expiry
+}
+
+// SetAnalysisHold handles POST /api/admin/analysis/{id}/hold
+func (lh *LegalHoldHandler) SetAnalysisHold(w http.ResponseWriter, r *http.Request) {
+	lh.setHold(w, r, lh.holdService.WithContext(r.Context()).SetAnalysisHold)
+}
+
+// ReleaseAnalysisHold handles DELETE /api/admin/analysis/{id}/hold
+func (lh *LegalHoldHandler) ReleaseAnalysisHold(w http.ResponseWriter, r *http.Request) {
+	lh.releaseHold(w, r, lh.holdService.WithContext(r.Context()).ReleaseAnalysisHold)
+}
+
+// SetTransactionHold handles POST /api/admin/transactions/{id}/hold
+func (lh *LegalHoldHandler) SetTransactionHold(w http.ResponseWriter, r *http.Request) {
+	lh.setHold(w, r, lh.holdService.WithContext(r.Context()).SetTransactionHold)
+}
+
+// ReleaseTransactionHold handles DELETE /api/admin/transactions/{id}/hold
+func (lh *LegalHoldHandler) ReleaseTransactionHold(w http.ResponseWriter, r *http.Request) {
+	lh.releaseHold(w, r, lh.holdService.WithContext(r.Context()).ReleaseTransactionHold)
+}
+
+func (lh *LegalHoldHandler) setHold(w http.ResponseWriter, r *http.Request, set func(uint, string, *time.Time, uint) error) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims := middleware.ClaimsFromContext(r.Context())
+
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var req holdRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+
+	if err := set(uint(id), req.Reason, req.Until, claims.UserID); err != nil {
+		if errors.Is(err, services.ErrHoldTargetNotFound) {
+			http.Error(w, "Record not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"id":      id,
+		"on_hold": true,
+		"reason":  req.Reason,
+		"until":   req.Until,
+	})
+}
+
+func (lh *LegalHoldHandler) releaseHold(w http.ResponseWriter, r *http.Request, release func(uint) error) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := release(uint(id)); err != nil {
+		if errors.Is(err, services.ErrHoldTargetNotFound) {
+			http.Error(w, "Record not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to release hold", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "id": id, "on_hold": false})
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+	"back_wa/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+type LimitsHandler struct {
+	authService   *services.AuthService
+	limitsService *services.LimitsService
+	entitlements  *services.EntitlementService
+}
+
+func NewLimitsHandler(repos *repository.Repositories) *LimitsHandler {
+	return &LimitsHandler{
+		authService:   services.NewAuthService(repos.Users, repos.AuthSessions),
+		limitsService: services.NewLimitsService(repos),
+		entitlements:  services.NewEntitlementService(repos),
+	}
+}
+
+// GetUserLimits handles GET /api/user/limits: the limits of the caller's tier with the current
+// usage, for the frontend to show before a limit is hit
+func (lh *LimitsHandler) GetUserLimits(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims := lh.claimsFromRequest(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limits, err := lh.limitsService.WithContext(r.Context()).ForUser(lh.entitlements, claims.UserID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to load user limits", "user_id", claims.UserID, "error", err)
+		http.Error(w, "Failed to load limits", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    limits,
+	})
+}
+
+// ListLimits handles GET /api/admin/limits: the limits of every tier
+func (lh *LimitsHandler) ListLimits(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims := lh.claimsFromRequest(r)
+	if claims == nil || claims.Role != "admin" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	limits, err := lh.limitsService.WithContext(r.Context()).List()
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to load product limits", "error", err)
+		http.Error(w, "Failed to load limits", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"data":     limits,
+		"defaults": models.DefaultProductLimits(),
+	})
+}
+
+// UpdateLimits handles PUT /api/admin/limits/{tier} with {"max_sessions", "scans_per_day",
+// "history_size"} (0 = unlimited). Tier is trial, paid or a plan code.
+func (lh *LimitsHandler) UpdateLimits(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims := lh.claimsFromRequest(r)
+	if claims == nil || claims.Role != "admin" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	tier := mux.Vars(r)["tier"]
+	var limit models.ProductLimit
+	if err := json.NewDecoder(r.Body).Decode(&limit); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := limit.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	saved, err := lh.limitsService.WithContext(r.Context()).Update(tier, limit, claims.UserID)
+	if errors.Is(err, services.ErrUnknownTier) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to save product limits", "tier", tier, "error", err)
+		http.Error(w, "Failed to save limits", http.StatusInternalServerError)
+		return
+	}
+	logging.FromContext(r.Context()).Info(fmt.Sprintf("Admin %d updated the limits of tier %s", claims.UserID, tier),
+		"audit", true, "admin_id", claims.UserID, "tier", tier, "max_sessions", saved.MaxSessions,
+		"scans_per_day", saved.ScansPerDay, "history_size", saved.HistorySize)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    saved,
+	})
+}
+
+func (lh *LimitsHandler) claimsFromRequest(r *http.Request) *services.JWTClaims {
+	authHeader := r.Header.Get("Authorization")
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if authHeader == "" || tokenString == authHeader {
+		return nil
+	}
+	claims, err := lh.authService.ValidateToken(tokenString)
+	if err != nil {
+		return nil
+	}
+	return claims
+}
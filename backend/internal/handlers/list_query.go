@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"back_wa/internal/repository"
+	"back_wa/internal/services"
+)
+
+// maxListLimit caps ?limit= of the user listings
+const maxListLimit = 200
+
+// listQueryFromRequest reads ?limit=&offset=&from=&to=&sort=&order= of a user listing. limit is
+// optional (all rows without it), from/to are YYYY-MM-DD days in SCAN_SCHEDULE_TIMEZONE with to
+// inclusive, sort must be a key of sorts and order is asc or desc (default desc).
+func listQueryFromRequest(r *http.Request, sorts map[string]string) (repository.ListQuery, error) {
+	params := r.URL.Query()
+	query := repository.ListQuery{Desc: true}
+
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxListLimit {
+			return query, fmt.Errorf("limit must be between 1 and %d", maxListLimit)
+		}
+		query.Limit = limit
+	}
+	if v := params.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return query, fmt.Errorf("offset must be a non-negative number")
+		}
+		if query.Limit == 0 {
+			return query, fmt.Errorf("offset requires limit")
+		}
+		query.Offset = offset
+	}
+
+	loc := services.ScanScheduleLocation()
+	if v := params.Get("from"); v != "" {
+		from, err := time.ParseInLocation("2006-01-02", v, loc)
+		if err != nil {
+			return query, fmt.Errorf("from must be in YYYY-MM-DD format")
+		}
+		query.From = from
+	}
+	if v := params.Get("to"); v != "" {
+		to, err := time.ParseInLocation("2006-01-02", v, loc)
+		if err != nil {
+			return query, fmt.Errorf("to must be in YYYY-MM-DD format")
+		}
+		query.To = to.AddDate(0, 0, 1)
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return query, fmt.Errorf("from must not be after to")
+	}
+
+	if v := params.Get("sort"); v != "" {
+		if _, ok := sorts[v]; !ok {
+			keys := make([]string, 0, len(sorts))
+			for key := range sorts {
+				keys = append(keys, key)
+			}
+			return query, fmt.Errorf("sort must be one of %s", strings.Join(keys, ", "))
+		}
+		query.Sort = v
+	}
+	switch params.Get("order") {
+	case "", "desc":
+	case "asc":
+		query.Desc = false
+	default:
+		return query, fmt.Errorf("order must be asc or desc")
+	}
+	return query, nil
+}
+
+// listFilter reads a comma-separated filter such as ?status=paid,pending; every value must be allowed
+func listFilter(r *http.Request, name string, allowed ...string) ([]string, error) {
+	raw := strings.TrimSpace(r.URL.Query().Get(name))
+	if raw == "" {
+		return nil, nil
+	}
+	var values []string
+	for _, value := range strings.Split(raw, ",") {
+		value = strings.TrimSpace(value)
+		ok := false
+		for _, candidate := range allowed {
+			if strings.EqualFold(value, candidate) {
+				value, ok = candidate, true
+				break
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("%s must be one of %s", name, strings.Join(allowed, ", "))
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
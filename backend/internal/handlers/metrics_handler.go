@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"back_wa/internal/database"
+	"back_wa/internal/repository"
+	"back_wa/internal/services"
+)
+
+type MetricsHandler struct {
+	authService  *services.AuthService
+	adminService *services.AdminService
+	dataKeys     repository.DataKeyRepo
+}
+
+func NewMetricsHandler(repos *repository.Repositories) *MetricsHandler {
+	return &MetricsHandler{
+		authService:  services.NewAuthService(repos.Users, repos.AuthSessions),
+		adminService: services.NewAdminService(repos),
+		dataKeys:     repos.DataKeys,
+	}
+}
+
+// GetAnalysisMetrics handles GET /api/admin/metrics/analysis
+func (mh *MetricsHandler) GetAnalysisMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !mh.requireAdmin(w, r) {
+		return
+	}
+
+	// Serving cost over the last ?days= (default 30), top ?limit= users or only ?user_id=
+	days, limit := 30, 20
+	if v, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && v > 0 {
+		days = v
+	}
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 && v <= 100 {
+		limit = v
+	}
+	var userID uint
+	if raw := r.URL.Query().Get("user_id"); raw != "" {
+		v, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid user_id", http.StatusBadRequest)
+			return
+		}
+		userID = uint(v)
+	}
+	cost, err := mh.adminService.WithContext(r.Context()).AnalysisCost(time.Now().AddDate(0, 0, -days), userID, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"metrics": services.AnalysisMetrics(),
+		"cost":    cost,
+	})
+}
+
+// GetDatabaseMetrics handles GET /api/admin/metrics/database
+func (mh *MetricsHandler) GetDatabaseMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !mh.requireAdmin(w, r) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"metrics": map[string]interface{}{
+			"pool":   database.Pool(),
+			"health": database.Health(),
+		},
+	})
+}
+
+// GetSelfCheck handles GET /api/admin/selfcheck: runs the configuration and dependency checks
+// and answers 503 when one of them fails
+func (mh *MetricsHandler) GetSelfCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !mh.requireAdmin(w, r) {
+		return
+	}
+
+	report := services.RunSelfCheck(r.Context(), mh.dataKeys)
+	w.Header().Set("Content-Type", "application/json")
+	if !report.Passed {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": report.Passed,
+		"data":    report,
+	})
+}
+
+// requireAdmin validates the bearer token and writes 401/403 unless it belongs to an admin
+func (mh *MetricsHandler) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	authHeader := r.Header.Get("Authorization")
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if authHeader == "" || tokenString == authHeader {
+		http.Error(w, "Authorization header required", http.StatusUnauthorized)
+		return false
+	}
+	claims, err := mh.authService.ValidateToken(tokenString)
+	if err != nil {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return false
+	}
+	if claims.Role != "admin" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
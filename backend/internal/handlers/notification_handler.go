@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"back_wa/internal/repository"
+	"back_wa/internal/services"
+)
+
+type NotificationHandler struct {
+	authService         *services.AuthService
+	notificationService *services.NotificationService
+}
+
+func NewNotificationHandler(repos *repository.Repositories) *NotificationHandler {
+	return &NotificationHandler{
+		authService:         services.NewAuthService(repos.Users, repos.AuthSessions),
+		notificationService: services.NewNotificationService(repos.Notifications, repos.Pushes),
+	}
+}
+
+// ListNotifications handles GET /api/user/notifications?unread=true&limit=20
+func (nh *NotificationHandler) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims := nh.claimsFromRequest(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit := 20
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 && v <= 100 {
+		limit = v
+	}
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+
+	notifications, err := nh.notificationService.WithContext(r.Context()).List(claims.UserID, unreadOnly, limit)
+	if err != nil {
+		http.Error(w, "Failed to get notifications", http.StatusInternalServerError)
+		return
+	}
+	unread, err := nh.notificationService.WithContext(r.Context()).UnreadCount(claims.UserID)
+	if err != nil {
+		http.Error(w, "Failed to get notifications", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":      true,
+		"data":         notifications,
+		"unread_count": unread,
+	})
+}
+
+// MarkNotificationsRead handles POST /api/user/notifications/read
+// Body: {"ids": [1, 2]} marks specific notifications, {"all": true} marks everything
+func (nh *NotificationHandler) MarkNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims := nh.claimsFromRequest(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		IDs []uint `json:"ids"`
+		All bool   `json:"all"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(payload.IDs) == 0 && !payload.All {
+		http.Error(w, "ids or all is required", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := nh.notificationService.WithContext(r.Context()).MarkRead(claims.UserID, payload.IDs)
+	if err != nil {
+		http.Error(w, "Failed to mark notifications as read", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "updated": updated})
+}
+
+func (nh *NotificationHandler) claimsFromRequest(r *http.Request) *services.JWTClaims {
+	authHeader := r.Header.Get("Authorization")
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if authHeader == "" || tokenString == authHeader {
+		return nil
+	}
+	claims, err := nh.authService.ValidateToken(tokenString)
+	if err != nil {
+		return nil
+	}
+	return claims
+}
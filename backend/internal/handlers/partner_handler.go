@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/middleware"
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+	"back_wa/internal/services"
+)
+
+type PartnerHandler struct {
+	tenantService *services.TenantService
+	usageService  *services.UsageService
+}
+
+func NewPartnerHandler(repos *repository.Repositories) *PartnerHandler {
+	return &PartnerHandler{
+		tenantService: services.NewTenantService(repos.Tenants),
+		usageService:  services.NewUsageService(repos.Usage, repos.Transactions),
+	}
+}
+
+// GetUsage handles GET /api/partner/usage?period=YYYY-MM
+// Authenticated with the partner's X-API-Key; returns metered counters and priced invoice lines
+func (ph *PartnerHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tenant := ph.partnerFromRequest(r)
+	if tenant == nil {
+		http.Error(w, "Invalid or missing X-API-Key", http.StatusUnauthorized)
+		return
+	}
+
+	period, ok := periodFromQuery(r)
+	if !ok {
+		http.Error(w, "period must be in YYYY-MM format", http.StatusBadRequest)
+		return
+	}
+
+	usage, err := ph.usageService.WithContext(r.Context()).GetUsage(tenant.ID, period)
+	if err != nil {
+		http.Error(w, "Failed to get usage", http.StatusInternalServerError)
+		return
+	}
+	tenantID := tenant.ID
+	lines, err := ph.usageService.WithContext(r.Context()).GetInvoiceLines(&tenantID, period)
+	if err != nil {
+		http.Error(w, "Failed to get usage", http.StatusInternalServerError)
+		return
+	}
+
+	counters := map[string]int64{}
+	for _, u := range usage {
+		counters[u.Metric] = u.Count
+	}
+	var total float64
+	for _, line := range lines {
+		total += line.Amount
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"tenant":  tenant.Slug,
+		"period":  period,
+		"usage":   counters,
+		"invoice": map[string]interface{}{
+			"lines":    lines,
+			"total":    total,
+			"currency": "IDR",
+		},
+	})
+}
+
+// ExportUsage handles GET /api/partner/usage/export?period=YYYY-MM&format=csv|json
+// Partners (X-API-Key) get their own lines; admins get every tenant for billing through
+// GET /api/admin/usage/export, where RequireRole has validated their token
+func (ph *PartnerHandler) ExportUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var tenantID *uint
+	if tenant := ph.partnerFromRequest(r); tenant != nil {
+		id := tenant.ID
+		tenantID = &id
+	} else if middleware.ClaimsFromContext(r.Context()) == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	period, ok := periodFromQuery(r)
+	if !ok {
+		http.Error(w, "period must be in YYYY-MM format", http.StatusBadRequest)
+		return
+	}
+
+	// Both formats are streamed so an all-tenant billing export never sits in memory
+	if strings.ToLower(r.URL.Query().Get("format")) == "json" {
+		stream := newJSONArrayStream(w, "lines", map[string]interface{}{"period": period})
+		err := ph.usageService.WithContext(r.Context()).EachInvoiceLine(tenantID, period, func(line models.UsageInvoiceLine) error {
+			return stream.Write(line)
+		})
+		stream.Close(err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"usage_%s.csv\"", period))
+	stream := newCSVStream(w, []string{"tenant_id", "slug", "brand_name", "period", "metric", "quantity", "unit_price", "amount", "currency"})
+	err := ph.usageService.WithContext(r.Context()).EachInvoiceLine(tenantID, period, func(line models.UsageInvoiceLine) error {
+		return stream.Write([]string{
+			strconv.FormatUint(uint64(line.TenantID), 10),
+			line.Slug,
+			line.BrandName,
+			line.Period,
+			line.Metric,
+			strconv.FormatInt(line.Quantity, 10),
+			strconv.FormatFloat(line.UnitPrice, 'f', 2, 64),
+			strconv.FormatFloat(line.Amount, 'f', 2, 64),
+			line.Currency,
+		})
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error(fmt.Sprintf("Usage export for %s aborted", period), "error", err)
+	}
+	stream.Flush()
+}
+
+// partnerFromRequest returns the active tenant owning the X-API-Key header, if any
+func (ph *PartnerHandler) partnerFromRequest(r *http.Request) *models.Tenant {
+	apiKey := strings.TrimSpace(r.Header.Get("X-API-Key"))
+	if apiKey == "" {
+		return nil
+	}
+	tenant, err := ph.tenantService.WithContext(r.Context()).GetByAPIKey(apiKey)
+	if err != nil {
+		return nil
+	}
+	return tenant
+}
+
+// periodFromQuery reads ?period=YYYY-MM, defaulting to the current month
+func periodFromQuery(r *http.Request) (string, bool) {
+	period := strings.TrimSpace(r.URL.Query().Get("period"))
+	if period == "" {
+		return services.CurrentPeriod(time.Now()), true
+	}
+	return period, services.ValidPeriod(period)
+}
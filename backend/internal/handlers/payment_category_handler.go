@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/middleware"
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+	"back_wa/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+type PaymentCategoryHandler struct {
+	categoryService *services.PaymentCategoryService
+}
+
+func NewPaymentCategoryHandler(repos *repository.Repositories) *PaymentCategoryHandler {
+	return &PaymentCategoryHandler{
+		categoryService: services.NewPaymentCategoryService(repos.PaymentCategories),
+	}
+}
+
+// ListCategories handles GET /api/admin/payment-categories
+func (pch *PaymentCategoryHandler) ListCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := pch.categoryService.WithContext(r.Context()).ListCategories()
+	if err != nil {
+		http.Error(w, "Failed to get payment categories", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    categories,
+	})
+}
+
+// CreateCategory handles POST /api/admin/payment-categories
+func (pch *PaymentCategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.ClaimsFromContext(r.Context())
+
+	// New categories can be paid for right away unless the body says otherwise
+	category := models.PaymentCategory{IsActive: true}
+	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := category.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := pch.categoryService.WithContext(r.Context()).CreateCategory(&category); err != nil {
+		if errors.Is(err, services.ErrCategoryNameTaken) {
+			http.Error(w, "A payment category with this name already exists", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to create payment category", http.StatusInternalServerError)
+		return
+	}
+	logging.FromContext(r.Context()).Info(fmt.Sprintf("Admin %d created payment category %q at %.0f", claims.UserID, category.Name, category.Price),
+		"audit", true, "admin_id", claims.UserID, "category_id", category.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    category,
+	})
+}
+
+// UpdateCategory handles PUT /api/admin/payment-categories/{id}
+func (pch *PaymentCategoryHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.ClaimsFromContext(r.Context())
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid category ID", http.StatusBadRequest)
+		return
+	}
+
+	changes := models.PaymentCategory{IsActive: true}
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := changes.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	category, err := pch.categoryService.WithContext(r.Context()).UpdateCategory(id, &changes)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrCategoryNotFound):
+			http.Error(w, "Payment category not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrCategoryNameTaken):
+			http.Error(w, "A payment category with this name already exists", http.StatusConflict)
+		default:
+			http.Error(w, "Failed to update payment category", http.StatusInternalServerError)
+		}
+		return
+	}
+	logging.FromContext(r.Context()).Info(fmt.Sprintf("Admin %d updated payment category %q to %.0f", claims.UserID, category.Name, category.Price),
+		"audit", true, "admin_id", claims.UserID, "category_id", category.ID, "is_active", category.IsActive)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    category,
+	})
+}
+
+// DeleteCategory handles DELETE /api/admin/payment-categories/{id}
+func (pch *PaymentCategoryHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.ClaimsFromContext(r.Context())
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid category ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := pch.categoryService.WithContext(r.Context()).DeleteCategory(id); err != nil {
+		if errors.Is(err, services.ErrCategoryNotFound) {
+			http.Error(w, "Payment category not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete payment category", http.StatusInternalServerError)
+		return
+	}
+	logging.FromContext(r.Context()).Info(fmt.Sprintf("Admin %d deleted payment category %d", claims.UserID, id),
+		"audit", true, "admin_id", claims.UserID, "category_id", id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+}
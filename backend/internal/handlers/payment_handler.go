@@ -2,27 +2,38 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
+	"back_wa/internal/logging"
+	"back_wa/internal/middleware"
 	"back_wa/internal/models"
+	"back_wa/internal/repository"
 	"back_wa/internal/services"
+
+	"github.com/gorilla/mux"
 )
 
 type PaymentHandler struct {
+	authService    *services.AuthService
 	paymentService *services.PaymentService
+	tenantService  *services.TenantService
 }
 
-func NewPaymentHandler(paymentService *services.PaymentService) *PaymentHandler {
+func NewPaymentHandler(repos *repository.Repositories, paymentService *services.PaymentService) *PaymentHandler {
 	return &PaymentHandler{
+		authService:    services.NewAuthService(repos.Users, repos.AuthSessions),
 		paymentService: paymentService,
+		tenantService:  services.NewTenantService(repos.Tenants),
 	}
 }
 
 // CreatePayment handles POST /api/payments/create
 func (ph *PaymentHandler) CreatePayment(w http.ResponseWriter, r *http.Request) {
-	fmt.Printf("🚀 Payment creation request received: %s %s\n", r.Method, r.URL.Path)
+	logging.FromContext(r.Context()).Info(fmt.Sprintf("Payment creation request received: %s %s", r.Method, r.URL.Path))
 
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -31,66 +42,114 @@ func (ph *PaymentHandler) CreatePayment(w http.ResponseWriter, r *http.Request)
 
 	var req models.CreatePaymentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		fmt.Printf("❌ Invalid request body: %v\n", err)
+		logging.FromContext(r.Context()).Error("Invalid request body", "error", err)
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	fmt.Printf("📋 Request data: %+v\n", req)
+	logging.FromContext(r.Context()).Debug(fmt.Sprintf("Request data: %+v", req))
 
 	// Get user ID from JWT token (you'll need to implement this)
 	userID := ph.getUserIDFromToken(r)
 	if userID == 0 {
-		fmt.Printf("❌ Unauthorized: No valid user ID from token\n")
+		logging.FromContext(r.Context()).Error("Unauthorized: No valid user ID from token")
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	fmt.Printf("👤 User ID: %d\n", userID)
+	logging.FromContext(r.Context()).Debug(fmt.Sprintf("User ID: %d", userID), "user_id", userID)
 
-	// Validate request
-	if req.Email == "" || req.Category == "" || req.PaymentMethod == "" || req.Amount <= 0 {
-		fmt.Printf("❌ Missing required fields: email=%s, category=%s, payment_method=%s, amount=%f\n",
-			req.Email, req.Category, req.PaymentMethod, req.Amount)
-		http.Error(w, "Missing required fields", http.StatusBadRequest)
-		return
+	// One-click checkout from a 402 bootstrap: the token carries plan, amount and phone number
+	if req.CreatePaymentToken != "" {
+		if err := ph.paymentService.ApplyPaymentToken(&req, userID); err != nil {
+			logging.FromContext(r.Context()).Error("Invalid create_payment_token", "error", err)
+			http.Error(w, "create_payment_token tidak valid atau sudah kedaluwarsa.", http.StatusBadRequest)
+			return
+		}
+		if req.Email == "" {
+			authService := ph.authService.WithContext(r.Context())
+			if user, err := authService.GetUserByID(uint(userID)); err == nil {
+				req.Email = user.Email
+			}
+		}
+		if req.PaymentMethod == "" {
+			req.PaymentMethod = "invoice" // Xendit hosted page lets the user pick the channel
+		}
 	}
 
-	// Set default amount if not provided
-	if req.Amount == 0 {
-		req.Amount = 50000 // Default amount for WhatsApp analysis
-		fmt.Printf("💰 Using default amount: %f\n", req.Amount)
+	// Validate request; the amount is not taken from the client but from the category's price
+	if req.Email == "" || (req.Category == "" && req.CategoryID <= 0) || req.PaymentMethod == "" {
+		logging.FromContext(r.Context()).Error(fmt.Sprintf("Missing required fields: email=%s, category=%s, category_id=%d, payment_method=%s", req.Email, req.Category, req.CategoryID, req.PaymentMethod))
+		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		return
 	}
 
 	// Create payment service request (using models.CreatePaymentRequest directly)
 	paymentReq := models.CreatePaymentRequest{
-		Email:         req.Email,
-		Amount:        req.Amount,
-		Category:      req.Category,
-		PaymentMethod: req.PaymentMethod,
-		PhoneNumber:   req.PhoneNumber,
+		Email:           req.Email,
+		Category:        req.Category,
+		CategoryID:      req.CategoryID,
+		PaymentMethod:   req.PaymentMethod,
+		PhoneNumber:     req.PhoneNumber,
+		RedirectBaseURL: req.RedirectBaseURL,
+		Gateway:         req.Gateway,
+		CouponCode:      req.CouponCode,
+	}
+
+	// Optional Idempotency-Key: a double-click replays the first invoice instead of creating another
+	idempotencyKey := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	if len(idempotencyKey) > services.MaxIdempotencyKeyLength {
+		http.Error(w, "Idempotency-Key terlalu panjang.", http.StatusBadRequest)
+		return
 	}
 
 	// Create payment
-	fmt.Printf("🔄 Creating payment for user %d with data: %+v\n", userID, paymentReq)
-	paymentResp, err := ph.paymentService.CreatePayment(paymentReq, userID)
+	logging.FromContext(r.Context()).Debug(fmt.Sprintf("Creating payment for user %d with data: %+v", userID, paymentReq), "user_id", userID)
+	tenant := ph.tenantService.WithContext(r.Context()).ResolveRequest(r)
+	paymentService := ph.paymentService.WithContext(r.Context())
+	var paymentResp *models.CreatePaymentResponse
+	var replayed bool
+	var err error
+	if idempotencyKey != "" {
+		paymentResp, replayed, err = paymentService.CreatePaymentIdempotent(paymentReq, userID, tenant, idempotencyKey)
+	} else {
+		paymentResp, err = paymentService.CreatePaymentForTenant(paymentReq, userID, tenant)
+	}
 	if err != nil {
-		fmt.Printf("❌ Payment creation failed: %v\n", err)
+		logging.FromContext(r.Context()).Error("Payment creation failed", "error", err)
 		// Map common Xendit errors to clearer HTTP responses
 		msg := err.Error()
 		switch {
+		case errors.Is(err, services.ErrRedirectNotAllowed):
+			http.Error(w, "Redirect URL tidak diizinkan.", http.StatusBadRequest)
+			return
+		case errors.Is(err, services.ErrUnknownPaymentGateway):
+			http.Error(w, "Payment gateway tidak dikenal (gunakan xendit atau midtrans).", http.StatusBadRequest)
+			return
+		case errors.Is(err, services.ErrCategoryNotFound):
+			http.Error(w, "Kategori pembayaran tidak ditemukan atau tidak aktif.", http.StatusBadRequest)
+			return
+		case services.CouponMessage(err) != "":
+			http.Error(w, services.CouponMessage(err), http.StatusUnprocessableEntity)
+			return
+		case errors.Is(err, services.ErrIdempotencyKeyReused):
+			http.Error(w, "Idempotency-Key sudah dipakai untuk request pembayaran lain.", http.StatusUnprocessableEntity)
+			return
 		case strings.Contains(msg, "xendit_error"):
 			http.Error(w, "Gagal membuat invoice di Xendit. Periksa XENDIT_SECRET_KEY/BASE_URL dan gunakan kunci sesuai environment (sandbox/live).", http.StatusBadGateway)
 			return
 		case strings.Contains(msg, "not configured"):
 			http.Error(w, "Konfigurasi payment service belum lengkap.", http.StatusServiceUnavailable)
 			return
+		case strings.Contains(msg, "midtrans_error"):
+			http.Error(w, "Gagal membuat transaksi di Midtrans. Periksa MIDTRANS_SERVER_KEY dan MIDTRANS_IS_PRODUCTION (sandbox/live).", http.StatusBadGateway)
+			return
 		default:
 			http.Error(w, fmt.Sprintf("Failed to create payment: %v", err), http.StatusInternalServerError)
 			return
 		}
 	}
-	fmt.Printf("✅ Payment created successfully: %+v\n", paymentResp)
+	logging.FromContext(r.Context()).Info(fmt.Sprintf("Payment created successfully: %+v", paymentResp))
 
 	// Prepare response
 	response := models.CreatePaymentResponse{
@@ -101,15 +160,27 @@ func (ph *PaymentHandler) CreatePayment(w http.ResponseWriter, r *http.Request)
 		Amount:        paymentResp.Amount,
 		Status:        paymentResp.Status,
 		PaymentMethod: paymentResp.PaymentMethod,
+		Gateway:       paymentResp.Gateway,
 		CreatedAt:     paymentResp.CreatedAt,
 		ExpiryDate:    paymentResp.ExpiryDate,
 		Message:       "Payment created successfully",
+		Existing:      paymentResp.Existing,
+		CouponCode:    paymentResp.CouponCode,
+		Discount:      paymentResp.Discount,
+	}
+
+	if paymentResp.Existing {
+		response.Message = "Pending payment already exists for this phone number and category"
+	}
+	if replayed {
+		response.Message = "Payment already created for this Idempotency-Key"
+		w.Header().Set("Idempotent-Replayed", "true")
 	}
 
-	fmt.Printf("📤 Sending response: %+v\n", response)
+	logging.FromContext(r.Context()).Debug(fmt.Sprintf("Sending response: %+v", response))
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
-	fmt.Printf("✅ Payment creation completed successfully\n")
+	logging.FromContext(r.Context()).Info("Payment creation completed successfully")
 }
 
 // GetPaymentStatus handles GET /api/payments/:external_id/status
@@ -138,7 +209,7 @@ func (ph *PaymentHandler) GetPaymentStatus(w http.ResponseWriter, r *http.Reques
 	}
 
 	// Get transaction (with reconciliation if still pending)
-	transaction, err := ph.paymentService.ReconcileTransactionStatusByExternalID(externalID)
+	transaction, err := ph.paymentService.WithContext(r.Context()).ReconcileTransactionStatusByExternalID(externalID)
 	if err != nil {
 		http.Error(w, "Transaction not found", http.StatusNotFound)
 		return
@@ -159,6 +230,7 @@ func (ph *PaymentHandler) GetPaymentStatus(w http.ResponseWriter, r *http.Reques
 		Status:         transaction.Status,
 		PaymentMethod:  transaction.PaymentMethod,
 		PaymentChannel: transaction.PaymentChannel,
+		Gateway:        transaction.Gateway,
 		CreatedAt:      transaction.CreatedAt,
 		UpdatedAt:      transaction.UpdatedAt,
 		PaidAt:         transaction.PaidAt,
@@ -168,7 +240,43 @@ func (ph *PaymentHandler) GetPaymentStatus(w http.ResponseWriter, r *http.Reques
 	json.NewEncoder(w).Encode(response)
 }
 
-// GetTransactionHistory handles GET /api/transactions
+// CheckPayment handles GET /api/payments/check?phone=
+// Lets the frontend show the paywall before the user scans the QR, using the same rules as /api/wa/analyze.
+// It answers the same precheck as GET /api/wa/entitlement (PaymentService.Precheck).
+func (ph *PaymentHandler) CheckPayment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := ph.getUserIDFromToken(r)
+	if userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	phone := strings.TrimSpace(r.URL.Query().Get("phone"))
+	if services.NormalizePhoneNumber(phone) == "" {
+		http.Error(w, "phone is required", http.StatusBadRequest)
+		return
+	}
+
+	precheck, err := ph.paymentService.WithContext(r.Context()).Precheck(uint(userID), phone)
+	if err != nil {
+		logging.FromContext(r.Context()).Error(fmt.Sprintf("Payment precheck failed for user %d", userID), "error", err, "user_id", userID)
+		http.Error(w, "Failed to verify payment status", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    precheck,
+	})
+}
+
+// GetTransactionHistory handles GET /api/transactions?limit=&offset=&from=&to=&status=&sort=&order=;
+// "total" counts the transactions matching the filters
 func (ph *PaymentHandler) GetTransactionHistory(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -182,17 +290,34 @@ func (ph *PaymentHandler) GetTransactionHistory(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	// Get transactions
-	transactions, err := ph.paymentService.GetUserTransactions(userID)
+	listQuery, err := listQueryFromRequest(r, repository.TransactionSorts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	statuses, err := listFilter(r, "status", "pending", "paid", "expired", "failed", "refunded")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query := services.TransactionQuery{ListQuery: listQuery, Statuses: statuses}
+
+	payments := ph.paymentService.WithContext(r.Context())
+	total, err := payments.CountUserTransactions(userID, query)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get transactions: %v", err), http.StatusInternalServerError)
+		logging.FromContext(r.Context()).Error("Failed to count transactions", "user_id", userID, "error", err)
+		http.Error(w, "Failed to get transactions", http.StatusInternalServerError)
 		return
 	}
+	meta := map[string]interface{}{"total": total}
+	if query.Limit > 0 {
+		meta["limit"], meta["offset"] = query.Limit, query.Offset
+	}
 
-	// Convert to response format
-	var response []models.TransactionHistoryResponse
-	for _, transaction := range transactions {
-		response = append(response, models.TransactionHistoryResponse{
+	// Stream transactions: users with long histories are never buffered in memory
+	stream := newJSONArrayStream(w, "data", meta)
+	err = payments.EachUserTransaction(userID, query, func(transaction models.Transaction) error {
+		return stream.Write(models.TransactionHistoryResponse{
 			ID:             transaction.ID,
 			ExternalID:     transaction.ExternalID,
 			Amount:         transaction.Amount,
@@ -205,13 +330,55 @@ func (ph *PaymentHandler) GetTransactionHistory(w http.ResponseWriter, r *http.R
 			CreatedAt:      transaction.CreatedAt,
 			UpdatedAt:      transaction.UpdatedAt,
 			PaidAt:         transaction.PaidAt,
+			RefundedAt:     transaction.RefundedAt,
 		})
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error(fmt.Sprintf("Failed to stream transactions for user %d", userID), "error", err, "user_id", userID)
+	}
+	stream.Close(err)
+}
+
+// ReconcilePending handles POST /api/admin/payments/reconcile
+// Body (optional): {"older_than_minutes": 30, "concurrency": 5, "limit": 1000}
+func (ph *PaymentHandler) ReconcilePending(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		OlderThanMinutes int `json:"older_than_minutes"`
+		Concurrency      int `json:"concurrency"`
+		Limit            int `json:"limit"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Concurrency <= 0 {
+		req.Concurrency = 5
+	}
+	if req.Concurrency > 20 {
+		req.Concurrency = 20 // stay well below Xendit rate limits
+	}
+	if req.Limit <= 0 || req.Limit > 5000 {
+		req.Limit = 1000
+	}
+
+	// Admin reconciliation covers every tenant, so the service is not bound to the request scope
+	summary, err := ph.paymentService.ReconcilePending(time.Duration(req.OlderThanMinutes)*time.Minute, req.Concurrency, req.Limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to reconcile transactions: %v", err), http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
-		"data":    response,
+		"data":    summary,
 	})
 }
 
@@ -246,6 +413,88 @@ func (ph *PaymentHandler) HandleWebhook(w http.ResponseWriter, r *http.Request)
 	w.Write([]byte("Webhook processed successfully"))
 }
 
+// RefundPayment handles POST /api/admin/payments/{external_id}/refund
+// Body (optional): {"reason": "REQUESTED_BY_CUSTOMER", "note": "Ticket #123"}
+func (ph *PaymentHandler) RefundPayment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims := middleware.ClaimsFromContext(r.Context())
+
+	var req models.RefundRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	externalID := mux.Vars(r)["external_id"]
+	// Admin refunds cover every tenant, so the service is not bound to the request scope
+	refund, transaction, err := ph.paymentService.RefundTransaction(externalID, req, claims.UserID)
+	switch {
+	case errors.Is(err, services.ErrInvalidRefundReason):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, services.ErrTransactionNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, services.ErrRefundNotPaid), errors.Is(err, services.ErrAlreadyRefunded), errors.Is(err, services.ErrRefundUnsupported):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case refund == nil:
+		logging.FromContext(r.Context()).Error("Failed to refund transaction", "external_id", externalID, "error", err)
+		http.Error(w, "Failed to refund transaction", http.StatusInternalServerError)
+		return
+	}
+	logging.FromContext(r.Context()).Info(fmt.Sprintf("Admin %d refunded transaction %s (%s): %s", claims.UserID, externalID, refund.Reason, refund.Status),
+		"audit", true, "admin_id", claims.UserID, "external_id", externalID, "refund_id", refund.ID, "amount", refund.Amount, "status", refund.Status)
+
+	status := http.StatusOK
+	response := map[string]interface{}{
+		"success":     err == nil,
+		"data":        refund,
+		"transaction": transaction,
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Refund failed", "external_id", externalID, "refund_id", refund.ID, "error", err)
+		status = http.StatusBadGateway
+		response["message"] = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ListRefunds handles GET /api/admin/payments/{external_id}/refunds; pending refunds are
+// refreshed from Xendit first
+func (ph *PaymentHandler) ListRefunds(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	externalID := mux.Vars(r)["external_id"]
+	transaction, refunds, err := ph.paymentService.ListRefunds(externalID)
+	if errors.Is(err, services.ErrTransactionNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to list refunds", "external_id", externalID, "error", err)
+		http.Error(w, "Failed to list refunds", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"data":        refunds,
+		"transaction": transaction,
+	})
+}
+
 // Helper function to get user ID from JWT token
 func (ph *PaymentHandler) getUserIDFromToken(r *http.Request) int {
 	// Extract token from Authorization header
@@ -260,8 +509,7 @@ func (ph *PaymentHandler) getUserIDFromToken(r *http.Request) int {
 	}
 
 	// Validate JWT token using auth service
-	authService := &services.AuthService{}
-	claims, err := authService.ValidateToken(authHeader)
+	claims, err := ph.authService.ValidateToken(authHeader)
 	if err != nil {
 		return 0
 	}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"back_wa/internal/repository"
+	"back_wa/internal/services"
+)
+
+type PushHandler struct {
+	authService *services.AuthService
+	pushService *services.PushService
+}
+
+func NewPushHandler(repos *repository.Repositories) *PushHandler {
+	return &PushHandler{
+		authService: services.NewAuthService(repos.Users, repos.AuthSessions),
+		pushService: services.NewPushService(repos.Pushes),
+	}
+}
+
+// RegisterPushToken handles POST /api/user/push-tokens
+// Body: {"platform": "fcm", "token": "..."} or {"platform": "webpush", "token": "<endpoint>", "keys": {"p256dh": "...", "auth": "..."}}
+func (ph *PushHandler) RegisterPushToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims := ph.claimsFromRequest(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		Platform string `json:"platform"`
+		Token    string `json:"token"`
+		Keys     struct {
+			P256dh string `json:"p256dh"`
+			Auth   string `json:"auth"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	token, err := ph.pushService.WithContext(r.Context()).RegisterToken(claims.UserID, strings.ToLower(payload.Platform), strings.TrimSpace(payload.Token),
+		payload.Keys.P256dh, payload.Keys.Auth, r.UserAgent())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": token})
+}
+
+// DeletePushToken handles DELETE /api/user/push-tokens with body {"token": "..."}
+func (ph *PushHandler) DeletePushToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims := ph.claimsFromRequest(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := ph.pushService.WithContext(r.Context()).UnregisterToken(claims.UserID, strings.TrimSpace(payload.Token)); err != nil {
+		http.Error(w, "Failed to remove push token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+}
+
+// GetPushPreferences handles GET /api/user/push-preferences
+func (ph *PushHandler) GetPushPreferences(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims := ph.claimsFromRequest(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	pref, err := ph.pushService.WithContext(r.Context()).GetPreferences(claims.UserID)
+	if err != nil {
+		http.Error(w, "Failed to get push preferences", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":          true,
+		"data":             pref,
+		"vapid_public_key": ph.pushService.WithContext(r.Context()).VAPIDPublicKey(),
+	})
+}
+
+// UpdatePushPreferences handles PUT /api/user/push-preferences
+// Body: any subset of {"qr_expiring": bool, "analysis_completed": bool, "payment_confirmed": bool,
+// "email_digest": "off"|"weekly"|"monthly"}
+func (ph *PushHandler) UpdatePushPreferences(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims := ph.claimsFromRequest(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	pref, err := ph.pushService.WithContext(r.Context()).GetPreferences(claims.UserID)
+	if err != nil {
+		http.Error(w, "Failed to get push preferences", http.StatusInternalServerError)
+		return
+	}
+
+	var payload struct {
+		QRExpiring        *bool   `json:"qr_expiring"`
+		AnalysisCompleted *bool   `json:"analysis_completed"`
+		PaymentConfirmed  *bool   `json:"payment_confirmed"`
+		EmailDigest       *string `json:"email_digest"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if payload.EmailDigest != nil {
+		if !services.ValidDigestFrequency(*payload.EmailDigest) {
+			http.Error(w, "email_digest must be off, weekly or monthly", http.StatusBadRequest)
+			return
+		}
+		pref.EmailDigest = *payload.EmailDigest
+	}
+	if payload.QRExpiring != nil {
+		pref.QRExpiring = *payload.QRExpiring
+	}
+	if payload.AnalysisCompleted != nil {
+		pref.AnalysisCompleted = *payload.AnalysisCompleted
+	}
+	if payload.PaymentConfirmed != nil {
+		pref.PaymentConfirmed = *payload.PaymentConfirmed
+	}
+
+	if err := ph.pushService.WithContext(r.Context()).UpdatePreferences(*pref); err != nil {
+		http.Error(w, "Failed to update push preferences", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": pref})
+}
+
+func (ph *PushHandler) claimsFromRequest(r *http.Request) *services.JWTClaims {
+	authHeader := r.Header.Get("Authorization")
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if authHeader == "" || tokenString == authHeader {
+		return nil
+	}
+	claims, err := ph.authService.ValidateToken(tokenString)
+	if err != nil {
+		return nil
+	}
+	return claims
+}
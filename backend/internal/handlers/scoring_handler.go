@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/middleware"
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+	"back_wa/internal/services"
+)
+
+type ScoringHandler struct {
+	authService    *services.AuthService
+	scoringService *services.ScoringService
+}
+
+func NewScoringHandler(repos *repository.Repositories) *ScoringHandler {
+	return &ScoringHandler{
+		authService:    services.NewAuthService(repos.Users, repos.AuthSessions),
+		scoringService: services.NewScoringService(repos.Scoring),
+	}
+}
+
+// GetScoringConfig handles GET /api/admin/scoring
+func (sh *ScoringHandler) GetScoringConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	config, err := sh.scoringService.WithContext(r.Context()).Get()
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to load scoring configuration", "error", err)
+		http.Error(w, "Failed to load scoring configuration", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"data":     config,
+		"defaults": models.DefaultScoringConfig(),
+	})
+}
+
+// UpdateScoringConfig handles PUT /api/admin/scoring with the full configuration
+// ({"good_average", "fair_average", "parameters": [...]}); analyses use it right away
+func (sh *ScoringHandler) UpdateScoringConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims := middleware.ClaimsFromContext(r.Context())
+
+	var config models.ScoringConfig
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := config.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	saved, err := sh.scoringService.WithContext(r.Context()).Update(&config, claims.UserID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to save scoring configuration", "error", err)
+		http.Error(w, "Failed to save scoring configuration", http.StatusInternalServerError)
+		return
+	}
+	logging.FromContext(r.Context()).Info(fmt.Sprintf("Admin %d updated the scoring configuration (version %d)", claims.UserID, saved.ID), "admin_id", claims.UserID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    saved,
+	})
+}
+
+// SimulateAnalysis handles POST /api/analysis/simulate: scores the eight parameters supplied in the
+// body without a WhatsApp connection and returns strength, summary and recommendations. Nothing is
+// stored. Admins may add "scoring" to try a draft configuration before saving it.
+func (sh *ScoringHandler) SimulateAnalysis(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	authHeader := r.Header.Get("Authorization")
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if authHeader == "" || tokenString == authHeader {
+		http.Error(w, "Authorization header required", http.StatusUnauthorized)
+		return
+	}
+	claims, err := sh.authService.ValidateToken(tokenString)
+	if err != nil {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	var input services.ScoringInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	values, err := input.Values()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	config := services.ActiveScoringConfig()
+	if input.Scoring != nil {
+		if claims.Role != "admin" {
+			http.Error(w, "Only admins can simulate a draft scoring configuration", http.StatusForbidden)
+			return
+		}
+		if err := input.Scoring.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		config = input.Scoring
+		config.ID = 0
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    services.SimulateScoring(config, values),
+	})
+}
+
+// GetScoringHistory handles GET /api/analysis/scoring-rules/history: every version of the scoring
+// rules (newest first) with the period it was in effect and what changed from the version before,
+// so a different rating of an unchanged account can be traced to a rules change. Results carry
+// the version they were scored with in "scoring_version".
+func (sh *ScoringHandler) GetScoringHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	authHeader := r.Header.Get("Authorization")
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if authHeader == "" || tokenString == authHeader {
+		http.Error(w, "Authorization header required", http.StatusUnauthorized)
+		return
+	}
+	if _, err := sh.authService.ValidateToken(tokenString); err != nil {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	history, err := sh.scoringService.WithContext(r.Context()).History()
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to load scoring history", "error", err)
+		http.Error(w, "Failed to load scoring history", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    history,
+	})
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"back_wa/internal/middleware"
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+	"back_wa/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+type ShareHandler struct {
+	authService  *services.AuthService
+	shareService *services.ShareLinkService
+}
+
+func NewShareHandler(repos *repository.Repositories) *ShareHandler {
+	return &ShareHandler{
+		authService:  services.NewAuthService(repos.Users, repos.AuthSessions),
+		shareService: services.NewShareLinkService(repos.ShareLinks, repos.Analyses),
+	}
+}
+
+// CreateShareLink handles POST /api/analysis/{id}/share
+// Body: {"resource": "json"|"pdf", "ttl_minutes": 60}
+func (sh *ShareHandler) CreateShareLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims := sh.claimsFromRequest(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	analysisID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid analysis ID", http.StatusBadRequest)
+		return
+	}
+
+	var payload struct {
+		Resource   string `json:"resource"`
+		TTLMinutes int    `json:"ttl_minutes"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if payload.Resource == "" {
+		payload.Resource = models.ShareResourceJSON
+	}
+	if payload.Resource != models.ShareResourceJSON && payload.Resource != models.ShareResourcePDF {
+		http.Error(w, "resource must be json or pdf", http.StatusBadRequest)
+		return
+	}
+
+	link, signedURL, err := sh.shareService.WithContext(r.Context()).Create(claims.UserID, uint(analysisID), payload.Resource,
+		time.Duration(payload.TTLMinutes)*time.Minute, publicBaseURL(r))
+	if err != nil {
+		if errors.Is(err, services.ErrShareAnalysisNotFound) {
+			http.Error(w, "Analysis not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to create share link", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"link_id":    link.LinkID,
+		"url":        signedURL,
+		"resource":   link.Resource,
+		"expires_at": link.ExpiresAt,
+	})
+}
+
+// RevokeShareLink handles DELETE /api/analysis/share/{link_id}
+func (sh *ShareHandler) RevokeShareLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims := sh.claimsFromRequest(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := sh.shareService.WithContext(r.Context()).Revoke(claims.UserID, mux.Vars(r)["link_id"]); err != nil {
+		if errors.Is(err, services.ErrShareLinkInvalid) {
+			http.Error(w, "Share link not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to revoke share link", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "message": "Share link revoked"})
+}
+
+// GetSharedAnalysis handles GET /api/shared/analysis/{link_id}?expires=...&signature=...
+// Public endpoint: the signature is the credential, no login required
+func (sh *ShareHandler) GetSharedAnalysis(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	link, analysis, err := sh.shareService.WithContext(r.Context()).Resolve(mux.Vars(r)["link_id"], q.Get("expires"), q.Get("signature"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrShareLinkExpired):
+			http.Error(w, "Link sudah kedaluwarsa atau dicabut", http.StatusGone)
+		case errors.Is(err, services.ErrShareLinkInvalid):
+			http.Error(w, "Link tidak valid", http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to load shared analysis", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private, no-store")
+	if link.Resource == models.ShareResourcePDF {
+		brandName := services.DefaultBranding().BrandName
+		if tenant := middleware.TenantFromContext(r.Context()); tenant != nil {
+			brandName = tenant.BrandName
+		}
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"analysis_%d.pdf\"", analysis.ID))
+		w.Write(services.RenderAnalysisPDF(analysis, brandName))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"data":       analysis,
+		"expires_at": link.ExpiresAt,
+	})
+}
+
+func (sh *ShareHandler) claimsFromRequest(r *http.Request) *services.JWTClaims {
+	authHeader := r.Header.Get("Authorization")
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if authHeader == "" || tokenString == authHeader {
+		return nil
+	}
+	claims, err := sh.authService.ValidateToken(tokenString)
+	if err != nil {
+		return nil
+	}
+	return claims
+}
+
+// publicBaseURL is the externally reachable API origin used in signed URLs
+func publicBaseURL(r *http.Request) string {
+	if base := os.Getenv("PUBLIC_API_BASE_URL"); base != "" {
+		return strings.TrimRight(base, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
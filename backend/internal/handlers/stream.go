@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+)
+
+// streamFlushEvery is how many rows are written between flushes of a streamed response
+const streamFlushEvery = 200
+
+// jsonArrayStream writes {"<meta>":..., "<field>":[ ... ], "success":true} row by row,
+// so large result sets are never held in memory and reach the client as chunked output.
+type jsonArrayStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	enc     *json.Encoder
+	count   int
+}
+
+// newJSONArrayStream sends the headers and the opening of the object; meta fields come first
+func newJSONArrayStream(w http.ResponseWriter, field string, meta map[string]interface{}) *jsonArrayStream {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	s := &jsonArrayStream{w: w, enc: json.NewEncoder(w)}
+	s.flusher, _ = w.(http.Flusher)
+
+	w.Write([]byte("{"))
+	for key, value := range meta {
+		s.writeKey(key)
+		s.enc.Encode(value)
+		w.Write([]byte(","))
+	}
+	s.writeKey(field)
+	w.Write([]byte("["))
+	return s
+}
+
+// Write appends one element to the array
+func (s *jsonArrayStream) Write(v interface{}) error {
+	if s.count > 0 {
+		if _, err := s.w.Write([]byte(",")); err != nil {
+			return err
+		}
+	}
+	if err := s.enc.Encode(v); err != nil {
+		return err
+	}
+	s.count++
+	if s.flusher != nil && s.count%streamFlushEvery == 0 {
+		s.flusher.Flush()
+	}
+	return nil
+}
+
+// Close terminates the document. The status code is already sent, so a failure
+// part-way through is reported in the body as "success": false with an error.
+func (s *jsonArrayStream) Close(err error) {
+	s.w.Write([]byte("],"))
+	if err != nil {
+		s.writeKey("error")
+		s.enc.Encode(err.Error())
+		s.w.Write([]byte(`,"success":false}`))
+	} else {
+		s.w.Write([]byte(`"success":true}`))
+	}
+	s.w.Write([]byte("\n"))
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+}
+
+func (s *jsonArrayStream) writeKey(key string) {
+	s.enc.Encode(key)
+	s.w.Write([]byte(":"))
+}
+
+// csvStream writes CSV rows and flushes them to the client every streamFlushEvery rows
+type csvStream struct {
+	cw      *csv.Writer
+	flusher http.Flusher
+	count   int
+}
+
+func newCSVStream(w http.ResponseWriter, header []string) *csvStream {
+	s := &csvStream{cw: csv.NewWriter(w)}
+	s.flusher, _ = w.(http.Flusher)
+	s.cw.Write(header)
+	return s
+}
+
+// Write appends one record
+func (s *csvStream) Write(record []string) error {
+	if err := s.cw.Write(record); err != nil {
+		return err
+	}
+	s.count++
+	if s.count%streamFlushEvery == 0 {
+		s.Flush()
+	}
+	return nil
+}
+
+// Flush pushes buffered rows to the client
+func (s *csvStream) Flush() error {
+	s.cw.Flush()
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+	return s.cw.Error()
+}
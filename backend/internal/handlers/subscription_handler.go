@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+	"back_wa/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+type SubscriptionHandler struct {
+	authService         *services.AuthService
+	paymentService      *services.PaymentService
+	subscriptionService *services.SubscriptionService
+	tenantService       *services.TenantService
+}
+
+func NewSubscriptionHandler(repos *repository.Repositories, paymentService *services.PaymentService) *SubscriptionHandler {
+	return &SubscriptionHandler{
+		authService:         services.NewAuthService(repos.Users, repos.AuthSessions),
+		paymentService:      paymentService,
+		subscriptionService: services.NewSubscriptionService(repos.Subscriptions),
+		tenantService:       services.NewTenantService(repos.Tenants),
+	}
+}
+
+// subscribeRequest is the body of POST /api/subscriptions
+type subscribeRequest struct {
+	PlanCode        string `json:"plan_code"`
+	Email           string `json:"email,omitempty"`
+	Gateway         string `json:"gateway,omitempty"`
+	RedirectBaseURL string `json:"redirect_base_url,omitempty"`
+}
+
+// ListPlans handles GET /api/plans (public)
+func (sh *SubscriptionHandler) ListPlans(w http.ResponseWriter, r *http.Request) {
+	plans, err := sh.subscriptionService.WithContext(r.Context()).ListPlans(false)
+	if err != nil {
+		http.Error(w, "Failed to get plans", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    plans,
+	})
+}
+
+// Subscribe handles POST /api/subscriptions: creates (or reuses) a pending subscription and its invoice.
+// The subscription starts once the invoice is paid.
+func (sh *SubscriptionHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	claims, ok := sh.claims(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req subscribeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.PlanCode = strings.ToLower(strings.TrimSpace(req.PlanCode))
+	if req.PlanCode == "" {
+		http.Error(w, "plan_code is required", http.StatusBadRequest)
+		return
+	}
+
+	if req.Email == "" {
+		if user, err := sh.authService.GetUserByID(claims.UserID); err == nil {
+			req.Email = user.Email
+		}
+	}
+
+	subscription, plan, err := sh.subscriptionService.WithContext(r.Context()).Subscribe(claims.UserID, req.PlanCode)
+	if err != nil {
+		if errors.Is(err, services.ErrPlanNotFound) {
+			http.Error(w, "Plan not found", http.StatusNotFound)
+			return
+		}
+		logging.FromContext(r.Context()).Error("Failed to create subscription", "user_id", claims.UserID, "error", err)
+		http.Error(w, "Failed to create subscription", http.StatusInternalServerError)
+		return
+	}
+
+	payment, err := sh.paymentService.WithContext(r.Context()).CreatePaymentForTenant(models.CreatePaymentRequest{
+		Email:           req.Email,
+		Amount:          plan.Price,
+		Category:        fmt.Sprintf("Langganan %s", plan.Name),
+		RedirectBaseURL: req.RedirectBaseURL,
+		Gateway:         req.Gateway,
+		SubscriptionID:  &subscription.ID,
+	}, int(claims.UserID), sh.tenantService.WithContext(r.Context()).ResolveRequest(r))
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to create subscription payment", "user_id", claims.UserID, "error", err)
+		switch {
+		case errors.Is(err, services.ErrRedirectNotAllowed):
+			http.Error(w, "Redirect URL tidak diizinkan.", http.StatusBadRequest)
+		case errors.Is(err, services.ErrUnknownPaymentGateway):
+			http.Error(w, "Payment gateway tidak dikenal (gunakan xendit atau midtrans).", http.StatusBadRequest)
+		case strings.Contains(err.Error(), "xendit_error"), strings.Contains(err.Error(), "midtrans_error"):
+			http.Error(w, "Gagal membuat invoice di payment gateway.", http.StatusBadGateway)
+		default:
+			http.Error(w, "Failed to create subscription payment", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"subscription": subscription,
+			"plan":         plan,
+			"payment":      payment,
+		},
+	})
+}
+
+// GetCurrent handles GET /api/subscriptions/current (data is null without an active subscription)
+func (sh *SubscriptionHandler) GetCurrent(w http.ResponseWriter, r *http.Request) {
+	claims, ok := sh.claims(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	status, err := sh.subscriptionService.WithContext(r.Context()).Current(claims.UserID)
+	if err != nil && !errors.Is(err, services.ErrNoSubscription) {
+		http.Error(w, "Failed to get subscription", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    status,
+	})
+}
+
+// AdminListPlans handles GET /api/admin/plans (including inactive plans)
+func (sh *SubscriptionHandler) AdminListPlans(w http.ResponseWriter, r *http.Request) {
+	plans, err := sh.subscriptionService.WithContext(r.Context()).ListPlans(true)
+	if err != nil {
+		http.Error(w, "Failed to get plans", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    plans,
+	})
+}
+
+// CreatePlan handles POST /api/admin/plans
+func (sh *SubscriptionHandler) CreatePlan(w http.ResponseWriter, r *http.Request) {
+	// New plans are offered unless the body says otherwise
+	plan := models.Plan{IsActive: true}
+	if err := json.NewDecoder(r.Body).Decode(&plan); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := plan.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := sh.subscriptionService.WithContext(r.Context()).CreatePlan(&plan); err != nil {
+		http.Error(w, "Failed to create plan", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    plan,
+	})
+}
+
+// UpdatePlan handles PUT /api/admin/plans/{id}
+func (sh *SubscriptionHandler) UpdatePlan(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid plan ID", http.StatusBadRequest)
+		return
+	}
+
+	changes := models.Plan{IsActive: true}
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := changes.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	plan, err := sh.subscriptionService.WithContext(r.Context()).UpdatePlan(uint(id), &changes)
+	if err != nil {
+		if errors.Is(err, services.ErrPlanNotFound) {
+			http.Error(w, "Plan not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to update plan", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    plan,
+	})
+}
+
+// claims validates the bearer token
+func (sh *SubscriptionHandler) claims(r *http.Request) (*services.JWTClaims, bool) {
+	authHeader := r.Header.Get("Authorization")
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if authHeader == "" || tokenString == authHeader {
+		return nil, false
+	}
+	claims, err := sh.authService.ValidateToken(tokenString)
+	return claims, err == nil
+}
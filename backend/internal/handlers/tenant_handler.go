@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"back_wa/internal/repository"
+	"back_wa/internal/services"
+)
+
+type TenantHandler struct {
+	tenantService *services.TenantService
+}
+
+func NewTenantHandler(repos *repository.Repositories) *TenantHandler {
+	return &TenantHandler{
+		tenantService: services.NewTenantService(repos.Tenants),
+	}
+}
+
+// GetBranding handles GET /api/tenant/branding
+// Resolves the tenant by X-API-Key or Host header and returns its public branding
+func (th *TenantHandler) GetBranding(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	branding := services.DefaultBranding()
+	isDefault := true
+	if tenant := th.tenantService.WithContext(r.Context()).ResolveRequest(r); tenant != nil {
+		branding = tenant.Branding()
+		isDefault = false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"is_default": isDefault,
+		"data":       branding,
+	})
+}
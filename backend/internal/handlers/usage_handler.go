@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"back_wa/internal/repository"
+	"back_wa/internal/services"
+)
+
+type UsageHandler struct {
+	authService  *services.AuthService
+	usageService *services.UsageService
+}
+
+func NewUsageHandler(repos *repository.Repositories) *UsageHandler {
+	return &UsageHandler{
+		authService:  services.NewAuthService(repos.Users, repos.AuthSessions),
+		usageService: services.NewUsageService(repos.Usage, repos.Transactions),
+	}
+}
+
+// GetUsage handles GET /api/user/usage?period=YYYY-MM (default: current month).
+// Shows the user's own API requests, scans and credits, e.g. to understand quota errors.
+func (uh *UsageHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	authHeader := r.Header.Get("Authorization")
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if authHeader == "" || tokenString == authHeader {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	claims, err := uh.authService.ValidateToken(tokenString)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	period, ok := periodFromQuery(r)
+	if !ok {
+		http.Error(w, "period must be in YYYY-MM format", http.StatusBadRequest)
+		return
+	}
+
+	report, err := uh.usageService.WithContext(r.Context()).GetUserUsage(claims.UserID, period)
+	if err != nil {
+		http.Error(w, "Failed to get usage", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    report,
+	})
+}
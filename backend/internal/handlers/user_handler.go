@@ -1,38 +1,60 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
-	"back_wa/internal/database"
+	"back_wa/internal/logging"
+	"back_wa/internal/middleware"
 	"back_wa/internal/models"
+	"back_wa/internal/repository"
 	"back_wa/internal/services"
 
 	"github.com/gorilla/mux"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type UserHandler struct {
+	users                repository.UserRepo
 	authService          *services.AuthService
 	otpService           *services.OTPService
 	passwordResetService *services.PasswordResetService
+	lockoutService       *services.LoginLockoutService
 	emailService         *services.EmailService
 	analysisService      *services.AnalysisService
+	feedbackService      *services.FeedbackService
+	goalService          *services.GoalService
+	entitlements         *services.EntitlementService
+	tenantService        *services.TenantService
+	sessionCookies       *services.SessionCookieConfig
 	// Simple in-memory storage for registration OTPs
 	registrationOTPs map[string]string
 }
 
-func NewUserHandler() *UserHandler {
+func NewUserHandler(repos *repository.Repositories) *UserHandler {
+	entitlements := services.NewEntitlementService(repos)
+	lockout := services.NewLoginLockoutService(repos.Users, repos.Tenants)
 	return &UserHandler{
-		authService:          &services.AuthService{},
-		otpService:           services.NewOTPService(),
-		passwordResetService: services.NewPasswordResetService(),
+		users:                repos.Users,
+		authService:          services.NewAuthService(repos.Users, repos.AuthSessions).WithEntitlements(entitlements).WithLockout(lockout),
+		otpService:           services.NewOTPService(repos.Users),
+		passwordResetService: services.NewPasswordResetService(repos.Users, repos.Tenants),
+		lockoutService:       lockout,
 		emailService:         &services.EmailService{},
-		analysisService:      services.NewAnalysisService(),
+		analysisService:      services.NewAnalysisService(repos),
+		feedbackService:      services.NewFeedbackService(repos.Feedback, repos.Analyses),
+		goalService:          services.NewGoalService(repos.Goals, repos.Analyses),
+		entitlements:         entitlements,
+		tenantService:        services.NewTenantService(repos.Tenants),
+		sessionCookies:       services.LoadSessionCookieConfig(),
 		registrationOTPs:     make(map[string]string),
 	}
 }
@@ -57,7 +79,7 @@ func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Register user
-	user, err := h.authService.Register(req)
+	user, err := h.authService.WithContext(r.Context()).Register(req)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -98,20 +120,104 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Login user
-	token, user, err := h.authService.Login(req)
+	token, user, err := h.authService.WithContext(r.Context()).Login(req, services.LoginClient{IP: middleware.ClientIP(r), UserAgent: r.UserAgent()})
+	if errors.Is(err, services.ErrInvalidScope) {
+		http.Error(w, "Unknown scope requested (allowed: wa, payments, admin)", http.StatusBadRequest)
+		return
+	}
+	var blocked *services.LoginBlockedError
+	if errors.As(err, &blocked) {
+		writeLoginBlocked(w, blocked)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
+	scopes, _ := h.authService.TokenScopes(token)
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Login successful",
+		"token":   token,
+		"user":    user,
+		"scopes":  scopes,
+	}
+
+	// Cookie deployments: JWT goes into an HttpOnly cookie instead of the response body
+	if h.sessionCookies.CookieMode() {
+		csrfToken, err := h.sessionCookies.IssueSession(w, token)
+		if err != nil {
+			http.Error(w, "Failed to create session", http.StatusInternalServerError)
+			return
+		}
+		delete(response, "token")
+		response["csrf_token"] = csrfToken
+	}
 
 	// Return success response with token
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetSession returns expiry and scopes of the caller's token so the frontend can refresh before it expires
+func (h *UserHandler) GetSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		http.Error(w, "Authorization header required", http.StatusUnauthorized)
+		return
+	}
+
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if tokenString == authHeader {
+		http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
+		return
+	}
+
+	session, err := h.authService.Session(tokenString)
+	if err != nil {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
-		"message": "Login successful",
-		"token":   token,
-		"user":    user,
+		"session": session,
+	})
+}
+
+// Logout revokes the caller's session and clears the session cookies (cookie mode)
+func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	authHeader := r.Header.Get("Authorization")
+	if tokenString := strings.TrimPrefix(authHeader, "Bearer "); tokenString != authHeader {
+		if claims, err := h.authService.ParseClaims(tokenString); err == nil {
+			if err := h.authService.WithContext(r.Context()).RevokeToken(claims); err != nil {
+				logging.FromContext(r.Context()).Warn("Failed to revoke session on logout", "user_id", claims.UserID, "error", err)
+			}
+		}
+	}
+
+	if h.sessionCookies.CookieMode() {
+		h.sessionCookies.ClearSession(w)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Logged out",
 	})
 }
 
@@ -129,9 +235,7 @@ func (h *UserHandler) CheckPhoneNumber(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if phone number exists in database
-	db := database.GetDB()
-	var existingUser models.User
-	err := db.Where("phone_number = ?", phoneNumber).First(&existingUser).Error
+	existingUser, err := h.users.FindByPhoneNumber(r.Context(), phoneNumber)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -186,7 +290,7 @@ func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get user profile
-	user, err := h.authService.GetUserByID(claims.UserID)
+	user, err := h.authService.WithContext(r.Context()).GetUserByID(claims.UserID)
 	if err != nil {
 		http.Error(w, "User not found", http.StatusNotFound)
 		return
@@ -201,26 +305,96 @@ func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// SendOTP sends a verification OTP to user's email
+// CreateScopedToken mints a short-lived purpose-scoped token (e.g. wa:qr) for an embedded scan widget
+func (h *UserHandler) CreateScopedToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract token from Authorization header
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		http.Error(w, "Authorization header required", http.StatusUnauthorized)
+		return
+	}
+
+	// Remove "Bearer " prefix
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if tokenString == authHeader {
+		http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
+		return
+	}
+
+	// Only a full account token may mint scoped tokens
+	claims, err := h.authService.ValidateToken(tokenString)
+	if err != nil {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Scope      string `json:"scope"`
+		TTLSeconds int    `json:"ttl_seconds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	token, expiresAt, err := h.authService.GenerateScopedToken(claims, req.Scope, time.Duration(req.TTLSeconds)*time.Second)
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownScope) {
+			http.Error(w, "Unsupported scope", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to create token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"token":      token,
+		"scope":      req.Scope,
+		"expires_at": expiresAt,
+	})
+}
+
+// SendOTP sends a verification OTP to user's email, or by WhatsApp/SMS to the user's phone number
 func (h *UserHandler) SendOTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 	var payload struct {
-		Email string `json:"email"`
+		Email   string `json:"email"`
+		Channel string `json:"channel"` // optional: email, whatsapp or sms
 	}
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Email == "" {
 		http.Error(w, "Email is required", http.StatusBadRequest)
 		return
 	}
+	if payload.Channel != "" && !services.ValidOTPChannel(payload.Channel) {
+		http.Error(w, services.ErrInvalidOTPChannel.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Send with the tenant's sender identity when the request comes from a white-label partner
+	otpService := h.otpService.WithContext(r.Context()).WithChannel(payload.Channel)
+	if tenant := h.tenantService.WithContext(r.Context()).ResolveRequest(r); tenant != nil {
+		otpService = otpService.WithEmail(services.EmailServiceFor(tenant))
+	}
 
 	// Check if user exists first
-	db := database.GetDB()
-	var user models.User
-	if err := db.Where("email = ?", payload.Email).First(&user).Error; err != nil {
+	user, err := h.users.FindByEmail(r.Context(), payload.Email)
+	if err != nil {
 		// User doesn't exist, this is for registration
-		otpCode, err := h.otpService.GenerateAndSend(payload.Email, 0) // Use 0 as temporary user ID
+		otpCode, err := otpService.GenerateAndSend(payload.Email, 0) // Use 0 as temporary user ID
+		if errors.Is(err, services.ErrOTPRateLimited) {
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
 		if err != nil {
 			http.Error(w, "Failed to send OTP", http.StatusInternalServerError)
 			return
@@ -228,15 +402,19 @@ func (h *UserHandler) SendOTP(w http.ResponseWriter, r *http.Request) {
 
 		// Store OTP in memory for registration flow
 		h.registrationOTPs[payload.Email] = otpCode
-		fmt.Printf("REGISTRATION OTP for %s: %s\n", payload.Email, otpCode)
+		logging.FromContext(r.Context()).Debug(fmt.Sprintf("REGISTRATION OTP for %s: %s", payload.Email, otpCode))
 	} else {
 		// User exists, this is for existing user (forgot password, etc.)
-		otpCode, err := h.otpService.GenerateAndSend(payload.Email, user.ID)
+		otpCode, err := otpService.GenerateAndSend(payload.Email, user.ID)
+		if errors.Is(err, services.ErrOTPRateLimited) {
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
 		if err != nil {
 			http.Error(w, "Failed to send OTP", http.StatusInternalServerError)
 			return
 		}
-		fmt.Printf("EXISTING USER OTP for %s: %s\n", payload.Email, otpCode)
+		logging.FromContext(r.Context()).Debug(fmt.Sprintf("EXISTING USER OTP for %s: %s", payload.Email, otpCode))
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -262,13 +440,11 @@ func (h *UserHandler) VerifyOTP(w http.ResponseWriter, r *http.Request) {
 			delete(h.registrationOTPs, payload.Email)
 
 			// Update user's email verification status if user exists
-			db := database.GetDB()
-			var user models.User
-			if err := db.Where("email = ?", payload.Email).First(&user).Error; err == nil {
+			if user, err := h.users.FindByEmail(r.Context(), payload.Email); err == nil {
 				now := time.Now()
 				user.EmailVerified = true
 				user.EmailVerifiedAt = &now
-				_ = db.Save(&user).Error
+				_ = h.users.Save(r.Context(), user)
 			}
 
 			w.Header().Set("Content-Type", "application/json")
@@ -295,20 +471,18 @@ func (h *UserHandler) VerifyOTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// For existing users, try to validate OTP normally
-	ok, err := h.otpService.Validate(payload.Email, payload.Otp)
+	ok, err := h.otpService.WithContext(r.Context()).Validate(payload.Email, payload.Otp)
 	if err != nil || !ok {
 		http.Error(w, "Invalid or expired OTP", http.StatusBadRequest)
 		return
 	}
 
 	// OTP is valid for existing user, mark email as verified
-	db := database.GetDB()
-	var user models.User
-	if err := db.Where("email = ?", payload.Email).First(&user).Error; err == nil {
+	if user, err := h.users.FindByEmail(r.Context(), payload.Email); err == nil {
 		now := time.Now()
 		user.EmailVerified = true
 		user.EmailVerifiedAt = &now
-		_ = db.Save(&user).Error
+		_ = h.users.Save(r.Context(), user)
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "message": "Email verified"})
@@ -327,9 +501,8 @@ func (h *UserHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if user exists first
-	db := database.GetDB()
-	var user models.User
-	if err := db.Where("email = ?", payload.Email).First(&user).Error; err != nil {
+	user, err := h.users.FindByEmail(r.Context(), payload.Email)
+	if err != nil {
 		// User doesn't exist, but don't reveal this information
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "message": "Jika email terdaftar, OTP telah dikirim"})
@@ -337,7 +510,7 @@ func (h *UserHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Generate and send OTP for password reset (no link)
-	_, err := h.otpService.GenerateAndSend(user.Email, user.ID)
+	_, err = h.otpService.GenerateAndSend(user.Email, user.ID)
 	if err != nil {
 		// do not reveal existence
 		w.Header().Set("Content-Type", "application/json")
@@ -367,15 +540,14 @@ func (h *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Find user by email
-	db := database.GetDB()
-	var user models.User
-	if err := db.Where("email = ?", payload.Email).First(&user).Error; err != nil {
+	user, err := h.users.FindByEmail(r.Context(), payload.Email)
+	if err != nil {
 		http.Error(w, "User not found", http.StatusBadRequest)
 		return
 	}
 
 	// Validate OTP using existing OTP service
-	ok, err := h.otpService.Validate(payload.Email, payload.Otp)
+	ok, err := h.otpService.WithContext(r.Context()).Validate(payload.Email, payload.Otp)
 	if err != nil || !ok {
 		http.Error(w, "Invalid or expired OTP", http.StatusBadRequest)
 		return
@@ -389,16 +561,25 @@ func (h *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	user.PasswordHash = string(hashedPassword)
-	if err := db.Save(&user).Error; err != nil {
+	if err := h.users.Save(r.Context(), user); err != nil {
 		http.Error(w, "Failed to update password", http.StatusInternalServerError)
 		return
 	}
+	// A new password lifts a lockout: the owner proved access to the mailbox
+	if err := h.lockoutService.WithContext(r.Context()).Reset(user.ID); err != nil {
+		logging.FromContext(r.Context()).Warn("Failed to reset failed logins", "user_id", user.ID, "error", err)
+	}
+	// Whoever knew the old password is signed out everywhere
+	if _, err := h.authService.WithContext(r.Context()).RevokeSessions(user.ID, ""); err != nil {
+		logging.FromContext(r.Context()).Warn("Failed to revoke sessions after password reset", "user_id", user.ID, "error", err)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "message": "Password updated successfully"})
 }
 
-// GetAnalysisHistory returns analysis history for the authenticated user
+// GetAnalysisHistory handles GET /api/analysis/history?limit=&offset=&from=&to=&strength=&sort=&order=
+// for the authenticated user; "total" counts the analyses matching the filters
 func (h *UserHandler) GetAnalysisHistory(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -426,20 +607,53 @@ func (h *UserHandler) GetAnalysisHistory(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Get analysis history with phone numbers
-	historyItems, err := h.analysisService.GetAnalysisHistoryWithPhone(claims.UserID)
+	listQuery, err := listQueryFromRequest(r, repository.HistorySorts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	strengths, err := listFilter(r, "strength", "Baik", "Cukup", "Buruk")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Only the newest analyses of the tier's history size are listed
+	query := services.HistoryQuery{
+		ListQuery: listQuery,
+		Strengths: strengths,
+		Newest:    h.entitlements.WithContext(r.Context()).HistoryLimit(claims.UserID),
+	}
+
+	analyses := h.analysisService.WithContext(r.Context())
+	total, err := analyses.CountHistory(claims.UserID, query)
 	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to count analysis history", "user_id", claims.UserID, "error", err)
 		http.Error(w, "Failed to get analysis history", http.StatusInternalServerError)
 		return
 	}
+	meta := map[string]interface{}{"total": total}
+	if query.Limit > 0 {
+		meta["limit"], meta["offset"] = query.Limit, query.Offset
+	}
+	if query.Newest > 0 {
+		meta["history_limit"] = query.Newest
+	}
+	// The dashboard shows goal progress above the history
+	if progress, err := h.goalService.WithContext(r.Context()).Progress(claims.UserID); err != nil {
+		logging.FromContext(r.Context()).Warn("Failed to load goal progress", "user_id", claims.UserID, "error", err)
+	} else if progress != nil {
+		meta["goal"] = progress
+	}
 
-	// Return history
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"data":    historyItems,
+	// Stream analysis history with phone numbers row by row
+	stream := newJSONArrayStream(w, "data", meta)
+	err = analyses.EachHistoryItem(claims.UserID, query, func(item services.HistoryItem) error {
+		return stream.Write(item)
 	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error(fmt.Sprintf("Failed to stream analysis history for user %d", claims.UserID), "error", err, "user_id", claims.UserID)
+	}
+	stream.Close(err)
 }
 
 // GetAnalysisDetail returns detailed analysis result for a specific analysis ID
@@ -484,19 +698,88 @@ func (h *UserHandler) GetAnalysisDetail(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	fields, err := models.ParseAnalysisFields(r.URL.Query().Get("fields"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Get analysis detail (ensure user can only access their own analysis)
-	analysisDetail, err := h.analysisService.GetAnalysisDetail(uint(analysisID), claims.UserID)
+	analysisDetail, err := h.analysisService.WithContext(r.Context()).GetAnalysisDetail(uint(analysisID), claims.UserID)
 	if err != nil {
 		http.Error(w, "Analysis not found", http.StatusNotFound)
 		return
 	}
+	analysisDetail.FeedbackPrompt = h.feedbackService.WithContext(r.Context()).ShouldPrompt(analysisDetail.ID)
 
 	// Return analysis detail
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
-		"data":    analysisDetail,
+		"data":    models.NewAnalysisResponse(analysisDetail).Sparse(fields),
+	})
+}
+
+// UpdateAnalysis handles PATCH /api/analysis/{id}: pin or unpin the result and set its label
+func (h *UserHandler) UpdateAnalysis(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	analysisID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid analysis ID", http.StatusBadRequest)
+		return
+	}
+
+	// Auth
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		http.Error(w, "Authorization header required", http.StatusUnauthorized)
+		return
+	}
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	claims, err := h.authService.ValidateToken(tokenString)
+	if err != nil {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	var annotation services.AnalysisAnnotation
+	if err := json.NewDecoder(r.Body).Decode(&annotation); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if annotation.Pinned == nil && annotation.Label == nil {
+		http.Error(w, "pinned or label is required", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.analysisService.WithContext(r.Context()).AnnotateAnalysis(claims.UserID, uint(analysisID), annotation)
+	if errors.Is(err, services.ErrAnalysisLabelTooLong) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Analysis not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error(fmt.Sprintf("Failed to update analysis %d", analysisID), "error", err, "user_id", claims.UserID)
+		http.Error(w, "Failed to update analysis", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"id":     result.ID,
+			"pinned": result.Pinned,
+			"label":  result.Label,
+		},
 	})
 }
 
@@ -534,7 +817,11 @@ func (h *UserHandler) DeleteAnalysis(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Delete
-	deleted, err := h.analysisService.DeleteAnalysisByID(claims.UserID, uint(analysisID64))
+	deleted, err := h.analysisService.WithContext(r.Context()).DeleteAnalysisByID(claims.UserID, uint(analysisID64))
+	if errors.Is(err, services.ErrLegalHold) {
+		http.Error(w, "Analisis ini sedang ditahan (legal hold) dan tidak dapat dihapus", http.StatusLocked)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to delete analysis", http.StatusInternalServerError)
 		return
@@ -544,7 +831,8 @@ func (h *UserHandler) DeleteAnalysis(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "deleted": deleted})
 }
 
-// DeleteAnalysesBulk deletes multiple analysis results for the authenticated user
+// DeleteAnalysesBulk deletes multiple analysis results for the authenticated user, undoable for
+// services.AnalysisUndoWindow
 func (h *UserHandler) DeleteAnalysesBulk(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -573,17 +861,19 @@ func (h *UserHandler) DeleteAnalysesBulk(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	deleted, err := h.analysisService.DeleteAnalysesByIDs(claims.UserID, payload.IDs)
+	// Pinned results are kept unless ?include_pinned=true
+	includePinned := r.URL.Query().Get("include_pinned") == "true"
+	deletion, err := h.analysisService.WithContext(r.Context()).DeleteAnalysesByIDs(claims.UserID, payload.IDs, includePinned)
 	if err != nil {
 		http.Error(w, "Failed to delete analyses", http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "deleted": deleted})
+	writeAnalysisDeletion(w, deletion)
 }
 
-// DeleteAllAnalyses deletes all analysis results for the authenticated user
+// DeleteAllAnalyses deletes all analysis results for the authenticated user, undoable for
+// services.AnalysisUndoWindow
 func (h *UserHandler) DeleteAllAnalyses(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -603,14 +893,73 @@ func (h *UserHandler) DeleteAllAnalyses(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	deleted, err := h.analysisService.DeleteAllAnalyses(claims.UserID)
+	// Pinned results are kept unless ?include_pinned=true
+	includePinned := r.URL.Query().Get("include_pinned") == "true"
+	deletion, err := h.analysisService.WithContext(r.Context()).DeleteAllAnalyses(claims.UserID, includePinned)
 	if err != nil {
 		http.Error(w, "Failed to delete analyses", http.StatusInternalServerError)
 		return
 	}
 
+	writeAnalysisDeletion(w, deletion)
+}
+
+// writeAnalysisDeletion answers a bulk delete with the undo token, when anything was deleted
+func writeAnalysisDeletion(w http.ResponseWriter, deletion *services.AnalysisDeletion) {
+	response := map[string]interface{}{
+		"success":        true,
+		"deleted":        deletion.Deleted,
+		"skipped_pinned": deletion.SkippedPinned,
+	}
+	if deletion.UndoToken != "" {
+		response["undo_token"] = deletion.UndoToken
+		response["undo_expires_at"] = deletion.UndoExpiresAt
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "deleted": deleted})
+	json.NewEncoder(w).Encode(response)
+}
+
+// UndoDeleteAnalyses restores the analyses of a bulk delete within its undo window
+func (h *UserHandler) UndoDeleteAnalyses(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Auth
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		http.Error(w, "Authorization header required", http.StatusUnauthorized)
+		return
+	}
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	claims, err := h.authService.ValidateToken(tokenString)
+	if err != nil {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UndoToken string `json:"undo_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || strings.TrimSpace(payload.UndoToken) == "" {
+		http.Error(w, "undo_token is required", http.StatusBadRequest)
+		return
+	}
+
+	restored, err := h.analysisService.WithContext(r.Context()).UndoDelete(claims.UserID, payload.UndoToken)
+	if errors.Is(err, services.ErrUndoExpired) {
+		http.Error(w, "Waktu untuk membatalkan penghapusan sudah habis", http.StatusGone)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to restore analyses", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "restored": restored})
 }
 
 // ChangePassword updates the authenticated user's password
@@ -644,9 +993,8 @@ func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Load user
-	db := database.GetDB()
-	var user models.User
-	if err := db.First(&user, claims.UserID).Error; err != nil {
+	user, err := h.users.FindByID(r.Context(), claims.UserID)
+	if err != nil {
 		http.Error(w, "User not found", http.StatusNotFound)
 		return
 	}
@@ -658,10 +1006,14 @@ func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update password
-	if err := h.authService.UpdatePassword(&user, payload.NewPassword); err != nil {
+	if err := h.authService.WithContext(r.Context()).UpdatePassword(user, payload.NewPassword); err != nil {
 		http.Error(w, "Failed to update password", http.StatusInternalServerError)
 		return
 	}
+	// Other devices must sign in again with the new password
+	if _, err := h.authService.WithContext(r.Context()).RevokeSessions(user.ID, claims.ID); err != nil {
+		logging.FromContext(r.Context()).Warn("Failed to revoke sessions after password change", "user_id", user.ID, "error", err)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "message": "Password updated"})
@@ -702,16 +1054,14 @@ func (h *UserHandler) ChangeUsername(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db := database.GetDB()
-	// Check uniqueness
-	var existing models.User
-	if err := db.Where("username = ?", payload.NewUsername).First(&existing).Error; err == nil && existing.ID != claims.UserID {
+	// Check uniqueness (usernames are unique across tenants)
+	if existing, err := h.users.FindByUsername(context.Background(), payload.NewUsername); err == nil && existing.ID != claims.UserID {
 		http.Error(w, "username already taken", http.StatusConflict)
 		return
 	}
 
 	// Update
-	if err := db.Model(&models.User{}).Where("id = ?", claims.UserID).Update("username", payload.NewUsername).Error; err != nil {
+	if err := h.users.UpdateUsername(r.Context(), claims.UserID, payload.NewUsername); err != nil {
 		http.Error(w, "Failed to update username", http.StatusInternalServerError)
 		return
 	}
@@ -719,3 +1069,195 @@ func (h *UserHandler) ChangeUsername(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "username": payload.NewUsername})
 }
+
+// OTPChannel handles GET and PUT /api/user/otp-channel: the channel OTP codes are sent through.
+// PUT body: {"channel": "email" | "whatsapp" | "sms" | ""}; empty uses the server default (OTP_CHANNEL).
+func (h *UserHandler) OTPChannel(w http.ResponseWriter, r *http.Request) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		http.Error(w, "Authorization header required", http.StatusUnauthorized)
+		return
+	}
+	claims, err := h.authService.ValidateToken(strings.TrimPrefix(authHeader, "Bearer "))
+	if err != nil {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var payload struct {
+			Channel string `json:"channel"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		err := h.otpService.WithContext(r.Context()).SetPreferredChannel(claims.UserID, payload.Channel)
+		if errors.Is(err, services.ErrInvalidOTPChannel) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to update OTP channel", http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, err := h.users.FindByID(r.Context(), claims.UserID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"channel":   user.OTPChannel,
+			"default":   services.DefaultOTPChannel(),
+			"available": services.AvailableOTPChannels(),
+		},
+	})
+}
+
+// writeLoginBlocked answers a login attempt during backoff (429) or lockout (423) with the wait
+func writeLoginBlocked(w http.ResponseWriter, blocked *services.LoginBlockedError) {
+	retryAfter := int(math.Ceil(blocked.RetryAfter(time.Now()).Seconds()))
+	status, errorType := http.StatusTooManyRequests, "login_backoff"
+	if blocked.Locked {
+		status, errorType = http.StatusLocked, "account_locked"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":             false,
+		"error":               blocked.Error(),
+		"error_type":          errorType,
+		"retry_after_seconds": retryAfter,
+		"locked":              blocked.Locked,
+		"blocked_until":       blocked.Until,
+	})
+}
+
+// UnlockAccount handles POST /api/auth/unlock-account with the email and token from the lockout email
+func (h *UserHandler) UnlockAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var payload struct {
+		Email string `json:"email"`
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Email == "" || payload.Token == "" {
+		http.Error(w, "Email and token are required", http.StatusBadRequest)
+		return
+	}
+
+	err := h.lockoutService.WithContext(r.Context()).Unlock(payload.Email, payload.Token)
+	if errors.Is(err, services.ErrInvalidUnlockToken) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to unlock account", "error", err)
+		http.Error(w, "Failed to unlock account", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "message": "Account unlocked, you can log in again"})
+}
+
+// sessionClaims validates the bearer token of a session management request
+func (h *UserHandler) sessionClaims(w http.ResponseWriter, r *http.Request) (*services.JWTClaims, bool) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		http.Error(w, "Authorization header required", http.StatusUnauthorized)
+		return nil, false
+	}
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	claims, err := h.authService.ValidateToken(tokenString)
+	if err != nil {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return nil, false
+	}
+	return claims, true
+}
+
+// ListSessions handles GET /api/auth/sessions: the devices signed in to the account
+func (h *UserHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.sessionClaims(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.authService.WithContext(r.Context()).ListSessions(claims.UserID, claims.ID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to list sessions", "user_id", claims.UserID, "error", err)
+		http.Error(w, "Failed to list sessions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "sessions": sessions})
+}
+
+// RevokeSession handles DELETE /api/auth/sessions/{id}: signs one device out
+func (h *UserHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.sessionClaims(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	err = h.authService.WithContext(r.Context()).RevokeSession(claims.UserID, uint(id))
+	if errors.Is(err, services.ErrSessionNotFound) {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to revoke session", "user_id", claims.UserID, "error", err)
+		http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "message": "Session revoked"})
+}
+
+// RevokeSessions handles DELETE /api/auth/sessions: signs out every other device,
+// or every device including this one with ?include_current=true
+func (h *UserHandler) RevokeSessions(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.sessionClaims(w, r)
+	if !ok {
+		return
+	}
+	keep := claims.ID
+	if r.URL.Query().Get("include_current") == "true" {
+		keep = ""
+	}
+
+	revoked, err := h.authService.WithContext(r.Context()).RevokeSessions(claims.UserID, keep)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to revoke sessions", "user_id", claims.UserID, "error", err)
+		http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+	if keep == "" && h.sessionCookies.CookieMode() {
+		h.sessionCookies.ClearSession(w)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "revoked": revoked})
+}
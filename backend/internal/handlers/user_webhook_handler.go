@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/repository"
+	"back_wa/internal/services"
+)
+
+type UserWebhookHandler struct {
+	authService    *services.AuthService
+	webhookService *services.WebhookService
+}
+
+func NewUserWebhookHandler(repos *repository.Repositories) *UserWebhookHandler {
+	return &UserWebhookHandler{
+		authService:    services.NewAuthService(repos.Users, repos.AuthSessions),
+		webhookService: services.NewWebhookService(repos.Webhooks),
+	}
+}
+
+// GetWebhook handles GET /api/user/webhook (data is null when none is configured)
+func (uh *UserWebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	claims := uh.claimsFromRequest(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	webhook, err := uh.webhookService.WithContext(r.Context()).Get(claims.UserID)
+	if err != nil && !errors.Is(err, services.ErrWebhookNotFound) {
+		http.Error(w, "Failed to get webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": webhook})
+}
+
+// SaveWebhook handles PUT /api/user/webhook ({"url": "https://...", "active": true, "rotate_secret": false}).
+// The signing secret is only returned when it is created or rotated.
+func (uh *UserWebhookHandler) SaveWebhook(w http.ResponseWriter, r *http.Request) {
+	claims := uh.claimsFromRequest(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	payload := struct {
+		URL          string `json:"url"`
+		Active       bool   `json:"active"`
+		RotateSecret bool   `json:"rotate_secret"`
+	}{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	webhook, secret, err := uh.webhookService.WithContext(r.Context()).Save(claims.UserID, payload.URL, payload.Active, payload.RotateSecret)
+	if errors.Is(err, services.ErrInvalidWebhookURL) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to save webhook", "user_id", claims.UserID, "error", err)
+		http.Error(w, "Failed to save webhook", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{"success": true, "data": webhook}
+	if secret != "" {
+		response["secret"] = secret
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// DeleteWebhook handles DELETE /api/user/webhook
+func (uh *UserWebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	claims := uh.claimsFromRequest(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := uh.webhookService.WithContext(r.Context()).Delete(claims.UserID); err != nil {
+		if errors.Is(err, services.ErrWebhookNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+}
+
+// ListDeliveries handles GET /api/user/webhook/deliveries?page=&limit=
+func (uh *UserWebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	claims := uh.claimsFromRequest(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	page := adminPage(r)
+	deliveries, total, err := uh.webhookService.WithContext(r.Context()).Deliveries(claims.UserID, page)
+	if err != nil {
+		http.Error(w, "Failed to list webhook deliveries", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    deliveries,
+		"total":   total,
+		"page":    page.Page,
+		"limit":   page.Limit,
+	})
+}
+
+func (uh *UserWebhookHandler) claimsFromRequest(r *http.Request) *services.JWTClaims {
+	authHeader := r.Header.Get("Authorization")
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if authHeader == "" || tokenString == authHeader {
+		return nil
+	}
+	claims, err := uh.authService.ValidateToken(tokenString)
+	if err != nil {
+		return nil
+	}
+	return claims
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"back_wa/internal/repository"
+	"back_wa/internal/services"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+type VerifyHandler struct {
+	analysisService *services.AnalysisService
+}
+
+func NewVerifyHandler(repos *repository.Repositories) *VerifyHandler {
+	return &VerifyHandler{
+		analysisService: services.NewAnalysisService(repos),
+	}
+}
+
+// VerifyChecksum handles GET /api/public/verify/{checksum}
+// Public endpoint confirming a report's checksum belongs to an existing, unmodified analysis
+func (vh *VerifyHandler) VerifyChecksum(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	checksum := strings.ToLower(strings.TrimSpace(mux.Vars(r)["checksum"]))
+	if _, err := hex.DecodeString(checksum); err != nil || len(checksum) != 64 {
+		http.Error(w, "checksum must be a 64 character SHA-256 hex string", http.StatusBadRequest)
+		return
+	}
+
+	result, valid, err := vh.analysisService.VerifyByChecksum(checksum)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success":  false,
+				"exists":   false,
+				"valid":    false,
+				"checksum": checksum,
+				"message":  "Hasil analisis dengan checksum ini tidak ditemukan",
+			})
+			return
+		}
+		http.Error(w, "Failed to verify checksum", http.StatusInternalServerError)
+		return
+	}
+
+	message := "Hasil analisis asli dan tidak diubah"
+	if !valid {
+		message = "Data hasil analisis tidak cocok dengan checksum"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"exists":    true,
+		"valid":     valid,
+		"checksum":  checksum,
+		"scan_date": result.ScanDate,
+		"strength":  result.Strength,
+		"message":   message,
+	})
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+	"back_wa/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+// WebhookEventHandler lets admins audit and reprocess inbound payment webhooks
+type WebhookEventHandler struct {
+	authService    *services.AuthService
+	paymentService *services.PaymentService
+	webhookEvents  *services.WebhookEventService
+}
+
+func NewWebhookEventHandler(repos *repository.Repositories, paymentService *services.PaymentService) *WebhookEventHandler {
+	return &WebhookEventHandler{
+		authService:    services.NewAuthService(repos.Users, repos.AuthSessions),
+		paymentService: paymentService,
+		webhookEvents:  services.NewWebhookEventService(repos.WebhookEvents),
+	}
+}
+
+// ListEvents handles GET /api/admin/webhook-events?gateway=&state=&external_id=&page=&limit=
+func (eh *WebhookEventHandler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims := eh.claimsFromRequest(r)
+	if claims == nil || claims.Role != "admin" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := services.WebhookEventFilter{
+		Gateway:    query.Get("gateway"),
+		State:      query.Get("state"),
+		ExternalID: query.Get("external_id"),
+	}
+	switch filter.State {
+	case "", models.WebhookEventReceived, models.WebhookEventProcessed, models.WebhookEventFailed:
+	default:
+		http.Error(w, "state must be received, processed or failed", http.StatusBadRequest)
+		return
+	}
+
+	page := adminPage(r)
+	events, total, err := eh.webhookEvents.WithContext(r.Context()).List(filter, page)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to list webhook events", "error", err)
+		http.Error(w, "Failed to list webhook events", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    events,
+		"total":   total,
+		"page":    page.Page,
+		"limit":   page.Limit,
+	})
+}
+
+// GetEvent handles GET /api/admin/webhook-events/{id}: the event with its raw payload
+func (eh *WebhookEventHandler) GetEvent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims := eh.claimsFromRequest(r)
+	if claims == nil || claims.Role != "admin" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid webhook event ID", http.StatusBadRequest)
+		return
+	}
+	event, err := eh.webhookEvents.WithContext(r.Context()).Get(uint(id))
+	if errors.Is(err, services.ErrWebhookEventNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to load webhook event", "webhook_event_id", id, "error", err)
+		http.Error(w, "Failed to load webhook event", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    event,
+	})
+}
+
+// ReprocessEvent handles POST /api/admin/webhook-events/{id}/reprocess: applies the stored payload
+// again, e.g. after fixing what made it fail
+func (eh *WebhookEventHandler) ReprocessEvent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims := eh.claimsFromRequest(r)
+	if claims == nil || claims.Role != "admin" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid webhook event ID", http.StatusBadRequest)
+		return
+	}
+	event, err := eh.webhookEvents.WithContext(r.Context()).Reprocess(uint(id), eh.paymentService)
+	switch {
+	case errors.Is(err, services.ErrWebhookEventNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, services.ErrWebhookEventInProgress):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case event == nil:
+		logging.FromContext(r.Context()).Error("Failed to reprocess webhook event", "webhook_event_id", id, "error", err)
+		http.Error(w, fmt.Sprintf("Failed to reprocess webhook event: %v", err), http.StatusBadRequest)
+		return
+	}
+	logging.FromContext(r.Context()).Info(fmt.Sprintf("Admin %d reprocessed webhook event %d (%s): %s", claims.UserID, event.ID, event.ExternalID, event.State),
+		"audit", true, "admin_id", claims.UserID, "webhook_event_id", event.ID, "external_id", event.ExternalID, "state", event.State)
+
+	status := http.StatusOK
+	if err != nil {
+		status = http.StatusBadGateway
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": err == nil,
+		"data":    event,
+	})
+}
+
+func (eh *WebhookEventHandler) claimsFromRequest(r *http.Request) *services.JWTClaims {
+	authHeader := r.Header.Get("Authorization")
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if authHeader == "" || tokenString == authHeader {
+		return nil
+	}
+	claims, err := eh.authService.ValidateToken(tokenString)
+	if err != nil {
+		return nil
+	}
+	return claims
+}
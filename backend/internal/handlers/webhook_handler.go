@@ -5,24 +5,34 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"strings"
 	"time"
 
+	"back_wa/internal/logging"
 	"back_wa/internal/models"
+	"back_wa/internal/repository"
 	"back_wa/internal/services"
 )
 
 type WebhookHandler struct {
-	paymentService *services.PaymentService
+	paymentService  *services.PaymentService
+	tenantService   *services.TenantService
+	midtransService *services.MidtransService
+	webhookEvents   *services.WebhookEventService
 }
 
-func NewWebhookHandler(paymentService *services.PaymentService) *WebhookHandler {
+func NewWebhookHandler(repos *repository.Repositories, paymentService *services.PaymentService) *WebhookHandler {
 	return &WebhookHandler{
-		paymentService: paymentService,
+		paymentService:  paymentService,
+		tenantService:   services.NewTenantService(repos.Tenants),
+		midtransService: services.NewMidtransService(),
+		webhookEvents:   services.NewWebhookEventService(repos.WebhookEvents),
 	}
 }
 
@@ -40,6 +50,13 @@ func (wh *WebhookHandler) HandleXenditWebhook(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	// Parse webhook payload; the transaction it names decides which token must have signed it
+	var payload models.WebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, "Invalid webhook payload", http.StatusBadRequest)
+		return
+	}
+
 	// Verify webhook signature
 	// Try multiple known headers from Xendit variants
 	signature := r.Header.Get("X-Xendit-Signature")
@@ -51,45 +68,100 @@ func (wh *WebhookHandler) HandleXenditWebhook(w http.ResponseWriter, r *http.Req
 	}
 	legacyToken := r.Header.Get("X-Callback-Token")
 
-	if !wh.verifyWebhookSignature(body, signature, legacyToken) {
-		fmt.Printf("❌ Invalid webhook signature. Headers: X-Xendit-Signature='%s' X-Callback-Signature='%s' X-Xendit-Callback-Signature='%s' X-Callback-Token='%s'\n",
-			r.Header.Get("X-Xendit-Signature"), r.Header.Get("X-Callback-Signature"), r.Header.Get("X-Xendit-Callback-Signature"), legacyToken)
+	if !wh.verifyTransactionWebhook(payload.ExternalID, body, signature, legacyToken) {
+		logging.FromContext(r.Context()).Error(fmt.Sprintf("Invalid webhook signature for %s. Headers: X-Xendit-Signature='%s' X-Callback-Signature='%s' X-Xendit-Callback-Signature='%s' X-Callback-Token='%s'", payload.ExternalID, r.Header.Get("X-Xendit-Signature"), r.Header.Get("X-Callback-Signature"), r.Header.Get("X-Xendit-Callback-Signature"), legacyToken), "external_id", payload.ExternalID)
 
 		// Optionally bypass verification in sandbox if explicitly allowed
 		if strings.EqualFold(os.Getenv("XENDIT_WEBHOOK_DISABLE_VERIFY"), "true") {
-			fmt.Println("⚠️ Bypassing webhook verification due to XENDIT_WEBHOOK_DISABLE_VERIFY=true (sandbox only)")
+			logging.FromContext(r.Context()).Warn("Bypassing webhook verification due to XENDIT_WEBHOOK_DISABLE_VERIFY=true (sandbox only)")
 		} else {
 			http.Error(w, "Invalid webhook signature", http.StatusUnauthorized)
 			return
 		}
 	}
 
-	// Parse webhook payload
-	var payload models.WebhookPayload
-	if err := json.Unmarshal(body, &payload); err != nil {
-		http.Error(w, "Invalid webhook payload", http.StatusBadRequest)
+	// Log webhook for debugging with key details
+	logging.FromContext(r.Context()).Debug(fmt.Sprintf("Xendit webhook: ext=%s status=%s channel=%s amount=%.2f id=%s", payload.ExternalID, payload.Status, payload.PaymentChannel, payload.Amount, payload.ID), "external_id", payload.ExternalID, "status", payload.Status)
+
+	// Xendit retries deliveries: record the event and apply it only once
+	webhookEvents := wh.webhookEvents.WithContext(r.Context())
+	event, process, err := webhookEvents.Begin(services.PaymentGatewayXendit, services.XenditEventID(payload), payload.ExternalID, payload.Status, body)
+	if err != nil {
+		logging.FromContext(r.Context()).Error(fmt.Sprintf("Failed to record webhook event for %s", payload.ExternalID), "error", err, "external_id", payload.ExternalID)
+		http.Error(w, "Failed to record webhook event", http.StatusInternalServerError)
+		return
+	}
+	if !process {
+		if event.State == models.WebhookEventReceived {
+			// Still being applied by another delivery; let Xendit retry in case that one fails
+			http.Error(w, "Webhook event is being processed", http.StatusConflict)
+			return
+		}
+		logging.FromContext(r.Context()).Info(fmt.Sprintf("Skipped redelivered webhook event %s for %s", event.EventID, payload.ExternalID), "external_id", payload.ExternalID, "webhook_event_id", event.ID)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Webhook already processed"))
 		return
 	}
-
-	// Log webhook for debugging with key details
-	fmt.Printf("📣 Xendit webhook: ext=%s status=%s channel=%s amount=%.2f id=%s\n",
-		payload.ExternalID, payload.Status, payload.PaymentChannel, payload.Amount, payload.ID)
 
 	// Update transaction status
-	err = wh.paymentService.UpdateTransactionStatus(
-		payload.ExternalID,
-		payload.Status,
-		payload.PaymentChannel,
-	)
+	err = webhookEvents.ApplyXendit(event, wh.paymentService)
 	if err != nil {
-		fmt.Printf("Failed to update transaction %s: %v\n", payload.ExternalID, err)
+		logging.FromContext(r.Context()).Error(fmt.Sprintf("Failed to update transaction %s", payload.ExternalID), "error", err, "external_id", payload.ExternalID)
 		http.Error(w, fmt.Sprintf("Failed to update transaction: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	// Log successful update
-	fmt.Printf("✅ Updated transaction %s to status %s (channel=%s)\n",
-		payload.ExternalID, payload.Status, payload.PaymentChannel)
+	logging.FromContext(r.Context()).Info(fmt.Sprintf("Updated transaction %s to status %s (channel=%s)", payload.ExternalID, payload.Status, payload.PaymentChannel), "external_id", payload.ExternalID, "status", payload.Status)
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Webhook processed successfully"))
+}
+
+// HandleMidtransWebhook handles POST /api/webhooks/midtrans (Midtrans HTTP notification)
+func (wh *WebhookHandler) HandleMidtransWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var notification models.MidtransNotification
+	if err := json.NewDecoder(r.Body).Decode(&notification); err != nil {
+		http.Error(w, "Invalid webhook payload", http.StatusBadRequest)
+		return
+	}
+
+	// signature_key binds order, status and amount to our server key
+	if !wh.midtransService.VerifyNotification(notification) {
+		logging.FromContext(r.Context()).Error(fmt.Sprintf("Invalid Midtrans signature for order %s", notification.OrderID), "external_id", notification.OrderID)
+		http.Error(w, "Invalid webhook signature", http.StatusUnauthorized)
+		return
+	}
+
+	status := services.MidtransStatus(notification.TransactionStatus, notification.FraudStatus)
+	logging.FromContext(r.Context()).Debug(fmt.Sprintf("Midtrans webhook: order=%s status=%s fraud=%s type=%s amount=%s -> %s", notification.OrderID, notification.TransactionStatus, notification.FraudStatus, notification.PaymentType, notification.GrossAmount, status), "external_id", notification.OrderID, "status", status)
+
+	transaction, err := wh.paymentService.GetTransactionByExternalID(notification.OrderID)
+	if err != nil {
+		// Test notifications from the Midtrans dashboard use made-up order IDs; acknowledge so they are not retried
+		logging.FromContext(r.Context()).Warn(fmt.Sprintf("Midtrans webhook for unknown order %s", notification.OrderID), "error", err, "external_id", notification.OrderID)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Unknown order ignored"))
+		return
+	}
+	if transaction.Gateway != services.PaymentGatewayMidtrans {
+		logging.FromContext(r.Context()).Warn(fmt.Sprintf("Midtrans webhook for order %s issued by %s ignored", notification.OrderID, transaction.Gateway), "external_id", notification.OrderID)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Order not issued by Midtrans"))
+		return
+	}
+
+	if err := wh.paymentService.UpdateTransactionStatus(notification.OrderID, status, notification.PaymentType); err != nil {
+		logging.FromContext(r.Context()).Error(fmt.Sprintf("Failed to update transaction %s", notification.OrderID), "error", err, "external_id", notification.OrderID)
+		http.Error(w, fmt.Sprintf("Failed to update transaction: %v", err), http.StatusInternalServerError)
+		return
+	}
+	logging.FromContext(r.Context()).Info(fmt.Sprintf("Updated transaction %s to status %s (channel=%s)", notification.OrderID, status, notification.PaymentType), "external_id", notification.OrderID, "status", status)
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Webhook processed successfully"))
@@ -105,6 +177,44 @@ func (wh *WebhookHandler) verifyWebhookSignature(payload []byte, signature strin
 		return true
 	}
 
+	return matchWebhookToken(webhookToken, payload, signature, legacyToken)
+}
+
+// verifyTransactionWebhook verifies a webhook about externalID against the token of the account that
+// issued it: the tenant's own token for white-label transactions, the global token otherwise. A
+// tenant's token never authorizes webhooks about another tenant's or default-brand transactions.
+func (wh *WebhookHandler) verifyTransactionWebhook(externalID string, payload []byte, signature string, legacyToken string) bool {
+	transaction, err := wh.paymentService.GetTransactionByExternalID(externalID)
+	if errors.Is(err, services.ErrTransactionNotFound) {
+		// Nothing to update; only the global account can be signing it
+		return wh.verifyWebhookSignature(payload, signature, legacyToken)
+	}
+	if err != nil {
+		slog.Error(fmt.Sprintf("Failed to load transaction %s for webhook verification", externalID), "error", err, "external_id", externalID)
+		return false
+	}
+	if transaction.TenantID == nil {
+		return wh.verifyWebhookSignature(payload, signature, legacyToken)
+	}
+
+	tenant, err := wh.tenantService.GetByID(*transaction.TenantID)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Webhook for %s names inactive or unknown tenant %d", externalID, *transaction.TenantID), "error", err, "external_id", externalID)
+		return false
+	}
+	if tenant.XenditWebhookToken == "" {
+		// The tenant bills through the global Xendit account
+		return wh.verifyWebhookSignature(payload, signature, legacyToken)
+	}
+	if matchWebhookToken(tenant.XenditWebhookToken, payload, signature, legacyToken) {
+		slog.Debug(fmt.Sprintf("Webhook verified with tenant %s token", tenant.Slug))
+		return true
+	}
+	return false
+}
+
+// matchWebhookToken verifies a webhook against a single token (legacy header or HMAC signature)
+func matchWebhookToken(webhookToken string, payload []byte, signature string, legacyToken string) bool {
 	// Legacy token path
 	if legacyToken != "" && legacyToken == webhookToken {
 		return true
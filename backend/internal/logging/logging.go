@@ -0,0 +1,78 @@
+// Package logging configures the process-wide structured logger (log/slog) and carries
+// request-scoped loggers through contexts.
+package logging
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+type loggerKey struct{}
+
+// Setup installs the default slog logger writing to out.
+// LOG_FORMAT is json or text (default text in development, json otherwise) and LOG_LEVEL is
+// debug, info, warn or error (default debug in development, info otherwise).
+// The standard log package is routed through the same handler at info level.
+func Setup(out io.Writer) {
+	development := strings.EqualFold(os.Getenv("ENVIRONMENT"), "development")
+
+	level := slog.LevelInfo
+	if development {
+		level = slog.LevelDebug
+	}
+	if raw := strings.TrimSpace(os.Getenv("LOG_LEVEL")); raw != "" {
+		if err := level.UnmarshalText([]byte(raw)); err != nil {
+			level = slog.LevelInfo
+		}
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	format := strings.ToLower(strings.TrimSpace(os.Getenv("LOG_FORMAT")))
+	if format == "" && !development {
+		format = "json"
+	}
+	var handler slog.Handler
+	if format == "json" {
+		handler = slog.NewJSONHandler(out, options)
+	} else {
+		handler = slog.NewTextHandler(out, options)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	log.SetFlags(0)
+	log.SetOutput(stdlogWriter{})
+}
+
+// WithLogger returns a copy of ctx carrying logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger bound to ctx (with the request ID and whatever fields were
+// added along the way), or the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+			return logger
+		}
+	}
+	return slog.Default()
+}
+
+// With returns a copy of ctx whose logger carries the extra fields, e.g. With(ctx, "user_id", id)
+func With(ctx context.Context, args ...any) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(args...))
+}
+
+// stdlogWriter turns lines written through the standard log package (libraries such as the
+// GORM SQL logger) into info records
+type stdlogWriter struct{}
+
+func (stdlogWriter) Write(p []byte) (int, error) {
+	slog.Default().Log(context.Background(), slog.LevelInfo, strings.TrimRight(string(p), "\n"))
+	return len(p), nil
+}
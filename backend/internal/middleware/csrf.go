@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"back_wa/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+// CookieSession lets handlers keep reading "Authorization: Bearer" when AUTH_MODE=cookie:
+// the JWT from the session cookie is copied into the header. Requests authenticated by
+// cookie must send the CSRF cookie value in X-CSRF-Token on state-changing methods.
+// A request that already carries an Authorization header is left untouched.
+func CookieSession(cfg *services.SessionCookieConfig) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !cfg.CookieMode() || r.Header.Get("Authorization") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			session, err := r.Cookie(cfg.CookieName)
+			if err != nil || session.Value == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if isStateChanging(r.Method) && !validCSRF(r, cfg) {
+				http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+				return
+			}
+
+			r.Header.Set("Authorization", "Bearer "+session.Value)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func isStateChanging(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// validCSRF implements the double-submit check: header value must equal the CSRF cookie
+func validCSRF(r *http.Request, cfg *services.SessionCookieConfig) bool {
+	csrfCookie, err := r.Cookie(cfg.CSRFCookieName)
+	if err != nil || csrfCookie.Value == "" {
+		return false
+	}
+	header := r.Header.Get(services.CSRFHeaderName)
+	return header != "" && subtle.ConstantTimeCompare([]byte(header), []byte(csrfCookie.Value)) == 1
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+// DataAccessRule protects a route that exposes one user's data. Route is the path template as
+// registered with the router; UserParam names the route variable or query parameter holding the
+// user ID. Requests without a user ID (e.g. unfiltered listings) are not affected.
+type DataAccessRule struct {
+	Route     string
+	UserParam string
+}
+
+// RequireDataAccess only serves the protected routes to admins holding an active data access
+// request for the user (403, error_type data_access_request_required otherwise) and records
+// every served request in the data access audit log. It must run after RequireRole.
+func RequireDataAccess(dataAccess *services.DataAccessService, rules ...DataAccessRule) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := mux.CurrentRoute(r)
+			if route == nil || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+			template, _ := route.GetPathTemplate()
+
+			param := ""
+			for _, rule := range rules {
+				if rule.Route == template {
+					param = rule.UserParam
+					break
+				}
+			}
+			if param == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			raw := mux.Vars(r)[param]
+			if raw == "" {
+				raw = r.URL.Query().Get(param)
+			}
+			if raw == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			userID, err := strconv.ParseUint(raw, 10, 32)
+			if err != nil {
+				next.ServeHTTP(w, r) // the handler rejects the malformed ID
+				return
+			}
+
+			// The rules cover admin routes, whose token RequireRole has already validated
+			claims := ClaimsFromContext(r.Context())
+			if claims == nil {
+				writeRoleError(w, http.StatusUnauthorized, "unauthorized", "Authorization header required")
+				return
+			}
+
+			request, err := dataAccess.Active(claims.UserID, uint(userID))
+			if errors.Is(err, services.ErrDataAccessRequired) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"success":    false,
+					"error":      "Create a data access request (reason, duration) before viewing this user's data",
+					"error_type": "data_access_request_required",
+					"user_id":    userID,
+				})
+				return
+			}
+			if err != nil {
+				logging.FromContext(r.Context()).Error(fmt.Sprintf("Failed to check data access for admin %d on user %d", claims.UserID, userID), "error", err, "admin_id", claims.UserID, "user_id", userID)
+				http.Error(w, "Failed to check data access", http.StatusInternalServerError)
+				return
+			}
+
+			// No audit entry, no access
+			if err := dataAccess.RecordAccess(request, r.Method, r.URL.RequestURI()); err != nil {
+				logging.FromContext(r.Context()).Error(fmt.Sprintf("Failed to record data access for admin %d on user %d", claims.UserID, userID), "error", err, "admin_id", claims.UserID, "user_id", userID)
+				http.Error(w, "Failed to record data access", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("X-Data-Access-Request", strconv.FormatUint(uint64(request.ID), 10))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
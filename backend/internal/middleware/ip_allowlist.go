@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"back_wa/internal/logging"
+
+	"github.com/gorilla/mux"
+)
+
+// IPAllowList restricts a path prefix to a set of CIDR ranges.
+// An empty list allows every address, so the feature is opt-in per prefix.
+type IPAllowList struct {
+	prefix         string
+	networks       []*net.IPNet
+	trustedProxies []*net.IPNet
+}
+
+// NewIPAllowList builds an allow-list for prefix from a comma separated env var of CIDRs or bare IPs.
+// X-Forwarded-For is only honoured when the direct peer is in TRUSTED_PROXY_CIDRS.
+func NewIPAllowList(prefix, envVar string) (*IPAllowList, error) {
+	networks, err := parseCIDRList(os.Getenv(envVar))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", envVar, err)
+	}
+	proxies, err := parseCIDRList(os.Getenv("TRUSTED_PROXY_CIDRS"))
+	if err != nil {
+		return nil, fmt.Errorf("TRUSTED_PROXY_CIDRS: %v", err)
+	}
+	return &IPAllowList{prefix: prefix, networks: networks, trustedProxies: proxies}, nil
+}
+
+// Enabled reports whether any range is configured
+func (al *IPAllowList) Enabled() bool {
+	return len(al.networks) > 0
+}
+
+// Middleware rejects requests under the prefix whose client IP is outside the allow-list with 403
+func (al *IPAllowList) Middleware() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !al.Enabled() || !strings.HasPrefix(r.URL.Path, al.prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ip := al.clientIP(r)
+			if ip != nil && containsIP(al.networks, ip) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			logging.FromContext(r.Context()).Warn(fmt.Sprintf("Blocked %s %s from %s (remote %s): not in %s* allow-list", r.Method, r.URL.Path, ip, r.RemoteAddr, al.prefix))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success":    false,
+				"error":      "Forbidden",
+				"error_type": "ip_not_allowed",
+				"message":    fmt.Sprintf("Source IP %s is not allowed to access this endpoint", ip),
+			})
+		})
+	}
+}
+
+// clientIP returns the peer address, or the first untrusted X-Forwarded-For hop behind a trusted proxy
+func (al *IPAllowList) clientIP(r *http.Request) net.IP {
+	return clientIPBehind(r, al.trustedProxies)
+}
+
+// defaultTrustedProxies are the TRUSTED_PROXY_CIDRS ranges, parsed once; invalid entries were reported at startup
+var defaultTrustedProxies = sync.OnceValue(func() []*net.IPNet {
+	proxies, _ := parseCIDRList(os.Getenv("TRUSTED_PROXY_CIDRS"))
+	return proxies
+})
+
+// ClientIP returns the client address of r behind the TRUSTED_PROXY_CIDRS proxies, empty when unknown
+func ClientIP(r *http.Request) string {
+	if ip := clientIPBehind(r, defaultTrustedProxies()); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+// clientIPBehind resolves the client address of r given the trusted proxy ranges
+func clientIPBehind(r *http.Request, trustedProxies []*net.IPNet) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !containsIP(trustedProxies, ip) {
+		return ip
+	}
+
+	// Walk X-Forwarded-For right to left, skipping our own proxies
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		ip = hop
+		if !containsIP(trustedProxies, hop) {
+			break
+		}
+	}
+	return ip
+}
+
+func parseCIDRList(raw string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP %q", entry)
+			}
+			if ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", entry)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
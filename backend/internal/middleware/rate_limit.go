@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+// rateLimitSweepInterval is how often idle buckets are dropped
+const rateLimitSweepInterval = 5 * time.Minute
+
+// RateLimitRule throttles the requests it matches with one token bucket per key
+type RateLimitRule struct {
+	Name      string
+	PerMinute int // <= 0 disables the rule
+	Burst     int
+	// ByUser keys buckets by the bearer token's user ID (by client IP without a valid token)
+	ByUser bool
+	Match  func(r *http.Request) bool
+}
+
+// LoadRateLimitRules returns the built-in rules with RATE_LIMIT_<NAME>_PER_MINUTE / _BURST overrides:
+// auth (login, OTP, registration, password reset, phone check) per client IP, and /api/wa/* per user
+func LoadRateLimitRules() []RateLimitRule {
+	rules := []RateLimitRule{
+		{
+			Name: "auth", PerMinute: 10, Burst: 5,
+			Match: func(r *http.Request) bool {
+				return strings.HasPrefix(r.URL.Path, "/api/auth/") &&
+					(r.Method != http.MethodGet || r.URL.Path == "/api/auth/check-phone")
+			},
+		},
+		{
+			Name: "wa", PerMinute: 120, Burst: 30, ByUser: true,
+			Match: func(r *http.Request) bool {
+				return strings.HasPrefix(r.URL.Path, "/api/wa/")
+			},
+		},
+	}
+	for i := range rules {
+		prefix := "RATE_LIMIT_" + strings.ToUpper(rules[i].Name)
+		if v, err := strconv.Atoi(os.Getenv(prefix + "_PER_MINUTE")); err == nil {
+			rules[i].PerMinute = v
+		}
+		if v, err := strconv.Atoi(os.Getenv(prefix + "_BURST")); err == nil && v > 0 {
+			rules[i].Burst = v
+		}
+	}
+	return rules
+}
+
+// RateLimiter enforces RateLimitRules in memory; each instance counts on its own
+type RateLimiter struct {
+	rules          []RateLimitRule
+	authService    *services.AuthService
+	trustedProxies []*net.IPNet
+	sessionCookie  *services.SessionCookieConfig
+
+	mu        sync.Mutex
+	buckets   map[string]time.Time // rule:key -> virtual time of the next free token
+	lastSweep time.Time
+}
+
+// NewRateLimiter creates a limiter for rules. X-Forwarded-For is only honoured behind TRUSTED_PROXY_CIDRS.
+// With AUTH_MODE=cookie, per-user rules also read the token from the session cookie.
+func NewRateLimiter(authService *services.AuthService, rules []RateLimitRule) (*RateLimiter, error) {
+	proxies, err := parseCIDRList(os.Getenv("TRUSTED_PROXY_CIDRS"))
+	if err != nil {
+		return nil, fmt.Errorf("TRUSTED_PROXY_CIDRS: %v", err)
+	}
+	return &RateLimiter{
+		rules:          rules,
+		authService:    authService,
+		trustedProxies: proxies,
+		sessionCookie:  services.LoadSessionCookieConfig(),
+		buckets:        make(map[string]time.Time),
+		lastSweep:      time.Now(),
+	}, nil
+}
+
+// Middleware answers 429 with Retry-After once a key used up its bucket
+func (rl *RateLimiter) Middleware() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, rule := range rl.rules {
+				if rule.PerMinute <= 0 || !rule.Match(r) {
+					continue
+				}
+				key := rl.key(r, rule)
+				if wait := rl.take(rule, key); wait > 0 {
+					retryAfter := int(math.Ceil(wait.Seconds()))
+					logging.FromContext(r.Context()).Warn(fmt.Sprintf("Rate limited %s %s (%s %s), retry in %ds", r.Method, r.URL.Path, rule.Name, key, retryAfter))
+					w.Header().Set("Content-Type", "application/json")
+					w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+					w.WriteHeader(http.StatusTooManyRequests)
+					json.NewEncoder(w).Encode(map[string]interface{}{
+						"success":             false,
+						"error":               "Too many requests, please try again later",
+						"error_type":          "rate_limited",
+						"retry_after_seconds": retryAfter,
+					})
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// key identifies the caller for rule: "user:<id>" or "ip:<addr>"
+func (rl *RateLimiter) key(r *http.Request, rule RateLimitRule) string {
+	if rule.ByUser {
+		if tokenString := rl.token(r); tokenString != "" {
+			if claims, err := rl.authService.ParseClaims(tokenString); err == nil {
+				return fmt.Sprintf("user:%d", claims.UserID)
+			}
+		}
+	}
+	return "ip:" + clientIPBehind(r, rl.trustedProxies).String()
+}
+
+// token returns the bearer token, or in cookie mode the session cookie, so the limiter keys
+// by user whether or not CookieSession ran before it
+func (rl *RateLimiter) token(r *http.Request) string {
+	authHeader := r.Header.Get("Authorization")
+	if tokenString := strings.TrimPrefix(authHeader, "Bearer "); authHeader != "" && tokenString != authHeader {
+		return tokenString
+	}
+	if rl.sessionCookie != nil && rl.sessionCookie.CookieMode() {
+		if session, err := r.Cookie(rl.sessionCookie.CookieName); err == nil {
+			return session.Value
+		}
+	}
+	return ""
+}
+
+// take spends a token from the key's bucket and returns how long to wait when none is left
+func (rl *RateLimiter) take(rule RateLimitRule, key string) time.Duration {
+	interval := time.Minute / time.Duration(rule.PerMinute)
+	now := time.Now()
+	bucket := rule.Name + ":" + key
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if now.Sub(rl.lastSweep) > rateLimitSweepInterval {
+		// Buckets whose next token lies in the past are full again and can be forgotten
+		for k, next := range rl.buckets {
+			if next.Before(now) {
+				delete(rl.buckets, k)
+			}
+		}
+		rl.lastSweep = now
+	}
+
+	// Unused tokens accumulate up to the burst size
+	next := rl.buckets[bucket]
+	if floor := now.Add(-time.Duration(rule.Burst-1) * interval); next.Before(floor) {
+		next = floor
+	}
+	if wait := next.Sub(now); wait > 0 {
+		return wait
+	}
+	rl.buckets[bucket] = next.Add(interval)
+	return 0
+}
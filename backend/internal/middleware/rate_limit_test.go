@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"back_wa/internal/services"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// limiterTestToken signs a session-less token for userID with the JWT_SECRET of the test
+func limiterTestToken(t *testing.T, userID uint) string {
+	t.Helper()
+	keys, err := services.JWTKeys()
+	if err != nil {
+		t.Fatalf("jwt keys: %v", err)
+	}
+	token, err := keys.Sign(services.JWTClaims{
+		UserID: userID,
+		Role:   "user",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return token
+}
+
+// waLimiter returns a handler behind the /api/wa/* rule with a bucket of one request
+func waLimiter(t *testing.T) http.Handler {
+	t.Helper()
+	t.Setenv("RATE_LIMIT_WA_PER_MINUTE", "1")
+	t.Setenv("RATE_LIMIT_WA_BURST", "1")
+	limiter, err := NewRateLimiter(services.NewAuthService(nil, nil), LoadRateLimitRules())
+	if err != nil {
+		t.Fatalf("rate limiter: %v", err)
+	}
+	return limiter.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+// waStatus sends GET /api/wa/status from one shared client address with the session cookie
+func waStatus(handler http.Handler, sessionToken string) int {
+	req := httptest.NewRequest(http.MethodGet, "/api/wa/status", nil)
+	req.RemoteAddr = "203.0.113.7:40000"
+	req.AddCookie(&http.Cookie{Name: "cekwa_session", Value: sessionToken})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestRateLimiterKeysCookieSessionsByUser(t *testing.T) {
+	t.Setenv("JWT_SECRET", "rate-limit-test-secret")
+	t.Setenv("AUTH_MODE", "cookie")
+	handler := waLimiter(t)
+	alice, bob := limiterTestToken(t, 1), limiterTestToken(t, 2)
+
+	if code := waStatus(handler, alice); code != http.StatusOK {
+		t.Fatalf("first request of user 1: %d", code)
+	}
+	if code := waStatus(handler, alice); code != http.StatusTooManyRequests {
+		t.Fatalf("second request of user 1: %d, want 429", code)
+	}
+	// Same address (one NAT), different account: its own bucket
+	if code := waStatus(handler, bob); code != http.StatusOK {
+		t.Fatalf("first request of user 2 behind the same address: %d", code)
+	}
+}
+
+func TestRateLimiterIgnoresSessionCookieInHeaderMode(t *testing.T) {
+	t.Setenv("JWT_SECRET", "rate-limit-test-secret")
+	t.Setenv("AUTH_MODE", "header")
+	handler := waLimiter(t)
+
+	if code := waStatus(handler, limiterTestToken(t, 1)); code != http.StatusOK {
+		t.Fatalf("first request: %d", code)
+	}
+	// Cookies are not credentials in header mode, so both callers share the address bucket
+	if code := waStatus(handler, limiterTestToken(t, 2)); code != http.StatusTooManyRequests {
+		t.Fatalf("second request from the same address: %d, want 429", code)
+	}
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"back_wa/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+type claimsKey struct{}
+
+// RequireRole only lets full account tokens whose role claim equals role reach paths starting
+// with prefix. Missing or invalid tokens get 401, other roles 403 (error_type forbidden).
+// Handlers behind it read the validated claims with ClaimsFromContext.
+func RequireRole(authService *services.AuthService, prefix, role string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasPrefix(r.URL.Path, prefix) || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			authHeader := r.Header.Get("Authorization")
+			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+			if authHeader == "" || tokenString == authHeader {
+				writeRoleError(w, http.StatusUnauthorized, "unauthorized", "Authorization header required")
+				return
+			}
+			claims, err := authService.ValidateToken(tokenString)
+			if err != nil {
+				writeRoleError(w, http.StatusUnauthorized, "unauthorized", "Invalid token")
+				return
+			}
+			if claims.Role != role {
+				writeRoleError(w, http.StatusForbidden, "forbidden", "This endpoint requires the "+role+" role")
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsKey{}, claims)))
+		})
+	}
+}
+
+// ClaimsFromContext returns the claims RequireRole validated for the request, or nil on paths
+// it does not guard
+func ClaimsFromContext(ctx context.Context) *services.JWTClaims {
+	claims, _ := ctx.Value(claimsKey{}).(*services.JWTClaims)
+	return claims
+}
+
+func writeRoleError(w http.ResponseWriter, status int, errorType, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    false,
+		"error":      message,
+		"error_type": errorType,
+	})
+}
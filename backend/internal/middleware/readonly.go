@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+
+	"back_wa/internal/database"
+
+	"github.com/gorilla/mux"
+)
+
+// ReadOnlyWhenDegraded rejects state-changing requests with 503 + Retry-After while the
+// database is unavailable. Reads still go through so cached analyses and session status
+// keep working from memory.
+func ReadOnlyWhenDegraded() mux.MiddlewareFunc {
+	retryAfter := 30
+	if v, err := strconv.Atoi(os.Getenv("DB_DEGRADED_RETRY_AFTER_SECONDS")); err == nil && v > 0 {
+		retryAfter = v
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !database.IsDegraded() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success":     false,
+				"error":       "Service is temporarily read-only, please retry later",
+				"error_type":  "read_only",
+				"retry_after": retryAfter,
+			})
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"back_wa/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+// ScopeRule requires Scope for every path starting with Prefix
+type ScopeRule struct {
+	Prefix string
+	Scope  string
+}
+
+// RequireScopes rejects bearer tokens that lack the scope of the route group with 403
+// (error_type insufficient_scope). Missing or invalid tokens are left to the handlers,
+// which answer 401 as before.
+func RequireScopes(authService *services.AuthService, rules ...ScopeRule) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			required := ""
+			for _, rule := range rules {
+				if strings.HasPrefix(r.URL.Path, rule.Prefix) {
+					required = rule.Scope
+					break
+				}
+			}
+
+			authHeader := r.Header.Get("Authorization")
+			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+			if required == "" || authHeader == "" || tokenString == authHeader {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			claims, err := authService.ParseClaims(tokenString)
+			if err != nil || claims.HasScope(required) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success":        false,
+				"error":          "Token does not grant access to this endpoint",
+				"error_type":     "insufficient_scope",
+				"required_scope": required,
+			})
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"back_wa/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+// RejectRevokedSessions answers 401 (error_type session_revoked) for bearer tokens whose
+// session was revoked, so clients can tell a sign-out from elsewhere apart from a bad token.
+// Missing, invalid or expired tokens are left to the handlers, and logout always goes through
+// so cookie sessions can be cleared.
+func RejectRevokedSessions(authService *services.AuthService) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeader := r.Header.Get("Authorization")
+			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+			if authHeader == "" || tokenString == authHeader || r.URL.Path == "/api/auth/logout" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if _, err := authService.ParseClaims(tokenString); !errors.Is(err, services.ErrSessionRevoked) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success":    false,
+				"error":      "Session has been revoked, please log in again",
+				"error_type": "session_revoked",
+			})
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"back_wa/internal/database"
+	"back_wa/internal/models"
+	"back_wa/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+type tenantKey struct{}
+
+// TenantScope resolves the tenant for every request (X-API-Key, then Host) and binds
+// it to the request context so that database queries made with r.Context() only
+// see rows belonging to that tenant. Requests without a tenant are scoped to the
+// default brand.
+func TenantScope(tenantService *services.TenantService) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant := tenantService.ResolveRequest(r)
+
+			var tenantID *uint
+			if tenant != nil {
+				id := tenant.ID
+				tenantID = &id
+			}
+
+			ctx := database.WithTenantScope(r.Context(), tenantID)
+			ctx = context.WithValue(ctx, tenantKey{}, tenant)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// TenantFromContext returns the tenant resolved by TenantScope, or nil for the default brand
+func TenantFromContext(ctx context.Context) *models.Tenant {
+	tenant, _ := ctx.Value(tenantKey{}).(*models.Tenant)
+	return tenant
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/models"
+	"back_wa/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+// PartnerUsage meters every request authenticated with a partner X-API-Key.
+// Must run after TenantScope. Requests to the usage endpoints themselves are not billed.
+func PartnerUsage(usageService *services.UsageService) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			apiKey := strings.TrimSpace(r.Header.Get("X-API-Key"))
+			tenant := TenantFromContext(r.Context())
+			if apiKey != "" && tenant != nil && tenant.APIKeyHash == services.HashAPIKey(apiKey) &&
+				!strings.HasPrefix(r.URL.Path, "/api/partner/") {
+				if err := usageService.Record(tenant.ID, models.UsageMetricAPIRequest); err != nil {
+					logging.FromContext(r.Context()).Warn(fmt.Sprintf("Failed to meter API request for tenant %d", tenant.ID), "error", err)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// UserUsage meters every /api/ request made with a valid bearer token for the account's own
+// usage page. Invalid tokens are left to the handlers; polling the usage page is not counted.
+func UserUsage(authService *services.AuthService, usageService *services.UsageService) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeader := r.Header.Get("Authorization")
+			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+			if authHeader != "" && tokenString != authHeader &&
+				strings.HasPrefix(r.URL.Path, "/api/") && r.URL.Path != "/api/user/usage" {
+				if claims, err := authService.ParseClaims(tokenString); err == nil {
+					if err := usageService.RecordUser(claims.UserID, models.UsageMetricAPIRequest); err != nil {
+						logging.FromContext(r.Context()).Warn(fmt.Sprintf("Failed to meter API request for user %d", claims.UserID), "error", err, "user_id", claims.UserID)
+					}
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
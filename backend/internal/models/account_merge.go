@@ -0,0 +1,53 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Account merge states
+const (
+	AccountMergeMerged     = "merged"
+	AccountMergeRolledBack = "rolled_back"
+)
+
+// AccountMerge is the audit record of an admin merging a duplicate account (source) into the
+// surviving one (target). MovedRecords lists the re-owned row ids per table so the merge can be
+// rolled back exactly. It is also the record of the ownership change of the analyses, whose
+// checksum stays sealed for the owner they were created for (AnalysisResult.SealedUserID).
+type AccountMerge struct {
+	ID              uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	SourceUserID    uint       `json:"source_user_id" gorm:"not null;index"`
+	TargetUserID    uint       `json:"target_user_id" gorm:"not null;index"`
+	AdminID         uint       `json:"admin_id" gorm:"not null"`
+	Reason          string     `json:"reason" gorm:"size:500;not null"`
+	Status          string     `json:"status" gorm:"type:varchar(20);not null;default:'merged'"`
+	SourceWasActive bool       `json:"source_was_active"`
+	MovedRecords    string     `json:"-" gorm:"type:text"`
+	RolledBackBy    *uint      `json:"rolled_back_by,omitempty" gorm:"default:null"`
+	RolledBackAt    *time.Time `json:"rolled_back_at,omitempty" gorm:"default:null"`
+	CreatedAt       time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for AccountMerge
+func (AccountMerge) TableName() string {
+	return "account_merges"
+}
+
+// Moved decodes MovedRecords (table name → row ids)
+func (m *AccountMerge) Moved() map[string][]uint {
+	moved := map[string][]uint{}
+	if m.MovedRecords != "" {
+		_ = json.Unmarshal([]byte(m.MovedRecords), &moved)
+	}
+	return moved
+}
+
+// MovedCounts returns the number of re-owned rows per table
+func (m *AccountMerge) MovedCounts() map[string]int {
+	counts := map[string]int{}
+	for table, ids := range m.Moved() {
+		counts[table] = len(ids)
+	}
+	return counts
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"fmt"
+	"log/slog"
 	"time"
 
 	"gorm.io/gorm"
@@ -10,6 +11,7 @@ import (
 type AnalysisResult struct {
 	ID                    uint           `json:"id" gorm:"primaryKey;autoIncrement"`
 	UserID                uint           `json:"user_id" gorm:"not null"`
+	TenantID              *uint          `json:"tenant_id" gorm:"index"`
 	ScanHistoryID         *uint          `json:"scan_history_id" gorm:"index"`
 	TotalChats            int            `json:"totalChats"`
 	TotalContacts         int            `json:"totalContacts"`
@@ -21,13 +23,61 @@ type AnalysisResult struct {
 	UnknownNumberChats    int            `json:"unknownNumberChats"`
 	Strength              string         `json:"strength"`
 	Summary               string         `json:"summary"`
+	Checksum              string         `json:"checksum" gorm:"size:64;index"` // SHA-256 of the canonical payload, set on create
+	DurationMs            int64          `json:"duration_ms"`                   // wall time of the whole analysis
+	ContactFetchMs        int64          `json:"contact_fetch_ms"`              // stage timings, see services.AnalysisStageTimer
+	GroupFetchMs          int64          `json:"group_fetch_ms"`
+	ScoringMs             int64          `json:"scoring_ms"`
+	PersistMs             int64          `json:"persist_ms"`
+	WhatsAppCalls         int            `json:"whatsapp_calls" gorm:"column:whatsapp_calls"` // serving cost: whatsmeow calls and database writes made
+	DBWrites              int            `json:"db_writes"`
+	DataQuality           *DataQuality   `json:"data_quality,omitempty" gorm:"type:text;serializer:json"` // sources used, nil for results from before it was recorded
+	Confidence            int            `json:"confidence"`                                              // 0-100 overall, 0 for results from before it was recorded
+	ScoringVersion        *uint          `json:"scoring_version" gorm:"default:null"`                     // scoring_configs id used, 0 = built-in defaults
+	Pinned                bool           `json:"pinned" gorm:"default:false;index"`                       // kept by bulk deletes unless include_pinned=true
+	Label                 string         `json:"label" gorm:"size:100;default:null"`                      // free text set by the user, e.g. "before cleanup"
 	ScanDate              time.Time      `json:"scan_date" gorm:"autoCreateTime"`
 	CreatedAt             time.Time      `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt             time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
 	DeletedAt             gorm.DeletedAt `json:"-" gorm:"index"`
+	DeletionToken         *string        `json:"-" gorm:"size:64;index;default:null"` // bulk delete the soft-deleted row can be undone with
+	SealedUserID          *uint          `json:"-" gorm:"default:null"`               // owner the checksum was sealed for, set while an account merge has re-owned the row
 
-	// Relationship
-	User        User        `json:"user" gorm:"foreignKey:UserID"`
+	LegalHold `gorm:"embedded"`
+
+	// Confidence of each parameter value, keyed by scoring parameter (see ScoringConfig)
+	ParameterConfidence ParameterConfidences `json:"parameter_confidence,omitempty" gorm:"type:text;serializer:json"`
+
+	// Per-parameter breakdown behind Summary (key, label, value, status, score, confidence) for
+	// charts; nil for results from before it was stored
+	Parameters []ParameterEvaluation `json:"parameters,omitempty" gorm:"type:text;serializer:json"`
+
+	// Messages per sensitive content category, nil when messages were not scanned (no consent)
+	SensitiveCategories SensitiveCounts `json:"sensitive_categories,omitempty" gorm:"type:text;serializer:json"`
+
+	// Computed values that contradicted each other, corrected or flagged before scoring
+	Anomalies []InputAnomaly `json:"anomalies,omitempty" gorm:"type:text;serializer:json"`
+
+	// Per-group / per-contact breakdowns, written with the result in one transaction
+	GroupBreakdown   []AnalysisGroup   `json:"-" gorm:"-"`
+	ContactBreakdown []AnalysisContact `json:"-" gorm:"-"`
+
+	// Set when the analysis ran automatically after contact sync (not persisted, see ScanHistory.Trigger)
+	AutoTriggered bool `json:"auto_triggered,omitempty" gorm:"-"`
+
+	// Set when the analysis ran from the user's scan schedule (not persisted, see ScanHistory.Trigger)
+	Scheduled bool `json:"scheduled,omitempty" gorm:"-"`
+
+	// Set when an identical scan of the same number was stored moments before and that result is
+	// returned instead of a new one (not persisted, see services.AnalysisDedupWindow)
+	Deduplicated bool `json:"deduplicated,omitempty" gorm:"-"`
+
+	// Set when the frontend should ask for feedback on this result (not persisted, see AnalysisFeedback)
+	FeedbackPrompt bool `json:"feedback_prompt" gorm:"-"`
+
+	// Relationship. The user is never serialized with the result: responses go through
+	// AnalysisResponse, and admin endpoints add the user explicitly (AdminAnalysisResponse).
+	User        User        `json:"-" gorm:"foreignKey:UserID"`
 	ScanHistory ScanHistory `json:"scan_history" gorm:"foreignKey:ScanHistoryID"`
 }
 
@@ -38,195 +88,92 @@ func (AnalysisResult) TableName() string {
 
 // ParameterEvaluation represents the evaluation result for each parameter
 type ParameterEvaluation struct {
-	Parameter string
-	Value     int
-	Status    string // "Baik", "Cukup", "Buruk"
-	Score     int    // 3 for Baik, 2 for Cukup, 1 for Buruk
+	Key        string `json:"key"`
+	Parameter  string `json:"parameter"`
+	Value      int    `json:"value"`
+	Status     string `json:"status"`     // "Baik", "Cukup", "Buruk"
+	Score      int    `json:"score"`      // 3 for Baik, 2 for Cukup, 1 for Buruk
+	Confidence int    `json:"confidence"` // 0-100, see ParameterConfidence
+	Estimated  bool   `json:"estimated"`
 }
 
+// CalculateStrength rates the account with the built-in indicator table
 func CalculateStrength(totalChats, totalContacts, accountAgeDays, totalGroups, totalChatWithContact, sensitiveContentCount, totalUnsavedChats, unknownNumberChats int) (string, string) {
-	fmt.Printf("DEBUG: Calculating strength with parameters:\n")
-	fmt.Printf("  Total Chats: %d\n", totalChats)
-	fmt.Printf("  Total Contacts: %d\n", totalContacts)
-	fmt.Printf("  Account Age: %d days\n", accountAgeDays)
-	fmt.Printf("  Total Groups: %d\n", totalGroups)
-	fmt.Printf("  Chat with Contact: %d\n", totalChatWithContact)
-	fmt.Printf("  Sensitive Content: %d\n", sensitiveContentCount)
-	fmt.Printf("  Total Unsaved Chats: %d\n", totalUnsavedChats)
-	fmt.Printf("  Unknown Number Chats: %d\n", unknownNumberChats)
+	return CalculateStrengthWith(DefaultScoringConfig(), nil, totalChats, totalContacts, accountAgeDays, totalGroups, totalChatWithContact, sensitiveContentCount, totalUnsavedChats, unknownNumberChats)
+}
+
+// CalculateStrengthWith rates the account with the given scoring configuration. confidences
+// (nil = all measured) mark estimated parameters in the evaluations and the summary.
+func CalculateStrengthWith(config *ScoringConfig, confidences ParameterConfidences, totalChats, totalContacts, accountAgeDays, totalGroups, totalChatWithContact, sensitiveContentCount, totalUnsavedChats, unknownNumberChats int) (string, string) {
+	strength, summary, _ := ScoreStrength(config, confidences, totalChats, totalContacts, accountAgeDays, totalGroups, totalChatWithContact, sensitiveContentCount, totalUnsavedChats, unknownNumberChats)
+	return strength, summary
+}
+
+// ScoreStrength is CalculateStrengthWith that also returns the per-parameter evaluations behind
+// the summary, in scoring configuration order (stored as AnalysisResult.Parameters)
+func ScoreStrength(config *ScoringConfig, confidences ParameterConfidences, totalChats, totalContacts, accountAgeDays, totalGroups, totalChatWithContact, sensitiveContentCount, totalUnsavedChats, unknownNumberChats int) (string, string, []ParameterEvaluation) {
+	slog.Debug("Calculating strength with parameters:")
+	slog.Debug(fmt.Sprintf("Total Chats: %d", totalChats))
+	slog.Debug(fmt.Sprintf("Total Contacts: %d", totalContacts))
+	slog.Debug(fmt.Sprintf("Account Age: %d days", accountAgeDays))
+	slog.Debug(fmt.Sprintf("Total Groups: %d", totalGroups))
+	slog.Debug(fmt.Sprintf("Chat with Contact: %d", totalChatWithContact))
+	slog.Debug(fmt.Sprintf("Sensitive Content: %d", sensitiveContentCount))
+	slog.Debug(fmt.Sprintf("Total Unsaved Chats: %d", totalUnsavedChats))
+	slog.Debug(fmt.Sprintf("Unknown Number Chats: %d", unknownNumberChats))
 
 	// Check if using default values
 	if totalChats == 150 && totalContacts == 250 && accountAgeDays == 400 {
-		fmt.Printf("DEBUG: Using default values for analysis\n")
+		slog.Debug("Using default values for analysis")
 	}
 
-	evaluations := []ParameterEvaluation{
-		evaluateTotalChats(totalChats),
-		evaluateTotalContacts(totalContacts),
-		evaluateAccountAge(accountAgeDays),
-		evaluateTotalGroups(totalGroups),
-		evaluateChatWithContacts(totalChatWithContact),
-		evaluateSensitiveContent(sensitiveContentCount),
-		evaluateUnsavedChats(totalUnsavedChats),
-		evaluateUnknownChats(unknownNumberChats),
+	values := map[string]int{
+		ScoreTotalChats:           totalChats,
+		ScoreTotalContacts:        totalContacts,
+		ScoreAccountAgeDays:       accountAgeDays,
+		ScoreTotalGroups:          totalGroups,
+		ScoreTotalChatWithContact: totalChatWithContact,
+		ScoreSensitiveContent:     sensitiveContentCount,
+		ScoreTotalUnsavedChats:    totalUnsavedChats,
+		ScoreUnknownNumberChats:   unknownNumberChats,
 	}
 
-	// Calculate total score
-	totalScore := 0
-	fmt.Printf("\nDEBUG: Parameter evaluations:\n")
-	for _, eval := range evaluations {
-		totalScore += eval.Score
-		fmt.Printf("  %s: %d (%s) - Score: %d\n", eval.Parameter, eval.Value, eval.Status, eval.Score)
+	// Weighted average of the parameter scores (3 = Baik, 2 = Cukup, 1 = Buruk)
+	evaluations := make([]ParameterEvaluation, 0, len(config.Parameters))
+	var weightedScore, totalWeight float64
+	slog.Debug("Parameter evaluations:")
+	for _, param := range config.Parameters {
+		eval := param.Evaluate(values[param.Key])
+		confidence := confidences.Of(param.Key)
+		eval.Confidence, eval.Estimated = confidence.Confidence, confidence.Estimated
+		evaluations = append(evaluations, eval)
+		weightedScore += float64(eval.Score) * param.Weight
+		totalWeight += param.Weight
+		slog.Debug(fmt.Sprintf("%s: %d (%s) - Score: %d, Confidence: %d%%", eval.Parameter, eval.Value, eval.Status, eval.Score, eval.Confidence))
 	}
 
-	// Calculate average score (max possible: 24, min possible: 8)
-	averageScore := float64(totalScore) / 8.0
-	fmt.Printf("\nDEBUG: Total Score: %d, Average Score: %.2f\n", totalScore, averageScore)
+	averageScore := 0.0
+	if totalWeight > 0 {
+		averageScore = weightedScore / totalWeight
+	}
+	slog.Debug(fmt.Sprintf("Weighted Score: %.2f, Average Score: %.2f", weightedScore, averageScore))
 
 	// Determine overall strength
 	var strength string
-	if averageScore >= 2.5 {
+	if averageScore >= config.GoodAverage {
 		strength = "Baik"
-	} else if averageScore >= 1.5 {
+	} else if averageScore >= config.FairAverage {
 		strength = "Cukup"
 	} else {
 		strength = "Buruk"
 	}
 
-	fmt.Printf("DEBUG: Final Strength: %s\n", strength)
+	slog.Debug(fmt.Sprintf("Final Strength: %s", strength))
 
 	// Generate summary
 	summary := generateSummary(evaluations, strength, averageScore)
 
-	return strength, summary
-}
-
-func evaluateTotalChats(value int) ParameterEvaluation {
-	var status string
-	var score int
-	if value >= 100 {
-		status = "Baik"
-		score = 3
-	} else if value >= 40 {
-		status = "Cukup"
-		score = 2
-	} else {
-		status = "Buruk"
-		score = 1
-	}
-	return ParameterEvaluation{"Total Chats", value, status, score}
-}
-
-func evaluateTotalContacts(value int) ParameterEvaluation {
-	var status string
-	var score int
-	if value >= 200 {
-		status = "Baik"
-		score = 3
-	} else if value >= 100 {
-		status = "Cukup"
-		score = 2
-	} else {
-		status = "Buruk"
-		score = 1
-	}
-	return ParameterEvaluation{"Total Kontak", value, status, score}
-}
-
-func evaluateAccountAge(value int) ParameterEvaluation {
-	var status string
-	var score int
-	if value >= 365 {
-		status = "Baik"
-		score = 3
-	} else if value >= 90 {
-		status = "Cukup"
-		score = 2
-	} else {
-		status = "Buruk"
-		score = 1
-	}
-	return ParameterEvaluation{"Umur Akun", value, status, score}
-}
-
-func evaluateTotalGroups(value int) ParameterEvaluation {
-	var status string
-	var score int
-	if value >= 80 {
-		status = "Baik"
-		score = 3
-	} else if value >= 30 {
-		status = "Cukup"
-		score = 2
-	} else {
-		status = "Buruk"
-		score = 1
-	}
-	return ParameterEvaluation{"Total Grup", value, status, score}
-}
-
-func evaluateChatWithContacts(value int) ParameterEvaluation {
-	var status string
-	var score int
-	if value >= 100 {
-		status = "Baik"
-		score = 3
-	} else if value >= 30 {
-		status = "Cukup"
-		score = 2
-	} else {
-		status = "Buruk"
-		score = 1
-	}
-	return ParameterEvaluation{"Chat dengan Kontak", value, status, score}
-}
-
-func evaluateSensitiveContent(value int) ParameterEvaluation {
-	var status string
-	var score int
-	if value <= 5 {
-		status = "Baik"
-		score = 3
-	} else if value <= 10 {
-		status = "Cukup"
-		score = 2
-	} else {
-		status = "Buruk"
-		score = 1
-	}
-	return ParameterEvaluation{"Sensitivitas Chat", value, status, score}
-}
-
-func evaluateUnsavedChats(value int) ParameterEvaluation {
-	var status string
-	var score int
-	if value <= 100 {
-		status = "Baik"
-		score = 3
-	} else if value <= 500 {
-		status = "Cukup"
-		score = 2
-	} else {
-		status = "Buruk"
-		score = 1
-	}
-	return ParameterEvaluation{"Uninterested Chat", value, status, score}
-}
-
-func evaluateUnknownChats(value int) ParameterEvaluation {
-	var status string
-	var score int
-	if value <= 15 {
-		status = "Baik"
-		score = 3
-	} else if value <= 30 {
-		status = "Cukup"
-		score = 2
-	} else {
-		status = "Buruk"
-		score = 1
-	}
-	return ParameterEvaluation{"Chat tidak dikenal", value, status, score}
+	return strength, summary, evaluations
 }
 
 func generateSummary(evaluations []ParameterEvaluation, strength string, averageScore float64) string {
@@ -256,7 +203,11 @@ func generateSummary(evaluations []ParameterEvaluation, strength string, average
 
 	summary += "Detail Parameter:\n"
 	for _, eval := range evaluations {
-		summary += fmt.Sprintf("• %s: %d (%s)\n", eval.Parameter, eval.Value, eval.Status)
+		if eval.Estimated {
+			summary += fmt.Sprintf("• %s: %d (%s, estimasi)\n", eval.Parameter, eval.Value, eval.Status)
+		} else {
+			summary += fmt.Sprintf("• %s: %d (%s)\n", eval.Parameter, eval.Value, eval.Status)
+		}
 	}
 
 	return summary
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// whatsAppLaunch bounds account ages: no account can be older than WhatsApp itself
+var whatsAppLaunch = time.Date(2009, time.February, 24, 0, 0, 0, 0, time.UTC)
+
+// AnalysisInputs are the eight computed parameters scored by CalculateStrengthWith
+type AnalysisInputs struct {
+	TotalChats            int
+	TotalContacts         int
+	AccountAgeDays        int
+	TotalGroups           int
+	TotalChatWithContact  int
+	SensitiveContentCount int
+	TotalUnsavedChats     int
+	UnknownNumberChats    int
+}
+
+// Values returns the inputs in CalculateStrength argument order
+func (in AnalysisInputs) Values() (int, int, int, int, int, int, int, int) {
+	return in.TotalChats, in.TotalContacts, in.AccountAgeDays, in.TotalGroups, in.TotalChatWithContact,
+		in.SensitiveContentCount, in.TotalUnsavedChats, in.UnknownNumberChats
+}
+
+// Inputs returns the scored parameters of a result
+func (r *AnalysisResult) Inputs() AnalysisInputs {
+	return AnalysisInputs{
+		TotalChats:            r.TotalChats,
+		TotalContacts:         r.TotalContacts,
+		AccountAgeDays:        r.AccountAgeDays,
+		TotalGroups:           r.TotalGroups,
+		TotalChatWithContact:  r.TotalChatWithContact,
+		SensitiveContentCount: r.SensitiveContentCount,
+		TotalUnsavedChats:     r.TotalUnsavedChats,
+		UnknownNumberChats:    r.UnknownNumberChats,
+	}
+}
+
+// InputAnomaly is a computed value that contradicted another one. Corrected anomalies were
+// clamped to Corrected before scoring; the others are only flagged.
+type InputAnomaly struct {
+	Rule      string `json:"rule"`
+	Key       string `json:"key"` // scoring parameter, see ScoringConfig
+	Value     int    `json:"value"`
+	Corrected *int   `json:"corrected,omitempty"`
+	Detail    string `json:"detail"`
+}
+
+// Sanitize checks the inputs against each other and clamps values that cannot be right,
+// e.g. more chats with contacts than chats in total. Every violation is returned, so the
+// caller can lower the parameter's confidence and log it for improving the engine.
+func (in *AnalysisInputs) Sanitize() []InputAnomaly {
+	var anomalies []InputAnomaly
+	clamp := func(rule, key string, value *int, bound int, detail string) {
+		if *value <= bound {
+			return
+		}
+		corrected := bound
+		anomalies = append(anomalies, InputAnomaly{Rule: rule, Key: key, Value: *value, Corrected: &corrected, Detail: detail})
+		*value = bound
+	}
+
+	for _, field := range []struct {
+		key   string
+		value *int
+	}{
+		{ScoreTotalChats, &in.TotalChats},
+		{ScoreTotalContacts, &in.TotalContacts},
+		{ScoreAccountAgeDays, &in.AccountAgeDays},
+		{ScoreTotalGroups, &in.TotalGroups},
+		{ScoreTotalChatWithContact, &in.TotalChatWithContact},
+		{ScoreSensitiveContent, &in.SensitiveContentCount},
+		{ScoreTotalUnsavedChats, &in.TotalUnsavedChats},
+		{ScoreUnknownNumberChats, &in.UnknownNumberChats},
+	} {
+		if *field.value < 0 {
+			corrected := 0
+			anomalies = append(anomalies, InputAnomaly{Rule: "non_negative", Key: field.key, Value: *field.value, Corrected: &corrected, Detail: "count cannot be negative"})
+			*field.value = 0
+		}
+	}
+
+	// Chats with contacts and unsaved chats are disjoint subsets of all chats, unknown
+	// numbers a subset of the unsaved ones
+	clamp("chats_with_contact_within_chats", ScoreTotalChatWithContact, &in.TotalChatWithContact, in.TotalChats,
+		fmt.Sprintf("more chats with contacts than chats in total (%d)", in.TotalChats))
+	clamp("unsaved_chats_within_chats", ScoreTotalUnsavedChats, &in.TotalUnsavedChats, in.TotalChats-in.TotalChatWithContact,
+		fmt.Sprintf("unsaved and contact chats exceed chats in total (%d)", in.TotalChats))
+	clamp("unknown_within_unsaved_chats", ScoreUnknownNumberChats, &in.UnknownNumberChats, in.TotalUnsavedChats,
+		fmt.Sprintf("more chats with unknown numbers than unsaved chats (%d)", in.TotalUnsavedChats))
+
+	clamp("groups_within_contacts", ScoreTotalGroups, &in.TotalGroups, in.TotalContacts,
+		fmt.Sprintf("more groups than contacts (%d)", in.TotalContacts))
+	maxAge := int(time.Since(whatsAppLaunch).Hours() / 24)
+	clamp("account_age_within_whatsapp", ScoreAccountAgeDays, &in.AccountAgeDays, maxAge,
+		fmt.Sprintf("account older than WhatsApp (%d days)", maxAge))
+
+	// Sensitive content is an estimate over messages, not chats; flag it without correcting
+	if in.TotalChats > 0 && in.SensitiveContentCount > in.TotalChats {
+		anomalies = append(anomalies, InputAnomaly{Rule: "sensitive_within_chats", Key: ScoreSensitiveContent, Value: in.SensitiveContentCount,
+			Detail: fmt.Sprintf("more sensitive content than chats (%d)", in.TotalChats)})
+	}
+	return anomalies
+}
+
+// Downgrade marks every corrected parameter as estimated with at most fallback confidence
+func (c ParameterConfidences) Downgrade(anomalies []InputAnomaly) {
+	for _, anomaly := range anomalies {
+		if anomaly.Corrected == nil {
+			continue
+		}
+		confidence := c.Of(anomaly.Key).Confidence
+		if confidence > ConfidenceFallback {
+			confidence = ConfidenceFallback
+		}
+		c[anomaly.Key] = Estimated(confidence)
+	}
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// AnalysisGroup is one group the account was a member of when the analysis ran
+type AnalysisGroup struct {
+	ID               uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	AnalysisResultID uint      `json:"analysis_result_id" gorm:"not null;index"`
+	GroupJID         string    `json:"group_jid" gorm:"size:100"`
+	Name             string    `json:"name" gorm:"size:255"`
+	CreatedAt        time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for AnalysisGroup
+func (AnalysisGroup) TableName() string {
+	return "analysis_groups"
+}
+
+// AnalysisContact is one contact seen during an analysis. The JID is stored as a
+// SHA-256 hash so breakdowns can be compared between scans without keeping phone numbers.
+type AnalysisContact struct {
+	ID               uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	AnalysisResultID uint      `json:"analysis_result_id" gorm:"not null;index"`
+	ContactHash      string    `json:"contact_hash" gorm:"size:64"`
+	Server           string    `json:"server" gorm:"size:32"`
+	Saved            bool      `json:"saved"`
+	Business         bool      `json:"business"`
+	CreatedAt        time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for AnalysisContact
+func (AnalysisContact) TableName() string {
+	return "analysis_contacts"
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrAnalysisImmutable is returned when an update would modify a stored analysis snapshot
+var ErrAnalysisImmutable = errors.New("analysis result is immutable")
+
+// canonicalAnalysis is the fixed field set (and order) covered by the checksum
+type canonicalAnalysis struct {
+	UserID                uint   `json:"user_id"`
+	TotalChats            int    `json:"total_chats"`
+	TotalContacts         int    `json:"total_contacts"`
+	AccountAgeDays        int    `json:"account_age_days"`
+	TotalGroups           int    `json:"total_groups"`
+	TotalChatWithContact  int    `json:"total_chat_with_contact"`
+	SensitiveContentCount int    `json:"sensitive_content_count"`
+	TotalUnsavedChats     int    `json:"total_unsaved_chats"`
+	UnknownNumberChats    int    `json:"unknown_number_chats"`
+	Strength              string `json:"strength"`
+	Summary               string `json:"summary"`
+	ScanDate              string `json:"scan_date"`
+
+	// Added later; omitted when empty so the checksums of older results stay valid
+	SensitiveCategories SensitiveCounts `json:"sensitive_categories,omitempty"`
+}
+
+// CanonicalPayload returns the deterministic JSON the checksum is computed over. An analysis an
+// account merge re-owned keeps the checksum sealed for its original owner (SealedUserID).
+func (a *AnalysisResult) CanonicalPayload() []byte {
+	owner := a.UserID
+	if a.SealedUserID != nil {
+		owner = *a.SealedUserID
+	}
+	payload, _ := json.Marshal(canonicalAnalysis{
+		UserID:                owner,
+		TotalChats:            a.TotalChats,
+		TotalContacts:         a.TotalContacts,
+		AccountAgeDays:        a.AccountAgeDays,
+		TotalGroups:           a.TotalGroups,
+		TotalChatWithContact:  a.TotalChatWithContact,
+		SensitiveContentCount: a.SensitiveContentCount,
+		TotalUnsavedChats:     a.TotalUnsavedChats,
+		UnknownNumberChats:    a.UnknownNumberChats,
+		Strength:              a.Strength,
+		Summary:               a.Summary,
+		ScanDate:              a.ScanDate.UTC().Format(time.RFC3339),
+		SensitiveCategories:   a.SensitiveCategories,
+	})
+	return payload
+}
+
+// ComputeChecksum returns the hex SHA-256 of the canonical payload
+func (a *AnalysisResult) ComputeChecksum() string {
+	sum := sha256.Sum256(a.CanonicalPayload())
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyChecksum reports whether the stored checksum still matches the stored data
+func (a *AnalysisResult) VerifyChecksum() bool {
+	return a.Checksum != "" && a.Checksum == a.ComputeChecksum()
+}
+
+// BeforeCreate seals the snapshot: scan date is truncated to seconds (what every
+// database keeps) and the checksum is computed over the canonical payload
+func (a *AnalysisResult) BeforeCreate(tx *gorm.DB) error {
+	if a.ScanDate.IsZero() {
+		a.ScanDate = time.Now()
+	}
+	a.ScanDate = a.ScanDate.Truncate(time.Second)
+	a.Checksum = a.ComputeChecksum()
+	return nil
+}
+
+// BeforeUpdate rejects updates that touch the sealed snapshot fields
+func (a *AnalysisResult) BeforeUpdate(tx *gorm.DB) error {
+	if tx.Statement.Changed("UserID", "TotalChats", "TotalContacts", "AccountAgeDays", "TotalGroups",
+		"TotalChatWithContact", "SensitiveContentCount", "TotalUnsavedChats", "UnknownNumberChats",
+		"Strength", "Summary", "Parameters", "ScanDate", "SensitiveCategories", "Checksum") {
+		return ErrAnalysisImmutable
+	}
+	return nil
+}
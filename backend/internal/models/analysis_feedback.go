@@ -0,0 +1,60 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Feedback ratings run from 1 (not useful) to 5 (very useful)
+const (
+	FeedbackRatingMin     = 1
+	FeedbackRatingMax     = 5
+	FeedbackCommentMaxLen = 2000
+)
+
+// AnalysisFeedback is the user's rating of one analysis result; sending it again replaces it
+type AnalysisFeedback struct {
+	ID               uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	AnalysisResultID uint      `json:"analysis_result_id" gorm:"not null;uniqueIndex"`
+	UserID           uint      `json:"user_id" gorm:"not null;index"`
+	Rating           int       `json:"rating" gorm:"not null"`
+	Comment          string    `json:"comment" gorm:"type:text"`
+	Strength         string    `json:"strength" gorm:"size:10;index"` // result's strength band when rated
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for AnalysisFeedback
+func (AnalysisFeedback) TableName() string {
+	return "analysis_feedback"
+}
+
+// Validate checks the rating range and trims the comment
+func (f *AnalysisFeedback) Validate() error {
+	if f.Rating < FeedbackRatingMin || f.Rating > FeedbackRatingMax {
+		return fmt.Errorf("rating must be between %d and %d", FeedbackRatingMin, FeedbackRatingMax)
+	}
+	f.Comment = strings.TrimSpace(f.Comment)
+	if len([]rune(f.Comment)) > FeedbackCommentMaxLen {
+		return fmt.Errorf("comment must be at most %d characters", FeedbackCommentMaxLen)
+	}
+	return nil
+}
+
+// FeedbackBandSummary aggregates the ratings of results in one strength band
+type FeedbackBandSummary struct {
+	Strength      string      `json:"strength"`
+	Count         int64       `json:"count"`
+	AverageRating float64     `json:"average_rating"`
+	Ratings       map[int]int `json:"ratings"` // rating -> count
+	WithComment   int64       `json:"with_comment"`
+}
+
+// FeedbackSummary is the admin overview of analysis feedback
+type FeedbackSummary struct {
+	Since         *time.Time            `json:"since,omitempty"`
+	Count         int64                 `json:"count"`
+	AverageRating float64               `json:"average_rating"`
+	Bands         []FeedbackBandSummary `json:"bands"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+)
+
+// Analysis job states
+const (
+	AnalysisJobQueued    = "queued"
+	AnalysisJobRunning   = "running"
+	AnalysisJobCompleted = "completed"
+	AnalysisJobFailed    = "failed"
+	AnalysisJobSkipped   = "skipped" // bulk scan member that was not analyzed, see Error
+)
+
+// Analysis job priorities, served by the workers in weighted fair order
+const (
+	AnalysisPriorityPaid  = "paid"  // user paid for analyses
+	AnalysisPriorityTrial = "trial" // user only has free grants
+	AnalysisPriorityBulk  = "bulk"  // member analysis of an org bulk scan, or a scheduled re-scan
+)
+
+// AnalysisJob is an analysis requested through POST /api/wa/analyze (or an org bulk scan or scan schedule) and run by the job workers.
+// Partial holds the metrics known so far (JSON) while the job is running.
+type AnalysisJob struct {
+	ID         uint       `json:"-" gorm:"primaryKey;autoIncrement"`
+	JobID      string     `json:"job_id" gorm:"uniqueIndex;size:32;not null"`
+	UserID     uint       `json:"user_id" gorm:"not null;index"`
+	BulkScanID *uint      `json:"bulk_scan_id,omitempty" gorm:"index;default:null"` // set for jobs of an org bulk scan
+	ScheduleID *uint      `json:"schedule_id,omitempty" gorm:"default:null"`        // set for runs of a scan schedule
+	Force      bool       `json:"force,omitempty" gorm:"default:false"`             // store a new result even when it duplicates a recent one
+	Status     string     `json:"status" gorm:"type:varchar(20);not null;default:'queued';index"`
+	Priority   string     `json:"priority" gorm:"type:varchar(10);not null;default:'paid'"`
+	Stage      string     `json:"stage" gorm:"size:30"`
+	Progress   int        `json:"progress" gorm:"default:0"` // 0-100
+	Partial    string     `json:"-" gorm:"type:text"`
+	AnalysisID *uint      `json:"analysis_id,omitempty" gorm:"default:null"`
+	Error      string     `json:"error,omitempty" gorm:"size:500"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	StartedAt  *time.Time `json:"started_at,omitempty" gorm:"default:null"`
+	FinishedAt *time.Time `json:"finished_at,omitempty" gorm:"default:null"`
+	UpdatedAt  time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName specifies the table name for AnalysisJob
+func (AnalysisJob) TableName() string {
+	return "analysis_jobs"
+}
+
+// Finished reports whether the job reached a final state
+func (j *AnalysisJob) Finished() bool {
+	return j.Status == AnalysisJobCompleted || j.Status == AnalysisJobFailed || j.Status == AnalysisJobSkipped
+}
@@ -0,0 +1,181 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AnalysisResponse is an analysis result as the API returns it to its owner. Unlike
+// AnalysisResult it never carries the related user record; of the scan history only the phone
+// number and trigger are kept. Stage timings, serving cost, tenant and legal hold stay internal.
+type AnalysisResponse struct {
+	ID                    uint                  `json:"id"`
+	UserID                uint                  `json:"user_id"`
+	ScanHistoryID         *uint                 `json:"scan_history_id"`
+	PhoneNumber           string                `json:"phone_number,omitempty"` // scanned number, when the scan history was loaded
+	ScanTrigger           string                `json:"scan_trigger,omitempty"`
+	TotalChats            int                   `json:"totalChats"`
+	TotalContacts         int                   `json:"totalContacts"`
+	AccountAgeDays        int                   `json:"accountAgeDays"`
+	TotalGroups           int                   `json:"totalGroups"`
+	TotalChatWithContact  int                   `json:"totalChatWithContact"`
+	SensitiveContentCount int                   `json:"sensitiveContentCount"`
+	TotalUnsavedChats     int                   `json:"totalUnsavedChats"`
+	UnknownNumberChats    int                   `json:"unknownNumberChats"`
+	Strength              string                `json:"strength"`
+	Summary               string                `json:"summary"`
+	Checksum              string                `json:"checksum"`
+	Confidence            int                   `json:"confidence"`
+	DurationMs            int64                 `json:"duration_ms"`
+	ScoringVersion        *uint                 `json:"scoring_version"`
+	DataQuality           *DataQuality          `json:"data_quality,omitempty"`
+	ParameterConfidence   ParameterConfidences  `json:"parameter_confidence,omitempty"`
+	Parameters            []ParameterEvaluation `json:"parameters,omitempty"`
+	SensitiveCategories   SensitiveCounts       `json:"sensitive_categories,omitempty"`
+	Anomalies             []InputAnomaly        `json:"anomalies,omitempty"`
+	Pinned                bool                  `json:"pinned"`
+	Label                 string                `json:"label"`
+	AutoTriggered         bool                  `json:"auto_triggered,omitempty"`
+	Scheduled             bool                  `json:"scheduled,omitempty"`
+	Deduplicated          bool                  `json:"deduplicated,omitempty"`
+	FeedbackPrompt        bool                  `json:"feedback_prompt"`
+	ScanDate              time.Time             `json:"scan_date"`
+	CreatedAt             time.Time             `json:"created_at"`
+	UpdatedAt             time.Time             `json:"updated_at"`
+}
+
+// NewAnalysisResponse maps a stored result to its API representation
+func NewAnalysisResponse(result *AnalysisResult) *AnalysisResponse {
+	response := &AnalysisResponse{
+		ID:                    result.ID,
+		UserID:                result.UserID,
+		ScanHistoryID:         result.ScanHistoryID,
+		TotalChats:            result.TotalChats,
+		TotalContacts:         result.TotalContacts,
+		AccountAgeDays:        result.AccountAgeDays,
+		TotalGroups:           result.TotalGroups,
+		TotalChatWithContact:  result.TotalChatWithContact,
+		SensitiveContentCount: result.SensitiveContentCount,
+		TotalUnsavedChats:     result.TotalUnsavedChats,
+		UnknownNumberChats:    result.UnknownNumberChats,
+		Strength:              result.Strength,
+		Summary:               result.Summary,
+		Checksum:              result.Checksum,
+		Confidence:            result.Confidence,
+		DurationMs:            result.DurationMs,
+		ScoringVersion:        result.ScoringVersion,
+		DataQuality:           result.DataQuality,
+		ParameterConfidence:   result.ParameterConfidence,
+		Parameters:            result.Parameters,
+		SensitiveCategories:   result.SensitiveCategories,
+		Anomalies:             result.Anomalies,
+		Pinned:                result.Pinned,
+		Label:                 result.Label,
+		AutoTriggered:         result.AutoTriggered,
+		Scheduled:             result.Scheduled,
+		Deduplicated:          result.Deduplicated,
+		FeedbackPrompt:        result.FeedbackPrompt,
+		ScanDate:              result.ScanDate,
+		CreatedAt:             result.CreatedAt,
+		UpdatedAt:             result.UpdatedAt,
+	}
+	if result.ScanHistory.ID != 0 {
+		response.PhoneNumber = result.ScanHistory.PhoneNumber
+		response.ScanTrigger = result.ScanHistory.Trigger
+	}
+	return response
+}
+
+// AdminAnalysisResponse is a stored analysis result as admin endpoints return it, with the
+// user it belongs to. Only admin endpoints may return it.
+type AdminAnalysisResponse struct {
+	AnalysisResult
+	User *User `json:"user,omitempty"`
+}
+
+// NewAdminAnalysisResponse attaches the result's preloaded user, when it was loaded
+func NewAdminAnalysisResponse(result AnalysisResult) AdminAnalysisResponse {
+	response := AdminAnalysisResponse{AnalysisResult: result}
+	if result.User.ID != 0 {
+		user := result.User
+		response.User = &user
+	}
+	return response
+}
+
+var (
+	analysisFieldsOnce sync.Once
+	analysisFields     map[string]bool
+)
+
+// AnalysisResponseFields returns the names accepted by ?fields=, in alphabetical order
+func AnalysisResponseFields() []string {
+	loadAnalysisFields()
+	names := make([]string, 0, len(analysisFields))
+	for name := range analysisFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func loadAnalysisFields() {
+	analysisFieldsOnce.Do(func() {
+		analysisFields = make(map[string]bool)
+		t := reflect.TypeOf(AnalysisResponse{})
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+			if name != "" && name != "-" {
+				analysisFields[name] = true
+			}
+		}
+	})
+}
+
+// ParseAnalysisFields reads a ?fields=id,strength,summary list; empty means all fields
+func ParseAnalysisFields(raw string) ([]string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	loadAnalysisFields()
+	var fields []string
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !analysisFields[field] {
+			return nil, fmt.Errorf("unknown field %q, expected any of %s", field, strings.Join(AnalysisResponseFields(), ", "))
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// Sparse returns the response itself when fields is empty, otherwise only the given fields
+func (r *AnalysisResponse) Sparse(fields []string) interface{} {
+	if len(fields) == 0 {
+		return r
+	}
+	encoded, err := json.Marshal(r)
+	if err != nil {
+		return r
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &all); err != nil {
+		return r
+	}
+	sparse := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		if value, ok := all[field]; ok {
+			sparse[field] = value
+		}
+	}
+	return sparse
+}
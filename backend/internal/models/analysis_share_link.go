@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+)
+
+// Resources that can be shared through a signed link
+const (
+	ShareResourceJSON = "json"
+	ShareResourcePDF  = "pdf"
+)
+
+// AnalysisShareLink is a revocable, time-limited signed link to one analysis result
+type AnalysisShareLink struct {
+	ID         uint       `json:"-" gorm:"primaryKey;autoIncrement"`
+	LinkID     string     `json:"link_id" gorm:"uniqueIndex;size:32;not null"`
+	UserID     uint       `json:"user_id" gorm:"not null;index"`
+	AnalysisID uint       `json:"analysis_id" gorm:"not null;index"`
+	Resource   string     `json:"resource" gorm:"size:10;not null"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt  *time.Time `json:"revoked_at" gorm:"default:null"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for AnalysisShareLink
+func (AnalysisShareLink) TableName() string {
+	return "analysis_share_links"
+}
+
+// SharedAnalysis is the public view of an analysis served through a share link
+type SharedAnalysis struct {
+	ID                    uint      `json:"id"`
+	PhoneNumber           string    `json:"phone_number"`
+	TotalChats            int       `json:"totalChats"`
+	TotalContacts         int       `json:"totalContacts"`
+	AccountAgeDays        int       `json:"accountAgeDays"`
+	TotalGroups           int       `json:"totalGroups"`
+	TotalChatWithContact  int       `json:"totalChatWithContact"`
+	SensitiveContentCount int       `json:"sensitiveContentCount"`
+	TotalUnsavedChats     int       `json:"totalUnsavedChats"`
+	UnknownNumberChats    int       `json:"unknownNumberChats"`
+	Strength              string    `json:"strength"`
+	Summary               string    `json:"summary"`
+	ScanDate              time.Time `json:"scan_date"`
+	Checksum              string    `json:"checksum"`
+
+	Parameters []ParameterEvaluation `json:"parameters,omitempty"`
+}
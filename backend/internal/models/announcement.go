@@ -0,0 +1,75 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Announcement kinds, used by the frontend to style the banner
+const (
+	AnnouncementKindInfo        = "info"
+	AnnouncementKindMaintenance = "maintenance"
+	AnnouncementKindPromo       = "promo"
+)
+
+// Announcement audiences: everyone, paying users or trial users (see EntitlementService.Tier)
+const (
+	AnnouncementAudienceAll   = "all"
+	AnnouncementAudiencePaid  = "paid"
+	AnnouncementAudienceTrial = "trial"
+)
+
+// Announcement is a banner published by operators, shown between StartsAt and EndsAt
+// (open-ended when nil) to the users of its audience
+type Announcement struct {
+	ID        uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	Title     string     `json:"title" gorm:"size:200;not null"`
+	Message   string     `json:"message" gorm:"type:text;not null"`
+	Kind      string     `json:"kind" gorm:"size:20;not null;default:'info'"`
+	Audience  string     `json:"audience" gorm:"size:10;not null;default:'all';index"`
+	Link      string     `json:"link,omitempty" gorm:"size:500"`
+	StartsAt  *time.Time `json:"starts_at" gorm:"default:null"`
+	EndsAt    *time.Time `json:"ends_at" gorm:"default:null;index"`
+	Active    bool       `json:"active" gorm:"not null"`
+	CreatedBy *uint      `json:"created_by,omitempty" gorm:"default:null"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName specifies the table name for Announcement
+func (Announcement) TableName() string {
+	return "announcements"
+}
+
+// Validate trims the text fields, fills in defaults and checks kind, audience and schedule
+func (a *Announcement) Validate() error {
+	a.Title = strings.TrimSpace(a.Title)
+	a.Message = strings.TrimSpace(a.Message)
+	if a.Title == "" || a.Message == "" {
+		return fmt.Errorf("title and message are required")
+	}
+	if len(a.Title) > 200 {
+		return fmt.Errorf("title must be at most 200 characters")
+	}
+	if a.Kind == "" {
+		a.Kind = AnnouncementKindInfo
+	}
+	if a.Audience == "" {
+		a.Audience = AnnouncementAudienceAll
+	}
+	switch a.Kind {
+	case AnnouncementKindInfo, AnnouncementKindMaintenance, AnnouncementKindPromo:
+	default:
+		return fmt.Errorf("kind must be %q, %q or %q", AnnouncementKindInfo, AnnouncementKindMaintenance, AnnouncementKindPromo)
+	}
+	switch a.Audience {
+	case AnnouncementAudienceAll, AnnouncementAudiencePaid, AnnouncementAudienceTrial:
+	default:
+		return fmt.Errorf("audience must be %q, %q or %q", AnnouncementAudienceAll, AnnouncementAudiencePaid, AnnouncementAudienceTrial)
+	}
+	if a.StartsAt != nil && a.EndsAt != nil && !a.EndsAt.After(*a.StartsAt) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+	return nil
+}
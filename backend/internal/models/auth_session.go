@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// AuthSession records one issued JWT (by its jti claim) so it can be listed as a signed-in device
+// and revoked before it expires. Purpose-scoped tokens (wa:qr) are recorded with the session
+// they were minted from and are revoked with it.
+type AuthSession struct {
+	ID         uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	JTI        string     `json:"-" gorm:"column:jti;size:64;uniqueIndex;not null"`
+	ParentJTI  string     `json:"-" gorm:"column:parent_jti;size:64;index"` // session a scoped token was minted from
+	UserID     uint       `json:"user_id" gorm:"not null;index"`
+	Purpose    string     `json:"purpose,omitempty" gorm:"size:20"` // empty for full account tokens
+	Device     string     `json:"device" gorm:"size:100"`           // e.g. "Chrome on Android", derived from UserAgent
+	UserAgent  string     `json:"user_agent" gorm:"size:255"`
+	IPAddress  string     `json:"ip_address" gorm:"size:45"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"not null;index"`
+	LastUsedAt *time.Time `json:"last_used_at" gorm:"default:null"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" gorm:"default:null"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for AuthSession
+func (AuthSession) TableName() string {
+	return "auth_sessions"
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+)
+
+// Bulk scan states
+const (
+	BulkScanRunning   = "running"
+	BulkScanCompleted = "completed"
+)
+
+// BulkScan is an organization-wide analysis of its members' connected WhatsApp sessions,
+// started through POST /api/orgs/{id}/bulk-scan. Every member gets an AnalysisJob
+// (BulkScanID set); Report holds the consolidated BulkScanReport (JSON) once all jobs finished.
+type BulkScan struct {
+	ID          uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	TenantID    uint       `json:"org_id" gorm:"not null;index"`
+	RequestedBy uint       `json:"requested_by" gorm:"not null"`
+	Status      string     `json:"status" gorm:"type:varchar(20);not null;default:'running';index"`
+	Total       int        `json:"total"`
+	Completed   int        `json:"completed"`
+	Failed      int        `json:"failed"`
+	Skipped     int        `json:"skipped"` // members whose number is not paid for or already analyzing
+	Report      string     `json:"-" gorm:"type:text"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty" gorm:"default:null"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName specifies the table name for BulkScan
+func (BulkScan) TableName() string {
+	return "bulk_scans"
+}
+
+// Progress returns the finished share of the members, 0-100
+func (b *BulkScan) Progress() int {
+	if b.Total == 0 {
+		return 100
+	}
+	return (b.Completed + b.Failed + b.Skipped) * 100 / b.Total
+}
+
+// BulkScanMemberResult is one member's line in the bulk scan report
+type BulkScanMemberResult struct {
+	UserID        uint   `json:"user_id"`
+	Username      string `json:"username"`
+	Status        string `json:"status"` // completed, failed or skipped
+	AnalysisID    *uint  `json:"analysis_id,omitempty"`
+	Strength      string `json:"strength,omitempty"`
+	TotalChats    int    `json:"totalChats,omitempty"`
+	TotalContacts int    `json:"totalContacts,omitempty"`
+	TotalGroups   int    `json:"totalGroups,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// BulkScanReport is the consolidated result of a bulk scan
+type BulkScanReport struct {
+	Members   int                    `json:"members"`
+	Completed int                    `json:"completed"`
+	Failed    int                    `json:"failed"`
+	Skipped   int                    `json:"skipped"`
+	Strength  map[string]int         `json:"strength"` // completed members per strength rating
+	Results   []BulkScanMemberResult `json:"results"`
+}
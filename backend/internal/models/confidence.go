@@ -0,0 +1,51 @@
+package models
+
+// Confidence levels (0-100) of how a parameter value was obtained
+const (
+	ConfidenceMeasured  = 100 // counted from data received from WhatsApp
+	ConfidenceFallback  = 50  // counted from a secondary source after the primary one failed
+	ConfidenceEstimated = 20  // heuristic estimate, not measured at all
+	ConfidenceNoData    = 10  // source had no data yet, the value is a placeholder
+)
+
+// ParameterConfidence tells how trustworthy one parameter value is. Estimated values are
+// heuristics (e.g. account age derived from contacts) and are shown as such by the frontend.
+type ParameterConfidence struct {
+	Confidence int  `json:"confidence"` // 0-100
+	Estimated  bool `json:"estimated"`
+}
+
+// ParameterConfidences maps scoring parameter keys (ScoreTotalChats, ...) to their confidence
+type ParameterConfidences map[string]ParameterConfidence
+
+// Measured returns the confidence of a value counted from WhatsApp data
+func Measured() ParameterConfidence {
+	return ParameterConfidence{Confidence: ConfidenceMeasured}
+}
+
+// Estimated returns the confidence of a heuristic value
+func Estimated(confidence int) ParameterConfidence {
+	return ParameterConfidence{Confidence: confidence, Estimated: true}
+}
+
+// Of returns the confidence of one parameter; parameters without an entry count as measured
+func (c ParameterConfidences) Of(key string) ParameterConfidence {
+	if confidence, ok := c[key]; ok {
+		return confidence
+	}
+	return Measured()
+}
+
+// Overall is the confidence of the whole result: the parameter confidences averaged with
+// the scoring weights, so uncertain parameters matter as much as they affect the strength
+func (c ParameterConfidences) Overall(config *ScoringConfig) int {
+	var weighted, total float64
+	for _, param := range config.Parameters {
+		weighted += float64(c.Of(param.Key).Confidence) * param.Weight
+		total += param.Weight
+	}
+	if total == 0 {
+		return 0
+	}
+	return int(weighted/total + 0.5)
+}
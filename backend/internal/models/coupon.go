@@ -0,0 +1,124 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Coupon discount types
+const (
+	CouponPercent = "percent" // DiscountValue percent of the amount, capped at MaxDiscount when set
+	CouponFixed   = "fixed"   // DiscountValue rupiah off
+)
+
+// MinChargeAmount is the smallest invoice amount; discounts never bring a payment below it
+const MinChargeAmount = 1000
+
+// Coupon is a promo code that lowers the amount of a payment. Each payment created with it uses
+// it once; uses of invoices that expire or fail unpaid are given back.
+type Coupon struct {
+	ID            uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	Code          string     `json:"code" gorm:"size:50;uniqueIndex;not null"`
+	Description   string     `json:"description" gorm:"size:255"`
+	DiscountType  string     `json:"discount_type" gorm:"size:10;not null"`
+	DiscountValue float64    `json:"discount_value" gorm:"not null"`
+	MaxDiscount   float64    `json:"max_discount"` // percent coupons only, 0 = no cap
+	MinAmount     float64    `json:"min_amount"`
+	UsageLimit    int        `json:"usage_limit" gorm:"not null;default:0"` // 0 = unlimited
+	UsedCount     int        `json:"used_count" gorm:"not null;default:0"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+	IsActive      bool       `json:"is_active" gorm:"not null"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for Coupon
+func (Coupon) TableName() string {
+	return "coupons"
+}
+
+// NormalizeCouponCode trims and upper-cases a code so lookups ignore case
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Validate normalizes the code and checks the discount, limits and expiry
+func (c *Coupon) Validate() error {
+	c.Code = NormalizeCouponCode(c.Code)
+	c.Description = strings.TrimSpace(c.Description)
+	c.DiscountType = strings.ToLower(strings.TrimSpace(c.DiscountType))
+	switch {
+	case c.Code == "" || len(c.Code) > 50:
+		return fmt.Errorf("code is required (max 50 characters)")
+	case strings.ContainsAny(c.Code, " ,"):
+		return fmt.Errorf("code cannot contain spaces or commas")
+	case len(c.Description) > 255:
+		return fmt.Errorf("description is too long (max 255 characters)")
+	case c.DiscountType != CouponPercent && c.DiscountType != CouponFixed:
+		return fmt.Errorf("discount_type must be percent or fixed")
+	case c.DiscountValue <= 0:
+		return fmt.Errorf("discount_value must be positive")
+	case c.DiscountType == CouponPercent && c.DiscountValue > 100:
+		return fmt.Errorf("discount_value cannot exceed 100 percent")
+	case c.MaxDiscount < 0 || c.MinAmount < 0:
+		return fmt.Errorf("max_discount and min_amount cannot be negative")
+	case c.UsageLimit < 0:
+		return fmt.Errorf("usage_limit cannot be negative")
+	}
+	return nil
+}
+
+// Expired reports whether the coupon's expiry has passed at now
+func (c *Coupon) Expired(now time.Time) bool {
+	return c.ExpiresAt != nil && !now.Before(*c.ExpiresAt)
+}
+
+// Exhausted reports whether every use of a limited coupon is taken
+func (c *Coupon) Exhausted() bool {
+	return c.UsageLimit > 0 && c.UsedCount >= c.UsageLimit
+}
+
+// DiscountFor returns the whole-rupiah discount on amount, leaving at least MinChargeAmount to pay
+func (c *Coupon) DiscountFor(amount float64) float64 {
+	discount := c.DiscountValue
+	if c.DiscountType == CouponPercent {
+		discount = amount * c.DiscountValue / 100
+		if c.MaxDiscount > 0 && discount > c.MaxDiscount {
+			discount = c.MaxDiscount
+		}
+	}
+	discount = math.Round(discount)
+	if amount-discount < MinChargeAmount {
+		discount = math.Max(0, amount-MinChargeAmount)
+	}
+	return discount
+}
+
+// CouponRedemption records the use of a coupon by one payment
+type CouponRedemption struct {
+	ID            uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	CouponID      uint       `json:"coupon_id" gorm:"not null;index"`
+	UserID        int        `json:"user_id" gorm:"not null;index"`
+	TransactionID int        `json:"transaction_id" gorm:"not null;uniqueIndex"`
+	Amount        float64    `json:"amount"` // before the discount
+	Discount      float64    `json:"discount"`
+	ReleasedAt    *time.Time `json:"released_at"` // the invoice expired or failed unpaid
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// TableName specifies the table name for CouponRedemption
+func (CouponRedemption) TableName() string {
+	return "coupon_redemptions"
+}
+
+// CouponQuote is what a coupon takes off an amount, as shown before paying
+type CouponQuote struct {
+	Code        string     `json:"code"`
+	Description string     `json:"description"`
+	Amount      float64    `json:"amount"`
+	Discount    float64    `json:"discount"`
+	FinalAmount float64    `json:"final_amount"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
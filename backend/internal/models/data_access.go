@@ -0,0 +1,72 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Data access request limits
+const (
+	DataAccessMinReasonLength = 10
+	DataAccessMaxMinutes      = 8 * 60
+)
+
+// DataAccessRequest is a support staff member's justification for viewing one user's analyses
+// and transactions. Admin endpoints exposing that data require an active request.
+type DataAccessRequest struct {
+	ID        uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	AdminID   uint       `json:"admin_id" gorm:"not null;index"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	Reason    string     `json:"reason" gorm:"size:500;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null;index"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" gorm:"default:null"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for DataAccessRequest
+func (DataAccessRequest) TableName() string {
+	return "data_access_requests"
+}
+
+// Active reports whether the request still grants access
+func (r *DataAccessRequest) Active() bool {
+	return r.RevokedAt == nil && time.Now().Before(r.ExpiresAt)
+}
+
+// CreateDataAccessRequest is the body of POST /api/admin/data-access-requests
+type CreateDataAccessRequest struct {
+	UserID          uint   `json:"user_id"`
+	Reason          string `json:"reason"`
+	DurationMinutes int    `json:"duration_minutes"`
+}
+
+// Validate checks the subject, reason and duration
+func (c *CreateDataAccessRequest) Validate() error {
+	c.Reason = strings.TrimSpace(c.Reason)
+	switch {
+	case c.UserID == 0:
+		return fmt.Errorf("user_id is required")
+	case len(c.Reason) < DataAccessMinReasonLength || len(c.Reason) > 500:
+		return fmt.Errorf("reason must be between %d and 500 characters", DataAccessMinReasonLength)
+	case c.DurationMinutes < 1 || c.DurationMinutes > DataAccessMaxMinutes:
+		return fmt.Errorf("duration_minutes must be between 1 and %d", DataAccessMaxMinutes)
+	}
+	return nil
+}
+
+// DataAccessLog is the audit trail of every admin request served under a data access request
+type DataAccessLog struct {
+	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	RequestID uint      `json:"request_id" gorm:"not null;index"`
+	AdminID   uint      `json:"admin_id" gorm:"not null;index"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	Method    string    `json:"method" gorm:"size:10;not null"`
+	Path      string    `json:"path" gorm:"size:500;not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime;index"`
+}
+
+// TableName specifies the table name for DataAccessLog
+func (DataAccessLog) TableName() string {
+	return "data_access_log"
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+)
+
+// Data source states in DataQuality
+const (
+	DataSourceOK       = "ok"       // fetched from WhatsApp
+	DataSourceFallback = "fallback" // fetch failed, value derived from other data
+	DataSourceMissing  = "missing"  // nothing received yet (e.g. history sync still running)
+)
+
+// DataQuality records which data sources an analysis could use. A degraded result is
+// served as usual but with lower confidence; failed sources are retried in the background
+// and the result is upgraded in place once they succeed.
+type DataQuality struct {
+	Contacts   string            `json:"contacts"`
+	Groups     string            `json:"groups"`
+	Chats      string            `json:"chats"`
+	Degraded   bool              `json:"degraded"`
+	Confidence int               `json:"confidence"` // 0-100
+	Errors     map[string]string `json:"errors,omitempty"`
+	UpgradedAt *time.Time        `json:"upgraded_at,omitempty"`
+}
+
+// Confidence penalties for sources that were not available
+const (
+	dataQualityFallbackPenalty = 25
+	dataQualityMissingPenalty  = 20
+)
+
+// Refresh recomputes Degraded and Confidence from the source states
+func (q *DataQuality) Refresh() {
+	q.Confidence = 100
+	q.Degraded = false
+	for _, state := range []string{q.Contacts, q.Groups, q.Chats} {
+		switch state {
+		case DataSourceFallback:
+			q.Confidence -= dataQualityFallbackPenalty
+			q.Degraded = true
+		case DataSourceMissing:
+			q.Confidence -= dataQualityMissingPenalty
+			q.Degraded = true
+		}
+	}
+}
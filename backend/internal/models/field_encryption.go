@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"back_wa/internal/fieldcrypt"
+
+	"gorm.io/gorm"
+)
+
+// Data key purposes
+const (
+	DataKeyPurposeField = "field" // seals encrypted columns
+	DataKeyPurposeIndex = "index" // keys the blind indexes of encrypted columns
+)
+
+// DataEncryptionKey is a data key of the field encryption, stored wrapped by a master key.
+// The oldest key of each purpose that is not retired is the active one.
+type DataEncryptionKey struct {
+	ID          uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	Purpose     string     `json:"purpose" gorm:"size:20;not null;index"`
+	WrappedKey  []byte     `json:"-" gorm:"not null"`
+	MasterKeyID string     `json:"master_key_id" gorm:"size:100;not null"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	RetiredAt   *time.Time `json:"retired_at"`
+}
+
+// BeforeSave keeps the blind index of the encrypted phone number in sync
+func (u *User) BeforeSave(tx *gorm.DB) error {
+	u.PhoneNumberHash = fieldcrypt.BlindIndex(u.PhoneNumber)
+	return nil
+}
+
+// BeforeSave keeps the blind index of the encrypted phone number in sync
+func (t *Transaction) BeforeSave(tx *gorm.DB) error {
+	t.PhoneNumberHash = fieldcrypt.BlindIndex(t.PhoneNumber)
+	return nil
+}
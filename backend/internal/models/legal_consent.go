@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Legal document types served by /api/legal
+const (
+	LegalDocumentTerms   = "tos"
+	LegalDocumentPrivacy = "privacy"
+)
+
+// LegalDocument is the version of a legal document this deployment currently serves
+type LegalDocument struct {
+	Type          string     `json:"type"`
+	Version       string     `json:"version"` // "major.minor"; a new major version requires re-consent
+	Major         int        `json:"major"`
+	EffectiveDate *time.Time `json:"effective_date,omitempty"`
+	URL           string     `json:"url,omitempty"`
+}
+
+// LegalConsent records a user accepting one version of a legal document
+type LegalConsent struct {
+	ID         uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID     uint      `json:"user_id" gorm:"not null;index:idx_legal_consents_user_document"`
+	Document   string    `json:"document" gorm:"size:20;not null;index:idx_legal_consents_user_document"`
+	Version    string    `json:"version" gorm:"size:20;not null"`
+	Major      int       `json:"major" gorm:"not null"`
+	IPAddress  string    `json:"-" gorm:"size:45"`
+	UserAgent  string    `json:"-" gorm:"size:255"`
+	AcceptedAt time.Time `json:"accepted_at" gorm:"not null"`
+}
+
+// TableName specifies the table name for LegalConsent
+func (LegalConsent) TableName() string {
+	return "legal_consents"
+}
+
+// LegalConsentStatus compares a user's latest consent for a document with the current version
+type LegalConsentStatus struct {
+	Document          LegalDocument `json:"document"`
+	AcceptedVersion   string        `json:"accepted_version,omitempty"`
+	AcceptedAt        *time.Time    `json:"accepted_at,omitempty"`
+	UpToDate          bool          `json:"up_to_date"`
+	RequiresReconsent bool          `json:"requires_reconsent"` // a new major version is in effect; analysis is blocked
+}
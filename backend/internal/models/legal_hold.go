@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// LegalHold marks a record as involved in a dispute; held records must not be deleted or purged.
+// Embedded into AnalysisResult and Transaction.
+type LegalHold struct {
+	OnHold     bool       `json:"on_hold" gorm:"default:false;index"`
+	HoldReason string     `json:"hold_reason,omitempty" gorm:"size:500"`
+	HoldUntil  *time.Time `json:"hold_until,omitempty" gorm:"default:null"` // nil = until released
+	HoldSetBy  *uint      `json:"hold_set_by,omitempty" gorm:"default:null"`
+	HoldSetAt  *time.Time `json:"hold_set_at,omitempty" gorm:"default:null"`
+}
+
+// HoldActive reports whether the hold is in force at the given time
+func (h LegalHold) HoldActive(now time.Time) bool {
+	return h.OnHold && (h.HoldUntil == nil || now.Before(*h.HoldUntil))
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// MessageScanConsent records whether a user allows the content of their recent messages to be
+// scanned for sensitive content. Without it the sensitive content parameter stays an estimate.
+type MessageScanConsent struct {
+	ID          uint       `json:"-" gorm:"primaryKey;autoIncrement"`
+	UserID      uint       `json:"user_id" gorm:"not null;uniqueIndex"`
+	Enabled     bool       `json:"enabled" gorm:"default:false"`
+	ConsentedAt *time.Time `json:"consented_at,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName specifies the table name for MessageScanConsent
+func (MessageScanConsent) TableName() string {
+	return "message_scan_consents"
+}
+
+// SensitiveCounts maps a sensitive content category (see contentscan) to the number of messages matching it
+type SensitiveCounts map[string]int
+
+// Add adds the counts of other
+func (c SensitiveCounts) Add(other SensitiveCounts) {
+	for category, n := range other {
+		c[category] += n
+	}
+}
@@ -0,0 +1,58 @@
+package models
+
+// Midtrans Snap API Models
+type MidtransSnapRequest struct {
+	TransactionDetails MidtransTransactionDetails `json:"transaction_details"`
+	CustomerDetails    MidtransCustomerDetails    `json:"customer_details"`
+	ItemDetails        []MidtransItem             `json:"item_details"`
+	Callbacks          *MidtransCallbacks         `json:"callbacks,omitempty"`
+	Expiry             MidtransExpiry             `json:"expiry"`
+}
+
+type MidtransTransactionDetails struct {
+	OrderID     string `json:"order_id"`
+	GrossAmount int64  `json:"gross_amount"`
+}
+
+type MidtransCustomerDetails struct {
+	FirstName string `json:"first_name"`
+	Email     string `json:"email"`
+}
+
+type MidtransItem struct {
+	ID       string `json:"id"`
+	Price    int64  `json:"price"`
+	Quantity int    `json:"quantity"`
+	Name     string `json:"name"`
+	Category string `json:"category,omitempty"`
+}
+
+type MidtransCallbacks struct {
+	Finish string `json:"finish"`
+	Error  string `json:"error,omitempty"`
+}
+
+type MidtransExpiry struct {
+	Unit     string `json:"unit"`
+	Duration int    `json:"duration"`
+}
+
+type MidtransSnapResponse struct {
+	Token         string   `json:"token"`
+	RedirectURL   string   `json:"redirect_url"`
+	ErrorMessages []string `json:"error_messages"`
+}
+
+// MidtransNotification is the body of both the status API and HTTP notifications (webhooks)
+type MidtransNotification struct {
+	TransactionID     string `json:"transaction_id"`
+	OrderID           string `json:"order_id"`
+	StatusCode        string `json:"status_code"`
+	GrossAmount       string `json:"gross_amount"`
+	SignatureKey      string `json:"signature_key"`
+	TransactionStatus string `json:"transaction_status"`
+	FraudStatus       string `json:"fraud_status"`
+	PaymentType       string `json:"payment_type"`
+	ExpiryTime        string `json:"expiry_time"`
+	StatusMessage     string `json:"status_message"`
+}
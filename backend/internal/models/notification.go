@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// Notification types emitted by domain events
+const (
+	NotificationPaymentPaid          = "payment_paid"
+	NotificationAnalysisCompleted    = "analysis_completed"
+	NotificationSessionDisconnected  = "session_disconnected"
+	NotificationSubscriptionExpiring = "subscription_expiring"
+	NotificationGoalAchieved         = "goal_achieved"
+	NotificationPaymentRefunded      = "payment_refunded"
+)
+
+// Notification is an in-app notification shown in the user's notification center
+type Notification struct {
+	ID        uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	Type      string     `json:"type" gorm:"size:50;not null"`
+	Title     string     `json:"title" gorm:"size:200;not null"`
+	Message   string     `json:"message" gorm:"type:text"`
+	Data      string     `json:"data,omitempty" gorm:"type:text"` // JSON payload for deep links (ids, external_id, ...)
+	ReadAt    *time.Time `json:"read_at" gorm:"default:null;index"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for Notification
+func (Notification) TableName() string {
+	return "notifications"
+}
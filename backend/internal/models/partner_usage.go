@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+)
+
+// Usage metrics metered per partner API key
+const (
+	UsageMetricAPIRequest = "api_request"
+	UsageMetricAnalysis   = "analysis"
+)
+
+// PartnerUsage is a monthly usage counter for one tenant and metric
+type PartnerUsage struct {
+	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	TenantID  uint      `json:"tenant_id" gorm:"not null;uniqueIndex:idx_partner_usage_period_metric"`
+	Period    string    `json:"period" gorm:"size:7;not null;uniqueIndex:idx_partner_usage_period_metric"` // YYYY-MM
+	Metric    string    `json:"metric" gorm:"size:32;not null;uniqueIndex:idx_partner_usage_period_metric"`
+	Count     int64     `json:"count" gorm:"not null;default:0"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName specifies the table name for PartnerUsage
+func (PartnerUsage) TableName() string {
+	return "partner_usage"
+}
+
+// UsageInvoiceLine is one billable line of a partner's monthly usage invoice
+type UsageInvoiceLine struct {
+	TenantID  uint    `json:"tenant_id"`
+	Slug      string  `json:"slug"`
+	BrandName string  `json:"brand_name"`
+	Period    string  `json:"period"`
+	Metric    string  `json:"metric"`
+	Quantity  int64   `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"`
+	Amount    float64 `json:"amount"`
+	Currency  string  `json:"currency"`
+}
+
+// UserUsage is a monthly usage counter for one account and metric (UsageMetricAPIRequest,
+// UsageMetricAnalysis), shown to the user by GET /api/user/usage
+type UserUsage struct {
+	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_user_usage_period_metric"`
+	Period    string    `json:"period" gorm:"size:7;not null;uniqueIndex:idx_user_usage_period_metric"` // YYYY-MM
+	Metric    string    `json:"metric" gorm:"size:32;not null;uniqueIndex:idx_user_usage_period_metric"`
+	Count     int64     `json:"count" gorm:"not null;default:0"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName specifies the table name for UserUsage
+func (UserUsage) TableName() string {
+	return "user_usage"
+}
+
+// UserUsageReport is the payload of GET /api/user/usage. Every analysis of a paid number
+// consumes one credit; credits are bought with paid transactions.
+type UserUsageReport struct {
+	Period          string  `json:"period"`
+	APIRequests     int64   `json:"api_requests"`
+	Scans           int64   `json:"scans"`
+	CreditsConsumed int64   `json:"credits_consumed"`
+	CreditsBought   int64   `json:"credits_bought"` // paid transactions in the period
+	AmountPaid      float64 `json:"amount_paid"`
+	Currency        string  `json:"currency"`
+}
@@ -1,32 +1,77 @@
 package models
 
 import (
+	"fmt"
+	"strings"
 	"time"
 )
 
 type Transaction struct {
-	ID             int        `json:"id" gorm:"primaryKey;autoIncrement"`
-	UserID         int        `json:"user_id" gorm:"not null"`
-	ExternalID     string     `json:"external_id" gorm:"uniqueIndex;not null"`
-	InvoiceID      string     `json:"invoice_id" gorm:"not null"`
-	Amount         float64    `json:"amount" gorm:"not null"`
-	Currency       string     `json:"currency" gorm:"default:IDR"`
-	Status         string     `json:"status" gorm:"default:pending"`
-	PaymentMethod  string     `json:"payment_method" gorm:"not null"`
-	PaymentChannel string     `json:"payment_channel"`
-	Description    string     `json:"description"`
-	PhoneNumber    string     `json:"phone_number" gorm:"not null"`
-	CreatedAt      time.Time  `json:"created_at"`
-	UpdatedAt      time.Time  `json:"updated_at"`
-	PaidAt         *time.Time `json:"paid_at"`
+	ID             int     `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID         int     `json:"user_id" gorm:"not null;uniqueIndex:idx_transactions_user_idempotency_key,priority:1"`
+	TenantID       *uint   `json:"tenant_id" gorm:"index"`
+	ExternalID     string  `json:"external_id" gorm:"uniqueIndex;not null"`
+	InvoiceID      string  `json:"invoice_id" gorm:"not null"`
+	Amount         float64 `json:"amount" gorm:"not null"`
+	Currency       string  `json:"currency" gorm:"default:IDR"`
+	Status         string  `json:"status" gorm:"default:pending"`
+	PaymentMethod  string  `json:"payment_method" gorm:"not null"`
+	PaymentChannel string  `json:"payment_channel"`
+	Description    string  `json:"description"`
+	PhoneNumber    string  `json:"phone_number" gorm:"not null;serializer:encrypted"`
+	// Blind index of PhoneNumber, used for lookups and the one-pending-per-phone index
+	PhoneNumberHash string     `json:"-" gorm:"size:64;index"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	PaidAt          *time.Time `json:"paid_at"`
+	RefundedAt      *time.Time `json:"refunded_at,omitempty"`
+
+	// Idempotency-Key of the create request, unique per user; replays return this transaction
+	IdempotencyKey *string `json:"-" gorm:"size:255;uniqueIndex:idx_transactions_user_idempotency_key,priority:2"`
+	RequestHash    string  `json:"-" gorm:"size:64"`
+	InvoiceURL     string  `json:"invoice_url"`
+	InvoiceExpiry  string  `json:"invoice_expiry"`
+	// Payment gateway that issued the invoice (xendit, midtrans); reconciliation asks the same one
+	Gateway string `json:"gateway" gorm:"size:20;not null;default:xendit"`
+
+	// Subscription paid by this transaction; such payments are not tied to a phone number
+	SubscriptionID *uint `json:"subscription_id,omitempty" gorm:"index"`
+
+	// Coupon applied when the invoice was created; Amount is what was charged after Discount
+	CouponCode string  `json:"coupon_code,omitempty" gorm:"size:50"`
+	Discount   float64 `json:"discount"`
+
+	LegalHold `gorm:"embedded"`
 }
 
 type CreatePaymentRequest struct {
 	Email         string  `json:"email" validate:"required,email"`
-	Category      string  `json:"category" validate:"required"`
+	Category      string  `json:"category"` // name of an active payment category, see also CategoryID
 	PaymentMethod string  `json:"payment_method" validate:"required"`
-	Amount        float64 `json:"amount" validate:"required,min=1000"`
+	Amount        float64 `json:"amount"` // set from the category price (or subscription plan), never taken from the client
 	PhoneNumber   string  `json:"phone_number" validate:"required"`
+	// Optional payment category ID, takes precedence over Category
+	CategoryID int `json:"category_id,omitempty"`
+	// Optional white-label redirect override, must be in FRONTEND_ALLOWED_ORIGINS
+	RedirectBaseURL string `json:"redirect_base_url,omitempty"`
+	// Optional token from a 402 payment bootstrap; prefills category, amount and phone number
+	CreatePaymentToken string `json:"create_payment_token,omitempty"`
+	// Optional payment gateway ("xendit" or "midtrans"), defaults to PAYMENT_GATEWAY
+	Gateway string `json:"gateway,omitempty"`
+	// Optional promo code; the discount is taken off Amount before the invoice is created
+	CouponCode string `json:"coupon_code,omitempty"`
+	// Set by the subscription flow, never from the request body
+	SubscriptionID *uint `json:"-"`
+}
+
+// PaymentBootstrap is attached to 402 responses so the frontend can go straight to checkout
+type PaymentBootstrap struct {
+	Plan               string    `json:"plan"`
+	Amount             float64   `json:"amount"`
+	Currency           string    `json:"currency"`
+	PhoneNumber        string    `json:"phone_number"`
+	CreatePaymentToken string    `json:"create_payment_token"`
+	ExpiresAt          time.Time `json:"expires_at"`
 }
 
 type CreatePaymentResponse struct {
@@ -37,9 +82,15 @@ type CreatePaymentResponse struct {
 	Amount        float64   `json:"amount"`
 	Status        string    `json:"status"`
 	PaymentMethod string    `json:"payment_method"`
+	Gateway       string    `json:"gateway"`
 	CreatedAt     time.Time `json:"created_at"`
 	ExpiryDate    string    `json:"expiry_date"` // Changed to string to match Xendit response
 	Message       string    `json:"message"`
+	// Existing is true when an already open transaction was returned instead of a new invoice
+	Existing bool `json:"existing"`
+	// Applied coupon; Amount is already reduced by Discount
+	CouponCode string  `json:"coupon_code,omitempty"`
+	Discount   float64 `json:"discount,omitempty"`
 }
 
 type PaymentStatusResponse struct {
@@ -50,6 +101,7 @@ type PaymentStatusResponse struct {
 	Status         string     `json:"status"`
 	PaymentMethod  string     `json:"payment_method"`
 	PaymentChannel string     `json:"payment_channel"`
+	Gateway        string     `json:"gateway"`
 	CreatedAt      time.Time  `json:"created_at"`
 	UpdatedAt      time.Time  `json:"updated_at"`
 	PaidAt         *time.Time `json:"paid_at"`
@@ -64,10 +116,11 @@ type TransactionHistoryResponse struct {
 	PaymentMethod  string     `json:"payment_method"`
 	PaymentChannel string     `json:"payment_channel"`
 	Description    string     `json:"description"`
-    PhoneNumber    string     `json:"phone_number"`
+	PhoneNumber    string     `json:"phone_number"`
 	CreatedAt      time.Time  `json:"created_at"`
 	UpdatedAt      time.Time  `json:"updated_at"`
 	PaidAt         *time.Time `json:"paid_at"`
+	RefundedAt     *time.Time `json:"refunded_at,omitempty"`
 }
 
 type WebhookPayload struct {
@@ -96,11 +149,71 @@ type PaymentMethod struct {
 	IsActive bool   `json:"is_active" gorm:"default:true"`
 }
 
+// PaymentCategory is something a user can pay for and its price; payments for a phone number are
+// charged the price of their category, whatever amount the client sends
 type PaymentCategory struct {
-	ID       int     `json:"id" gorm:"primaryKey;autoIncrement"`
-	Name     string  `json:"name" gorm:"not null"`
-	Price    float64 `json:"price" gorm:"not null"`
-	IsActive bool    `json:"is_active" gorm:"default:true"`
+	ID          int       `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name        string    `json:"name" gorm:"not null"`
+	Description string    `json:"description" gorm:"size:255"`
+	Price       float64   `json:"price" gorm:"not null"`
+	IsActive    bool      `json:"is_active" gorm:"default:true"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Validate trims the name and description and checks the price
+func (c *PaymentCategory) Validate() error {
+	c.Name = strings.TrimSpace(c.Name)
+	c.Description = strings.TrimSpace(c.Description)
+	switch {
+	case c.Name == "" || len(c.Name) > 100:
+		return fmt.Errorf("name is required (max 100 characters)")
+	case len(c.Description) > 255:
+		return fmt.Errorf("description is too long (max 255 characters)")
+	case c.Price < MinChargeAmount:
+		return fmt.Errorf("price must be at least %d", MinChargeAmount)
+	}
+	return nil
+}
+
+// ReconcileChange is one transaction whose status changed during a bulk reconciliation
+type ReconcileChange struct {
+	TransactionID int    `json:"transaction_id"`
+	ExternalID    string `json:"external_id"`
+	From          string `json:"from"`
+	To            string `json:"to"`
+}
+
+// ReconcileSummary is the result of reconciling pending transactions against Xendit
+type ReconcileSummary struct {
+	Checked        int               `json:"checked"`
+	Changed        int               `json:"changed"`
+	Unchanged      int               `json:"unchanged"`
+	Failed         int               `json:"failed"`
+	ExpiredLocally int               `json:"expired_locally"` // past the invoice expiry while the gateway still had them pending (or unknown)
+	ByStatus       map[string]int    `json:"by_status"`       // new status -> count, for changed transactions
+	Changes        []ReconcileChange `json:"changes"`
+	Errors         []string          `json:"errors,omitempty"`
+	Duration       string            `json:"duration"`
+}
+
+// GatewayCheckoutRequest is what any payment gateway needs to open a hosted payment page
+type GatewayCheckoutRequest struct {
+	ExternalID         string
+	Amount             float64
+	Description        string
+	Email              string
+	SuccessRedirectURL string
+	FailureRedirectURL string
+}
+
+// GatewayCheckout is a gateway's hosted payment page and its current state
+type GatewayCheckout struct {
+	ID             string // gateway reference stored as Transaction.InvoiceID
+	URL            string
+	Status         string // gateway status, normalized by UpdateTransactionStatus
+	PaymentChannel string
+	ExpiryDate     string
 }
 
 // Xendit API Models
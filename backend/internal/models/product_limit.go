@@ -0,0 +1,41 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// ProductLimit holds the per-user limits of one tier. Tier is "trial", "paid" or the code of a
+// subscription plan; a plan row applies to its active subscribers and takes precedence over
+// "paid". A value of 0 means unlimited.
+type ProductLimit struct {
+	ID          uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	Tier        string    `json:"tier" gorm:"size:50;not null;uniqueIndex"`
+	MaxSessions int       `json:"max_sessions" gorm:"not null;default:0"`  // signed-in devices; the least recently used are signed out
+	ScansPerDay int       `json:"scans_per_day" gorm:"not null;default:0"` // analyses per calendar day (SCAN_SCHEDULE_TIMEZONE)
+	HistorySize int       `json:"history_size" gorm:"not null;default:0"`  // newest analyses listed in the history
+	UpdatedBy   *uint     `json:"updated_by" gorm:"default:null"`
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName specifies the table name for ProductLimit
+func (ProductLimit) TableName() string {
+	return "product_limits"
+}
+
+// DefaultProductLimits are the limits of the built-in tiers until an admin changes them
+func DefaultProductLimits() map[string]ProductLimit {
+	return map[string]ProductLimit{
+		"trial": {Tier: "trial", MaxSessions: 3, ScansPerDay: 3, HistorySize: 20},
+		"paid":  {Tier: "paid", MaxSessions: 10, ScansPerDay: 50, HistorySize: 0},
+	}
+}
+
+// Validate checks that no limit is negative
+func (l *ProductLimit) Validate() error {
+	if l.MaxSessions < 0 || l.ScansPerDay < 0 || l.HistorySize < 0 {
+		return fmt.Errorf("limits must not be negative (0 = unlimited)")
+	}
+	return nil
+}
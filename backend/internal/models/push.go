@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+)
+
+// Push platforms supported by the push sender
+const (
+	PushPlatformFCM     = "fcm"
+	PushPlatformWebPush = "webpush"
+)
+
+// NotificationQRExpiring is a push-only event sent shortly before a pairing QR code expires
+const NotificationQRExpiring = "qr_expiring"
+
+// PushToken is a device registration for push notifications.
+// For FCM Token is the registration token; for WebPush it is the subscription endpoint.
+type PushToken struct {
+	ID         uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID     uint       `json:"user_id" gorm:"not null;index"`
+	Platform   string     `json:"platform" gorm:"size:20;not null"`
+	Token      string     `json:"token" gorm:"type:text;not null"`
+	TokenHash  string     `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	P256dh     string     `json:"-" gorm:"size:255"` // WebPush subscription keys
+	Auth       string     `json:"-" gorm:"size:255"`
+	UserAgent  string     `json:"user_agent" gorm:"size:255"`
+	LastUsedAt *time.Time `json:"last_used_at" gorm:"default:null"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName specifies the table name for PushToken
+func (PushToken) TableName() string {
+	return "push_tokens"
+}
+
+// Email digest frequencies of PushPreference.EmailDigest
+const (
+	DigestOff     = "off"
+	DigestWeekly  = "weekly"
+	DigestMonthly = "monthly"
+)
+
+// PushPreference holds the per-event push toggles of a user (all enabled by default) and the
+// email digest they opted into (off by default)
+type PushPreference struct {
+	UserID            uint       `json:"user_id" gorm:"primaryKey"`
+	QRExpiring        bool       `json:"qr_expiring" gorm:"not null;default:true"`
+	AnalysisCompleted bool       `json:"analysis_completed" gorm:"not null;default:true"`
+	PaymentConfirmed  bool       `json:"payment_confirmed" gorm:"not null;default:true"`
+	EmailDigest       string     `json:"email_digest" gorm:"type:varchar(10);not null;default:'off';index"`
+	DigestSentAt      *time.Time `json:"digest_sent_at" gorm:"default:null"` // the last digest covers the analyses up to here
+	UpdatedAt         time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName specifies the table name for PushPreference
+func (PushPreference) TableName() string {
+	return "push_preferences"
+}
+
+// Allows reports whether the user wants pushes for the given notification type
+func (p PushPreference) Allows(notificationType string) bool {
+	switch notificationType {
+	case NotificationQRExpiring:
+		return p.QRExpiring
+	case NotificationAnalysisCompleted:
+		return p.AnalysisCompleted
+	case NotificationPaymentPaid:
+		return p.PaymentConfirmed
+	default:
+		return false
+	}
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+)
+
+// Refund states. A pending refund was accepted by the gateway and is still being processed, or its
+// request got no answer and has to be retried under the same reference.
+const (
+	RefundPending   = "pending"
+	RefundSucceeded = "succeeded"
+	RefundFailed    = "failed"
+)
+
+// Refund is the repayment of a paid transaction. A transaction is marked refunded as soon as the
+// gateway accepts its refund; a refund that fails afterwards can be retried.
+type Refund struct {
+	ID              uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	TransactionID   int        `json:"transaction_id" gorm:"not null;index"`
+	ExternalID      string     `json:"external_id" gorm:"size:255;not null;index"` // of the transaction
+	Gateway         string     `json:"gateway" gorm:"size:20;not null"`
+	GatewayRefundID string     `json:"gateway_refund_id" gorm:"size:100;index"`
+	Reference       string     `json:"reference" gorm:"size:255;index"` // reference_id and idempotency key sent to the gateway
+	Amount          float64    `json:"amount" gorm:"not null"`
+	Currency        string     `json:"currency" gorm:"size:3;default:IDR"`
+	Reason          string     `json:"reason" gorm:"size:50"` // Xendit reason code
+	Note            string     `json:"note" gorm:"size:500"`
+	Status          string     `json:"status" gorm:"size:20;not null;default:pending"`
+	FailureCode     string     `json:"failure_code,omitempty" gorm:"size:100"`
+	LastError       string     `json:"last_error,omitempty" gorm:"type:text"`
+	AdminID         uint       `json:"admin_id"`
+	CompletedAt     *time.Time `json:"completed_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for Refund
+func (Refund) TableName() string {
+	return "refunds"
+}
+
+// Live reports whether the refund was accepted and has not failed
+func (r *Refund) Live() bool {
+	return r.GatewayRefundID != "" && (r.Status == RefundPending || r.Status == RefundSucceeded)
+}
+
+// Unconfirmed reports whether the refund was requested but the gateway's answer never arrived, so
+// it may or may not exist there
+func (r *Refund) Unconfirmed() bool {
+	return r.Status == RefundPending && r.GatewayRefundID == ""
+}
+
+// RefundRequest is the body of POST /api/admin/payments/{external_id}/refund
+type RefundRequest struct {
+	Reason string `json:"reason"` // Xendit reason code, default REQUESTED_BY_CUSTOMER
+	Note   string `json:"note"`
+}
+
+// XenditRefundRequest is the body of POST /refunds
+type XenditRefundRequest struct {
+	InvoiceID   string            `json:"invoice_id"`
+	ReferenceID string            `json:"reference_id"`
+	Amount      float64           `json:"amount"`
+	Currency    string            `json:"currency"`
+	Reason      string            `json:"reason"` // REQUESTED_BY_CUSTOMER, CANCELLATION, DUPLICATE, FRAUDULENT or OTHERS
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+type XenditRefundResponse struct {
+	ID          string  `json:"id"`
+	InvoiceID   string  `json:"invoice_id"`
+	ReferenceID string  `json:"reference_id"`
+	Amount      float64 `json:"amount"`
+	Currency    string  `json:"currency"`
+	Status      string  `json:"status"` // PENDING, SUCCEEDED or FAILED
+	Reason      string  `json:"reason"`
+	FailureCode string  `json:"failure_code"`
+}
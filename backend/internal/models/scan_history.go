@@ -6,21 +6,30 @@ import (
 	"gorm.io/gorm"
 )
 
+// Scan triggers
+const (
+	ScanTriggerManual    = "manual"    // user clicked Analyze
+	ScanTriggerAuto      = "auto"      // ran on contact sync completion for an already paid number
+	ScanTriggerBulk      = "bulk"      // part of an organization bulk scan
+	ScanTriggerScheduled = "scheduled" // periodic re-scan of the user's scan schedule
+)
+
 // ScanHistory represents a scan operation history for a user
 type ScanHistory struct {
 	ID          uint           `json:"id" gorm:"primaryKey;autoIncrement"`
 	UserID      uint           `json:"user_id" gorm:"not null"`
-	PhoneNumber string         `json:"phone_number" gorm:"size:20;not null"`
+	PhoneNumber string         `json:"phone_number" gorm:"size:255;not null;serializer:encrypted"`
 	ScanDate    time.Time      `json:"scan_date" gorm:"autoCreateTime"`
 	Status      string         `json:"status" gorm:"type:varchar(20);default:'pending';check:status IN ('success','failed','pending')"`
 	ResultData  string         `json:"result_data" gorm:"type:text"` // JSON string of scan results
 	ErrorMsg    string         `json:"error_msg" gorm:"size:500"`
+	Trigger     string         `json:"trigger" gorm:"column:scan_trigger;size:20;default:'manual'"`
 	CreatedAt   time.Time      `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt   time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
 
-	// Relationship
-	User User `json:"user" gorm:"foreignKey:UserID"`
+	// Relationship, never serialized so the owner's email and phone number cannot leak with a scan
+	User User `json:"-" gorm:"foreignKey:UserID"`
 }
 
 // TableName specifies the table name for ScanHistory
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+)
+
+// Scan schedule frequencies
+const (
+	ScanFrequencyWeekly  = "weekly"
+	ScanFrequencyMonthly = "monthly"
+)
+
+// ScanSchedule is a user's automatic re-analysis of their connected number, managed through
+// /api/wa/schedule. The scheduler queues an analysis job once NextRunAt has passed and records
+// the outcome of the last run; runs of unpaid or disconnected numbers are skipped, not retried.
+type ScanSchedule struct {
+	ID        uint   `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID    uint   `json:"user_id" gorm:"uniqueIndex;not null"`
+	Enabled   bool   `json:"enabled" gorm:"not null;default:true;index"`
+	Frequency string `json:"frequency" gorm:"type:varchar(10);not null"`
+	// Weekday (0 = Sunday) of weekly runs, DayOfMonth (1-28) of monthly runs, and the hour of
+	// either, in the scheduler time zone (SCAN_SCHEDULE_TIMEZONE)
+	Weekday      int  `json:"weekday"`
+	DayOfMonth   int  `json:"day_of_month"`
+	Hour         int  `json:"hour"`
+	EmailSummary bool `json:"email_summary" gorm:"not null;default:false"`
+
+	NextRunAt      time.Time  `json:"next_run_at" gorm:"index"`
+	LastRunAt      *time.Time `json:"last_run_at,omitempty" gorm:"default:null"`
+	LastStatus     string     `json:"last_status,omitempty" gorm:"size:20"` // analysis job status of the last run
+	LastError      string     `json:"last_error,omitempty" gorm:"size:500"`
+	LastJobID      string     `json:"last_job_id,omitempty" gorm:"size:32"`
+	LastAnalysisID *uint      `json:"last_analysis_id,omitempty" gorm:"default:null"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName specifies the table name for ScanSchedule
+func (ScanSchedule) TableName() string {
+	return "scan_schedules"
+}
+
+// NextRun returns the first scheduled time strictly after `after`, in loc
+func (s *ScanSchedule) NextRun(after time.Time, loc *time.Location) time.Time {
+	local := after.In(loc)
+	if s.Frequency == ScanFrequencyMonthly {
+		next := time.Date(local.Year(), local.Month(), s.DayOfMonth, s.Hour, 0, 0, 0, loc)
+		if !next.After(local) {
+			next = next.AddDate(0, 1, 0)
+		}
+		return next
+	}
+
+	next := time.Date(local.Year(), local.Month(), local.Day(), s.Hour, 0, 0, 0, loc)
+	next = next.AddDate(0, 0, (s.Weekday-int(local.Weekday())+7)%7)
+	if !next.After(local) {
+		next = next.AddDate(0, 0, 7)
+	}
+	return next
+}
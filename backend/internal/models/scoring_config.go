@@ -0,0 +1,127 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Scoring parameter keys, in the order they are evaluated and listed in the summary
+const (
+	ScoreTotalChats           = "total_chats"
+	ScoreTotalContacts        = "total_contacts"
+	ScoreAccountAgeDays       = "account_age_days"
+	ScoreTotalGroups          = "total_groups"
+	ScoreTotalChatWithContact = "total_chat_with_contact"
+	ScoreSensitiveContent     = "sensitive_content_count"
+	ScoreTotalUnsavedChats    = "total_unsaved_chats"
+	ScoreUnknownNumberChats   = "unknown_number_chats"
+)
+
+// ScoringParameter holds the thresholds of one indicator. For higher-is-better parameters a
+// value >= Good is "Baik" and >= Fair is "Cukup"; with LowerIsBetter it is <= Good and <= Fair.
+type ScoringParameter struct {
+	Key           string  `json:"key"`
+	Label         string  `json:"label"`
+	Good          int     `json:"good"`
+	Fair          int     `json:"fair"`
+	LowerIsBetter bool    `json:"lower_is_better"`
+	Weight        float64 `json:"weight"`
+}
+
+// ScoringConfig is the tunable indicator table behind CalculateStrength. The latest row is
+// the active one, older rows are the archive of earlier versions; GoodAverage and FairAverage are the weighted average score cutoffs (1-3)
+// for an overall "Baik" and "Cukup".
+type ScoringConfig struct {
+	ID             uint               `json:"id" gorm:"primaryKey;autoIncrement"`
+	GoodAverage    float64            `json:"good_average" gorm:"not null"`
+	FairAverage    float64            `json:"fair_average" gorm:"not null"`
+	ParametersJSON string             `json:"-" gorm:"column:parameters;type:text;not null"`
+	Parameters     []ScoringParameter `json:"parameters" gorm:"-"`
+	UpdatedBy      *uint              `json:"updated_by,omitempty" gorm:"default:null"`
+	CreatedAt      time.Time          `json:"created_at" gorm:"autoCreateTime"`           // in effect from
+	EffectiveTo    *time.Time         `json:"effective_to,omitempty" gorm:"default:null"` // set when a newer version replaced it
+}
+
+// TableName specifies the table name for ScoringConfig
+func (ScoringConfig) TableName() string {
+	return "scoring_configs"
+}
+
+// DefaultScoringConfig returns the original indicator table, used until an admin saves a configuration
+func DefaultScoringConfig() *ScoringConfig {
+	return &ScoringConfig{
+		GoodAverage: 2.5,
+		FairAverage: 1.5,
+		Parameters: []ScoringParameter{
+			{Key: ScoreTotalChats, Label: "Total Chats", Good: 100, Fair: 40, Weight: 1},
+			{Key: ScoreTotalContacts, Label: "Total Kontak", Good: 200, Fair: 100, Weight: 1},
+			{Key: ScoreAccountAgeDays, Label: "Umur Akun", Good: 365, Fair: 90, Weight: 1},
+			{Key: ScoreTotalGroups, Label: "Total Grup", Good: 80, Fair: 30, Weight: 1},
+			{Key: ScoreTotalChatWithContact, Label: "Chat dengan Kontak", Good: 100, Fair: 30, Weight: 1},
+			{Key: ScoreSensitiveContent, Label: "Sensitivitas Chat", Good: 5, Fair: 10, LowerIsBetter: true, Weight: 1},
+			{Key: ScoreTotalUnsavedChats, Label: "Uninterested Chat", Good: 100, Fair: 500, LowerIsBetter: true, Weight: 1},
+			{Key: ScoreUnknownNumberChats, Label: "Chat tidak dikenal", Good: 15, Fair: 30, LowerIsBetter: true, Weight: 1},
+		},
+	}
+}
+
+// EncodeParameters stores Parameters in ParametersJSON before saving
+func (c *ScoringConfig) EncodeParameters() error {
+	encoded, err := json.Marshal(c.Parameters)
+	if err != nil {
+		return err
+	}
+	c.ParametersJSON = string(encoded)
+	return nil
+}
+
+// DecodeParameters fills Parameters from ParametersJSON after loading
+func (c *ScoringConfig) DecodeParameters() error {
+	return json.Unmarshal([]byte(c.ParametersJSON), &c.Parameters)
+}
+
+// Validate checks that every indicator is configured exactly once with consistent thresholds
+func (c *ScoringConfig) Validate() error {
+	if c.FairAverage < 1 || c.GoodAverage > 3 || c.FairAverage >= c.GoodAverage {
+		return fmt.Errorf("averages must satisfy 1 <= fair_average < good_average <= 3")
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range c.Parameters {
+		if seen[p.Key] {
+			return fmt.Errorf("parameter %q is configured twice", p.Key)
+		}
+		seen[p.Key] = true
+		if p.Weight <= 0 {
+			return fmt.Errorf("parameter %q: weight must be positive", p.Key)
+		}
+		if p.LowerIsBetter && p.Good > p.Fair || !p.LowerIsBetter && p.Good < p.Fair {
+			return fmt.Errorf("parameter %q: good threshold must be stricter than fair", p.Key)
+		}
+	}
+	for _, p := range DefaultScoringConfig().Parameters {
+		if !seen[p.Key] {
+			return fmt.Errorf("parameter %q is missing", p.Key)
+		}
+	}
+	if len(seen) != len(DefaultScoringConfig().Parameters) {
+		return fmt.Errorf("unknown scoring parameter")
+	}
+	return nil
+}
+
+// Evaluate rates one parameter value: "Baik" scores 3, "Cukup" 2 and "Buruk" 1
+func (p ScoringParameter) Evaluate(value int) ParameterEvaluation {
+	good, fair := value >= p.Good, value >= p.Fair
+	if p.LowerIsBetter {
+		good, fair = value <= p.Good, value <= p.Fair
+	}
+	switch {
+	case good:
+		return ParameterEvaluation{Key: p.Key, Parameter: p.Label, Value: value, Status: "Baik", Score: 3}
+	case fair:
+		return ParameterEvaluation{Key: p.Key, Parameter: p.Label, Value: value, Status: "Cukup", Score: 2}
+	}
+	return ParameterEvaluation{Key: p.Key, Parameter: p.Label, Value: value, Status: "Buruk", Score: 1}
+}
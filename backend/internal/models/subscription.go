@@ -0,0 +1,102 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Subscription statuses. An active subscription past its EndsAt is reported as expired.
+const (
+	SubscriptionPending   = "pending" // waiting for its payment
+	SubscriptionActive    = "active"
+	SubscriptionExpired   = "expired"
+	SubscriptionCancelled = "cancelled"
+)
+
+// Plan is a subscription offer: ScansPerMonth analyses of any phone number per monthly cycle,
+// for Months months per payment
+type Plan struct {
+	ID            uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	Code          string    `json:"code" gorm:"size:50;uniqueIndex;not null"`
+	Name          string    `json:"name" gorm:"size:100;not null"`
+	Description   string    `json:"description" gorm:"type:text"`
+	Price         float64   `json:"price" gorm:"not null"`
+	Currency      string    `json:"currency" gorm:"size:3;default:IDR"`
+	ScansPerMonth int       `json:"scans_per_month" gorm:"not null"` // 0 = unlimited
+	Months        int       `json:"months" gorm:"not null;default:1"`
+	IsActive      bool      `json:"is_active" gorm:"not null"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for Plan
+func (Plan) TableName() string {
+	return "plans"
+}
+
+// Validate normalizes the code and checks price, quota and duration
+func (p *Plan) Validate() error {
+	p.Code = strings.ToLower(strings.TrimSpace(p.Code))
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Currency == "" {
+		p.Currency = "IDR"
+	}
+	if p.Months == 0 {
+		p.Months = 1
+	}
+	switch {
+	case p.Code == "" || len(p.Code) > 50:
+		return fmt.Errorf("code is required (max 50 characters)")
+	case p.Name == "" || len(p.Name) > 100:
+		return fmt.Errorf("name is required (max 100 characters)")
+	case p.Price < 1000:
+		return fmt.Errorf("price must be at least 1000")
+	case p.ScansPerMonth < 0:
+		return fmt.Errorf("scans_per_month cannot be negative")
+	case p.Months < 1 || p.Months > 24:
+		return fmt.Errorf("months must be between 1 and 24")
+	}
+	return nil
+}
+
+// Subscription is one paid period of a plan. The quota is copied from the plan so later plan
+// changes do not affect subscriptions already bought.
+type Subscription struct {
+	ID            uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID        uint       `json:"user_id" gorm:"not null;index"`
+	PlanID        uint       `json:"plan_id" gorm:"not null;index"`
+	Status        string     `json:"status" gorm:"size:20;not null;index"`
+	ScansPerMonth int        `json:"scans_per_month" gorm:"not null"`
+	Months        int        `json:"months" gorm:"not null"`
+	StartsAt      *time.Time `json:"starts_at"` // set on activation; later than now when queued after a running subscription
+	EndsAt        *time.Time `json:"ends_at" gorm:"index"`
+	CycleStart    *time.Time `json:"cycle_start"` // start of the monthly cycle ScansUsed counts
+	ScansUsed     int        `json:"scans_used" gorm:"not null;default:0"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for Subscription
+func (Subscription) TableName() string {
+	return "subscriptions"
+}
+
+// SubscriptionStatus is the user-facing state of the subscription covering now
+type SubscriptionStatus struct {
+	ID             uint       `json:"id"`
+	PlanCode       string     `json:"plan_code"`
+	PlanName       string     `json:"plan_name"`
+	Status         string     `json:"status"`
+	StartsAt       *time.Time `json:"starts_at"`
+	EndsAt         *time.Time `json:"ends_at"`
+	ScansPerMonth  int        `json:"scans_per_month"` // 0 = unlimited
+	ScansUsed      int        `json:"scans_used"`
+	ScansRemaining *int       `json:"scans_remaining"` // nil = unlimited
+	CycleEndsAt    *time.Time `json:"cycle_ends_at"`
+}
+
+// HasScansLeft reports whether the current cycle still allows an analysis
+func (s *SubscriptionStatus) HasScansLeft() bool {
+	return s.ScansRemaining == nil || *s.ScansRemaining > 0
+}
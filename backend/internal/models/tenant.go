@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Tenant represents a white-label partner reselling the analysis under its own brand
+type Tenant struct {
+	ID              uint   `json:"id" gorm:"primaryKey;autoIncrement"`
+	Slug            string `json:"slug" gorm:"uniqueIndex;size:50;not null"`
+	BrandName       string `json:"brand_name" gorm:"size:100;not null"`
+	LogoURL         string `json:"logo_url" gorm:"size:500"`
+	FrontendBaseURL string `json:"frontend_base_url" gorm:"size:255"`
+	// Host is matched against the request Host header (without port), e.g. "scan.partner.co.id"
+	Host string `json:"host" gorm:"size:255;index"`
+	// APIKeyHash is the hex SHA-256 of the partner API key sent in X-API-Key
+	APIKeyHash string `json:"-" gorm:"size:64;index"`
+	// OwnerUserID is the member allowed to run organization-wide operations (bulk scans)
+	OwnerUserID *uint `json:"owner_user_id" gorm:"index;default:null"`
+
+	// Email sender identity
+	EmailFromName    string `json:"email_from_name" gorm:"size:100"`
+	EmailFromAddress string `json:"email_from_address" gorm:"size:100"`
+
+	// Optional custom Xendit credentials, fall back to global keys when empty
+	XenditSecretKey    string `json:"-" gorm:"size:255"`
+	XenditWebhookToken string `json:"-" gorm:"size:255"`
+
+	IsActive  bool           `json:"is_active" gorm:"default:true"`
+	CreatedAt time.Time      `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// TenantBranding is the public subset of a tenant exposed to the frontend
+type TenantBranding struct {
+	Slug            string `json:"slug"`
+	BrandName       string `json:"brand_name"`
+	LogoURL         string `json:"logo_url"`
+	FrontendBaseURL string `json:"frontend_base_url"`
+}
+
+// TableName specifies the table name for Tenant
+func (Tenant) TableName() string {
+	return "tenants"
+}
+
+// Branding returns the public branding information of the tenant
+func (t *Tenant) Branding() TenantBranding {
+	return TenantBranding{
+		Slug:            t.Slug,
+		BrandName:       t.BrandName,
+		LogoURL:         t.LogoURL,
+		FrontendBaseURL: t.FrontendBaseURL,
+	}
+}
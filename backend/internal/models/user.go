@@ -9,23 +9,38 @@ import (
 // User represents a user account
 type User struct {
 	ID              uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	TenantID        *uint      `json:"tenant_id" gorm:"index"` // nil = default brand
 	Username        string     `json:"username" gorm:"uniqueIndex;size:50;not null"`
 	Email           string     `json:"email" gorm:"uniqueIndex;size:100;not null"`
 	PasswordHash    string     `json:"-" gorm:"size:255;not null"` // "-" means don't include in JSON
-	PhoneNumber     string     `json:"phone_number" gorm:"size:20;not null"`
+	PhoneNumber     string     `json:"phone_number" gorm:"size:255;not null;serializer:encrypted"`
+	PhoneNumberHash string     `json:"-" gorm:"size:64;index"` // blind index for lookups by phone number
 	Role            string     `json:"role" gorm:"type:varchar(20);default:'user';check:role IN ('admin','user')"`
 	IsActive        bool       `json:"is_active" gorm:"default:true"`
 	EmailVerified   bool       `json:"email_verified" gorm:"default:false"`
 	EmailVerifiedAt *time.Time `json:"email_verified_at" gorm:"default:null"`
 
 	// OTP fields
-	OTPCode      string     `json:"-" gorm:"size:10;default:null"`
+	OTPCode      string     `json:"-" gorm:"size:255;default:null;serializer:encrypted"`
 	OTPExpiresAt *time.Time `json:"-" gorm:"default:null"`
+	OTPChannel   string     `json:"otp_channel" gorm:"size:20;default:null"` // email, whatsapp or sms; empty = OTP_CHANNEL
 
 	// Password reset token fields
-	ResetToken          string     `json:"-" gorm:"size:255;default:null"`
+	ResetToken          string     `json:"-" gorm:"size:255;default:null;serializer:encrypted"`
 	ResetTokenExpiresAt *time.Time `json:"-" gorm:"default:null"`
 
+	// Failed-login tracking: login is refused until LoginBlockedUntil (backoff, or lockout when LockedAt is set)
+	FailedLoginCount     int        `json:"failed_login_count" gorm:"default:0"`
+	LoginBlockedUntil    *time.Time `json:"login_blocked_until" gorm:"default:null"`
+	LockedAt             *time.Time `json:"locked_at" gorm:"default:null"`
+	UnlockToken          string     `json:"-" gorm:"size:255;default:null;serializer:encrypted"`
+	UnlockTokenExpiresAt *time.Time `json:"-" gorm:"default:null"`
+
+	// Inactivity tracking for the retention job
+	LastLoginAt        *time.Time `json:"last_login_at" gorm:"default:null"`
+	InactivityWarnedAt *time.Time `json:"-" gorm:"default:null"`
+	AnonymizedAt       *time.Time `json:"-" gorm:"default:null;index"`
+
 	CreatedAt time.Time      `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
@@ -40,6 +55,8 @@ type User struct {
 type UserLogin struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=6"`
+	// Scopes requested for this login context (e.g. ["wa"] for the scanner app); empty = wa + payments
+	Scopes []string `json:"scopes,omitempty"`
 }
 
 // UserRegister represents registration request
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// UserGoal is what a user is working towards: an overall strength, target values for scoring
+// parameters, or both. Progress is measured from the latest analysis at the time the goal was set.
+type UserGoal struct {
+	ID                 uint            `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID             uint            `json:"user_id" gorm:"not null;uniqueIndex"`
+	TargetStrength     string          `json:"target_strength,omitempty" gorm:"size:10"` // "Baik" or "Cukup", empty = no strength goal
+	ParameterGoals     []ParameterGoal `json:"parameter_goals" gorm:"type:text;serializer:json"`
+	BaselineAnalysisID *uint           `json:"baseline_analysis_id" gorm:"default:null"` // nil when the user had no analysis yet
+	AchievedAt         *time.Time      `json:"achieved_at" gorm:"default:null"`          // first analysis meeting every target
+	CreatedAt          time.Time       `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt          time.Time       `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// ParameterGoal is a target value for one scoring parameter (ScoreTotalContacts, ...). For
+// lower-is-better parameters the value must drop to Target or below, otherwise reach it.
+type ParameterGoal struct {
+	Key    string `json:"key"`
+	Target int    `json:"target"`
+}
+
+// TableName specifies the table name for UserGoal
+func (UserGoal) TableName() string {
+	return "user_goals"
+}
+
+// ParameterValue returns the measured value of a scoring parameter
+func (r *AnalysisResult) ParameterValue(key string) (int, bool) {
+	switch key {
+	case ScoreTotalChats:
+		return r.TotalChats, true
+	case ScoreTotalContacts:
+		return r.TotalContacts, true
+	case ScoreAccountAgeDays:
+		return r.AccountAgeDays, true
+	case ScoreTotalGroups:
+		return r.TotalGroups, true
+	case ScoreTotalChatWithContact:
+		return r.TotalChatWithContact, true
+	case ScoreSensitiveContent:
+		return r.SensitiveContentCount, true
+	case ScoreTotalUnsavedChats:
+		return r.TotalUnsavedChats, true
+	case ScoreUnknownNumberChats:
+		return r.UnknownNumberChats, true
+	}
+	return 0, false
+}
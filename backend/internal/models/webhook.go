@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// Outbound webhook events
+const (
+	WebhookEventAnalysisCompleted = "analysis.completed"
+)
+
+// Webhook delivery states
+const (
+	WebhookDeliveryPending   = "pending" // waiting for its first or next attempt
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed" // gave up after the last attempt
+)
+
+// UserWebhook is the URL a user's integration is notified on. Every POST is signed with Secret.
+type UserWebhook struct {
+	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex"`
+	URL       string    `json:"url" gorm:"size:500;not null"`
+	Secret    string    `json:"-" gorm:"size:100;not null"`
+	Active    bool      `json:"active" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for UserWebhook
+func (UserWebhook) TableName() string {
+	return "user_webhooks"
+}
+
+// WebhookDelivery is one event sent (or being retried) to a user's webhook
+type WebhookDelivery struct {
+	ID            uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	WebhookID     uint       `json:"webhook_id" gorm:"not null;index"`
+	UserID        uint       `json:"user_id" gorm:"not null;index"`
+	Event         string     `json:"event" gorm:"size:50;not null"`
+	Payload       string     `json:"-" gorm:"type:text"`
+	Status        string     `json:"status" gorm:"size:20;not null;index"`
+	Attempts      int        `json:"attempts" gorm:"not null;default:0"`
+	ResponseCode  int        `json:"response_code"`
+	LastError     string     `json:"last_error,omitempty" gorm:"size:500"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty" gorm:"index"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for WebhookDelivery
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Inbound payment webhook event states
+const (
+	WebhookEventReceived  = "received"  // being applied
+	WebhookEventProcessed = "processed" // applied; redeliveries are skipped
+	WebhookEventFailed    = "failed"    // applying it failed; a redelivery or an admin reprocess retries it
+)
+
+// WebhookEvent is one payment gateway webhook as received, kept so redeliveries of an event that
+// was already applied are skipped and its raw payload is available for audit and reprocessing.
+type WebhookEvent struct {
+	ID          uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	Gateway     string     `json:"gateway" gorm:"size:20;not null;uniqueIndex:idx_webhook_events_event"`
+	EventID     string     `json:"event_id" gorm:"size:150;not null;uniqueIndex:idx_webhook_events_event"`
+	ExternalID  string     `json:"external_id" gorm:"size:100;index"`
+	Status      string     `json:"status" gorm:"size:30"` // status reported by the gateway
+	State       string     `json:"state" gorm:"size:20;not null;index"`
+	Attempts    int        `json:"attempts" gorm:"not null;default:0"`
+	Duplicates  int        `json:"duplicates" gorm:"not null;default:0"` // redeliveries skipped
+	LastError   string     `json:"last_error,omitempty" gorm:"size:500"`
+	Payload     string     `json:"payload,omitempty" gorm:"type:text"`
+	ProcessedAt *time.Time `json:"processed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for WebhookEvent
+func (WebhookEvent) TableName() string {
+	return "webhook_events"
+}
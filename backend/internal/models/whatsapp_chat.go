@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// WhatsAppChat is the metadata of one conversation seen on a user's linked device, collected from
+// history sync and live message events. Message contents are never stored, only the number of
+// messages matching each sensitive content category.
+type WhatsAppChat struct {
+	ID            uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID        uint       `json:"user_id" gorm:"not null;uniqueIndex:idx_whatsapp_chats_user_chat,priority:1"`
+	ChatJID       string     `json:"chat_jid" gorm:"size:100;not null;uniqueIndex:idx_whatsapp_chats_user_chat,priority:2"`
+	IsGroup       bool       `json:"is_group" gorm:"default:false"`
+	MessageCount  int        `json:"message_count" gorm:"default:0"`
+	LastMessageAt *time.Time `json:"last_message_at" gorm:"default:null"`
+
+	// Messages matching a sensitive content rule, only filled while the user consents to message scanning
+	SensitiveMessages int             `json:"sensitive_messages" gorm:"default:0"`
+	SensitiveCounts   SensitiveCounts `json:"sensitive_counts,omitempty" gorm:"type:text;serializer:json"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName specifies the table name for WhatsAppChat
+func (WhatsAppChat) TableName() string {
+	return "whatsapp_chats"
+}
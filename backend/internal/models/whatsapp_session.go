@@ -8,11 +8,12 @@ import (
 
 // WhatsAppSession represents a WhatsApp session for a specific user
 type WhatsAppSession struct {
-	ID           uint           `json:"id" gorm:"primaryKey;autoIncrement"`
-	UserID       uint           `json:"user_id" gorm:"not null"`
-	SessionData  string         `json:"session_data" gorm:"type:text"` // encrypted session data
-	QRCode       string         `json:"qr_code" gorm:"type:text"`
-	Status       string         `json:"status" gorm:"type:varchar(20);default:'disconnected';check:status IN ('connected','disconnected','scanning')"`
+	ID          uint   `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID      uint   `json:"user_id" gorm:"not null"`
+	SessionData string `json:"session_data" gorm:"type:text;serializer:encrypted"` // encrypted session data
+	// Only the expiry of the current QR is stored; the QR image itself never leaves memory
+	QRExpiresAt  *time.Time     `json:"qr_expires_at"`
+	Status       string         `json:"status" gorm:"type:varchar(20);default:'disconnected';check:chk_whatsapp_sessions_status_v2,status IN ('connected','disconnected','scanning','connecting','banned')"`
 	DeviceID     string         `json:"device_id" gorm:"size:100"`
 	LastActivity time.Time      `json:"last_activity" gorm:"autoUpdateTime"`
 	CreatedAt    time.Time      `json:"created_at" gorm:"autoCreateTime"`
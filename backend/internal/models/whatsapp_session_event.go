@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Session event types recorded for churn analytics
+const (
+	SessionEventBanned     = "banned"      // account banned or locked by WhatsApp
+	SessionEventTempBanned = "temp_banned" // temporarily restricted, see ExpiresAt
+)
+
+// WhatsAppSessionEvent records account-level WhatsApp events (bans, restrictions) per user
+type WhatsAppSessionEvent struct {
+	ID          uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID      uint       `json:"user_id" gorm:"not null;index"`
+	PhoneNumber string     `json:"phone_number" gorm:"size:255;serializer:encrypted"`
+	Type        string     `json:"type" gorm:"size:30;not null;index"`
+	Code        int        `json:"code"` // whatsmeow ban/connect failure code
+	Reason      string     `json:"reason" gorm:"size:255"`
+	ExpiresAt   *time.Time `json:"expires_at"` // end of a temporary ban
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime;index"`
+}
+
+// TableName specifies the table name for WhatsAppSessionEvent
+func (WhatsAppSessionEvent) TableName() string {
+	return "whatsapp_session_events"
+}
@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"back_wa/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// AccountErasure counts what Erase removed
+type AccountErasure struct {
+	AnalysesDeleted        int64
+	AnalysesKept           int64 // under legal hold
+	ScanHistoryDeleted     int64
+	TransactionsAnonymized int64
+}
+
+// AccountRepo runs the retention operations that span all tables of an account
+type AccountRepo interface {
+	// ListInactive returns up to limit non-admin, not yet anonymized accounts whose last activity
+	// (last login, or registration) is before cutoff and that have not been warned
+	ListInactive(ctx context.Context, cutoff time.Time, limit int) ([]models.User, error)
+	// ListWarnedInactive is ListInactive for accounts warned before warnedBefore
+	ListWarnedInactive(ctx context.Context, cutoff, warnedBefore time.Time, limit int) ([]models.User, error)
+	// MarkInactivityWarned stamps the start of the grace period
+	MarkInactivityWarned(ctx context.Context, userID uint, at time.Time) error
+	// Anonymize replaces the personal data of the account with placeholders, sets passwordHash,
+	// removes its device-level data and soft-deletes it, in one transaction
+	Anonymize(ctx context.Context, userID uint, passwordHash string, now time.Time) error
+	// Erase deletes the account's analyses (held ones survive), scan history, jobs, feedback,
+	// webhooks and session events, strips the phone number from its transactions, cancels its
+	// subscriptions and anonymizes it, in one transaction
+	Erase(ctx context.Context, userID uint, passwordHash string, now time.Time) (*AccountErasure, error)
+}
+
+type gormAccountRepo struct {
+	conn Conn
+}
+
+// NewAccountRepo creates a GORM-backed AccountRepo on conn (nil = DefaultConn)
+func NewAccountRepo(conn Conn) AccountRepo {
+	return &gormAccountRepo{conn: orDefault(conn)}
+}
+
+func (r *gormAccountRepo) dormant(ctx context.Context, cutoff time.Time) *gorm.DB {
+	return r.conn(ctx).Where("role <> ? AND anonymized_at IS NULL", "admin").
+		Where("COALESCE(last_login_at, created_at) < ?", cutoff)
+}
+
+func (r *gormAccountRepo) ListInactive(ctx context.Context, cutoff time.Time, limit int) ([]models.User, error) {
+	var users []models.User
+	err := r.dormant(ctx, cutoff).Where("inactivity_warned_at IS NULL").Limit(limit).Find(&users).Error
+	return users, err
+}
+
+func (r *gormAccountRepo) ListWarnedInactive(ctx context.Context, cutoff, warnedBefore time.Time, limit int) ([]models.User, error) {
+	var users []models.User
+	err := r.dormant(ctx, cutoff).Where("inactivity_warned_at < ?", warnedBefore).Limit(limit).Find(&users).Error
+	return users, err
+}
+
+func (r *gormAccountRepo) MarkInactivityWarned(ctx context.Context, userID uint, at time.Time) error {
+	return r.conn(ctx).Model(&models.User{}).Where("id = ?", userID).UpdateColumn("inactivity_warned_at", at).Error
+}
+
+func (r *gormAccountRepo) Anonymize(ctx context.Context, userID uint, passwordHash string, now time.Time) error {
+	return anonymizeUser(r.conn(ctx), userID, passwordHash, now)
+}
+
+func (r *gormAccountRepo) Erase(ctx context.Context, userID uint, passwordHash string, now time.Time) (*AccountErasure, error) {
+	erasure := &AccountErasure{}
+	err := r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		var analysisIDs []uint
+		if err := tx.Unscoped().Model(&models.AnalysisResult{}).Where("user_id = ?", userID).Pluck("id", &analysisIDs).Error; err != nil {
+			return err
+		}
+		res := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.AnalysisResult{})
+		if res.Error != nil {
+			return res.Error
+		}
+		erasure.AnalysesDeleted = res.RowsAffected
+		erasure.AnalysesKept = int64(len(analysisIDs)) - res.RowsAffected
+
+		// Breakdowns and scan history of held analyses stay with them
+		if len(analysisIDs) > 0 {
+			kept := tx.Unscoped().Model(&models.AnalysisResult{}).Select("id").Where("id IN ?", analysisIDs)
+			for _, model := range []interface{}{&models.AnalysisGroup{}, &models.AnalysisContact{}} {
+				if err := tx.Where("analysis_result_id IN ? AND analysis_result_id NOT IN (?)", analysisIDs, kept).Delete(model).Error; err != nil {
+					return err
+				}
+			}
+		}
+		referenced := tx.Unscoped().Model(&models.AnalysisResult{}).Select("scan_history_id").Where("user_id = ? AND scan_history_id IS NOT NULL", userID)
+		res = tx.Unscoped().Where("user_id = ? AND id NOT IN (?)", userID, referenced).Delete(&models.ScanHistory{})
+		if res.Error != nil {
+			return res.Error
+		}
+		erasure.ScanHistoryDeleted = res.RowsAffected
+
+		// Payments are kept for bookkeeping, without the number that was analyzed
+		res = tx.Model(&models.Transaction{}).Where("user_id = ?", userID).UpdateColumns(map[string]interface{}{
+			"phone_number":      "",
+			"phone_number_hash": "",
+			"invoice_url":       "",
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		erasure.TransactionsAnonymized = res.RowsAffected
+		if err := tx.Model(&models.Subscription{}).
+			Where("user_id = ? AND status IN ?", userID, []string{models.SubscriptionPending, models.SubscriptionActive}).
+			UpdateColumn("status", models.SubscriptionCancelled).Error; err != nil {
+			return err
+		}
+
+		for _, model := range []interface{}{
+			&models.AnalysisJob{},
+			&models.AnalysisFeedback{},
+			&models.UserWebhook{},
+			&models.WebhookDelivery{},
+			&models.WhatsAppSessionEvent{},
+		} {
+			if err := tx.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		// Personal data, device data, sessions and the WhatsApp session row
+		return anonymizeUser(tx, userID, passwordHash, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return erasure, nil
+}
+
+// anonymizeUser keeps analysis results and transactions (without contact details) for aggregate
+// statistics and bookkeeping and removes device-level data (chats, push tokens, notifications,
+// share links)
+func anonymizeUser(db *gorm.DB, userID uint, passwordHash string, now time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).UpdateColumns(map[string]interface{}{
+			"email":                   fmt.Sprintf("deleted-%d@anonymized.invalid", userID),
+			"username":                fmt.Sprintf("deleted_%d", userID),
+			"phone_number":            "",
+			"phone_number_hash":       "",
+			"password_hash":           passwordHash,
+			"otp_code":                nil,
+			"otp_expires_at":          nil,
+			"reset_token":             nil,
+			"reset_token_expires_at":  nil,
+			"unlock_token":            nil,
+			"unlock_token_expires_at": nil,
+			"is_active":               false,
+			"anonymized_at":           now,
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.ScanHistory{}).Where("user_id = ?", userID).UpdateColumn("phone_number", "").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("UPDATE analysis_groups SET name = '' WHERE analysis_result_id IN (SELECT id FROM analysis_results WHERE user_id = ?)", userID).Error; err != nil {
+			return err
+		}
+		// Sessions stay as revoked rows so outstanding tokens keep failing, without device details
+		if err := tx.Model(&models.AuthSession{}).Where("user_id = ?", userID).UpdateColumns(map[string]interface{}{
+			"device":     "",
+			"user_agent": "",
+			"ip_address": "",
+			"revoked_at": gorm.Expr("COALESCE(revoked_at, ?)", now),
+		}).Error; err != nil {
+			return err
+		}
+
+		for _, model := range []interface{}{
+			&models.WhatsAppChat{},
+			&models.MessageScanConsent{},
+			&models.ScanSchedule{},
+			&models.PushToken{},
+			&models.PushPreference{},
+			&models.Notification{},
+			&models.AnalysisShareLink{},
+			&models.UserGoal{},
+		} {
+			if err := tx.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.WhatsAppSession{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&models.User{}, userID).Error
+	})
+}
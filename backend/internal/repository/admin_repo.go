@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"back_wa/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// UserFilter narrows the admin user listing. Zero values leave it unrestricted.
+type UserFilter struct {
+	Search      string   // matched against username and email
+	PhoneHashes []string // blind indexes the phone number may match as well
+	Role        string
+	Active      *bool
+}
+
+// SessionListing is a whatsapp_sessions row without the stored session data
+type SessionListing struct {
+	ID           uint      `json:"id"`
+	UserID       uint      `json:"user_id"`
+	Username     string    `json:"username"`
+	Status       string    `json:"status"`
+	DeviceID     string    `json:"device_id"`
+	LastActivity time.Time `json:"last_activity"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// AdminRepo reads the cross-user listings of the admin panel, newest first.
+// Every listing returns one page (query.Limit/Offset) and the total matching the filter.
+type AdminRepo interface {
+	ListUsers(ctx context.Context, filter UserFilter, query ListQuery) ([]models.User, int64, error)
+	// ListTransactions returns transactions of all users, with status and of userID only unless empty/0
+	ListTransactions(ctx context.Context, status string, userID int, query ListQuery) ([]models.Transaction, int64, error)
+	// ListUserAnalyses returns the user's analysis results with the user preloaded
+	ListUserAnalyses(ctx context.Context, userID uint, query ListQuery) ([]models.AnalysisResult, int64, error)
+	// ListSessions returns stored WhatsApp sessions with status (empty = all), most recently active first
+	ListSessions(ctx context.Context, status string, query ListQuery) ([]SessionListing, int64, error)
+}
+
+type gormAdminRepo struct {
+	conn Conn
+}
+
+// NewAdminRepo creates a GORM-backed AdminRepo on conn (nil = DefaultConn)
+func NewAdminRepo(conn Conn) AdminRepo {
+	return &gormAdminRepo{conn: orDefault(conn)}
+}
+
+func (r *gormAdminRepo) ListUsers(ctx context.Context, filter UserFilter, query ListQuery) ([]models.User, int64, error) {
+	db := r.conn(ctx).Model(&models.User{})
+	if filter.Search != "" {
+		like := "%" + filter.Search + "%"
+		if len(filter.PhoneHashes) > 0 {
+			db = db.Where("username LIKE ? OR email LIKE ? OR phone_number_hash IN ?", like, like, filter.PhoneHashes)
+		} else {
+			db = db.Where("username LIKE ? OR email LIKE ?", like, like)
+		}
+	}
+	if filter.Role != "" {
+		db = db.Where("role = ?", filter.Role)
+	}
+	if filter.Active != nil {
+		db = db.Where("is_active = ?", *filter.Active)
+	}
+
+	var users []models.User
+	total, err := countAndPage(db, query, "id DESC", &users)
+	return users, total, err
+}
+
+func (r *gormAdminRepo) ListTransactions(ctx context.Context, status string, userID int, query ListQuery) ([]models.Transaction, int64, error) {
+	db := r.conn(ctx).Model(&models.Transaction{})
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+	if userID != 0 {
+		db = db.Where("user_id = ?", userID)
+	}
+
+	var transactions []models.Transaction
+	total, err := countAndPage(db, query, "id DESC", &transactions)
+	return transactions, total, err
+}
+
+func (r *gormAdminRepo) ListUserAnalyses(ctx context.Context, userID uint, query ListQuery) ([]models.AnalysisResult, int64, error) {
+	db := r.conn(ctx).Model(&models.AnalysisResult{}).Where("user_id = ?", userID)
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var results []models.AnalysisResult
+	if err := query.page(db.Preload("User").Order("id DESC")).Find(&results).Error; err != nil {
+		return nil, 0, err
+	}
+	return results, total, nil
+}
+
+func (r *gormAdminRepo) ListSessions(ctx context.Context, status string, query ListQuery) ([]SessionListing, int64, error) {
+	db := r.conn(ctx).Table("whatsapp_sessions").
+		Joins("LEFT JOIN users ON users.id = whatsapp_sessions.user_id").
+		Where("whatsapp_sessions.deleted_at IS NULL")
+	if status != "" {
+		db = db.Where("whatsapp_sessions.status = ?", status)
+	}
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var sessions []SessionListing
+	err := query.page(db.Order("whatsapp_sessions.last_activity DESC")).
+		Select("whatsapp_sessions.id, whatsapp_sessions.user_id, COALESCE(users.username, '') AS username, whatsapp_sessions.status, " +
+			"whatsapp_sessions.device_id, whatsapp_sessions.last_activity, whatsapp_sessions.created_at").
+		Scan(&sessions).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return sessions, total, nil
+}
+
+// countAndPage counts the rows of db and loads one page of them in order into dest
+func countAndPage(db *gorm.DB, query ListQuery, order string, dest interface{}) (int64, error) {
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return 0, err
+	}
+	if err := query.page(db.Order(order)).Find(dest).Error; err != nil {
+		return 0, err
+	}
+	return total, nil
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"back_wa/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// AnalysisJobRepo stores async analysis jobs (analysis_jobs)
+type AnalysisJobRepo interface {
+	Create(ctx context.Context, job *models.AnalysisJob) error
+	Update(ctx context.Context, jobID string, columns map[string]interface{}) error
+	FindForUser(ctx context.Context, jobID string, userID uint) (*models.AnalysisJob, error)
+	// ActiveForUser returns the user's queued or running job, or nil
+	ActiveForUser(ctx context.Context, userID uint) (*models.AnalysisJob, error)
+	// ListUnfinished returns every queued or running job, oldest first
+	ListUnfinished(ctx context.Context) ([]models.AnalysisJob, error)
+}
+
+type gormAnalysisJobRepo struct {
+	conn Conn
+}
+
+// NewAnalysisJobRepo creates a GORM-backed AnalysisJobRepo on conn (nil = DefaultConn)
+func NewAnalysisJobRepo(conn Conn) AnalysisJobRepo {
+	return &gormAnalysisJobRepo{conn: orDefault(conn)}
+}
+
+func (r *gormAnalysisJobRepo) Create(ctx context.Context, job *models.AnalysisJob) error {
+	return r.conn(ctx).Create(job).Error
+}
+
+func (r *gormAnalysisJobRepo) Update(ctx context.Context, jobID string, columns map[string]interface{}) error {
+	return r.conn(ctx).Model(&models.AnalysisJob{}).Where("job_id = ?", jobID).Updates(columns).Error
+}
+
+func (r *gormAnalysisJobRepo) FindForUser(ctx context.Context, jobID string, userID uint) (*models.AnalysisJob, error) {
+	var job models.AnalysisJob
+	if err := r.conn(ctx).Where("job_id = ? AND user_id = ?", jobID, userID).First(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *gormAnalysisJobRepo) ActiveForUser(ctx context.Context, userID uint) (*models.AnalysisJob, error) {
+	var job models.AnalysisJob
+	err := r.conn(ctx).Where("user_id = ? AND status IN ?", userID, []string{models.AnalysisJobQueued, models.AnalysisJobRunning}).
+		Order("id DESC").First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *gormAnalysisJobRepo) ListUnfinished(ctx context.Context) ([]models.AnalysisJob, error) {
+	var jobs []models.AnalysisJob
+	err := r.conn(ctx).Where("status IN ?", []string{models.AnalysisJobQueued, models.AnalysisJobRunning}).
+		Order("id ASC").Find(&jobs).Error
+	return jobs, err
+}
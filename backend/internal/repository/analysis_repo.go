@@ -0,0 +1,366 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"back_wa/internal/database"
+	"back_wa/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// HistoryRow is one analysis in a user's history, joined with the scanned phone number
+type HistoryRow struct {
+	ID          uint      `json:"id"`
+	PhoneNumber string    `json:"phone_number" gorm:"serializer:encrypted"` // scan_history.phone_number
+	ScanDate    time.Time `json:"scan_date"`
+	Strength    string    `json:"strength"`
+	Checksum    string    `json:"checksum"`
+	Trigger     string    `json:"trigger" gorm:"column:scan_trigger"` // manual, auto, bulk or scheduled
+	// Scoring rules version the result was scored with, nil for results from before it was recorded
+	ScoringVersion *uint `json:"scoring_version"`
+	// Pinned results are listed first
+	Pinned bool   `json:"pinned"`
+	Label  string `json:"label"`
+}
+
+// AnalysisRepo stores analysis results, their breakdowns and scan history
+type AnalysisRepo interface {
+	// Transaction runs fn with a repository bound to one database transaction
+	Transaction(ctx context.Context, fn func(tx AnalysisRepo) error) error
+
+	Create(ctx context.Context, result *models.AnalysisResult) error
+	// CreateBreakdowns inserts group/contact breakdown rows batchSize at a time
+	CreateBreakdowns(ctx context.Context, groups []models.AnalysisGroup, contacts []models.AnalysisContact, batchSize int) error
+	UpdateColumns(ctx context.Context, result *models.AnalysisResult, columns map[string]interface{}) error
+
+	ListByUser(ctx context.Context, userID uint) ([]models.AnalysisResult, error)
+	// EachHistory streams the user's history matching query (default order: pinned, then newest
+	// first) to fn one row at a time
+	EachHistory(ctx context.Context, userID uint, query HistoryQuery, fn func(HistoryRow) error) error
+	// CountHistory counts the user's history matching query, ignoring limit and offset
+	CountHistory(ctx context.Context, userID uint, query HistoryQuery) (int64, error)
+	// CountByUser counts the user's stored analyses
+	CountByUser(ctx context.Context, userID uint) (int64, error)
+	// CountScansSince counts the user's analyses scanned at or after start, deleted ones included
+	CountScansSince(ctx context.Context, userID uint, start time.Time) (int64, error)
+	Latest(ctx context.Context, userID uint) (*models.AnalysisResult, error)
+	// LatestAt returns the user's newest analysis scanned at or before at
+	LatestAt(ctx context.Context, userID uint, at time.Time) (*models.AnalysisResult, error)
+	// ListScannedBetween returns the user's analyses scanned after from and at or before to, oldest first
+	ListScannedBetween(ctx context.Context, userID uint, from, to time.Time) ([]models.AnalysisResult, error)
+	// PreviousOf returns the user's analysis stored right before the one with id
+	PreviousOf(ctx context.Context, userID, id uint) (*models.AnalysisResult, error)
+	FindForUser(ctx context.Context, id, userID uint) (*models.AnalysisResult, error)
+	// FindDetailForUser is FindForUser with the scan history preloaded
+	FindDetailForUser(ctx context.Context, id, userID uint) (*models.AnalysisResult, error)
+	FindByChecksum(ctx context.Context, checksum string) (*models.AnalysisResult, error)
+	// FindSameSnapshot returns the user's analyses scanned at or after since with the same eight
+	// parameter values as result, newest first, with the scan history preloaded
+	FindSameSnapshot(ctx context.Context, result *models.AnalysisResult, since time.Time) ([]models.AnalysisResult, error)
+
+	// Refs returns id, scan_history_id and pinned of the user's analyses in ids (nil = all of them)
+	Refs(ctx context.Context, userID uint, ids []uint) ([]models.AnalysisResult, error)
+	// HardDelete removes the user's analyses in ids (nil = all of them)
+	HardDelete(ctx context.Context, userID uint, ids []uint) (int64, error)
+	// MarkDeleted soft deletes the user's analyses in ids (nil = all of them) under token so they
+	// can be restored; rows under legal hold stay in place
+	MarkDeleted(ctx context.Context, userID uint, ids []uint, token string) (int64, error)
+	// RestoreDeleted restores the user's analyses soft deleted under token at or after since
+	RestoreDeleted(ctx context.Context, userID uint, token string, since time.Time) (int64, error)
+	// DeletedBefore returns id, user_id and scan_history_id of analyses soft deleted by a bulk
+	// delete before cutoff
+	DeletedBefore(ctx context.Context, cutoff time.Time) ([]models.AnalysisResult, error)
+	// PurgeBreakdowns removes breakdown rows of analyses that no longer exist
+	PurgeBreakdowns(ctx context.Context, analysisIDs []uint) error
+	// PurgeScanHistory removes the user's scan history rows no analysis references anymore; analyses
+	// soft deleted within their undo window still count as references
+	PurgeScanHistory(ctx context.Context, userID uint, scanIDs []uint) error
+
+	CreateScanHistory(ctx context.Context, scan *models.ScanHistory) error
+}
+
+type gormAnalysisRepo struct {
+	conn Conn
+}
+
+// NewAnalysisRepo creates a GORM-backed AnalysisRepo on conn (nil = DefaultConn)
+func NewAnalysisRepo(conn Conn) AnalysisRepo {
+	return &gormAnalysisRepo{conn: orDefault(conn)}
+}
+
+func (r *gormAnalysisRepo) Transaction(ctx context.Context, fn func(tx AnalysisRepo) error) error {
+	return r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&gormAnalysisRepo{conn: txConn(tx)})
+	})
+}
+
+func (r *gormAnalysisRepo) Create(ctx context.Context, result *models.AnalysisResult) error {
+	return r.conn(ctx).Create(result).Error
+}
+
+func (r *gormAnalysisRepo) CreateBreakdowns(ctx context.Context, groups []models.AnalysisGroup, contacts []models.AnalysisContact, batchSize int) error {
+	db := r.conn(ctx)
+	if len(groups) > 0 {
+		if err := db.CreateInBatches(groups, batchSize).Error; err != nil {
+			return err
+		}
+	}
+	if len(contacts) > 0 {
+		if err := db.CreateInBatches(contacts, batchSize).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *gormAnalysisRepo) UpdateColumns(ctx context.Context, result *models.AnalysisResult, columns map[string]interface{}) error {
+	return r.conn(ctx).Model(result).UpdateColumns(columns).Error
+}
+
+func (r *gormAnalysisRepo) ListByUser(ctx context.Context, userID uint) ([]models.AnalysisResult, error) {
+	var results []models.AnalysisResult
+	err := r.conn(ctx).Where("user_id = ?", userID).Order("scan_date DESC").Find(&results).Error
+	return results, err
+}
+
+func (r *gormAnalysisRepo) EachHistory(ctx context.Context, userID uint, query HistoryQuery, fn func(HistoryRow) error) error {
+	db := r.conn(ctx)
+	scoped, err := r.historyScope(ctx, userID, query)
+	if err != nil {
+		return err
+	}
+	scoped = query.order(scoped, HistorySorts, "ar.pinned DESC, ar.scan_date DESC", "ar.id")
+	rows, err := query.page(scoped).
+		Select("ar.id, COALESCE(sh.phone_number, '') as phone_number, ar.scan_date, ar.strength, ar.checksum, COALESCE(sh.scan_trigger, 'manual') as scan_trigger, ar.scoring_version, ar.pinned, COALESCE(ar.label, '') as label").
+		Joins("LEFT JOIN scan_history sh ON ar.scan_history_id = sh.id").
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row HistoryRow
+		if err := db.ScanRows(rows, &row); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *gormAnalysisRepo) CountHistory(ctx context.Context, userID uint, query HistoryQuery) (int64, error) {
+	scoped, err := r.historyScope(ctx, userID, query)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	err = scoped.Count(&count).Error
+	return count, err
+}
+
+// historyScope selects the user's analyses matching the filters of query
+func (r *gormAnalysisRepo) historyScope(ctx context.Context, userID uint, query HistoryQuery) (*gorm.DB, error) {
+	db := r.conn(ctx).Table("analysis_results ar").
+		Where("ar.user_id = ?", userID).
+		Scopes(database.TenantScopeFor(ctx, "ar.tenant_id"))
+	if query.Newest > 0 {
+		// Scan date of the Nth newest analysis; older ones are beyond the history size
+		var cutoff []time.Time
+		err := r.conn(ctx).Model(&models.AnalysisResult{}).Where("user_id = ?", userID).
+			Order("scan_date DESC").Offset(query.Newest-1).Limit(1).Pluck("scan_date", &cutoff).Error
+		if err != nil {
+			return nil, err
+		}
+		if len(cutoff) > 0 {
+			db = db.Where("ar.scan_date >= ?", cutoff[0])
+		}
+	}
+	if len(query.Strengths) > 0 {
+		db = db.Where("ar.strength IN ?", query.Strengths)
+	}
+	return query.between(db, "ar.scan_date"), nil
+}
+
+func (r *gormAnalysisRepo) CountByUser(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.conn(ctx).Model(&models.AnalysisResult{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+func (r *gormAnalysisRepo) CountScansSince(ctx context.Context, userID uint, start time.Time) (int64, error) {
+	var count int64
+	err := r.conn(ctx).Unscoped().Model(&models.AnalysisResult{}).Where("user_id = ? AND scan_date >= ?", userID, start).Count(&count).Error
+	return count, err
+}
+
+func (r *gormAnalysisRepo) Latest(ctx context.Context, userID uint) (*models.AnalysisResult, error) {
+	var result models.AnalysisResult
+	if err := r.conn(ctx).Where("user_id = ?", userID).Order("scan_date DESC").First(&result).Error; err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (r *gormAnalysisRepo) LatestAt(ctx context.Context, userID uint, at time.Time) (*models.AnalysisResult, error) {
+	var result models.AnalysisResult
+	if err := r.conn(ctx).Where("user_id = ? AND scan_date <= ?", userID, at).Order("scan_date DESC").First(&result).Error; err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (r *gormAnalysisRepo) ListScannedBetween(ctx context.Context, userID uint, from, to time.Time) ([]models.AnalysisResult, error) {
+	var results []models.AnalysisResult
+	err := r.conn(ctx).Where("user_id = ? AND scan_date > ? AND scan_date <= ?", userID, from, to).
+		Order("scan_date ASC").Find(&results).Error
+	return results, err
+}
+
+func (r *gormAnalysisRepo) PreviousOf(ctx context.Context, userID, id uint) (*models.AnalysisResult, error) {
+	var result models.AnalysisResult
+	if err := r.conn(ctx).Where("user_id = ? AND id < ?", userID, id).Order("id DESC").First(&result).Error; err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (r *gormAnalysisRepo) FindForUser(ctx context.Context, id, userID uint) (*models.AnalysisResult, error) {
+	var result models.AnalysisResult
+	if err := r.conn(ctx).Where("id = ? AND user_id = ?", id, userID).First(&result).Error; err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (r *gormAnalysisRepo) FindDetailForUser(ctx context.Context, id, userID uint) (*models.AnalysisResult, error) {
+	var result models.AnalysisResult
+	if err := r.conn(ctx).Preload("ScanHistory").Where("id = ? AND user_id = ?", id, userID).First(&result).Error; err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (r *gormAnalysisRepo) FindByChecksum(ctx context.Context, checksum string) (*models.AnalysisResult, error) {
+	var result models.AnalysisResult
+	if err := r.conn(ctx).Where("checksum = ?", strings.ToLower(checksum)).First(&result).Error; err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (r *gormAnalysisRepo) FindSameSnapshot(ctx context.Context, result *models.AnalysisResult, since time.Time) ([]models.AnalysisResult, error) {
+	var results []models.AnalysisResult
+	err := r.conn(ctx).Preload("ScanHistory").
+		Where("user_id = ? AND scan_date >= ?", result.UserID, since).
+		Where("total_chats = ? AND total_contacts = ? AND account_age_days = ? AND total_groups = ?",
+			result.TotalChats, result.TotalContacts, result.AccountAgeDays, result.TotalGroups).
+		Where("total_chat_with_contact = ? AND sensitive_content_count = ? AND total_unsaved_chats = ? AND unknown_number_chats = ?",
+			result.TotalChatWithContact, result.SensitiveContentCount, result.TotalUnsavedChats, result.UnknownNumberChats).
+		Order("scan_date DESC").Find(&results).Error
+	return results, err
+}
+
+func (r *gormAnalysisRepo) Refs(ctx context.Context, userID uint, ids []uint) ([]models.AnalysisResult, error) {
+	query := r.conn(ctx).Select("id", "scan_history_id", "pinned").Where("user_id = ?", userID)
+	if ids != nil {
+		query = query.Where("id IN ?", ids)
+	}
+	var refs []models.AnalysisResult
+	err := query.Find(&refs).Error
+	return refs, err
+}
+
+func (r *gormAnalysisRepo) HardDelete(ctx context.Context, userID uint, ids []uint) (int64, error) {
+	query := r.conn(ctx).Unscoped().Where("user_id = ?", userID)
+	if ids != nil {
+		query = query.Where("id IN ?", ids)
+	}
+	res := query.Delete(&models.AnalysisResult{})
+	return res.RowsAffected, res.Error
+}
+
+func (r *gormAnalysisRepo) MarkDeleted(ctx context.Context, userID uint, ids []uint, token string) (int64, error) {
+	// Tagging, deleting and untagging the held rows is one step, so a failure never leaves rows
+	// tagged with a token that was not handed out
+	var deleted int64
+	err := r.conn(ctx).Transaction(func(db *gorm.DB) error {
+		query := db.Model(&models.AnalysisResult{}).Where("user_id = ?", userID)
+		if ids != nil {
+			query = query.Where("id IN ?", ids)
+		}
+		if err := query.UpdateColumn("deletion_token", token).Error; err != nil {
+			return err
+		}
+		// The soft delete goes through the legal hold guard, so held rows keep their deleted_at empty
+		res := db.Where("user_id = ? AND deletion_token = ?", userID, token).Delete(&models.AnalysisResult{})
+		if res.Error != nil {
+			return res.Error
+		}
+		deleted = res.RowsAffected
+		return db.Model(&models.AnalysisResult{}).Where("user_id = ? AND deletion_token = ?", userID, token).
+			UpdateColumn("deletion_token", nil).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+func (r *gormAnalysisRepo) RestoreDeleted(ctx context.Context, userID uint, token string, since time.Time) (int64, error) {
+	res := r.conn(ctx).Unscoped().Model(&models.AnalysisResult{}).
+		Where("user_id = ? AND deletion_token = ? AND deleted_at >= ?", userID, token, since).
+		UpdateColumns(map[string]interface{}{"deleted_at": nil, "deletion_token": nil})
+	return res.RowsAffected, res.Error
+}
+
+func (r *gormAnalysisRepo) DeletedBefore(ctx context.Context, cutoff time.Time) ([]models.AnalysisResult, error) {
+	var refs []models.AnalysisResult
+	err := r.conn(ctx).Unscoped().Select("id", "user_id", "scan_history_id").
+		Where("deletion_token IS NOT NULL AND deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Order("user_id").Find(&refs).Error
+	return refs, err
+}
+
+func (r *gormAnalysisRepo) PurgeBreakdowns(ctx context.Context, analysisIDs []uint) error {
+	if len(analysisIDs) == 0 {
+		return nil
+	}
+	// Rows of analyses that survived the delete (e.g. under legal hold) are kept
+	db := r.conn(ctx)
+	existing := db.Unscoped().Model(&models.AnalysisResult{}).Select("id").Where("id IN ?", analysisIDs)
+	for _, model := range []interface{}{&models.AnalysisGroup{}, &models.AnalysisContact{}} {
+		if err := db.Where("analysis_result_id IN ? AND analysis_result_id NOT IN (?)", analysisIDs, existing).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *gormAnalysisRepo) PurgeScanHistory(ctx context.Context, userID uint, scanIDs []uint) error {
+	return r.conn(ctx).Transaction(func(db *gorm.DB) error {
+		for _, scanID := range scanIDs {
+			// Analyses still in their undo window are soft deleted and can be restored, so they
+			// keep their scan history
+			var remaining int64
+			if err := db.Unscoped().Model(&models.AnalysisResult{}).Where("scan_history_id = ?", scanID).Count(&remaining).Error; err != nil {
+				return err
+			}
+			if remaining == 0 {
+				if err := db.Unscoped().Where("id = ? AND user_id = ?", scanID, userID).Delete(&models.ScanHistory{}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (r *gormAnalysisRepo) CreateScanHistory(ctx context.Context, scan *models.ScanHistory) error {
+	return r.conn(ctx).Create(scan).Error
+}
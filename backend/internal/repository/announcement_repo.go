@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"back_wa/internal/models"
+)
+
+// AnnouncementRepo stores the banners operators publish to users, listed newest first.
+// Lookups return gorm.ErrRecordNotFound when nothing matches.
+type AnnouncementRepo interface {
+	// ListCurrent returns the active announcements for audiences scheduled at now
+	ListCurrent(ctx context.Context, audiences []string, now time.Time) ([]models.Announcement, error)
+	List(ctx context.Context) ([]models.Announcement, error)
+	Create(ctx context.Context, announcement *models.Announcement) error
+	FindByID(ctx context.Context, id uint) (*models.Announcement, error)
+	// Update writes the editable columns of announcement, cleared ones included
+	Update(ctx context.Context, announcement *models.Announcement) error
+	// Delete removes the announcement; reports false when it did not exist
+	Delete(ctx context.Context, id uint) (bool, error)
+}
+
+type gormAnnouncementRepo struct {
+	conn Conn
+}
+
+// NewAnnouncementRepo creates a GORM-backed AnnouncementRepo on conn (nil = DefaultConn)
+func NewAnnouncementRepo(conn Conn) AnnouncementRepo {
+	return &gormAnnouncementRepo{conn: orDefault(conn)}
+}
+
+func (r *gormAnnouncementRepo) ListCurrent(ctx context.Context, audiences []string, now time.Time) ([]models.Announcement, error) {
+	var announcements []models.Announcement
+	err := r.conn(ctx).Where("active = ? AND audience IN ?", true, audiences).
+		Where("starts_at IS NULL OR starts_at <= ?", now).
+		Where("ends_at IS NULL OR ends_at > ?", now).
+		Order("id DESC").
+		Find(&announcements).Error
+	return announcements, err
+}
+
+func (r *gormAnnouncementRepo) List(ctx context.Context) ([]models.Announcement, error) {
+	var announcements []models.Announcement
+	err := r.conn(ctx).Order("id DESC").Find(&announcements).Error
+	return announcements, err
+}
+
+func (r *gormAnnouncementRepo) Create(ctx context.Context, announcement *models.Announcement) error {
+	return r.conn(ctx).Create(announcement).Error
+}
+
+func (r *gormAnnouncementRepo) FindByID(ctx context.Context, id uint) (*models.Announcement, error) {
+	var announcement models.Announcement
+	if err := r.conn(ctx).First(&announcement, id).Error; err != nil {
+		return nil, err
+	}
+	return &announcement, nil
+}
+
+func (r *gormAnnouncementRepo) Update(ctx context.Context, announcement *models.Announcement) error {
+	// Select the columns so cleared fields (active=false, no end time) are written too
+	return r.conn(ctx).Model(announcement).Select("title", "message", "kind", "audience", "link", "starts_at", "ends_at", "active").
+		Updates(announcement).Error
+}
+
+func (r *gormAnnouncementRepo) Delete(ctx context.Context, id uint) (bool, error) {
+	result := r.conn(ctx).Delete(&models.Announcement{}, id)
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"back_wa/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// AuthSessionRepo stores the sessions of issued tokens (auth_sessions), keyed by jti.
+// Lookups return gorm.ErrRecordNotFound when no session matches.
+type AuthSessionRepo interface {
+	Create(ctx context.Context, session *models.AuthSession) error
+	// PurgeExpired deletes the user's sessions that expired before cutoff
+	PurgeExpired(ctx context.Context, userID uint, cutoff time.Time) error
+	// ListActive returns the user's unrevoked, unexpired account sessions, most recently used first
+	ListActive(ctx context.Context, userID uint, now time.Time) ([]models.AuthSession, error)
+	// CountActive counts the sessions ListActive returns
+	CountActive(ctx context.Context, userID uint, now time.Time) (int64, error)
+	// FindActive returns one of the user's unrevoked sessions
+	FindActive(ctx context.Context, userID, id uint) (*models.AuthSession, error)
+	FindByJTI(ctx context.Context, jti string) (*models.AuthSession, error)
+	Touch(ctx context.Context, id uint, at time.Time) error
+
+	// RevokeFamilies revokes the sessions of jtis and the scoped sessions minted from them.
+	// It returns the jtis that were revoked by this call.
+	RevokeFamilies(ctx context.Context, jtis []string, at time.Time) ([]string, error)
+	// RevokeUser revokes the user's sessions except the family of keepJTI (empty = all)
+	// and returns the jtis that were revoked by this call
+	RevokeUser(ctx context.Context, userID uint, keepJTI string, at time.Time) ([]string, error)
+}
+
+type gormAuthSessionRepo struct {
+	conn Conn
+}
+
+// NewAuthSessionRepo creates a GORM-backed AuthSessionRepo on conn (nil = DefaultConn)
+func NewAuthSessionRepo(conn Conn) AuthSessionRepo {
+	return &gormAuthSessionRepo{conn: orDefault(conn)}
+}
+
+func (r *gormAuthSessionRepo) Create(ctx context.Context, session *models.AuthSession) error {
+	return r.conn(ctx).Create(session).Error
+}
+
+func (r *gormAuthSessionRepo) PurgeExpired(ctx context.Context, userID uint, cutoff time.Time) error {
+	return r.conn(ctx).Where("user_id = ? AND expires_at < ?", userID, cutoff).Delete(&models.AuthSession{}).Error
+}
+
+func (r *gormAuthSessionRepo) ListActive(ctx context.Context, userID uint, now time.Time) ([]models.AuthSession, error) {
+	var sessions []models.AuthSession
+	err := r.conn(ctx).Where("user_id = ? AND purpose = ? AND revoked_at IS NULL AND expires_at > ?", userID, "", now).
+		Order("COALESCE(last_used_at, created_at) DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+func (r *gormAuthSessionRepo) CountActive(ctx context.Context, userID uint, now time.Time) (int64, error) {
+	var count int64
+	err := r.conn(ctx).Model(&models.AuthSession{}).
+		Where("user_id = ? AND purpose = ? AND revoked_at IS NULL AND expires_at > ?", userID, "", now).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *gormAuthSessionRepo) FindActive(ctx context.Context, userID, id uint) (*models.AuthSession, error) {
+	var session models.AuthSession
+	if err := r.conn(ctx).Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *gormAuthSessionRepo) FindByJTI(ctx context.Context, jti string) (*models.AuthSession, error) {
+	var session models.AuthSession
+	if err := r.conn(ctx).Select("id", "revoked_at", "last_used_at").Where("jti = ?", jti).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *gormAuthSessionRepo) Touch(ctx context.Context, id uint, at time.Time) error {
+	return r.conn(ctx).Model(&models.AuthSession{}).Where("id = ?", id).UpdateColumn("last_used_at", at).Error
+}
+
+func (r *gormAuthSessionRepo) RevokeFamilies(ctx context.Context, jtis []string, at time.Time) ([]string, error) {
+	return r.revokeWhere(r.conn(ctx).Where("jti IN ? OR parent_jti IN ?", jtis, jtis), at)
+}
+
+func (r *gormAuthSessionRepo) RevokeUser(ctx context.Context, userID uint, keepJTI string, at time.Time) ([]string, error) {
+	query := r.conn(ctx).Where("user_id = ?", userID)
+	if keepJTI != "" {
+		query = query.Where("jti <> ? AND parent_jti <> ?", keepJTI, keepJTI)
+	}
+	return r.revokeWhere(query, at)
+}
+
+// revokeWhere stamps revoked_at on the unrevoked sessions matching query
+func (r *gormAuthSessionRepo) revokeWhere(query *gorm.DB, at time.Time) ([]string, error) {
+	var jtis []string
+	if err := query.Session(&gorm.Session{}).Model(&models.AuthSession{}).Where("revoked_at IS NULL").Pluck("jti", &jtis).Error; err != nil {
+		return nil, err
+	}
+	if len(jtis) == 0 {
+		return nil, nil
+	}
+	if err := query.Session(&gorm.Session{}).Model(&models.AuthSession{}).Where("jti IN ?", jtis).UpdateColumn("revoked_at", at).Error; err != nil {
+		return nil, err
+	}
+	return jtis, nil
+}
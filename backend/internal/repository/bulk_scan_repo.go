@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"back_wa/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// BulkScanRepo stores organization bulk scans (bulk_scans) and reads their member jobs
+type BulkScanRepo interface {
+	Create(ctx context.Context, scan *models.BulkScan) error
+	Update(ctx context.Context, id uint, columns map[string]interface{}) error
+	// Increment adds one to a counter column (completed, failed)
+	Increment(ctx context.Context, id uint, column string) error
+	FindForTenant(ctx context.Context, id, tenantID uint) (*models.BulkScan, error)
+	// ActiveForTenant returns the tenant's running bulk scan, or nil
+	ActiveForTenant(ctx context.Context, tenantID uint) (*models.BulkScan, error)
+	ListUnfinished(ctx context.Context) ([]models.BulkScan, error)
+	// Jobs returns the member analysis jobs of a bulk scan
+	Jobs(ctx context.Context, id uint) ([]models.AnalysisJob, error)
+}
+
+type gormBulkScanRepo struct {
+	conn Conn
+}
+
+// NewBulkScanRepo creates a GORM-backed BulkScanRepo on conn (nil = DefaultConn)
+func NewBulkScanRepo(conn Conn) BulkScanRepo {
+	return &gormBulkScanRepo{conn: orDefault(conn)}
+}
+
+func (r *gormBulkScanRepo) Create(ctx context.Context, scan *models.BulkScan) error {
+	return r.conn(ctx).Create(scan).Error
+}
+
+func (r *gormBulkScanRepo) Update(ctx context.Context, id uint, columns map[string]interface{}) error {
+	return r.conn(ctx).Model(&models.BulkScan{}).Where("id = ?", id).Updates(columns).Error
+}
+
+func (r *gormBulkScanRepo) Increment(ctx context.Context, id uint, column string) error {
+	return r.conn(ctx).Model(&models.BulkScan{}).Where("id = ?", id).
+		UpdateColumn(column, gorm.Expr(column+" + 1")).Error
+}
+
+func (r *gormBulkScanRepo) FindForTenant(ctx context.Context, id, tenantID uint) (*models.BulkScan, error) {
+	var scan models.BulkScan
+	if err := r.conn(ctx).Where("id = ? AND tenant_id = ?", id, tenantID).First(&scan).Error; err != nil {
+		return nil, err
+	}
+	return &scan, nil
+}
+
+func (r *gormBulkScanRepo) ActiveForTenant(ctx context.Context, tenantID uint) (*models.BulkScan, error) {
+	var scan models.BulkScan
+	err := r.conn(ctx).Where("tenant_id = ? AND status = ?", tenantID, models.BulkScanRunning).
+		Order("id DESC").First(&scan).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &scan, nil
+}
+
+func (r *gormBulkScanRepo) ListUnfinished(ctx context.Context) ([]models.BulkScan, error) {
+	var scans []models.BulkScan
+	err := r.conn(ctx).Where("status = ?", models.BulkScanRunning).Order("id ASC").Find(&scans).Error
+	return scans, err
+}
+
+func (r *gormBulkScanRepo) Jobs(ctx context.Context, id uint) ([]models.AnalysisJob, error) {
+	var jobs []models.AnalysisJob
+	err := r.conn(ctx).Where("bulk_scan_id = ?", id).Order("id ASC").Find(&jobs).Error
+	return jobs, err
+}
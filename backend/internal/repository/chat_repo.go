@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+
+	"back_wa/internal/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// ChatRepo stores per-session conversation metadata (whatsapp_chats)
+type ChatRepo interface {
+	// Upsert writes the given chats, keyed by user_id + chat_jid; counts are absolute, not increments
+	Upsert(ctx context.Context, chats []models.WhatsAppChat) error
+	ListByUser(ctx context.Context, userID uint) ([]models.WhatsAppChat, error)
+	DeleteByUser(ctx context.Context, userID uint) error
+
+	// FindScanConsent returns the user's message scan consent (gorm.ErrRecordNotFound when never given)
+	FindScanConsent(ctx context.Context, userID uint) (*models.MessageScanConsent, error)
+	// SaveScanConsent writes the consent; a revoked one also clears the sensitive counts of the
+	// user's chats, in one transaction
+	SaveScanConsent(ctx context.Context, consent *models.MessageScanConsent) error
+}
+
+type gormChatRepo struct {
+	conn Conn
+}
+
+// NewChatRepo creates a GORM-backed ChatRepo on conn (nil = DefaultConn)
+func NewChatRepo(conn Conn) ChatRepo {
+	return &gormChatRepo{conn: orDefault(conn)}
+}
+
+func (r *gormChatRepo) Upsert(ctx context.Context, chats []models.WhatsAppChat) error {
+	if len(chats) == 0 {
+		return nil
+	}
+	return r.conn(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "chat_jid"}},
+		DoUpdates: clause.AssignmentColumns([]string{"is_group", "message_count", "last_message_at", "sensitive_messages", "sensitive_counts", "updated_at"}),
+	}).CreateInBatches(chats, 200).Error
+}
+
+func (r *gormChatRepo) ListByUser(ctx context.Context, userID uint) ([]models.WhatsAppChat, error) {
+	var chats []models.WhatsAppChat
+	err := r.conn(ctx).Where("user_id = ?", userID).Find(&chats).Error
+	return chats, err
+}
+
+func (r *gormChatRepo) DeleteByUser(ctx context.Context, userID uint) error {
+	return r.conn(ctx).Where("user_id = ?", userID).Delete(&models.WhatsAppChat{}).Error
+}
+
+func (r *gormChatRepo) FindScanConsent(ctx context.Context, userID uint) (*models.MessageScanConsent, error) {
+	var consent models.MessageScanConsent
+	if err := r.conn(ctx).Where("user_id = ?", userID).First(&consent).Error; err != nil {
+		return nil, err
+	}
+	return &consent, nil
+}
+
+func (r *gormChatRepo) SaveScanConsent(ctx context.Context, consent *models.MessageScanConsent) error {
+	return r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		// Select("*") so an explicit false is written instead of the column default
+		if err := tx.Select("*").Save(consent).Error; err != nil {
+			return err
+		}
+		if consent.Enabled {
+			return nil
+		}
+		return tx.Model(&models.WhatsAppChat{}).Where("user_id = ?", consent.UserID).
+			UpdateColumns(map[string]interface{}{"sensitive_messages": 0, "sensitive_counts": nil}).Error
+	})
+}
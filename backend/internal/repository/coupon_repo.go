@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"back_wa/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// CouponRepo stores promo codes (coupons) and their redemptions (coupon_redemptions).
+// Lookups return gorm.ErrRecordNotFound when nothing matches.
+type CouponRepo interface {
+	// List returns all coupons, newest first
+	List(ctx context.Context) ([]models.Coupon, error)
+	Create(ctx context.Context, coupon *models.Coupon) error
+	FindByID(ctx context.Context, id uint) (*models.Coupon, error)
+	// FindActive returns the active coupon with the (normalized) code
+	FindActive(ctx context.Context, code string) (*models.Coupon, error)
+	// Update writes the editable columns of coupon, zero values included
+	Update(ctx context.Context, coupon *models.Coupon) error
+	// TakeUse counts one use of the coupon unless its usage limit is reached; reports whether it did
+	TakeUse(ctx context.Context, id uint) (bool, error)
+	// ReturnUse gives one use of the coupon back
+	ReturnUse(ctx context.Context, id uint) error
+
+	CreateRedemption(ctx context.Context, redemption *models.CouponRedemption) error
+	// ReleaseRedemption marks the unreleased redemption of the transaction released at at and
+	// returns it, or nil when there is none (or another caller released it first)
+	ReleaseRedemption(ctx context.Context, transactionID int, at time.Time) (*models.CouponRedemption, error)
+}
+
+type gormCouponRepo struct {
+	conn Conn
+}
+
+// NewCouponRepo creates a GORM-backed CouponRepo on conn (nil = DefaultConn)
+func NewCouponRepo(conn Conn) CouponRepo {
+	return &gormCouponRepo{conn: orDefault(conn)}
+}
+
+func (r *gormCouponRepo) List(ctx context.Context) ([]models.Coupon, error) {
+	var coupons []models.Coupon
+	err := r.conn(ctx).Order("created_at DESC, id DESC").Find(&coupons).Error
+	return coupons, err
+}
+
+func (r *gormCouponRepo) Create(ctx context.Context, coupon *models.Coupon) error {
+	return r.conn(ctx).Create(coupon).Error
+}
+
+func (r *gormCouponRepo) FindByID(ctx context.Context, id uint) (*models.Coupon, error) {
+	var coupon models.Coupon
+	if err := r.conn(ctx).First(&coupon, id).Error; err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+func (r *gormCouponRepo) FindActive(ctx context.Context, code string) (*models.Coupon, error) {
+	var coupon models.Coupon
+	if err := r.conn(ctx).Where("code = ? AND is_active = ?", models.NormalizeCouponCode(code), true).First(&coupon).Error; err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+func (r *gormCouponRepo) Update(ctx context.Context, coupon *models.Coupon) error {
+	return r.conn(ctx).Model(coupon).Select("code", "description", "discount_type", "discount_value", "max_discount",
+		"min_amount", "usage_limit", "expires_at", "is_active").Updates(coupon).Error
+}
+
+func (r *gormCouponRepo) TakeUse(ctx context.Context, id uint) (bool, error) {
+	result := r.conn(ctx).Model(&models.Coupon{}).
+		Where("id = ? AND (usage_limit = 0 OR used_count < usage_limit)", id).
+		UpdateColumn("used_count", gorm.Expr("used_count + 1"))
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *gormCouponRepo) ReturnUse(ctx context.Context, id uint) error {
+	return r.conn(ctx).Model(&models.Coupon{}).Where("id = ? AND used_count > 0", id).
+		UpdateColumn("used_count", gorm.Expr("used_count - 1")).Error
+}
+
+func (r *gormCouponRepo) CreateRedemption(ctx context.Context, redemption *models.CouponRedemption) error {
+	return r.conn(ctx).Create(redemption).Error
+}
+
+func (r *gormCouponRepo) ReleaseRedemption(ctx context.Context, transactionID int, at time.Time) (*models.CouponRedemption, error) {
+	db := r.conn(ctx)
+	var redemption models.CouponRedemption
+	err := db.Where("transaction_id = ? AND released_at IS NULL", transactionID).First(&redemption).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := db.Model(&models.CouponRedemption{}).Where("id = ? AND released_at IS NULL", redemption.ID).
+		UpdateColumn("released_at", at)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+	return &redemption, nil
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"back_wa/internal/models"
+)
+
+// DataAccessRepo stores support staff's data access requests and the requests served under them.
+// Lookups return gorm.ErrRecordNotFound when nothing matches.
+type DataAccessRepo interface {
+	Create(ctx context.Context, request *models.DataAccessRequest) error
+	// FindActive returns the admin's newest-expiring request for the user that is neither
+	// revoked nor expired at now
+	FindActive(ctx context.Context, adminID, userID uint, now time.Time) (*models.DataAccessRequest, error)
+	// FindByAdmin returns the request when the admin opened it
+	FindByAdmin(ctx context.Context, id, adminID uint) (*models.DataAccessRequest, error)
+	Revoke(ctx context.Context, id uint, at time.Time) error
+	// List returns one page of requests newest first, of userID and adminID only unless 0, and
+	// the total matching
+	List(ctx context.Context, userID, adminID uint, query ListQuery) ([]models.DataAccessRequest, int64, error)
+
+	CreateLog(ctx context.Context, entry *models.DataAccessLog) error
+	// ListLogs returns the requests served under the data access request, oldest first
+	ListLogs(ctx context.Context, requestID uint) ([]models.DataAccessLog, error)
+}
+
+type gormDataAccessRepo struct {
+	conn Conn
+}
+
+// NewDataAccessRepo creates a GORM-backed DataAccessRepo on conn (nil = DefaultConn)
+func NewDataAccessRepo(conn Conn) DataAccessRepo {
+	return &gormDataAccessRepo{conn: orDefault(conn)}
+}
+
+func (r *gormDataAccessRepo) Create(ctx context.Context, request *models.DataAccessRequest) error {
+	return r.conn(ctx).Create(request).Error
+}
+
+func (r *gormDataAccessRepo) FindActive(ctx context.Context, adminID, userID uint, now time.Time) (*models.DataAccessRequest, error) {
+	var request models.DataAccessRequest
+	err := r.conn(ctx).Where("admin_id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?", adminID, userID, now).
+		Order("expires_at DESC").First(&request).Error
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (r *gormDataAccessRepo) FindByAdmin(ctx context.Context, id, adminID uint) (*models.DataAccessRequest, error) {
+	var request models.DataAccessRequest
+	if err := r.conn(ctx).Where("id = ? AND admin_id = ?", id, adminID).First(&request).Error; err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (r *gormDataAccessRepo) Revoke(ctx context.Context, id uint, at time.Time) error {
+	return r.conn(ctx).Model(&models.DataAccessRequest{}).Where("id = ?", id).UpdateColumn("revoked_at", at).Error
+}
+
+func (r *gormDataAccessRepo) List(ctx context.Context, userID, adminID uint, query ListQuery) ([]models.DataAccessRequest, int64, error) {
+	db := r.conn(ctx).Model(&models.DataAccessRequest{})
+	if userID != 0 {
+		db = db.Where("user_id = ?", userID)
+	}
+	if adminID != 0 {
+		db = db.Where("admin_id = ?", adminID)
+	}
+	var requests []models.DataAccessRequest
+	total, err := countAndPage(db, query, "id DESC", &requests)
+	return requests, total, err
+}
+
+func (r *gormDataAccessRepo) CreateLog(ctx context.Context, entry *models.DataAccessLog) error {
+	return r.conn(ctx).Create(entry).Error
+}
+
+func (r *gormDataAccessRepo) ListLogs(ctx context.Context, requestID uint) ([]models.DataAccessLog, error) {
+	var entries []models.DataAccessLog
+	err := r.conn(ctx).Where("request_id = ?", requestID).Order("id ASC").Find(&entries).Error
+	return entries, err
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+
+	"back_wa/internal/models"
+)
+
+// DataKeyRepo stores the wrapped data keys of field encryption and reads and writes the
+// encrypted columns for the encrypt-backfill command
+type DataKeyRepo interface {
+	// List returns every data key, oldest first
+	List(ctx context.Context) ([]models.DataEncryptionKey, error)
+	// ListActive returns the keys that are not retired, oldest first
+	ListActive(ctx context.Context) ([]models.DataEncryptionKey, error)
+	// ListNotWrappedWith returns the keys wrapped with another master key than masterKeyID
+	ListNotWrappedWith(ctx context.Context, masterKeyID string) ([]models.DataEncryptionKey, error)
+	Create(ctx context.Context, key *models.DataEncryptionKey) error
+	// Rewrap replaces the key's wrapped form and the master key it was wrapped with
+	Rewrap(ctx context.Context, id uint, wrapped []byte, masterKeyID string) error
+
+	// ListRows returns up to limit rows of table with id above afterID, by id, with the columns
+	ListRows(ctx context.Context, table string, columns []string, afterID uint, limit int) ([]map[string]interface{}, error)
+	// UpdateRow writes columns of the row of table with id, without hooks or updated_at
+	UpdateRow(ctx context.Context, table string, id uint64, columns map[string]interface{}) error
+}
+
+type gormDataKeyRepo struct {
+	conn Conn
+}
+
+// NewDataKeyRepo creates a GORM-backed DataKeyRepo on conn (nil = DefaultConn)
+func NewDataKeyRepo(conn Conn) DataKeyRepo {
+	return &gormDataKeyRepo{conn: orDefault(conn)}
+}
+
+func (r *gormDataKeyRepo) List(ctx context.Context) ([]models.DataEncryptionKey, error) {
+	var keys []models.DataEncryptionKey
+	err := r.conn(ctx).Order("id ASC").Find(&keys).Error
+	return keys, err
+}
+
+func (r *gormDataKeyRepo) ListActive(ctx context.Context) ([]models.DataEncryptionKey, error) {
+	var keys []models.DataEncryptionKey
+	err := r.conn(ctx).Where("retired_at IS NULL").Order("id ASC").Find(&keys).Error
+	return keys, err
+}
+
+func (r *gormDataKeyRepo) ListNotWrappedWith(ctx context.Context, masterKeyID string) ([]models.DataEncryptionKey, error) {
+	var keys []models.DataEncryptionKey
+	err := r.conn(ctx).Where("master_key_id <> ?", masterKeyID).Find(&keys).Error
+	return keys, err
+}
+
+func (r *gormDataKeyRepo) Create(ctx context.Context, key *models.DataEncryptionKey) error {
+	return r.conn(ctx).Create(key).Error
+}
+
+func (r *gormDataKeyRepo) Rewrap(ctx context.Context, id uint, wrapped []byte, masterKeyID string) error {
+	return r.conn(ctx).Model(&models.DataEncryptionKey{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"wrapped_key": wrapped, "master_key_id": masterKeyID}).Error
+}
+
+func (r *gormDataKeyRepo) ListRows(ctx context.Context, table string, columns []string, afterID uint, limit int) ([]map[string]interface{}, error) {
+	var rows []map[string]interface{}
+	err := r.conn(ctx).Table(table).Select(columns).Where("id > ?", afterID).
+		Order("id ASC").Limit(limit).Find(&rows).Error
+	return rows, err
+}
+
+func (r *gormDataKeyRepo) UpdateRow(ctx context.Context, table string, id uint64, columns map[string]interface{}) error {
+	return r.conn(ctx).Table(table).Where("id = ?", id).UpdateColumns(columns).Error
+}
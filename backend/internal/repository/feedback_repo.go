@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"back_wa/internal/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// FeedbackCount is the number of feedback entries with one strength band and rating
+type FeedbackCount struct {
+	Strength    string
+	Rating      int
+	Count       int64
+	WithComment int64
+}
+
+// FeedbackRepo stores the users' ratings of analysis results
+type FeedbackRepo interface {
+	// Upsert stores feedback, replacing the earlier rating of the same analysis
+	Upsert(ctx context.Context, feedback *models.AnalysisFeedback) error
+	// CountForAnalysis counts the feedback given on the analysis
+	CountForAnalysis(ctx context.Context, analysisID uint) (int64, error)
+	// CountByRating groups the feedback created at or after since (nil = all) by strength band
+	// and rating, in that order
+	CountByRating(ctx context.Context, since *time.Time) ([]FeedbackCount, error)
+}
+
+type gormFeedbackRepo struct {
+	conn Conn
+}
+
+// NewFeedbackRepo creates a GORM-backed FeedbackRepo on conn (nil = DefaultConn)
+func NewFeedbackRepo(conn Conn) FeedbackRepo {
+	return &gormFeedbackRepo{conn: orDefault(conn)}
+}
+
+func (r *gormFeedbackRepo) Upsert(ctx context.Context, feedback *models.AnalysisFeedback) error {
+	return r.conn(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "analysis_result_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rating", "comment", "updated_at"}),
+	}).Create(feedback).Error
+}
+
+func (r *gormFeedbackRepo) CountForAnalysis(ctx context.Context, analysisID uint) (int64, error) {
+	var count int64
+	err := r.conn(ctx).Model(&models.AnalysisFeedback{}).Where("analysis_result_id = ?", analysisID).Count(&count).Error
+	return count, err
+}
+
+func (r *gormFeedbackRepo) CountByRating(ctx context.Context, since *time.Time) ([]FeedbackCount, error) {
+	query := r.conn(ctx).Model(&models.AnalysisFeedback{})
+	if since != nil {
+		query = query.Where("created_at >= ?", *since)
+	}
+	var counts []FeedbackCount
+	err := query.Select("strength, rating, COUNT(*) AS count, SUM(CASE WHEN comment <> '' THEN 1 ELSE 0 END) AS with_comment").
+		Group("strength, rating").Order("strength, rating").Scan(&counts).Error
+	return counts, err
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"back_wa/internal/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// GoalRepo stores the users' strength and parameter goals, one per user.
+// Lookups return gorm.ErrRecordNotFound when the user has no (matching) goal.
+type GoalRepo interface {
+	Find(ctx context.Context, userID uint) (*models.UserGoal, error)
+	// FindUnachieved returns the user's goal while it has not been achieved
+	FindUnachieved(ctx context.Context, userID uint) (*models.UserGoal, error)
+	// Upsert stores goal, replacing the user's previous goal
+	Upsert(ctx context.Context, goal *models.UserGoal) error
+	// Delete removes the user's goal; reports whether there was one
+	Delete(ctx context.Context, userID uint) (bool, error)
+	// MarkAchieved stamps achieved_at; reports false when the goal was already achieved
+	MarkAchieved(ctx context.Context, id uint, at time.Time) (bool, error)
+}
+
+type gormGoalRepo struct {
+	conn Conn
+}
+
+// NewGoalRepo creates a GORM-backed GoalRepo on conn (nil = DefaultConn)
+func NewGoalRepo(conn Conn) GoalRepo {
+	return &gormGoalRepo{conn: orDefault(conn)}
+}
+
+func (r *gormGoalRepo) Find(ctx context.Context, userID uint) (*models.UserGoal, error) {
+	var goal models.UserGoal
+	if err := r.conn(ctx).Where("user_id = ?", userID).First(&goal).Error; err != nil {
+		return nil, err
+	}
+	return &goal, nil
+}
+
+func (r *gormGoalRepo) FindUnachieved(ctx context.Context, userID uint) (*models.UserGoal, error) {
+	var goal models.UserGoal
+	if err := r.conn(ctx).Where("user_id = ? AND achieved_at IS NULL", userID).First(&goal).Error; err != nil {
+		return nil, err
+	}
+	return &goal, nil
+}
+
+func (r *gormGoalRepo) Upsert(ctx context.Context, goal *models.UserGoal) error {
+	return r.conn(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"target_strength", "parameter_goals", "baseline_analysis_id", "achieved_at", "updated_at"}),
+	}).Create(goal).Error
+}
+
+func (r *gormGoalRepo) Delete(ctx context.Context, userID uint) (bool, error) {
+	res := r.conn(ctx).Where("user_id = ?", userID).Delete(&models.UserGoal{})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *gormGoalRepo) MarkAchieved(ctx context.Context, id uint, at time.Time) (bool, error) {
+	res := r.conn(ctx).Model(&models.UserGoal{}).Where("id = ? AND achieved_at IS NULL", id).UpdateColumn("achieved_at", at)
+	return res.RowsAffected > 0, res.Error
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"back_wa/internal/models"
+)
+
+// LegalRepo stores legal holds on analyses and transactions and the users' consent records
+type LegalRepo interface {
+	// SetHold places or updates the hold on the row id of model's table; reports false when there is no such row
+	SetHold(ctx context.Context, model interface{}, id uint, reason string, until *time.Time, adminID uint, at time.Time) (bool, error)
+	// ReleaseHold lifts the hold on the row id of model's table; reports false when there is no such row
+	ReleaseHold(ctx context.Context, model interface{}, id uint, at time.Time) (bool, error)
+
+	CreateConsents(ctx context.Context, consents []models.LegalConsent) error
+	// LatestConsent returns the user's newest consent to document, or nil
+	LatestConsent(ctx context.Context, userID uint, document string) (*models.LegalConsent, error)
+}
+
+type gormLegalRepo struct {
+	conn Conn
+}
+
+// NewLegalRepo creates a GORM-backed LegalRepo on conn (nil = DefaultConn)
+func NewLegalRepo(conn Conn) LegalRepo {
+	return &gormLegalRepo{conn: orDefault(conn)}
+}
+
+func (r *gormLegalRepo) SetHold(ctx context.Context, model interface{}, id uint, reason string, until *time.Time, adminID uint, at time.Time) (bool, error) {
+	res := r.conn(ctx).Model(model).Where("id = ?", id).Updates(map[string]interface{}{
+		"on_hold":     true,
+		"hold_reason": reason,
+		"hold_until":  until,
+		"hold_set_by": adminID,
+		"hold_set_at": at,
+	})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *gormLegalRepo) ReleaseHold(ctx context.Context, model interface{}, id uint, at time.Time) (bool, error) {
+	res := r.conn(ctx).Model(model).Where("id = ?", id).Updates(map[string]interface{}{
+		"on_hold":     false,
+		"hold_until":  nil,
+		"hold_set_at": at,
+	})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *gormLegalRepo) CreateConsents(ctx context.Context, consents []models.LegalConsent) error {
+	return r.conn(ctx).Create(&consents).Error
+}
+
+func (r *gormLegalRepo) LatestConsent(ctx context.Context, userID uint, document string) (*models.LegalConsent, error) {
+	var consents []models.LegalConsent
+	err := r.conn(ctx).Where("user_id = ? AND document = ?", userID, document).Order("accepted_at DESC").Limit(1).Find(&consents).Error
+	if err != nil || len(consents) == 0 {
+		return nil, err
+	}
+	return &consents[0], nil
+}
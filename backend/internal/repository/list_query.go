@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ListQuery pages, date-filters and sorts one user's listing. Zero values leave the listing
+// unrestricted; Sort must be a key of the listing's sort map (empty = its default order).
+type ListQuery struct {
+	Limit  int // 0 = all rows; Offset only applies with a limit
+	Offset int
+	From   time.Time // inclusive, zero = no lower bound
+	To     time.Time // exclusive, zero = no upper bound
+	Sort   string
+	Desc   bool
+}
+
+// HistoryQuery narrows the analysis history
+type HistoryQuery struct {
+	ListQuery
+	Strengths []string // Baik, Cukup, Buruk; empty = all
+	// Newest restricts the history to the user's newest N analyses (history size of the tier), 0 = all
+	Newest int
+}
+
+// TransactionQuery narrows a user's transactions
+type TransactionQuery struct {
+	ListQuery
+	Statuses []string // pending, paid, expired, failed, ...; empty = all
+}
+
+// HistorySorts are the sort keys of the analysis history
+var HistorySorts = map[string]string{
+	"scan_date": "ar.scan_date",
+	"strength":  "ar.strength",
+}
+
+// TransactionSorts are the sort keys of the transaction list
+var TransactionSorts = map[string]string{
+	"created_at": "created_at",
+	"paid_at":    "paid_at",
+	"amount":     "amount",
+	"status":     "status",
+}
+
+// between restricts column to [From, To)
+func (q ListQuery) between(db *gorm.DB, column string) *gorm.DB {
+	if !q.From.IsZero() {
+		db = db.Where(column+" >= ?", q.From)
+	}
+	if !q.To.IsZero() {
+		db = db.Where(column+" < ?", q.To)
+	}
+	return db
+}
+
+// order sorts by the requested key, by fallback when none (or an unknown one) is given.
+// The id breaks ties so pages stay stable.
+func (q ListQuery) order(db *gorm.DB, sorts map[string]string, fallback, idColumn string) *gorm.DB {
+	column, ok := sorts[q.Sort]
+	if !ok {
+		return db.Order(fallback)
+	}
+	direction := " ASC"
+	if q.Desc {
+		direction = " DESC"
+	}
+	return db.Order(column + direction).Order(idColumn + direction)
+}
+
+// page applies limit and offset
+func (q ListQuery) page(db *gorm.DB) *gorm.DB {
+	if q.Limit <= 0 {
+		return db
+	}
+	db = db.Limit(q.Limit)
+	if q.Offset > 0 {
+		db = db.Offset(q.Offset)
+	}
+	return db
+}
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+
+	"back_wa/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// MergeRepo moves the rows of one account to another and stores the account_merges audit log.
+// Lookups return gorm.ErrRecordNotFound when nothing matches.
+type MergeRepo interface {
+	// Transaction runs fn with a repository bound to one database transaction
+	Transaction(ctx context.Context, fn func(tx MergeRepo) error) error
+
+	FindUser(ctx context.Context, id uint) (*models.User, error)
+	// CountConnectedSessions counts the user's WhatsApp sessions that are connected or connecting
+	CountConnectedSessions(ctx context.Context, userID uint) (int64, error)
+	// CountRows counts the user's rows in table
+	CountRows(ctx context.Context, table string, userID uint) (int64, error)
+	// MoveRows re-owns every row of the user in table (soft-deleted ones included) and returns their ids
+	MoveRows(ctx context.Context, table string, fromUserID, toUserID uint) ([]uint, error)
+	// RestoreRows re-owns the rows of ids in table that still belong to fromUserID
+	RestoreRows(ctx context.Context, table string, ids []uint, fromUserID, toUserID uint) error
+	SetUserActive(ctx context.Context, userID uint, active bool) error
+
+	Create(ctx context.Context, merge *models.AccountMerge) error
+	Save(ctx context.Context, merge *models.AccountMerge) error
+	FindByID(ctx context.Context, id uint) (*models.AccountMerge, error)
+	// CountMergedAfter counts merges of userID as the source that are still in effect and newer than mergeID
+	CountMergedAfter(ctx context.Context, userID, mergeID uint) (int64, error)
+	// List returns up to limit merges newest first, those involving userID only unless it is 0
+	List(ctx context.Context, userID uint, limit int) ([]models.AccountMerge, error)
+}
+
+type gormMergeRepo struct {
+	conn Conn
+}
+
+// NewMergeRepo creates a GORM-backed MergeRepo on conn (nil = DefaultConn)
+func NewMergeRepo(conn Conn) MergeRepo {
+	return &gormMergeRepo{conn: orDefault(conn)}
+}
+
+func (r *gormMergeRepo) Transaction(ctx context.Context, fn func(tx MergeRepo) error) error {
+	return r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&gormMergeRepo{conn: txConn(tx)})
+	})
+}
+
+func (r *gormMergeRepo) FindUser(ctx context.Context, id uint) (*models.User, error) {
+	var user models.User
+	if err := r.conn(ctx).First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *gormMergeRepo) CountConnectedSessions(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.conn(ctx).Model(&models.WhatsAppSession{}).
+		Where("user_id = ? AND status IN ?", userID, []string{"connected", "connecting"}).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *gormMergeRepo) CountRows(ctx context.Context, table string, userID uint) (int64, error) {
+	var count int64
+	err := r.conn(ctx).Table(table).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+func (r *gormMergeRepo) MoveRows(ctx context.Context, table string, fromUserID, toUserID uint) ([]uint, error) {
+	db := r.conn(ctx)
+	var ids []uint
+	if err := db.Table(table).Where("user_id = ?", fromUserID).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if err := db.Table(table).Where("id IN ?", ids).Updates(reownColumns(table, toUserID)).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *gormMergeRepo) RestoreRows(ctx context.Context, table string, ids []uint, fromUserID, toUserID uint) error {
+	return r.conn(ctx).Table(table).Where("id IN ? AND user_id = ?", ids, fromUserID).
+		Updates(reownColumns(table, toUserID)).Error
+}
+
+func (r *gormMergeRepo) SetUserActive(ctx context.Context, userID uint, active bool) error {
+	return r.conn(ctx).Model(&models.User{}).Where("id = ?", userID).UpdateColumn("is_active", active).Error
+}
+
+func (r *gormMergeRepo) Create(ctx context.Context, merge *models.AccountMerge) error {
+	return r.conn(ctx).Create(merge).Error
+}
+
+func (r *gormMergeRepo) Save(ctx context.Context, merge *models.AccountMerge) error {
+	return r.conn(ctx).Save(merge).Error
+}
+
+func (r *gormMergeRepo) FindByID(ctx context.Context, id uint) (*models.AccountMerge, error) {
+	var merge models.AccountMerge
+	if err := r.conn(ctx).First(&merge, id).Error; err != nil {
+		return nil, err
+	}
+	return &merge, nil
+}
+
+func (r *gormMergeRepo) CountMergedAfter(ctx context.Context, userID, mergeID uint) (int64, error) {
+	var count int64
+	err := r.conn(ctx).Model(&models.AccountMerge{}).
+		Where("source_user_id = ? AND status = ? AND id > ?", userID, models.AccountMergeMerged, mergeID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *gormMergeRepo) List(ctx context.Context, userID uint, limit int) ([]models.AccountMerge, error) {
+	query := r.conn(ctx).Order("id DESC").Limit(limit)
+	if userID != 0 {
+		query = query.Where("source_user_id = ? OR target_user_id = ?", userID, userID)
+	}
+	var merges []models.AccountMerge
+	err := query.Find(&merges).Error
+	return merges, err
+}
+
+// reownColumns sets user_id. Analysis results also remember the owner their checksum was sealed
+// for, the first time they are re-owned, and forget it once they are back with that owner.
+// GORM writes the assignments in key order, so even on MySQL (left to right) sealed_user_id
+// reads the old user_id.
+func reownColumns(table string, toUserID uint) map[string]interface{} {
+	columns := map[string]interface{}{"user_id": toUserID}
+	if table == "analysis_results" {
+		columns["sealed_user_id"] = gorm.Expr("CASE WHEN COALESCE(sealed_user_id, user_id) = ? THEN NULL ELSE COALESCE(sealed_user_id, user_id) END", toUserID)
+	}
+	return columns
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"back_wa/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// NotificationRepo stores in-app notifications (notifications)
+type NotificationRepo interface {
+	Create(ctx context.Context, notification *models.Notification) error
+	// ListByUser returns the user's newest notifications first
+	ListByUser(ctx context.Context, userID uint, unreadOnly bool, limit int) ([]models.Notification, error)
+	CountUnread(ctx context.Context, userID uint) (int64, error)
+	// MarkRead marks the user's unread notifications in ids (all when empty) as read at
+	MarkRead(ctx context.Context, userID uint, ids []uint, at time.Time) (int64, error)
+}
+
+type gormNotificationRepo struct {
+	conn Conn
+}
+
+// NewNotificationRepo creates a GORM-backed NotificationRepo on conn (nil = DefaultConn)
+func NewNotificationRepo(conn Conn) NotificationRepo {
+	return &gormNotificationRepo{conn: orDefault(conn)}
+}
+
+func (r *gormNotificationRepo) Create(ctx context.Context, notification *models.Notification) error {
+	return r.conn(ctx).Create(notification).Error
+}
+
+func (r *gormNotificationRepo) ListByUser(ctx context.Context, userID uint, unreadOnly bool, limit int) ([]models.Notification, error) {
+	query := r.conn(ctx).Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var notifications []models.Notification
+	err := query.Order("created_at DESC").Limit(limit).Find(&notifications).Error
+	return notifications, err
+}
+
+func (r *gormNotificationRepo) CountUnread(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.conn(ctx).Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+func (r *gormNotificationRepo) MarkRead(ctx context.Context, userID uint, ids []uint, at time.Time) (int64, error) {
+	query := r.conn(ctx).Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+	res := query.Update("read_at", at)
+	return res.RowsAffected, res.Error
+}
+
+// PushRepo stores device push tokens (push_tokens) and per-user push preferences (push_preferences)
+type PushRepo interface {
+	// FindToken looks a device token up by its SHA-256 hash; gorm.ErrRecordNotFound when unknown
+	FindToken(ctx context.Context, tokenHash string) (*models.PushToken, error)
+	SaveToken(ctx context.Context, token *models.PushToken) error
+	DeleteToken(ctx context.Context, userID uint, tokenHash string) error
+	DeleteTokenByID(ctx context.Context, id uint) error
+	ListTokens(ctx context.Context, userID uint) ([]models.PushToken, error)
+	TouchToken(ctx context.Context, id uint, at time.Time) error
+
+	// FindPreferences loads the user's stored preferences into pref; reports false when none are stored
+	FindPreferences(ctx context.Context, userID uint, pref *models.PushPreference) (bool, error)
+	// SavePreferences writes every column of pref, including false toggles
+	SavePreferences(ctx context.Context, pref *models.PushPreference) error
+
+	// ListDigestCandidates returns up to limit preferences with a weekly or monthly email digest
+	// that was never sent or last sent at or before sentBefore, least recently sent first
+	ListDigestCandidates(ctx context.Context, sentBefore time.Time, limit int) ([]models.PushPreference, error)
+	// ClaimDigest moves the user's digest_sent_at from sentAt to now; reports false when another
+	// instance claimed the same digest first
+	ClaimDigest(ctx context.Context, userID uint, sentAt *time.Time, now time.Time) (bool, error)
+	// SetDigestSentAt overwrites the user's digest_sent_at (nil = never sent)
+	SetDigestSentAt(ctx context.Context, userID uint, at *time.Time) error
+}
+
+type gormPushRepo struct {
+	conn Conn
+}
+
+// NewPushRepo creates a GORM-backed PushRepo on conn (nil = DefaultConn)
+func NewPushRepo(conn Conn) PushRepo {
+	return &gormPushRepo{conn: orDefault(conn)}
+}
+
+func (r *gormPushRepo) FindToken(ctx context.Context, tokenHash string) (*models.PushToken, error) {
+	var token models.PushToken
+	if err := r.conn(ctx).Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *gormPushRepo) SaveToken(ctx context.Context, token *models.PushToken) error {
+	return r.conn(ctx).Save(token).Error
+}
+
+func (r *gormPushRepo) DeleteToken(ctx context.Context, userID uint, tokenHash string) error {
+	return r.conn(ctx).Where("token_hash = ? AND user_id = ?", tokenHash, userID).Delete(&models.PushToken{}).Error
+}
+
+func (r *gormPushRepo) DeleteTokenByID(ctx context.Context, id uint) error {
+	return r.conn(ctx).Delete(&models.PushToken{}, id).Error
+}
+
+func (r *gormPushRepo) ListTokens(ctx context.Context, userID uint) ([]models.PushToken, error) {
+	var tokens []models.PushToken
+	err := r.conn(ctx).Where("user_id = ?", userID).Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *gormPushRepo) TouchToken(ctx context.Context, id uint, at time.Time) error {
+	return r.conn(ctx).Model(&models.PushToken{}).Where("id = ?", id).UpdateColumn("last_used_at", at).Error
+}
+
+func (r *gormPushRepo) FindPreferences(ctx context.Context, userID uint, pref *models.PushPreference) (bool, error) {
+	err := r.conn(ctx).Where("user_id = ?", userID).First(pref).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *gormPushRepo) SavePreferences(ctx context.Context, pref *models.PushPreference) error {
+	// Select("*") so explicit false values are written instead of the column defaults
+	return r.conn(ctx).Select("*").Save(pref).Error
+}
+
+func (r *gormPushRepo) ListDigestCandidates(ctx context.Context, sentBefore time.Time, limit int) ([]models.PushPreference, error) {
+	var prefs []models.PushPreference
+	err := r.conn(ctx).Where("email_digest IN ? AND (digest_sent_at IS NULL OR digest_sent_at <= ?)",
+		[]string{models.DigestWeekly, models.DigestMonthly}, sentBefore).
+		Order("digest_sent_at ASC").Limit(limit).Find(&prefs).Error
+	return prefs, err
+}
+
+func (r *gormPushRepo) ClaimDigest(ctx context.Context, userID uint, sentAt *time.Time, now time.Time) (bool, error) {
+	query := r.conn(ctx).Model(&models.PushPreference{}).Where("user_id = ?", userID)
+	if sentAt == nil {
+		query = query.Where("digest_sent_at IS NULL")
+	} else {
+		query = query.Where("digest_sent_at = ?", *sentAt)
+	}
+	result := query.UpdateColumn("digest_sent_at", now)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *gormPushRepo) SetDigestSentAt(ctx context.Context, userID uint, at *time.Time) error {
+	return r.conn(ctx).Model(&models.PushPreference{}).Where("user_id = ?", userID).UpdateColumn("digest_sent_at", at).Error
+}
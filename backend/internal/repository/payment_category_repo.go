@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"back_wa/internal/models"
+)
+
+// PaymentCategoryRepo stores the payment categories and their prices (payment_categories).
+// Lookups return gorm.ErrRecordNotFound when nothing matches.
+type PaymentCategoryRepo interface {
+	// List returns all categories, cheapest first
+	List(ctx context.Context) ([]models.PaymentCategory, error)
+	Create(ctx context.Context, category *models.PaymentCategory) error
+	FindByID(ctx context.Context, id int) (*models.PaymentCategory, error)
+	// FindActive returns the active category with id when it is set, else with name (case-insensitive)
+	FindActive(ctx context.Context, id int, name string) (*models.PaymentCategory, error)
+	CountActive(ctx context.Context) (int64, error)
+	// NameTaken reports whether a category other than id uses name (case-insensitive)
+	NameTaken(ctx context.Context, name string, id int) (bool, error)
+	// Update writes the name, description, price and active flag of category
+	Update(ctx context.Context, category *models.PaymentCategory) error
+	// Delete removes the category; reports false when there is none with id
+	Delete(ctx context.Context, id int) (bool, error)
+}
+
+type gormPaymentCategoryRepo struct {
+	conn Conn
+}
+
+// NewPaymentCategoryRepo creates a GORM-backed PaymentCategoryRepo on conn (nil = DefaultConn)
+func NewPaymentCategoryRepo(conn Conn) PaymentCategoryRepo {
+	return &gormPaymentCategoryRepo{conn: orDefault(conn)}
+}
+
+func (r *gormPaymentCategoryRepo) List(ctx context.Context) ([]models.PaymentCategory, error) {
+	var categories []models.PaymentCategory
+	err := r.conn(ctx).Order("price ASC, id ASC").Find(&categories).Error
+	return categories, err
+}
+
+func (r *gormPaymentCategoryRepo) Create(ctx context.Context, category *models.PaymentCategory) error {
+	return r.conn(ctx).Create(category).Error
+}
+
+func (r *gormPaymentCategoryRepo) FindByID(ctx context.Context, id int) (*models.PaymentCategory, error) {
+	var category models.PaymentCategory
+	if err := r.conn(ctx).First(&category, id).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+func (r *gormPaymentCategoryRepo) FindActive(ctx context.Context, id int, name string) (*models.PaymentCategory, error) {
+	query := r.conn(ctx).Where("is_active = ?", true)
+	if id > 0 {
+		query = query.Where("id = ?", id)
+	} else {
+		query = query.Where("LOWER(name) = ?", strings.ToLower(strings.TrimSpace(name)))
+	}
+	var category models.PaymentCategory
+	if err := query.First(&category).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+func (r *gormPaymentCategoryRepo) CountActive(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.conn(ctx).Model(&models.PaymentCategory{}).Where("is_active = ?", true).Count(&count).Error
+	return count, err
+}
+
+func (r *gormPaymentCategoryRepo) NameTaken(ctx context.Context, name string, id int) (bool, error) {
+	var count int64
+	err := r.conn(ctx).Model(&models.PaymentCategory{}).Where("LOWER(name) = ? AND id <> ?", strings.ToLower(name), id).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormPaymentCategoryRepo) Update(ctx context.Context, category *models.PaymentCategory) error {
+	// Select the columns so an inactive category is written too
+	return r.conn(ctx).Model(category).Select("name", "description", "price", "is_active").Updates(category).Error
+}
+
+func (r *gormPaymentCategoryRepo) Delete(ctx context.Context, id int) (bool, error) {
+	result := r.conn(ctx).Delete(&models.PaymentCategory{}, id)
+	return result.RowsAffected > 0, result.Error
+}
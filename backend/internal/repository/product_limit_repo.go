@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+
+	"back_wa/internal/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// ProductLimitRepo stores the saved product limits per tier (product_limits)
+type ProductLimitRepo interface {
+	List(ctx context.Context) ([]models.ProductLimit, error)
+	// Upsert creates or replaces the limits of limit.Tier
+	Upsert(ctx context.Context, limit *models.ProductLimit) error
+}
+
+type gormProductLimitRepo struct {
+	conn Conn
+}
+
+// NewProductLimitRepo creates a GORM-backed ProductLimitRepo on conn (nil = DefaultConn)
+func NewProductLimitRepo(conn Conn) ProductLimitRepo {
+	return &gormProductLimitRepo{conn: orDefault(conn)}
+}
+
+func (r *gormProductLimitRepo) List(ctx context.Context) ([]models.ProductLimit, error) {
+	var saved []models.ProductLimit
+	err := r.conn(ctx).Find(&saved).Error
+	return saved, err
+}
+
+func (r *gormProductLimitRepo) Upsert(ctx context.Context, limit *models.ProductLimit) error {
+	return r.conn(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tier"}},
+		DoUpdates: clause.AssignmentColumns([]string{"max_sessions", "scans_per_day", "history_size", "updated_by", "updated_at"}),
+	}).Create(limit).Error
+}
@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"back_wa/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// RatingCount is the number of feedback entries with one rating
+type RatingCount struct {
+	Rating int
+	Count  int64
+}
+
+// UserScanCount is the number of analyses of one user
+type UserScanCount struct {
+	UserID uint
+	Scans  int64
+}
+
+// RenewalCounts counts paying users and those among them with two or more payments
+type RenewalCounts struct {
+	PayingUsers  int64
+	RepeatPayers int64
+}
+
+// ChurnCandidate is a user who paid exactly once, with the scans made since
+type ChurnCandidate struct {
+	UserID            uint
+	PaidAt            time.Time
+	Amount            float64
+	ScansSincePayment int64
+}
+
+// AnalysisCostSums sums the serving cost recorded on analysis results
+type AnalysisCostSums struct {
+	UserID        uint // 0 for the totals over all users
+	Analyses      int64
+	DurationMs    int64
+	WhatsAppCalls int64 `gorm:"column:whatsapp_calls"`
+	DBWrites      int64
+}
+
+// ReportRepo aggregates feedback, analyses and payments for the admin reports
+type ReportRepo interface {
+	// RatingCounts counts the feedback updated at or after since per rating
+	RatingCounts(ctx context.Context, since time.Time) ([]RatingCount, error)
+	// ScanCounts counts the analyses created at or after since per user
+	ScanCounts(ctx context.Context, since time.Time) ([]UserScanCount, error)
+	// Renewals counts the users with paid transactions over all time
+	Renewals(ctx context.Context) (RenewalCounts, error)
+	// CountChurnCandidates counts the users whose only payment was at or before paidBefore and
+	// who scanned at most once since
+	CountChurnCandidates(ctx context.Context, paidBefore time.Time) (int64, error)
+	// ListChurnCandidates returns up to limit of those users, oldest payment first
+	ListChurnCandidates(ctx context.Context, paidBefore time.Time, limit int) ([]ChurnCandidate, error)
+	// LastScans returns the time of the newest analysis of each user in userIDs that has one
+	LastScans(ctx context.Context, userIDs []uint) (map[uint]time.Time, error)
+	// LowestRatings returns the lowest feedback rating of each user in userIDs that gave one
+	LowestRatings(ctx context.Context, userIDs []uint) (map[uint]int, error)
+
+	// AnalysisCost sums the cost of the analyses created at or after since, deleted ones included
+	AnalysisCost(ctx context.Context, since time.Time) (AnalysisCostSums, error)
+	// AnalysisCostByUser is AnalysisCost per user: userID only, or the limit users with the
+	// highest total analysis time when userID is 0
+	AnalysisCostByUser(ctx context.Context, since time.Time, userID uint, limit int) ([]AnalysisCostSums, error)
+}
+
+type gormReportRepo struct {
+	conn Conn
+}
+
+// NewReportRepo creates a GORM-backed ReportRepo on conn (nil = DefaultConn)
+func NewReportRepo(conn Conn) ReportRepo {
+	return &gormReportRepo{conn: orDefault(conn)}
+}
+
+func (r *gormReportRepo) RatingCounts(ctx context.Context, since time.Time) ([]RatingCount, error) {
+	var counts []RatingCount
+	err := r.conn(ctx).Model(&models.AnalysisFeedback{}).Select("rating, COUNT(*) AS count").
+		Where("updated_at >= ?", since).Group("rating").Scan(&counts).Error
+	return counts, err
+}
+
+func (r *gormReportRepo) ScanCounts(ctx context.Context, since time.Time) ([]UserScanCount, error) {
+	var counts []UserScanCount
+	err := r.conn(ctx).Model(&models.AnalysisResult{}).Select("user_id, COUNT(*) AS scans").
+		Where("created_at >= ?", since).Group("user_id").Scan(&counts).Error
+	return counts, err
+}
+
+func (r *gormReportRepo) Renewals(ctx context.Context) (RenewalCounts, error) {
+	db := r.conn(ctx)
+	var counts RenewalCounts
+	paidPerUser := db.Model(&models.Transaction{}).Select("user_id, COUNT(*) AS payments").
+		Where("status = ?", "paid").Group("user_id")
+	err := db.Table("(?) AS p", paidPerUser).
+		Select("COUNT(*) AS paying_users, COALESCE(SUM(CASE WHEN payments >= 2 THEN 1 ELSE 0 END), 0) AS repeat_payers").
+		Scan(&counts).Error
+	return counts, err
+}
+
+// churnCandidates groups the users who paid exactly once, at or before paidBefore, with their
+// analyses since the payment, keeping those with at most one
+func (r *gormReportRepo) churnCandidates(db *gorm.DB, paidBefore time.Time) *gorm.DB {
+	paidOnce := db.Model(&models.Transaction{}).
+		Select("user_id, MAX(paid_at) AS paid_at, MAX(amount) AS amount").
+		Where("status = ? AND paid_at IS NOT NULL", "paid").
+		Group("user_id").
+		Having("COUNT(*) = 1 AND MAX(paid_at) <= ?", paidBefore)
+	return db.Table("(?) AS p", paidOnce).
+		Joins("LEFT JOIN analysis_results a ON a.user_id = p.user_id AND a.created_at > p.paid_at AND a.deleted_at IS NULL").
+		Group("p.user_id, p.paid_at, p.amount").
+		Having("COUNT(a.id) <= 1")
+}
+
+func (r *gormReportRepo) CountChurnCandidates(ctx context.Context, paidBefore time.Time) (int64, error) {
+	db := r.conn(ctx)
+	var count int64
+	err := db.Table("(?) AS r", r.churnCandidates(db, paidBefore).Select("p.user_id")).Count(&count).Error
+	return count, err
+}
+
+func (r *gormReportRepo) ListChurnCandidates(ctx context.Context, paidBefore time.Time, limit int) ([]ChurnCandidate, error) {
+	var candidates []ChurnCandidate
+	err := r.churnCandidates(r.conn(ctx), paidBefore).
+		Select("p.user_id, p.paid_at, p.amount, COUNT(a.id) AS scans_since_payment").
+		Order("p.paid_at ASC").Limit(limit).Scan(&candidates).Error
+	return candidates, err
+}
+
+func (r *gormReportRepo) LastScans(ctx context.Context, userIDs []uint) (map[uint]time.Time, error) {
+	var rows []struct {
+		UserID     uint
+		LastScanAt time.Time
+	}
+	if err := r.conn(ctx).Model(&models.AnalysisResult{}).Select("user_id, MAX(created_at) AS last_scan_at").
+		Where("user_id IN ?", userIDs).Group("user_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	lastScans := make(map[uint]time.Time, len(rows))
+	for _, row := range rows {
+		lastScans[row.UserID] = row.LastScanAt
+	}
+	return lastScans, nil
+}
+
+func (r *gormReportRepo) LowestRatings(ctx context.Context, userIDs []uint) (map[uint]int, error) {
+	var rows []struct {
+		UserID uint
+		Rating int
+	}
+	if err := r.conn(ctx).Model(&models.AnalysisFeedback{}).Select("user_id, MIN(rating) AS rating").
+		Where("user_id IN ?", userIDs).Group("user_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	ratings := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		ratings[row.UserID] = row.Rating
+	}
+	return ratings, nil
+}
+
+const analysisCostColumns = "COUNT(*) AS analyses, COALESCE(SUM(duration_ms), 0) AS duration_ms, " +
+	"COALESCE(SUM(whatsapp_calls), 0) AS whatsapp_calls, COALESCE(SUM(db_writes), 0) AS db_writes"
+
+func (r *gormReportRepo) analysesSince(ctx context.Context, since time.Time) *gorm.DB {
+	return r.conn(ctx).Unscoped().Model(&models.AnalysisResult{}).Where("created_at >= ?", since)
+}
+
+func (r *gormReportRepo) AnalysisCost(ctx context.Context, since time.Time) (AnalysisCostSums, error) {
+	var sums AnalysisCostSums
+	err := r.analysesSince(ctx, since).Select(analysisCostColumns).Scan(&sums).Error
+	return sums, err
+}
+
+func (r *gormReportRepo) AnalysisCostByUser(ctx context.Context, since time.Time, userID uint, limit int) ([]AnalysisCostSums, error) {
+	query := r.analysesSince(ctx, since).Select("user_id, " + analysisCostColumns).Group("user_id")
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	} else {
+		query = query.Order("SUM(duration_ms) DESC").Limit(limit)
+	}
+	var sums []AnalysisCostSums
+	err := query.Scan(&sums).Error
+	return sums, err
+}
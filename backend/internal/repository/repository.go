@@ -0,0 +1,139 @@
+// Package repository holds the data access layer used by services, handlers and the
+// WhatsApp manager. Implementations are injected through constructors instead of
+// reaching for the global database handle, so tests and additional databases can
+// supply their own.
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"back_wa/internal/database"
+	"back_wa/internal/fieldcrypt"
+
+	"gorm.io/gorm"
+)
+
+// Conn resolves the connection a repository runs on for ctx.
+// A ctx carrying a tenant scope must come back bound to it so tenant isolation keeps applying.
+type Conn func(ctx context.Context) *gorm.DB
+
+// DefaultConn is the shared application database (database.WithContext).
+// It is resolved per call so a reconnect that swaps the pool is picked up.
+func DefaultConn(ctx context.Context) *gorm.DB {
+	return database.WithContext(ctx)
+}
+
+// Repositories bundles the repositories wired into the application
+type Repositories struct {
+	Users             UserRepo
+	Analyses          AnalysisRepo
+	Transactions      TransactionRepo
+	Sessions          SessionRepo
+	Chats             ChatRepo
+	Jobs              AnalysisJobRepo
+	BulkScans         BulkScanRepo
+	Legal             LegalRepo
+	Notifications     NotificationRepo
+	Pushes            PushRepo
+	AuthSessions      AuthSessionRepo
+	Subscriptions     SubscriptionRepo
+	ProductLimits     ProductLimitRepo
+	Coupons           CouponRepo
+	PaymentCategories PaymentCategoryRepo
+	Tenants           TenantRepo
+	Accounts          AccountRepo
+	Merges            MergeRepo
+	Admin             AdminRepo
+	Reports           ReportRepo
+	DataAccess        DataAccessRepo
+	Feedback          FeedbackRepo
+	Goals             GoalRepo
+	ShareLinks        ShareLinkRepo
+	Usage             UsageRepo
+	SessionEvents     SessionEventRepo
+	ScanSchedules     ScanScheduleRepo
+	Scoring           ScoringRepo
+	Announcements     AnnouncementRepo
+	Webhooks          WebhookRepo
+	WebhookEvents     WebhookEventRepo
+	DataKeys          DataKeyRepo
+}
+
+// New creates GORM-backed repositories on conn (nil = DefaultConn)
+func New(conn Conn) *Repositories {
+	return &Repositories{
+		Users:             NewUserRepo(conn),
+		Analyses:          NewAnalysisRepo(conn),
+		Transactions:      NewTransactionRepo(conn),
+		Sessions:          NewSessionRepo(conn),
+		Chats:             NewChatRepo(conn),
+		Jobs:              NewAnalysisJobRepo(conn),
+		BulkScans:         NewBulkScanRepo(conn),
+		Legal:             NewLegalRepo(conn),
+		Notifications:     NewNotificationRepo(conn),
+		Pushes:            NewPushRepo(conn),
+		AuthSessions:      NewAuthSessionRepo(conn),
+		Subscriptions:     NewSubscriptionRepo(conn),
+		ProductLimits:     NewProductLimitRepo(conn),
+		Coupons:           NewCouponRepo(conn),
+		PaymentCategories: NewPaymentCategoryRepo(conn),
+		Tenants:           NewTenantRepo(conn),
+		Accounts:          NewAccountRepo(conn),
+		Merges:            NewMergeRepo(conn),
+		Admin:             NewAdminRepo(conn),
+		Reports:           NewReportRepo(conn),
+		DataAccess:        NewDataAccessRepo(conn),
+		Feedback:          NewFeedbackRepo(conn),
+		Goals:             NewGoalRepo(conn),
+		ShareLinks:        NewShareLinkRepo(conn),
+		Usage:             NewUsageRepo(conn),
+		SessionEvents:     NewSessionEventRepo(conn),
+		ScanSchedules:     NewScanScheduleRepo(conn),
+		Scoring:           NewScoringRepo(conn),
+		Announcements:     NewAnnouncementRepo(conn),
+		Webhooks:          NewWebhookRepo(conn),
+		WebhookEvents:     NewWebhookEventRepo(conn),
+		DataKeys:          NewDataKeyRepo(conn),
+	}
+}
+
+var (
+	defaultRepos     *Repositories
+	defaultReposOnce sync.Once
+)
+
+// Default returns the repositories on the shared application database
+func Default() *Repositories {
+	defaultReposOnce.Do(func() {
+		defaultRepos = New(DefaultConn)
+	})
+	return defaultRepos
+}
+
+// orDefault returns conn, falling back to DefaultConn
+func orDefault(conn Conn) Conn {
+	if conn == nil {
+		return DefaultConn
+	}
+	return conn
+}
+
+// phoneNumberIs matches rows whose encrypted phone_number equals phoneNumber through its blind
+// index. Rows written before field encryption (no hash yet) are still matched on the plaintext
+// until the encrypt-backfill command has run.
+func phoneNumberIs(phoneNumber string) func(*gorm.DB) *gorm.DB {
+	hash := fieldcrypt.BlindIndex(phoneNumber)
+	return func(db *gorm.DB) *gorm.DB {
+		if hash == "" {
+			return db.Where("phone_number = ?", phoneNumber)
+		}
+		return db.Where("phone_number_hash = ? OR ((phone_number_hash IS NULL OR phone_number_hash = '') AND phone_number = ?)",
+			hash, phoneNumber)
+	}
+}
+
+// txConn pins every call to an open transaction
+func txConn(tx *gorm.DB) Conn {
+	return func(context.Context) *gorm.DB { return tx }
+}
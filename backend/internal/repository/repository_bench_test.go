@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"back_wa/internal/fieldcrypt"
+	"back_wa/internal/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// benchConn opens a seeded in-memory SQLite database with or without prepared statement
+// caching (DB_PREPARE_STMT), so each hot query can be compared both ways
+func benchConn(b *testing.B, prepare bool) Conn {
+	b.Helper()
+
+	// Phone numbers are stored encrypted, as in production
+	dataKey, err := fieldcrypt.NewDataKey()
+	if err != nil {
+		b.Fatalf("data key: %v", err)
+	}
+	indexKey, err := fieldcrypt.NewDataKey()
+	if err != nil {
+		b.Fatalf("index key: %v", err)
+	}
+	keyring, err := fieldcrypt.NewKeyring("bench", map[string][]byte{"bench": dataKey}, indexKey)
+	if err != nil {
+		b.Fatalf("keyring: %v", err)
+	}
+	fieldcrypt.Install(keyring)
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		Logger:      logger.Default.LogMode(logger.Silent),
+		PrepareStmt: prepare,
+	})
+	if err != nil {
+		b.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		b.Fatalf("sql db: %v", err)
+	}
+	// Every connection to :memory: is a separate database
+	sqlDB.SetMaxOpenConns(1)
+	b.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(&models.User{}, &models.Transaction{}); err != nil {
+		b.Fatalf("migrate: %v", err)
+	}
+	for u := 1; u <= 50; u++ {
+		user := models.User{Email: fmt.Sprintf("user%d@example.com", u), Username: fmt.Sprintf("user%d", u), PasswordHash: "x", Role: "user"}
+		if err := db.Create(&user).Error; err != nil {
+			b.Fatalf("seed user: %v", err)
+		}
+		for t := 0; t < 20; t++ {
+			status := "paid"
+			if t%3 == 0 {
+				status = "pending"
+			}
+			phone := fmt.Sprintf("62812%08d", u*100+t)
+			transaction := models.Transaction{
+				UserID:          int(user.ID),
+				ExternalID:      fmt.Sprintf("bench-%d-%d", u, t),
+				InvoiceID:       fmt.Sprintf("inv-%d-%d", u, t),
+				PaymentMethod:   "invoice",
+				PhoneNumber:     phone,
+				PhoneNumberHash: fieldcrypt.BlindIndex(phone),
+				Amount:          50000,
+				Status:          status,
+			}
+			if err := db.Create(&transaction).Error; err != nil {
+				b.Fatalf("seed transaction: %v", err)
+			}
+		}
+	}
+	return txConn(db)
+}
+
+// benchmarkPrepared runs fn as two sub-benchmarks, without and with prepared statements
+func benchmarkPrepared(b *testing.B, fn func(b *testing.B, conn Conn)) {
+	for _, prepare := range []bool{false, true} {
+		b.Run(fmt.Sprintf("prepare=%t", prepare), func(b *testing.B) {
+			conn := benchConn(b, prepare)
+			b.ReportAllocs()
+			b.ResetTimer()
+			fn(b, conn)
+		})
+	}
+}
+
+// BenchmarkPaidPhoneNumbers is the payment check every /api/wa/analyze request makes
+func BenchmarkPaidPhoneNumbers(b *testing.B) {
+	benchmarkPrepared(b, func(b *testing.B, conn Conn) {
+		repo := NewTransactionRepo(conn)
+		ctx := context.Background()
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.PaidPhoneNumbers(ctx, i%50+1); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkCountPaid is the per-phone paid check of the payment status endpoint
+func BenchmarkCountPaid(b *testing.B) {
+	benchmarkPrepared(b, func(b *testing.B, conn Conn) {
+		repo := NewTransactionRepo(conn)
+		ctx := context.Background()
+		for i := 0; i < b.N; i++ {
+			user := i%50 + 1
+			if _, err := repo.CountPaid(ctx, user, fmt.Sprintf("62812%08d", user*100+i%20)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkFindUserByID is the user lookup behind every authenticated token
+func BenchmarkFindUserByID(b *testing.B) {
+	benchmarkPrepared(b, func(b *testing.B, conn Conn) {
+		repo := NewUserRepo(conn)
+		ctx := context.Background()
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.FindByID(ctx, uint(i%50+1)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"back_wa/internal/models"
+)
+
+// ScanScheduleRepo stores the users' scheduled re-analyses.
+// Lookups return gorm.ErrRecordNotFound when nothing matches.
+type ScanScheduleRepo interface {
+	FindByUser(ctx context.Context, userID uint) (*models.ScanSchedule, error)
+	// Save writes every column of the schedule, including false toggles
+	Save(ctx context.Context, schedule *models.ScanSchedule) error
+	// DeleteByUser removes the user's schedule; reports false when there was none
+	DeleteByUser(ctx context.Context, userID uint) (bool, error)
+	// ListDue returns up to limit enabled schedules whose next run is at or before now, oldest first
+	ListDue(ctx context.Context, now time.Time, limit int) ([]models.ScanSchedule, error)
+	// Claim moves the enabled schedule's next run from runAt to next and stamps last_run_at; reports
+	// false when another instance claimed the run first
+	Claim(ctx context.Context, id uint, runAt, next, now time.Time) (bool, error)
+	UpdateColumns(ctx context.Context, id uint, columns map[string]interface{}) error
+}
+
+type gormScanScheduleRepo struct {
+	conn Conn
+}
+
+// NewScanScheduleRepo creates a GORM-backed ScanScheduleRepo on conn (nil = DefaultConn)
+func NewScanScheduleRepo(conn Conn) ScanScheduleRepo {
+	return &gormScanScheduleRepo{conn: orDefault(conn)}
+}
+
+func (r *gormScanScheduleRepo) FindByUser(ctx context.Context, userID uint) (*models.ScanSchedule, error) {
+	var schedule models.ScanSchedule
+	if err := r.conn(ctx).Where("user_id = ?", userID).First(&schedule).Error; err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (r *gormScanScheduleRepo) Save(ctx context.Context, schedule *models.ScanSchedule) error {
+	// Select("*") so an explicit false is written instead of the column default
+	return r.conn(ctx).Select("*").Save(schedule).Error
+}
+
+func (r *gormScanScheduleRepo) DeleteByUser(ctx context.Context, userID uint) (bool, error) {
+	result := r.conn(ctx).Where("user_id = ?", userID).Delete(&models.ScanSchedule{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *gormScanScheduleRepo) ListDue(ctx context.Context, now time.Time, limit int) ([]models.ScanSchedule, error) {
+	var schedules []models.ScanSchedule
+	err := r.conn(ctx).Where("enabled = ? AND next_run_at <= ?", true, now).
+		Order("next_run_at ASC").Limit(limit).Find(&schedules).Error
+	return schedules, err
+}
+
+func (r *gormScanScheduleRepo) Claim(ctx context.Context, id uint, runAt, next, now time.Time) (bool, error) {
+	result := r.conn(ctx).Model(&models.ScanSchedule{}).
+		Where("id = ? AND enabled = ? AND next_run_at = ?", id, true, runAt).
+		UpdateColumns(map[string]interface{}{"next_run_at": next, "last_run_at": now})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *gormScanScheduleRepo) UpdateColumns(ctx context.Context, id uint, columns map[string]interface{}) error {
+	return r.conn(ctx).Model(&models.ScanSchedule{}).Where("id = ?", id).UpdateColumns(columns).Error
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"back_wa/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ScoringRepo stores the versions of the scoring configuration (scoring_configs).
+// Parameters are returned encoded; callers decode them.
+type ScoringRepo interface {
+	// Latest returns the newest version; gorm.ErrRecordNotFound when none was saved
+	Latest(ctx context.Context) (*models.ScoringConfig, error)
+	// List returns every version, oldest first
+	List(ctx context.Context) ([]models.ScoringConfig, error)
+	// Replace archives the version in effect with effective_to = at and creates config, in one transaction
+	Replace(ctx context.Context, config *models.ScoringConfig, at time.Time) error
+}
+
+type gormScoringRepo struct {
+	conn Conn
+}
+
+// NewScoringRepo creates a GORM-backed ScoringRepo on conn (nil = DefaultConn)
+func NewScoringRepo(conn Conn) ScoringRepo {
+	return &gormScoringRepo{conn: orDefault(conn)}
+}
+
+func (r *gormScoringRepo) Latest(ctx context.Context) (*models.ScoringConfig, error) {
+	var config models.ScoringConfig
+	if err := r.conn(ctx).Order("id DESC").First(&config).Error; err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+func (r *gormScoringRepo) List(ctx context.Context) ([]models.ScoringConfig, error) {
+	var configs []models.ScoringConfig
+	err := r.conn(ctx).Order("id ASC").Find(&configs).Error
+	return configs, err
+}
+
+func (r *gormScoringRepo) Replace(ctx context.Context, config *models.ScoringConfig, at time.Time) error {
+	return r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.ScoringConfig{}).Where("effective_to IS NULL").Update("effective_to", at).Error; err != nil {
+			return err
+		}
+		return tx.Create(config).Error
+	})
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+
+	"back_wa/internal/models"
+)
+
+// SessionEventRepo stores the ban/restriction events of WhatsApp sessions
+type SessionEventRepo interface {
+	Create(ctx context.Context, event *models.WhatsAppSessionEvent) error
+}
+
+type gormSessionEventRepo struct {
+	conn Conn
+}
+
+// NewSessionEventRepo creates a GORM-backed SessionEventRepo on conn (nil = DefaultConn)
+func NewSessionEventRepo(conn Conn) SessionEventRepo {
+	return &gormSessionEventRepo{conn: orDefault(conn)}
+}
+
+func (r *gormSessionEventRepo) Create(ctx context.Context, event *models.WhatsAppSessionEvent) error {
+	return r.conn(ctx).Create(event).Error
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"back_wa/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// SessionRepo stores the per-user WhatsApp session records (whatsapp_sessions)
+type SessionRepo interface {
+	// Upsert creates or updates the user's session row, keyed by user_id
+	Upsert(ctx context.Context, session *models.WhatsAppSession) error
+	UpdateStatus(ctx context.Context, userID uint, status string) error
+	ListByStatus(ctx context.Context, status string) ([]models.WhatsAppSession, error)
+	Delete(ctx context.Context, userID uint) error
+	// UserIDsWithSession returns which of userIDs have a session row
+	UserIDsWithSession(ctx context.Context, userIDs []uint) ([]uint, error)
+	// ClearExpiredQR drops qr_expires_at markers that are past now
+	ClearExpiredQR(ctx context.Context, now time.Time) error
+}
+
+type gormSessionRepo struct {
+	conn Conn
+}
+
+// NewSessionRepo creates a GORM-backed SessionRepo on conn (nil = DefaultConn)
+func NewSessionRepo(conn Conn) SessionRepo {
+	return &gormSessionRepo{conn: orDefault(conn)}
+}
+
+func (r *gormSessionRepo) Upsert(ctx context.Context, session *models.WhatsAppSession) error {
+	db := r.conn(ctx)
+
+	var existing models.WhatsAppSession
+	err := db.Where("user_id = ?", session.UserID).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return db.Create(session).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	existing.Status = session.Status
+	existing.DeviceID = session.DeviceID
+	existing.LastActivity = session.LastActivity
+	existing.QRExpiresAt = session.QRExpiresAt
+	if err := db.Save(&existing).Error; err != nil {
+		return err
+	}
+	*session = existing
+	return nil
+}
+
+func (r *gormSessionRepo) UpdateStatus(ctx context.Context, userID uint, status string) error {
+	return r.conn(ctx).Model(&models.WhatsAppSession{}).Where("user_id = ?", userID).Update("status", status).Error
+}
+
+func (r *gormSessionRepo) ListByStatus(ctx context.Context, status string) ([]models.WhatsAppSession, error) {
+	var sessions []models.WhatsAppSession
+	err := r.conn(ctx).Where("status = ?", status).Order("last_activity DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+func (r *gormSessionRepo) Delete(ctx context.Context, userID uint) error {
+	return r.conn(ctx).Where("user_id = ?", userID).Delete(&models.WhatsAppSession{}).Error
+}
+
+func (r *gormSessionRepo) UserIDsWithSession(ctx context.Context, userIDs []uint) ([]uint, error) {
+	var found []uint
+	if len(userIDs) == 0 {
+		return found, nil
+	}
+	err := r.conn(ctx).Model(&models.WhatsAppSession{}).Where("user_id IN ?", userIDs).Pluck("user_id", &found).Error
+	return found, err
+}
+
+func (r *gormSessionRepo) ClearExpiredQR(ctx context.Context, now time.Time) error {
+	return r.conn(ctx).Model(&models.WhatsAppSession{}).
+		Where("qr_expires_at IS NOT NULL AND qr_expires_at < ?", now).
+		Update("qr_expires_at", nil).Error
+}
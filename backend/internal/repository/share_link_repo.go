@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"back_wa/internal/models"
+)
+
+// ShareLinkRepo stores the signed links users mint for their analysis results.
+// Lookups return gorm.ErrRecordNotFound when nothing matches.
+type ShareLinkRepo interface {
+	Create(ctx context.Context, link *models.AnalysisShareLink) error
+	FindByLinkID(ctx context.Context, linkID string) (*models.AnalysisShareLink, error)
+	// Revoke stamps revoked_at on the user's link; reports false when the user has no such unrevoked link
+	Revoke(ctx context.Context, userID uint, linkID string, at time.Time) (bool, error)
+}
+
+type gormShareLinkRepo struct {
+	conn Conn
+}
+
+// NewShareLinkRepo creates a GORM-backed ShareLinkRepo on conn (nil = DefaultConn)
+func NewShareLinkRepo(conn Conn) ShareLinkRepo {
+	return &gormShareLinkRepo{conn: orDefault(conn)}
+}
+
+func (r *gormShareLinkRepo) Create(ctx context.Context, link *models.AnalysisShareLink) error {
+	return r.conn(ctx).Create(link).Error
+}
+
+func (r *gormShareLinkRepo) FindByLinkID(ctx context.Context, linkID string) (*models.AnalysisShareLink, error) {
+	var link models.AnalysisShareLink
+	if err := r.conn(ctx).Where("link_id = ?", linkID).First(&link).Error; err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (r *gormShareLinkRepo) Revoke(ctx context.Context, userID uint, linkID string, at time.Time) (bool, error) {
+	res := r.conn(ctx).Model(&models.AnalysisShareLink{}).
+		Where("link_id = ? AND user_id = ? AND revoked_at IS NULL", linkID, userID).
+		Update("revoked_at", at)
+	return res.RowsAffected > 0, res.Error
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"back_wa/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// SubscriptionRepo stores subscription plans (plans) and the users' subscriptions (subscriptions).
+// Lookups return gorm.ErrRecordNotFound when nothing matches.
+type SubscriptionRepo interface {
+	// Transaction runs fn with a repository bound to one database transaction
+	Transaction(ctx context.Context, fn func(tx SubscriptionRepo) error) error
+
+	// ListPlans returns the plans ordered by price; inactive plans only when includeInactive is set
+	ListPlans(ctx context.Context, includeInactive bool) ([]models.Plan, error)
+	FindPlan(ctx context.Context, id uint) (*models.Plan, error)
+	FindActivePlan(ctx context.Context, code string) (*models.Plan, error)
+	// PlanExists reports whether a plan (active or not) has code
+	PlanExists(ctx context.Context, code string) (bool, error)
+	CreatePlan(ctx context.Context, plan *models.Plan) error
+	// UpdatePlan writes the editable columns of plan, zero values included
+	UpdatePlan(ctx context.Context, plan *models.Plan) error
+
+	Create(ctx context.Context, subscription *models.Subscription) error
+	FindByID(ctx context.Context, id uint) (*models.Subscription, error)
+	// FindPending returns the user's newest pending subscription to the plan
+	FindPending(ctx context.Context, userID, planID uint) (*models.Subscription, error)
+	// FindLastEnding returns the user's active subscription that ends last, if it ends after t
+	FindLastEnding(ctx context.Context, userID uint, t time.Time) (*models.Subscription, error)
+	// FindCurrent returns the user's earliest started active subscription covering now
+	FindCurrent(ctx context.Context, userID uint, now time.Time) (*models.Subscription, error)
+	// SaveActivation writes the status, period, cycle and scan counter of subscription
+	SaveActivation(ctx context.Context, subscription *models.Subscription) error
+	// Cancel cancels the subscription when it is pending or active
+	Cancel(ctx context.Context, id uint) error
+	// RollCycle moves the cycle start of the subscription from "from" to "to" and resets its scan
+	// counter; reports false when the cycle no longer starts at "from"
+	RollCycle(ctx context.Context, id uint, from, to time.Time) (bool, error)
+	// ConsumeScan counts one scan against the cycle starting at cycleStart unless its allowance is
+	// used up; reports false when nothing was counted
+	ConsumeScan(ctx context.Context, id uint, cycleStart *time.Time) (bool, error)
+}
+
+type gormSubscriptionRepo struct {
+	conn Conn
+}
+
+// NewSubscriptionRepo creates a GORM-backed SubscriptionRepo on conn (nil = DefaultConn)
+func NewSubscriptionRepo(conn Conn) SubscriptionRepo {
+	return &gormSubscriptionRepo{conn: orDefault(conn)}
+}
+
+func (r *gormSubscriptionRepo) Transaction(ctx context.Context, fn func(tx SubscriptionRepo) error) error {
+	return r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&gormSubscriptionRepo{conn: txConn(tx)})
+	})
+}
+
+func (r *gormSubscriptionRepo) ListPlans(ctx context.Context, includeInactive bool) ([]models.Plan, error) {
+	query := r.conn(ctx).Order("price ASC, id ASC")
+	if !includeInactive {
+		query = query.Where("is_active = ?", true)
+	}
+	var plans []models.Plan
+	err := query.Find(&plans).Error
+	return plans, err
+}
+
+func (r *gormSubscriptionRepo) FindPlan(ctx context.Context, id uint) (*models.Plan, error) {
+	var plan models.Plan
+	if err := r.conn(ctx).First(&plan, id).Error; err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func (r *gormSubscriptionRepo) FindActivePlan(ctx context.Context, code string) (*models.Plan, error) {
+	var plan models.Plan
+	if err := r.conn(ctx).Where("code = ? AND is_active = ?", code, true).First(&plan).Error; err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func (r *gormSubscriptionRepo) PlanExists(ctx context.Context, code string) (bool, error) {
+	var count int64
+	err := r.conn(ctx).Model(&models.Plan{}).Where("code = ?", code).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormSubscriptionRepo) CreatePlan(ctx context.Context, plan *models.Plan) error {
+	return r.conn(ctx).Create(plan).Error
+}
+
+func (r *gormSubscriptionRepo) UpdatePlan(ctx context.Context, plan *models.Plan) error {
+	return r.conn(ctx).Model(plan).Select("code", "name", "description", "price", "currency", "scans_per_month", "months", "is_active").
+		Updates(plan).Error
+}
+
+func (r *gormSubscriptionRepo) Create(ctx context.Context, subscription *models.Subscription) error {
+	return r.conn(ctx).Create(subscription).Error
+}
+
+func (r *gormSubscriptionRepo) FindByID(ctx context.Context, id uint) (*models.Subscription, error) {
+	var subscription models.Subscription
+	if err := r.conn(ctx).First(&subscription, id).Error; err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+func (r *gormSubscriptionRepo) FindPending(ctx context.Context, userID, planID uint) (*models.Subscription, error) {
+	var subscription models.Subscription
+	err := r.conn(ctx).Where("user_id = ? AND plan_id = ? AND status = ?", userID, planID, models.SubscriptionPending).
+		Order("id DESC").First(&subscription).Error
+	if err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+func (r *gormSubscriptionRepo) FindLastEnding(ctx context.Context, userID uint, t time.Time) (*models.Subscription, error) {
+	var subscription models.Subscription
+	err := r.conn(ctx).Where("user_id = ? AND status = ? AND ends_at > ?", userID, models.SubscriptionActive, t).
+		Order("ends_at DESC").First(&subscription).Error
+	if err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+func (r *gormSubscriptionRepo) FindCurrent(ctx context.Context, userID uint, now time.Time) (*models.Subscription, error) {
+	var subscription models.Subscription
+	err := r.conn(ctx).Where("user_id = ? AND status = ? AND starts_at <= ? AND ends_at > ?", userID, models.SubscriptionActive, now, now).
+		Order("starts_at ASC").First(&subscription).Error
+	if err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+func (r *gormSubscriptionRepo) SaveActivation(ctx context.Context, subscription *models.Subscription) error {
+	return r.conn(ctx).Model(subscription).Select("status", "starts_at", "ends_at", "cycle_start", "scans_used").
+		Updates(subscription).Error
+}
+
+func (r *gormSubscriptionRepo) Cancel(ctx context.Context, id uint) error {
+	return r.conn(ctx).Model(&models.Subscription{}).
+		Where("id = ? AND status IN ?", id, []string{models.SubscriptionPending, models.SubscriptionActive}).
+		UpdateColumn("status", models.SubscriptionCancelled).Error
+}
+
+func (r *gormSubscriptionRepo) RollCycle(ctx context.Context, id uint, from, to time.Time) (bool, error) {
+	result := r.conn(ctx).Model(&models.Subscription{}).
+		Where("id = ? AND cycle_start = ?", id, from).
+		Updates(map[string]interface{}{"cycle_start": to, "scans_used": 0})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *gormSubscriptionRepo) ConsumeScan(ctx context.Context, id uint, cycleStart *time.Time) (bool, error) {
+	result := r.conn(ctx).Model(&models.Subscription{}).
+		Where("id = ? AND cycle_start = ?", id, cycleStart).
+		Where("scans_per_month = 0 OR scans_used < scans_per_month").
+		UpdateColumn("scans_used", gorm.Expr("scans_used + 1"))
+	return result.RowsAffected == 1, result.Error
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+
+	"back_wa/internal/models"
+)
+
+// TenantRepo reads the white-label tenants (tenants).
+// Lookups return gorm.ErrRecordNotFound when no tenant matches.
+type TenantRepo interface {
+	// FindByID returns the tenant whether or not it is active
+	FindByID(ctx context.Context, id uint) (*models.Tenant, error)
+	FindActiveByID(ctx context.Context, id uint) (*models.Tenant, error)
+	FindActiveByAPIKeyHash(ctx context.Context, apiKeyHash string) (*models.Tenant, error)
+	FindActiveByHost(ctx context.Context, host string) (*models.Tenant, error)
+	ListActive(ctx context.Context) ([]models.Tenant, error)
+}
+
+type gormTenantRepo struct {
+	conn Conn
+}
+
+// NewTenantRepo creates a GORM-backed TenantRepo on conn (nil = DefaultConn)
+func NewTenantRepo(conn Conn) TenantRepo {
+	return &gormTenantRepo{conn: orDefault(conn)}
+}
+
+func (r *gormTenantRepo) FindByID(ctx context.Context, id uint) (*models.Tenant, error) {
+	var tenant models.Tenant
+	if err := r.conn(ctx).First(&tenant, id).Error; err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+func (r *gormTenantRepo) FindActiveByID(ctx context.Context, id uint) (*models.Tenant, error) {
+	return r.findActive(ctx, "id", id)
+}
+
+func (r *gormTenantRepo) FindActiveByAPIKeyHash(ctx context.Context, apiKeyHash string) (*models.Tenant, error) {
+	return r.findActive(ctx, "api_key_hash", apiKeyHash)
+}
+
+func (r *gormTenantRepo) FindActiveByHost(ctx context.Context, host string) (*models.Tenant, error) {
+	return r.findActive(ctx, "host", host)
+}
+
+func (r *gormTenantRepo) findActive(ctx context.Context, column string, value interface{}) (*models.Tenant, error) {
+	var tenant models.Tenant
+	if err := r.conn(ctx).Where(column+" = ? AND is_active = ?", value, true).First(&tenant).Error; err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+func (r *gormTenantRepo) ListActive(ctx context.Context) ([]models.Tenant, error) {
+	var tenants []models.Tenant
+	err := r.conn(ctx).Where("is_active = ?", true).Find(&tenants).Error
+	return tenants, err
+}
@@ -0,0 +1,247 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"back_wa/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// TransactionRepo stores payment transactions and reads the payment catalog.
+// Lookups return gorm.ErrRecordNotFound when nothing matches.
+type TransactionRepo interface {
+	Create(ctx context.Context, transaction *models.Transaction) error
+	FindByExternalID(ctx context.Context, externalID string) (*models.Transaction, error)
+	FindByIdempotencyKey(ctx context.Context, userID int, key string) (*models.Transaction, error)
+	// ListPending returns pending transactions (oldest first) created before olderThan ago (0 = all)
+	ListPending(ctx context.Context, olderThan time.Duration, limit int) ([]models.Transaction, error)
+	// ListOpenPending returns the user's pending transactions for category, newest first
+	ListOpenPending(ctx context.Context, userID int, category string) ([]models.Transaction, error)
+	// ListByUser returns the user's transactions matching query (default order: newest first)
+	ListByUser(ctx context.Context, userID int, query TransactionQuery) ([]models.Transaction, error)
+	// EachByUser streams the user's transactions matching query (default order: newest first) to fn one row at a time
+	EachByUser(ctx context.Context, userID int, query TransactionQuery, fn func(models.Transaction) error) error
+	// CountByUser counts the user's transactions matching query, ignoring limit and offset
+	CountByUser(ctx context.Context, userID int, query TransactionQuery) (int64, error)
+	// CountPaid counts the user's paid transactions, for phoneNumber only unless it is empty
+	CountPaid(ctx context.Context, userID int, phoneNumber string) (int64, error)
+	// PaidPhoneNumbers returns the phone numbers (as stored) of the user's paid per-phone transactions
+	PaidPhoneNumbers(ctx context.Context, userID int) ([]string, error)
+	// PaidAmount sums the amounts of the user's paid transactions
+	PaidAmount(ctx context.Context, userID int) (float64, error)
+	// PaidBetween counts and sums the user's transactions paid in [from, to)
+	PaidBetween(ctx context.Context, userID int, from, to time.Time) (int64, float64, error)
+	UpdateByExternalID(ctx context.Context, externalID string, updates map[string]interface{}) error
+	UpdateByID(ctx context.Context, id int, updates map[string]interface{}) error
+	// ExpirePending marks the transaction expired unless it left pending meanwhile; reports whether it did
+	ExpirePending(ctx context.Context, id int) (bool, error)
+	// MarkRefunded marks the paid transaction refunded; reports false when it was no longer paid
+	MarkRefunded(ctx context.Context, id int) (bool, error)
+
+	CreateRefund(ctx context.Context, refund *models.Refund) error
+	SaveRefund(ctx context.Context, refund *models.Refund) error
+	// ListRefunds returns the refunds of the transaction, newest first
+	ListRefunds(ctx context.Context, transactionID int) ([]models.Refund, error)
+
+	// IssuingTenant loads the tenant that issued the transaction
+	IssuingTenant(ctx context.Context, tenantID uint) (*models.Tenant, error)
+	// CheapestActiveCategory returns the cheapest active payment category, or nil when none is configured
+	CheapestActiveCategory(ctx context.Context) (*models.PaymentCategory, error)
+}
+
+type gormTransactionRepo struct {
+	conn Conn
+}
+
+// NewTransactionRepo creates a GORM-backed TransactionRepo on conn (nil = DefaultConn)
+func NewTransactionRepo(conn Conn) TransactionRepo {
+	return &gormTransactionRepo{conn: orDefault(conn)}
+}
+
+func (r *gormTransactionRepo) Create(ctx context.Context, transaction *models.Transaction) error {
+	return r.conn(ctx).Create(transaction).Error
+}
+
+func (r *gormTransactionRepo) FindByExternalID(ctx context.Context, externalID string) (*models.Transaction, error) {
+	var transaction models.Transaction
+	if err := r.conn(ctx).Where("external_id = ?", externalID).First(&transaction).Error; err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+func (r *gormTransactionRepo) FindByIdempotencyKey(ctx context.Context, userID int, key string) (*models.Transaction, error) {
+	var transaction models.Transaction
+	if err := r.conn(ctx).Where("user_id = ? AND idempotency_key = ?", userID, key).First(&transaction).Error; err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+func (r *gormTransactionRepo) ListPending(ctx context.Context, olderThan time.Duration, limit int) ([]models.Transaction, error) {
+	query := r.conn(ctx).Model(&models.Transaction{}).Select("id", "external_id", "status").
+		Where("status = ?", "pending").Order("created_at ASC")
+	if olderThan > 0 {
+		query = query.Where("created_at < ?", time.Now().Add(-olderThan))
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var pending []models.Transaction
+	err := query.Find(&pending).Error
+	return pending, err
+}
+
+func (r *gormTransactionRepo) ListOpenPending(ctx context.Context, userID int, category string) ([]models.Transaction, error) {
+	var candidates []models.Transaction
+	err := r.conn(ctx).Where("user_id = ? AND status = ? AND description = ?", userID, "pending", category).
+		Order("created_at DESC").Find(&candidates).Error
+	return candidates, err
+}
+
+func (r *gormTransactionRepo) ListByUser(ctx context.Context, userID int, query TransactionQuery) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+	db := query.order(r.userScope(ctx, userID, query), TransactionSorts, "created_at DESC", "id")
+	err := query.page(db).Find(&transactions).Error
+	return transactions, err
+}
+
+func (r *gormTransactionRepo) EachByUser(ctx context.Context, userID int, query TransactionQuery, fn func(models.Transaction) error) error {
+	db := r.conn(ctx)
+	scoped := query.order(r.userScope(ctx, userID, query), TransactionSorts, "created_at DESC", "id")
+	rows, err := query.page(scoped).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transaction models.Transaction
+		if err := db.ScanRows(rows, &transaction); err != nil {
+			return err
+		}
+		if err := fn(transaction); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *gormTransactionRepo) CountByUser(ctx context.Context, userID int, query TransactionQuery) (int64, error) {
+	var count int64
+	err := r.userScope(ctx, userID, query).Count(&count).Error
+	return count, err
+}
+
+// userScope selects the user's transactions matching the filters of query
+func (r *gormTransactionRepo) userScope(ctx context.Context, userID int, query TransactionQuery) *gorm.DB {
+	db := r.conn(ctx).Model(&models.Transaction{}).Where("user_id = ?", userID)
+	if len(query.Statuses) > 0 {
+		db = db.Where("status IN ?", query.Statuses)
+	}
+	return query.between(db, "created_at")
+}
+
+func (r *gormTransactionRepo) CountPaid(ctx context.Context, userID int, phoneNumber string) (int64, error) {
+	query := r.conn(ctx).Model(&models.Transaction{}).Where("user_id = ? AND status = ?", userID, "paid")
+	if phoneNumber != "" {
+		query = query.Scopes(phoneNumberIs(phoneNumber))
+	}
+	var count int64
+	err := query.Count(&count).Error
+	return count, err
+}
+
+func (r *gormTransactionRepo) PaidPhoneNumbers(ctx context.Context, userID int) ([]string, error) {
+	// Loaded as models so the encrypted column is decrypted (Pluck would return the ciphertext)
+	var transactions []models.Transaction
+	err := r.conn(ctx).Select("id", "phone_number").
+		Where("user_id = ? AND status = ? AND subscription_id IS NULL", userID, "paid").
+		Find(&transactions).Error
+	if err != nil {
+		return nil, err
+	}
+	phones := make([]string, 0, len(transactions))
+	for _, transaction := range transactions {
+		phones = append(phones, transaction.PhoneNumber)
+	}
+	return phones, nil
+}
+
+func (r *gormTransactionRepo) PaidAmount(ctx context.Context, userID int) (float64, error) {
+	var total float64
+	err := r.conn(ctx).Model(&models.Transaction{}).
+		Where("user_id = ? AND status = ?", userID, "paid").
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+func (r *gormTransactionRepo) PaidBetween(ctx context.Context, userID int, from, to time.Time) (int64, float64, error) {
+	var paid struct {
+		Count  int64
+		Amount float64
+	}
+	err := r.conn(ctx).Model(&models.Transaction{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(amount), 0) AS amount").
+		Where("user_id = ? AND status = ? AND paid_at >= ? AND paid_at < ?", userID, "paid", from, to).
+		Scan(&paid).Error
+	return paid.Count, paid.Amount, err
+}
+
+func (r *gormTransactionRepo) UpdateByExternalID(ctx context.Context, externalID string, updates map[string]interface{}) error {
+	return r.conn(ctx).Model(&models.Transaction{}).Where("external_id = ?", externalID).Updates(updates).Error
+}
+
+func (r *gormTransactionRepo) UpdateByID(ctx context.Context, id int, updates map[string]interface{}) error {
+	return r.conn(ctx).Model(&models.Transaction{}).Where("id = ?", id).Updates(updates).Error
+}
+
+func (r *gormTransactionRepo) ExpirePending(ctx context.Context, id int) (bool, error) {
+	result := r.conn(ctx).Model(&models.Transaction{}).Where("id = ? AND status = ?", id, "pending").
+		Updates(map[string]interface{}{"status": "expired", "updated_at": time.Now()})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *gormTransactionRepo) MarkRefunded(ctx context.Context, id int) (bool, error) {
+	now := time.Now()
+	result := r.conn(ctx).Model(&models.Transaction{}).Where("id = ? AND status = ?", id, "paid").
+		Updates(map[string]interface{}{"status": "refunded", "refunded_at": now, "updated_at": now})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *gormTransactionRepo) CreateRefund(ctx context.Context, refund *models.Refund) error {
+	return r.conn(ctx).Create(refund).Error
+}
+
+func (r *gormTransactionRepo) SaveRefund(ctx context.Context, refund *models.Refund) error {
+	return r.conn(ctx).Save(refund).Error
+}
+
+func (r *gormTransactionRepo) ListRefunds(ctx context.Context, transactionID int) ([]models.Refund, error) {
+	var refunds []models.Refund
+	err := r.conn(ctx).Where("transaction_id = ?", transactionID).Order("created_at DESC, id DESC").Find(&refunds).Error
+	return refunds, err
+}
+
+func (r *gormTransactionRepo) IssuingTenant(ctx context.Context, tenantID uint) (*models.Tenant, error) {
+	var tenant models.Tenant
+	if err := r.conn(ctx).Where("id = ?", tenantID).First(&tenant).Error; err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+func (r *gormTransactionRepo) CheapestActiveCategory(ctx context.Context) (*models.PaymentCategory, error) {
+	var categories []models.PaymentCategory
+	if err := r.conn(ctx).Where("is_active = ?", true).Order("price ASC").Limit(1).Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	if len(categories) == 0 {
+		return nil, nil
+	}
+	return &categories[0], nil
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"back_wa/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UsageRepo stores the monthly usage counters of partners (per tenant) and users
+type UsageRepo interface {
+	// IncrementPartner adds one to the tenant's counter for metric in period
+	IncrementPartner(ctx context.Context, tenantID uint, period, metric string, at time.Time) error
+	// ListPartner returns the tenant's counters for period, by metric
+	ListPartner(ctx context.Context, tenantID uint, period string) ([]models.PartnerUsage, error)
+	// IncrementUser adds one to the user's counter for metric in period
+	IncrementUser(ctx context.Context, userID uint, period, metric string, at time.Time) error
+	ListUser(ctx context.Context, userID uint, period string) ([]models.UserUsage, error)
+	// EachInvoiceLine streams the partner counters of period with their tenant, by tenant and
+	// metric, to fn; tenantID == nil covers all tenants. Prices are left to the caller.
+	EachInvoiceLine(ctx context.Context, tenantID *uint, period string, fn func(models.UsageInvoiceLine) error) error
+}
+
+type gormUsageRepo struct {
+	conn Conn
+}
+
+// NewUsageRepo creates a GORM-backed UsageRepo on conn (nil = DefaultConn)
+func NewUsageRepo(conn Conn) UsageRepo {
+	return &gormUsageRepo{conn: orDefault(conn)}
+}
+
+func (r *gormUsageRepo) IncrementPartner(ctx context.Context, tenantID uint, period, metric string, at time.Time) error {
+	usage := models.PartnerUsage{TenantID: tenantID, Period: period, Metric: metric, Count: 1}
+	return r.conn(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "tenant_id"}, {Name: "period"}, {Name: "metric"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"count":      gorm.Expr("partner_usage.count + 1"),
+			"updated_at": at,
+		}),
+	}).Create(&usage).Error
+}
+
+func (r *gormUsageRepo) ListPartner(ctx context.Context, tenantID uint, period string) ([]models.PartnerUsage, error) {
+	var usage []models.PartnerUsage
+	err := r.conn(ctx).Where("tenant_id = ? AND period = ?", tenantID, period).
+		Order("metric ASC").
+		Find(&usage).Error
+	return usage, err
+}
+
+func (r *gormUsageRepo) IncrementUser(ctx context.Context, userID uint, period, metric string, at time.Time) error {
+	usage := models.UserUsage{UserID: userID, Period: period, Metric: metric, Count: 1}
+	return r.conn(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "period"}, {Name: "metric"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"count":      gorm.Expr("user_usage.count + 1"),
+			"updated_at": at,
+		}),
+	}).Create(&usage).Error
+}
+
+func (r *gormUsageRepo) ListUser(ctx context.Context, userID uint, period string) ([]models.UserUsage, error) {
+	var usage []models.UserUsage
+	err := r.conn(ctx).Where("user_id = ? AND period = ?", userID, period).Find(&usage).Error
+	return usage, err
+}
+
+func (r *gormUsageRepo) EachInvoiceLine(ctx context.Context, tenantID *uint, period string, fn func(models.UsageInvoiceLine) error) error {
+	db := r.conn(ctx)
+	query := db.Table("partner_usage pu").
+		Select("pu.tenant_id, t.slug, t.brand_name, pu.period, pu.metric, pu.count as quantity").
+		Joins("JOIN tenants t ON t.id = pu.tenant_id").
+		Where("pu.period = ?", period)
+	if tenantID != nil {
+		query = query.Where("pu.tenant_id = ?", *tenantID)
+	}
+
+	rows, err := query.Order("pu.tenant_id ASC, pu.metric ASC").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line models.UsageInvoiceLine
+		if err := db.ScanRows(rows, &line); err != nil {
+			return err
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"back_wa/internal/models"
+)
+
+// UserRepo loads and stores user accounts.
+// Lookups return gorm.ErrRecordNotFound when no user matches. Pass a ctx without
+// tenant scope (e.g. context.Background()) for cross-tenant checks such as uniqueness.
+type UserRepo interface {
+	FindByID(ctx context.Context, id uint) (*models.User, error)
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	FindByUsername(ctx context.Context, username string) (*models.User, error)
+	FindByPhoneNumber(ctx context.Context, phoneNumber string) (*models.User, error)
+	// FindByPendingOTP, FindByPendingResetToken and FindByPendingUnlockToken return the user of
+	// email whose OTP, password reset or unlock token has not expired at now
+	FindByPendingOTP(ctx context.Context, email string, now time.Time) (*models.User, error)
+	FindByPendingResetToken(ctx context.Context, email string, now time.Time) (*models.User, error)
+	FindByPendingUnlockToken(ctx context.Context, email string, now time.Time) (*models.User, error)
+	// TenantID returns the tenant the user belongs to (nil = default brand)
+	TenantID(ctx context.Context, id uint) (*uint, error)
+	Create(ctx context.Context, user *models.User) error
+	Save(ctx context.Context, user *models.User) error
+	UpdateUsername(ctx context.Context, id uint, username string) error
+	// Update writes the given columns of the user, stamping updated_at
+	Update(ctx context.Context, id uint, fields map[string]interface{}) error
+	// UpdateColumns writes the given columns of the user without touching updated_at
+	UpdateColumns(ctx context.Context, id uint, columns map[string]interface{}) error
+	// RecordLogin stamps last_login_at and clears a pending inactivity warning
+	RecordLogin(ctx context.Context, id uint, at time.Time) error
+	// ListByTenant returns the active members of a tenant
+	ListByTenant(ctx context.Context, tenantID uint) ([]models.User, error)
+	// ListByIDs returns the users of ids that exist (deleted users are left out)
+	ListByIDs(ctx context.Context, ids []uint) ([]models.User, error)
+}
+
+type gormUserRepo struct {
+	conn Conn
+}
+
+// NewUserRepo creates a GORM-backed UserRepo on conn (nil = DefaultConn)
+func NewUserRepo(conn Conn) UserRepo {
+	return &gormUserRepo{conn: orDefault(conn)}
+}
+
+func (r *gormUserRepo) FindByID(ctx context.Context, id uint) (*models.User, error) {
+	var user models.User
+	if err := r.conn(ctx).First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepo) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.findBy(ctx, "email", email)
+}
+
+func (r *gormUserRepo) FindByUsername(ctx context.Context, username string) (*models.User, error) {
+	return r.findBy(ctx, "username", username)
+}
+
+func (r *gormUserRepo) FindByPhoneNumber(ctx context.Context, phoneNumber string) (*models.User, error) {
+	var user models.User
+	if err := r.conn(ctx).Scopes(phoneNumberIs(phoneNumber)).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepo) FindByPendingOTP(ctx context.Context, email string, now time.Time) (*models.User, error) {
+	return r.findUnexpired(ctx, email, "otp_expires_at", now)
+}
+
+func (r *gormUserRepo) FindByPendingResetToken(ctx context.Context, email string, now time.Time) (*models.User, error) {
+	return r.findUnexpired(ctx, email, "reset_token_expires_at", now)
+}
+
+func (r *gormUserRepo) FindByPendingUnlockToken(ctx context.Context, email string, now time.Time) (*models.User, error) {
+	return r.findUnexpired(ctx, email, "unlock_token_expires_at", now)
+}
+
+func (r *gormUserRepo) findUnexpired(ctx context.Context, email, expiresColumn string, now time.Time) (*models.User, error) {
+	var user models.User
+	if err := r.conn(ctx).Where("email = ? AND "+expiresColumn+" > ?", email, now).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepo) findBy(ctx context.Context, column, value string) (*models.User, error) {
+	var user models.User
+	if err := r.conn(ctx).Where(column+" = ?", value).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepo) TenantID(ctx context.Context, id uint) (*uint, error) {
+	var user models.User
+	if err := r.conn(ctx).Select("id", "tenant_id").First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return user.TenantID, nil
+}
+
+func (r *gormUserRepo) Create(ctx context.Context, user *models.User) error {
+	return r.conn(ctx).Create(user).Error
+}
+
+func (r *gormUserRepo) Save(ctx context.Context, user *models.User) error {
+	return r.conn(ctx).Save(user).Error
+}
+
+func (r *gormUserRepo) UpdateUsername(ctx context.Context, id uint, username string) error {
+	return r.conn(ctx).Model(&models.User{}).Where("id = ?", id).Update("username", username).Error
+}
+
+func (r *gormUserRepo) Update(ctx context.Context, id uint, fields map[string]interface{}) error {
+	return r.conn(ctx).Model(&models.User{}).Where("id = ?", id).Updates(fields).Error
+}
+
+func (r *gormUserRepo) UpdateColumns(ctx context.Context, id uint, columns map[string]interface{}) error {
+	return r.conn(ctx).Model(&models.User{}).Where("id = ?", id).UpdateColumns(columns).Error
+}
+
+func (r *gormUserRepo) RecordLogin(ctx context.Context, id uint, at time.Time) error {
+	return r.conn(ctx).Model(&models.User{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+		"last_login_at":        at,
+		"inactivity_warned_at": nil,
+	}).Error
+}
+
+func (r *gormUserRepo) ListByTenant(ctx context.Context, tenantID uint) ([]models.User, error) {
+	var users []models.User
+	err := r.conn(ctx).Where("tenant_id = ? AND is_active = ?", tenantID, true).Order("id ASC").Find(&users).Error
+	return users, err
+}
+
+func (r *gormUserRepo) ListByIDs(ctx context.Context, ids []uint) ([]models.User, error) {
+	var users []models.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	err := r.conn(ctx).Where("id IN ?", ids).Find(&users).Error
+	return users, err
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"back_wa/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WebhookEventFilter narrows the webhook event listing. Empty fields leave it unrestricted.
+type WebhookEventFilter struct {
+	Gateway    string
+	State      string
+	ExternalID string
+}
+
+// WebhookEventRepo stores the inbound payment webhooks (webhook_events), keyed by gateway and event ID.
+// Lookups return gorm.ErrRecordNotFound when nothing matches.
+type WebhookEventRepo interface {
+	// InsertIfNew stores the event unless one with its gateway and event ID exists; reports whether it did
+	InsertIfNew(ctx context.Context, event *models.WebhookEvent) (bool, error)
+	FindByEventID(ctx context.Context, gateway, eventID string) (*models.WebhookEvent, error)
+	FindByID(ctx context.Context, id uint) (*models.WebhookEvent, error)
+	// Claim marks the event received for one more attempt when it failed or sat received since
+	// before staleBefore, or, with force, in any state but a fresh received one; reports whether it did
+	Claim(ctx context.Context, id uint, force bool, staleBefore, now time.Time) (bool, error)
+	// CountDuplicate counts one more skipped redelivery of the event
+	CountDuplicate(ctx context.Context, id uint) error
+	Update(ctx context.Context, id uint, updates map[string]interface{}) error
+	// List returns one page of events newest first, without their payloads, and the total
+	List(ctx context.Context, filter WebhookEventFilter, query ListQuery) ([]models.WebhookEvent, int64, error)
+}
+
+type gormWebhookEventRepo struct {
+	conn Conn
+}
+
+// NewWebhookEventRepo creates a GORM-backed WebhookEventRepo on conn (nil = DefaultConn)
+func NewWebhookEventRepo(conn Conn) WebhookEventRepo {
+	return &gormWebhookEventRepo{conn: orDefault(conn)}
+}
+
+func (r *gormWebhookEventRepo) InsertIfNew(ctx context.Context, event *models.WebhookEvent) (bool, error) {
+	result := r.conn(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(event)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *gormWebhookEventRepo) FindByEventID(ctx context.Context, gateway, eventID string) (*models.WebhookEvent, error) {
+	var event models.WebhookEvent
+	if err := r.conn(ctx).Where("gateway = ? AND event_id = ?", gateway, eventID).First(&event).Error; err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (r *gormWebhookEventRepo) FindByID(ctx context.Context, id uint) (*models.WebhookEvent, error) {
+	var event models.WebhookEvent
+	if err := r.conn(ctx).First(&event, id).Error; err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (r *gormWebhookEventRepo) Claim(ctx context.Context, id uint, force bool, staleBefore, now time.Time) (bool, error) {
+	query := r.conn(ctx).Model(&models.WebhookEvent{}).Where("id = ?", id)
+	if force {
+		query = query.Where("state <> ? OR updated_at < ?", models.WebhookEventReceived, staleBefore)
+	} else {
+		query = query.Where("state = ? OR (state = ? AND updated_at < ?)", models.WebhookEventFailed, models.WebhookEventReceived, staleBefore)
+	}
+	result := query.Updates(map[string]interface{}{
+		"state":      models.WebhookEventReceived,
+		"attempts":   gorm.Expr("attempts + 1"),
+		"updated_at": now,
+	})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *gormWebhookEventRepo) CountDuplicate(ctx context.Context, id uint) error {
+	return r.conn(ctx).Model(&models.WebhookEvent{}).Where("id = ?", id).
+		UpdateColumn("duplicates", gorm.Expr("duplicates + 1")).Error
+}
+
+func (r *gormWebhookEventRepo) Update(ctx context.Context, id uint, updates map[string]interface{}) error {
+	return r.conn(ctx).Model(&models.WebhookEvent{}).Where("id = ?", id).Updates(updates).Error
+}
+
+func (r *gormWebhookEventRepo) List(ctx context.Context, filter WebhookEventFilter, query ListQuery) ([]models.WebhookEvent, int64, error) {
+	db := r.conn(ctx).Model(&models.WebhookEvent{})
+	if filter.Gateway != "" {
+		db = db.Where("gateway = ?", filter.Gateway)
+	}
+	if filter.State != "" {
+		db = db.Where("state = ?", filter.State)
+	}
+	if filter.ExternalID != "" {
+		db = db.Where("external_id = ?", filter.ExternalID)
+	}
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var events []models.WebhookEvent
+	if err := query.page(db.Omit("payload").Order("id DESC")).Find(&events).Error; err != nil {
+		return nil, 0, err
+	}
+	return events, total, nil
+}
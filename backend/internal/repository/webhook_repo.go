@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"back_wa/internal/models"
+)
+
+// WebhookRepo stores the users' outbound webhooks and their delivery log.
+// Lookups return gorm.ErrRecordNotFound when nothing matches.
+type WebhookRepo interface {
+	FindByUser(ctx context.Context, userID uint) (*models.UserWebhook, error)
+	FindByID(ctx context.Context, id uint) (*models.UserWebhook, error)
+	Save(ctx context.Context, webhook *models.UserWebhook) error
+	// DeleteByUser removes the user's webhook; reports false when there was none
+	DeleteByUser(ctx context.Context, userID uint) (bool, error)
+
+	CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	// ListDeliveries returns one page of the user's deliveries newest first and the total
+	ListDeliveries(ctx context.Context, userID uint, query ListQuery) ([]models.WebhookDelivery, int64, error)
+	// ListDueDeliveries returns up to limit pending deliveries whose next attempt is at or before now
+	ListDueDeliveries(ctx context.Context, now time.Time, limit int) ([]models.WebhookDelivery, error)
+	// ClaimAttempt counts one more attempt of the pending delivery unless another worker already
+	// did (attempts no longer matches); reports whether it claimed it
+	ClaimAttempt(ctx context.Context, id uint, attempts int) (bool, error)
+	UpdateDelivery(ctx context.Context, id uint, updates map[string]interface{}) error
+}
+
+type gormWebhookRepo struct {
+	conn Conn
+}
+
+// NewWebhookRepo creates a GORM-backed WebhookRepo on conn (nil = DefaultConn)
+func NewWebhookRepo(conn Conn) WebhookRepo {
+	return &gormWebhookRepo{conn: orDefault(conn)}
+}
+
+func (r *gormWebhookRepo) FindByUser(ctx context.Context, userID uint) (*models.UserWebhook, error) {
+	var webhook models.UserWebhook
+	if err := r.conn(ctx).Where("user_id = ?", userID).First(&webhook).Error; err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (r *gormWebhookRepo) FindByID(ctx context.Context, id uint) (*models.UserWebhook, error) {
+	var webhook models.UserWebhook
+	if err := r.conn(ctx).First(&webhook, id).Error; err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (r *gormWebhookRepo) Save(ctx context.Context, webhook *models.UserWebhook) error {
+	return r.conn(ctx).Save(webhook).Error
+}
+
+func (r *gormWebhookRepo) DeleteByUser(ctx context.Context, userID uint) (bool, error) {
+	result := r.conn(ctx).Where("user_id = ?", userID).Delete(&models.UserWebhook{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *gormWebhookRepo) CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	return r.conn(ctx).Create(delivery).Error
+}
+
+func (r *gormWebhookRepo) ListDeliveries(ctx context.Context, userID uint, query ListQuery) ([]models.WebhookDelivery, int64, error) {
+	var deliveries []models.WebhookDelivery
+	total, err := countAndPage(r.conn(ctx).Model(&models.WebhookDelivery{}).Where("user_id = ?", userID), query, "id DESC", &deliveries)
+	return deliveries, total, err
+}
+
+func (r *gormWebhookRepo) ListDueDeliveries(ctx context.Context, now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := r.conn(ctx).Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, now).
+		Order("next_attempt_at ASC").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (r *gormWebhookRepo) ClaimAttempt(ctx context.Context, id uint, attempts int) (bool, error) {
+	result := r.conn(ctx).Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND attempts = ?", id, models.WebhookDeliveryPending, attempts).
+		Update("attempts", attempts+1)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *gormWebhookRepo) UpdateDelivery(ctx context.Context, id uint, updates map[string]interface{}) error {
+	return r.conn(ctx).Model(&models.WebhookDelivery{}).Where("id = ?", id).Updates(updates).Error
+}
@@ -0,0 +1,17 @@
+package server
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// ShutdownTimeout returns SHUTDOWN_TIMEOUT_SECONDS (default 25), the time in-flight requests get
+// to finish after SIGTERM. Keep it below the orchestrator's grace period (30s on Kubernetes).
+func ShutdownTimeout() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("SHUTDOWN_TIMEOUT_SECONDS"))
+	if err != nil || seconds <= 0 {
+		seconds = 25
+	}
+	return time.Duration(seconds) * time.Second
+}
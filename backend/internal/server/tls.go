@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"back_wa/internal/logging"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// TLS modes selectable with TLS_MODE
+const (
+	TLSModeOff      = "off"      // plain HTTP on :9090 (default, e.g. behind a reverse proxy)
+	TLSModeFiles    = "files"    // certificate and key from TLS_CERT_FILE / TLS_KEY_FILE
+	TLSModeAutocert = "autocert" // ACME (Let's Encrypt) certificates for TLS_AUTOCERT_DOMAINS
+)
+
+// TLSConfig describes how the server terminates TLS
+type TLSConfig struct {
+	Mode         string
+	Addr         string // HTTPS listen address
+	RedirectAddr string // HTTP listener redirecting to HTTPS (and answering ACME challenges); empty disables it
+	CertFile     string
+	KeyFile      string
+	Domains      []string
+	CacheDir     string
+	Email        string
+}
+
+// LoadTLSConfig reads and validates the TLS_* settings.
+// Validation errors explain which variables are needed so a misconfigured server fails fast at startup.
+func LoadTLSConfig() (*TLSConfig, error) {
+	cfg := &TLSConfig{
+		Mode:         strings.ToLower(strings.TrimSpace(os.Getenv("TLS_MODE"))),
+		Addr:         os.Getenv("TLS_ADDR"),
+		RedirectAddr: os.Getenv("TLS_REDIRECT_ADDR"),
+		CertFile:     os.Getenv("TLS_CERT_FILE"),
+		KeyFile:      os.Getenv("TLS_KEY_FILE"),
+		CacheDir:     os.Getenv("TLS_AUTOCERT_CACHE_DIR"),
+		Email:        os.Getenv("TLS_AUTOCERT_EMAIL"),
+	}
+	if cfg.Mode == "" {
+		cfg.Mode = TLSModeOff
+	}
+	if cfg.Addr == "" {
+		cfg.Addr = ":443"
+	}
+	if cfg.RedirectAddr == "" {
+		cfg.RedirectAddr = ":80"
+	} else if strings.EqualFold(cfg.RedirectAddr, "off") {
+		cfg.RedirectAddr = ""
+	}
+	if cfg.CacheDir == "" {
+		cfg.CacheDir = "certs"
+	}
+	for _, domain := range strings.Split(os.Getenv("TLS_AUTOCERT_DOMAINS"), ",") {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			cfg.Domains = append(cfg.Domains, domain)
+		}
+	}
+
+	switch cfg.Mode {
+	case TLSModeOff:
+		return cfg, nil
+	case TLSModeFiles:
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, errors.New("TLS_MODE=files requires TLS_CERT_FILE and TLS_KEY_FILE (PEM certificate chain and private key)")
+		}
+		if _, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile); err != nil {
+			return nil, fmt.Errorf("TLS_CERT_FILE/TLS_KEY_FILE could not be loaded: %v", err)
+		}
+		return cfg, nil
+	case TLSModeAutocert:
+		if len(cfg.Domains) == 0 {
+			return nil, errors.New("TLS_MODE=autocert requires TLS_AUTOCERT_DOMAINS, a comma separated allow-list such as api.cekwa.id")
+		}
+		if cfg.RedirectAddr == "" {
+			slog.Warn("TLS_REDIRECT_ADDR=off: ACME HTTP-01 challenges cannot be answered, only TLS-ALPN-01 on TLS_ADDR will work")
+		}
+		if err := os.MkdirAll(cfg.CacheDir, 0700); err != nil {
+			return nil, fmt.Errorf("TLS_AUTOCERT_CACHE_DIR %q is not writable: %v", cfg.CacheDir, err)
+		}
+		return cfg, nil
+	default:
+		return nil, fmt.Errorf("unknown TLS_MODE %q, expected %q, %q or %q", cfg.Mode, TLSModeOff, TLSModeFiles, TLSModeAutocert)
+	}
+}
+
+// Enabled reports whether the server terminates TLS itself
+func (c *TLSConfig) Enabled() bool {
+	return c.Mode != TLSModeOff
+}
+
+// ListenAndServe serves handler according to the TLS mode; plainAddr is used when TLS is off.
+// Once ctx is cancelled the listeners stop accepting connections and in-flight requests get up
+// to drain to finish; a clean shutdown returns nil.
+func (c *TLSConfig) ListenAndServe(ctx context.Context, plainAddr string, handler http.Handler, drain time.Duration) error {
+	srv := &http.Server{Addr: plainAddr, Handler: handler}
+	var redirectSrv *http.Server
+	serve := srv.ListenAndServe
+
+	if c.Enabled() {
+		srv = &http.Server{
+			Addr:              c.Addr,
+			Handler:           handler,
+			ReadHeaderTimeout: 10 * time.Second,
+			TLSConfig:         &tls.Config{MinVersion: tls.VersionTLS12},
+		}
+
+		var redirect http.Handler = http.HandlerFunc(c.redirectToHTTPS)
+		if c.Mode == TLSModeAutocert {
+			manager := &autocert.Manager{
+				Prompt:     autocert.AcceptTOS,
+				HostPolicy: autocert.HostWhitelist(c.Domains...),
+				Cache:      autocert.DirCache(c.CacheDir),
+				Email:      c.Email,
+			}
+			srv.TLSConfig = manager.TLSConfig()
+			srv.TLSConfig.MinVersion = tls.VersionTLS12
+			// Answers HTTP-01 challenges and redirects everything else
+			redirect = manager.HTTPHandler(redirect)
+		}
+
+		if c.RedirectAddr != "" {
+			redirectSrv = &http.Server{Addr: c.RedirectAddr, Handler: redirect, ReadHeaderTimeout: 10 * time.Second}
+			go func() {
+				logging.FromContext(ctx).Debug(fmt.Sprintf("HTTP→HTTPS redirect listening on %s", c.RedirectAddr))
+				if err := redirectSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					logging.FromContext(ctx).Error("HTTP redirect listener stopped", "error", err)
+				}
+			}()
+		}
+
+		logging.FromContext(ctx).Debug(fmt.Sprintf("HTTPS (%s) listening on %s", c.Mode, c.Addr))
+		serve = func() error {
+			if c.Mode == TLSModeAutocert {
+				return srv.ListenAndServeTLS("", "")
+			}
+			return srv.ListenAndServeTLS(c.CertFile, c.KeyFile)
+		}
+	}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- serve() }()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	logging.FromContext(ctx).Info(fmt.Sprintf("Shutting down HTTP server, draining in-flight requests (up to %s)", drain))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	if redirectSrv != nil {
+		_ = redirectSrv.Shutdown(shutdownCtx)
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("HTTP drain incomplete: %v", err)
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// redirectToHTTPS sends plain HTTP requests to the same path on the HTTPS address
+func (c *TLSConfig) redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if _, port, err := net.SplitHostPort(c.Addr); err == nil && port != "" && port != "443" {
+		host = net.JoinHostPort(host, port)
+	}
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+}
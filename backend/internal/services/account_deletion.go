@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrDeletionConfirmationRequired is returned when neither the password nor an OTP is given
+	ErrDeletionConfirmationRequired = errors.New("password or otp is required to delete the account")
+	// ErrDeletionConfirmationInvalid is returned for a wrong password or an invalid/expired OTP
+	ErrDeletionConfirmationInvalid = errors.New("password or otp is incorrect")
+)
+
+// AccountDeletionRequest is the body of DELETE /api/user/account. The OTP is requested
+// beforehand through POST /api/auth/send-otp.
+type AccountDeletionRequest struct {
+	Password string `json:"password"`
+	OTP      string `json:"otp"`
+}
+
+// AccountDeletionSummary reports what DeleteAccount removed
+type AccountDeletionSummary struct {
+	AnalysesDeleted        int64 `json:"analyses_deleted"`
+	AnalysesKept           int64 `json:"analyses_kept"` // under legal hold, kept unchanged with breakdowns, scan history and phone number
+	ScanHistoryDeleted     int64 `json:"scan_history_deleted"`
+	TransactionsAnonymized int64 `json:"transactions_anonymized"`
+}
+
+// AccountDeletionService erases an account at the user's request. Unlike the inactive-account
+// anonymization, analysis results and scan history are deleted too; transactions are kept for
+// bookkeeping without the phone number, and records under legal hold survive (the delete
+// callback skips them).
+type AccountDeletionService struct {
+	ctx      context.Context
+	users    repository.UserRepo
+	accounts repository.AccountRepo
+}
+
+// NewAccountDeletionService creates an account deletion service on the given repositories.
+// The zero value AccountDeletionService{} uses the default repositories.
+func NewAccountDeletionService(users repository.UserRepo, accounts repository.AccountRepo) *AccountDeletionService {
+	return &AccountDeletionService{users: users, accounts: accounts}
+}
+
+// WithContext returns a copy of the service bound to the request context
+func (ds *AccountDeletionService) WithContext(ctx context.Context) *AccountDeletionService {
+	return &AccountDeletionService{ctx: ctx, users: ds.users, accounts: ds.accounts}
+}
+
+// userRepo returns the injected user repository or the default one
+func (ds *AccountDeletionService) userRepo() repository.UserRepo {
+	if ds.users != nil {
+		return ds.users
+	}
+	return repository.Default().Users
+}
+
+// accountRepo returns the injected account repository or the default one
+func (ds *AccountDeletionService) accountRepo() repository.AccountRepo {
+	if ds.accounts != nil {
+		return ds.accounts
+	}
+	return repository.Default().Accounts
+}
+
+// queryContext returns the service context (background when unset)
+func (ds *AccountDeletionService) queryContext() context.Context {
+	if ds.ctx != nil {
+		return ds.ctx
+	}
+	return context.Background()
+}
+
+// Confirm checks the password or, when no password is given, the OTP of the account
+func (ds *AccountDeletionService) Confirm(userID uint, req AccountDeletionRequest) error {
+	if req.Password == "" && req.OTP == "" {
+		return ErrDeletionConfirmationRequired
+	}
+
+	user, err := ds.userRepo().FindByID(ds.queryContext(), userID)
+	if err != nil {
+		return err
+	}
+
+	if req.Password != "" {
+		if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)) != nil {
+			return ErrDeletionConfirmationInvalid
+		}
+		return nil
+	}
+	ok, err := NewOTPService(ds.userRepo()).WithContext(ds.ctx).Validate(user.Email, req.OTP)
+	if err != nil || !ok {
+		return ErrDeletionConfirmationInvalid
+	}
+	return nil
+}
+
+// DeleteAccount purges the user's data in one transaction and anonymizes the account row.
+// The WhatsApp client and its session store are the caller's job (see the whatsapp package).
+func (ds *AccountDeletionService) DeleteAccount(userID uint) (*AccountDeletionSummary, error) {
+	passwordHash, err := unusablePasswordHash()
+	if err != nil {
+		return nil, err
+	}
+	erasure, err := ds.accountRepo().Erase(ds.queryContext(), userID, passwordHash, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &AccountDeletionSummary{
+		AnalysesDeleted:        erasure.AnalysesDeleted,
+		AnalysesKept:           erasure.AnalysesKept,
+		ScanHistoryDeleted:     erasure.ScanHistoryDeleted,
+		TransactionsAnonymized: erasure.TransactionsAnonymized,
+	}
+	logging.FromContext(ds.ctx).Info(fmt.Sprintf("account %d deleted at the user's request", userID),
+		"audit", true, "user_id", userID, "analyses_deleted", summary.AnalysesDeleted, "analyses_kept", summary.AnalysesKept,
+		"transactions_anonymized", summary.TransactionsAnonymized)
+	return summary, nil
+}
@@ -0,0 +1,289 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrMergeReasonRequired is returned when the admin gives no reason for the audit log
+	ErrMergeReasonRequired = errors.New("merge reason is required")
+	// ErrMergeSameUser is returned when source and target are the same account
+	ErrMergeSameUser = errors.New("source and target must be different accounts")
+	// ErrMergeUserNotFound is returned when either account does not exist
+	ErrMergeUserNotFound = errors.New("user not found")
+	// ErrMergePhoneMismatch is returned when the accounts do not share a phone number (override with force)
+	ErrMergePhoneMismatch = errors.New("accounts have different phone numbers")
+	// ErrMergeTenantMismatch is returned when the accounts belong to different tenants
+	ErrMergeTenantMismatch = errors.New("accounts belong to different tenants")
+	// ErrMergeTargetInactive is returned when the surviving account is itself deactivated (e.g. merged away)
+	ErrMergeTargetInactive = errors.New("target account is inactive")
+	// ErrMergeSessionActive is returned while the source still has a connected WhatsApp session
+	ErrMergeSessionActive = errors.New("source account has a connected WhatsApp session")
+	// ErrMergeNotFound is returned when the merge record does not exist
+	ErrMergeNotFound = errors.New("merge not found")
+	// ErrMergeNotRollbackable is returned for merges already rolled back or superseded by a later merge
+	ErrMergeNotRollbackable = errors.New("merge cannot be rolled back")
+)
+
+// mergedUserTables are moved to the target by rewriting user_id; analysis results (sealed owner)
+// and the tables in mergedSingletonTables are handled separately
+var mergedUserTables = []string{
+	"scan_history",
+	"transactions",
+	"subscriptions",
+	"coupon_redemptions",
+	"analysis_feedback",
+	"analysis_share_links",
+	"notifications",
+	"push_tokens",
+	"auth_sessions",
+	"whatsapp_session_events",
+}
+
+// mergedSingletonTables hold at most one row per user. The source's row only moves when the
+// target has none, together with the rows that belong to it.
+var mergedSingletonTables = []struct {
+	table      string
+	dependents []string
+}{
+	{table: "whatsapp_sessions"},
+	{table: "user_goals"},
+	{table: "scan_schedules"},
+	{table: "user_webhooks", dependents: []string{"webhook_deliveries"}},
+}
+
+// MergeRequest asks to fold SourceUserID into TargetUserID
+type MergeRequest struct {
+	SourceUserID uint   `json:"source_user_id"`
+	TargetUserID uint   `json:"target_user_id"`
+	Reason       string `json:"reason"`
+	// Force merges accounts whose phone numbers differ
+	Force bool `json:"force"`
+}
+
+// AccountMergeService consolidates duplicate registrations. Analyses and their feedback, scan history,
+// transactions, subscriptions and coupon redemptions (and with them entitlements), share links,
+// notifications, push tokens, login sessions, WhatsApp session records and the source's goal,
+// scan schedule and webhook are re-owned by the target, the source is deactivated, and everything
+// is recorded in account_merges so the merge can be rolled back.
+type AccountMergeService struct {
+	ctx    context.Context
+	merges repository.MergeRepo
+}
+
+// NewAccountMergeService creates an account merge service on the given repository.
+// The zero value AccountMergeService{} uses the default repository.
+func NewAccountMergeService(merges repository.MergeRepo) *AccountMergeService {
+	return &AccountMergeService{merges: merges}
+}
+
+// WithContext returns a copy of the service bound to the request context
+func (ms *AccountMergeService) WithContext(ctx context.Context) *AccountMergeService {
+	return &AccountMergeService{ctx: ctx, merges: ms.merges}
+}
+
+// mergeRepo returns the injected merge repository or the default one
+func (ms *AccountMergeService) mergeRepo() repository.MergeRepo {
+	if ms.merges != nil {
+		return ms.merges
+	}
+	return repository.Default().Merges
+}
+
+// queryContext returns the service context (background when unset)
+func (ms *AccountMergeService) queryContext() context.Context {
+	if ms.ctx != nil {
+		return ms.ctx
+	}
+	return context.Background()
+}
+
+// Merge moves all data of the source account to the target in one database transaction
+func (ms *AccountMergeService) Merge(req MergeRequest, adminID uint) (*models.AccountMerge, error) {
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return nil, ErrMergeReasonRequired
+	}
+	if req.SourceUserID == req.TargetUserID {
+		return nil, ErrMergeSameUser
+	}
+
+	ctx := ms.queryContext()
+	var merge *models.AccountMerge
+	err := ms.mergeRepo().Transaction(ctx, func(tx repository.MergeRepo) error {
+		source, err := tx.FindUser(ctx, req.SourceUserID)
+		if err != nil {
+			return mergeLookupError(err)
+		}
+		target, err := tx.FindUser(ctx, req.TargetUserID)
+		if err != nil {
+			return mergeLookupError(err)
+		}
+		if !target.IsActive {
+			return ErrMergeTargetInactive
+		}
+		if !sameTenant(source.TenantID, target.TenantID) {
+			return ErrMergeTenantMismatch
+		}
+		if !req.Force && NormalizePhoneNumber(source.PhoneNumber) != NormalizePhoneNumber(target.PhoneNumber) {
+			return ErrMergePhoneMismatch
+		}
+
+		connected, err := tx.CountConnectedSessions(ctx, source.ID)
+		if err != nil {
+			return err
+		}
+		if connected > 0 {
+			return ErrMergeSessionActive
+		}
+
+		moved := map[string][]uint{}
+		for _, table := range mergedUserTables {
+			ids, err := tx.MoveRows(ctx, table, source.ID, target.ID)
+			if err != nil {
+				return fmt.Errorf("failed to move %s: %w", table, err)
+			}
+			if len(ids) > 0 {
+				moved[table] = ids
+			}
+		}
+
+		// Analyses keep the checksum they were sealed with; the audit record below holds the change of owner
+		ids, err := tx.MoveRows(ctx, "analysis_results", source.ID, target.ID)
+		if err != nil {
+			return fmt.Errorf("failed to move analysis_results: %w", err)
+		}
+		if len(ids) > 0 {
+			moved["analysis_results"] = ids
+		}
+
+		// Keep the target's own session, goal, schedule and webhook
+		for _, singleton := range mergedSingletonTables {
+			targetRows, err := tx.CountRows(ctx, singleton.table, target.ID)
+			if err != nil {
+				return err
+			}
+			if targetRows > 0 {
+				continue
+			}
+			for _, table := range append([]string{singleton.table}, singleton.dependents...) {
+				ids, err := tx.MoveRows(ctx, table, source.ID, target.ID)
+				if err != nil {
+					return fmt.Errorf("failed to move %s: %w", table, err)
+				}
+				if len(ids) > 0 {
+					moved[table] = ids
+				}
+			}
+		}
+
+		if err := tx.SetUserActive(ctx, source.ID, false); err != nil {
+			return err
+		}
+
+		encoded, err := json.Marshal(moved)
+		if err != nil {
+			return err
+		}
+		merge = &models.AccountMerge{
+			SourceUserID:    source.ID,
+			TargetUserID:    target.ID,
+			AdminID:         adminID,
+			Reason:          req.Reason,
+			Status:          models.AccountMergeMerged,
+			SourceWasActive: source.IsActive,
+			MovedRecords:    string(encoded),
+		}
+		return tx.Create(ctx, merge)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info(fmt.Sprintf("admin %d merged user %d into user %d (merge %d, moved %v): %s", adminID, merge.SourceUserID, merge.TargetUserID, merge.ID, merge.MovedCounts(), merge.Reason), "audit", true, "admin_id", adminID, "user_id", merge.SourceUserID)
+	return merge, nil
+}
+
+// Rollback hands the recorded rows back to the source account and restores its active flag.
+// Rows created for the target after the merge stay with the target.
+func (ms *AccountMergeService) Rollback(mergeID, adminID uint) (*models.AccountMerge, error) {
+	ctx := ms.queryContext()
+	var merge *models.AccountMerge
+	err := ms.mergeRepo().Transaction(ctx, func(tx repository.MergeRepo) error {
+		var err error
+		merge, err = tx.FindByID(ctx, mergeID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrMergeNotFound
+			}
+			return err
+		}
+		if merge.Status != models.AccountMergeMerged {
+			return ErrMergeNotRollbackable
+		}
+
+		// A later merge of the target into another account would leave the rows elsewhere
+		later, err := tx.CountMergedAfter(ctx, merge.TargetUserID, merge.ID)
+		if err != nil {
+			return err
+		}
+		if later > 0 {
+			return ErrMergeNotRollbackable
+		}
+
+		for table, ids := range merge.Moved() {
+			if err := tx.RestoreRows(ctx, table, ids, merge.TargetUserID, merge.SourceUserID); err != nil {
+				return fmt.Errorf("failed to restore %s: %w", table, err)
+			}
+		}
+
+		if err := tx.SetUserActive(ctx, merge.SourceUserID, merge.SourceWasActive); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		merge.Status = models.AccountMergeRolledBack
+		merge.RolledBackBy = &adminID
+		merge.RolledBackAt = &now
+		return tx.Save(ctx, merge)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info(fmt.Sprintf("admin %d rolled back merge %d (user %d restored from user %d)", adminID, merge.ID, merge.SourceUserID, merge.TargetUserID), "audit", true, "admin_id", adminID, "user_id", merge.SourceUserID)
+	return merge, nil
+}
+
+// ListMerges returns the merge audit log, newest first (userID 0 = all users)
+func (ms *AccountMergeService) ListMerges(userID uint, limit int) ([]models.AccountMerge, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	return ms.mergeRepo().List(ms.queryContext(), userID, limit)
+}
+
+func mergeLookupError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrMergeUserNotFound
+	}
+	return err
+}
+
+func sameTenant(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
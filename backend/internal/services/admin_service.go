@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"back_wa/internal/fieldcrypt"
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// ErrAdminUserNotFound is returned when the user to change does not exist
+var ErrAdminUserNotFound = errors.New("user not found")
+
+// AdminPage selects one page of an admin listing (1-based)
+type AdminPage struct {
+	Page  int
+	Limit int
+}
+
+// listQuery converts the page to a repository query (50 per page by default)
+func (p AdminPage) listQuery() repository.ListQuery {
+	if p.Limit <= 0 {
+		p.Limit = 50
+	}
+	if p.Page <= 0 {
+		p.Page = 1
+	}
+	return repository.ListQuery{Limit: p.Limit, Offset: (p.Page - 1) * p.Limit}
+}
+
+// AdminUserFilter narrows GET /api/admin/users
+type AdminUserFilter struct {
+	Query  string // matched against username, email and phone number
+	Role   string
+	Active *bool
+}
+
+// AdminTransactionFilter narrows GET /api/admin/transactions
+type AdminTransactionFilter struct {
+	Status string
+	UserID int
+}
+
+// AdminSession is a whatsapp_sessions row without the stored session data
+type AdminSession = repository.SessionListing
+
+// AdminService backs the /api/admin user, transaction and session listings and reports
+type AdminService struct {
+	ctx   context.Context
+	repos *repository.Repositories
+}
+
+// NewAdminService creates an admin service on the given repositories.
+// The zero value AdminService{} uses the default repositories.
+func NewAdminService(repos *repository.Repositories) *AdminService {
+	return &AdminService{repos: repos}
+}
+
+// WithContext returns a copy of the service bound to the request context
+func (as *AdminService) WithContext(ctx context.Context) *AdminService {
+	return &AdminService{ctx: ctx, repos: as.repos}
+}
+
+// repositories returns the injected repositories or the default ones
+func (as *AdminService) repositories() *repository.Repositories {
+	if as.repos != nil {
+		return as.repos
+	}
+	return repository.Default()
+}
+
+// queryContext returns the service context (background when unset)
+func (as *AdminService) queryContext() context.Context {
+	if as.ctx != nil {
+		return as.ctx
+	}
+	return context.Background()
+}
+
+// ListUsers returns one page of users and the total matching the filter
+func (as *AdminService) ListUsers(filter AdminUserFilter, page AdminPage) ([]models.User, int64, error) {
+	userFilter := repository.UserFilter{Search: strings.TrimSpace(filter.Query), Role: filter.Role, Active: filter.Active}
+	// Phone numbers are encrypted: they only match as a whole number, through the blind index
+	if hash := fieldcrypt.BlindIndex(NormalizePhoneNumber(userFilter.Search)); userFilter.Search != "" && hash != "" {
+		userFilter.PhoneHashes = []string{hash, fieldcrypt.BlindIndex(userFilter.Search)}
+	}
+	return as.repositories().Admin.ListUsers(as.queryContext(), userFilter, page.listQuery())
+}
+
+// SetUserActive activates or deactivates an account. Deactivated users can no longer log in.
+func (as *AdminService) SetUserActive(userID uint, active bool) (*models.User, error) {
+	users := as.repositories().Users
+	user, err := users.FindByID(as.queryContext(), userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAdminUserNotFound
+		}
+		return nil, err
+	}
+	if err := users.UpdateColumns(as.queryContext(), userID, map[string]interface{}{"is_active": active}); err != nil {
+		return nil, err
+	}
+	user.IsActive = active
+	return user, nil
+}
+
+// ListTransactions returns one page of transactions of all users, newest first
+func (as *AdminService) ListTransactions(filter AdminTransactionFilter, page AdminPage) ([]models.Transaction, int64, error) {
+	return as.repositories().Admin.ListTransactions(as.queryContext(), filter.Status, filter.UserID, page.listQuery())
+}
+
+// ListUserAnalyses returns one page of a user's analysis results with the user, newest first
+func (as *AdminService) ListUserAnalyses(userID uint, page AdminPage) ([]models.AdminAnalysisResponse, int64, error) {
+	results, total, err := as.repositories().Admin.ListUserAnalyses(as.queryContext(), userID, page.listQuery())
+	if err != nil {
+		return nil, 0, err
+	}
+	responses := make([]models.AdminAnalysisResponse, 0, len(results))
+	for _, result := range results {
+		responses = append(responses, models.NewAdminAnalysisResponse(result))
+	}
+	return responses, total, nil
+}
+
+// ListSessions returns one page of stored WhatsApp sessions, most recently active first
+func (as *AdminService) ListSessions(status string, page AdminPage) ([]AdminSession, int64, error) {
+	return as.repositories().Admin.ListSessions(as.queryContext(), status, page.listQuery())
+}
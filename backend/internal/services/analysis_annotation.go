@@ -0,0 +1,61 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"back_wa/internal/models"
+)
+
+// MaxAnalysisLabelLength is the longest label a result can carry, in characters
+const MaxAnalysisLabelLength = 100
+
+// ErrAnalysisLabelTooLong is returned for a label longer than MaxAnalysisLabelLength
+var ErrAnalysisLabelTooLong = errors.New("label must be at most 100 characters")
+
+// AnalysisAnnotation is the body of PATCH /api/analysis/{id}; fields left out are unchanged
+type AnalysisAnnotation struct {
+	Pinned *bool   `json:"pinned"`
+	Label  *string `json:"label"` // "" removes the label
+}
+
+// AnnotateAnalysis pins, unpins or labels one of the user's results.
+// Returns gorm.ErrRecordNotFound when the result does not belong to the user.
+func (as *AnalysisService) AnnotateAnalysis(userID, analysisID uint, annotation AnalysisAnnotation) (*models.AnalysisResult, error) {
+	columns := map[string]interface{}{}
+	var label string
+	if annotation.Label != nil {
+		label = strings.TrimSpace(*annotation.Label)
+		if utf8.RuneCountInString(label) > MaxAnalysisLabelLength {
+			return nil, ErrAnalysisLabelTooLong
+		}
+		if label == "" {
+			columns["label"] = nil
+		} else {
+			columns["label"] = label
+		}
+	}
+	if annotation.Pinned != nil {
+		columns["pinned"] = *annotation.Pinned
+	}
+
+	ctx := as.queryContext()
+	result, err := as.analysisRepo().FindForUser(ctx, analysisID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return result, nil
+	}
+	if err := as.analysisRepo().UpdateColumns(ctx, result, columns); err != nil {
+		return nil, err
+	}
+	if annotation.Label != nil {
+		result.Label = label
+	}
+	if annotation.Pinned != nil {
+		result.Pinned = *annotation.Pinned
+	}
+	return result, nil
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"back_wa/internal/logging"
+)
+
+// defaultAnalysisInsertBatchSize is the number of breakdown rows per INSERT
+const defaultAnalysisInsertBatchSize = 100
+
+// analysisInsertBatchSize returns ANALYSIS_INSERT_BATCH_SIZE or the default
+func analysisInsertBatchSize() int {
+	size := getIntEnv("ANALYSIS_INSERT_BATCH_SIZE", defaultAnalysisInsertBatchSize)
+	if size <= 0 {
+		return defaultAnalysisInsertBatchSize
+	}
+	return size
+}
+
+// insertBatches is the number of INSERT statements CreateInBatches issues for rows
+func insertBatches(rows, batchSize int) int {
+	return (rows + batchSize - 1) / batchSize
+}
+
+// hashJID hashes a contact JID for the per-contact breakdown
+func hashJID(jid string) string {
+	sum := sha256.Sum256([]byte(jid))
+	return hex.EncodeToString(sum[:])
+}
+
+// purgeBreakdowns removes group/contact breakdown rows of analyses that no longer exist.
+// Rows of analyses that survived the delete (e.g. under legal hold) are kept.
+func (as *AnalysisService) purgeBreakdowns(analysisIDs []uint) {
+	// Breakdown rows carry no tenant, the analysis IDs were already checked against it
+	if err := as.analysisRepo().PurgeBreakdowns(context.Background(), analysisIDs); err != nil {
+		logging.FromContext(as.ctx).Warn("Failed to purge analysis breakdown rows", "error", err)
+	}
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"back_wa/internal/repository"
+)
+
+// AnalysisCostTotals sums the serving cost recorded on analysis results
+type AnalysisCostTotals struct {
+	Analyses         int64   `json:"analyses"`
+	DurationMs       int64   `json:"duration_ms"`
+	WhatsAppCalls    int64   `json:"whatsapp_calls"`
+	DBWrites         int64   `json:"db_writes"`
+	AvgDurationMs    float64 `json:"avg_duration_ms"`
+	AvgWhatsAppCalls float64 `json:"avg_whatsapp_calls"`
+	AvgDBWrites      float64 `json:"avg_db_writes"`
+}
+
+// UserAnalysisCost is the cost of one user's analyses
+type UserAnalysisCost struct {
+	UserID uint `json:"user_id"`
+	AnalysisCostTotals
+}
+
+// AnalysisCostReport is the cost section of GET /api/admin/metrics/analysis
+type AnalysisCostReport struct {
+	Since  time.Time          `json:"since"`
+	Totals AnalysisCostTotals `json:"totals"`
+	// Users with the highest total analysis time, or only the requested user
+	Users []UserAnalysisCost `json:"users"`
+}
+
+// AnalysisCost aggregates analysis cost since the given time, overall and per user.
+// userID limits the per-user list to that user; otherwise the top limit users are returned.
+// Deleted analyses are included, their cost was paid all the same.
+func (as *AdminService) AnalysisCost(since time.Time, userID uint, limit int) (*AnalysisCostReport, error) {
+	ctx, reports := as.queryContext(), as.repositories().Reports
+	report := &AnalysisCostReport{Since: since, Users: []UserAnalysisCost{}}
+
+	totals, err := reports.AnalysisCost(ctx, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate analysis cost: %v", err)
+	}
+	report.Totals = analysisCostTotals(totals)
+
+	users, err := reports.AnalysisCostByUser(ctx, since, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate analysis cost per user: %v", err)
+	}
+	for _, sums := range users {
+		report.Users = append(report.Users, UserAnalysisCost{UserID: sums.UserID, AnalysisCostTotals: analysisCostTotals(sums)})
+	}
+	return report, nil
+}
+
+// analysisCostTotals adds the averages to the summed cost
+func analysisCostTotals(sums repository.AnalysisCostSums) AnalysisCostTotals {
+	totals := AnalysisCostTotals{
+		Analyses:      sums.Analyses,
+		DurationMs:    sums.DurationMs,
+		WhatsAppCalls: sums.WhatsAppCalls,
+		DBWrites:      sums.DBWrites,
+	}
+	totals.averages()
+	return totals
+}
+
+func (t *AnalysisCostTotals) averages() {
+	if t.Analyses == 0 {
+		return
+	}
+	n := float64(t.Analyses)
+	t.AvgDurationMs = float64(t.DurationMs) / n
+	t.AvgWhatsAppCalls = float64(t.WhatsAppCalls) / n
+	t.AvgDBWrites = float64(t.DBWrites) / n
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"time"
+
+	"back_wa/internal/models"
+)
+
+// AnalysisDedupWindow returns ANALYSIS_DEDUP_MINUTES (default 5). An analysis of the same number
+// with the same parameter snapshot within it returns the stored result instead of a new row,
+// which catches double-clicked scans; 0 or less disables the check.
+func AnalysisDedupWindow() time.Duration {
+	return time.Duration(getIntEnv("ANALYSIS_DEDUP_MINUTES", 5)) * time.Minute
+}
+
+// FindDuplicate returns the stored analysis of the user's phone number with the same eight
+// parameter values as result scanned within the dedup window before now, nil when there is none
+func (as *AnalysisService) FindDuplicate(result *models.AnalysisResult, phone string, now time.Time) (*models.AnalysisResult, error) {
+	window := AnalysisDedupWindow()
+	if window <= 0 || phone == "" {
+		return nil, nil
+	}
+
+	candidates, err := as.analysisRepo().FindSameSnapshot(as.queryContext(), result, now.Add(-window))
+	if err != nil {
+		return nil, err
+	}
+	phone = NormalizePhoneNumber(phone)
+	for i := range candidates {
+		if candidates[i].ScanHistoryID != nil && NormalizePhoneNumber(candidates[i].ScanHistory.PhoneNumber) == phone {
+			return &candidates[i], nil
+		}
+	}
+	return nil, nil
+}
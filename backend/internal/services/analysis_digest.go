@@ -0,0 +1,320 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"strings"
+	"time"
+
+	"back_wa/internal/database"
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// ValidDigestFrequency reports whether frequency is off, weekly or monthly
+func ValidDigestFrequency(frequency string) bool {
+	return frequency == models.DigestOff || frequency == models.DigestWeekly || frequency == models.DigestMonthly
+}
+
+// DigestPolicy configures the email digest job
+type DigestPolicy struct {
+	Interval  time.Duration // DIGEST_CHECK_MINUTES (default 60), 0 disables the job
+	SendHour  int           // DIGEST_SEND_HOUR (default 8): digests go out from this hour, scheduler time zone
+	BatchSize int           // DIGEST_BATCH_SIZE (default 200) digests per run
+}
+
+// LoadDigestPolicy reads the policy from the environment
+func LoadDigestPolicy() DigestPolicy {
+	policy := DigestPolicy{
+		Interval:  time.Duration(getIntEnv("DIGEST_CHECK_MINUTES", 60)) * time.Minute,
+		SendHour:  getIntEnv("DIGEST_SEND_HOUR", 8),
+		BatchSize: getIntEnv("DIGEST_BATCH_SIZE", 200),
+	}
+	if policy.SendHour < 0 || policy.SendHour > 23 {
+		policy.SendHour = 8
+	}
+	if policy.BatchSize <= 0 {
+		policy.BatchSize = 200
+	}
+	return policy
+}
+
+// nextDigest returns when the digest after one sent at sentAt is due: a week or a month later
+// at the send hour. A user who just opted in (sentAt nil) is due from the send hour today.
+func (p DigestPolicy) nextDigest(frequency string, sentAt *time.Time, now time.Time) time.Time {
+	loc := ScanScheduleLocation()
+	from := now.In(loc)
+	if sentAt != nil {
+		from = sentAt.In(loc)
+	}
+	next := time.Date(from.Year(), from.Month(), from.Day(), p.SendHour, 0, 0, 0, loc)
+	if sentAt == nil {
+		return next
+	}
+	if frequency == models.DigestMonthly {
+		return next.AddDate(0, 1, 0)
+	}
+	return next.AddDate(0, 0, 7)
+}
+
+// DigestSweep summarizes one run of the job
+type DigestSweep struct {
+	Sent    int `json:"sent"`
+	Skipped int `json:"skipped"` // nothing to report, or the account cannot receive email
+	Failed  int `json:"failed"`
+}
+
+// StartDigestWorker sends due digests every policy interval (no-op when disabled)
+func StartDigestWorker(repos *repository.Repositories, policy DigestPolicy) {
+	if policy.Interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(policy.Interval)
+		defer ticker.Stop()
+		for {
+			if database.IsDegraded() {
+				slog.Debug("Digest job skipped while the database is degraded")
+			} else if sweep, err := RunDigestSweep(context.Background(), repos, policy, time.Now()); err != nil {
+				slog.Warn("Digest job failed", "error", err)
+			} else if sweep.Sent+sweep.Failed > 0 {
+				slog.Debug(fmt.Sprintf("Digest job: sent=%d skipped=%d failed=%d", sweep.Sent, sweep.Skipped, sweep.Failed))
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// RunDigestSweep emails every user whose weekly or monthly digest is due
+func RunDigestSweep(ctx context.Context, repos *repository.Repositories, policy DigestPolicy, now time.Time) (DigestSweep, error) {
+	var sweep DigestSweep
+
+	// A weekly digest is never due within 6 days of the last one, which keeps the candidates small
+	prefs, err := repos.Pushes.ListDigestCandidates(ctx, now.AddDate(0, 0, -6), policy.BatchSize)
+	if err != nil {
+		return sweep, err
+	}
+
+	for i := range prefs {
+		pref := &prefs[i]
+		if now.Before(policy.nextDigest(pref.EmailDigest, pref.DigestSentAt, now)) {
+			continue
+		}
+		claimed, err := repos.Pushes.ClaimDigest(ctx, pref.UserID, pref.DigestSentAt, now)
+		if err != nil {
+			return sweep, err
+		}
+		if !claimed {
+			continue
+		}
+
+		sent, err := sendDigest(ctx, repos, pref, now)
+		switch {
+		case err != nil:
+			slog.Warn("Failed to send email digest", "user_id", pref.UserID, "error", err)
+			sweep.Failed++
+			// Hand the period back so the next run retries it
+			_ = repos.Pushes.SetDigestSentAt(ctx, pref.UserID, pref.DigestSentAt)
+		case sent:
+			sweep.Sent++
+		default:
+			sweep.Skipped++
+		}
+	}
+	return sweep, nil
+}
+
+// AnalysisDigest is the content of the weekly or monthly digest email
+type AnalysisDigest struct {
+	Name             string
+	BrandName        string
+	Monthly          bool
+	PeriodStart      time.Time
+	PeriodEnd        time.Time
+	Strength         string    // rating of the latest analysis
+	LatestScanDate   time.Time // of the latest analysis
+	PreviousStrength string    // rating at the start of the period, empty when there was none
+	Trend            string    // ↑, ↓ or →
+	TrendLabel       string
+	ScanCount        int      // analyses in the period
+	RiskFlags        []string // problems found by the analyses in the period
+	Goal             *GoalProgress
+	GoalLines        []string // one line per target of Goal
+	DashboardURL     string
+	SettingsURL      string
+}
+
+var analysisDigestTemplate = template.Must(template.New("analysis_digest").Parse(`<h2>Ringkasan {{if .Monthly}}bulanan{{else}}mingguan{{end}} WhatsApp kamu</h2>
+<p>Halo {{.Name}},</p>
+<p>Berikut ringkasan akun WhatsApp kamu untuk {{.PeriodStart.Format "02 Jan 2006"}} - {{.PeriodEnd.Format "02 Jan 2006"}}.</p>
+<p>Kekuatan akun terakhir: <strong>{{.Strength}}</strong> <span style="font-size:1.2em">{{.Trend}}</span> {{.TrendLabel}}{{if .PreviousStrength}} (sebelumnya: {{.PreviousStrength}}){{end}}<br>
+<small>Scan terakhir: {{.LatestScanDate.Format "02 Jan 2006 15:04"}}</small></p>
+{{if .ScanCount}}<p>{{.ScanCount}} scan dalam periode ini.</p>{{else}}<p>Belum ada scan baru sejak ringkasan terakhir.</p>{{end}}
+{{if .RiskFlags}}<h3>Perlu perhatian</h3>
+<ul>
+{{range .RiskFlags}}<li>{{.}}</li>
+{{end}}</ul>{{else if .ScanCount}}<p>Tidak ada risiko baru yang terdeteksi.</p>{{end}}
+{{with .Goal}}<h3>Target kamu</h3>
+<p>{{.Met}} dari {{.Total}} target tercapai ({{.Percent}}%){{if .Achieved}}. Selamat, semua target sudah tercapai!{{end}}</p>
+<ul>
+{{range $.GoalLines}}<li>{{.}}</li>
+{{end}}</ul>{{end}}
+<p><a href="{{.DashboardURL}}">Buka dashboard {{.BrandName}}</a> untuk melihat detail dan riwayat analisis.</p>
+<p><small>Kamu menerima email ini karena mengaktifkan ringkasan email. <a href="{{.SettingsURL}}">Ubah pengaturan notifikasi</a>.</small></p>`))
+
+// renderAnalysisDigest returns the subject and HTML body of the digest email
+func renderAnalysisDigest(digest AnalysisDigest) (string, string, error) {
+	period := "mingguan"
+	if digest.Monthly {
+		period = "bulanan"
+	}
+	subject := fmt.Sprintf("Ringkasan %s WhatsApp kamu: %s %s", period, digest.Strength, digest.Trend)
+	var body strings.Builder
+	if err := analysisDigestTemplate.Execute(&body, digest); err != nil {
+		return "", "", err
+	}
+	return subject, body.String(), nil
+}
+
+// strengthRank orders the overall ratings for the trend arrow
+var strengthRank = map[string]int{"Buruk": 1, "Cukup": 2, "Baik": 3}
+
+// sendDigest builds and emails the user's digest for the period since the last one. It returns
+// false without sending when the user has no analysis yet or cannot receive email.
+func sendDigest(ctx context.Context, repos *repository.Repositories, pref *models.PushPreference, now time.Time) (bool, error) {
+	user, err := repos.Users.FindByID(ctx, pref.UserID)
+	if err != nil {
+		return false, err
+	}
+	if user.Email == "" || !user.IsActive || !user.EmailVerified || user.AnonymizedAt != nil {
+		return false, nil
+	}
+
+	start := now.AddDate(0, 0, -7)
+	if pref.EmailDigest == models.DigestMonthly {
+		start = now.AddDate(0, -1, 0)
+	}
+	if pref.DigestSentAt != nil {
+		start = *pref.DigestSentAt
+	}
+
+	latest, err := repos.Analyses.Latest(ctx, user.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	inPeriod, err := repos.Analyses.ListScannedBetween(ctx, user.ID, start, now)
+	if err != nil {
+		return false, err
+	}
+	var previous *models.AnalysisResult
+	if before, err := repos.Analyses.LatestAt(ctx, user.ID, start); err == nil {
+		previous = before
+	}
+
+	tenant, branding := userBranding(ctx, repos.Tenants, user)
+	loc := ScanScheduleLocation()
+	baseURL := strings.TrimRight(branding.FrontendBaseURL, "/")
+	digest := AnalysisDigest{
+		Name:           user.Username,
+		BrandName:      branding.BrandName,
+		Monthly:        pref.EmailDigest == models.DigestMonthly,
+		PeriodStart:    start.In(loc),
+		PeriodEnd:      now.In(loc),
+		Strength:       latest.Strength,
+		LatestScanDate: latest.ScanDate.In(loc),
+		ScanCount:      len(inPeriod),
+		RiskFlags:      digestRiskFlags(previous, inPeriod),
+		DashboardURL:   baseURL + "/dashboard",
+		SettingsURL:    baseURL + "/settings/notifications",
+	}
+	if progress, err := NewGoalService(repos.Goals, repos.Analyses).WithContext(ctx).Progress(user.ID); err != nil {
+		slog.Warn("Failed to load goal progress for digest", "user_id", user.ID, "error", err)
+	} else if progress != nil {
+		digest.Goal, digest.GoalLines = progress, digestGoalLines(progress)
+	}
+	digest.Trend, digest.TrendLabel = "→", "stabil"
+	if previous != nil {
+		digest.PreviousStrength = previous.Strength
+		switch diff := strengthRank[latest.Strength] - strengthRank[previous.Strength]; {
+		case diff > 0:
+			digest.Trend, digest.TrendLabel = "↑", "membaik"
+		case diff < 0:
+			digest.Trend, digest.TrendLabel = "↓", "menurun"
+		}
+	}
+
+	subject, body, err := renderAnalysisDigest(digest)
+	if err != nil {
+		return false, err
+	}
+	if err := EmailServiceFor(tenant).SendEmail(user.Email, subject, body); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// digestGoalLines describes each target of the goal as "current → target" with its progress
+func digestGoalLines(progress *GoalProgress) []string {
+	var lines []string
+	status := func(met bool, percent int) string {
+		if met {
+			return "tercapai"
+		}
+		return fmt.Sprintf("%d%%", percent)
+	}
+	if s := progress.Strength; s != nil {
+		current := s.Current
+		if current == "" {
+			current = "-"
+		}
+		lines = append(lines, fmt.Sprintf("Kekuatan akun: %s → %s (%s)", current, s.Target, status(s.Met, s.Percent)))
+	}
+	for _, p := range progress.Parameters {
+		current := "-"
+		if p.Current != nil {
+			current = fmt.Sprint(*p.Current)
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s → %d (%s)", p.Parameter, current, p.Target, status(p.Met, p.Percent)))
+	}
+	return lines
+}
+
+// digestRiskFlags lists rating drops, sensitive content and the "Buruk" parameters of the
+// newest analysis among the analyses of the period (oldest first)
+func digestRiskFlags(previous *models.AnalysisResult, inPeriod []models.AnalysisResult) []string {
+	var flags []string
+	last := previous
+	sensitive := 0
+	for i := range inPeriod {
+		result := &inPeriod[i]
+		if last != nil && strengthRank[result.Strength] < strengthRank[last.Strength] {
+			flags = append(flags, fmt.Sprintf("Kekuatan akun turun dari %s ke %s pada %s",
+				last.Strength, result.Strength, result.ScanDate.In(ScanScheduleLocation()).Format("02 Jan 2006")))
+		}
+		for _, n := range result.SensitiveCategories {
+			sensitive += n
+		}
+		last = result
+	}
+	if sensitive > 0 {
+		flags = append(flags, fmt.Sprintf("%d pesan dengan konten sensitif terdeteksi", sensitive))
+	}
+	if len(inPeriod) > 0 {
+		for _, param := range inPeriod[len(inPeriod)-1].Parameters {
+			if param.Status == "Buruk" {
+				flags = append(flags, fmt.Sprintf("%s berstatus Buruk (%d)", param.Parameter, param.Value))
+			}
+		}
+	}
+	return flags
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"back_wa/internal/models"
+)
+
+// Analysis stages reported by the metrics endpoint
+const (
+	AnalysisStageContactFetch = "contact_fetch"
+	AnalysisStageGroupFetch   = "group_fetch"
+	AnalysisStageScoring      = "scoring"
+	AnalysisStagePersist      = "persist"
+	AnalysisStageTotal        = "total"
+)
+
+var analysisStages = []string{
+	AnalysisStageContactFetch,
+	AnalysisStageGroupFetch,
+	AnalysisStageScoring,
+	AnalysisStagePersist,
+	AnalysisStageTotal,
+}
+
+// AnalysisStageTimer measures the stages of one analysis run
+type AnalysisStageTimer struct {
+	start time.Time
+	last  time.Time
+}
+
+// NewAnalysisStageTimer starts timing an analysis
+func NewAnalysisStageTimer() *AnalysisStageTimer {
+	now := time.Now()
+	return &AnalysisStageTimer{start: now, last: now}
+}
+
+// Lap returns the milliseconds since the previous lap (or the start)
+func (t *AnalysisStageTimer) Lap() int64 {
+	now := time.Now()
+	elapsed := now.Sub(t.last).Milliseconds()
+	t.last = now
+	return elapsed
+}
+
+// Total returns the milliseconds since the timer started
+func (t *AnalysisStageTimer) Total() int64 {
+	return time.Since(t.start).Milliseconds()
+}
+
+// AnalysisStageStats summarizes one stage over the metrics window
+type AnalysisStageStats struct {
+	Count int   `json:"count"`
+	P50Ms int64 `json:"p50_ms"`
+	P95Ms int64 `json:"p95_ms"`
+	MaxMs int64 `json:"max_ms"`
+}
+
+// AnalysisMetricsSnapshot is the payload of GET /api/admin/metrics/analysis
+type AnalysisMetricsSnapshot struct {
+	Window      int                           `json:"window"`
+	Observed    int64                         `json:"observed"` // analyses since process start
+	SLOP95Ms    int64                         `json:"slo_p95_ms"`
+	SLOBreached bool                          `json:"slo_breached"`
+	Stages      map[string]AnalysisStageStats `json:"stages"`
+	Spool       AnalysisSpoolStats            `json:"spool"`
+	Anomalies   map[string]int64              `json:"anomalies"` // sanity-rule violations since process start, by rule
+}
+
+// analysisMetrics keeps the most recent analysis timings in a ring buffer
+type analysisMetrics struct {
+	mu       sync.Mutex
+	samples  []map[string]int64
+	next     int
+	observed int64
+}
+
+var (
+	analysisMetricsOnce    sync.Once
+	defaultAnalysisMetrics *analysisMetrics
+)
+
+func getAnalysisMetrics() *analysisMetrics {
+	analysisMetricsOnce.Do(func() {
+		window := getIntEnv("ANALYSIS_METRICS_WINDOW", 200)
+		if window <= 0 {
+			window = 200
+		}
+		defaultAnalysisMetrics = &analysisMetrics{samples: make([]map[string]int64, 0, window)}
+	})
+	return defaultAnalysisMetrics
+}
+
+// analysisSLOP95Ms returns the p95 objective for total analysis time, 0 when disabled
+func analysisSLOP95Ms() int64 {
+	return int64(getIntEnv("ANALYSIS_SLO_P95_MS", 0))
+}
+
+// RecordAnalysisTimings adds a finished analysis to the metrics window and alerts ops
+// when the p95 of total analysis time exceeds ANALYSIS_SLO_P95_MS
+func RecordAnalysisTimings(result *models.AnalysisResult) {
+	m := getAnalysisMetrics()
+	m.observe(map[string]int64{
+		AnalysisStageContactFetch: result.ContactFetchMs,
+		AnalysisStageGroupFetch:   result.GroupFetchMs,
+		AnalysisStageScoring:      result.ScoringMs,
+		AnalysisStagePersist:      result.PersistMs,
+		AnalysisStageTotal:        result.DurationMs,
+	})
+
+	slo := analysisSLOP95Ms()
+	if slo <= 0 {
+		return
+	}
+	snapshot := m.snapshot(slo)
+	total := snapshot.Stages[AnalysisStageTotal]
+	if snapshot.SLOBreached && total.Count >= getIntEnv("ANALYSIS_SLO_MIN_SAMPLES", 20) {
+		NewOpsAlerter().Alert("analysis_slo", fmt.Sprintf(
+			"Analysis p95 %dms exceeds SLO %dms over the last %d analyses (contact fetch p95 %dms, group fetch p95 %dms, scoring p95 %dms, persist p95 %dms)",
+			total.P95Ms, slo, total.Count,
+			snapshot.Stages[AnalysisStageContactFetch].P95Ms,
+			snapshot.Stages[AnalysisStageGroupFetch].P95Ms,
+			snapshot.Stages[AnalysisStageScoring].P95Ms,
+			snapshot.Stages[AnalysisStagePersist].P95Ms))
+	}
+}
+
+// AnalysisMetrics returns per-stage latency percentiles over the metrics window
+func AnalysisMetrics() AnalysisMetricsSnapshot {
+	snapshot := getAnalysisMetrics().snapshot(analysisSLOP95Ms())
+	snapshot.Spool = AnalysisSpool()
+	snapshot.Anomalies = inputAnomalyCounts()
+	return snapshot
+}
+
+func (m *analysisMetrics) observe(sample map[string]int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observed++
+	if len(m.samples) < cap(m.samples) {
+		m.samples = append(m.samples, sample)
+		return
+	}
+	m.samples[m.next] = sample
+	m.next = (m.next + 1) % len(m.samples)
+}
+
+func (m *analysisMetrics) snapshot(slo int64) AnalysisMetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := AnalysisMetricsSnapshot{
+		Window:   cap(m.samples),
+		Observed: m.observed,
+		SLOP95Ms: slo,
+		Stages:   make(map[string]AnalysisStageStats, len(analysisStages)),
+	}
+	for _, stage := range analysisStages {
+		values := make([]int64, 0, len(m.samples))
+		for _, sample := range m.samples {
+			values = append(values, sample[stage])
+		}
+		snapshot.Stages[stage] = stageStats(values)
+	}
+	snapshot.SLOBreached = slo > 0 && snapshot.Stages[AnalysisStageTotal].P95Ms > slo
+	return snapshot
+}
+
+// stageStats computes nearest-rank percentiles
+func stageStats(values []int64) AnalysisStageStats {
+	if len(values) == 0 {
+		return AnalysisStageStats{}
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	rank := func(p int) int64 {
+		idx := (p*len(values)+99)/100 - 1
+		if idx < 0 {
+			idx = 0
+		}
+		return values[idx]
+	}
+	return AnalysisStageStats{
+		Count: len(values),
+		P50Ms: rank(50),
+		P95Ms: rank(95),
+		MaxMs: values[len(values)-1],
+	}
+}
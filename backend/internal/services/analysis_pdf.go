@@ -0,0 +1,132 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"back_wa/internal/models"
+)
+
+// pdfLinesPerPage is how many 14pt lines fit between the top (y=790) and bottom (y=50) margins of an A4 page
+const pdfLinesPerPage = 52
+
+// RenderAnalysisPDF renders a PDF report of a shared analysis, continuing on further pages when
+// the summary is long. It writes the PDF objects by hand (Helvetica, no embedded fonts) to avoid
+// a PDF dependency.
+func RenderAnalysisPDF(a *models.SharedAnalysis, brandName string) []byte {
+	lines := []string{
+		fmt.Sprintf("%s - Laporan Analisis WhatsApp", brandName),
+		"",
+		fmt.Sprintf("Nomor: %s", a.PhoneNumber),
+		fmt.Sprintf("Tanggal scan: %s", a.ScanDate.Format("02 Jan 2006 15:04")),
+		fmt.Sprintf("Kekuatan akun: %s", a.Strength),
+		"",
+		fmt.Sprintf("Total chat: %d", a.TotalChats),
+		fmt.Sprintf("Total kontak: %d", a.TotalContacts),
+		fmt.Sprintf("Umur akun: %d hari", a.AccountAgeDays),
+		fmt.Sprintf("Total grup: %d", a.TotalGroups),
+		fmt.Sprintf("Chat dengan kontak: %d", a.TotalChatWithContact),
+		fmt.Sprintf("Konten sensitif: %d", a.SensitiveContentCount),
+		fmt.Sprintf("Chat belum disimpan: %d", a.TotalUnsavedChats),
+		fmt.Sprintf("Chat nomor tidak dikenal: %d", a.UnknownNumberChats),
+		"",
+		"Ringkasan:",
+	}
+	for _, paragraph := range strings.Split(a.Summary, "\n") {
+		lines = append(lines, wrapText(paragraph, 90)...)
+	}
+	if a.Checksum != "" {
+		lines = append(lines, "", "Checksum SHA-256:", a.Checksum)
+	}
+
+	// Objects 1-3 are the catalog, the page tree and the font; each page adds a page and a content object
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+	}
+	var kids []string
+	for start := 0; start < len(lines); start += pdfLinesPerPage {
+		end := start + pdfLinesPerPage
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		var content bytes.Buffer
+		content.WriteString("BT\n/F1 11 Tf\n14 TL\n50 790 Td\n")
+		for i := start; i < end; i++ {
+			if i == 0 {
+				content.WriteString("/F1 16 Tf\n")
+			} else if i == 1 {
+				content.WriteString("/F1 11 Tf\n")
+			}
+			fmt.Fprintf(&content, "(%s) Tj T*\n", escapePDFText(lines[i]))
+		}
+		content.WriteString("ET\n")
+
+		page := len(objects) + 1
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", page+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+		)
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes()
+}
+
+// escapePDFText escapes a PDF string literal and drops characters outside Latin-1
+func escapePDFText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r < 32:
+			b.WriteRune(' ')
+		case r > 255:
+			// emoji and other symbols are not available in the standard font
+		case r > 126:
+			b.WriteByte(byte(r)) // WinAnsi/Latin-1 single byte
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// wrapText splits text into lines of at most width runes on word boundaries
+func wrapText(text string, width int) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return []string{""}
+	}
+
+	var lines []string
+	current := words[0]
+	for _, word := range words[1:] {
+		if len([]rune(current))+1+len([]rune(word)) > width {
+			lines = append(lines, current)
+			current = word
+			continue
+		}
+		current += " " + word
+	}
+	return append(lines, current)
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+)
+
+// AnalysisReport is the content of the email sent after an analysis
+type AnalysisReport struct {
+	Name             string
+	BrandName        string
+	Strength         string
+	PreviousStrength string // rating of the analysis before, empty for the first one
+	Summary          string
+	ScanDate         time.Time
+	TotalChats       int
+	TotalContacts    int
+	TotalGroups      int
+	AccountAgeDays   int
+	Parameters       []models.ParameterEvaluation
+	DashboardURL     string
+	Scheduled        bool // ran from the user's scan schedule
+}
+
+var analysisReportTemplate = template.Must(template.New("analysis_report").Parse(`<h2>{{if .Scheduled}}Hasil scan terjadwal{{else}}Hasil analisis{{end}} WhatsApp kamu</h2>
+<p>Halo {{.Name}},</p>
+<p>Analisis nomor WhatsApp kamu pada {{.ScanDate.Format "02 Jan 2006 15:04"}} sudah selesai.</p>
+<p>Kekuatan akun: <strong>{{.Strength}}</strong>{{if .PreviousStrength}} (sebelumnya: {{.PreviousStrength}}){{end}}</p>
+<table cellpadding="4">
+<tr><td>Total chat</td><td>{{.TotalChats}}</td></tr>
+<tr><td>Total kontak</td><td>{{.TotalContacts}}</td></tr>
+<tr><td>Total grup</td><td>{{.TotalGroups}}</td></tr>
+<tr><td>Umur akun</td><td>{{.AccountAgeDays}} hari</td></tr>
+</table>
+{{if .Parameters}}<h3>Parameter utama</h3>
+<table cellpadding="4">
+<tr><th align="left">Parameter</th><th align="left">Nilai</th><th align="left">Status</th></tr>
+{{range .Parameters}}<tr><td>{{.Parameter}}</td><td>{{.Value}}{{if .Estimated}} (estimasi){{end}}</td><td>{{.Status}}</td></tr>
+{{end}}</table>{{end}}
+{{if .Summary}}<p>{{.Summary}}</p>{{end}}
+<p><a href="{{.DashboardURL}}">Buka dashboard {{.BrandName}}</a> untuk melihat detail dan riwayat analisis.</p>`))
+
+// renderAnalysisReport returns the subject and HTML body of the report email
+func renderAnalysisReport(report AnalysisReport) (string, string, error) {
+	subject := fmt.Sprintf("Hasil analisis WhatsApp kamu: %s", report.Strength)
+	if report.Scheduled {
+		subject = fmt.Sprintf("Hasil scan terjadwal WhatsApp kamu: %s", report.Strength)
+	}
+	var body strings.Builder
+	if err := analysisReportTemplate.Execute(&body, report); err != nil {
+		return "", "", err
+	}
+	return subject, body.String(), nil
+}
+
+// SendAnalysisReport emails the strength rating, key parameters and a dashboard link of an analysis
+func (s *EmailService) SendAnalysisReport(to string, report AnalysisReport) error {
+	subject, body, err := renderAnalysisReport(report)
+	if err != nil {
+		return err
+	}
+	return s.SendEmail(to, subject, body)
+}
+
+// analysisReportsEnabled reports whether every analysis is emailed; ANALYSIS_REPORT_EMAIL=false disables it
+func analysisReportsEnabled() bool {
+	return os.Getenv("ANALYSIS_REPORT_EMAIL") != "false"
+}
+
+// SendAnalysisReportAsync emails the report of a stored analysis in the background. It is best
+// effort: failures are logged and never affect the analysis. repos nil uses the default repositories.
+func SendAnalysisReportAsync(repos *repository.Repositories, result *models.AnalysisResult) {
+	if !analysisReportsEnabled() {
+		return
+	}
+	snapshot := *result
+	go func() {
+		if err := sendAnalysisReport(context.Background(), repos, &snapshot); err != nil {
+			slog.Warn("Failed to email analysis report", "user_id", snapshot.UserID, "analysis_id", snapshot.ID, "error", err)
+		}
+	}()
+}
+
+// sendAnalysisReport emails the result to its user, in the brand of the user's tenant
+func sendAnalysisReport(ctx context.Context, repos *repository.Repositories, result *models.AnalysisResult) error {
+	if repos == nil {
+		repos = repository.Default()
+	}
+	user, err := repos.Users.FindByID(ctx, result.UserID)
+	if err != nil {
+		return err
+	}
+	if user.Email == "" || user.AnonymizedAt != nil {
+		return nil
+	}
+	tenant, branding := userBranding(ctx, repos.Tenants, user)
+
+	report := AnalysisReport{
+		Name:           user.Username,
+		BrandName:      branding.BrandName,
+		Strength:       result.Strength,
+		Summary:        result.Summary,
+		ScanDate:       result.ScanDate.In(ScanScheduleLocation()),
+		TotalChats:     result.TotalChats,
+		TotalContacts:  result.TotalContacts,
+		TotalGroups:    result.TotalGroups,
+		AccountAgeDays: result.AccountAgeDays,
+		Parameters:     result.Parameters,
+		DashboardURL:   strings.TrimRight(branding.FrontendBaseURL, "/") + "/dashboard",
+		Scheduled:      result.Scheduled,
+	}
+	if result.ID != 0 {
+		if before, err := repos.Analyses.PreviousOf(ctx, result.UserID, result.ID); err == nil {
+			report.PreviousStrength = before.Strength
+		}
+	}
+	return EmailServiceFor(tenant).SendAnalysisReport(user.Email, report)
+}
+
+// userBranding returns the user's tenant (nil for the default brand) and the brand name and
+// frontend URL emails to the user are sent with
+func userBranding(ctx context.Context, tenants repository.TenantRepo, user *models.User) (*models.Tenant, models.TenantBranding) {
+	var tenant *models.Tenant
+	if user.TenantID != nil {
+		if t, err := tenants.FindByID(ctx, *user.TenantID); err == nil {
+			tenant = t
+		}
+	}
+	branding := DefaultBranding()
+	if tenant != nil {
+		if tenant.BrandName != "" {
+			branding.BrandName = tenant.BrandName
+		}
+		if tenant.FrontendBaseURL != "" {
+			branding.FrontendBaseURL = tenant.FrontendBaseURL
+		}
+	}
+	return tenant, branding
+}
@@ -2,28 +2,66 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"log"
 	"time"
 
 	"back_wa/internal/database"
+	"back_wa/internal/logging"
 	"back_wa/internal/models"
+	"back_wa/internal/repository"
 
 	"go.mau.fi/whatsmeow"
 	"go.mau.fi/whatsmeow/types"
 )
 
 // AnalysisService handles WhatsApp analysis for multiple users
-type AnalysisService struct{}
+type AnalysisService struct {
+	ctx   context.Context
+	repos *repository.Repositories
+}
+
+// NewAnalysisService creates an analysis service on the given repositories. Besides analyses
+// and users it notifies, meters usage and checks goals through them once a result is stored.
+// The zero value AnalysisService{} uses the default repositories.
+func NewAnalysisService(repos *repository.Repositories) *AnalysisService {
+	return &AnalysisService{repos: repos}
+}
+
+// WithContext returns an AnalysisService whose queries are limited to the tenant scope in ctx
+func (as *AnalysisService) WithContext(ctx context.Context) *AnalysisService {
+	return &AnalysisService{ctx: ctx, repos: as.repos}
+}
+
+// repositories returns the injected repositories or the default ones
+func (as *AnalysisService) repositories() *repository.Repositories {
+	if as.repos != nil {
+		return as.repos
+	}
+	return repository.Default()
+}
+
+// analysisRepo returns the analysis repository
+func (as *AnalysisService) analysisRepo() repository.AnalysisRepo {
+	return as.repositories().Analyses
+}
 
-// NewAnalysisService creates a new analysis service
-func NewAnalysisService() *AnalysisService {
-	return &AnalysisService{}
+// userRepo returns the user repository
+func (as *AnalysisService) userRepo() repository.UserRepo {
+	return as.repositories().Users
+}
+
+// queryContext returns the service context (background when unset)
+func (as *AnalysisService) queryContext() context.Context {
+	if as.ctx != nil {
+		return as.ctx
+	}
+	return context.Background()
 }
 
 // AnalyzeWhatsApp analyzes WhatsApp data for a specific user
 func (as *AnalysisService) AnalyzeWhatsApp(userID uint, client *whatsmeow.Client) (*models.AnalysisResult, error) {
-	log.Printf("DEBUG: User %d - Starting WhatsApp analysis...", userID)
+	logging.FromContext(as.ctx).Debug("Starting WhatsApp analysis...", "user_id", userID)
 
 	if client == nil {
 		return nil, fmt.Errorf("WhatsApp client not available")
@@ -34,22 +72,25 @@ func (as *AnalysisService) AnalyzeWhatsApp(userID uint, client *whatsmeow.Client
 		return nil, fmt.Errorf("WhatsApp not connected")
 	}
 
+	timer := NewAnalysisStageTimer()
+
 	// Get contacts with timeout (reduced from 10s to 5s like single-user)
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	log.Printf("DEBUG: User %d - Fetching contacts with timeout...", userID)
+	logging.FromContext(as.ctx).Debug("Fetching contacts with timeout...", "user_id", userID)
 	allContacts, err := client.Store.Contacts.GetAllContacts(ctx)
 	if err != nil {
-		log.Printf("DEBUG: User %d - Error getting contacts: %v", userID, err)
+		logging.FromContext(as.ctx).Debug("Error getting contacts", "user_id", userID, "error", err)
 		return nil, fmt.Errorf("failed to get contacts: %v", err)
 	}
+	contactFetchMs := timer.Lap()
 
-	log.Printf("DEBUG: User %d - Total contacts found: %d", userID, len(allContacts))
+	logging.FromContext(as.ctx).Debug(fmt.Sprintf("Total contacts found: %d", len(allContacts)), "user_id", userID)
 
 	// If no contacts found, return specific error message
 	if len(allContacts) == 0 {
-		log.Printf("DEBUG: User %d - No contacts found, cannot analyze empty contact list", userID)
+		logging.FromContext(as.ctx).Debug("No contacts found, cannot analyze empty contact list", "user_id", userID)
 		return nil, fmt.Errorf("contacts not loaded yet. Please wait a moment and try again")
 	}
 
@@ -60,32 +101,46 @@ func (as *AnalysisService) AnalyzeWhatsApp(userID uint, client *whatsmeow.Client
 	groupCount := 0
 	unsavedCount := 0
 
+	// Per-group / per-contact breakdown stored alongside the result
+	var groupBreakdown []models.AnalysisGroup
+	var contactBreakdown []models.AnalysisContact
+
 	for jid, contact := range allContacts {
+		saved := contact.FullName != "" && contact.FullName != "Unknown"
+		if jid.Server == "g.us" {
+			groupBreakdown = append(groupBreakdown, models.AnalysisGroup{GroupJID: jid.String(), Name: contact.FullName})
+		} else {
+			contactBreakdown = append(contactBreakdown, models.AnalysisContact{
+				ContactHash: hashJID(jid.String()),
+				Server:      jid.Server,
+				Saved:       saved,
+				Business:    contact.BusinessName != "",
+			})
+		}
+
 		// Separate saved and unsaved contacts
-		if contact.FullName != "" && contact.FullName != "Unknown" {
+		if saved {
 			savedContacts[jid] = contact
 			contactCount++
 			if contactCount <= 10 { // Log first 10 saved contacts
-				log.Printf("DEBUG: User %d - Saved Contact %d: %s (Server: %s, Name: %s, BusinessName: %s)",
-					userID, contactCount, jid.String(), jid.Server, contact.FullName, contact.BusinessName)
+				logging.FromContext(as.ctx).Debug(fmt.Sprintf("Saved Contact %d: %s (Server: %s, Name: %s, BusinessName: %s)", contactCount, jid.String(), jid.Server, contact.FullName, contact.BusinessName), "user_id", userID)
 			}
 			if jid.Server == "g.us" {
 				groupCount++
-				log.Printf("DEBUG: User %d - Group found: %s (Name: %s)", userID, jid.String(), contact.FullName)
+				logging.FromContext(as.ctx).Debug(fmt.Sprintf("Group found: %s (Name: %s)", jid.String(), contact.FullName), "user_id", userID)
 			}
 		} else {
 			// Count unsaved contacts (no name or unknown name)
 			unsavedContacts[jid] = contact
 			unsavedCount++
 			if unsavedCount <= 5 { // Log first 5 unsaved contacts
-				log.Printf("DEBUG: User %d - Unsaved Contact %d: %s (Server: %s)",
-					userID, unsavedCount, jid.String(), jid.Server)
+				logging.FromContext(as.ctx).Debug(fmt.Sprintf("Unsaved Contact %d: %s (Server: %s)", unsavedCount, jid.String(), jid.Server), "user_id", userID)
 			}
 		}
 	}
 
-	log.Printf("DEBUG: User %d - Total saved contacts: %d, Total unsaved contacts: %d, Total groups found: %d",
-		userID, len(savedContacts), len(unsavedContacts), groupCount)
+	logging.FromContext(as.ctx).Debug(fmt.Sprintf("Total saved contacts: %d, Total unsaved contacts: %d, Total groups found: %d", len(savedContacts), len(unsavedContacts), groupCount), "user_id", userID)
+	groupFetchMs := timer.Lap()
 
 	// Use savedContacts for main analysis
 	contacts := savedContacts
@@ -104,19 +159,41 @@ func (as *AnalysisService) AnalyzeWhatsApp(userID uint, client *whatsmeow.Client
 	// Get account age
 	accountAgeDays := as.estimateAccountAge(client)
 
-	log.Printf("DEBUG: User %d - Calculated parameters:", userID)
-	log.Printf("  Total Chats: %d", totalChats)
-	log.Printf("  Total Contacts: %d", totalContacts)
-	log.Printf("  Account Age: %d days", accountAgeDays)
-	log.Printf("  Total Groups: %d", totalGroups)
-	log.Printf("  Chat with Contact: %d", totalChatWithContact)
-	log.Printf("  Sensitive Content: %d", sensitiveContentCount)
-	log.Printf("  Total Unsaved Chats: %d", totalUnsavedChats)
-	log.Printf("  Unknown Number Chats: %d", unknownNumberChats)
+	logging.FromContext(as.ctx).Debug("Calculated parameters", "user_id", userID)
+	logging.FromContext(as.ctx).Debug(fmt.Sprintf("Total Chats: %d", totalChats))
+	logging.FromContext(as.ctx).Debug(fmt.Sprintf("Total Contacts: %d", totalContacts))
+	logging.FromContext(as.ctx).Debug(fmt.Sprintf("Account Age: %d days", accountAgeDays))
+	logging.FromContext(as.ctx).Debug(fmt.Sprintf("Total Groups: %d", totalGroups))
+	logging.FromContext(as.ctx).Debug(fmt.Sprintf("Chat with Contact: %d", totalChatWithContact))
+	logging.FromContext(as.ctx).Debug(fmt.Sprintf("Sensitive Content: %d", sensitiveContentCount))
+	logging.FromContext(as.ctx).Debug(fmt.Sprintf("Total Unsaved Chats: %d", totalUnsavedChats))
+	logging.FromContext(as.ctx).Debug(fmt.Sprintf("Unknown Number Chats: %d", unknownNumberChats))
+
+	// Only the contact count is measured here, everything else is derived from contacts
+	confidences := models.ParameterConfidences{
+		models.ScoreTotalChats:           models.Estimated(models.ConfidenceEstimated),
+		models.ScoreAccountAgeDays:       models.Estimated(models.ConfidenceNoData),
+		models.ScoreTotalGroups:          models.Estimated(models.ConfidenceFallback),
+		models.ScoreTotalChatWithContact: models.Estimated(models.ConfidenceEstimated),
+		models.ScoreSensitiveContent:     models.Estimated(models.ConfidenceNoData),
+		models.ScoreTotalUnsavedChats:    models.Estimated(models.ConfidenceEstimated),
+		models.ScoreUnknownNumberChats:   models.Estimated(models.ConfidenceEstimated),
+	}
+
+	// Correct values that contradict each other before they are scored
+	inputs := models.AnalysisInputs{TotalChats: totalChats, TotalContacts: totalContacts, AccountAgeDays: accountAgeDays, TotalGroups: totalGroups,
+		TotalChatWithContact: totalChatWithContact, SensitiveContentCount: sensitiveContentCount, TotalUnsavedChats: totalUnsavedChats, UnknownNumberChats: unknownNumberChats}
+	anomalies := inputs.Sanitize()
+	totalChats, totalContacts, accountAgeDays, totalGroups, totalChatWithContact, sensitiveContentCount, totalUnsavedChats, unknownNumberChats = inputs.Values()
+	confidences.Downgrade(anomalies)
+	RecordInputAnomalies(userID, anomalies)
 
 	// Calculate strength dengan parameter baru sesuai tabel indikator
-	log.Printf("DEBUG: User %d - Calling CalculateStrength...", userID)
-	rating, summary := models.CalculateStrength(totalChats, totalContacts, accountAgeDays, totalGroups, totalChatWithContact, sensitiveContentCount, totalUnsavedChats, unknownNumberChats)
+	logging.FromContext(as.ctx).Debug("Calling CalculateStrength...", "user_id", userID)
+	scoring := ActiveScoringConfig()
+	scoringVersion := scoring.ID
+	rating, summary, parameters := models.ScoreStrength(scoring, confidences, totalChats, totalContacts, accountAgeDays, totalGroups, totalChatWithContact, sensitiveContentCount, totalUnsavedChats, unknownNumberChats)
+	scoringMs := timer.Lap()
 
 	result := models.AnalysisResult{
 		UserID:                userID,
@@ -130,28 +207,124 @@ func (as *AnalysisService) AnalyzeWhatsApp(userID uint, client *whatsmeow.Client
 		UnknownNumberChats:    unknownNumberChats,
 		Strength:              rating,
 		Summary:               summary,
+		Parameters:            parameters,
+		Confidence:            confidences.Overall(scoring),
+		ScoringVersion:        &scoringVersion,
+		ParameterConfidence:   confidences,
+		Anomalies:             anomalies,
 		ScanDate:              time.Now(),
+		ContactFetchMs:        contactFetchMs,
+		GroupFetchMs:          groupFetchMs,
+		ScoringMs:             scoringMs,
+		DurationMs:            timer.Total(),
+		GroupBreakdown:        groupBreakdown,
+		ContactBreakdown:      contactBreakdown,
 	}
 
-	log.Printf("DEBUG: User %d - Analysis result - Strength: %s", userID, rating)
+	logging.FromContext(as.ctx).Debug(fmt.Sprintf("Analysis result - Strength: %s", rating), "user_id", userID)
 
 	// Save analysis result to database
 	if err := as.saveAnalysisResult(&result); err != nil {
-		log.Printf("WARNING: User %d - Failed to save analysis result: %v", userID, err)
+		logging.FromContext(as.ctx).Warn("Failed to save analysis result", "user_id", userID, "error", err)
 	}
 
 	return &result, nil
 }
 
 // saveAnalysisResult saves analysis result to database
+// DurationMs is expected to hold the time spent before persisting; the persist stage is added here.
+// When the database is unavailable the result is spooled to disk and replayed later.
 func (as *AnalysisService) saveAnalysisResult(result *models.AnalysisResult) error {
+	persistStart := time.Now()
+	defer RecordAnalysisTimings(result)
+
+	if err := as.persistAnalysisResult(result, persistStart); err != nil {
+		if spoolErr := spoolAnalysisResult(result, err); spoolErr != nil {
+			logging.FromContext(as.ctx).Error("Failed to spool unsaved analysis result", "user_id", result.UserID, "error", spoolErr)
+			return err
+		}
+		return fmt.Errorf("%w: %v", ErrAnalysisSpooled, err)
+	}
+
+	as.afterAnalysisSaved(result)
+	return nil
+}
+
+// persistAnalysisResult writes the result and its breakdowns in one transaction.
+// A zero persistStart keeps the timings already on the result (spool replay).
+func (as *AnalysisService) persistAnalysisResult(result *models.AnalysisResult, persistStart time.Time) error {
 	// Check and reconnect database if needed
 	if err := database.CheckAndReconnect(); err != nil {
-		log.Printf("WARNING: Failed to check database connection: %v", err)
+		logging.FromContext(as.ctx).Warn("Failed to check database connection", "error", err)
 	}
 
-	db := database.GetDB()
-	return db.Create(result).Error
+	ctx := as.queryContext()
+
+	// Results created outside a request (background analysis) inherit the owner's tenant
+	if result.TenantID == nil {
+		if tenantID, err := as.userRepo().TenantID(context.Background(), result.UserID); err == nil {
+			result.TenantID = tenantID
+		}
+	}
+
+	// Result and its breakdown rows commit together; breakdowns go in batches so
+	// accounts with hundreds of groups/contacts don't issue one INSERT per row
+	batchSize := analysisInsertBatchSize()
+	if !persistStart.IsZero() {
+		// Result insert, breakdown batches and the timing update below
+		result.DBWrites += 2 + insertBatches(len(result.GroupBreakdown), batchSize) + insertBatches(len(result.ContactBreakdown), batchSize)
+	}
+	return as.analysisRepo().Transaction(ctx, func(tx repository.AnalysisRepo) error {
+		if err := tx.Create(ctx, result); err != nil {
+			return err
+		}
+		for i := range result.GroupBreakdown {
+			result.GroupBreakdown[i].AnalysisResultID = result.ID
+		}
+		for i := range result.ContactBreakdown {
+			result.ContactBreakdown[i].AnalysisResultID = result.ID
+		}
+		if err := tx.CreateBreakdowns(ctx, result.GroupBreakdown, result.ContactBreakdown, batchSize); err != nil {
+			return fmt.Errorf("failed to save analysis breakdown: %v", err)
+		}
+
+		if persistStart.IsZero() {
+			return nil
+		}
+		result.PersistMs = time.Since(persistStart).Milliseconds()
+		result.DurationMs += result.PersistMs
+		return tx.UpdateColumns(ctx, result, map[string]interface{}{
+			"persist_ms":  result.PersistMs,
+			"duration_ms": result.DurationMs,
+		})
+	})
+}
+
+// afterAnalysisSaved notifies the user (in-app, by email and through their webhook), checks their goal
+// and meters user and partner usage once a result is stored
+func (as *AnalysisService) afterAnalysisSaved(result *models.AnalysisResult) {
+	repos := as.repositories()
+	notifications := NewNotificationService(repos.Notifications, repos.Pushes).WithContext(as.ctx)
+	notifications.NotifyAsync(result.UserID, models.NotificationAnalysisCompleted,
+		"Analisis selesai",
+		fmt.Sprintf("Hasil analisis WhatsApp kamu sudah siap. Kekuatan akun: %s.", result.Strength),
+		map[string]interface{}{"analysis_id": result.ID, "auto_triggered": result.AutoTriggered})
+	NewWebhookService(repos.Webhooks).WithContext(as.ctx).NotifyAnalysisCompleted(result)
+	// Scheduled runs are emailed by the scheduler when the schedule asks for it
+	if !result.Scheduled {
+		SendAnalysisReportAsync(repos, result)
+	}
+	NewGoalService(repos.Goals, repos.Analyses).WithContext(as.ctx).checkAchieved(result, notifications)
+
+	// Meter the scan for the user's own usage page and, for partner tenants, for billing
+	if err := NewUsageService(repos.Usage, repos.Transactions).WithContext(as.ctx).RecordUser(result.UserID, models.UsageMetricAnalysis); err != nil {
+		logging.FromContext(as.ctx).Warn(fmt.Sprintf("Failed to meter analysis for user %d", result.UserID), "error", err, "user_id", result.UserID)
+	}
+	if result.TenantID != nil {
+		if err := NewUsageService(repos.Usage, repos.Transactions).WithContext(as.ctx).Record(*result.TenantID, models.UsageMetricAnalysis); err != nil {
+			logging.FromContext(as.ctx).Warn(fmt.Sprintf("Failed to meter analysis for tenant %d", *result.TenantID), "error", err)
+		}
+	}
 }
 
 // SaveAnalysisResult saves analysis result to database (public method)
@@ -159,186 +332,220 @@ func (as *AnalysisService) SaveAnalysisResult(result *models.AnalysisResult) err
 	return as.saveAnalysisResult(result)
 }
 
-// HistoryItem represents a history item with phone number
-type HistoryItem struct {
-	ID          uint      `json:"id"`
-	PhoneNumber string    `json:"phone_number"`
-	ScanDate    time.Time `json:"scan_date"`
-	Strength    string    `json:"strength"`
+// UpgradeAnalysisResult stores the recomputed values of a degraded result once a failed
+// data source was retried successfully. The checksum is resealed over the new payload.
+func (as *AnalysisService) UpgradeAnalysisResult(result *models.AnalysisResult) error {
+	quality, err := json.Marshal(result.DataQuality)
+	if err != nil {
+		return err
+	}
+	confidences, err := json.Marshal(result.ParameterConfidence)
+	if err != nil {
+		return err
+	}
+	anomalies, err := json.Marshal(result.Anomalies)
+	if err != nil {
+		return err
+	}
+	parameters, err := json.Marshal(result.Parameters)
+	if err != nil {
+		return err
+	}
+	result.Checksum = result.ComputeChecksum()
+	return as.analysisRepo().UpdateColumns(as.queryContext(), result, map[string]interface{}{
+		"total_groups":         result.TotalGroups,
+		"strength":             result.Strength,
+		"summary":              result.Summary,
+		"parameters":           string(parameters),
+		"data_quality":         string(quality),
+		"confidence":           result.Confidence,
+		"parameter_confidence": string(confidences),
+		"anomalies":            string(anomalies),
+		"scoring_version":      result.ScoringVersion,
+		"checksum":             result.Checksum,
+	})
 }
 
+// HistoryItem represents a history item with phone number
+type HistoryItem = repository.HistoryRow
+
 // GetAnalysisHistory returns analysis history for a user
 func (as *AnalysisService) GetAnalysisHistory(userID uint) ([]models.AnalysisResult, error) {
-	db := database.GetDB()
-
-	var results []models.AnalysisResult
-	err := db.Where("user_id = ?", userID).
-		Order("scan_date DESC").
-		Find(&results).Error
-
-	return results, err
+	return as.analysisRepo().ListByUser(as.queryContext(), userID)
 }
 
-// GetAnalysisHistoryWithPhone returns analysis history with phone numbers for a user
-func (as *AnalysisService) GetAnalysisHistoryWithPhone(userID uint) ([]HistoryItem, error) {
-	db := database.GetDB()
-	if db == nil {
-		return nil, fmt.Errorf("database connection is nil")
+// HistoryQuery pages, filters and sorts the analysis history
+type HistoryQuery = repository.HistoryQuery
+
+// GetAnalysisHistoryWithPhone returns one page of the user's analysis history with phone numbers
+// and the number of analyses matching the query
+func (as *AnalysisService) GetAnalysisHistoryWithPhone(userID uint, query HistoryQuery) ([]HistoryItem, int64, error) {
+	total, err := as.CountHistory(userID, query)
+	if err != nil {
+		return nil, 0, err
 	}
+	historyItems := []HistoryItem{}
+	err = as.EachHistoryItem(userID, query, func(item HistoryItem) error {
+		historyItems = append(historyItems, item)
+		return nil
+	})
+	return historyItems, total, err
+}
 
-	var historyItems []HistoryItem
-	err := db.Table("analysis_results ar").
-		Select("ar.id, COALESCE(sh.phone_number, '') as phone_number, ar.scan_date, ar.strength").
-		Joins("LEFT JOIN scan_history sh ON ar.scan_history_id = sh.id").
-		Where("ar.user_id = ?", userID).
-		Order("ar.scan_date DESC").
-		Scan(&historyItems).Error
+// CountHistory counts the user's analyses matching query, ignoring limit and offset
+func (as *AnalysisService) CountHistory(userID uint, query HistoryQuery) (int64, error) {
+	return as.analysisRepo().CountHistory(as.queryContext(), userID, query)
+}
 
-	return historyItems, err
+// EachHistoryItem streams the user's analysis history matching query (default order: pinned,
+// then newest first) to fn without loading it all
+func (as *AnalysisService) EachHistoryItem(userID uint, query HistoryQuery, fn func(HistoryItem) error) error {
+	history := as.scoringHistory()
+	return as.analysisRepo().EachHistory(as.queryContext(), userID, query, func(item HistoryItem) error {
+		if item.ScoringVersion == nil && history != nil {
+			version := ScoringVersionAt(history, item.ScanDate)
+			item.ScoringVersion = &version
+		}
+		return fn(item)
+	})
 }
 
 // GetLatestAnalysis returns the latest analysis for a user
 func (as *AnalysisService) GetLatestAnalysis(userID uint) (*models.AnalysisResult, error) {
-	db := database.GetDB()
-
-	var result models.AnalysisResult
-	err := db.Where("user_id = ?", userID).
-		Order("scan_date DESC").
-		First(&result).Error
+	return as.analysisRepo().Latest(as.queryContext(), userID)
+}
 
+// GetAnalysisDetail returns analysis details by ID for a specific user
+func (as *AnalysisService) GetAnalysisDetail(analysisID uint, userID uint) (*models.AnalysisResult, error) {
+	result, err := as.analysisRepo().FindDetailForUser(as.queryContext(), analysisID, userID)
 	if err != nil {
 		return nil, err
 	}
-
-	return &result, nil
+	if result.ScoringVersion == nil {
+		if history := as.scoringHistory(); history != nil {
+			version := ScoringVersionAt(history, result.ScanDate)
+			result.ScoringVersion = &version
+		}
+	}
+	return result, nil
 }
 
-// GetAnalysisDetail returns analysis details by ID for a specific user
-func (as *AnalysisService) GetAnalysisDetail(analysisID uint, userID uint) (*models.AnalysisResult, error) {
-	db := database.GetDB()
-	if db == nil {
-		return nil, fmt.Errorf("database connection is nil")
+// scoringHistory returns the scoring rulesets used to annotate results stored before the scoring
+// version was recorded (they get the version in effect at their scan date), nil when unavailable
+func (as *AnalysisService) scoringHistory() []ScoringRuleset {
+	history, err := NewScoringService(as.repositories().Scoring).WithContext(as.ctx).History()
+	if err != nil {
+		logging.FromContext(as.ctx).Warn("Failed to load scoring history", "error", err)
+		return nil
 	}
+	return history
+}
 
-	var result models.AnalysisResult
-	err := db.Preload("ScanHistory").
-		Where("id = ? AND user_id = ?", analysisID, userID).
-		First(&result).Error
-
+// VerifyByChecksum looks up an analysis by its published checksum and re-hashes the stored data.
+// Returns gorm.ErrRecordNotFound when no analysis carries the checksum.
+func (as *AnalysisService) VerifyByChecksum(checksum string) (*models.AnalysisResult, bool, error) {
+	// Checksums are published, so the lookup is not tenant scoped
+	result, err := as.analysisRepo().FindByChecksum(context.Background(), checksum)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
-
-	return &result, nil
+	return result, result.VerifyChecksum(), nil
 }
 
 // DeleteAnalysisByID deletes a single analysis result by ID for a specific user
 func (as *AnalysisService) DeleteAnalysisByID(userID uint, analysisID uint) (int64, error) {
-	db := database.GetDB()
-	if db == nil {
-		return 0, fmt.Errorf("database connection is nil")
-	}
+	ctx := as.queryContext()
+	analyses := as.analysisRepo()
 
 	// Load the analysis to get its scan_history_id
-	var analysisResult models.AnalysisResult
-	if err := db.Where("id = ? AND user_id = ?", analysisID, userID).First(&analysisResult).Error; err != nil {
+	analysisResult, err := analyses.FindForUser(ctx, analysisID, userID)
+	if err != nil {
 		return 0, err
 	}
+	if analysisResult.HoldActive(time.Now()) {
+		return 0, ErrLegalHold
+	}
 
 	// Hard delete the analysis result
-	res := db.Unscoped().Where("id = ? AND user_id = ?", analysisID, userID).Delete(&models.AnalysisResult{})
-	if res.Error != nil {
-		return res.RowsAffected, res.Error
+	deleted, err := analyses.HardDelete(ctx, userID, []uint{analysisID})
+	if err != nil {
+		return deleted, err
 	}
+	as.purgeBreakdowns([]uint{analysisID})
 
 	// If this analysis had a scan_history reference, remove the scan_history
 	// only if no other analysis_results still reference it
 	if analysisResult.ScanHistoryID != nil {
-		var remaining int64
-		db.Model(&models.AnalysisResult{}).Where("scan_history_id = ?", *analysisResult.ScanHistoryID).Count(&remaining)
-		if remaining == 0 {
-			// Hard delete scan_history
-			db.Unscoped().Where("id = ? AND user_id = ?", *analysisResult.ScanHistoryID, userID).Delete(&models.ScanHistory{})
-		}
+		as.purgeScanHistory(userID, []uint{*analysisResult.ScanHistoryID})
 	}
 
-	return res.RowsAffected, res.Error
+	return deleted, nil
 }
 
-// DeleteAnalysesByIDs deletes multiple analysis results by IDs for a specific user
-func (as *AnalysisService) DeleteAnalysesByIDs(userID uint, ids []uint) (int64, error) {
+// DeleteAnalysesByIDs removes multiple analysis results of a specific user for AnalysisUndoWindow
+// before they are deleted for good. Pinned results are kept unless includePinned.
+func (as *AnalysisService) DeleteAnalysesByIDs(userID uint, ids []uint, includePinned bool) (*AnalysisDeletion, error) {
 	if len(ids) == 0 {
-		return 0, nil
+		return &AnalysisDeletion{}, nil
 	}
+	return as.deleteAnalyses(userID, ids, includePinned)
+}
 
-	db := database.GetDB()
-	if db == nil {
-		return 0, fmt.Errorf("database connection is nil")
-	}
+// DeleteAllAnalyses removes all analysis results of a specific user, except pinned ones unless
+// includePinned, for AnalysisUndoWindow before they are deleted for good
+func (as *AnalysisService) DeleteAllAnalyses(userID uint, includePinned bool) (*AnalysisDeletion, error) {
+	return as.deleteAnalyses(userID, nil, includePinned)
+}
 
-	// Collect referenced scan_history_ids prior to deletion
-	var toDelete []models.AnalysisResult
-	if err := db.Select("scan_history_id").Where("user_id = ? AND id IN ?", userID, ids).Find(&toDelete).Error; err != nil {
-		return 0, err
+// deleteAnalyses marks the user's analyses in ids (nil = all) pending deletion under a new undo
+// token; PurgeDeletedAnalyses hard deletes them and their scan history and breakdown rows once
+// the undo window has passed
+func (as *AnalysisService) deleteAnalyses(userID uint, ids []uint, includePinned bool) (*AnalysisDeletion, error) {
+	ctx := as.queryContext()
+	analyses := as.analysisRepo()
+
+	refs, err := analyses.Refs(ctx, userID, ids)
+	if err != nil {
+		return nil, err
 	}
-	refCounts := map[uint]int{}
-	for _, ar := range toDelete {
-		if ar.ScanHistoryID != nil {
-			refCounts[*ar.ScanHistoryID]++
+	analysisIDs := make([]uint, 0, len(refs))
+	deletion := &AnalysisDeletion{}
+	for _, ar := range refs {
+		if ar.Pinned && !includePinned {
+			deletion.SkippedPinned++
+			continue
 		}
+		analysisIDs = append(analysisIDs, ar.ID)
 	}
-
-	// Hard delete analysis_results
-	res := db.Unscoped().Where("user_id = ? AND id IN ?", userID, ids).Delete(&models.AnalysisResult{})
-	if res.Error != nil {
-		return res.RowsAffected, res.Error
+	if len(analysisIDs) == 0 {
+		return deletion, nil
 	}
-
-	// For each scan_history_id referenced, delete scan_history if no analysis_results remain
-	for scanID := range refCounts {
-		var remaining int64
-		db.Model(&models.AnalysisResult{}).Where("scan_history_id = ?", scanID).Count(&remaining)
-		if remaining == 0 {
-			db.Unscoped().Where("id = ? AND user_id = ?", scanID, userID).Delete(&models.ScanHistory{})
-		}
+	if deletion.SkippedPinned > 0 {
+		// Delete exactly the unpinned rows collected above
+		ids = analysisIDs
 	}
 
-	return res.RowsAffected, nil
-}
-
-// DeleteAllAnalyses deletes all analysis results for a specific user
-func (as *AnalysisService) DeleteAllAnalyses(userID uint) (int64, error) {
-	db := database.GetDB()
-	if db == nil {
-		return 0, fmt.Errorf("database connection is nil")
+	token, err := newUndoToken()
+	if err != nil {
+		return nil, err
 	}
-
-	// Get distinct scan_history_ids for this user
-	var scanIDs []uint
-	if err := db.Model(&models.AnalysisResult{}).
-		Where("user_id = ? AND scan_history_id IS NOT NULL", userID).
-		Pluck("scan_history_id", &scanIDs).Error; err != nil {
-		return 0, err
+	deleted, err := analyses.MarkDeleted(ctx, userID, ids, token)
+	if err != nil {
+		return nil, err
 	}
-
-	// Hard delete all analysis_results for this user
-	res := db.Unscoped().Where("user_id = ?", userID).Delete(&models.AnalysisResult{})
-	if res.Error != nil {
-		return res.RowsAffected, res.Error
+	deletion.Deleted = deleted
+	if deleted > 0 {
+		deletion.UndoToken = token
+		deletion.UndoExpiresAt = time.Now().Add(AnalysisUndoWindow).Truncate(time.Second)
 	}
+	return deletion, nil
+}
 
-	if len(scanIDs) > 0 {
-		// After deleting, ensure no remaining references to these scan histories
-		for _, scanID := range scanIDs {
-			var remaining int64
-			db.Model(&models.AnalysisResult{}).Where("scan_history_id = ?", scanID).Count(&remaining)
-			if remaining == 0 {
-				db.Unscoped().Where("id = ? AND user_id = ?", scanID, userID).Delete(&models.ScanHistory{})
-			}
-		}
+// purgeScanHistory removes the user's scan history rows that no analysis references anymore
+func (as *AnalysisService) purgeScanHistory(userID uint, scanIDs []uint) {
+	if err := as.analysisRepo().PurgeScanHistory(as.queryContext(), userID, scanIDs); err != nil {
+		logging.FromContext(as.ctx).Warn(fmt.Sprintf("Failed to purge scan history of user %d", userID), "error", err, "user_id", userID)
 	}
-
-	return res.RowsAffected, nil
 }
 
 // estimateTotalChats estimates total chats based on saved contacts
@@ -348,7 +555,7 @@ func (as *AnalysisService) estimateTotalChats(contacts map[types.JID]types.Conta
 	// Estimate total chats as saved contacts + some additional chats
 	totalChats := savedContactsCount + int(float64(savedContactsCount)*0.3) // 30% additional chats
 
-	log.Printf("DEBUG: Estimated total chats: %d (from %d saved contacts)", totalChats, savedContactsCount)
+	logging.FromContext(as.ctx).Debug(fmt.Sprintf("Estimated total chats: %d (from %d saved contacts)", totalChats, savedContactsCount))
 	return totalChats
 }
 
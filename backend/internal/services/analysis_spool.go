@@ -0,0 +1,203 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"back_wa/internal/models"
+)
+
+// ErrAnalysisSpooled means the result could not be saved and was written to the local spool instead.
+// It will be persisted by the spool worker once the database is reachable again.
+var ErrAnalysisSpooled = errors.New("analysis result spooled for retry")
+
+// spooledAnalysis is the on-disk form of an unsaved result (breakdowns are not part of its JSON)
+type spooledAnalysis struct {
+	Result        models.AnalysisResult    `json:"result"`
+	Groups        []models.AnalysisGroup   `json:"groups,omitempty"`
+	Contacts      []models.AnalysisContact `json:"contacts,omitempty"`
+	AutoTriggered bool                     `json:"auto_triggered,omitempty"`
+	SpooledAt     time.Time                `json:"spooled_at"`
+	LastError     string                   `json:"last_error"`
+	Attempts      int                      `json:"attempts"`
+}
+
+// AnalysisSpoolStats are the spool counters reported with the analysis metrics
+type AnalysisSpoolStats struct {
+	Pending  int   `json:"pending"`  // files currently waiting in the spool
+	Spooled  int64 `json:"spooled"`  // results spooled since process start
+	Replayed int64 `json:"replayed"` // spooled results persisted since process start
+	Failed   int64 `json:"failed"`   // replay attempts that failed
+}
+
+var (
+	spoolMu       sync.Mutex // serializes spool writes and replays
+	spoolSpooled  atomic.Int64
+	spoolReplayed atomic.Int64
+	spoolFailed   atomic.Int64
+)
+
+// analysisSpoolDir returns ANALYSIS_SPOOL_DIR (default spool/analysis)
+func analysisSpoolDir() string {
+	return getenv("ANALYSIS_SPOOL_DIR", filepath.Join("spool", "analysis"))
+}
+
+// spoolAnalysisResult durably writes an unsaved result to the spool directory
+func spoolAnalysisResult(result *models.AnalysisResult, cause error) error {
+	entry := spooledAnalysis{
+		Result:        *result,
+		Groups:        result.GroupBreakdown,
+		Contacts:      result.ContactBreakdown,
+		AutoTriggered: result.AutoTriggered,
+		SpooledAt:     time.Now(),
+		LastError:     cause.Error(),
+	}
+	// A rolled back transaction may have assigned IDs
+	entry.Result.ID = 0
+	for i := range entry.Groups {
+		entry.Groups[i].ID, entry.Groups[i].AnalysisResultID = 0, 0
+	}
+	for i := range entry.Contacts {
+		entry.Contacts[i].ID, entry.Contacts[i].AnalysisResultID = 0, 0
+	}
+
+	spoolMu.Lock()
+	defer spoolMu.Unlock()
+
+	dir := analysisSpoolDir()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d-user%d.json", entry.SpooledAt.UnixNano(), result.UserID)
+	if err := writeSpoolFile(filepath.Join(dir, name), &entry); err != nil {
+		return err
+	}
+
+	spoolSpooled.Add(1)
+	slog.Warn(fmt.Sprintf("Analysis result spooled to %s (%v)", name, cause), "user_id", result.UserID)
+	return nil
+}
+
+// writeSpoolFile writes via a synced temp file + rename so a crash never leaves half a result
+func writeSpoolFile(path string, entry *spooledAnalysis) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".spool-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// spoolFiles lists spooled results oldest first
+func spoolFiles() []string {
+	entries, err := os.ReadDir(analysisSpoolDir())
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			files = append(files, filepath.Join(analysisSpoolDir(), e.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// ReplayAnalysisSpool persists spooled results through as; it stops at the first failure since
+// the database is most likely still unavailable. Returns how many results were saved.
+func ReplayAnalysisSpool(as *AnalysisService) int {
+	spoolMu.Lock()
+	defer spoolMu.Unlock()
+
+	saved := 0
+	for _, path := range spoolFiles() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("Failed to read spooled analysis %s", path), "error", err)
+			continue
+		}
+		var entry spooledAnalysis
+		if err := json.Unmarshal(data, &entry); err != nil {
+			// Keep the file for manual inspection, but move it out of the replay path
+			slog.Error(fmt.Sprintf("Corrupt spooled analysis %s", path), "error", err)
+			_ = os.Rename(path, path+".corrupt")
+			continue
+		}
+
+		result := entry.Result
+		result.GroupBreakdown = entry.Groups
+		result.ContactBreakdown = entry.Contacts
+		result.AutoTriggered = entry.AutoTriggered
+		if err := as.persistAnalysisResult(&result, time.Time{}); err != nil {
+			spoolFailed.Add(1)
+			entry.Attempts++
+			entry.LastError = err.Error()
+			_ = writeSpoolFile(path, &entry)
+			slog.Warn(fmt.Sprintf("Spooled analysis replay failed (%d pending)", len(spoolFiles())), "error", err)
+			return saved
+		}
+
+		if err := os.Remove(path); err != nil {
+			slog.Warn(fmt.Sprintf("Failed to remove replayed spool file %s", path), "error", err)
+		}
+		spoolReplayed.Add(1)
+		saved++
+		slog.Info(fmt.Sprintf("Spooled analysis persisted as #%d (spooled %s ago)", result.ID, time.Since(entry.SpooledAt).Round(time.Second)), "user_id", result.UserID)
+		as.afterAnalysisSaved(&result)
+	}
+	return saved
+}
+
+// StartAnalysisSpoolWorker replays spooled results every ANALYSIS_SPOOL_RETRY_SECONDS (default 30)
+func StartAnalysisSpoolWorker(as *AnalysisService) {
+	interval := time.Duration(getIntEnv("ANALYSIS_SPOOL_RETRY_SECONDS", 30)) * time.Second
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	go func() {
+		ReplayAnalysisSpool(as)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if len(spoolFiles()) > 0 {
+				ReplayAnalysisSpool(as)
+			}
+		}
+	}()
+}
+
+// AnalysisSpool returns the spool counters
+func AnalysisSpool() AnalysisSpoolStats {
+	return AnalysisSpoolStats{
+		Pending:  len(spoolFiles()),
+		Spooled:  spoolSpooled.Load(),
+		Replayed: spoolReplayed.Load(),
+		Failed:   spoolFailed.Load(),
+	}
+}
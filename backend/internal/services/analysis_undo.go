@@ -0,0 +1,106 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// AnalysisUndoWindow is how long analyses removed by a bulk delete can be restored
+const AnalysisUndoWindow = 30 * time.Second
+
+// analysisPurgeInterval is how often analyses past their undo window are deleted for good
+const analysisPurgeInterval = 10 * time.Second
+
+// ErrUndoExpired is returned for an unknown undo token or one whose window has passed
+var ErrUndoExpired = errors.New("undo token is invalid or expired")
+
+// AnalysisDeletion is the outcome of a bulk delete
+type AnalysisDeletion struct {
+	Deleted       int64
+	SkippedPinned int
+	UndoToken     string // empty when nothing was deleted
+	UndoExpiresAt time.Time
+}
+
+func newUndoToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// UndoDelete restores the analyses a bulk delete removed less than AnalysisUndoWindow ago
+func (as *AnalysisService) UndoDelete(userID uint, token string) (int64, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return 0, ErrUndoExpired
+	}
+
+	restored, err := as.analysisRepo().RestoreDeleted(as.queryContext(), userID, token, time.Now().Add(-AnalysisUndoWindow))
+	if err != nil {
+		return 0, err
+	}
+	if restored == 0 {
+		return 0, ErrUndoExpired
+	}
+	return restored, nil
+}
+
+// PurgeDeletedAnalyses hard deletes analyses whose undo window has passed, with the scan history
+// and breakdown rows nothing references anymore
+func (as *AnalysisService) PurgeDeletedAnalyses() (int64, error) {
+	ctx := as.queryContext()
+	analyses := as.analysisRepo()
+
+	refs, err := analyses.DeletedBefore(ctx, time.Now().Add(-AnalysisUndoWindow))
+	if err != nil {
+		return 0, err
+	}
+
+	// Group by user, keeping each scan history id once
+	analysisIDs := map[uint][]uint{}
+	scanIDs := map[uint][]uint{}
+	seen := map[uint]bool{}
+	for _, ar := range refs {
+		analysisIDs[ar.UserID] = append(analysisIDs[ar.UserID], ar.ID)
+		if ar.ScanHistoryID != nil && !seen[*ar.ScanHistoryID] {
+			seen[*ar.ScanHistoryID] = true
+			scanIDs[ar.UserID] = append(scanIDs[ar.UserID], *ar.ScanHistoryID)
+		}
+	}
+
+	var purged int64
+	for userID, ids := range analysisIDs {
+		deleted, err := analyses.HardDelete(ctx, userID, ids)
+		if err != nil {
+			return purged, fmt.Errorf("failed to purge analyses of user %d: %v", userID, err)
+		}
+		purged += deleted
+		as.purgeBreakdowns(ids)
+		as.purgeScanHistory(userID, scanIDs[userID])
+	}
+	return purged, nil
+}
+
+// StartAnalysisPurgeWorker deletes analyses for good once their undo window has passed
+func StartAnalysisPurgeWorker(as *AnalysisService) {
+	go func() {
+		ticker := time.NewTicker(analysisPurgeInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			purged, err := as.PurgeDeletedAnalyses()
+			if err != nil {
+				slog.Warn("Failed to purge deleted analyses", "error", err)
+			}
+			if purged > 0 {
+				slog.Info(fmt.Sprintf("Purged %d deleted analyses past their undo window", purged))
+			}
+		}
+	}()
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// ErrAnnouncementNotFound is returned for unknown announcement IDs
+var ErrAnnouncementNotFound = errors.New("announcement not found")
+
+// AnnouncementService manages the banners operators publish to users
+type AnnouncementService struct {
+	ctx           context.Context
+	announcements repository.AnnouncementRepo
+}
+
+// NewAnnouncementService creates an announcement service on the given repository.
+// The zero value AnnouncementService{} uses the default repository.
+func NewAnnouncementService(announcements repository.AnnouncementRepo) *AnnouncementService {
+	return &AnnouncementService{announcements: announcements}
+}
+
+// WithContext returns a copy of the service bound to the request context
+func (as *AnnouncementService) WithContext(ctx context.Context) *AnnouncementService {
+	return &AnnouncementService{ctx: ctx, announcements: as.announcements}
+}
+
+// announcementRepo returns the injected announcement repository or the default one
+func (as *AnnouncementService) announcementRepo() repository.AnnouncementRepo {
+	if as.announcements != nil {
+		return as.announcements
+	}
+	return repository.Default().Announcements
+}
+
+// queryContext returns the service context (background when unset)
+func (as *AnnouncementService) queryContext() context.Context {
+	if as.ctx != nil {
+		return as.ctx
+	}
+	return context.Background()
+}
+
+// Current returns the active announcements scheduled for now, for the given audiences
+// (AnnouncementAudienceAll plus the user's tier), most recently created first
+func (as *AnnouncementService) Current(audiences []string) ([]models.Announcement, error) {
+	return as.announcementRepo().ListCurrent(as.queryContext(), audiences, time.Now())
+}
+
+// List returns all announcements, including inactive and expired ones, for the admin panel
+func (as *AnnouncementService) List() ([]models.Announcement, error) {
+	return as.announcementRepo().List(as.queryContext())
+}
+
+// Create validates and stores a new announcement
+func (as *AnnouncementService) Create(announcement *models.Announcement, adminID uint) error {
+	if err := announcement.Validate(); err != nil {
+		return err
+	}
+
+	announcement.ID = 0
+	announcement.CreatedBy = &adminID
+	return as.announcementRepo().Create(as.queryContext(), announcement)
+}
+
+// Update replaces the editable fields of an announcement
+func (as *AnnouncementService) Update(id uint, changes *models.Announcement) (*models.Announcement, error) {
+	if err := changes.Validate(); err != nil {
+		return nil, err
+	}
+
+	announcement, err := as.announcementRepo().FindByID(as.queryContext(), id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAnnouncementNotFound
+		}
+		return nil, err
+	}
+	announcement.Title = changes.Title
+	announcement.Message = changes.Message
+	announcement.Kind = changes.Kind
+	announcement.Audience = changes.Audience
+	announcement.Link = changes.Link
+	announcement.StartsAt = changes.StartsAt
+	announcement.EndsAt = changes.EndsAt
+	announcement.Active = changes.Active
+	if err := as.announcementRepo().Update(as.queryContext(), announcement); err != nil {
+		return nil, err
+	}
+	return announcement, nil
+}
+
+// Delete removes an announcement
+func (as *AnnouncementService) Delete(id uint) error {
+	deleted, err := as.announcementRepo().Delete(as.queryContext(), id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrAnnouncementNotFound
+	}
+	return nil
+}
@@ -1,39 +1,136 @@
 package services
 
 import (
+	"context"
 	"errors"
-	"os"
 	"time"
 
-	"back_wa/internal/database"
+	"back_wa/internal/logging"
 	"back_wa/internal/models"
+	"back_wa/internal/repository"
 
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
-type AuthService struct{}
+type AuthService struct {
+	ctx      context.Context
+	users    repository.UserRepo
+	sessions repository.AuthSessionRepo
+	// Limits the number of sessions per user, see enforceSessionLimit
+	entitlements *EntitlementService
+	// Counts failed logins, see Login
+	lockout *LoginLockoutService
+}
+
+// NewAuthService creates an auth service on the given user and token session repositories.
+// The zero value AuthService{} uses the default repositories.
+func NewAuthService(users repository.UserRepo, sessions repository.AuthSessionRepo) *AuthService {
+	return &AuthService{users: users, sessions: sessions}
+}
+
+// WithContext returns an AuthService whose user lookups honour the tenant scope in ctx
+func (as *AuthService) WithContext(ctx context.Context) *AuthService {
+	clone := *as
+	clone.ctx = ctx
+	return &clone
+}
+
+// WithEntitlements returns a copy of the service that reads the session limit of a user's tier from entitlements
+func (as *AuthService) WithEntitlements(entitlements *EntitlementService) *AuthService {
+	clone := *as
+	clone.entitlements = entitlements
+	return &clone
+}
+
+// WithLockout returns a copy of the service that counts failed logins with lockout
+func (as *AuthService) WithLockout(lockout *LoginLockoutService) *AuthService {
+	clone := *as
+	clone.lockout = lockout
+	return &clone
+}
+
+// userRepo returns the injected user repository or the default one
+func (as *AuthService) userRepo() repository.UserRepo {
+	if as.users != nil {
+		return as.users
+	}
+	return repository.Default().Users
+}
+
+// authSessionRepo returns the injected token session repository or the default one
+func (as *AuthService) authSessionRepo() repository.AuthSessionRepo {
+	if as.sessions != nil {
+		return as.sessions
+	}
+	return repository.Default().AuthSessions
+}
+
+// entitlementService returns the injected entitlement service or one on the default repositories
+func (as *AuthService) entitlementService() *EntitlementService {
+	if as.entitlements != nil {
+		return as.entitlements
+	}
+	return NewEntitlementService(repository.Default())
+}
+
+// lockoutService returns the injected lockout service or one on the default repositories
+func (as *AuthService) lockoutService() *LoginLockoutService {
+	if as.lockout != nil {
+		return as.lockout
+	}
+	return NewLoginLockoutService(repository.Default().Users, repository.Default().Tenants)
+}
 
+// queryContext returns the service context (background when unset)
+func (as *AuthService) queryContext() context.Context {
+	if as.ctx != nil {
+		return as.ctx
+	}
+	return context.Background()
+}
+
+// JWTClaims is kept small: profile data (username, email) is fetched from /api/auth/profile
 type JWTClaims struct {
-	UserID   uint   `json:"user_id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Role     string `json:"role"`
+	UserID uint   `json:"user_id"`
+	Role   string `json:"role"`
+	// Scopes are the route groups the token may call (wa, payments, admin); see TokenScopes
+	Scopes []string `json:"scopes,omitempty"`
+	// Scope is empty for full account tokens; purpose-scoped tokens only work on endpoints accepting that scope
+	Scope string `json:"scope,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// ScopeWAQR limits a token to the QR scan flow (QR, status, state, QR refresh)
+const ScopeWAQR = "wa:qr"
+
+// Scoped token lifetimes
+const (
+	DefaultScopedTokenTTL = 10 * time.Minute
+	MaxScopedTokenTTL     = 30 * time.Minute
+)
+
+var (
+	// ErrScopedToken is returned when a purpose-scoped token is used as a full account token
+	ErrScopedToken = errors.New("scoped token not allowed here")
+	// ErrUnknownScope is returned when minting a token for a scope that does not exist
+	ErrUnknownScope = errors.New("unknown token scope")
+)
+
 // Register creates a new user account
 func (as *AuthService) Register(req models.UserRegister) (*models.UserResponse, error) {
-	db := database.GetDB()
+	users := as.userRepo()
+
+	// Email and username are unique across all tenants, so check them unscoped
+	unscoped := context.Background()
 
 	// Check if email already exists
-	var existingUser models.User
-	if err := db.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
+	if _, err := users.FindByEmail(unscoped, req.Email); err == nil {
 		return nil, errors.New("email already registered")
 	}
 
 	// Check if username already exists
-	if err := db.Where("username = ?", req.Username).First(&existingUser).Error; err == nil {
+	if _, err := users.FindByUsername(unscoped, req.Username); err == nil {
 		return nil, errors.New("username already taken")
 	}
 
@@ -54,7 +151,7 @@ func (as *AuthService) Register(req models.UserRegister) (*models.UserResponse,
 		EmailVerified: true, // Set email as verified since OTP was already verified
 	}
 
-	if err := db.Create(&user).Error; err != nil {
+	if err := users.Create(as.queryContext(), &user); err != nil {
 		return nil, err
 	}
 
@@ -69,16 +166,33 @@ func (as *AuthService) Register(req models.UserRegister) (*models.UserResponse,
 	}, nil
 }
 
-// Login authenticates user and returns JWT token
-func (as *AuthService) Login(req models.UserLogin) (string, *models.UserResponse, error) {
-	db := database.GetDB()
+// Login authenticates user and returns JWT token. client.IP counts failures per address and,
+// with the user agent, is recorded on the new session; a *LoginBlockedError is returned while
+// the address or account has to wait.
+func (as *AuthService) Login(req models.UserLogin, client LoginClient) (string, *models.UserResponse, error) {
+	// Scopes are checked before the password so a bad request does not count as a login attempt
+	if err := validateRequestedScopes(req.Scopes); err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	lockout := as.lockoutService().WithContext(as.ctx)
+	if err := lockout.CheckIP(client.IP, now); err != nil {
+		return "", nil, err
+	}
 
 	// Find user by email
-	var user models.User
-	if err := db.Where("email = ?", req.Email).First(&user).Error; err != nil {
+	user, err := as.userRepo().FindByEmail(as.queryContext(), req.Email)
+	if err != nil {
+		lockout.RecordIPFailure(client.IP, now)
 		return "", nil, errors.New("invalid email or password")
 	}
 
+	// Backoff and lockout apply before the password is checked, so guessing stops entirely
+	if err := lockout.Check(user, now); err != nil {
+		return "", nil, err
+	}
+
 	// Check if user is active
 	if !user.IsActive {
 		return "", nil, errors.New("account is deactivated")
@@ -91,15 +205,31 @@ func (as *AuthService) Login(req models.UserLogin) (string, *models.UserResponse
 
 	// Verify password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		lockout.RecordIPFailure(client.IP, now)
+		if err := lockout.RecordFailure(user, now); err != nil {
+			var blocked *LoginBlockedError
+			if errors.As(err, &blocked) {
+				return "", nil, err
+			}
+			logging.FromContext(as.ctx).Warn("Failed to record failed login", "user_id", user.ID, "error", err)
+		}
 		return "", nil, errors.New("invalid email or password")
 	}
 
-	// Generate JWT token
-	token, err := as.generateJWT(user)
+	// Generate JWT token for the requested login context
+	token, err := as.generateJWT(*user, grantScopes(user.Role, req.Scopes), client)
 	if err != nil {
 		return "", nil, err
 	}
 
+	// Activity resets the inactive-account retention clock
+	if err := as.userRepo().RecordLogin(as.queryContext(), user.ID, time.Now()); err != nil {
+		logging.FromContext(as.ctx).Warn("Failed to record login", "user_id", user.ID, "error", err)
+	}
+	if err := lockout.RecordSuccess(user); err != nil {
+		logging.FromContext(as.ctx).Warn("Failed to reset failed logins", "user_id", user.ID, "error", err)
+	}
+
 	// Return token and user response
 	userResponse := &models.UserResponse{
 		ID:          user.ID,
@@ -119,51 +249,134 @@ func (as *AuthService) UpdatePassword(user *models.User, newPassword string) err
 	if err != nil {
 		return err
 	}
-	db := database.GetDB()
 	user.PasswordHash = string(hashedPassword)
-	return db.Save(user).Error
+	return as.userRepo().Save(as.queryContext(), user)
 }
 
-// generateJWT creates a JWT token for the user
-func (as *AuthService) generateJWT(user models.User) (string, error) {
-	// JWT secret key from environment variable
-	secretKey := os.Getenv("JWT_SECRET")
-	if secretKey == "" {
-		secretKey = "wa-analyzer-super-secret-jwt-key-2024-change-in-production" // fallback
+// generateJWT creates a JWT token for the user carrying the granted scopes and records it as a session
+func (as *AuthService) generateJWT(user models.User, scopes []string, client LoginClient) (string, error) {
+	keys, err := JWTKeys()
+	if err != nil {
+		return "", err
 	}
 
+	expiresAt := time.Now().Add(24 * time.Hour) // 24 hours
 	claims := JWTClaims{
-		UserID:   user.ID,
-		Username: user.Username,
-		Email:    user.Email,
-		Role:     user.Role,
+		UserID: user.ID,
+		Role:   user.Role,
+		Scopes: scopes,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // 24 hours
+			ID:        newTokenID(),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secretKey))
+	signed, err := keys.Sign(claims)
+	if err != nil {
+		return "", err
+	}
+	as.recordSession(&models.AuthSession{
+		JTI:       claims.ID,
+		UserID:    user.ID,
+		UserAgent: client.UserAgent,
+		IPAddress: client.IP,
+		ExpiresAt: expiresAt,
+	})
+	as.enforceSessionLimit(user.ID)
+	return signed, nil
 }
 
-// ValidateToken validates JWT token and returns user claims
-func (as *AuthService) ValidateToken(tokenString string) (*JWTClaims, error) {
-	secretKey := os.Getenv("JWT_SECRET")
-	if secretKey == "" {
-		secretKey = "wa-analyzer-super-secret-jwt-key-2024-change-in-production" // fallback
+// GenerateScopedToken mints a short-lived token that carries only the given scope,
+// so embedded widgets and webviews never receive the account-wide JWT.
+// The token is recorded under the session of claims and is revoked with it.
+func (as *AuthService) GenerateScopedToken(claims *JWTClaims, scope string, ttl time.Duration) (string, time.Time, error) {
+	if scope != ScopeWAQR {
+		return "", time.Time{}, ErrUnknownScope
+	}
+	if ttl <= 0 {
+		ttl = DefaultScopedTokenTTL
+	}
+	if ttl > MaxScopedTokenTTL {
+		ttl = MaxScopedTokenTTL
+	}
+
+	keys, err := JWTKeys()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(ttl)
+	scoped := JWTClaims{
+		UserID: claims.UserID,
+		Role:   claims.Role,
+		Scope:  scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        newTokenID(),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+		},
 	}
 
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secretKey), nil
+	signed, err := keys.Sign(scoped)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	as.recordSession(&models.AuthSession{
+		JTI:       scoped.ID,
+		ParentJTI: claims.ID,
+		UserID:    claims.UserID,
+		Purpose:   scope,
+		ExpiresAt: expiresAt,
 	})
+	return signed, expiresAt, nil
+}
+
+// ValidateToken validates a full account JWT and returns user claims.
+// Scoped tokens are rejected here; use ValidateTokenForScope on endpoints that accept them.
+func (as *AuthService) ValidateToken(tokenString string) (*JWTClaims, error) {
+	claims, err := as.parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Scope != "" {
+		return nil, ErrScopedToken
+	}
+	return claims, nil
+}
+
+// ValidateTokenForScope accepts either a full account JWT or a token scoped to scope
+func (as *AuthService) ValidateTokenForScope(tokenString, scope string) (*JWTClaims, error) {
+	claims, err := as.parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Scope != "" && claims.Scope != scope {
+		return nil, ErrScopedToken
+	}
+	return claims, nil
+}
+
+// parseToken verifies the signature (key picked by kid), expiry and session of a JWT;
+// tokens of a revoked session fail with ErrSessionRevoked
+func (as *AuthService) parseToken(tokenString string) (*JWTClaims, error) {
+	keys, err := JWTKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, keys.Keyfunc)
 
 	if err != nil {
 		return nil, err
 	}
 
 	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
+		if err := as.checkSession(claims.ID); err != nil {
+			return nil, err
+		}
 		return claims, nil
 	}
 
@@ -172,10 +385,8 @@ func (as *AuthService) ValidateToken(tokenString string) (*JWTClaims, error) {
 
 // GetUserByID retrieves user by ID
 func (as *AuthService) GetUserByID(userID uint) (*models.UserResponse, error) {
-	db := database.GetDB()
-
-	var user models.User
-	if err := db.First(&user, userID).Error; err != nil {
+	user, err := as.userRepo().FindByID(as.queryContext(), userID)
+	if err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"os"
+	"time"
+)
+
+// SessionInfo describes the token a client is currently using (GET /api/auth/session)
+type SessionInfo struct {
+	UserID             uint      `json:"user_id"`
+	Role               string    `json:"role"`
+	Scopes             []string  `json:"scopes"`
+	Purpose            string    `json:"purpose,omitempty"` // set for purpose-scoped tokens such as wa:qr
+	IssuedAt           time.Time `json:"issued_at"`
+	ExpiresAt          time.Time `json:"expires_at"`
+	ExpiresInSeconds   int64     `json:"expires_in_seconds"`
+	RefreshRecommended bool      `json:"refresh_recommended"`
+}
+
+// authRefreshWindow is how long before expiry clients are told to refresh (AUTH_REFRESH_WINDOW, default 2h)
+func authRefreshWindow() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("AUTH_REFRESH_WINDOW")); err == nil && d > 0 {
+		return d
+	}
+	return 2 * time.Hour
+}
+
+// Session reports expiry and scopes of a full or wa:qr token. A refresh is recommended once the
+// remaining lifetime drops below the refresh window, capped at half the token lifetime so
+// short-lived tokens are not flagged right after issue.
+func (as *AuthService) Session(tokenString string) (*SessionInfo, error) {
+	claims, err := as.ValidateTokenForScope(tokenString, ScopeWAQR)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &SessionInfo{
+		UserID:  claims.UserID,
+		Role:    claims.Role,
+		Scopes:  claims.TokenScopes(),
+		Purpose: claims.Scope,
+	}
+	if claims.IssuedAt != nil {
+		info.IssuedAt = claims.IssuedAt.Time
+	}
+	if claims.ExpiresAt == nil {
+		return info, nil
+	}
+
+	info.ExpiresAt = claims.ExpiresAt.Time
+	remaining := time.Until(info.ExpiresAt)
+	info.ExpiresInSeconds = int64(remaining / time.Second)
+
+	window := authRefreshWindow()
+	if !info.IssuedAt.IsZero() {
+		if half := info.ExpiresAt.Sub(info.IssuedAt) / 2; half < window {
+			window = half
+		}
+	}
+	info.RefreshRecommended = remaining <= window
+	return info, nil
+}
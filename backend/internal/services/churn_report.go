@@ -0,0 +1,215 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"back_wa/internal/models"
+)
+
+// Churn risk reasons of a ChurnRiskUser
+const (
+	ChurnNeverScannedAfterPayment = "never_scanned_after_payment"
+	ChurnSingleScanAfterPayment   = "single_scan_after_payment"
+	ChurnLowFeedback              = "low_feedback"
+)
+
+// ChurnReportOptions selects the window of a churn report
+type ChurnReportOptions struct {
+	Days     int // window for NPS and scan frequency (default 90)
+	IdleDays int // a single payment must be at least this old to count as at risk (default 14)
+	Limit    int // at-risk users returned, oldest payment first (default 100)
+}
+
+// NPSSummary maps 1-5 feedback ratings onto NPS: 5 promoter, 4 passive, 1-3 detractor
+type NPSSummary struct {
+	Responses  int64   `json:"responses"`
+	Promoters  int64   `json:"promoters"`
+	Passives   int64   `json:"passives"`
+	Detractors int64   `json:"detractors"`
+	Score      float64 `json:"score"` // % promoters - % detractors, -100..100
+}
+
+// ScanFrequency summarizes analyses in the report window
+type ScanFrequency struct {
+	Scans           int64   `json:"scans"`
+	ScanningUsers   int64   `json:"scanning_users"`
+	ScansPerUser    float64 `json:"scans_per_user"`
+	RepeatScanUsers int64   `json:"repeat_scan_users"` // users with at least two scans in the window
+}
+
+// RenewalSummary counts paying users and how many of them paid again
+type RenewalSummary struct {
+	PayingUsers   int64   `json:"paying_users"`
+	RepeatPayers  int64   `json:"repeat_payers"`
+	PaidOnceUsers int64   `json:"paid_once_users"`
+	RenewalRate   float64 `json:"renewal_rate"` // % of paying users with two or more payments
+}
+
+// ChurnRiskUser is a user who paid once and did not come back to scan
+type ChurnRiskUser struct {
+	UserID            uint       `json:"user_id"`
+	Username          string     `json:"username"`
+	Email             string     `json:"email"`
+	PhoneNumber       string     `json:"phone_number"`
+	PaidAt            time.Time  `json:"paid_at"`
+	Amount            float64    `json:"amount"`
+	DaysSincePayment  int        `json:"days_since_payment"`
+	ScansSincePayment int64      `json:"scans_since_payment"`
+	LastScanAt        *time.Time `json:"last_scan_at"`
+	LowestRating      *int       `json:"lowest_rating"`
+	Reasons           []string   `json:"reasons"`
+}
+
+// ChurnReport is the admin retention overview behind GET /api/admin/reports/churn
+type ChurnReport struct {
+	GeneratedAt time.Time       `json:"generated_at"`
+	Since       time.Time       `json:"since"`
+	IdleDays    int             `json:"idle_days"`
+	NPS         NPSSummary      `json:"nps"`
+	Scans       ScanFrequency   `json:"scans"`
+	Renewals    RenewalSummary  `json:"renewals"`
+	AtRiskTotal int64           `json:"at_risk_total"`
+	AtRisk      []ChurnRiskUser `json:"at_risk"`
+}
+
+// ChurnReport aggregates feedback, scan frequency and payment renewals, and lists users who
+// paid once but scanned at most once since, as targets for retention campaigns
+func (as *AdminService) ChurnReport(opts ChurnReportOptions) (*ChurnReport, error) {
+	if opts.Days <= 0 {
+		opts.Days = 90
+	}
+	if opts.IdleDays <= 0 {
+		opts.IdleDays = 14
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = 100
+	}
+
+	ctx, reports := as.queryContext(), as.repositories().Reports
+	now := time.Now()
+	report := &ChurnReport{
+		GeneratedAt: now,
+		Since:       now.AddDate(0, 0, -opts.Days),
+		IdleDays:    opts.IdleDays,
+		AtRisk:      []ChurnRiskUser{},
+	}
+
+	// NPS from analysis feedback in the window
+	ratings, err := reports.RatingCounts(ctx, report.Since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate feedback: %v", err)
+	}
+	for _, row := range ratings {
+		report.NPS.Responses += row.Count
+		switch {
+		case row.Rating >= 5:
+			report.NPS.Promoters += row.Count
+		case row.Rating == 4:
+			report.NPS.Passives += row.Count
+		default:
+			report.NPS.Detractors += row.Count
+		}
+	}
+	if report.NPS.Responses > 0 {
+		report.NPS.Score = percent(report.NPS.Promoters-report.NPS.Detractors, report.NPS.Responses)
+	}
+
+	// Scan frequency in the window
+	scansPerUser, err := reports.ScanCounts(ctx, report.Since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate scans: %v", err)
+	}
+	for _, row := range scansPerUser {
+		report.Scans.Scans += row.Scans
+		report.Scans.ScanningUsers++
+		if row.Scans >= 2 {
+			report.Scans.RepeatScanUsers++
+		}
+	}
+	if report.Scans.ScanningUsers > 0 {
+		report.Scans.ScansPerUser = math.Round(float64(report.Scans.Scans)/float64(report.Scans.ScanningUsers)*100) / 100
+	}
+
+	// Renewals over all paid transactions
+	renewals, err := reports.Renewals(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate renewals: %v", err)
+	}
+	report.Renewals.PayingUsers, report.Renewals.RepeatPayers = renewals.PayingUsers, renewals.RepeatPayers
+	report.Renewals.PaidOnceUsers = report.Renewals.PayingUsers - report.Renewals.RepeatPayers
+	if report.Renewals.PayingUsers > 0 {
+		report.Renewals.RenewalRate = percent(report.Renewals.RepeatPayers, report.Renewals.PayingUsers)
+	}
+
+	// Paid exactly once, long enough ago, and at most one scan since
+	paidBefore := now.AddDate(0, 0, -opts.IdleDays)
+	if report.AtRiskTotal, err = reports.CountChurnCandidates(ctx, paidBefore); err != nil {
+		return nil, fmt.Errorf("failed to count at-risk users: %v", err)
+	}
+	candidates, err := reports.ListChurnCandidates(ctx, paidBefore, opts.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list at-risk users: %v", err)
+	}
+	if len(candidates) == 0 {
+		return report, nil
+	}
+
+	userIDs := make([]uint, 0, len(candidates))
+	for _, c := range candidates {
+		userIDs = append(userIDs, c.UserID)
+	}
+
+	users, err := as.repositories().Users.ListByIDs(ctx, userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load at-risk users: %v", err)
+	}
+	byID := make(map[uint]models.User, len(users))
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+	lastScanByUser, err := reports.LastScans(ctx, userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load last scans: %v", err)
+	}
+	ratingByUser, err := reports.LowestRatings(ctx, userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load feedback: %v", err)
+	}
+
+	for _, c := range candidates {
+		user := byID[c.UserID]
+		entry := ChurnRiskUser{
+			UserID:            c.UserID,
+			Username:          user.Username,
+			Email:             user.Email,
+			PhoneNumber:       user.PhoneNumber,
+			PaidAt:            c.PaidAt,
+			Amount:            c.Amount,
+			DaysSincePayment:  int(now.Sub(c.PaidAt).Hours() / 24),
+			ScansSincePayment: c.ScansSincePayment,
+		}
+		if lastScan, ok := lastScanByUser[c.UserID]; ok {
+			entry.LastScanAt = &lastScan
+		}
+		if c.ScansSincePayment == 0 {
+			entry.Reasons = append(entry.Reasons, ChurnNeverScannedAfterPayment)
+		} else {
+			entry.Reasons = append(entry.Reasons, ChurnSingleScanAfterPayment)
+		}
+		if rating, ok := ratingByUser[c.UserID]; ok {
+			entry.LowestRating = &rating
+			if rating <= 3 {
+				entry.Reasons = append(entry.Reasons, ChurnLowFeedback)
+			}
+		}
+		report.AtRisk = append(report.AtRisk, entry)
+	}
+	return report, nil
+}
+
+// percent returns part/total in percent, rounded to one decimal
+func percent(part, total int64) float64 {
+	return math.Round(float64(part)/float64(total)*1000) / 10
+}
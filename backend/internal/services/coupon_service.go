@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrCouponNotFound is returned for unknown or inactive coupon codes
+	ErrCouponNotFound = errors.New("coupon_not_found")
+	// ErrCouponExpired is returned for coupons past their expiry
+	ErrCouponExpired = errors.New("coupon_expired")
+	// ErrCouponExhausted is returned when every use of a limited coupon is taken
+	ErrCouponExhausted = errors.New("coupon_exhausted")
+	// ErrCouponMinAmount is returned when the amount is below the coupon's minimum
+	ErrCouponMinAmount = errors.New("coupon_min_amount")
+)
+
+// CouponMessage returns the user-facing explanation of a coupon error, or "" for other errors
+func CouponMessage(err error) string {
+	switch {
+	case errors.Is(err, ErrCouponNotFound):
+		return "Kode promo tidak ditemukan atau sudah tidak berlaku."
+	case errors.Is(err, ErrCouponExpired):
+		return "Kode promo sudah kedaluwarsa."
+	case errors.Is(err, ErrCouponExhausted):
+		return "Kuota kode promo sudah habis."
+	case errors.Is(err, ErrCouponMinAmount):
+		return "Nominal pembayaran belum memenuhi minimum kode promo."
+	}
+	return ""
+}
+
+// CouponErrorType returns the error_type of a coupon error, or "" for other errors
+func CouponErrorType(err error) string {
+	for _, couponErr := range []error{ErrCouponNotFound, ErrCouponExpired, ErrCouponExhausted, ErrCouponMinAmount} {
+		if errors.Is(err, couponErr) {
+			return couponErr.Error()
+		}
+	}
+	return ""
+}
+
+// CouponService manages promo codes and their redemptions. A use is reserved when a payment is
+// created with the code and given back when that payment expires or fails unpaid.
+type CouponService struct {
+	ctx     context.Context
+	coupons repository.CouponRepo
+}
+
+// NewCouponService creates a coupon service on the given repository.
+// The zero value CouponService{} uses the default repository.
+func NewCouponService(coupons repository.CouponRepo) *CouponService {
+	return &CouponService{coupons: coupons}
+}
+
+// WithContext returns a copy of the service bound to the request context
+func (cs *CouponService) WithContext(ctx context.Context) *CouponService {
+	return &CouponService{ctx: ctx, coupons: cs.coupons}
+}
+
+// couponRepo returns the injected coupon repository or the default one
+func (cs *CouponService) couponRepo() repository.CouponRepo {
+	if cs.coupons != nil {
+		return cs.coupons
+	}
+	return repository.Default().Coupons
+}
+
+// queryContext returns the service context (background when unset)
+func (cs *CouponService) queryContext() context.Context {
+	if cs.ctx != nil {
+		return cs.ctx
+	}
+	return context.Background()
+}
+
+// ListCoupons returns all coupons, newest first
+func (cs *CouponService) ListCoupons() ([]models.Coupon, error) {
+	return cs.couponRepo().List(cs.queryContext())
+}
+
+// CreateCoupon validates and stores a new coupon
+func (cs *CouponService) CreateCoupon(coupon *models.Coupon) error {
+	if err := coupon.Validate(); err != nil {
+		return err
+	}
+
+	coupon.ID = 0
+	coupon.UsedCount = 0
+	return cs.couponRepo().Create(cs.queryContext(), coupon)
+}
+
+// UpdateCoupon replaces the editable fields of a coupon. The number of uses is kept; payments
+// already created keep their discount.
+func (cs *CouponService) UpdateCoupon(id uint, changes *models.Coupon) (*models.Coupon, error) {
+	if err := changes.Validate(); err != nil {
+		return nil, err
+	}
+
+	coupons := cs.couponRepo()
+	coupon, err := coupons.FindByID(cs.queryContext(), id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCouponNotFound
+		}
+		return nil, err
+	}
+	coupon.Code = changes.Code
+	coupon.Description = changes.Description
+	coupon.DiscountType = changes.DiscountType
+	coupon.DiscountValue = changes.DiscountValue
+	coupon.MaxDiscount = changes.MaxDiscount
+	coupon.MinAmount = changes.MinAmount
+	coupon.UsageLimit = changes.UsageLimit
+	coupon.ExpiresAt = changes.ExpiresAt
+	coupon.IsActive = changes.IsActive
+	if err := coupons.Update(cs.queryContext(), coupon); err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+// Quote checks the code against amount and returns the discount it would give
+func (cs *CouponService) Quote(code string, amount float64) (*models.CouponQuote, error) {
+	coupon, err := cs.usable(code, amount)
+	if err != nil {
+		return nil, err
+	}
+	return couponQuote(coupon, amount), nil
+}
+
+// Reserve takes one use of the coupon for a payment of amount. The limit holds under concurrent
+// payments; give the use back with Release when no invoice gets created.
+func (cs *CouponService) Reserve(code string, amount float64) (*models.Coupon, *models.CouponQuote, error) {
+	coupon, err := cs.usable(code, amount)
+	if err != nil {
+		return nil, nil, err
+	}
+	taken, err := cs.couponRepo().TakeUse(cs.queryContext(), coupon.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to reserve coupon: %v", err)
+	}
+	if !taken {
+		return nil, nil, ErrCouponExhausted
+	}
+	coupon.UsedCount++
+	return coupon, couponQuote(coupon, amount), nil
+}
+
+// Release gives back a use taken by Reserve
+func (cs *CouponService) Release(couponID uint) error {
+	return cs.couponRepo().ReturnUse(cs.queryContext(), couponID)
+}
+
+// Redeem records that the transaction used the coupon
+func (cs *CouponService) Redeem(redemption *models.CouponRedemption) error {
+	return cs.couponRepo().CreateRedemption(cs.queryContext(), redemption)
+}
+
+// ReleaseTransaction gives back the coupon use of a transaction that expired or failed unpaid.
+// Each redemption is released at most once.
+func (cs *CouponService) ReleaseTransaction(transactionID int) error {
+	redemption, err := cs.couponRepo().ReleaseRedemption(cs.queryContext(), transactionID, time.Now())
+	if err != nil || redemption == nil {
+		return err
+	}
+	return cs.Release(redemption.CouponID)
+}
+
+// usable loads the active coupon for code and checks it can be applied to amount
+func (cs *CouponService) usable(code string, amount float64) (*models.Coupon, error) {
+	coupon, err := cs.couponRepo().FindActive(cs.queryContext(), code)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCouponNotFound
+		}
+		return nil, err
+	}
+
+	switch {
+	case coupon.Expired(time.Now()):
+		return nil, ErrCouponExpired
+	case coupon.Exhausted():
+		return nil, ErrCouponExhausted
+	case amount < coupon.MinAmount:
+		return nil, fmt.Errorf("%w: %.0f", ErrCouponMinAmount, coupon.MinAmount)
+	}
+	return coupon, nil
+}
+
+// couponQuote is the discount of coupon on amount
+func couponQuote(coupon *models.Coupon, amount float64) *models.CouponQuote {
+	discount := coupon.DiscountFor(amount)
+	return &models.CouponQuote{
+		Code:        coupon.Code,
+		Description: coupon.Description,
+		Amount:      amount,
+		Discount:    discount,
+		FinalAmount: amount - discount,
+		ExpiresAt:   coupon.ExpiresAt,
+	}
+}
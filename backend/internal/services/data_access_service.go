@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrDataAccessRequired is returned when an admin has no active data access request for the user
+	ErrDataAccessRequired = errors.New("data access request required")
+	// ErrDataAccessRequestNotFound is returned for unknown data access request IDs
+	ErrDataAccessRequestNotFound = errors.New("data access request not found")
+)
+
+// DataAccessService records why support staff look at a user's data and every request they make under it
+type DataAccessService struct {
+	ctx      context.Context
+	requests repository.DataAccessRepo
+	users    repository.UserRepo
+}
+
+// NewDataAccessService creates a data access service on the given repositories.
+// The zero value DataAccessService{} uses the default repositories.
+func NewDataAccessService(requests repository.DataAccessRepo, users repository.UserRepo) *DataAccessService {
+	return &DataAccessService{requests: requests, users: users}
+}
+
+// WithContext returns a copy of the service bound to the request context
+func (ds *DataAccessService) WithContext(ctx context.Context) *DataAccessService {
+	return &DataAccessService{ctx: ctx, requests: ds.requests, users: ds.users}
+}
+
+// requestRepo returns the injected data access repository or the default one
+func (ds *DataAccessService) requestRepo() repository.DataAccessRepo {
+	if ds.requests != nil {
+		return ds.requests
+	}
+	return repository.Default().DataAccess
+}
+
+// userRepo returns the injected user repository or the default one
+func (ds *DataAccessService) userRepo() repository.UserRepo {
+	if ds.users != nil {
+		return ds.users
+	}
+	return repository.Default().Users
+}
+
+// queryContext returns the service context (background when unset)
+func (ds *DataAccessService) queryContext() context.Context {
+	if ds.ctx != nil {
+		return ds.ctx
+	}
+	return context.Background()
+}
+
+// Open creates a data access request for the user, valid for the requested duration
+func (ds *DataAccessService) Open(adminID uint, req models.CreateDataAccessRequest) (*models.DataAccessRequest, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	if _, err := ds.userRepo().FindByID(ds.queryContext(), req.UserID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAdminUserNotFound
+		}
+		return nil, err
+	}
+
+	request := &models.DataAccessRequest{
+		AdminID:   adminID,
+		UserID:    req.UserID,
+		Reason:    req.Reason,
+		ExpiresAt: time.Now().Add(time.Duration(req.DurationMinutes) * time.Minute),
+	}
+	if err := ds.requestRepo().Create(ds.queryContext(), request); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// Active returns the admin's unexpired, unrevoked request for the user, or ErrDataAccessRequired
+func (ds *DataAccessService) Active(adminID, userID uint) (*models.DataAccessRequest, error) {
+	request, err := ds.requestRepo().FindActive(ds.queryContext(), adminID, userID, time.Now())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDataAccessRequired
+		}
+		return nil, err
+	}
+	return request, nil
+}
+
+// Revoke ends a request early. Only the admin who opened it can revoke it.
+func (ds *DataAccessService) Revoke(id, adminID uint) (*models.DataAccessRequest, error) {
+	request, err := ds.requestRepo().FindByAdmin(ds.queryContext(), id, adminID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDataAccessRequestNotFound
+		}
+		return nil, err
+	}
+	if request.RevokedAt == nil {
+		now := time.Now()
+		if err := ds.requestRepo().Revoke(ds.queryContext(), request.ID, now); err != nil {
+			return nil, err
+		}
+		request.RevokedAt = &now
+	}
+	return request, nil
+}
+
+// RecordAccess appends a request served under the data access request to the audit log
+func (ds *DataAccessService) RecordAccess(request *models.DataAccessRequest, method, path string) error {
+	if len(path) > 500 {
+		path = path[:500]
+	}
+	return ds.requestRepo().CreateLog(ds.queryContext(), &models.DataAccessLog{
+		RequestID: request.ID,
+		AdminID:   request.AdminID,
+		UserID:    request.UserID,
+		Method:    method,
+		Path:      path,
+	})
+}
+
+// List returns one page of data access requests, newest first, optionally for one user or admin (0 = all)
+func (ds *DataAccessService) List(userID, adminID uint, page AdminPage) ([]models.DataAccessRequest, int64, error) {
+	return ds.requestRepo().List(ds.queryContext(), userID, adminID, page.listQuery())
+}
+
+// AuditLog returns the requests served under one data access request, oldest first
+func (ds *DataAccessService) AuditLog(requestID uint) ([]models.DataAccessLog, error) {
+	return ds.requestRepo().ListLogs(ds.queryContext(), requestID)
+}
@@ -32,3 +32,12 @@ func (s *DevEmailService) SendPasswordResetEmail(to string, token string, expiry
 	fmt.Printf("=====================================\n")
 	return nil
 }
+
+func (s *DevEmailService) SendAnalysisReport(to string, report AnalysisReport) error {
+	fmt.Printf("=== ANALYSIS REPORT EMAIL (DEV MODE) ===\n")
+	fmt.Printf("To: %s\n", to)
+	fmt.Printf("Strength: %s (previous: %s)\n", report.Strength, report.PreviousStrength)
+	fmt.Printf("Dashboard: %s\n", report.DashboardURL)
+	fmt.Printf("========================================\n")
+	return nil
+}
@@ -3,13 +3,19 @@ package services
 import (
 	"crypto/tls"
 	"fmt"
+	"log/slog"
 	"net"
 	"net/smtp"
 	"os"
 	"strings"
 )
 
-type EmailService struct{}
+// EmailService sends email over SMTP. FromName/FromAddress override the
+// EMAIL_FROM_NAME/EMAIL_FROM sender identity (used for white-label tenants).
+type EmailService struct {
+	FromName    string
+	FromAddress string
+}
 
 func (s *EmailService) SendEmail(to string, subject string, htmlBody string) error {
 	username := os.Getenv("EMAIL_USERNAME")
@@ -18,13 +24,19 @@ func (s *EmailService) SendEmail(to string, subject string, htmlBody string) err
 	port := getenv("EMAIL_PORT", "587")
 	from := getenv("EMAIL_FROM", username)
 	fromName := getenv("EMAIL_FROM_NAME", "WhatsApp Defender")
+	if s.FromAddress != "" {
+		from = s.FromAddress
+	}
+	if s.FromName != "" {
+		fromName = s.FromName
+	}
 
 	if username == "" || password == "" {
 		return fmt.Errorf("email credentials not configured. Please set EMAIL_USERNAME and EMAIL_PASSWORD environment variables")
 	}
 
 	// Log email configuration (without password)
-	fmt.Printf("Attempting to send email via %s:%s from %s to %s\n", host, port, username, to)
+	slog.Info(fmt.Sprintf("Attempting to send email via %s:%s from %s to %s", host, port, username, to))
 
 	addr := net.JoinHostPort(host, port)
 
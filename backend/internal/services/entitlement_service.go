@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+)
+
+// Entitlement reasons, shared with the 402 error_type values of /api/wa/analyze
+const (
+	EntitlementPaid             = "paid"
+	EntitlementWrongPhoneNumber = "wrong_phone_number"
+	EntitlementNoPayment        = "no_payment"
+	// Not paid per phone, but covered by an active subscription with scans left
+	EntitlementSubscription = "subscription"
+	// Active subscription whose scans for this month are used up
+	EntitlementSubscriptionLimitReached = "subscription_limit_reached"
+	// Paid or subscribed, but today's scans of the user's tier are used up
+	EntitlementDailyScanLimit = "daily_scan_limit"
+)
+
+// Entitlement tiers. Paying users have spent money on analyses; trial users only hold
+// free (zero-amount) grants or nothing at all.
+const (
+	EntitlementTierPaid  = "paid"
+	EntitlementTierTrial = "trial"
+)
+
+// Entitlement describes whether a user may analyze a phone number
+type Entitlement struct {
+	PhoneNumber        string `json:"phone_number"` // normalized, e.g. 6281234567890
+	Entitled           bool   `json:"entitled"`
+	Reason             string `json:"reason"`
+	HasPaidOtherNumber bool   `json:"has_paid_other_number"`
+
+	// Subscription consulted when the phone number itself is not paid
+	Subscription *models.SubscriptionStatus `json:"subscription,omitempty"`
+}
+
+// Message returns the user-facing explanation for a missing entitlement
+func (e *Entitlement) Message() string {
+	switch e.Reason {
+	case EntitlementWrongPhoneNumber:
+		return fmt.Sprintf("Anda sudah membayar untuk nomor lain, tapi mencoba scan nomor %s. Silakan bayar untuk nomor ini atau scan nomor yang sudah dibayar.", e.PhoneNumber)
+	case EntitlementNoPayment:
+		return fmt.Sprintf("Pembayaran diperlukan untuk nomor %s. Silakan lakukan pembayaran terlebih dahulu.", e.PhoneNumber)
+	case EntitlementSubscriptionLimitReached:
+		return fmt.Sprintf("Kuota scan langganan Anda bulan ini sudah habis. Silakan tunggu periode berikutnya atau bayar untuk nomor %s.", e.PhoneNumber)
+	case EntitlementDailyScanLimit:
+		return DailyScanLimitMessage()
+	}
+	return ""
+}
+
+// PaymentRequired reports whether paying for the phone number would let the user analyze it
+func (e *Entitlement) PaymentRequired() bool {
+	return !e.Entitled && e.Reason != EntitlementDailyScanLimit
+}
+
+// EntitlementService decides whether a user has paid for analyzing a phone number.
+// It is the single source of truth for the analyze paywall and its prechecks.
+type EntitlementService struct {
+	ctx           context.Context
+	transactions  repository.TransactionRepo
+	analyses      repository.AnalysisRepo
+	limits        repository.ProductLimitRepo
+	subscriptions *SubscriptionService
+}
+
+// NewEntitlementService creates an entitlement service on the given repositories (transactions,
+// subscriptions, product limits and analyses for the daily scan count)
+func NewEntitlementService(repos *repository.Repositories) *EntitlementService {
+	return &EntitlementService{
+		ctx:           context.Background(),
+		transactions:  repos.Transactions,
+		analyses:      repos.Analyses,
+		limits:        repos.ProductLimits,
+		subscriptions: NewSubscriptionService(repos.Subscriptions),
+	}
+}
+
+// WithContext returns an EntitlementService bound to the request context (tenant scope)
+func (es *EntitlementService) WithContext(ctx context.Context) *EntitlementService {
+	return &EntitlementService{ctx: ctx, transactions: es.transactions, analyses: es.analyses, limits: es.limits, subscriptions: es.subscriptions.WithContext(ctx)}
+}
+
+// Check compares the normalized phone number against the user's paid transactions,
+// falling back to the user's active subscription
+func (es *EntitlementService) Check(userID uint, phoneNumber string) (*Entitlement, error) {
+	normalized := NormalizePhoneNumber(phoneNumber)
+	entitlement := &Entitlement{PhoneNumber: normalized}
+
+	paidPhones, err := es.transactions.PaidPhoneNumbers(es.ctx, int(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check payment for phone: %v", err)
+	}
+
+	for _, paid := range paidPhones {
+		if normalized != "" && NormalizePhoneNumber(paid) == normalized {
+			entitlement.Entitled = true
+			entitlement.Reason = EntitlementPaid
+			return entitlement, nil
+		}
+	}
+
+	entitlement.HasPaidOtherNumber = len(paidPhones) > 0
+
+	subscription, err := es.subscriptions.Current(userID)
+	switch {
+	case err == nil:
+		entitlement.Subscription = subscription
+		if subscription.HasScansLeft() {
+			entitlement.Entitled = true
+			entitlement.Reason = EntitlementSubscription
+		} else {
+			entitlement.Reason = EntitlementSubscriptionLimitReached
+		}
+		return entitlement, nil
+	case !errors.Is(err, ErrNoSubscription):
+		return nil, fmt.Errorf("failed to check subscription: %v", err)
+	}
+
+	if entitlement.HasPaidOtherNumber {
+		entitlement.Reason = EntitlementWrongPhoneNumber
+	} else {
+		entitlement.Reason = EntitlementNoPayment
+	}
+	return entitlement, nil
+}
+
+// Evaluate applies every rule of /api/wa/analyze: Check, then the daily scan limit of the user's
+// tier. It backs the prechecks the frontend makes before connecting.
+func (es *EntitlementService) Evaluate(userID uint, phoneNumber string) (*Entitlement, error) {
+	entitlement, err := es.Check(userID, phoneNumber)
+	if err != nil || !entitlement.Entitled {
+		return entitlement, err
+	}
+	if err := es.CheckDailyScans(userID); errors.Is(err, ErrDailyScanLimit) {
+		entitlement.Entitled = false
+		entitlement.Reason = EntitlementDailyScanLimit
+	} else if err != nil {
+		// The analyze request checks again; a precheck does not fail on it
+		logging.FromContext(es.ctx).Warn("Failed to check daily scan limit", "user_id", userID, "error", err)
+	}
+	return entitlement, nil
+}
+
+// ConsumeScan records a completed analysis of phoneNumber. Numbers paid per phone are free to
+// re-analyze; anything else counts against the user's subscription.
+func (es *EntitlementService) ConsumeScan(userID uint, phoneNumber string) {
+	entitlement, err := es.Check(userID, phoneNumber)
+	if err != nil {
+		logging.FromContext(es.ctx).Error("Failed to check entitlement for scan accounting", "user_id", userID, "error", err)
+		return
+	}
+	if entitlement.Reason != EntitlementSubscription {
+		return
+	}
+	if err := es.subscriptions.ConsumeScan(userID); err != nil {
+		logging.FromContext(es.ctx).Warn("Failed to count subscription scan", "user_id", userID, "error", err)
+	}
+}
+
+// Tier returns EntitlementTierPaid when the user has paid a non-zero amount, otherwise EntitlementTierTrial
+func (es *EntitlementService) Tier(userID uint) (string, error) {
+	amount, err := es.transactions.PaidAmount(es.ctx, int(userID))
+	if err != nil {
+		return "", fmt.Errorf("failed to check paid amount: %v", err)
+	}
+	if amount > 0 {
+		return EntitlementTierPaid, nil
+	}
+	return EntitlementTierTrial, nil
+}
+
+// NormalizePhoneNumber reduces Indonesian phone numbers to the WhatsApp JID form (62xxxxxxxxxx).
+// "+62 812-3456-7890", "0812 3456 7890" and "6281234567890" all normalize to "6281234567890".
+func NormalizePhoneNumber(raw string) string {
+	// Drop a JID suffix/device part if a JID was passed ("628123:12@s.whatsapp.net")
+	if i := strings.IndexAny(raw, ":@"); i >= 0 {
+		raw = raw[:i]
+	}
+
+	var digits strings.Builder
+	for _, r := range raw {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	phone := digits.String()
+
+	switch {
+	case strings.HasPrefix(phone, "0"):
+		phone = "62" + strings.TrimLeft(phone, "0")
+	case strings.HasPrefix(phone, "8") && len(phone) >= 9 && len(phone) <= 12:
+		phone = "62" + phone
+	}
+	return phone
+}
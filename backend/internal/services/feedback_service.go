@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrAnalysisNotFound is returned when the analysis does not exist or belongs to another user
+	ErrAnalysisNotFound = errors.New("analysis not found")
+	// ErrInvalidFeedback wraps rating/comment validation errors
+	ErrInvalidFeedback = errors.New("invalid feedback")
+)
+
+// FeedbackService stores ratings of analysis results
+type FeedbackService struct {
+	ctx      context.Context
+	feedback repository.FeedbackRepo
+	analyses repository.AnalysisRepo
+}
+
+// NewFeedbackService creates a feedback service on the given repositories.
+// The zero value FeedbackService{} uses the default repositories.
+func NewFeedbackService(feedback repository.FeedbackRepo, analyses repository.AnalysisRepo) *FeedbackService {
+	return &FeedbackService{feedback: feedback, analyses: analyses}
+}
+
+// WithContext returns a copy of the service bound to the request context
+func (fs *FeedbackService) WithContext(ctx context.Context) *FeedbackService {
+	return &FeedbackService{ctx: ctx, feedback: fs.feedback, analyses: fs.analyses}
+}
+
+// feedbackRepo returns the injected feedback repository or the default one
+func (fs *FeedbackService) feedbackRepo() repository.FeedbackRepo {
+	if fs.feedback != nil {
+		return fs.feedback
+	}
+	return repository.Default().Feedback
+}
+
+// analysisRepo returns the injected analysis repository or the default one
+func (fs *FeedbackService) analysisRepo() repository.AnalysisRepo {
+	if fs.analyses != nil {
+		return fs.analyses
+	}
+	return repository.Default().Analyses
+}
+
+// queryContext returns the service context (background when unset)
+func (fs *FeedbackService) queryContext() context.Context {
+	if fs.ctx != nil {
+		return fs.ctx
+	}
+	return context.Background()
+}
+
+// Submit stores the user's rating of one of their analyses, replacing an earlier one
+func (fs *FeedbackService) Submit(userID, analysisID uint, feedback *models.AnalysisFeedback) error {
+	if err := feedback.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidFeedback, err)
+	}
+
+	analysis, err := fs.analysisRepo().FindForUser(fs.queryContext(), analysisID, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrAnalysisNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	feedback.ID = 0
+	feedback.AnalysisResultID = analysisID
+	feedback.UserID = userID
+	feedback.Strength = analysis.Strength
+	return fs.feedbackRepo().Upsert(fs.queryContext(), feedback)
+}
+
+// ShouldPrompt reports whether the frontend should ask for feedback on the analysis
+func (fs *FeedbackService) ShouldPrompt(analysisID uint) bool {
+	if analysisID == 0 {
+		return false
+	}
+	count, err := fs.feedbackRepo().CountForAnalysis(fs.queryContext(), analysisID)
+	if err != nil {
+		return false
+	}
+	return count == 0
+}
+
+// Summary returns the average rating overall and per strength band, for feedback since since (nil = all)
+func (fs *FeedbackService) Summary(since *time.Time) (*models.FeedbackSummary, error) {
+	rows, err := fs.feedbackRepo().CountByRating(fs.queryContext(), since)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &models.FeedbackSummary{Since: since, Bands: []models.FeedbackBandSummary{}}
+	bands := map[string]int{} // strength -> index in summary.Bands
+	var total int64
+	for _, row := range rows {
+		i, ok := bands[row.Strength]
+		if !ok {
+			i = len(summary.Bands)
+			bands[row.Strength] = i
+			summary.Bands = append(summary.Bands, models.FeedbackBandSummary{Strength: row.Strength, Ratings: map[int]int{}})
+		}
+		band := &summary.Bands[i]
+		band.Count += row.Count
+		band.WithComment += row.WithComment
+		band.Ratings[row.Rating] += int(row.Count)
+		band.AverageRating += float64(row.Rating) * float64(row.Count)
+		summary.Count += row.Count
+		total += int64(row.Rating) * row.Count
+	}
+	for i := range summary.Bands {
+		band := &summary.Bands[i]
+		band.AverageRating = roundRating(band.AverageRating / float64(band.Count))
+	}
+	if summary.Count > 0 {
+		summary.AverageRating = roundRating(float64(total) / float64(summary.Count))
+	}
+	return summary, nil
+}
+
+func roundRating(v float64) float64 {
+	return math.Round(v*100) / 100
+}
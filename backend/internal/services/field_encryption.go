@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"back_wa/internal/fieldcrypt"
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+)
+
+// devFieldMasterKeyID is the key id of the built-in master key, only accepted when ENVIRONMENT=development
+const devFieldMasterKeyID = "dev"
+
+// ErrNoFieldMasterKey is returned outside development mode when no master key is configured
+var ErrNoFieldMasterKey = errors.New("no field encryption master key configured (set FIELD_ENCRYPTION_KEYS)")
+
+// EncryptedColumn is a column sealed by field encryption, with its blind index column if it has one
+type EncryptedColumn struct {
+	Table       string
+	Column      string
+	IndexColumn string
+}
+
+// EncryptedColumns lists the columns encrypted at the application layer
+var EncryptedColumns = []EncryptedColumn{
+	{Table: "users", Column: "phone_number", IndexColumn: "phone_number_hash"},
+	{Table: "users", Column: "otp_code"},
+	{Table: "users", Column: "reset_token"},
+	{Table: "transactions", Column: "phone_number", IndexColumn: "phone_number_hash"},
+	{Table: "scan_history", Column: "phone_number"},
+	{Table: "whatsapp_session_events", Column: "phone_number"},
+	{Table: "whatsapp_sessions", Column: "session_data"},
+}
+
+// LoadFieldMasterKeys reads the master keys wrapping the data keys: FIELD_ENCRYPTION_KEYS
+// ("kid:base64 32 byte key,...") and FIELD_ENCRYPTION_ACTIVE_KID (default: the first key).
+// Rotation: add the new key, make it active, run encrypt-backfill and drop the old key afterwards.
+func LoadFieldMasterKeys() (fieldcrypt.KeyProvider, error) {
+	keys := make(map[string][]byte)
+	var order []string
+	for _, entry := range splitList(os.Getenv("FIELD_ENCRYPTION_KEYS")) {
+		kid, encoded, err := splitKeyEntry(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid FIELD_ENCRYPTION_KEYS entry: %v", err)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid FIELD_ENCRYPTION_KEYS entry %q: key is not base64", kid)
+		}
+		if _, dup := keys[kid]; dup {
+			return nil, fmt.Errorf("duplicate field encryption key id %q", kid)
+		}
+		keys[kid] = key
+		order = append(order, kid)
+	}
+
+	if len(keys) == 0 {
+		if !IsDevelopment() {
+			return nil, ErrNoFieldMasterKey
+		}
+		slog.Warn("No field encryption master key configured, using the built-in development key")
+		devKey := sha256.Sum256([]byte("cekwa-development-field-encryption-key"))
+		keys[devFieldMasterKeyID] = devKey[:]
+		order = append(order, devFieldMasterKeyID)
+	}
+	if _, ok := keys[devFieldMasterKeyID]; ok && !IsDevelopment() {
+		return nil, fmt.Errorf("field encryption key id %q is reserved for development", devFieldMasterKeyID)
+	}
+
+	activeID := strings.TrimSpace(os.Getenv("FIELD_ENCRYPTION_ACTIVE_KID"))
+	if activeID == "" {
+		activeID = order[0]
+	}
+	return fieldcrypt.NewStaticKeyProvider(activeID, keys)
+}
+
+// InitFieldEncryption unwraps the data keys stored in the database (creating them on first start)
+// and installs them, so encrypted columns are sealed and opened transparently
+func InitFieldEncryption(dataKeyRepo repository.DataKeyRepo, provider fieldcrypt.KeyProvider) error {
+	ctx := context.Background()
+	keys, err := dataKeyRepo.ListActive(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load data keys: %v", err)
+	}
+	for _, purpose := range []string{models.DataKeyPurposeField, models.DataKeyPurposeIndex} {
+		if activeDataKey(keys, purpose) != nil {
+			continue
+		}
+		created, err := createDataKey(ctx, dataKeyRepo, provider, purpose)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, *created)
+	}
+	// Another instance may have created keys at the same time: the oldest one wins everywhere
+	if keys, err = dataKeyRepo.List(ctx); err != nil {
+		return fmt.Errorf("failed to load data keys: %v", err)
+	}
+
+	dataKeys := make(map[string][]byte)
+	for _, key := range keys {
+		if key.Purpose != models.DataKeyPurposeField {
+			continue
+		}
+		unwrapped, err := provider.Unwrap(key.MasterKeyID, key.WrappedKey)
+		if err != nil {
+			return fmt.Errorf("data key %d: %v", key.ID, err)
+		}
+		dataKeys[strconv.FormatUint(uint64(key.ID), 10)] = unwrapped
+	}
+
+	field, index := activeDataKey(keys, models.DataKeyPurposeField), activeDataKey(keys, models.DataKeyPurposeIndex)
+	indexKey, err := provider.Unwrap(index.MasterKeyID, index.WrappedKey)
+	if err != nil {
+		return fmt.Errorf("blind index key %d: %v", index.ID, err)
+	}
+	keyring, err := fieldcrypt.NewKeyring(strconv.FormatUint(uint64(field.ID), 10), dataKeys, indexKey)
+	if err != nil {
+		return err
+	}
+	fieldcrypt.Install(keyring)
+	return nil
+}
+
+// activeDataKey returns the oldest key of purpose that is not retired
+func activeDataKey(keys []models.DataEncryptionKey, purpose string) *models.DataEncryptionKey {
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	for i := range keys {
+		if keys[i].Purpose == purpose && keys[i].RetiredAt == nil {
+			return &keys[i]
+		}
+	}
+	return nil
+}
+
+func createDataKey(ctx context.Context, dataKeyRepo repository.DataKeyRepo, provider fieldcrypt.KeyProvider, purpose string) (*models.DataEncryptionKey, error) {
+	dataKey, err := fieldcrypt.NewDataKey()
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := provider.Wrap(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap new %s data key: %v", purpose, err)
+	}
+	key := &models.DataEncryptionKey{Purpose: purpose, WrappedKey: wrapped, MasterKeyID: provider.KeyID()}
+	if err := dataKeyRepo.Create(ctx, key); err != nil {
+		return nil, fmt.Errorf("failed to store new %s data key: %v", purpose, err)
+	}
+	return key, nil
+}
+
+// FieldEncryptionBackfill summarizes an encrypt-backfill run
+type FieldEncryptionBackfill struct {
+	RewrappedKeys int            `json:"rewrapped_keys"`
+	Rows          map[string]int `json:"rows"` // "table.column" -> rows encrypted or re-indexed
+}
+
+// backfillBatchSize is the number of rows read per query by BackfillFieldEncryption
+const backfillBatchSize = 500
+
+// BackfillFieldEncryption rewraps the data keys with the active master key and encrypts the rows
+// written before field encryption (or re-indexes them when the blind index is missing).
+// It is idempotent and can run while the API serves traffic.
+func BackfillFieldEncryption(dataKeyRepo repository.DataKeyRepo, provider fieldcrypt.KeyProvider) (*FieldEncryptionBackfill, error) {
+	ctx := context.Background()
+	if !fieldcrypt.Installed() {
+		return nil, fieldcrypt.ErrNotConfigured
+	}
+	summary := &FieldEncryptionBackfill{Rows: make(map[string]int)}
+
+	keys, err := dataKeyRepo.ListNotWrappedWith(ctx, provider.KeyID())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load data keys: %v", err)
+	}
+	for _, key := range keys {
+		dataKey, err := provider.Unwrap(key.MasterKeyID, key.WrappedKey)
+		if err != nil {
+			return nil, fmt.Errorf("data key %d: %v", key.ID, err)
+		}
+		wrapped, err := provider.Wrap(dataKey)
+		if err != nil {
+			return nil, fmt.Errorf("data key %d: %v", key.ID, err)
+		}
+		if err := dataKeyRepo.Rewrap(ctx, key.ID, wrapped, provider.KeyID()); err != nil {
+			return nil, fmt.Errorf("failed to rewrap data key %d: %v", key.ID, err)
+		}
+		summary.RewrappedKeys++
+	}
+
+	for _, column := range EncryptedColumns {
+		count, err := backfillColumn(ctx, dataKeyRepo, column)
+		if err != nil {
+			return summary, fmt.Errorf("%s.%s: %v", column.Table, column.Column, err)
+		}
+		summary.Rows[column.Table+"."+column.Column] = count
+	}
+	return summary, nil
+}
+
+// backfillColumn walks the table by id and updates the rows that are still plaintext or lack a blind index
+func backfillColumn(ctx context.Context, dataKeyRepo repository.DataKeyRepo, column EncryptedColumn) (int, error) {
+	selected := []string{"id", column.Column}
+	if column.IndexColumn != "" {
+		selected = append(selected, column.IndexColumn)
+	}
+
+	updated := 0
+	var lastID uint
+	for {
+		rows, err := dataKeyRepo.ListRows(ctx, column.Table, selected, lastID, backfillBatchSize)
+		if err != nil {
+			return updated, err
+		}
+		if len(rows) == 0 {
+			return updated, nil
+		}
+
+		for _, row := range rows {
+			id, _ := strconv.ParseUint(fmt.Sprint(row["id"]), 10, 64)
+			lastID = uint(id)
+			value := columnString(row[column.Column])
+			if value == "" {
+				continue
+			}
+
+			updates := make(map[string]interface{})
+			plaintext, err := fieldcrypt.Open(column.Column, value)
+			if err != nil {
+				return updated, fmt.Errorf("row %d: %v", id, err)
+			}
+			if !fieldcrypt.IsSealed(value) {
+				if updates[column.Column], err = fieldcrypt.Seal(column.Column, plaintext); err != nil {
+					return updated, err
+				}
+			}
+			if column.IndexColumn != "" {
+				if hash := fieldcrypt.BlindIndex(plaintext); columnString(row[column.IndexColumn]) != hash {
+					updates[column.IndexColumn] = hash
+				}
+			}
+			if len(updates) == 0 {
+				continue
+			}
+			if err := dataKeyRepo.UpdateRow(ctx, column.Table, id, updates); err != nil {
+				return updated, fmt.Errorf("row %d: %v", id, err)
+			}
+			updated++
+		}
+		time.Sleep(10 * time.Millisecond) // leave room for live traffic between batches
+	}
+}
+
+func columnString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"log/slog"
+	"time"
+
+	"back_wa/internal/database"
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+)
+
+// InactiveAccountPolicy is the retention policy for dormant accounts.
+// Accounts without a login for Months are warned by email; if they still do not log in within
+// GraceDays the personal data is anonymized and the account soft-deleted.
+type InactiveAccountPolicy struct {
+	Months    int           // INACTIVE_ACCOUNT_MONTHS, 0 disables the job
+	GraceDays int           // INACTIVE_ACCOUNT_GRACE_DAYS (default 30)
+	Interval  time.Duration // INACTIVE_ACCOUNT_CHECK_HOURS (default 24)
+	BatchSize int           // INACTIVE_ACCOUNT_BATCH_SIZE (default 200)
+}
+
+// InactiveAccountSweep summarizes one run of the job
+type InactiveAccountSweep struct {
+	Warned     int `json:"warned"`
+	Anonymized int `json:"anonymized"`
+	Failed     int `json:"failed"`
+}
+
+// LoadInactiveAccountPolicy reads the policy from the environment
+func LoadInactiveAccountPolicy() InactiveAccountPolicy {
+	policy := InactiveAccountPolicy{
+		Months:    getIntEnv("INACTIVE_ACCOUNT_MONTHS", 0),
+		GraceDays: getIntEnv("INACTIVE_ACCOUNT_GRACE_DAYS", 30),
+		Interval:  time.Duration(getIntEnv("INACTIVE_ACCOUNT_CHECK_HOURS", 24)) * time.Hour,
+		BatchSize: getIntEnv("INACTIVE_ACCOUNT_BATCH_SIZE", 200),
+	}
+	if policy.GraceDays < 1 {
+		policy.GraceDays = 30
+	}
+	if policy.Interval <= 0 {
+		policy.Interval = 24 * time.Hour
+	}
+	if policy.BatchSize <= 0 {
+		policy.BatchSize = 200
+	}
+	return policy
+}
+
+// StartInactiveAccountWorker runs the sweep every policy interval (no-op when disabled)
+func StartInactiveAccountWorker(repos *repository.Repositories, policy InactiveAccountPolicy) {
+	if policy.Months <= 0 {
+		return
+	}
+	slog.Debug(fmt.Sprintf("Inactive account job enabled (inactive after %d months, %d days grace)", policy.Months, policy.GraceDays))
+
+	go func() {
+		ticker := time.NewTicker(policy.Interval)
+		defer ticker.Stop()
+		for {
+			if database.IsDegraded() {
+				slog.Debug("Inactive account job skipped while the database is degraded")
+			} else if sweep, err := RunInactiveAccountSweep(context.Background(), repos, policy, time.Now()); err != nil {
+				slog.Warn("Inactive account job failed", "error", err)
+			} else if sweep.Warned+sweep.Anonymized+sweep.Failed > 0 {
+				slog.Debug(fmt.Sprintf("Inactive account job: warned=%d anonymized=%d failed=%d", sweep.Warned, sweep.Anonymized, sweep.Failed))
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// RunInactiveAccountSweep warns newly inactive accounts and anonymizes those whose grace period ran out.
+// Admin accounts are never touched. Activity is the last login, or registration for accounts that never logged in.
+func RunInactiveAccountSweep(ctx context.Context, repos *repository.Repositories, policy InactiveAccountPolicy, now time.Time) (InactiveAccountSweep, error) {
+	var sweep InactiveAccountSweep
+	cutoff := now.AddDate(0, -policy.Months, 0)
+
+	toWarn, err := repos.Accounts.ListInactive(ctx, cutoff, policy.BatchSize)
+	if err != nil {
+		return sweep, err
+	}
+	for i := range toWarn {
+		if err := warnInactiveAccount(ctx, repos, &toWarn[i], policy, now); err != nil {
+			slog.Warn("Failed to send inactivity warning", "user_id", toWarn[i].ID, "error", err)
+			sweep.Failed++
+			continue
+		}
+		sweep.Warned++
+	}
+
+	toAnonymize, err := repos.Accounts.ListWarnedInactive(ctx, cutoff, now.AddDate(0, 0, -policy.GraceDays), policy.BatchSize)
+	if err != nil {
+		return sweep, err
+	}
+	for i := range toAnonymize {
+		if err := AnonymizeUser(ctx, repos.Accounts, toAnonymize[i].ID, now); err != nil {
+			slog.Warn("Failed to anonymize inactive account", "user_id", toAnonymize[i].ID, "error", err)
+			sweep.Failed++
+			continue
+		}
+		slog.Info(fmt.Sprintf("user %d anonymized after %d months of inactivity", toAnonymize[i].ID, policy.Months), "audit", true, "user_id", toAnonymize[i].ID)
+		sweep.Anonymized++
+	}
+
+	return sweep, nil
+}
+
+// warnInactiveAccount emails the deletion notice and starts the grace period
+func warnInactiveAccount(ctx context.Context, repos *repository.Repositories, user *models.User, policy InactiveAccountPolicy, now time.Time) error {
+	var tenant *models.Tenant
+	if user.TenantID != nil {
+		if t, err := repos.Tenants.FindByID(ctx, *user.TenantID); err == nil {
+			tenant = t
+		}
+	}
+
+	deadline := now.AddDate(0, 0, policy.GraceDays)
+	subject := "Akun kamu akan dihapus karena tidak aktif"
+	body := fmt.Sprintf(`<h2>%s</h2><p>Halo %s,</p><p>Akun kamu tidak digunakan selama lebih dari %d bulan.
+Sesuai kebijakan retensi data, data pribadi akun (email, nomor HP, password) akan dianonimkan pada <strong>%s</strong>.</p>
+<p>Login sebelum tanggal tersebut jika kamu ingin tetap memakai akun ini.</p>`,
+		subject, html.EscapeString(user.Username), policy.Months, deadline.Format("02 Jan 2006"))
+	if err := EmailServiceFor(tenant).SendEmail(user.Email, subject, body); err != nil {
+		return err
+	}
+
+	return repos.Accounts.MarkInactivityWarned(ctx, user.ID, now)
+}
+
+// AnonymizeUser replaces the personal data of an account, invalidates its password and soft-deletes it.
+// Analysis results and transactions are kept (without contact details) for aggregate statistics
+// and bookkeeping; device-level data (chats, push tokens, notifications, share links) is removed.
+func AnonymizeUser(ctx context.Context, accounts repository.AccountRepo, userID uint, now time.Time) error {
+	passwordHash, err := unusablePasswordHash()
+	if err != nil {
+		return err
+	}
+	return accounts.Anonymize(ctx, userID, passwordHash, now)
+}
+
+// unusablePasswordHash returns a random password hash: "!" never matches a bcrypt hash, so the
+// password can no longer be used
+func unusablePasswordHash() (string, error) {
+	placeholder := make([]byte, 16)
+	if _, err := rand.Read(placeholder); err != nil {
+		return "", err
+	}
+	return "!" + hex.EncodeToString(placeholder), nil
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"back_wa/internal/models"
+)
+
+// inputAnomalies counts sanity-rule violations per rule since process start
+var inputAnomalies = struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}{counts: make(map[string]int64)}
+
+// RecordInputAnomalies logs the inconsistent values an analysis produced and counts them per
+// rule, so recurring engine errors show up in GET /api/admin/metrics/analysis
+func RecordInputAnomalies(userID uint, anomalies []models.InputAnomaly) {
+	if len(anomalies) == 0 {
+		return
+	}
+
+	inputAnomalies.mu.Lock()
+	for _, anomaly := range anomalies {
+		inputAnomalies.counts[anomaly.Rule]++
+	}
+	inputAnomalies.mu.Unlock()
+
+	for _, anomaly := range anomalies {
+		if anomaly.Corrected != nil {
+			slog.Warn(fmt.Sprintf("%s: %s=%d corrected to %d (%s)", anomaly.Rule, anomaly.Key, anomaly.Value, *anomaly.Corrected, anomaly.Detail), "anomaly", true, "user_id", userID)
+		} else {
+			slog.Warn(fmt.Sprintf("%s: %s=%d flagged (%s)", anomaly.Rule, anomaly.Key, anomaly.Value, anomaly.Detail), "anomaly", true, "user_id", userID)
+		}
+	}
+}
+
+func inputAnomalyCounts() map[string]int64 {
+	inputAnomalies.mu.Lock()
+	defer inputAnomalies.mu.Unlock()
+	counts := make(map[string]int64, len(inputAnomalies.counts))
+	for rule, count := range inputAnomalies.counts {
+		counts[rule] = count
+	}
+	return counts
+}
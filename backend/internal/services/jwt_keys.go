@@ -0,0 +1,375 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// legacyJWTKeyID is the key id given to JWT_SECRET; tokens issued before key ids existed carry no kid
+const legacyJWTKeyID = "default"
+
+// devJWTSecret is only accepted when ENVIRONMENT=development
+const devJWTSecret = "wa-analyzer-super-secret-jwt-key-2024-change-in-production"
+
+var (
+	// ErrNoJWTKey is returned outside development mode when no signing key is configured
+	ErrNoJWTKey = errors.New("no JWT signing key configured (set JWT_KEYS or JWT_SECRET)")
+	// ErrUnknownJWTKeyID is returned for tokens signed with a key that is no longer accepted
+	ErrUnknownJWTKeyID = errors.New("unknown JWT key id")
+)
+
+// jwtKey is one key of the keyset. sign is nil for verify-only keys (previous public keys).
+type jwtKey struct {
+	id     string
+	method jwt.SigningMethod
+	sign   interface{} // []byte, *rsa.PrivateKey or ed25519.PrivateKey
+	verify interface{} // []byte, *rsa.PublicKey or ed25519.PublicKey
+}
+
+// JWTKeySet holds the key used to sign new tokens and every key still accepted for verification.
+// Rotation: add the new key to JWT_KEYS, point JWT_ACTIVE_KID at it and drop the old key once
+// the tokens it signed have expired. With JWT_SIGNING_ALG=RS256/EdDSA the private key signs
+// and the public keys are published as JWKS.
+type JWTKeySet struct {
+	activeID string
+	keys     map[string]*jwtKey
+}
+
+var (
+	jwtKeys     *JWTKeySet
+	jwtKeysErr  error
+	jwtKeysOnce sync.Once
+	jwtKeysMu   sync.RWMutex
+)
+
+// JWTKeys returns the keyset loaded from the environment
+func JWTKeys() (*JWTKeySet, error) {
+	jwtKeysOnce.Do(func() {
+		ks, err := LoadJWTKeySet()
+		jwtKeysMu.Lock()
+		jwtKeys, jwtKeysErr = ks, err
+		jwtKeysMu.Unlock()
+	})
+	jwtKeysMu.RLock()
+	defer jwtKeysMu.RUnlock()
+	return jwtKeys, jwtKeysErr
+}
+
+// ReloadJWTKeys reads the keyset from the environment again, e.g. after a rotated secret was
+// fetched from the secret manager. The current keyset stays in use when the new one is invalid.
+func ReloadJWTKeys() error {
+	JWTKeys()
+	ks, err := LoadJWTKeySet()
+	if err != nil {
+		return err
+	}
+	jwtKeysMu.Lock()
+	jwtKeys, jwtKeysErr = ks, nil
+	jwtKeysMu.Unlock()
+	return nil
+}
+
+// IsDevelopment reports whether ENVIRONMENT is development (insecure defaults allowed)
+func IsDevelopment() bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("ENVIRONMENT"))) {
+	case "development", "dev", "local":
+		return true
+	}
+	return false
+}
+
+// LoadJWTKeySet reads the HMAC keys (JWT_KEYS "kid:secret,...", JWT_ACTIVE_KID, legacy JWT_SECRET)
+// and, for JWT_SIGNING_ALG=RS256 or EdDSA, the private key (JWT_PRIVATE_KEY_FILE, JWT_PRIVATE_KEY_KID)
+// plus previous public keys still accepted (JWT_PUBLIC_KEYS "kid:path,...").
+func LoadJWTKeySet() (*JWTKeySet, error) {
+	ks := &JWTKeySet{keys: make(map[string]*jwtKey)}
+
+	var hmacOrder []string
+	for _, entry := range splitList(os.Getenv("JWT_KEYS")) {
+		kid, secret, err := splitKeyEntry(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT_KEYS entry: %v", err)
+		}
+		if err := ks.add(&jwtKey{id: kid, method: jwt.SigningMethodHS256, sign: []byte(secret), verify: []byte(secret)}); err != nil {
+			return nil, err
+		}
+		hmacOrder = append(hmacOrder, kid)
+	}
+
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		if _, exists := ks.keys[legacyJWTKeyID]; !exists {
+			ks.keys[legacyJWTKeyID] = &jwtKey{id: legacyJWTKeyID, method: jwt.SigningMethodHS256, sign: []byte(secret), verify: []byte(secret)}
+			hmacOrder = append(hmacOrder, legacyJWTKeyID)
+		}
+	}
+
+	alg := strings.ToUpper(strings.TrimSpace(os.Getenv("JWT_SIGNING_ALG")))
+	switch alg {
+	case "", "HS256":
+		if len(ks.keys) == 0 {
+			if !IsDevelopment() {
+				return nil, ErrNoJWTKey
+			}
+			slog.Warn("No JWT key configured, using the built-in development key")
+			ks.keys[legacyJWTKeyID] = &jwtKey{id: legacyJWTKeyID, method: jwt.SigningMethodHS256, sign: []byte(devJWTSecret), verify: []byte(devJWTSecret)}
+			hmacOrder = append(hmacOrder, legacyJWTKeyID)
+		}
+		ks.activeID = strings.TrimSpace(os.Getenv("JWT_ACTIVE_KID"))
+		if ks.activeID == "" {
+			ks.activeID = hmacOrder[0]
+		}
+	case "RS256", "EDDSA":
+		key, err := loadPrivateJWTKey(alg)
+		if err != nil {
+			return nil, err
+		}
+		if err := ks.add(key); err != nil {
+			return nil, err
+		}
+		ks.activeID = key.id
+	default:
+		return nil, fmt.Errorf("unsupported JWT_SIGNING_ALG %q (HS256, RS256 or EdDSA)", alg)
+	}
+
+	for _, entry := range splitList(os.Getenv("JWT_PUBLIC_KEYS")) {
+		kid, path, err := splitKeyEntry(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT_PUBLIC_KEYS entry: %v", err)
+		}
+		key, err := loadPublicJWTKey(kid, path)
+		if err != nil {
+			return nil, err
+		}
+		if err := ks.add(key); err != nil {
+			return nil, err
+		}
+	}
+
+	if active, ok := ks.keys[ks.activeID]; !ok || active.sign == nil {
+		return nil, fmt.Errorf("JWT_ACTIVE_KID %q is not a configured signing key", ks.activeID)
+	}
+
+	if !IsDevelopment() {
+		for kid, key := range ks.keys {
+			if secret, ok := key.sign.([]byte); ok && string(secret) == devJWTSecret {
+				return nil, fmt.Errorf("JWT key %q uses the built-in development secret", kid)
+			}
+		}
+	}
+	return ks, nil
+}
+
+// add registers a key, rejecting duplicate key ids
+func (ks *JWTKeySet) add(key *jwtKey) error {
+	if _, dup := ks.keys[key.id]; dup {
+		return fmt.Errorf("duplicate JWT key id %q", key.id)
+	}
+	ks.keys[key.id] = key
+	return nil
+}
+
+// loadPrivateJWTKey reads JWT_PRIVATE_KEY_FILE for RS256 (PKCS#1/PKCS#8) or EdDSA (PKCS#8)
+func loadPrivateJWTKey(alg string) (*jwtKey, error) {
+	path := os.Getenv("JWT_PRIVATE_KEY_FILE")
+	if path == "" {
+		return nil, fmt.Errorf("JWT_SIGNING_ALG=%s requires JWT_PRIVATE_KEY_FILE", alg)
+	}
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT private key: %v", err)
+	}
+
+	key := &jwtKey{id: strings.TrimSpace(os.Getenv("JWT_PRIVATE_KEY_KID"))}
+	if alg == "RS256" {
+		private, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA private key: %v", err)
+		}
+		key.method, key.sign, key.verify = jwt.SigningMethodRS256, private, &private.PublicKey
+	} else {
+		parsed, err := jwt.ParseEdPrivateKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Ed25519 private key: %v", err)
+		}
+		private, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("invalid Ed25519 private key")
+		}
+		key.method, key.sign, key.verify = jwt.SigningMethodEdDSA, private, private.Public()
+	}
+
+	if key.id == "" {
+		key.id, err = keyThumbprint(key.verify)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// loadPublicJWTKey reads a PEM public key (RSA or Ed25519) accepted for verification only
+func loadPublicJWTKey(kid, path string) (*jwtKey, error) {
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT public key %q: %v", kid, err)
+	}
+	if public, err := jwt.ParseRSAPublicKeyFromPEM(pemBytes); err == nil {
+		return &jwtKey{id: kid, method: jwt.SigningMethodRS256, verify: public}, nil
+	}
+	if public, err := jwt.ParseEdPublicKeyFromPEM(pemBytes); err == nil {
+		return &jwtKey{id: kid, method: jwt.SigningMethodEdDSA, verify: public}, nil
+	}
+	return nil, fmt.Errorf("JWT public key %q is neither RSA nor Ed25519", kid)
+}
+
+// keyThumbprint derives a stable key id from the public key
+func keyThumbprint(public crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode JWT public key: %v", err)
+	}
+	sum := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(sum[:12]), nil
+}
+
+// splitList splits a comma separated setting, skipping empty entries
+func splitList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// splitKeyEntry splits "kid:value" on the first colon
+func splitKeyEntry(entry string) (string, string, error) {
+	kid, value, ok := strings.Cut(entry, ":")
+	kid, value = strings.TrimSpace(kid), strings.TrimSpace(value)
+	if !ok || kid == "" || value == "" {
+		return "", "", fmt.Errorf("%q (want kid:value)", entry)
+	}
+	return kid, value, nil
+}
+
+// ActiveKeyID returns the id of the key signing new tokens
+func (ks *JWTKeySet) ActiveKeyID() string {
+	return ks.activeID
+}
+
+// Sign signs claims with the active key and sets the kid header
+func (ks *JWTKeySet) Sign(claims jwt.Claims) (string, error) {
+	active := ks.keys[ks.activeID]
+	token := jwt.NewWithClaims(active.method, claims)
+	token.Header["kid"] = active.id
+	return token.SignedString(active.sign)
+}
+
+// Keyfunc selects the verification key by the token's kid header. The token's alg must
+// match the key's algorithm, so a public key can never be used as an HMAC secret.
+func (ks *JWTKeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		// Issued before key ids: signed with JWT_SECRET, or the active key if that is gone
+		kid = legacyJWTKeyID
+		if _, ok := ks.keys[kid]; !ok {
+			kid = ks.activeID
+		}
+	}
+
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, ErrUnknownJWTKeyID
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+	}
+	return key.verify, nil
+}
+
+// JWKS returns the public keys (RS256/EdDSA) as a JSON Web Key Set; HMAC keys are never published
+func (ks *JWTKeySet) JWKS() map[string]interface{} {
+	keys := []map[string]interface{}{}
+	for _, key := range ks.keys {
+		switch public := key.verify.(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, map[string]interface{}{
+				"kty": "RSA",
+				"kid": key.id,
+				"use": "sig",
+				"alg": key.method.Alg(),
+				"n":   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			keys = append(keys, map[string]interface{}{
+				"kty": "OKP",
+				"crv": "Ed25519",
+				"kid": key.id,
+				"use": "sig",
+				"alg": key.method.Alg(),
+				"x":   base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+	return map[string]interface{}{"keys": keys}
+}
+
+// hmacSecret returns the secret other signers (share links, payment tokens) fall back to:
+// the active key when it is HMAC, otherwise the legacy or first HMAC key
+func (ks *JWTKeySet) hmacSecret() []byte {
+	for _, kid := range []string{ks.activeID, legacyJWTKeyID} {
+		if key, ok := ks.keys[kid]; ok {
+			if secret, ok := key.sign.([]byte); ok {
+				return secret
+			}
+		}
+	}
+	for _, key := range ks.keys {
+		if secret, ok := key.sign.([]byte); ok {
+			return secret
+		}
+	}
+	return nil
+}
+
+// fallbackSigningSecret is the secret used by other signers (share links, payment tokens)
+// when they have no dedicated secret configured
+func fallbackSigningSecret() []byte {
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	ks, err := JWTKeys()
+	if err != nil {
+		return nil
+	}
+	return ks.hmacSecret()
+}
+
+// CheckSigningSecrets verifies that share links and payment tokens have an HMAC secret,
+// which is not implied once tokens are signed with a private key
+func CheckSigningSecrets() error {
+	if fallbackSigningSecret() != nil {
+		return nil
+	}
+	for _, name := range []string{"SIGNED_URL_SECRET", "PAYMENT_TOKEN_SECRET"} {
+		if os.Getenv(name) == "" {
+			return fmt.Errorf("%s is required when no HMAC JWT key is configured", name)
+		}
+	}
+	return nil
+}
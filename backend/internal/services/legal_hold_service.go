@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+)
+
+// ErrLegalHold is returned when deleting a record that is under legal hold
+var ErrLegalHold = errors.New("record is under legal hold")
+
+// ErrHoldTargetNotFound is returned when the analysis or transaction to hold does not exist
+var ErrHoldTargetNotFound = errors.New("record not found")
+
+// LegalHoldService lets admins place and release dispute holds on analyses and transactions
+type LegalHoldService struct {
+	ctx   context.Context
+	legal repository.LegalRepo
+}
+
+// NewLegalHoldService creates a legal hold service on the given repository.
+// The zero value LegalHoldService{} uses the default repository.
+func NewLegalHoldService(legal repository.LegalRepo) *LegalHoldService {
+	return &LegalHoldService{legal: legal}
+}
+
+// WithContext returns a copy of the service bound to the request context
+func (ls *LegalHoldService) WithContext(ctx context.Context) *LegalHoldService {
+	return &LegalHoldService{ctx: ctx, legal: ls.legal}
+}
+
+// legalRepo returns the injected repository or the default one
+func (ls *LegalHoldService) legalRepo() repository.LegalRepo {
+	if ls.legal != nil {
+		return ls.legal
+	}
+	return repository.Default().Legal
+}
+
+// queryContext returns the service context (background when unset)
+func (ls *LegalHoldService) queryContext() context.Context {
+	if ls.ctx != nil {
+		return ls.ctx
+	}
+	return context.Background()
+}
+
+// SetAnalysisHold places (or updates) a hold on an analysis result
+func (ls *LegalHoldService) SetAnalysisHold(analysisID uint, reason string, until *time.Time, adminID uint) error {
+	return ls.setHold(&models.AnalysisResult{}, analysisID, reason, until, adminID)
+}
+
+// ReleaseAnalysisHold removes the hold from an analysis result
+func (ls *LegalHoldService) ReleaseAnalysisHold(analysisID uint) error {
+	return ls.releaseHold(&models.AnalysisResult{}, analysisID)
+}
+
+// SetTransactionHold places (or updates) a hold on a transaction
+func (ls *LegalHoldService) SetTransactionHold(transactionID uint, reason string, until *time.Time, adminID uint) error {
+	return ls.setHold(&models.Transaction{}, transactionID, reason, until, adminID)
+}
+
+// ReleaseTransactionHold removes the hold from a transaction
+func (ls *LegalHoldService) ReleaseTransactionHold(transactionID uint) error {
+	return ls.releaseHold(&models.Transaction{}, transactionID)
+}
+
+func (ls *LegalHoldService) setHold(model interface{}, id uint, reason string, until *time.Time, adminID uint) error {
+	if reason == "" {
+		return fmt.Errorf("hold reason is required")
+	}
+	if until != nil && !until.After(time.Now()) {
+		return fmt.Errorf("hold expiry must be in the future")
+	}
+
+	found, err := ls.legalRepo().SetHold(ls.queryContext(), model, id, reason, until, adminID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrHoldTargetNotFound
+	}
+	return nil
+}
+
+func (ls *LegalHoldService) releaseHold(model interface{}, id uint) error {
+	found, err := ls.legalRepo().ReleaseHold(ls.queryContext(), model, id, time.Now())
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrHoldTargetNotFound
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+)
+
+// ErrUnknownLegalVersion is returned when a consent names a document or version that is not current
+var ErrUnknownLegalVersion = errors.New("only the current version of a legal document can be accepted")
+
+// LegalDocuments returns the terms of service and privacy policy versions configured for this
+// deployment (LEGAL_TOS_* and LEGAL_PRIVACY_*: VERSION, EFFECTIVE_DATE as YYYY-MM-DD, URL)
+func LegalDocuments() []models.LegalDocument {
+	return []models.LegalDocument{
+		legalDocumentFromEnv(models.LegalDocumentTerms, "LEGAL_TOS"),
+		legalDocumentFromEnv(models.LegalDocumentPrivacy, "LEGAL_PRIVACY"),
+	}
+}
+
+func legalDocumentFromEnv(docType, prefix string) models.LegalDocument {
+	version := strings.TrimSpace(os.Getenv(prefix + "_VERSION"))
+	if version == "" {
+		version = "1.0"
+	}
+	doc := models.LegalDocument{
+		Type:    docType,
+		Version: version,
+		Major:   legalMajor(version),
+		URL:     strings.TrimSpace(os.Getenv(prefix + "_URL")),
+	}
+	if raw := strings.TrimSpace(os.Getenv(prefix + "_EFFECTIVE_DATE")); raw != "" {
+		if effective, err := time.Parse("2006-01-02", raw); err == nil {
+			doc.EffectiveDate = &effective
+		}
+	}
+	return doc
+}
+
+// legalMajor returns the major part of "2.1" (1 when it cannot be parsed)
+func legalMajor(version string) int {
+	major, err := strconv.Atoi(strings.SplitN(strings.TrimPrefix(version, "v"), ".", 2)[0])
+	if err != nil || major < 1 {
+		return 1
+	}
+	return major
+}
+
+// LegalService records and checks users' consent to the current legal documents
+type LegalService struct {
+	ctx   context.Context
+	legal repository.LegalRepo
+}
+
+// NewLegalService creates a legal service on the given repository.
+// The zero value LegalService{} uses the default repository.
+func NewLegalService(legal repository.LegalRepo) *LegalService {
+	return &LegalService{legal: legal}
+}
+
+// WithContext returns a copy of the service bound to the request context
+func (ls *LegalService) WithContext(ctx context.Context) *LegalService {
+	return &LegalService{ctx: ctx, legal: ls.legal}
+}
+
+// legalRepo returns the injected repository or the default one
+func (ls *LegalService) legalRepo() repository.LegalRepo {
+	if ls.legal != nil {
+		return ls.legal
+	}
+	return repository.Default().Legal
+}
+
+// queryContext returns the service context (background when unset)
+func (ls *LegalService) queryContext() context.Context {
+	if ls.ctx != nil {
+		return ls.ctx
+	}
+	return context.Background()
+}
+
+// Accept records consent to the given documents (type → version). Each version must be the current one.
+func (ls *LegalService) Accept(userID uint, accepted map[string]string, ipAddress, userAgent string) ([]models.LegalConsentStatus, error) {
+	if len(accepted) == 0 {
+		return nil, ErrUnknownLegalVersion
+	}
+
+	current := map[string]models.LegalDocument{}
+	for _, doc := range LegalDocuments() {
+		current[doc.Type] = doc
+	}
+	now := time.Now()
+	var consents []models.LegalConsent
+	for docType, version := range accepted {
+		doc, ok := current[docType]
+		if !ok || doc.Version != strings.TrimSpace(version) {
+			return nil, ErrUnknownLegalVersion
+		}
+		consents = append(consents, models.LegalConsent{
+			UserID:     userID,
+			Document:   doc.Type,
+			Version:    doc.Version,
+			Major:      doc.Major,
+			IPAddress:  ipAddress,
+			UserAgent:  truncateRunes(userAgent, 255),
+			AcceptedAt: now,
+		})
+	}
+	if err := ls.legalRepo().CreateConsents(ls.queryContext(), consents); err != nil {
+		return nil, err
+	}
+	return ls.Status(userID)
+}
+
+// Status returns the user's consent state for every current document. Users without a consent
+// record count as having accepted version 1 at registration, so only a major version above 1 blocks them.
+func (ls *LegalService) Status(userID uint) ([]models.LegalConsentStatus, error) {
+	now := time.Now()
+	var statuses []models.LegalConsentStatus
+	for _, doc := range LegalDocuments() {
+		status := models.LegalConsentStatus{Document: doc}
+		acceptedMajor := 1
+
+		latest, err := ls.legalRepo().LatestConsent(ls.queryContext(), userID, doc.Type)
+		if err != nil {
+			return nil, err
+		}
+		if latest != nil {
+			status.AcceptedVersion = latest.Version
+			status.AcceptedAt = &latest.AcceptedAt
+			status.UpToDate = latest.Version == doc.Version
+			acceptedMajor = latest.Major
+		}
+
+		// A new major version only blocks once it is in effect
+		inEffect := doc.EffectiveDate == nil || !now.Before(*doc.EffectiveDate)
+		status.RequiresReconsent = inEffect && !status.UpToDate && doc.Major > acceptedMajor
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// RequiresReconsent returns the documents the user has to accept again before analyzing
+func (ls *LegalService) RequiresReconsent(userID uint) ([]models.LegalDocument, error) {
+	statuses, err := ls.Status(userID)
+	if err != nil {
+		return nil, err
+	}
+	var pending []models.LegalDocument
+	for _, status := range statuses {
+		if status.RequiresReconsent {
+			pending = append(pending, status.Document)
+		}
+	}
+	return pending, nil
+}
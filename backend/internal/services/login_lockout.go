@@ -0,0 +1,342 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"back_wa/internal/fieldcrypt"
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidUnlockToken is returned for an unknown or expired account unlock link
+var ErrInvalidUnlockToken = errors.New("invalid or expired unlock link")
+
+// unlockTokenTTL is how long the link in the lockout email stays valid
+const unlockTokenTTL = 24 * time.Hour
+
+// LoginBlockedError is returned by Login while the account (or the client address) has to wait
+// before the next attempt: a short exponential backoff, or a lockout after too many failures
+type LoginBlockedError struct {
+	Until  time.Time
+	Locked bool // account locked, can also be lifted with the emailed unlock link
+	PerIP  bool // too many failures from the client address rather than on the account
+}
+
+func (e *LoginBlockedError) Error() string {
+	if e.Locked {
+		return "account temporarily locked after too many failed logins"
+	}
+	return "too many failed logins, try again later"
+}
+
+// RetryAfter returns the wait from now, at least one second
+func (e *LoginBlockedError) RetryAfter(now time.Time) time.Duration {
+	if wait := e.Until.Sub(now); wait > time.Second {
+		return wait.Round(time.Second)
+	}
+	return time.Second
+}
+
+// LoginLockoutPolicy configures the failed-login limits
+type LoginLockoutPolicy struct {
+	BackoffAfter     int           // LOGIN_BACKOFF_AFTER: failures before the backoff starts (1s, doubling)
+	MaxBackoff       time.Duration // LOGIN_BACKOFF_MAX_SECONDS
+	LockoutThreshold int           // LOGIN_LOCKOUT_THRESHOLD: consecutive failures that lock the account, 0 = never
+	LockoutDuration  time.Duration // LOGIN_LOCKOUT_MINUTES
+	IPMaxFailures    int           // LOGIN_IP_MAX_FAILURES per LOGIN_IP_WINDOW_MINUTES, 0 = no per-IP limit
+	IPWindow         time.Duration
+}
+
+// LoadLoginLockoutPolicy reads the policy from the environment
+func LoadLoginLockoutPolicy() LoginLockoutPolicy {
+	return LoginLockoutPolicy{
+		BackoffAfter:     getIntEnv("LOGIN_BACKOFF_AFTER", 3),
+		MaxBackoff:       time.Duration(getIntEnv("LOGIN_BACKOFF_MAX_SECONDS", 300)) * time.Second,
+		LockoutThreshold: getIntEnv("LOGIN_LOCKOUT_THRESHOLD", 10),
+		LockoutDuration:  time.Duration(getIntEnv("LOGIN_LOCKOUT_MINUTES", 30)) * time.Minute,
+		IPMaxFailures:    getIntEnv("LOGIN_IP_MAX_FAILURES", 20),
+		IPWindow:         time.Duration(getIntEnv("LOGIN_IP_WINDOW_MINUTES", 15)) * time.Minute,
+	}
+}
+
+// backoff returns the wait after the given number of consecutive failures
+func (p LoginLockoutPolicy) backoff(failures int) time.Duration {
+	if p.BackoffAfter <= 0 || failures < p.BackoffAfter {
+		return 0
+	}
+	shift := failures - p.BackoffAfter
+	if shift > 20 {
+		return p.MaxBackoff
+	}
+	wait := time.Second << shift
+	if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return wait
+}
+
+// LoginLockoutState is the failed-login state of an account shown to admins
+type LoginLockoutState struct {
+	UserID           uint       `json:"user_id"`
+	FailedLoginCount int        `json:"failed_login_count"`
+	Locked           bool       `json:"locked"`
+	LockedAt         *time.Time `json:"locked_at"`
+	BlockedUntil     *time.Time `json:"blocked_until"` // nil when the account can log in now
+}
+
+// LoginLockoutService tracks failed logins per account (in the users table) and per client
+// address (in memory, so with several instances each one counts on its own)
+type LoginLockoutService struct {
+	ctx     context.Context
+	policy  LoginLockoutPolicy
+	users   repository.UserRepo
+	tenants repository.TenantRepo
+}
+
+// NewLoginLockoutService creates a lockout service with the policy from the environment on the
+// given repositories; tenants brand the unlock email
+func NewLoginLockoutService(users repository.UserRepo, tenants repository.TenantRepo) *LoginLockoutService {
+	return &LoginLockoutService{policy: LoadLoginLockoutPolicy(), users: users, tenants: tenants}
+}
+
+// WithContext returns a copy of the service bound to the request context
+func (s *LoginLockoutService) WithContext(ctx context.Context) *LoginLockoutService {
+	return &LoginLockoutService{ctx: ctx, policy: s.policy, users: s.users, tenants: s.tenants}
+}
+
+// userRepo returns the injected user repository or the default one
+func (s *LoginLockoutService) userRepo() repository.UserRepo {
+	if s.users != nil {
+		return s.users
+	}
+	return repository.Default().Users
+}
+
+// tenantRepo returns the injected tenant repository or the default one
+func (s *LoginLockoutService) tenantRepo() repository.TenantRepo {
+	if s.tenants != nil {
+		return s.tenants
+	}
+	return repository.Default().Tenants
+}
+
+// queryContext returns the service context (background when unset)
+func (s *LoginLockoutService) queryContext() context.Context {
+	if s.ctx != nil {
+		return s.ctx
+	}
+	return context.Background()
+}
+
+// CheckIP returns a LoginBlockedError while the client address is over its failure limit
+func (s *LoginLockoutService) CheckIP(ip string, now time.Time) error {
+	if until, blocked := loginIPFailures.blockedUntil(ip, s.policy, now); blocked {
+		return &LoginBlockedError{Until: until, PerIP: true}
+	}
+	return nil
+}
+
+// Check returns a LoginBlockedError while the account is in backoff or locked
+func (s *LoginLockoutService) Check(user *models.User, now time.Time) error {
+	if user.LoginBlockedUntil != nil && user.LoginBlockedUntil.After(now) {
+		return &LoginBlockedError{Until: *user.LoginBlockedUntil, Locked: user.LockedAt != nil}
+	}
+	return nil
+}
+
+// RecordIPFailure counts a failed login from the client address, also for unknown emails
+func (s *LoginLockoutService) RecordIPFailure(ip string, now time.Time) {
+	loginIPFailures.add(ip, s.policy, now)
+}
+
+// RecordFailure counts a wrong password on the account and starts the backoff. Reaching the
+// lockout threshold locks the account, emails an unlock link and returns the LoginBlockedError.
+func (s *LoginLockoutService) RecordFailure(user *models.User, now time.Time) error {
+	// A lockout that ran out starts a fresh count
+	failures := user.FailedLoginCount
+	if user.LockedAt != nil {
+		failures = 0
+	}
+	failures++
+
+	updates := map[string]interface{}{
+		"failed_login_count":  failures,
+		"login_blocked_until": nil,
+		"locked_at":           nil,
+	}
+	var blocked *LoginBlockedError
+	var unlockToken string
+	if s.policy.LockoutThreshold > 0 && failures >= s.policy.LockoutThreshold {
+		until := now.Add(s.policy.LockoutDuration)
+		unlockToken = generateResetToken()
+		sealed, err := fieldcrypt.Seal("unlock_token", unlockToken)
+		if err != nil {
+			return err
+		}
+		updates["login_blocked_until"] = until
+		updates["locked_at"] = now
+		updates["unlock_token"] = sealed
+		updates["unlock_token_expires_at"] = now.Add(unlockTokenTTL)
+		blocked = &LoginBlockedError{Until: until, Locked: true}
+	} else if wait := s.policy.backoff(failures); wait > 0 {
+		updates["login_blocked_until"] = now.Add(wait)
+	}
+
+	if err := s.userRepo().UpdateColumns(s.queryContext(), user.ID, updates); err != nil {
+		return err
+	}
+	if blocked == nil {
+		return nil
+	}
+
+	slog.Warn(fmt.Sprintf("user %d locked after %d failed logins", user.ID, failures), "audit", true, "user_id", user.ID, "locked_until", blocked.Until)
+	if err := s.sendUnlockEmail(user, unlockToken, blocked.Until); err != nil {
+		slog.Warn("Failed to send account unlock email", "user_id", user.ID, "error", err)
+	}
+	return blocked
+}
+
+// RecordSuccess clears the failed-login count after a successful login
+func (s *LoginLockoutService) RecordSuccess(user *models.User) error {
+	if user.FailedLoginCount == 0 && user.LoginBlockedUntil == nil && user.LockedAt == nil {
+		return nil
+	}
+	return s.Reset(user.ID)
+}
+
+// Reset clears the failed-login count, backoff and lockout of an account
+func (s *LoginLockoutService) Reset(userID uint) error {
+	return s.userRepo().UpdateColumns(s.queryContext(), userID, clearedLockout())
+}
+
+// Unlock lifts the lockout of the account with the token from the lockout email
+func (s *LoginLockoutService) Unlock(email, token string) error {
+	users := s.userRepo()
+	// The token is stored encrypted, so it is compared after decryption
+	user, err := users.FindByPendingUnlockToken(s.queryContext(), email, time.Now())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidUnlockToken
+		}
+		return err
+	}
+	if !tokenMatches(user.UnlockToken, token) {
+		return ErrInvalidUnlockToken
+	}
+
+	if err := users.UpdateColumns(s.queryContext(), user.ID, clearedLockout()); err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("user %d unlocked by email link", user.ID), "audit", true, "user_id", user.ID)
+	return nil
+}
+
+// State returns the failed-login state of an account
+func (s *LoginLockoutService) State(userID uint, now time.Time) (*LoginLockoutState, error) {
+	user, err := s.userRepo().FindByID(s.queryContext(), userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAdminUserNotFound
+		}
+		return nil, err
+	}
+
+	state := &LoginLockoutState{UserID: user.ID, FailedLoginCount: user.FailedLoginCount}
+	if user.LoginBlockedUntil != nil && user.LoginBlockedUntil.After(now) {
+		state.BlockedUntil = user.LoginBlockedUntil
+		state.Locked = user.LockedAt != nil
+		state.LockedAt = user.LockedAt
+	}
+	return state, nil
+}
+
+// clearedLockout are the column values of an account without failed logins
+func clearedLockout() map[string]interface{} {
+	return map[string]interface{}{
+		"failed_login_count":      0,
+		"login_blocked_until":     nil,
+		"locked_at":               nil,
+		"unlock_token":            nil,
+		"unlock_token_expires_at": nil,
+	}
+}
+
+// sendUnlockEmail tells the user about the lockout with a link that lifts it early
+func (s *LoginLockoutService) sendUnlockEmail(user *models.User, token string, until time.Time) error {
+	var tenant *models.Tenant
+	if user.TenantID != nil {
+		if t, err := s.tenantRepo().FindByID(s.queryContext(), *user.TenantID); err == nil {
+			tenant = t
+		}
+	}
+	baseURL := DefaultBranding().FrontendBaseURL
+	if tenant != nil && tenant.FrontendBaseURL != "" {
+		baseURL = tenant.FrontendBaseURL
+	}
+
+	link := strings.TrimRight(baseURL, "/") + "/unlock-account?email=" + url.QueryEscape(user.Email) + "&token=" + token
+	subject := "Akun kamu dikunci sementara"
+	body := fmt.Sprintf(`<h2>%s</h2><p>Halo %s,</p><p>Terlalu banyak percobaan login gagal ke akun kamu, sehingga login dikunci sampai <strong>%s</strong>.</p>
+<p>Jika itu kamu, buka akun sekarang lewat tautan berikut (berlaku 24 jam):</p><p><a href="%s">Buka Kunci Akun</a></p>
+<p>Jika bukan kamu, sebaiknya segera ganti password.</p>`,
+		subject, html.EscapeString(user.Username), until.In(ScanScheduleLocation()).Format("02 Jan 2006 15:04"), html.EscapeString(link))
+	return EmailServiceFor(tenant).SendEmail(user.Email, subject, body)
+}
+
+// loginIPTracker keeps the failed-login times per client address over the policy window
+type loginIPTracker struct {
+	mu       sync.Mutex
+	failures map[string][]time.Time
+}
+
+var loginIPFailures = &loginIPTracker{failures: make(map[string][]time.Time)}
+
+// prune drops failures older than the window; the caller holds mu
+func (t *loginIPTracker) prune(window time.Duration, now time.Time) {
+	cutoff := now.Add(-window)
+	for ip, times := range t.failures {
+		for len(times) > 0 && !times[0].After(cutoff) {
+			times = times[1:]
+		}
+		if len(times) == 0 {
+			delete(t.failures, ip)
+		} else {
+			t.failures[ip] = times
+		}
+	}
+}
+
+func (t *loginIPTracker) add(ip string, policy LoginLockoutPolicy, now time.Time) {
+	if ip == "" || policy.IPMaxFailures <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prune(policy.IPWindow, now)
+	t.failures[ip] = append(t.failures[ip], now)
+}
+
+// blockedUntil reports whether ip reached the limit, and when the oldest counted failure leaves the window
+func (t *loginIPTracker) blockedUntil(ip string, policy LoginLockoutPolicy, now time.Time) (time.Time, bool) {
+	if ip == "" || policy.IPMaxFailures <= 0 {
+		return time.Time{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prune(policy.IPWindow, now)
+	times := t.failures[ip]
+	if len(times) < policy.IPMaxFailures {
+		return time.Time{}, false
+	}
+	return times[len(times)-policy.IPMaxFailures].Add(policy.IPWindow), true
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"back_wa/internal/contentscan"
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// MessageScanService manages the users' consent to sensitive content scanning of their messages
+type MessageScanService struct {
+	ctx   context.Context
+	chats repository.ChatRepo
+}
+
+// NewMessageScanService creates a message scan service on the given repository.
+// The zero value MessageScanService{} uses the default repository.
+func NewMessageScanService(chats repository.ChatRepo) *MessageScanService {
+	return &MessageScanService{chats: chats}
+}
+
+// WithContext returns a copy of the service bound to the request context
+func (ms *MessageScanService) WithContext(ctx context.Context) *MessageScanService {
+	return &MessageScanService{ctx: ctx, chats: ms.chats}
+}
+
+// chatRepo returns the injected chat repository or the default one
+func (ms *MessageScanService) chatRepo() repository.ChatRepo {
+	if ms.chats != nil {
+		return ms.chats
+	}
+	return repository.Default().Chats
+}
+
+// queryContext returns the service context (background when unset)
+func (ms *MessageScanService) queryContext() context.Context {
+	if ms.ctx != nil {
+		return ms.ctx
+	}
+	return context.Background()
+}
+
+// GetConsent returns the user's message scan consent, disabled when the user never gave one
+func (ms *MessageScanService) GetConsent(userID uint) (*models.MessageScanConsent, error) {
+	consent, err := ms.chatRepo().FindScanConsent(ms.queryContext(), userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.MessageScanConsent{UserID: userID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return consent, nil
+}
+
+// Enabled reports whether the user consents to message scanning
+func (ms *MessageScanService) Enabled(userID uint) (bool, error) {
+	consent, err := ms.GetConsent(userID)
+	if err != nil {
+		return false, err
+	}
+	return consent.Enabled, nil
+}
+
+// SetConsent grants or revokes the consent. Revoking also drops the sensitive counts collected so far.
+func (ms *MessageScanService) SetConsent(userID uint, enabled bool) (*models.MessageScanConsent, error) {
+	consent, err := ms.GetConsent(userID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	consent.Enabled = enabled
+	if enabled {
+		consent.ConsentedAt = &now
+		consent.RevokedAt = nil
+	} else {
+		consent.RevokedAt = &now
+	}
+
+	if err := ms.chatRepo().SaveScanConsent(ms.queryContext(), consent); err != nil {
+		return nil, err
+	}
+	return consent, nil
+}
+
+// MessageScanWindow is how far back and how many of the latest messages per chat are scanned
+type MessageScanWindow struct {
+	MaxAge     time.Duration
+	MaxPerChat int
+}
+
+// ActiveMessageScanWindow reads MESSAGE_SCAN_DAYS (default 90) and MESSAGE_SCAN_MAX_PER_CHAT (default 500)
+func ActiveMessageScanWindow() MessageScanWindow {
+	return MessageScanWindow{
+		MaxAge:     time.Duration(getIntEnv("MESSAGE_SCAN_DAYS", 90)) * 24 * time.Hour,
+		MaxPerChat: getIntEnv("MESSAGE_SCAN_MAX_PER_CHAT", 500),
+	}
+}
+
+var (
+	sensitiveRulesOnce sync.Once
+	sensitiveRules     *contentscan.Ruleset
+)
+
+// SensitiveContentRules returns the ruleset messages are classified with: the JSON file in
+// SENSITIVE_CONTENT_RULES_FILE, or contentscan.DefaultRules when it is unset or invalid.
+// Nil when no ruleset could be compiled.
+func SensitiveContentRules() *contentscan.Ruleset {
+	sensitiveRulesOnce.Do(func() {
+		if path := os.Getenv("SENSITIVE_CONTENT_RULES_FILE"); path != "" {
+			rules, err := contentscan.LoadRuleset(path)
+			if err == nil {
+				slog.Info("Loaded sensitive content rules", "path", path, "categories", rules.Categories())
+				sensitiveRules = rules
+				return
+			}
+			slog.Error("Failed to load sensitive content rules, using the built-in rules", "path", path, "error", err)
+		}
+		rules, err := contentscan.NewRuleset(contentscan.DefaultRules)
+		if err != nil {
+			// A nil ruleset matches nothing; analyses fall back to the estimate
+			slog.Error("Invalid built-in sensitive content rules", "error", err)
+		}
+		sensitiveRules = rules
+	})
+	return sensitiveRules
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"back_wa/internal/models"
+)
+
+// midtransInvoiceHours matches the 24 hour Xendit invoice duration
+const midtransInvoiceHours = 24
+
+type MidtransService struct {
+	SnapURL   string
+	APIURL    string
+	ServerKey string
+}
+
+// NewMidtransService configures Snap from MIDTRANS_SERVER_KEY; MIDTRANS_IS_PRODUCTION=true switches
+// from the sandbox to the production endpoints
+func NewMidtransService() *MidtransService {
+	snapURL, apiURL := "https://app.sandbox.midtrans.com", "https://api.sandbox.midtrans.com"
+	if strings.EqualFold(os.Getenv("MIDTRANS_IS_PRODUCTION"), "true") {
+		snapURL, apiURL = "https://app.midtrans.com", "https://api.midtrans.com"
+	}
+	if v := os.Getenv("MIDTRANS_SNAP_URL"); v != "" {
+		snapURL = v
+	}
+	if v := os.Getenv("MIDTRANS_API_URL"); v != "" {
+		apiURL = v
+	}
+
+	return &MidtransService{
+		SnapURL:   strings.TrimRight(snapURL, "/"),
+		APIURL:    strings.TrimRight(apiURL, "/"),
+		ServerKey: os.Getenv("MIDTRANS_SERVER_KEY"),
+	}
+}
+
+func (ms *MidtransService) Name() string { return PaymentGatewayMidtrans }
+
+// CreateCheckout creates a Snap transaction; its token is stored as the invoice ID
+func (ms *MidtransService) CreateCheckout(req models.GatewayCheckoutRequest) (*models.GatewayCheckout, error) {
+	if ms.ServerKey == "" {
+		return nil, fmt.Errorf("midtrans server key is not configured")
+	}
+
+	amount := int64(math.Round(req.Amount)) // IDR has no minor unit on Midtrans
+	snapReq := models.MidtransSnapRequest{
+		TransactionDetails: models.MidtransTransactionDetails{OrderID: req.ExternalID, GrossAmount: amount},
+		CustomerDetails:    models.MidtransCustomerDetails{FirstName: "Customer", Email: req.Email},
+		ItemDetails: []models.MidtransItem{
+			{ID: "cekwa", Price: amount, Quantity: 1, Name: truncateRunes(req.Description, 50), Category: req.Description},
+		},
+		Expiry: models.MidtransExpiry{Unit: "hours", Duration: midtransInvoiceHours},
+	}
+	if req.SuccessRedirectURL != "" {
+		snapReq.Callbacks = &models.MidtransCallbacks{Finish: req.SuccessRedirectURL, Error: req.FailureRedirectURL}
+	}
+
+	jsonData, err := json.Marshal(snapReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	url := fmt.Sprintf("%s/snap/v1/transactions", ms.SnapURL)
+	slog.Debug(fmt.Sprintf("Creating Midtrans Snap transaction at: %s", url))
+	body, status, err := ms.do(http.MethodPost, url, jsonData)
+	if err != nil {
+		return nil, err
+	}
+	slog.Debug(fmt.Sprintf("Midtrans response status: %d", status), "status", status)
+
+	var snapResp models.MidtransSnapResponse
+	if err := json.Unmarshal(body, &snapResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal Midtrans response: %v", err)
+	}
+	if status != http.StatusCreated && status != http.StatusOK || snapResp.Token == "" {
+		return nil, fmt.Errorf("midtrans API error (status %d): %s", status, string(body))
+	}
+
+	slog.Info(fmt.Sprintf("Midtrans Snap transaction created for order %s", req.ExternalID), "external_id", req.ExternalID)
+	return &models.GatewayCheckout{
+		ID:         snapResp.Token,
+		URL:        snapResp.RedirectURL,
+		Status:     "pending",
+		ExpiryDate: time.Now().Add(midtransInvoiceHours * time.Hour).UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// CheckoutStatus reads the order from the Midtrans status API. An order the customer has not
+// opened yet is unknown to Midtrans (404) and still pending.
+func (ms *MidtransService) CheckoutStatus(transaction *models.Transaction) (*models.GatewayCheckout, error) {
+	if ms.ServerKey == "" {
+		return nil, fmt.Errorf("midtrans server key is not configured")
+	}
+
+	url := fmt.Sprintf("%s/v2/%s/status", ms.APIURL, transaction.ExternalID)
+	body, status, err := ms.do(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("midtrans API error: %s", string(body))
+	}
+
+	var notification models.MidtransNotification
+	if err := json.Unmarshal(body, &notification); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+	checkout := &models.GatewayCheckout{
+		ID:             transaction.InvoiceID,
+		URL:            transaction.InvoiceURL,
+		Status:         "pending",
+		PaymentChannel: notification.PaymentType,
+		ExpiryDate:     transaction.InvoiceExpiry,
+	}
+	// The status API answers 200 with status_code 404 for orders without a payment attempt
+	if notification.StatusCode != "404" {
+		checkout.Status = MidtransStatus(notification.TransactionStatus, notification.FraudStatus)
+	}
+	return checkout, nil
+}
+
+// VerifyNotification checks signature_key = SHA512(order_id + status_code + gross_amount + server key)
+func (ms *MidtransService) VerifyNotification(n models.MidtransNotification) bool {
+	if ms.ServerKey == "" || n.SignatureKey == "" {
+		return false
+	}
+	sum := sha512.Sum512([]byte(n.OrderID + n.StatusCode + n.GrossAmount + ms.ServerKey))
+	expected := hex.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(strings.ToLower(n.SignatureKey)), []byte(expected)) == 1
+}
+
+// MidtransStatus maps a Midtrans transaction_status (and fraud_status for card captures)
+// to the statuses UpdateTransactionStatus understands
+func MidtransStatus(transactionStatus, fraudStatus string) string {
+	switch strings.ToLower(transactionStatus) {
+	case "settlement":
+		return "paid"
+	case "capture":
+		// Card payments flagged by fraud detection wait for a manual decision
+		if strings.EqualFold(fraudStatus, "challenge") {
+			return "pending"
+		}
+		if strings.EqualFold(fraudStatus, "deny") {
+			return "failed"
+		}
+		return "paid"
+	case "deny", "cancel", "failure":
+		return "failed"
+	case "expire":
+		return "expired"
+	case "refund", "partial_refund":
+		return "refunded"
+	default:
+		return "pending"
+	}
+}
+
+// do sends an authenticated request to Midtrans and returns the body and status code
+func (ms *MidtransService) do(method, url string, payload []byte) ([]byte, int, error) {
+	var reader io.Reader
+	if payload != nil {
+		reader = bytes.NewBuffer(payload)
+	}
+	httpReq, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create request: %v", err)
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if payload != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	httpReq.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(ms.ServerKey+":")))
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to send request to Midtrans: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read Midtrans response: %v", err)
+	}
+	return body, resp.StatusCode, nil
+}
+
+// truncateRunes shortens s to at most n characters
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+)
+
+// NotificationService stores in-app notifications fed by domain events
+type NotificationService struct {
+	ctx           context.Context
+	notifications repository.NotificationRepo
+	pushes        repository.PushRepo
+}
+
+// NewNotificationService creates a notification service on the given repositories; pushes mirror
+// notifications to the user's devices. The zero value NotificationService{} uses the default repositories.
+func NewNotificationService(notifications repository.NotificationRepo, pushes repository.PushRepo) *NotificationService {
+	return &NotificationService{notifications: notifications, pushes: pushes}
+}
+
+// WithContext returns a copy of the service bound to the request context
+func (ns *NotificationService) WithContext(ctx context.Context) *NotificationService {
+	return &NotificationService{ctx: ctx, notifications: ns.notifications, pushes: ns.pushes}
+}
+
+// notificationRepo returns the injected notification repository or the default one
+func (ns *NotificationService) notificationRepo() repository.NotificationRepo {
+	if ns.notifications != nil {
+		return ns.notifications
+	}
+	return repository.Default().Notifications
+}
+
+// queryContext returns the service context (background when unset)
+func (ns *NotificationService) queryContext() context.Context {
+	if ns.ctx != nil {
+		return ns.ctx
+	}
+	return context.Background()
+}
+
+// Notify stores a notification for the user; data is serialized as JSON
+func (ns *NotificationService) Notify(userID uint, notificationType, title, message string, data map[string]interface{}) error {
+	notification := models.Notification{
+		UserID:  userID,
+		Type:    notificationType,
+		Title:   title,
+		Message: message,
+	}
+	if len(data) > 0 {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		notification.Data = string(raw)
+	}
+	return ns.notificationRepo().Create(ns.queryContext(), &notification)
+}
+
+// NotifyAsync is the fire-and-forget variant used from event paths that must not block or fail
+func (ns *NotificationService) NotifyAsync(userID uint, notificationType, title, message string, data map[string]interface{}) {
+	ctx := detachedContext(ns.ctx)
+	ns = ns.WithContext(ctx)
+	go func() {
+		if err := ns.Notify(userID, notificationType, title, message, data); err != nil {
+			slog.Warn(fmt.Sprintf("Failed to store %s notification", notificationType), "user_id", userID, "error", err)
+		}
+
+		// Mirror to the user's devices (subject to their push preferences)
+		pushData := make(map[string]string, len(data))
+		for k, v := range data {
+			pushData[k] = fmt.Sprint(v)
+		}
+		NewPushService(ns.pushes).WithContext(ctx).SendToUser(userID, notificationType, PushMessage{Title: title, Body: message, Data: pushData})
+	}()
+}
+
+// detachedContext keeps the values of ctx (tenant scope, request logger) but not its cancellation,
+// for writes that finish after the request has been answered
+func detachedContext(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return context.WithoutCancel(ctx)
+}
+
+// List returns the user's newest notifications
+func (ns *NotificationService) List(userID uint, unreadOnly bool, limit int) ([]models.Notification, error) {
+	return ns.notificationRepo().ListByUser(ns.queryContext(), userID, unreadOnly, limit)
+}
+
+// UnreadCount returns the number of unread notifications for the bell badge
+func (ns *NotificationService) UnreadCount(userID uint) (int64, error) {
+	return ns.notificationRepo().CountUnread(ns.queryContext(), userID)
+}
+
+// MarkRead marks the given notifications (or all when ids is empty) as read
+func (ns *NotificationService) MarkRead(userID uint, ids []uint) (int64, error) {
+	return ns.notificationRepo().MarkRead(ns.queryContext(), userID, ids, time.Now())
+}
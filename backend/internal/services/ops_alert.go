@@ -0,0 +1,71 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// OpsAlerter posts operational alerts to the ops chat webhook (Slack-compatible {"text": ...} payload).
+// Each alert key is rate limited by a cooldown so a sustained breach doesn't flood the channel.
+type OpsAlerter struct {
+	webhookURL string
+	cooldown   time.Duration
+	client     *http.Client
+
+	mu       sync.Mutex
+	lastSent map[string]time.Time
+}
+
+var (
+	opsAlerterOnce    sync.Once
+	defaultOpsAlerter *OpsAlerter
+)
+
+// NewOpsAlerter returns the shared ops alerter configured from OPS_ALERT_WEBHOOK_URL
+func NewOpsAlerter() *OpsAlerter {
+	opsAlerterOnce.Do(func() {
+		defaultOpsAlerter = &OpsAlerter{
+			webhookURL: os.Getenv("OPS_ALERT_WEBHOOK_URL"),
+			cooldown:   time.Duration(getIntEnv("OPS_ALERT_COOLDOWN_MINUTES", 30)) * time.Minute,
+			client:     &http.Client{Timeout: 10 * time.Second},
+			lastSent:   make(map[string]time.Time),
+		}
+	})
+	return defaultOpsAlerter
+}
+
+// Alert sends message under key unless the same key fired within the cooldown.
+// Delivery is asynchronous; without a webhook the alert is only logged.
+func (oa *OpsAlerter) Alert(key, message string) {
+	oa.mu.Lock()
+	if last, ok := oa.lastSent[key]; ok && time.Since(last) < oa.cooldown {
+		oa.mu.Unlock()
+		return
+	}
+	oa.lastSent[key] = time.Now()
+	oa.mu.Unlock()
+
+	slog.Error(fmt.Sprintf("OPS ALERT [%s]: %s", key, message))
+	if oa.webhookURL == "" {
+		return
+	}
+
+	go func() {
+		body, _ := json.Marshal(map[string]string{"text": "🚨 [" + key + "] " + message})
+		resp, err := oa.client.Post(oa.webhookURL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			slog.Warn(fmt.Sprintf("Failed to send ops alert %s", key), "error", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			slog.Warn(fmt.Sprintf("Ops alert %s rejected with status %d", key, resp.StatusCode))
+		}
+	}()
+}
@@ -0,0 +1,257 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OTP delivery channels
+const (
+	OTPChannelEmail    = "email"
+	OTPChannelWhatsApp = "whatsapp"
+	OTPChannelSMS      = "sms"
+)
+
+// ErrOTPRateLimited is returned when the recipient already got too many codes through the channel this hour
+var ErrOTPRateLimited = errors.New("too many OTP requests, try again later")
+
+// ErrInvalidOTPChannel is returned for a channel other than email, whatsapp or sms
+var ErrInvalidOTPChannel = errors.New("OTP channel must be email, whatsapp or sms")
+
+// OTPRecipient is where a code can be delivered; PhoneNumber is empty when unknown (registration)
+type OTPRecipient struct {
+	Email       string
+	PhoneNumber string
+}
+
+// OTPSender delivers a code through one channel
+type OTPSender interface {
+	SendOTP(ctx context.Context, to OTPRecipient, code string, expiryMinutes int) error
+}
+
+// ValidOTPChannel reports whether channel names a delivery channel
+func ValidOTPChannel(channel string) bool {
+	return channel == OTPChannelEmail || channel == OTPChannelWhatsApp || channel == OTPChannelSMS
+}
+
+// DefaultOTPChannel returns OTP_CHANNEL (default email), used when the user has no preference
+func DefaultOTPChannel() string {
+	channel := strings.ToLower(getenv("OTP_CHANNEL", OTPChannelEmail))
+	if !ValidOTPChannel(channel) {
+		return OTPChannelEmail
+	}
+	return channel
+}
+
+var (
+	otpSendersMu sync.RWMutex
+	otpSenders   = map[string]OTPSender{}
+)
+
+// RegisterOTPSender makes a phone channel available. The whatsapp package registers the
+// dedicated system client here once it is logged in.
+func RegisterOTPSender(channel string, sender OTPSender) {
+	otpSendersMu.Lock()
+	defer otpSendersMu.Unlock()
+	if sender == nil {
+		delete(otpSenders, channel)
+		return
+	}
+	otpSenders[channel] = sender
+}
+
+// phoneOTPSender returns the sender of a phone channel, nil when it is not configured
+func phoneOTPSender(channel string) OTPSender {
+	if channel == OTPChannelSMS {
+		// Credentials are read per use so rotated secrets apply without a restart
+		if sender := newSMSOTPSender(); sender != nil {
+			return sender
+		}
+	}
+	otpSendersMu.RLock()
+	defer otpSendersMu.RUnlock()
+	return otpSenders[channel]
+}
+
+// AvailableOTPChannels lists the channels codes can currently be sent through
+func AvailableOTPChannels() []string {
+	channels := []string{OTPChannelEmail}
+	for _, channel := range []string{OTPChannelWhatsApp, OTPChannelSMS} {
+		if phoneOTPSender(channel) != nil {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+// OTPMessage is the text of a code sent by WhatsApp or SMS
+func OTPMessage(code string, expiryMinutes int) string {
+	return fmt.Sprintf("Kode OTP %s kamu: %s. Berlaku %d menit. Jangan berikan kode ini kepada siapa pun.",
+		DefaultBranding().BrandName, code, expiryMinutes)
+}
+
+// emailOTPSender delivers codes through the (tenant's) email sender
+type emailOTPSender struct {
+	email EmailServiceInterface
+}
+
+func (s emailOTPSender) SendOTP(ctx context.Context, to OTPRecipient, code string, expiryMinutes int) error {
+	if to.Email == "" {
+		return fmt.Errorf("no email address")
+	}
+	return s.email.SendOTPEmail(to.Email, code, expiryMinutes)
+}
+
+var smsHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// smsOTPSender sends codes as SMS through Twilio or Vonage (OTP_SMS_PROVIDER)
+type smsOTPSender struct {
+	provider string
+	user     string // Twilio account SID or Vonage API key
+	secret   string // Twilio auth token or Vonage API secret
+	from     string
+}
+
+// newSMSOTPSender returns the configured SMS provider, nil when OTP_SMS_PROVIDER or its credentials are not set
+func newSMSOTPSender() *smsOTPSender {
+	var sender smsOTPSender
+	switch strings.ToLower(os.Getenv("OTP_SMS_PROVIDER")) {
+	case "twilio":
+		sender = smsOTPSender{provider: "twilio", user: os.Getenv("TWILIO_ACCOUNT_SID"), secret: os.Getenv("TWILIO_AUTH_TOKEN"), from: os.Getenv("TWILIO_FROM")}
+	case "vonage":
+		sender = smsOTPSender{provider: "vonage", user: os.Getenv("VONAGE_API_KEY"), secret: os.Getenv("VONAGE_API_SECRET"), from: os.Getenv("VONAGE_FROM")}
+	default:
+		return nil
+	}
+	if sender.user == "" || sender.secret == "" || sender.from == "" {
+		return nil
+	}
+	return &sender
+}
+
+func (s *smsOTPSender) SendOTP(ctx context.Context, to OTPRecipient, code string, expiryMinutes int) error {
+	phone := NormalizePhoneNumber(to.PhoneNumber)
+	if phone == "" {
+		return fmt.Errorf("no phone number")
+	}
+	text := OTPMessage(code, expiryMinutes)
+	if s.provider == "twilio" {
+		return s.sendTwilio(ctx, "+"+phone, text)
+	}
+	return s.sendVonage(ctx, phone, text)
+}
+
+// sendTwilio sends through the Twilio Messages API
+func (s *smsOTPSender) sendTwilio(ctx context.Context, to, text string) error {
+	form := url.Values{"To": {to}, "From": {s.from}, "Body": {text}}
+	endpoint := fmt.Sprintf("https://api.twilio.com/2010-04-01/Accounts/%s/Messages.json", url.PathEscape(s.user))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(s.user, s.secret)
+
+	resp, err := smsHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var body struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return fmt.Errorf("twilio returned %d: %s", resp.StatusCode, body.Message)
+	}
+	return nil
+}
+
+// sendVonage sends through the Vonage SMS API, which reports failures per message with HTTP 200
+func (s *smsOTPSender) sendVonage(ctx context.Context, to, text string) error {
+	form := url.Values{"api_key": {s.user}, "api_secret": {s.secret}, "from": {s.from}, "to": {to}, "text": {text}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://rest.nexmo.com/sms/json", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := smsHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("vonage returned %d", resp.StatusCode)
+	}
+	var body struct {
+		Messages []struct {
+			Status    string `json:"status"`
+			ErrorText string `json:"error-text"`
+		} `json:"messages"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return err
+	}
+	for _, message := range body.Messages {
+		if message.Status != "0" {
+			return fmt.Errorf("vonage status %s: %s", message.Status, message.ErrorText)
+		}
+	}
+	return nil
+}
+
+// otpRateLimits are the default codes per recipient and hour; SMS costs money per message
+var otpRateLimits = map[string]int{
+	OTPChannelEmail:    10,
+	OTPChannelWhatsApp: 5,
+	OTPChannelSMS:      3,
+}
+
+// otpRateLimiter counts the codes sent per channel and recipient over the last hour. It is
+// kept in memory, so with several instances each one applies the limit on its own.
+type otpRateLimiter struct {
+	mu   sync.Mutex
+	sent map[string][]time.Time
+}
+
+var otpLimiter = &otpRateLimiter{sent: make(map[string][]time.Time)}
+
+// Allow records a code for the recipient unless OTP_RATE_LIMIT_<CHANNEL>_PER_HOUR (0 = unlimited)
+// codes were already sent through the channel in the last hour
+func (l *otpRateLimiter) Allow(channel, recipient string, now time.Time) bool {
+	limit := getIntEnv("OTP_RATE_LIMIT_"+strings.ToUpper(channel)+"_PER_HOUR", otpRateLimits[channel])
+	if limit <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cutoff := now.Add(-time.Hour)
+	for key, times := range l.sent {
+		for len(times) > 0 && !times[0].After(cutoff) {
+			times = times[1:]
+		}
+		if len(times) == 0 {
+			delete(l.sent, key)
+		} else {
+			l.sent[key] = times
+		}
+	}
+
+	key := channel + ":" + strings.ToLower(recipient)
+	if len(l.sent[key]) >= limit {
+		return false
+	}
+	l.sent[key] = append(l.sent[key], now)
+	return true
+}
@@ -1,89 +1,197 @@
 package services
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/binary"
 	"fmt"
+	"log/slog"
 	"os"
 	"time"
 
-	"back_wa/internal/database"
-	"back_wa/internal/models"
+	"back_wa/internal/fieldcrypt"
+	"back_wa/internal/logging"
+	"back_wa/internal/repository"
+
+	"gorm.io/gorm"
 )
 
 type OTPService struct {
-	email EmailServiceInterface
+	email   EmailServiceInterface
+	channel string // requested delivery channel, empty = the user's preference or OTP_CHANNEL
+	ctx     context.Context
+	users   repository.UserRepo
 }
 
 type EmailServiceInterface interface {
+	SendEmail(to string, subject string, htmlBody string) error
 	SendOTPEmail(to string, code string, expiryMinutes int) error
 	SendPasswordResetEmail(to string, token string, expiryMinutes int) error
+	SendAnalysisReport(to string, report AnalysisReport) error
 }
 
-func NewOTPService() *OTPService {
+// NewOTPService creates an OTP service on the given user repository.
+// The zero value OTPService{} uses the default repository.
+func NewOTPService(users repository.UserRepo) *OTPService {
 	// Check if email credentials are configured
 	username := os.Getenv("EMAIL_USERNAME")
 	password := os.Getenv("EMAIL_PASSWORD")
 
 	var emailService EmailServiceInterface
 	if username == "" || password == "" {
-		fmt.Println("Email credentials not configured, using development mode")
+		slog.Info("Email credentials not configured, using development mode")
 		emailService = &DevEmailService{}
 	} else {
 		emailService = &EmailService{}
 	}
 
-	return &OTPService{email: emailService}
+	return &OTPService{email: emailService, users: users}
+}
+
+// WithEmail returns a copy of the OTP service that delivers through the given email sender
+func (s *OTPService) WithEmail(email EmailServiceInterface) *OTPService {
+	return &OTPService{email: email, channel: s.channel, ctx: s.ctx, users: s.users}
+}
+
+// WithChannel returns a copy of the OTP service that delivers through the given channel when possible
+func (s *OTPService) WithChannel(channel string) *OTPService {
+	return &OTPService{email: s.email, channel: channel, ctx: s.ctx, users: s.users}
+}
+
+// WithContext returns a copy of the OTP service bound to the request context
+func (s *OTPService) WithContext(ctx context.Context) *OTPService {
+	return &OTPService{email: s.email, channel: s.channel, ctx: ctx, users: s.users}
+}
+
+// userRepo returns the injected user repository or the default one
+func (s *OTPService) userRepo() repository.UserRepo {
+	if s.users != nil {
+		return s.users
+	}
+	return repository.Default().Users
+}
+
+// queryContext returns the service context (background when unset)
+func (s *OTPService) queryContext() context.Context {
+	if s.ctx != nil {
+		return s.ctx
+	}
+	return context.Background()
 }
 
+// GenerateAndSend stores a new code for the user and delivers it through the requested channel,
+// the user's preference or OTP_CHANNEL, falling back to email when the phone channel is not
+// available or fails. Returns ErrOTPRateLimited when the channel's hourly limit is reached.
 func (s *OTPService) GenerateAndSend(email string, userID uint) (string, error) {
+	to := OTPRecipient{Email: email}
+	preferred := s.channel
+	if userID > 0 {
+		if user, err := s.userRepo().FindByID(s.queryContext(), userID); err != nil {
+			logging.FromContext(s.ctx).Warn("Failed to load OTP preferences, sending by email", "user_id", userID, "error", err)
+		} else {
+			to.PhoneNumber = user.PhoneNumber
+			if preferred == "" {
+				preferred = user.OTPChannel
+			}
+		}
+	}
+	channel, sender := s.resolveChannel(preferred, to)
+
+	recipient := to.Email
+	if channel != OTPChannelEmail {
+		recipient = NormalizePhoneNumber(to.PhoneNumber)
+	}
+	if !otpLimiter.Allow(channel, recipient, time.Now()) {
+		logging.FromContext(s.ctx).Warn("OTP rate limit reached", "channel", channel, "user_id", userID)
+		return "", ErrOTPRateLimited
+	}
+
 	code := generateNumericCode(getIntEnv("OTP_LENGTH", 6))
 	expiry := time.Now().Add(time.Duration(getIntEnv("OTP_EXPIRY_MINUTES", 10)) * time.Minute)
 
 	// For registration flow (userID = 0), we don't update user record
 	// For existing users, update user with new OTP
 	if userID > 0 {
-		db := database.GetDB()
-		if err := db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
-			"otp_code":       code,
+		sealed, err := fieldcrypt.Seal("otp_code", code)
+		if err != nil {
+			return "", err
+		}
+		if err := s.userRepo().Update(s.queryContext(), userID, map[string]interface{}{
+			"otp_code":       sealed,
 			"otp_expires_at": expiry,
-		}).Error; err != nil {
+		}); err != nil {
 			return "", err
 		}
 	}
 
+	ctx := s.queryContext()
+	expiryMinutes := getIntEnv("OTP_EXPIRY_MINUTES", 10)
+	if channel != OTPChannelEmail {
+		err := sender.SendOTP(ctx, to, code, expiryMinutes)
+		if err == nil {
+			logging.FromContext(s.ctx).Info(fmt.Sprintf("OTP sent by %s", channel), "user_id", userID)
+			return code, nil
+		}
+		logging.FromContext(s.ctx).Warn(fmt.Sprintf("Failed to send OTP by %s, falling back to email", channel), "user_id", userID, "error", err)
+	}
+
 	// Try to send email, but don't fail if email sending fails
-	if err := s.email.SendOTPEmail(email, code, int(getIntEnv("OTP_EXPIRY_MINUTES", 10))); err != nil {
+	if err := s.email.SendOTPEmail(email, code, expiryMinutes); err != nil {
 		// Log the error but don't return it, so OTP is still saved in database
-		fmt.Printf("Failed to send OTP email to %s: %v\n", email, err)
+		logging.FromContext(s.ctx).Error(fmt.Sprintf("Failed to send OTP email to %s", email), "error", err)
 		// In development, you might want to print the OTP to console
-		fmt.Printf("DEVELOPMENT: OTP for %s is: %s\n", email, code)
+		logging.FromContext(s.ctx).Debug(fmt.Sprintf("OTP for %s is: %s", email, code))
 	} else {
-		fmt.Printf("OTP email sent successfully to %s\n", email)
+		logging.FromContext(s.ctx).Info(fmt.Sprintf("OTP email sent successfully to %s", email))
 	}
 
 	return code, nil
 }
 
+// resolveChannel returns the channel to use and its sender; phone channels need a phone number
+// and a configured sender, otherwise the code goes by email
+func (s *OTPService) resolveChannel(preferred string, to OTPRecipient) (string, OTPSender) {
+	channel := preferred
+	if !ValidOTPChannel(channel) {
+		channel = DefaultOTPChannel()
+	}
+	if channel != OTPChannelEmail && to.PhoneNumber != "" {
+		if sender := phoneOTPSender(channel); sender != nil {
+			return channel, sender
+		}
+	}
+	return OTPChannelEmail, emailOTPSender{email: s.email}
+}
+
+// SetPreferredChannel stores the user's OTP channel; an empty channel goes back to OTP_CHANNEL
+func (s *OTPService) SetPreferredChannel(userID uint, channel string) error {
+	if channel != "" && !ValidOTPChannel(channel) {
+		return ErrInvalidOTPChannel
+	}
+	return s.userRepo().Update(s.queryContext(), userID, map[string]interface{}{"otp_channel": channel})
+}
+
 func (s *OTPService) Validate(email string, code string) (bool, error) {
-	db := database.GetDB()
-	var user models.User
+	users := s.userRepo()
 
-	// Find user by email and check OTP
-	if err := db.Where("email = ? AND otp_code = ? AND otp_expires_at > ?",
-		email, code, time.Now()).First(&user).Error; err != nil {
+	// Find user by email and check OTP (stored encrypted, so compared after decryption)
+	user, err := users.FindByPendingOTP(s.queryContext(), email, time.Now())
+	if err != nil {
 		// For registration flow, user might not exist yet, so just return false
 		return false, err
 	}
+	if !tokenMatches(user.OTPCode, code) {
+		return false, gorm.ErrRecordNotFound
+	}
 
 	// Mark email as verified and clear OTP
 	now := time.Now()
-	if err := db.Model(&user).Updates(map[string]interface{}{
+	if err := users.Update(s.queryContext(), user.ID, map[string]interface{}{
 		"email_verified":    true,
 		"email_verified_at": &now,
 		"otp_code":          nil,
 		"otp_expires_at":    nil,
-	}).Error; err != nil {
+	}); err != nil {
 		return false, err
 	}
 
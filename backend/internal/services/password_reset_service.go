@@ -1,25 +1,33 @@
 package services
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/hex"
-	"fmt"
+	"log/slog"
 	"os"
 	"time"
 
-	"back_wa/internal/database"
-	"back_wa/internal/models"
+	"back_wa/internal/fieldcrypt"
+	"back_wa/internal/repository"
 
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type PasswordResetService struct {
 	email interface {
 		SendPasswordResetEmail(to string, token string, expiryMinutes int) error
 	}
+	ctx     context.Context
+	users   repository.UserRepo
+	tenants repository.TenantRepo
 }
 
-func NewPasswordResetService() *PasswordResetService {
+// NewPasswordResetService creates a password reset service on the given repositories; tenants
+// brand the emails of the lockout a reset lifts. The zero value uses the default repositories.
+func NewPasswordResetService(users repository.UserRepo, tenants repository.TenantRepo) *PasswordResetService {
 	// Check if email credentials are configured
 	username := os.Getenv("EMAIL_USERNAME")
 	password := os.Getenv("EMAIL_PASSWORD")
@@ -28,13 +36,34 @@ func NewPasswordResetService() *PasswordResetService {
 		SendPasswordResetEmail(to string, token string, expiryMinutes int) error
 	}
 	if username == "" || password == "" {
-		fmt.Println("Email credentials not configured, using development mode")
+		slog.Info("Email credentials not configured, using development mode")
 		emailService = &DevEmailService{}
 	} else {
 		emailService = &EmailService{}
 	}
 
-	return &PasswordResetService{email: emailService}
+	return &PasswordResetService{email: emailService, users: users, tenants: tenants}
+}
+
+// WithContext returns a copy of the service bound to the request context
+func (s *PasswordResetService) WithContext(ctx context.Context) *PasswordResetService {
+	return &PasswordResetService{email: s.email, ctx: ctx, users: s.users, tenants: s.tenants}
+}
+
+// userRepo returns the injected user repository or the default one
+func (s *PasswordResetService) userRepo() repository.UserRepo {
+	if s.users != nil {
+		return s.users
+	}
+	return repository.Default().Users
+}
+
+// queryContext returns the service context (background when unset)
+func (s *PasswordResetService) queryContext() context.Context {
+	if s.ctx != nil {
+		return s.ctx
+	}
+	return context.Background()
 }
 
 func (s *PasswordResetService) GenerateAndSend(email string) (string, error) {
@@ -42,17 +71,21 @@ func (s *PasswordResetService) GenerateAndSend(email string) (string, error) {
 	expiry := time.Now().Add(60 * time.Minute) // 60 minutes default
 
 	// Find user by email
-	db := database.GetDB()
-	var user models.User
-	if err := db.Where("email = ?", email).First(&user).Error; err != nil {
+	users := s.userRepo()
+	user, err := users.FindByEmail(s.queryContext(), email)
+	if err != nil {
 		return "", err
 	}
 
 	// Update user with new reset token
-	if err := db.Model(&user).Updates(map[string]interface{}{
-		"reset_token":            token,
+	sealed, err := fieldcrypt.Seal("reset_token", token)
+	if err != nil {
+		return "", err
+	}
+	if err := users.Update(s.queryContext(), user.ID, map[string]interface{}{
+		"reset_token":            sealed,
 		"reset_token_expires_at": expiry,
-	}).Error; err != nil {
+	}); err != nil {
 		return "", err
 	}
 
@@ -64,27 +97,28 @@ func (s *PasswordResetService) GenerateAndSend(email string) (string, error) {
 }
 
 func (s *PasswordResetService) ValidateToken(email string, token string) (bool, error) {
-	db := database.GetDB()
-	var user models.User
-
-	// Find user by email and check reset token
-	if err := db.Where("email = ? AND reset_token = ? AND reset_token_expires_at > ?",
-		email, token, time.Now()).First(&user).Error; err != nil {
+	// Find user by email and check reset token (stored encrypted, so compared after decryption)
+	user, err := s.userRepo().FindByPendingResetToken(s.queryContext(), email, time.Now())
+	if err != nil {
 		return false, err
 	}
+	if !tokenMatches(user.ResetToken, token) {
+		return false, gorm.ErrRecordNotFound
+	}
 
 	return true, nil
 }
 
 func (s *PasswordResetService) ResetPassword(email string, token string, newPassword string) error {
-	db := database.GetDB()
-	var user models.User
-
-	// Find user by email and check reset token
-	if err := db.Where("email = ? AND reset_token = ? AND reset_token_expires_at > ?",
-		email, token, time.Now()).First(&user).Error; err != nil {
+	users := s.userRepo()
+	// Find user by email and check reset token (stored encrypted, so compared after decryption)
+	user, err := users.FindByPendingResetToken(s.queryContext(), email, time.Now())
+	if err != nil {
 		return err
 	}
+	if !tokenMatches(user.ResetToken, token) {
+		return gorm.ErrRecordNotFound
+	}
 
 	// Hash new password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
@@ -93,15 +127,21 @@ func (s *PasswordResetService) ResetPassword(email string, token string, newPass
 	}
 
 	// Update password and clear reset token
-	if err := db.Model(&user).Updates(map[string]interface{}{
+	if err := users.Update(s.queryContext(), user.ID, map[string]interface{}{
 		"password_hash":          hashedPassword,
 		"reset_token":            nil,
 		"reset_token_expires_at": nil,
-	}).Error; err != nil {
+	}); err != nil {
 		return err
 	}
 
-	return nil
+	// A new password lifts a lockout: the owner proved access to the mailbox
+	return NewLoginLockoutService(s.users, s.tenants).WithContext(s.ctx).Reset(user.ID)
+}
+
+// tokenMatches compares a stored one-time code or token with the submitted one in constant time
+func tokenMatches(stored, submitted string) bool {
+	return stored != "" && subtle.ConstantTimeCompare([]byte(stored), []byte(submitted)) == 1
 }
 
 func generateResetToken() string {
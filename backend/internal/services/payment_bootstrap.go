@@ -0,0 +1,182 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/models"
+)
+
+// ErrPaymentTokenInvalid is returned for tampered, expired or foreign create_payment_token values
+var ErrPaymentTokenInvalid = errors.New("payment_token_invalid")
+
+const paymentTokenTTL = 30 * time.Minute
+
+// paymentTokenClaims is the signed body of a create_payment_token
+type paymentTokenClaims struct {
+	UserID      int     `json:"uid"`
+	PhoneNumber string  `json:"phone"`
+	Category    string  `json:"cat"`
+	Amount      float64 `json:"amt"`
+	ExpiresAt   int64   `json:"exp"`
+}
+
+// PaymentPrice is the starting price shown before checkout
+type PaymentPrice struct {
+	Category string  `json:"category"`
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+}
+
+// EntitlementPrecheck is the answer of GET /api/wa/entitlement and GET /api/payments/check
+type EntitlementPrecheck struct {
+	*Entitlement
+	Message         string                   `json:"message"`
+	PaymentRequired bool                     `json:"payment_required"`
+	Price           *PaymentPrice            `json:"price"`
+	Payment         *models.PaymentBootstrap `json:"payment"` // checkout payload, only when payment_required
+}
+
+// Precheck tells whether the user may analyze the phone number (EntitlementService.Evaluate) and,
+// when a payment would allow it, adds the starting price and a ready-to-use checkout payload
+// as in the 402 of /api/wa/analyze
+func (ps *PaymentService) Precheck(userID uint, phoneNumber string) (*EntitlementPrecheck, error) {
+	entitlement, err := ps.Entitlements().Evaluate(userID, phoneNumber)
+	if err != nil {
+		return nil, err
+	}
+	precheck := &EntitlementPrecheck{
+		Entitlement:     entitlement,
+		Message:         entitlement.Message(),
+		PaymentRequired: entitlement.PaymentRequired(),
+	}
+	if !precheck.PaymentRequired {
+		return precheck, nil
+	}
+
+	// Both are optional: the frontend falls back to its own payment form
+	if category, err := ps.GetStartingPrice(); err != nil {
+		logging.FromContext(ps.ctx).Warn("Could not load payment price", "error", err)
+	} else if category != nil {
+		precheck.Price = &PaymentPrice{Category: category.Name, Amount: category.Price, Currency: "IDR"}
+	}
+	if bootstrap, err := ps.NewPaymentBootstrap(int(userID), phoneNumber); err != nil {
+		logging.FromContext(ps.ctx).Error("Failed to build payment bootstrap", "user_id", userID, "error", err)
+	} else {
+		precheck.Payment = bootstrap
+	}
+	return precheck, nil
+}
+
+// NewPaymentBootstrap suggests a plan for the phone number and signs a one-click create_payment_token for it
+func (ps *PaymentService) NewPaymentBootstrap(userID int, phoneNumber string) (*models.PaymentBootstrap, error) {
+	plan, amount := defaultPlan()
+	if category, err := ps.GetStartingPrice(); err != nil {
+		return nil, err
+	} else if category != nil {
+		plan, amount = category.Name, category.Price
+	}
+
+	claims := paymentTokenClaims{
+		UserID:      userID,
+		PhoneNumber: NormalizePhoneNumber(phoneNumber),
+		Category:    plan,
+		Amount:      amount,
+		ExpiresAt:   time.Now().Add(paymentTokenTTL).Unix(),
+	}
+	token, err := signPaymentToken(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.PaymentBootstrap{
+		Plan:               plan,
+		Amount:             amount,
+		Currency:           "IDR",
+		PhoneNumber:        claims.PhoneNumber,
+		CreatePaymentToken: token,
+		ExpiresAt:          time.Unix(claims.ExpiresAt, 0),
+	}, nil
+}
+
+// ApplyPaymentToken verifies req.CreatePaymentToken for userID and fills category, amount and phone number from it
+func (ps *PaymentService) ApplyPaymentToken(req *models.CreatePaymentRequest, userID int) error {
+	claims, err := parsePaymentToken(req.CreatePaymentToken)
+	if err != nil {
+		return err
+	}
+	if claims.UserID != userID {
+		return ErrPaymentTokenInvalid
+	}
+
+	req.Category = claims.Category
+	req.Amount = claims.Amount
+	req.PhoneNumber = claims.PhoneNumber
+	return nil
+}
+
+// defaultPlan is used when no payment_categories row is active
+func defaultPlan() (string, float64) {
+	plan := os.Getenv("PAYMENT_DEFAULT_CATEGORY")
+	if plan == "" {
+		plan = "WhatsApp Analysis"
+	}
+	amount, err := strconv.ParseFloat(os.Getenv("PAYMENT_DEFAULT_AMOUNT"), 64)
+	if err != nil || amount <= 0 {
+		amount = 50000 // Default amount for WhatsApp analysis
+	}
+	return plan, amount
+}
+
+func paymentTokenSecret() []byte {
+	if secret := os.Getenv("PAYMENT_TOKEN_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	return fallbackSigningSecret()
+}
+
+func signPaymentToken(claims paymentTokenClaims) (string, error) {
+	body, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(body)
+	mac := hmac.New(sha256.New, paymentTokenSecret())
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+func parsePaymentToken(token string) (*paymentTokenClaims, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrPaymentTokenInvalid
+	}
+	mac := hmac.New(sha256.New, paymentTokenSecret())
+	mac.Write([]byte(payload))
+	expected := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return nil, ErrPaymentTokenInvalid
+	}
+
+	body, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrPaymentTokenInvalid
+	}
+	var claims paymentTokenClaims
+	if err := json.Unmarshal(body, &claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPaymentTokenInvalid, err)
+	}
+	if time.Now().Unix() > claims.ExpiresAt {
+		return nil, ErrPaymentTokenInvalid
+	}
+	return &claims, nil
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrCategoryNotFound is returned for unknown or inactive payment categories
+	ErrCategoryNotFound = errors.New("payment_category_not_found")
+	// ErrCategoryNameTaken is returned when another category already has the name
+	ErrCategoryNameTaken = errors.New("payment category name is already in use")
+)
+
+// PaymentCategoryService manages the payment categories and their prices. Payments for a phone
+// number are charged the price of their category; the client only names the category.
+type PaymentCategoryService struct {
+	ctx        context.Context
+	categories repository.PaymentCategoryRepo
+}
+
+// NewPaymentCategoryService creates a payment category service on the given repository.
+// The zero value PaymentCategoryService{} uses the default repository.
+func NewPaymentCategoryService(categories repository.PaymentCategoryRepo) *PaymentCategoryService {
+	return &PaymentCategoryService{categories: categories}
+}
+
+// WithContext returns a copy of the service bound to the request context
+func (pcs *PaymentCategoryService) WithContext(ctx context.Context) *PaymentCategoryService {
+	return &PaymentCategoryService{ctx: ctx, categories: pcs.categories}
+}
+
+// categoryRepo returns the injected payment category repository or the default one
+func (pcs *PaymentCategoryService) categoryRepo() repository.PaymentCategoryRepo {
+	if pcs.categories != nil {
+		return pcs.categories
+	}
+	return repository.Default().PaymentCategories
+}
+
+// queryContext returns the service context (background when unset)
+func (pcs *PaymentCategoryService) queryContext() context.Context {
+	if pcs.ctx != nil {
+		return pcs.ctx
+	}
+	return context.Background()
+}
+
+// ListCategories returns all payment categories, cheapest first
+func (pcs *PaymentCategoryService) ListCategories() ([]models.PaymentCategory, error) {
+	return pcs.categoryRepo().List(pcs.queryContext())
+}
+
+// CreateCategory validates and stores a new payment category
+func (pcs *PaymentCategoryService) CreateCategory(category *models.PaymentCategory) error {
+	if err := category.Validate(); err != nil {
+		return err
+	}
+	if err := pcs.checkName(category.Name, 0); err != nil {
+		return err
+	}
+
+	category.ID = 0
+	return pcs.categoryRepo().Create(pcs.queryContext(), category)
+}
+
+// UpdateCategory replaces the name, description, price and active flag of a category. Invoices
+// already created keep the amount they were issued for.
+func (pcs *PaymentCategoryService) UpdateCategory(id int, changes *models.PaymentCategory) (*models.PaymentCategory, error) {
+	if err := changes.Validate(); err != nil {
+		return nil, err
+	}
+
+	categories := pcs.categoryRepo()
+	category, err := categories.FindByID(pcs.queryContext(), id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCategoryNotFound
+		}
+		return nil, err
+	}
+	if err := pcs.checkName(changes.Name, id); err != nil {
+		return nil, err
+	}
+	category.Name = changes.Name
+	category.Description = changes.Description
+	category.Price = changes.Price
+	category.IsActive = changes.IsActive
+	if err := categories.Update(pcs.queryContext(), category); err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// DeleteCategory removes a category. Transactions keep its name as their description.
+func (pcs *PaymentCategoryService) DeleteCategory(id int) error {
+	deleted, err := pcs.categoryRepo().Delete(pcs.queryContext(), id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
+
+// Resolve returns the active category a payment is charged for: by id when set, else by name
+// (case-insensitive). Until any category is configured every payment gets the default plan
+// (PAYMENT_DEFAULT_CATEGORY / PAYMENT_DEFAULT_AMOUNT).
+func (pcs *PaymentCategoryService) Resolve(id int, name string) (*models.PaymentCategory, error) {
+	categories := pcs.categoryRepo()
+	category, err := categories.FindActive(pcs.queryContext(), id, name)
+	if err == nil {
+		return category, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get payment category: %v", err)
+	}
+
+	active, err := categories.CountActive(pcs.queryContext())
+	if err != nil {
+		return nil, fmt.Errorf("failed to count payment categories: %v", err)
+	}
+	if active > 0 {
+		return nil, ErrCategoryNotFound
+	}
+	plan, amount := defaultPlan()
+	return &models.PaymentCategory{Name: plan, Price: amount, IsActive: true}, nil
+}
+
+// checkName fails when a category other than id already uses name
+func (pcs *PaymentCategoryService) checkName(name string, id int) error {
+	taken, err := pcs.categoryRepo().NameTaken(pcs.queryContext(), name, id)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrCategoryNameTaken
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"back_wa/internal/models"
+)
+
+// Payment gateways a transaction can be issued by
+const (
+	PaymentGatewayXendit   = "xendit"
+	PaymentGatewayMidtrans = "midtrans"
+)
+
+// ErrUnknownPaymentGateway is returned for a gateway name other than xendit or midtrans
+var ErrUnknownPaymentGateway = errors.New("unknown_payment_gateway")
+
+// PaymentGateway creates hosted payment pages and reports their status
+type PaymentGateway interface {
+	Name() string
+	// CreateCheckout opens a hosted payment page for the transaction req.ExternalID
+	CreateCheckout(req models.GatewayCheckoutRequest) (*models.GatewayCheckout, error)
+	// CheckoutStatus fetches the current state of a transaction issued by this gateway
+	CheckoutStatus(transaction *models.Transaction) (*models.GatewayCheckout, error)
+}
+
+// DefaultPaymentGateway returns PAYMENT_GATEWAY (default xendit)
+func DefaultPaymentGateway() string {
+	if name := strings.ToLower(strings.TrimSpace(os.Getenv("PAYMENT_GATEWAY"))); name != "" {
+		return name
+	}
+	return PaymentGatewayXendit
+}
+
+// gateway returns the named gateway ("" = PAYMENT_GATEWAY); Xendit uses the tenant's own keys when set
+func (ps *PaymentService) gateway(name string, tenant *models.Tenant) (PaymentGateway, error) {
+	if name == "" {
+		name = DefaultPaymentGateway()
+	}
+	switch strings.ToLower(name) {
+	case PaymentGatewayXendit:
+		if tenant != nil {
+			return NewXenditServiceForTenant(tenant), nil
+		}
+		return NewXenditService(), nil
+	case PaymentGatewayMidtrans:
+		return ps.midtransService, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPaymentGateway, name)
+	}
+}
+
+// gatewayFor returns the gateway that issued the transaction
+func (ps *PaymentService) gatewayFor(transaction *models.Transaction) PaymentGateway {
+	if transaction.Gateway == PaymentGatewayMidtrans {
+		return ps.midtransService
+	}
+	return ps.xenditServiceFor(transaction)
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"back_wa/internal/models"
+)
+
+// ErrIdempotencyKeyReused is returned when an Idempotency-Key is sent again with a different request body
+var ErrIdempotencyKeyReused = errors.New("idempotency_key_reused")
+
+// MaxIdempotencyKeyLength matches the transactions.idempotency_key column size
+const MaxIdempotencyKeyLength = 255
+
+var idempotencyLocks sync.Map // "userID:key" -> *sync.Mutex
+
+// idempotencyLock returns the mutex guarding one user's Idempotency-Key
+func idempotencyLock(userID int, key string) *sync.Mutex {
+	lock, _ := idempotencyLocks.LoadOrStore(fmt.Sprintf("%d:%s", userID, key), &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+// paymentRequestHash fingerprints the fields that decide what invoice gets created
+func paymentRequestHash(req models.CreatePaymentRequest) string {
+	fingerprint := fmt.Sprintf("%s|%s|%s|%.2f|%s|%s",
+		req.Email, req.Category, req.PaymentMethod, req.Amount, NormalizePhoneNumber(req.PhoneNumber), req.RedirectBaseURL)
+	// Only appended when set, so keys stored before gateway selection keep their hash
+	if req.Gateway != "" {
+		fingerprint += "|" + strings.ToLower(req.Gateway)
+	}
+	if req.CouponCode != "" {
+		fingerprint += "|coupon:" + models.NormalizeCouponCode(req.CouponCode)
+	}
+	sum := sha256.Sum256([]byte(fingerprint))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var pendingPaymentLocks sync.Map // "userID|phone|category" -> *sync.Mutex
+
+// pendingPaymentLock serializes invoice creation for one user + phone + category within this
+// instance, so concurrent requests reuse one invoice instead of racing into the unique index
+func pendingPaymentLock(userID int, phoneNumber, category string) *sync.Mutex {
+	key := fmt.Sprintf("%d|%s|%s", userID, phoneNumber, strings.ToLower(category))
+	lock, _ := pendingPaymentLocks.LoadOrStore(key, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+// findOpenPending returns the user's still-pending transaction for phone + category, or nil.
+// Candidates are reconciled with Xendit first so an invoice that was paid or expired meanwhile is not reused.
+func (ps *PaymentService) findOpenPending(userID int, phoneNumber, category string) (*models.Transaction, error) {
+	candidates, err := ps.transactions.ListOpenPending(ps.ctx, userID, category)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check pending transactions: %v", err)
+	}
+
+	for _, candidate := range candidates {
+		// Older rows may hold the phone number in its raw form
+		if NormalizePhoneNumber(candidate.PhoneNumber) != phoneNumber {
+			continue
+		}
+
+		current, err := ps.ReconcileTransactionStatusByExternalID(candidate.ExternalID)
+		if err != nil {
+			return nil, err
+		}
+		if current.Status != "pending" {
+			continue
+		}
+
+		// Transactions created before invoice URLs were stored: fetch it once from Xendit
+		if current.InvoiceURL == "" {
+			invoice, err := ps.xenditServiceFor(current).GetInvoice(current.InvoiceID)
+			if err != nil || invoice.InvoiceURL == "" {
+				continue
+			}
+			current.InvoiceURL = invoice.InvoiceURL
+			current.InvoiceExpiry = invoice.ExpiryDate
+			ps.transactions.UpdateByID(ps.ctx, current.ID,
+				map[string]interface{}{"invoice_url": invoice.InvoiceURL, "invoice_expiry": invoice.ExpiryDate})
+		}
+		return current, nil
+	}
+	return nil, nil
+}
+
+// xenditServiceFor returns the Xendit client holding the keys of the tenant that issued the transaction
+func (ps *PaymentService) xenditServiceFor(transaction *models.Transaction) *XenditService {
+	if transaction.TenantID != nil {
+		if tenant, err := ps.transactions.IssuingTenant(ps.ctx, *transaction.TenantID); err == nil {
+			return NewXenditServiceForTenant(tenant)
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logging.FromContext(ps.ctx).Warn(fmt.Sprintf("Failed to load tenant %d for Xendit keys", *transaction.TenantID), "error", err)
+		}
+	}
+	// Built per call so rotated XENDIT_* secrets apply without a restart
+	return NewXenditService()
+}
+
+// isPendingDuplicate reports whether err is a violation of the one-pending-per-user+phone+category
+// index: the partial index on PostgreSQL and SQLite, the pending_key index on MySQL
+func isPendingDuplicate(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "idx_transactions_one_pending") ||
+		(strings.Contains(msg, "unique") && strings.Contains(msg, "transactions.user_id, transactions.phone_number"))
+}
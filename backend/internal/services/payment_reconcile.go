@@ -0,0 +1,99 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"back_wa/internal/database"
+	"back_wa/internal/logging"
+	"back_wa/internal/models"
+)
+
+// invoiceDuration is how long Xendit and Midtrans invoices stay payable
+const invoiceDuration = 24 * time.Hour
+
+// PaymentReconcilePolicy configures the pending payment reconciliation worker
+type PaymentReconcilePolicy struct {
+	Interval    time.Duration // PAYMENT_RECONCILE_MINUTES (default 10, 0 disables)
+	OlderThan   time.Duration // PAYMENT_RECONCILE_AFTER_MINUTES: only transactions pending this long (default 15)
+	BatchSize   int           // PAYMENT_RECONCILE_BATCH: transactions per run, oldest first (default 200)
+	Concurrency int           // PAYMENT_RECONCILE_CONCURRENCY: gateway lookups at once (default 5)
+}
+
+// LoadPaymentReconcilePolicy reads the reconciliation worker settings from the environment
+func LoadPaymentReconcilePolicy() PaymentReconcilePolicy {
+	return PaymentReconcilePolicy{
+		Interval:    time.Duration(getIntEnv("PAYMENT_RECONCILE_MINUTES", 10)) * time.Minute,
+		OlderThan:   time.Duration(getIntEnv("PAYMENT_RECONCILE_AFTER_MINUTES", 15)) * time.Minute,
+		BatchSize:   getIntEnv("PAYMENT_RECONCILE_BATCH", 200),
+		Concurrency: getIntEnv("PAYMENT_RECONCILE_CONCURRENCY", 5),
+	}
+}
+
+// paymentExpireGrace is PAYMENT_EXPIRE_GRACE_MINUTES (default 30): how long past its invoice expiry a
+// transaction the gateway still reports pending is kept before it is expired locally
+func paymentExpireGrace() time.Duration {
+	return time.Duration(getIntEnv("PAYMENT_EXPIRE_GRACE_MINUTES", 30)) * time.Minute
+}
+
+// StartPaymentReconcileWorker reconciles transactions left pending (a missed or failed webhook)
+// with their gateway every policy.Interval, so payments are settled without the user polling
+func StartPaymentReconcileWorker(payments *PaymentService, policy PaymentReconcilePolicy) {
+	if policy.Interval <= 0 {
+		return
+	}
+	slog.Debug(fmt.Sprintf("Payment reconciliation worker enabled (every %s, pending for %s)", policy.Interval, policy.OlderThan))
+
+	go func() {
+		ticker := time.NewTicker(policy.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if database.IsDegraded() {
+				slog.Debug("Payment reconciliation skipped while the database is degraded")
+				continue
+			}
+			summary, err := payments.ReconcilePending(policy.OlderThan, policy.Concurrency, policy.BatchSize)
+			if err != nil {
+				slog.Warn("Payment reconciliation failed", "error", err)
+				continue
+			}
+			if summary.Changed+summary.Failed > 0 {
+				slog.Info(fmt.Sprintf("Payment reconciliation: %d checked, %d changed (%d expired locally), %d failed",
+					summary.Checked, summary.Changed, summary.ExpiredLocally, summary.Failed), "by_status", summary.ByStatus)
+			}
+		}
+	}()
+}
+
+// invoiceOverdue reports whether the transaction's invoice expired more than the grace period
+// before now. Transactions without a stored expiry use their creation time plus invoiceDuration.
+func invoiceOverdue(transaction *models.Transaction, now time.Time) bool {
+	expiry := transaction.CreatedAt.Add(invoiceDuration)
+	if parsed, err := time.Parse(time.RFC3339, transaction.InvoiceExpiry); err == nil {
+		expiry = parsed
+	}
+	return now.After(expiry.Add(paymentExpireGrace()))
+}
+
+// expireOverdue expires a pending transaction whose invoice can no longer be paid. It only
+// applies while the transaction is still pending, so a webhook settling it meanwhile wins.
+func (ps *PaymentService) expireOverdue(transaction *models.Transaction) (*models.Transaction, bool, error) {
+	expired, err := ps.transactions.ExpirePending(ps.ctx, transaction.ID)
+	if err != nil {
+		return transaction, false, fmt.Errorf("failed to expire transaction: %v", err)
+	}
+	if expired {
+		logging.FromContext(ps.ctx).Info(fmt.Sprintf("Expired overdue pending transaction %s", transaction.ExternalID), "external_id", transaction.ExternalID, "invoice_expiry", transaction.InvoiceExpiry)
+		if transaction.CouponCode != "" {
+			if err := ps.coupons.ReleaseTransaction(transaction.ID); err != nil {
+				logging.FromContext(ps.ctx).Warn(fmt.Sprintf("Failed to release coupon %s", transaction.CouponCode), "error", err, "external_id", transaction.ExternalID)
+			}
+		}
+	}
+	current, err := ps.GetTransactionByExternalID(transaction.ExternalID)
+	if err != nil {
+		return transaction, expired, err
+	}
+	return current, expired, nil
+}
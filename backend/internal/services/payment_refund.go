@@ -0,0 +1,269 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrTransactionNotFound is returned for an unknown external ID
+	ErrTransactionNotFound = errors.New("transaction not found")
+	// ErrRefundNotPaid is returned for transactions that were never paid
+	ErrRefundNotPaid = errors.New("only paid transactions can be refunded")
+	// ErrAlreadyRefunded is returned when the transaction has a pending or succeeded refund
+	ErrAlreadyRefunded = errors.New("transaction is already refunded")
+	// ErrRefundUnsupported is returned for transactions of gateways other than Xendit
+	ErrRefundUnsupported = errors.New("refunds are only supported for Xendit payments")
+	// ErrInvalidRefundReason is returned for a reason code Xendit does not accept
+	ErrInvalidRefundReason = errors.New("reason must be REQUESTED_BY_CUSTOMER, CANCELLATION, DUPLICATE, FRAUDULENT or OTHERS")
+	// ErrRefundFailed is returned when Xendit rejected the refund or could not be reached
+	ErrRefundFailed = errors.New("refund failed")
+)
+
+// refundReasons are the reason codes Xendit accepts
+var refundReasons = map[string]bool{
+	"REQUESTED_BY_CUSTOMER": true,
+	"CANCELLATION":          true,
+	"DUPLICATE":             true,
+	"FRAUDULENT":            true,
+	"OTHERS":                true,
+}
+
+var refundLocks sync.Map // externalID -> *sync.Mutex
+
+// refundLock returns the mutex serializing refunds of one transaction
+func refundLock(externalID string) *sync.Mutex {
+	lock, _ := refundLocks.LoadOrStore(externalID, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+// RefundTransaction refunds a paid Xendit transaction in full. Once Xendit accepts the refund the
+// transaction is marked refunded, which takes the phone number off the user's paid numbers, and
+// the subscription it paid for is cancelled. A transaction whose refunds all failed can be
+// refunded again. The refund is returned, recorded as failed, also when it failed.
+//
+// Each attempt is sent under a reference that doubles as Xendit idempotency key. When Xendit's
+// answer is lost the refund stays pending without a gateway ID and the next call retries it under
+// the same reference, so a refund that did go through is not issued twice. Only a failure Xendit
+// confirmed moves on to a new reference.
+func (ps *PaymentService) RefundTransaction(externalID string, req models.RefundRequest, adminID uint) (*models.Refund, *models.Transaction, error) {
+	reason := strings.ToUpper(strings.TrimSpace(req.Reason))
+	if reason == "" {
+		reason = "REQUESTED_BY_CUSTOMER"
+	}
+	if !refundReasons[reason] {
+		return nil, nil, ErrInvalidRefundReason
+	}
+
+	lock := refundLock(externalID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	transaction, refunds, err := ps.loadRefunds(externalID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range refunds {
+		if refunds[i].Live() {
+			// Accepted already; make sure the transaction no longer grants access
+			if err := ps.revokeRefunded(transaction); err != nil {
+				return &refunds[i], transaction, err
+			}
+			return &refunds[i], transaction, ErrAlreadyRefunded
+		}
+	}
+	if transaction.Status != "paid" && transaction.Status != "refunded" {
+		return nil, transaction, ErrRefundNotPaid
+	}
+	if transaction.Gateway != PaymentGatewayXendit {
+		return nil, transaction, ErrRefundUnsupported
+	}
+	if transaction.Amount <= 0 || transaction.InvoiceID == "" {
+		return nil, transaction, fmt.Errorf("%w: nothing was charged", ErrRefundNotPaid)
+	}
+
+	refund, err := ps.pendingRefund(transaction, refunds, reason, req.Note, adminID)
+	if err != nil {
+		return nil, transaction, err
+	}
+
+	response, err := ps.xenditServiceFor(transaction).CreateRefund(models.XenditRefundRequest{
+		InvoiceID:   transaction.InvoiceID,
+		ReferenceID: refund.Reference,
+		Amount:      refund.Amount,
+		Currency:    refund.Currency,
+		Reason:      refund.Reason,
+		Metadata:    map[string]string{"transaction_id": fmt.Sprint(transaction.ID)},
+	}, refund.Reference)
+	if err != nil {
+		refund.LastError = err.Error()
+		var apiErr *XenditAPIError
+		if errors.As(err, &apiErr) && apiErr.Rejected() {
+			now := time.Now()
+			refund.Status = models.RefundFailed
+			refund.CompletedAt = &now
+		}
+		if saveErr := ps.transactions.SaveRefund(ps.ctx, refund); saveErr != nil {
+			logging.FromContext(ps.ctx).Error("Failed to record failed refund", "refund_id", refund.ID, "error", saveErr)
+		}
+		if refund.Status == models.RefundPending {
+			return refund, transaction, fmt.Errorf("%w: no answer from Xendit, retry to resend refund %s: %v", ErrRefundFailed, refund.Reference, err)
+		}
+		return refund, transaction, fmt.Errorf("%w: %v", ErrRefundFailed, err)
+	}
+	applyRefundResponse(refund, response)
+	refund.LastError = ""
+	if err := ps.transactions.SaveRefund(ps.ctx, refund); err != nil {
+		logging.FromContext(ps.ctx).Error("Failed to record refund", "refund_id", refund.ID, "gateway_refund_id", refund.GatewayRefundID, "error", err)
+	}
+	if refund.Status == models.RefundFailed {
+		return refund, transaction, fmt.Errorf("%w: %s", ErrRefundFailed, refund.FailureCode)
+	}
+
+	if err := ps.revokeRefunded(transaction); err != nil {
+		// The money is on its way back; the transaction must not keep granting access
+		NewOpsAlerter().Alert("refund_revoke", fmt.Sprintf("Refund %s of transaction %s was accepted but the transaction could not be marked refunded: %v",
+			refund.GatewayRefundID, transaction.ExternalID, err))
+		return refund, transaction, err
+	}
+	updated, err := ps.transactions.FindByExternalID(ps.ctx, externalID)
+	if err != nil {
+		return refund, transaction, nil
+	}
+	return refund, updated, nil
+}
+
+// pendingRefund returns the refund to send to Xendit: the unconfirmed attempt when there is one,
+// otherwise a new refund whose reference counts the failures Xendit confirmed before it
+func (ps *PaymentService) pendingRefund(transaction *models.Transaction, refunds []models.Refund, reason, note string, adminID uint) (*models.Refund, error) {
+	failed := 0
+	for i := range refunds {
+		if refunds[i].Unconfirmed() && refunds[i].Reference != "" {
+			return &refunds[i], nil
+		}
+		if refunds[i].Status == models.RefundFailed {
+			failed++
+		}
+	}
+
+	refund := &models.Refund{
+		TransactionID: transaction.ID,
+		ExternalID:    transaction.ExternalID,
+		Gateway:       transaction.Gateway,
+		Reference:     fmt.Sprintf("%s-refund-%d", transaction.ExternalID, failed+1),
+		Amount:        transaction.Amount,
+		Currency:      transaction.Currency,
+		Reason:        reason,
+		Note:          truncateRunes(strings.TrimSpace(note), 500),
+		Status:        models.RefundPending,
+		AdminID:       adminID,
+	}
+	if refund.Currency == "" {
+		refund.Currency = "IDR"
+	}
+	if err := ps.transactions.CreateRefund(ps.ctx, refund); err != nil {
+		return nil, fmt.Errorf("failed to record refund: %v", err)
+	}
+	return refund, nil
+}
+
+// ListRefunds returns the refunds of a transaction, newest first. Refunds still pending at Xendit
+// are refreshed first; one that failed there leaves the transaction refundable again.
+func (ps *PaymentService) ListRefunds(externalID string) (*models.Transaction, []models.Refund, error) {
+	transaction, refunds, err := ps.loadRefunds(externalID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := range refunds {
+		refund := &refunds[i]
+		if refund.Status != models.RefundPending || refund.GatewayRefundID == "" || refund.Gateway != PaymentGatewayXendit {
+			continue
+		}
+		response, err := ps.xenditServiceFor(transaction).GetRefund(refund.GatewayRefundID)
+		if err != nil {
+			logging.FromContext(ps.ctx).Warn("Failed to refresh refund", "refund_id", refund.ID, "gateway_refund_id", refund.GatewayRefundID, "error", err)
+			continue
+		}
+		applyRefundResponse(refund, response)
+		if refund.Status == models.RefundPending {
+			continue
+		}
+		if err := ps.transactions.SaveRefund(ps.ctx, refund); err != nil {
+			logging.FromContext(ps.ctx).Error("Failed to update refund", "refund_id", refund.ID, "error", err)
+			continue
+		}
+		if refund.Status == models.RefundFailed {
+			NewOpsAlerter().Alert("refund_failed", fmt.Sprintf("Refund %s of transaction %s failed at Xendit (%s); the transaction stays refunded and can be refunded again",
+				refund.GatewayRefundID, transaction.ExternalID, refund.FailureCode))
+		}
+	}
+	return transaction, refunds, nil
+}
+
+func (ps *PaymentService) loadRefunds(externalID string) (*models.Transaction, []models.Refund, error) {
+	transaction, err := ps.transactions.FindByExternalID(ps.ctx, externalID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrTransactionNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get transaction: %v", err)
+	}
+	refunds, err := ps.transactions.ListRefunds(ps.ctx, transaction.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load refunds: %v", err)
+	}
+	return transaction, refunds, nil
+}
+
+// revokeRefunded marks the transaction refunded, cancels the subscription it paid for and tells
+// the user. A transaction that already was refunded (a retried refund) is left as is.
+func (ps *PaymentService) revokeRefunded(transaction *models.Transaction) error {
+	marked, err := ps.transactions.MarkRefunded(ps.ctx, transaction.ID)
+	if err != nil {
+		return fmt.Errorf("failed to mark transaction refunded: %v", err)
+	}
+	if !marked {
+		return nil
+	}
+
+	message := fmt.Sprintf("Pembayaran Rp%.0f untuk nomor %s telah dikembalikan. Nomor ini tidak lagi dapat dianalisis tanpa pembayaran baru.", transaction.Amount, transaction.PhoneNumber)
+	if transaction.SubscriptionID != nil {
+		if err := ps.subscriptions.Cancel(*transaction.SubscriptionID); err != nil {
+			logging.FromContext(ps.ctx).Warn(fmt.Sprintf("Failed to cancel subscription %d", *transaction.SubscriptionID), "error", err)
+		}
+		message = fmt.Sprintf("Pembayaran Rp%.0f untuk %s telah dikembalikan. Langganan Anda telah dihentikan.", transaction.Amount, transaction.Description)
+	}
+	ps.notifications.NotifyAsync(uint(transaction.UserID), models.NotificationPaymentRefunded,
+		"Pembayaran dikembalikan", message,
+		map[string]interface{}{"external_id": transaction.ExternalID, "transaction_id": transaction.ID})
+	return nil
+}
+
+// applyRefundResponse copies Xendit's view of the refund onto the record
+func applyRefundResponse(refund *models.Refund, response *models.XenditRefundResponse) {
+	if response.ID != "" {
+		refund.GatewayRefundID = response.ID
+	}
+	switch strings.ToUpper(response.Status) {
+	case "SUCCEEDED":
+		now := time.Now()
+		refund.Status = models.RefundSucceeded
+		refund.CompletedAt = &now
+	case "FAILED":
+		now := time.Now()
+		refund.Status = models.RefundFailed
+		refund.FailureCode = response.FailureCode
+		refund.CompletedAt = &now
+	default:
+		refund.Status = models.RefundPending
+	}
+}
@@ -1,138 +1,309 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
 	"strings"
+	"sync"
 	"time"
 
+	"back_wa/internal/logging"
 	"back_wa/internal/models"
+	"back_wa/internal/repository"
 
 	"gorm.io/gorm"
 )
 
 type PaymentService struct {
-	xenditService *XenditService
-	db            *gorm.DB
+	midtransService *MidtransService
+	coupons         *CouponService
+	categories      *PaymentCategoryService
+	subscriptions   *SubscriptionService
+	notifications   *NotificationService
+	redirects       *RedirectAllowList
+	transactions    repository.TransactionRepo
+	repos           *repository.Repositories
+	ctx             context.Context
 }
 
-func NewPaymentService(db *gorm.DB) *PaymentService {
+// NewPaymentService creates a payment service on the given repositories
+func NewPaymentService(repos *repository.Repositories) *PaymentService {
 	return &PaymentService{
-		xenditService: NewXenditService(),
-		db:            db,
+		midtransService: NewMidtransService(),
+		coupons:         NewCouponService(repos.Coupons),
+		categories:      NewPaymentCategoryService(repos.PaymentCategories),
+		subscriptions:   NewSubscriptionService(repos.Subscriptions),
+		notifications:   NewNotificationService(repos.Notifications, repos.Pushes),
+		redirects:       NewRedirectAllowList(),
+		transactions:    repos.Transactions,
+		repos:           repos,
+		ctx:             context.Background(),
 	}
 }
 
+// WithContext returns a copy of the service whose queries are bound to ctx (and its tenant scope)
+func (ps *PaymentService) WithContext(ctx context.Context) *PaymentService {
+	clone := *ps
+	clone.ctx = ctx
+	clone.coupons = ps.coupons.WithContext(ctx)
+	clone.categories = ps.categories.WithContext(ctx)
+	clone.subscriptions = ps.subscriptions.WithContext(ctx)
+	clone.notifications = ps.notifications.WithContext(ctx)
+	return &clone
+}
+
+// Entitlements returns the entitlement service on the same repositories and context
+func (ps *PaymentService) Entitlements() *EntitlementService {
+	return NewEntitlementService(ps.repos).WithContext(ps.ctx)
+}
+
 func (ps *PaymentService) CreatePayment(req models.CreatePaymentRequest, userID int) (*models.CreatePaymentResponse, error) {
-	fmt.Printf("💰 Creating payment for user %d: %+v\n", userID, req)
+	return ps.CreatePaymentForTenant(req, userID, nil)
+}
+
+// CreatePaymentForTenant creates a payment using the tenant's branding and Xendit keys (nil = default brand)
+func (ps *PaymentService) CreatePaymentForTenant(req models.CreatePaymentRequest, userID int, tenant *models.Tenant) (*models.CreatePaymentResponse, error) {
+	if err := ps.applyPrice(&req); err != nil {
+		return nil, err
+	}
+	return ps.createPayment(req, userID, tenant, nil)
+}
+
+// CreatePaymentIdempotent is CreatePaymentForTenant keyed by the client's Idempotency-Key.
+// A repeated key returns the original transaction (replayed = true) without creating a new invoice;
+// reusing a key with a different request body returns ErrIdempotencyKeyReused.
+func (ps *PaymentService) CreatePaymentIdempotent(req models.CreatePaymentRequest, userID int, tenant *models.Tenant, key string) (*models.CreatePaymentResponse, bool, error) {
+	// Serialize concurrent double-clicks with the same key; the unique index is the backstop
+	lock := idempotencyLock(userID, key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := ps.applyPrice(&req); err != nil {
+		return nil, false, err
+	}
+	hash := paymentRequestHash(req)
+
+	existing, err := ps.transactions.FindByIdempotencyKey(ps.ctx, userID, key)
+	if err == nil {
+		if existing.RequestHash != hash {
+			return nil, false, ErrIdempotencyKeyReused
+		}
+		logging.FromContext(ps.ctx).Debug(fmt.Sprintf("Idempotent replay for user %d, key %s -> %s", userID, key, existing.ExternalID), "user_id", userID, "external_id", existing.ExternalID)
+		return pendingResponse(existing), true, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, fmt.Errorf("failed to look up idempotency key: %v", err)
+	}
+
+	resp, err := ps.createPayment(req, userID, tenant, &idempotencyRecord{key: key, hash: hash})
+	return resp, false, err
+}
+
+// pendingResponse describes an already existing transaction as a create response
+func pendingResponse(transaction *models.Transaction) *models.CreatePaymentResponse {
+	return &models.CreatePaymentResponse{
+		ID:            transaction.ID,
+		ExternalID:    transaction.ExternalID,
+		InvoiceID:     transaction.InvoiceID,
+		InvoiceURL:    transaction.InvoiceURL,
+		Amount:        transaction.Amount,
+		Status:        transaction.Status,
+		PaymentMethod: transaction.PaymentMethod,
+		Gateway:       transaction.Gateway,
+		CreatedAt:     transaction.CreatedAt,
+		ExpiryDate:    transaction.InvoiceExpiry,
+		Existing:      true,
+		CouponCode:    transaction.CouponCode,
+		Discount:      transaction.Discount,
+	}
+}
+
+// applyPrice sets the category and amount of req from its payment category, so the client cannot
+// choose what it pays. Subscription payments already carry the plan price.
+func (ps *PaymentService) applyPrice(req *models.CreatePaymentRequest) error {
+	if req.SubscriptionID != nil {
+		return nil
+	}
+	category, err := ps.categories.Resolve(req.CategoryID, req.Category)
+	if err != nil {
+		return err
+	}
+	req.Category = category.Name
+	req.Amount = category.Price
+	return nil
+}
+
+// idempotencyRecord is stored on the transaction created for an Idempotency-Key
+type idempotencyRecord struct {
+	key  string
+	hash string
+}
+
+func (ps *PaymentService) createPayment(req models.CreatePaymentRequest, userID int, tenant *models.Tenant, idem *idempotencyRecord) (*models.CreatePaymentResponse, error) {
+	logging.FromContext(ps.ctx).Debug(fmt.Sprintf("Creating payment for user %d: %+v", userID, req), "user_id", userID)
+
+	// Store phone numbers in one canonical form so pending/paid lookups match
+	req.PhoneNumber = NormalizePhoneNumber(req.PhoneNumber)
+
+	// Only one pending invoice per user + phone + category: hand back the open one instead of stacking more
+	lock := pendingPaymentLock(userID, req.PhoneNumber, req.Category)
+	lock.Lock()
+	defer lock.Unlock()
+
+	existing, err := ps.findOpenPending(userID, req.PhoneNumber, req.Category)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		logging.FromContext(ps.ctx).Debug(fmt.Sprintf("Reusing pending transaction %s for user %d", existing.ExternalID, userID), "external_id", existing.ExternalID, "user_id", userID)
+		return pendingResponse(existing), nil
+	}
+
+	gateway, err := ps.gateway(req.Gateway, tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply the coupon before the invoice is built; the use is given back if no invoice results
+	amount := req.Amount
+	var coupon *models.Coupon
+	var discount float64
+	if strings.TrimSpace(req.CouponCode) != "" {
+		var quote *models.CouponQuote
+		coupon, quote, err = ps.coupons.Reserve(req.CouponCode, req.Amount)
+		if err != nil {
+			return nil, err
+		}
+		discount = quote.Discount
+		amount = quote.FinalAmount
+	}
+	releaseCoupon := func() {
+		if coupon == nil {
+			return
+		}
+		if err := ps.coupons.Release(coupon.ID); err != nil {
+			logging.FromContext(ps.ctx).Warn(fmt.Sprintf("Failed to release coupon %s", coupon.Code), "error", err)
+		}
+	}
 
 	// Generate external ID
 	externalID := fmt.Sprintf("cekwa_%d_%d", userID, time.Now().Unix())
-	fmt.Printf("🆔 Generated external ID: %s\n", externalID)
-
-	// Create Xendit invoice request
-	// Build redirect URLs from environment to avoid hardcoded localhost
-	frontendBaseURL := os.Getenv("FRONTEND_BASE_URL")
-	if frontendBaseURL == "" {
-		frontendBaseURL = "http://localhost:3000"
-	}
-
-	// Map selected payment method; if empty/auto, let Xendit decide by omitting
-	mappedMethods := ps.mapPaymentMethodToXendit(req.PaymentMethod)
-
-	xenditReq := models.XenditInvoiceRequest{
-		ExternalID:      externalID,
-		Amount:          req.Amount,
-		Description:     req.Category,
-		InvoiceDuration: 24, // 24 hours
-		Customer: models.XenditCustomer{
-			GivenNames: "Customer",
-			Email:      req.Email,
-		},
-		CustomerNotificationPreference: models.XenditNotificationPreference{
-			InvoiceCreated:  []string{"email"},
-			InvoiceReminder: []string{"email"},
-			InvoicePaid:     []string{"email"},
-			InvoiceExpired:  []string{"email"},
-		},
-		SuccessRedirectURL: fmt.Sprintf("%s/dashboard/transaksi?status=success", frontendBaseURL),
-		FailureRedirectURL: fmt.Sprintf("%s/dashboard/transaksi?status=failed", frontendBaseURL),
-		PaymentMethods:     mappedMethods,
-		ShouldSendEmail:    true,
-		Items: []models.XenditItem{
-			{
-				Name:     req.Category,
-				Quantity: 1,
-				Price:    req.Amount,
-				Category: req.Category,
-			},
-		},
-	}
+	logging.FromContext(ps.ctx).Debug(fmt.Sprintf("Generated external ID: %s", externalID), "external_id", externalID)
 
-	fmt.Printf("📋 Xendit request prepared: %+v\n", xenditReq)
+	// Build redirect URLs from the allow-list; partners may override within the list
+	frontendBaseURL, err := ps.redirects.ResolveForTenant(req.RedirectBaseURL, tenant)
+	if err != nil {
+		logging.FromContext(ps.ctx).Error("Redirect base URL rejected", "error", err)
+		releaseCoupon()
+		return nil, err
+	}
 
-	// Create invoice via Xendit
-	fmt.Printf("🔄 Calling Xendit API...\n")
-	invoiceResp, err := ps.xenditService.CreateInvoice(xenditReq)
+	// Create the hosted payment page via the selected gateway
+	logging.FromContext(ps.ctx).Debug(fmt.Sprintf("Calling %s API...", gateway.Name()))
+	checkout, err := gateway.CreateCheckout(models.GatewayCheckoutRequest{
+		ExternalID:         externalID,
+		Amount:             amount,
+		Description:        req.Category,
+		Email:              req.Email,
+		SuccessRedirectURL: fmt.Sprintf("%s/dashboard/transaksi?status=success", frontendBaseURL),
+		FailureRedirectURL: fmt.Sprintf("%s/dashboard/transaksi?status=failed", frontendBaseURL),
+	})
 	if err != nil {
-		fmt.Printf("❌ Xendit API failed: %v\n", err)
-		return nil, fmt.Errorf("xendit_error: %v", err)
+		logging.FromContext(ps.ctx).Error(fmt.Sprintf("%s API failed", gateway.Name()), "error", err)
+		releaseCoupon()
+		return nil, fmt.Errorf("%s_error: %v", gateway.Name(), err)
 	}
-	fmt.Printf("✅ Xendit invoice created: %s\n", invoiceResp.ID)
+	logging.FromContext(ps.ctx).Info(fmt.Sprintf("%s checkout created: %s", gateway.Name(), checkout.ID))
 
 	// Save transaction to database
 	transaction := models.Transaction{
-		UserID:        userID,
-		ExternalID:    externalID,
-		InvoiceID:     invoiceResp.ID,
-		Amount:        req.Amount,
-		Currency:      "IDR",
-		Status:        "pending",
-		PaymentMethod: req.PaymentMethod,
-		Description:   req.Category,
-		PhoneNumber:   req.PhoneNumber,
-		CreatedAt:     time.Now(),
-		UpdatedAt:     time.Now(),
+		UserID:         userID,
+		TenantID:       tenantIDOf(tenant),
+		ExternalID:     externalID,
+		InvoiceID:      checkout.ID,
+		Amount:         amount,
+		Currency:       "IDR",
+		Status:         "pending",
+		PaymentMethod:  req.PaymentMethod,
+		Description:    req.Category,
+		PhoneNumber:    req.PhoneNumber,
+		InvoiceURL:     checkout.URL,
+		InvoiceExpiry:  checkout.ExpiryDate,
+		Gateway:        gateway.Name(),
+		SubscriptionID: req.SubscriptionID,
+		Discount:       discount,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+	if coupon != nil {
+		transaction.CouponCode = coupon.Code
+	}
+	if idem != nil {
+		transaction.IdempotencyKey = &idem.key
+		transaction.RequestHash = idem.hash
 	}
 
-	fmt.Printf("💾 Saving transaction to database...\n")
+	logging.FromContext(ps.ctx).Debug("Saving transaction to database...")
 	transactionID, err := ps.saveTransaction(transaction)
 	if err != nil {
-		fmt.Printf("❌ Failed to save transaction: %v\n", err)
+		releaseCoupon()
+		// Another instance won the race (partial unique index): return its invoice instead
+		if isPendingDuplicate(err) {
+			if existing, findErr := ps.findOpenPending(userID, req.PhoneNumber, req.Category); findErr == nil && existing != nil {
+				return pendingResponse(existing), nil
+			}
+		}
+		logging.FromContext(ps.ctx).Error("Failed to save transaction", "error", err)
 		return nil, fmt.Errorf("failed to save transaction: %v", err)
 	}
 
-	fmt.Printf("✅ Transaction saved with ID: %d\n", transactionID)
+	logging.FromContext(ps.ctx).Info(fmt.Sprintf("Transaction saved with ID: %d", transactionID))
+
+	if coupon != nil {
+		redemption := &models.CouponRedemption{
+			CouponID:      coupon.ID,
+			UserID:        userID,
+			TransactionID: transactionID,
+			Amount:        req.Amount,
+			Discount:      discount,
+		}
+		if err := ps.coupons.Redeem(redemption); err != nil {
+			logging.FromContext(ps.ctx).Error(fmt.Sprintf("Failed to record redemption of coupon %s", coupon.Code), "error", err, "external_id", externalID)
+		}
+	}
 
 	response := &models.CreatePaymentResponse{
 		ID:            transactionID,
 		ExternalID:    externalID,
-		InvoiceID:     invoiceResp.ID,
-		InvoiceURL:    invoiceResp.InvoiceURL,
-		Amount:        req.Amount,
+		InvoiceID:     checkout.ID,
+		InvoiceURL:    checkout.URL,
+		Amount:        amount,
 		Status:        "pending",
 		PaymentMethod: req.PaymentMethod,
+		Gateway:       gateway.Name(),
 		CreatedAt:     time.Now(),
-		ExpiryDate:    invoiceResp.ExpiryDate, // Now string type
+		ExpiryDate:    checkout.ExpiryDate, // Now string type
+		CouponCode:    transaction.CouponCode,
+		Discount:      discount,
 	}
 
-	fmt.Printf("🎉 Payment creation completed successfully: %+v\n", response)
+	logging.FromContext(ps.ctx).Info(fmt.Sprintf("Payment creation completed successfully: %+v", response))
 	return response, nil
 }
 
 func (ps *PaymentService) GetTransactionByExternalID(externalID string) (*models.Transaction, error) {
-	var transaction models.Transaction
-	err := ps.db.Where("external_id = ?", externalID).First(&transaction).Error
+	transaction, err := ps.transactions.FindByExternalID(ps.ctx, externalID)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("transaction not found")
+			return nil, ErrTransactionNotFound
 		}
 		return nil, fmt.Errorf("failed to get transaction: %v", err)
 	}
-	return &transaction, nil
+	return transaction, nil
 }
 
-// ReconcileTransactionStatusByExternalID checks Xendit for latest invoice status
+// ReconcileTransactionStatusByExternalID checks the issuing gateway for the latest status
 // and updates local transaction if it has changed. Returns the latest transaction.
 func (ps *PaymentService) ReconcileTransactionStatusByExternalID(externalID string) (*models.Transaction, error) {
 	// Load current transaction
@@ -146,16 +317,21 @@ func (ps *PaymentService) ReconcileTransactionStatusByExternalID(externalID stri
 		return current, nil
 	}
 
-	// Query Xendit invoice
-	invoice, err := ps.xenditService.GetInvoice(current.InvoiceID)
+	// Query the gateway that issued it (Xendit with the tenant's keys)
+	checkout, err := ps.gatewayFor(current).CheckoutStatus(current)
 	if err != nil {
 		// Non-fatal: return current transaction, caller can still see current DB state
-		fmt.Printf("⚠️ Reconcile skip: fetch invoice failed for %s: %v\n", externalID, err)
+		logging.FromContext(ps.ctx).Warn(fmt.Sprintf("Reconcile skip: fetch invoice failed for %s", externalID), "error", err, "external_id", externalID)
 		return current, nil
 	}
 
+	channel := checkout.PaymentChannel
+	if channel == "" {
+		channel = current.PaymentChannel
+	}
+
 	// Update local status (mapping done inside UpdateTransactionStatus)
-	if err := ps.UpdateTransactionStatus(externalID, invoice.Status, current.PaymentChannel); err != nil {
+	if err := ps.UpdateTransactionStatus(externalID, checkout.Status, channel); err != nil {
 		return current, err
 	}
 
@@ -163,6 +339,71 @@ func (ps *PaymentService) ReconcileTransactionStatusByExternalID(externalID stri
 	return ps.GetTransactionByExternalID(externalID)
 }
 
+// ReconcilePending reconciles every pending transaction created before olderThan ago (0 = all)
+// against their gateways, running at most concurrency lookups at once.
+func (ps *PaymentService) ReconcilePending(olderThan time.Duration, concurrency, limit int) (*models.ReconcileSummary, error) {
+	started := time.Now()
+	if concurrency <= 0 {
+		concurrency = 5
+	}
+
+	pending, err := ps.transactions.ListPending(ps.ctx, olderThan, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pending transactions: %v", err)
+	}
+
+	summary := &models.ReconcileSummary{
+		Checked:  len(pending),
+		ByStatus: make(map[string]int),
+		Changes:  []models.ReconcileChange{},
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for _, txn := range pending {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(txn models.Transaction) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			updated, err := ps.ReconcileTransactionStatusByExternalID(txn.ExternalID)
+			expired := false
+			if err == nil && updated.Status == "pending" && invoiceOverdue(updated, time.Now()) {
+				updated, expired, err = ps.expireOverdue(updated)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if expired {
+				summary.ExpiredLocally++
+			}
+			switch {
+			case err != nil:
+				summary.Failed++
+				summary.Errors = append(summary.Errors, fmt.Sprintf("%s: %v", txn.ExternalID, err))
+			case updated.Status != txn.Status:
+				summary.Changed++
+				summary.ByStatus[updated.Status]++
+				summary.Changes = append(summary.Changes, models.ReconcileChange{
+					TransactionID: txn.ID,
+					ExternalID:    txn.ExternalID,
+					From:          txn.Status,
+					To:            updated.Status,
+				})
+			default:
+				summary.Unchanged++
+			}
+		}(txn)
+	}
+	wg.Wait()
+
+	summary.Duration = time.Since(started).Round(time.Millisecond).String()
+	logging.FromContext(ps.ctx).Debug(fmt.Sprintf("Reconciled %d pending transactions: %d changed, %d unchanged, %d failed", summary.Checked, summary.Changed, summary.Unchanged, summary.Failed))
+	return summary, nil
+}
+
 func (ps *PaymentService) UpdateTransactionStatus(externalID, status string, paymentChannel string) error {
 	normalized := strings.ToLower(status)
 	switch normalized {
@@ -187,29 +428,90 @@ func (ps *PaymentService) UpdateTransactionStatus(externalID, status string, pay
 	if normalized == "paid" {
 		updates["paid_at"] = time.Now()
 	}
+	if normalized == "refunded" {
+		updates["refunded_at"] = time.Now()
+	}
+
+	// Remember the previous state so the paid notification fires only once
+	previous, prevErr := ps.transactions.FindByExternalID(ps.ctx, externalID)
 
-	err := ps.db.Model(&models.Transaction{}).Where("external_id = ?", externalID).Updates(updates).Error
+	// A late or redelivered gateway notification must not bring a refunded payment back
+	if prevErr == nil && previous.Status == "refunded" && normalized != "refunded" {
+		logging.FromContext(ps.ctx).Warn(fmt.Sprintf("Ignoring status %s for refunded transaction %s", normalized, externalID), "external_id", externalID)
+		return nil
+	}
+
+	err := ps.transactions.UpdateByExternalID(ps.ctx, externalID, updates)
 	if err != nil {
 		return fmt.Errorf("failed to update transaction status: %v", err)
 	}
+
+	// An invoice that ended unpaid gives its coupon use back
+	if (normalized == "expired" || normalized == "failed") && prevErr == nil && previous.Status == "pending" && previous.CouponCode != "" {
+		if err := ps.coupons.ReleaseTransaction(previous.ID); err != nil {
+			logging.FromContext(ps.ctx).Warn(fmt.Sprintf("Failed to release coupon %s", previous.CouponCode), "error", err, "external_id", externalID)
+		}
+	}
+
+	// Refunded at the gateway (Midtrans dashboard): the subscription it paid for ends too
+	if normalized == "refunded" && prevErr == nil && previous.Status == "paid" && previous.SubscriptionID != nil {
+		if err := ps.subscriptions.Cancel(*previous.SubscriptionID); err != nil {
+			logging.FromContext(ps.ctx).Warn(fmt.Sprintf("Failed to cancel subscription %d", *previous.SubscriptionID), "error", err)
+		}
+	}
+
+	if normalized == "paid" && prevErr == nil && previous.Status != "paid" && previous.SubscriptionID != nil {
+		if _, err := ps.subscriptions.Activate(*previous.SubscriptionID); err != nil {
+			logging.FromContext(ps.ctx).Warn(fmt.Sprintf("Failed to activate subscription %d", *previous.SubscriptionID), "error", err)
+		}
+		ps.notifications.NotifyAsync(uint(previous.UserID), models.NotificationPaymentPaid,
+			"Pembayaran berhasil",
+			fmt.Sprintf("Pembayaran Rp%.0f untuk %s telah diterima. Langganan Anda sudah aktif.", previous.Amount, previous.Description),
+			map[string]interface{}{"external_id": externalID, "transaction_id": previous.ID, "subscription_id": *previous.SubscriptionID})
+	} else if normalized == "paid" && prevErr == nil && previous.Status != "paid" {
+		ps.notifications.NotifyAsync(uint(previous.UserID), models.NotificationPaymentPaid,
+			"Pembayaran berhasil",
+			fmt.Sprintf("Pembayaran Rp%.0f untuk nomor %s telah diterima.", previous.Amount, previous.PhoneNumber),
+			map[string]interface{}{"external_id": externalID, "transaction_id": previous.ID})
+	}
 	return nil
 }
 
-func (ps *PaymentService) GetUserTransactions(userID int) ([]models.Transaction, error) {
-	var transactions []models.Transaction
-	err := ps.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&transactions).Error
+// TransactionQuery pages, filters and sorts a user's transactions
+type TransactionQuery = repository.TransactionQuery
+
+// GetUserTransactions returns one page of the user's transactions and the number of
+// transactions matching the query
+func (ps *PaymentService) GetUserTransactions(userID int, query TransactionQuery) ([]models.Transaction, int64, error) {
+	total, err := ps.CountUserTransactions(userID, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	transactions, err := ps.transactions.ListByUser(ps.ctx, userID, query)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get transactions: %v", err)
+	}
+	return transactions, total, nil
+}
+
+// CountUserTransactions counts the user's transactions matching query, ignoring limit and offset
+func (ps *PaymentService) CountUserTransactions(userID int, query TransactionQuery) (int64, error) {
+	total, err := ps.transactions.CountByUser(ps.ctx, userID, query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get transactions: %v", err)
+		return 0, fmt.Errorf("failed to count transactions: %v", err)
 	}
-	return transactions, nil
+	return total, nil
+}
+
+// EachUserTransaction streams the user's transactions matching query (default order: newest
+// first) to fn one row at a time
+func (ps *PaymentService) EachUserTransaction(userID int, query TransactionQuery, fn func(models.Transaction) error) error {
+	return ps.transactions.EachByUser(ps.ctx, userID, query, fn)
 }
 
 // CheckIfUserPaidForPhone checks if user has a paid transaction for specific phone number
 func (ps *PaymentService) CheckIfUserPaidForPhone(userID int, phoneNumber string) (bool, error) {
-	var count int64
-	err := ps.db.Model(&models.Transaction{}).
-		Where("user_id = ? AND phone_number = ? AND status = ?", userID, phoneNumber, "paid").
-		Count(&count).Error
+	count, err := ps.transactions.CountPaid(ps.ctx, userID, phoneNumber)
 	if err != nil {
 		return false, fmt.Errorf("failed to check payment for phone: %v", err)
 	}
@@ -218,31 +520,31 @@ func (ps *PaymentService) CheckIfUserPaidForPhone(userID int, phoneNumber string
 
 // CheckIfUserHasAnyPaidTransaction checks if user has any paid transaction (regardless of phone number)
 func (ps *PaymentService) CheckIfUserHasAnyPaidTransaction(userID int) (bool, error) {
-	var count int64
-	err := ps.db.Model(&models.Transaction{}).
-		Where("user_id = ? AND status = ?", userID, "paid").
-		Count(&count).Error
+	count, err := ps.transactions.CountPaid(ps.ctx, userID, "")
 	if err != nil {
 		return false, fmt.Errorf("failed to check if user has any paid transactions: %v", err)
 	}
 	return count > 0, nil
 }
 
+// GetStartingPrice returns the cheapest active payment category, or nil when none is configured
+func (ps *PaymentService) GetStartingPrice() (*models.PaymentCategory, error) {
+	category, err := ps.transactions.CheapestActiveCategory(ps.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment categories: %v", err)
+	}
+	return category, nil
+}
+
 func (ps *PaymentService) saveTransaction(transaction models.Transaction) (int, error) {
-	fmt.Printf("💾 Saving transaction to database: %+v\n", transaction)
+	logging.FromContext(ps.ctx).Debug(fmt.Sprintf("Saving transaction to database: %+v", transaction))
 
-	err := ps.db.Create(&transaction).Error
+	err := ps.transactions.Create(ps.ctx, &transaction)
 	if err != nil {
-		fmt.Printf("❌ Database error: %v\n", err)
+		logging.FromContext(ps.ctx).Error("Database error", "error", err)
 		return 0, fmt.Errorf("failed to insert transaction: %v", err)
 	}
 
-	fmt.Printf("✅ Transaction saved with ID: %d\n", transaction.ID)
+	logging.FromContext(ps.ctx).Info(fmt.Sprintf("Transaction saved with ID: %d", transaction.ID))
 	return transaction.ID, nil
 }
-
-func (ps *PaymentService) mapPaymentMethodToXendit(paymentMethod string) []string {
-	// Always return nil to let Xendit show all available payment methods
-	// This allows users to choose payment method on Xendit's hosted page
-	return nil
-}
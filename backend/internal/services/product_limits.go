@@ -0,0 +1,277 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+)
+
+var (
+	// ErrDailyScanLimit is returned when the user has used up the analyses of the day for their tier
+	ErrDailyScanLimit = errors.New("daily scan limit reached")
+	// ErrUnknownTier is returned when setting limits for a tier that is neither built in nor a plan code
+	ErrUnknownTier = errors.New("unknown tier")
+)
+
+// limitsCache keeps the product limits of all tiers. Updates through this process refresh it
+// at once; other instances pick them up after LIMITS_CACHE_SECONDS (default 60).
+var limitsCache struct {
+	mu       sync.Mutex
+	limits   map[string]models.ProductLimit
+	loadedAt time.Time
+}
+
+// UserLimits is what GET /api/user/limits shows: the limits of the user's tier and how much of
+// them is in use
+type UserLimits struct {
+	models.ProductLimit
+	Usage LimitUsage `json:"usage"`
+}
+
+// LimitUsage counts what the limits apply to
+type LimitUsage struct {
+	Sessions      int64     `json:"sessions"`
+	ScansToday    int64     `json:"scans_today"`
+	ScansResetAt  time.Time `json:"scans_reset_at"`
+	HistoryStored int64     `json:"history_stored"`
+}
+
+// LimitsService reads and updates the per-tier product limits
+type LimitsService struct {
+	ctx   context.Context
+	repos *repository.Repositories
+}
+
+// NewLimitsService creates a limits service on the given repositories (product limits, plans,
+// sessions and analyses for the usage). The zero value LimitsService{} uses the default repositories.
+func NewLimitsService(repos *repository.Repositories) *LimitsService {
+	return &LimitsService{repos: repos}
+}
+
+// WithContext returns a copy of the service bound to the request context
+func (ls *LimitsService) WithContext(ctx context.Context) *LimitsService {
+	return &LimitsService{ctx: ctx, repos: ls.repos}
+}
+
+// repositories returns the injected repositories or the default ones
+func (ls *LimitsService) repositories() *repository.Repositories {
+	if ls.repos != nil {
+		return ls.repos
+	}
+	return repository.Default()
+}
+
+// queryContext returns the service context (background when unset)
+func (ls *LimitsService) queryContext() context.Context {
+	if ls.ctx != nil {
+		return ls.ctx
+	}
+	return context.Background()
+}
+
+// List returns the limits of every tier, the built-in tiers included when they were never saved
+func (ls *LimitsService) List() ([]models.ProductLimit, error) {
+	limits, err := loadProductLimits(ls.queryContext(), ls.repositories().ProductLimits)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]models.ProductLimit, 0, len(limits))
+	for _, limit := range limits {
+		list = append(list, limit)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Tier < list[j].Tier })
+	return list, nil
+}
+
+// Update stores the limits of a tier. Tiers other than trial and paid must be plan codes.
+func (ls *LimitsService) Update(tier string, limit models.ProductLimit, adminID uint) (*models.ProductLimit, error) {
+	if err := limit.Validate(); err != nil {
+		return nil, err
+	}
+	repos := ls.repositories()
+	if _, builtIn := models.DefaultProductLimits()[tier]; !builtIn {
+		exists, err := NewSubscriptionService(repos.Subscriptions).WithContext(ls.ctx).PlanExists(tier)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("%w %q: use trial, paid or a plan code", ErrUnknownTier, tier)
+		}
+	}
+
+	saved := &models.ProductLimit{
+		Tier:        tier,
+		MaxSessions: limit.MaxSessions,
+		ScansPerDay: limit.ScansPerDay,
+		HistorySize: limit.HistorySize,
+		UpdatedBy:   &adminID,
+	}
+	if err := repos.ProductLimits.Upsert(ls.queryContext(), saved); err != nil {
+		return nil, err
+	}
+
+	limitsCache.mu.Lock()
+	limitsCache.limits = nil
+	limitsCache.mu.Unlock()
+	return saved, nil
+}
+
+// ForUser returns the limits of the user's tier together with the current usage
+func (ls *LimitsService) ForUser(entitlements *EntitlementService, userID uint) (*UserLimits, error) {
+	limit, err := entitlements.WithContext(ls.ctx).Limits(userID)
+	if err != nil {
+		return nil, err
+	}
+	repos, ctx := ls.repositories(), ls.queryContext()
+
+	view := &UserLimits{ProductLimit: *limit}
+	if view.Usage.Sessions, err = repos.AuthSessions.CountActive(ctx, userID, time.Now()); err != nil {
+		return nil, err
+	}
+	start := startOfScanDay(time.Now())
+	if view.Usage.ScansToday, err = repos.Analyses.CountScansSince(ctx, userID, start); err != nil {
+		return nil, err
+	}
+	view.Usage.ScansResetAt = start.AddDate(0, 0, 1)
+	if view.Usage.HistoryStored, err = repos.Analyses.CountByUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	return view, nil
+}
+
+// Limits returns the product limits of the user: those of their active subscription's plan
+// when configured, otherwise those of their paid/trial tier
+func (es *EntitlementService) Limits(userID uint) (*models.ProductLimit, error) {
+	limits := activeProductLimits(es.limits)
+	subscription, err := es.subscriptions.Current(userID)
+	switch {
+	case err == nil:
+		if limit, ok := limits[subscription.PlanCode]; ok {
+			return &limit, nil
+		}
+	case !errors.Is(err, ErrNoSubscription):
+		return nil, fmt.Errorf("failed to check subscription: %v", err)
+	}
+
+	tier, err := es.Tier(userID)
+	if err != nil {
+		return nil, err
+	}
+	limit := limits[tier]
+	return &limit, nil
+}
+
+// CheckDailyScans returns ErrDailyScanLimit when the user already ran the analyses their tier
+// allows today. Analyses deleted since still count.
+func (es *EntitlementService) CheckDailyScans(userID uint) error {
+	limit, err := es.Limits(userID)
+	if err != nil {
+		return err
+	}
+	if limit.ScansPerDay == 0 {
+		return nil
+	}
+	count, err := es.analyses.CountScansSince(es.ctx, userID, startOfScanDay(time.Now()))
+	if err != nil {
+		return err
+	}
+	if count >= int64(limit.ScansPerDay) {
+		return ErrDailyScanLimit
+	}
+	return nil
+}
+
+// DailyScanLimitMessage is the user-facing explanation of ErrDailyScanLimit
+func DailyScanLimitMessage() string {
+	return "Batas analisis harian untuk paket Anda sudah tercapai. Silakan coba lagi besok."
+}
+
+// enforceSessionLimit signs out the user's least recently used devices beyond the MaxSessions of
+// their tier. Failures are logged only, the new session stays valid either way.
+func (as *AuthService) enforceSessionLimit(userID uint) {
+	limit, err := as.entitlementService().WithContext(as.ctx).Limits(userID)
+	if err != nil {
+		slog.Warn("Failed to load session limit", "user_id", userID, "error", err)
+		return
+	}
+	if limit.MaxSessions == 0 {
+		return
+	}
+	sessions, err := as.authSessionRepo().ListActive(as.queryContext(), userID, time.Now())
+	if err != nil {
+		slog.Warn("Failed to list sessions over the limit", "user_id", userID, "error", err)
+		return
+	}
+	if len(sessions) <= limit.MaxSessions {
+		return
+	}
+	var jtis []string
+	for _, session := range sessions[limit.MaxSessions:] {
+		jtis = append(jtis, session.JTI)
+	}
+	if _, err := as.revoked(as.authSessionRepo().RevokeFamilies(as.queryContext(), jtis, time.Now())); err != nil {
+		slog.Warn("Failed to revoke sessions over the limit", "user_id", userID, "error", err)
+		return
+	}
+	slog.Info(fmt.Sprintf("Signed out %d device(s) of user %d over the session limit", len(jtis), userID), "user_id", userID, "max_sessions", limit.MaxSessions)
+}
+
+// HistoryLimit returns how many analyses the user's history lists, 0 = all
+func (es *EntitlementService) HistoryLimit(userID uint) int {
+	limit, err := es.Limits(userID)
+	if err != nil {
+		slog.Warn("Failed to load history limit, listing all analyses", "user_id", userID, "error", err)
+		return 0
+	}
+	return limit.HistorySize
+}
+
+// activeProductLimits returns the limits per tier, cached for LIMITS_CACHE_SECONDS (default 60).
+// Falls back to the built-in defaults when the database can't be read.
+func activeProductLimits(repo repository.ProductLimitRepo) map[string]models.ProductLimit {
+	limitsCache.mu.Lock()
+	defer limitsCache.mu.Unlock()
+
+	ttl := time.Duration(getIntEnv("LIMITS_CACHE_SECONDS", 60)) * time.Second
+	if limitsCache.limits != nil && time.Since(limitsCache.loadedAt) < ttl {
+		return limitsCache.limits
+	}
+
+	// Shared by every tenant, so loaded outside any request scope
+	limits, err := loadProductLimits(context.Background(), repo)
+	if err != nil {
+		slog.Warn("Failed to load product limits, using defaults", "error", err)
+		if limitsCache.limits != nil {
+			return limitsCache.limits
+		}
+		return models.DefaultProductLimits()
+	}
+	limitsCache.limits, limitsCache.loadedAt = limits, time.Now()
+	return limits
+}
+
+// loadProductLimits reads the saved limits over the built-in defaults
+func loadProductLimits(ctx context.Context, repo repository.ProductLimitRepo) (map[string]models.ProductLimit, error) {
+	saved, err := repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	limits := models.DefaultProductLimits()
+	for _, limit := range saved {
+		limits[limit.Tier] = limit
+	}
+	return limits, nil
+}
+
+// startOfScanDay is midnight of t's day in SCAN_SCHEDULE_TIMEZONE
+func startOfScanDay(t time.Time) time.Time {
+	local := t.In(ScanScheduleLocation())
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+}
@@ -0,0 +1,344 @@
+package services
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"back_wa/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrPushTokenGone means the device token is no longer valid and should be removed
+var ErrPushTokenGone = errors.New("push token no longer registered")
+
+// PushMessage is the platform independent content of a push
+type PushMessage struct {
+	Title string            `json:"title"`
+	Body  string            `json:"body"`
+	Data  map[string]string `json:"data,omitempty"`
+}
+
+// PushSender delivers a message to one device token
+type PushSender interface {
+	Send(token models.PushToken, msg PushMessage) error
+}
+
+var pushHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// FCMSender sends through the Firebase Cloud Messaging HTTP v1 API using a service account
+type FCMSender struct {
+	projectID   string
+	clientEmail string
+	tokenURI    string
+	privateKey  []byte
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// NewFCMSender loads the service account from FCM_SERVICE_ACCOUNT_FILE; returns nil when not configured
+func NewFCMSender() *FCMSender {
+	path := os.Getenv("FCM_SERVICE_ACCOUNT_FILE")
+	if path == "" {
+		return nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		slog.Warn("Failed to read FCM service account", "error", err)
+		return nil
+	}
+
+	var account struct {
+		ProjectID   string `json:"project_id"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(raw, &account); err != nil {
+		slog.Warn("Invalid FCM service account JSON", "error", err)
+		return nil
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	if projectID := os.Getenv("FCM_PROJECT_ID"); projectID != "" {
+		account.ProjectID = projectID
+	}
+
+	return &FCMSender{
+		projectID:   account.ProjectID,
+		clientEmail: account.ClientEmail,
+		tokenURI:    account.TokenURI,
+		privateKey:  []byte(account.PrivateKey),
+	}
+}
+
+// Send implements PushSender
+func (fs *FCMSender) Send(token models.PushToken, msg PushMessage) error {
+	accessToken, err := fs.getAccessToken()
+	if err != nil {
+		return err
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"message": map[string]interface{}{
+			"token":        token.Token,
+			"notification": map[string]string{"title": msg.Title, "body": msg.Body},
+			"data":         msg.Data,
+		},
+	})
+
+	req, err := http.NewRequest(http.MethodPost,
+		fmt.Sprintf("https://fcm.googleapis.com/v1/projects/%s/messages:send", fs.projectID), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := pushHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	if resp.StatusCode == http.StatusNotFound || strings.Contains(string(respBody), "UNREGISTERED") {
+		return ErrPushTokenGone
+	}
+	return fmt.Errorf("fcm error %d: %s", resp.StatusCode, string(respBody))
+}
+
+// getAccessToken exchanges a signed service account JWT for an OAuth access token (cached)
+func (fs *FCMSender) getAccessToken() (string, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.accessToken != "" && time.Now().Before(fs.expiresAt.Add(-time.Minute)) {
+		return fs.accessToken, nil
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(fs.privateKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid FCM private key: %v", err)
+	}
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   fs.clientEmail,
+		"scope": "https://www.googleapis.com/auth/firebase.messaging",
+		"aud":   fs.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(key)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := pushHTTPClient.PostForm(fs.tokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil || tokenResp.AccessToken == "" {
+		return "", fmt.Errorf("failed to obtain FCM access token (status %d)", resp.StatusCode)
+	}
+
+	fs.accessToken = tokenResp.AccessToken
+	fs.expiresAt = now.Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	return fs.accessToken, nil
+}
+
+// WebPushSender sends RFC 8291 encrypted pushes authenticated with VAPID (RFC 8292)
+type WebPushSender struct {
+	subject    string
+	publicKey  string // base64url uncompressed P-256 point, as given to browsers
+	privateKey *ecdsa.PrivateKey
+}
+
+// NewWebPushSender reads VAPID_PUBLIC_KEY, VAPID_PRIVATE_KEY and VAPID_SUBJECT; returns nil when not configured
+func NewWebPushSender() *WebPushSender {
+	publicKey := os.Getenv("VAPID_PUBLIC_KEY")
+	privateKey := os.Getenv("VAPID_PRIVATE_KEY")
+	if publicKey == "" || privateKey == "" {
+		return nil
+	}
+
+	pub, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(publicKey, "="))
+	if err != nil || len(pub) != 65 {
+		slog.Warn("VAPID_PUBLIC_KEY must be a base64url uncompressed P-256 key")
+		return nil
+	}
+	d, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(privateKey, "="))
+	if err != nil || len(d) != 32 {
+		slog.Warn("VAPID_PRIVATE_KEY must be a base64url 32 byte P-256 scalar")
+		return nil
+	}
+
+	return &WebPushSender{
+		subject:   getenv("VAPID_SUBJECT", "mailto:admin@cekwa.id"),
+		publicKey: base64.RawURLEncoding.EncodeToString(pub),
+		privateKey: &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(pub[1:33]),
+				Y:     new(big.Int).SetBytes(pub[33:65]),
+			},
+			D: new(big.Int).SetBytes(d),
+		},
+	}
+}
+
+// PublicKey returns the VAPID application server key for browser subscriptions
+func (ws *WebPushSender) PublicKey() string {
+	return ws.publicKey
+}
+
+// Send implements PushSender
+func (ws *WebPushSender) Send(token models.PushToken, msg PushMessage) error {
+	payload, _ := json.Marshal(msg)
+	body, err := encryptWebPushPayload(payload, token.P256dh, token.Auth)
+	if err != nil {
+		return err
+	}
+
+	endpoint, err := url.Parse(token.Token)
+	if err != nil {
+		return ErrPushTokenGone
+	}
+	vapidJWT, err := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"aud": endpoint.Scheme + "://" + endpoint.Host,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": ws.subject,
+	}).SignedString(ws.privateKey)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, token.Token, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", "86400")
+	req.Header.Set("Urgency", "normal")
+	req.Header.Set("Authorization", fmt.Sprintf("vapid t=%s, k=%s", vapidJWT, ws.publicKey))
+
+	resp, err := pushHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrPushTokenGone
+	case resp.StatusCode >= 300:
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("webpush error %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// encryptWebPushPayload implements the aes128gcm content encoding of RFC 8291 (single record)
+func encryptWebPushPayload(payload []byte, p256dh, authSecret string) ([]byte, error) {
+	uaPublic, err := decodeBase64URL(p256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %v", err)
+	}
+	auth, err := decodeBase64URL(authSecret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth secret: %v", err)
+	}
+
+	curve := ecdh.P256()
+	uaKey, err := curve.NewPublicKey(uaPublic)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %v", err)
+	}
+	asKey, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asKey.PublicKey().Bytes()
+	sharedSecret, err := asKey.ECDH(uaKey)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := append(append([]byte("WebPush: info\x00"), uaPublic...), asPublic...)
+	ikm, err := hkdf.Key(sha256.New, sharedSecret, auth, string(keyInfo), 32)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// 0x02 marks the last (and only) record
+	ciphertext := gcm.Seal(nil, nonce, append(payload, 0x02), nil)
+
+	header := make([]byte, 0, 16+4+1+len(asPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, 4096)
+	header = append(header, byte(len(asPublic)))
+	header = append(header, asPublic...)
+	return append(header, ciphertext...), nil
+}
+
+func decodeBase64URL(s string) ([]byte, error) {
+	s = strings.TrimRight(strings.NewReplacer("+", "-", "/", "_").Replace(s), "=")
+	return base64.RawURLEncoding.DecodeString(s)
+}
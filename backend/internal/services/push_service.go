@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// PushService manages device tokens and per-event preferences, and fans pushes out to devices
+type PushService struct {
+	ctx     context.Context
+	senders map[string]PushSender
+	webPush *WebPushSender
+	pushes  repository.PushRepo
+}
+
+var (
+	pushSendersOnce     sync.Once
+	defaultPushInstance *PushService
+)
+
+// NewPushService creates a push service on the given repository (nil = default repository).
+// Senders are configured once from the environment and shared.
+func NewPushService(pushes repository.PushRepo) *PushService {
+	pushSendersOnce.Do(func() {
+		ps := &PushService{senders: make(map[string]PushSender)}
+		if fcm := NewFCMSender(); fcm != nil {
+			ps.senders[models.PushPlatformFCM] = fcm
+		}
+		if wp := NewWebPushSender(); wp != nil {
+			ps.senders[models.PushPlatformWebPush] = wp
+			ps.webPush = wp
+		}
+		defaultPushInstance = ps
+	})
+	return &PushService{senders: defaultPushInstance.senders, webPush: defaultPushInstance.webPush, pushes: pushes}
+}
+
+// WithContext returns a copy of the service bound to the request context
+func (ps *PushService) WithContext(ctx context.Context) *PushService {
+	return &PushService{ctx: ctx, senders: ps.senders, webPush: ps.webPush, pushes: ps.pushes}
+}
+
+// pushRepo returns the injected push repository or the default one
+func (ps *PushService) pushRepo() repository.PushRepo {
+	if ps.pushes != nil {
+		return ps.pushes
+	}
+	return repository.Default().Pushes
+}
+
+// queryContext returns the service context (background when unset)
+func (ps *PushService) queryContext() context.Context {
+	if ps.ctx != nil {
+		return ps.ctx
+	}
+	return context.Background()
+}
+
+// VAPIDPublicKey returns the WebPush application server key, empty when WebPush is disabled
+func (ps *PushService) VAPIDPublicKey() string {
+	if ps.webPush == nil {
+		return ""
+	}
+	return ps.webPush.PublicKey()
+}
+
+// RegisterToken stores (or re-assigns) a device token for the user
+func (ps *PushService) RegisterToken(userID uint, platform, token, p256dh, auth, userAgent string) (*models.PushToken, error) {
+	if platform != models.PushPlatformFCM && platform != models.PushPlatformWebPush {
+		return nil, fmt.Errorf("platform must be fcm or webpush")
+	}
+	if token == "" {
+		return nil, fmt.Errorf("token is required")
+	}
+	if platform == models.PushPlatformWebPush && (p256dh == "" || auth == "") {
+		return nil, fmt.Errorf("webpush subscriptions require keys.p256dh and keys.auth")
+	}
+
+	pushes := ps.pushRepo()
+	hash := hashPushToken(token)
+	existing, err := pushes.FindToken(ps.queryContext(), hash)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		existing, err = &models.PushToken{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// A device token belongs to whoever registered it last (e.g. after switching accounts)
+	existing.UserID = userID
+	existing.Platform = platform
+	existing.Token = token
+	existing.TokenHash = hash
+	existing.P256dh = p256dh
+	existing.Auth = auth
+	existing.UserAgent = userAgent
+	if err := pushes.SaveToken(ps.queryContext(), existing); err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+// UnregisterToken removes a device token of the user
+func (ps *PushService) UnregisterToken(userID uint, token string) error {
+	return ps.pushRepo().DeleteToken(ps.queryContext(), userID, hashPushToken(token))
+}
+
+// GetPreferences returns the user's push toggles, defaulting to all enabled and no email digest
+func (ps *PushService) GetPreferences(userID uint) (*models.PushPreference, error) {
+	pref := models.PushPreference{UserID: userID, QRExpiring: true, AnalysisCompleted: true, PaymentConfirmed: true, EmailDigest: models.DigestOff}
+	if _, err := ps.pushRepo().FindPreferences(ps.queryContext(), userID, &pref); err != nil {
+		return nil, err
+	}
+	return &pref, nil
+}
+
+// UpdatePreferences persists the user's push toggles
+func (ps *PushService) UpdatePreferences(pref models.PushPreference) error {
+	return ps.pushRepo().SavePreferences(ps.queryContext(), &pref)
+}
+
+// SendToUser pushes to every device of the user if they opted in for the notification type.
+// Tokens rejected as unregistered are deleted.
+func (ps *PushService) SendToUser(userID uint, notificationType string, msg PushMessage) {
+	if len(ps.senders) == 0 {
+		return
+	}
+
+	pref, err := ps.GetPreferences(userID)
+	if err != nil || !pref.Allows(notificationType) {
+		return
+	}
+
+	pushes := ps.pushRepo()
+	tokens, err := pushes.ListTokens(ps.queryContext(), userID)
+	if err != nil {
+		slog.Warn("Failed to load push tokens", "user_id", userID, "error", err)
+		return
+	}
+
+	if msg.Data == nil {
+		msg.Data = map[string]string{}
+	}
+	msg.Data["type"] = notificationType
+
+	for _, token := range tokens {
+		sender, ok := ps.senders[token.Platform]
+		if !ok {
+			continue
+		}
+		if err := sender.Send(token, msg); err != nil {
+			if errors.Is(err, ErrPushTokenGone) {
+				pushes.DeleteTokenByID(ps.queryContext(), token.ID)
+				continue
+			}
+			slog.Warn(fmt.Sprintf("Push via %s failed", token.Platform), "user_id", userID, "error", err)
+			continue
+		}
+		pushes.TouchToken(ps.queryContext(), token.ID, time.Now())
+	}
+}
+
+// SendToUserAsync is the non-blocking variant used from event paths
+func (ps *PushService) SendToUserAsync(userID uint, notificationType string, msg PushMessage) {
+	go ps.WithContext(detachedContext(ps.ctx)).SendToUser(userID, notificationType, msg)
+}
+
+func hashPushToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
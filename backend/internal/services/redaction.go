@@ -0,0 +1,118 @@
+package services
+
+import (
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxPhoneRevealDuration caps how long an admin can turn phone number redaction off
+const MaxPhoneRevealDuration = 4 * time.Hour
+
+var (
+	// WhatsApp JIDs ("6281234567890:12@s.whatsapp.net"), international numbers ("+6281234567890")
+	// and Indonesian mobile numbers ("6281234567890", "081234567890")
+	phoneJIDPattern   = regexp.MustCompile(`\b(\d{6,15})((?::\d+)?@(?:s\.whatsapp\.net|c\.us))`)
+	phoneTextPattern  = regexp.MustCompile(`\+\d{8,15}\b|\b(?:62|0)8\d{7,12}\b`)
+	phoneRevealMu     sync.RWMutex
+	phoneRevealUntil  time.Time
+	phoneRedactionOff bool
+)
+
+// RedactPhone masks a phone number or JID, keeping the country code and the last 3 digits:
+// "6281234567890" becomes "62********890", "6281234567890:12@s.whatsapp.net" becomes "62********890:12@s.whatsapp.net"
+func RedactPhone(raw string) string {
+	if raw == "" {
+		return raw
+	}
+	user, suffix := raw, ""
+	if i := strings.IndexAny(raw, ":@"); i >= 0 {
+		user, suffix = raw[:i], raw[i:]
+	}
+
+	digits := NormalizePhoneNumber(user)
+	if len(digits) <= 5 {
+		return strings.Repeat("*", len(digits)) + suffix
+	}
+	return digits[:2] + strings.Repeat("*", len(digits)-5) + digits[len(digits)-3:] + suffix
+}
+
+// RedactText masks every phone number and JID found in free text (log lines, debug payloads)
+func RedactText(text string) string {
+	text = phoneJIDPattern.ReplaceAllStringFunc(text, RedactPhone)
+	return phoneTextPattern.ReplaceAllStringFunc(text, RedactPhone)
+}
+
+// PhoneRedactionActive reports whether logs are redacted right now (LOG_REDACT_PHONES and no admin reveal)
+func PhoneRedactionActive() bool {
+	if phoneRedactionOff {
+		return false
+	}
+	phoneRevealMu.RLock()
+	defer phoneRevealMu.RUnlock()
+	return !time.Now().Before(phoneRevealUntil)
+}
+
+// RevealPhonesInLogs turns log redaction off for d (capped at MaxPhoneRevealDuration); d <= 0 turns it back on.
+// Returns the time redaction resumes.
+func RevealPhonesInLogs(d time.Duration) time.Time {
+	if d > MaxPhoneRevealDuration {
+		d = MaxPhoneRevealDuration
+	}
+	phoneRevealMu.Lock()
+	defer phoneRevealMu.Unlock()
+	if d <= 0 {
+		phoneRevealUntil = time.Time{}
+	} else {
+		phoneRevealUntil = time.Now().Add(d)
+	}
+	return phoneRevealUntil
+}
+
+// PhoneRevealUntil returns when an admin reveal ends, nil when none is running
+func PhoneRevealUntil() *time.Time {
+	phoneRevealMu.RLock()
+	defer phoneRevealMu.RUnlock()
+	if !time.Now().Before(phoneRevealUntil) {
+		return nil
+	}
+	until := phoneRevealUntil
+	return &until
+}
+
+// redactingWriter masks phone numbers in everything written through it
+type redactingWriter struct {
+	out io.Writer
+}
+
+// RedactingWriter wraps out so phone numbers written through it are masked while redaction is active,
+// e.g. as the output of the structured logger
+func RedactingWriter(out io.Writer) io.Writer {
+	return redactingWriter{out: out}
+}
+
+func (rw redactingWriter) Write(p []byte) (int, error) {
+	if !PhoneRedactionActive() {
+		return rw.out.Write(p)
+	}
+	if _, err := io.WriteString(rw.out, RedactText(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// InstallLogRedaction routes the standard logger (and the SQL log written through it) through phone
+// number redaction. LOG_REDACT_PHONES=false keeps full numbers in the logs.
+func InstallLogRedaction() {
+	phoneRedactionOff = strings.EqualFold(os.Getenv("LOG_REDACT_PHONES"), "false")
+	if phoneRedactionOff {
+		slog.Warn("LOG_REDACT_PHONES=false, phone numbers are logged in full")
+		return
+	}
+	log.SetOutput(redactingWriter{out: os.Stderr})
+}
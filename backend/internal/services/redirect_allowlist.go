@@ -0,0 +1,121 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+
+	"back_wa/internal/models"
+)
+
+// ErrRedirectNotAllowed is returned when a requested redirect base URL is not in the allow-list
+var ErrRedirectNotAllowed = errors.New("redirect_not_allowed")
+
+// RedirectAllowList holds the frontend origins that payment redirects may point to
+type RedirectAllowList struct {
+	defaultBaseURL string
+	origins        map[string]bool
+}
+
+// NewRedirectAllowList builds the allow-list from FRONTEND_BASE_URL and FRONTEND_ALLOWED_ORIGINS
+// FRONTEND_ALLOWED_ORIGINS is a comma separated list, e.g. "https://cekwa.id,https://partner.example.com"
+func NewRedirectAllowList() *RedirectAllowList {
+	defaultBaseURL := os.Getenv("FRONTEND_BASE_URL")
+	if defaultBaseURL == "" {
+		defaultBaseURL = "http://localhost:3000"
+	}
+	defaultBaseURL = strings.TrimRight(defaultBaseURL, "/")
+
+	al := &RedirectAllowList{
+		defaultBaseURL: defaultBaseURL,
+		origins:        make(map[string]bool),
+	}
+
+	if origin, err := normalizeOrigin(defaultBaseURL); err == nil {
+		al.origins[origin] = true
+	} else {
+		slog.Warn("FRONTEND_BASE_URL is not a valid URL", "error", err)
+	}
+
+	for _, raw := range strings.Split(os.Getenv("FRONTEND_ALLOWED_ORIGINS"), ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		origin, err := normalizeOrigin(raw)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("Ignoring invalid FRONTEND_ALLOWED_ORIGINS entry %q", raw), "error", err)
+			continue
+		}
+		al.origins[origin] = true
+	}
+
+	return al
+}
+
+// Resolve returns the base URL to build redirects from.
+// An empty override falls back to FRONTEND_BASE_URL; a non-empty override must match an allowed origin.
+func (al *RedirectAllowList) Resolve(override string) (string, error) {
+	override = strings.TrimSpace(override)
+	if override == "" {
+		return al.defaultBaseURL, nil
+	}
+
+	origin, err := normalizeOrigin(override)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrRedirectNotAllowed, err)
+	}
+	if !al.origins[origin] {
+		return "", fmt.Errorf("%w: %s", ErrRedirectNotAllowed, origin)
+	}
+
+	return strings.TrimRight(override, "/"), nil
+}
+
+// ResolveForTenant behaves like Resolve but defaults to, and also allows, the tenant's frontend base URL
+func (al *RedirectAllowList) ResolveForTenant(override string, tenant *models.Tenant) (string, error) {
+	if tenant == nil || tenant.FrontendBaseURL == "" {
+		return al.Resolve(override)
+	}
+
+	tenantBaseURL := strings.TrimRight(tenant.FrontendBaseURL, "/")
+	override = strings.TrimSpace(override)
+	if override == "" {
+		return tenantBaseURL, nil
+	}
+
+	tenantOrigin, err := normalizeOrigin(tenantBaseURL)
+	if err == nil {
+		if origin, err := normalizeOrigin(override); err == nil && origin == tenantOrigin {
+			return strings.TrimRight(override, "/"), nil
+		}
+	}
+	return al.Resolve(override)
+}
+
+// IsAllowed reports whether the given URL points to an allowed frontend origin
+func (al *RedirectAllowList) IsAllowed(rawURL string) bool {
+	origin, err := normalizeOrigin(rawURL)
+	if err != nil {
+		return false
+	}
+	return al.origins[origin]
+}
+
+// normalizeOrigin reduces a URL to its lowercase scheme://host[:port] form
+func normalizeOrigin(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("missing host")
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host), nil
+}
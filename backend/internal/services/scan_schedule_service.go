@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// ErrNoScanSchedule is returned when the user has no scan schedule
+var ErrNoScanSchedule = errors.New("no scan schedule")
+
+// ErrInvalidScanSchedule wraps validation errors of a schedule request
+var ErrInvalidScanSchedule = errors.New("invalid scan schedule")
+
+// ScanScheduleInput is the body of PUT /api/wa/schedule. Unset fields keep their current value,
+// or default to the current weekday/day of month and 09:00 for a new schedule.
+type ScanScheduleInput struct {
+	Enabled      *bool  `json:"enabled"`
+	Frequency    string `json:"frequency"` // weekly or monthly
+	Weekday      *int   `json:"weekday"`
+	DayOfMonth   *int   `json:"day_of_month"`
+	Hour         *int   `json:"hour"`
+	EmailSummary *bool  `json:"email_summary"`
+}
+
+// ScanScheduleService manages the users' scheduled re-analyses
+type ScanScheduleService struct {
+	ctx   context.Context
+	repos *repository.Repositories
+}
+
+// NewScanScheduleService creates a scan schedule service on the given repositories.
+// The zero value ScanScheduleService{} uses the default repositories.
+func NewScanScheduleService(repos *repository.Repositories) *ScanScheduleService {
+	return &ScanScheduleService{repos: repos}
+}
+
+// WithContext returns a copy of the service bound to the request context
+func (ss *ScanScheduleService) WithContext(ctx context.Context) *ScanScheduleService {
+	return &ScanScheduleService{ctx: ctx, repos: ss.repos}
+}
+
+// repositories returns the injected repositories or the default ones
+func (ss *ScanScheduleService) repositories() *repository.Repositories {
+	if ss.repos != nil {
+		return ss.repos
+	}
+	return repository.Default()
+}
+
+// queryContext returns the service context (background when unset)
+func (ss *ScanScheduleService) queryContext() context.Context {
+	if ss.ctx != nil {
+		return ss.ctx
+	}
+	return context.Background()
+}
+
+var (
+	scanScheduleLocOnce sync.Once
+	scanScheduleLoc     *time.Location
+)
+
+// ScanScheduleLocation returns the time zone schedules are defined in: SCAN_SCHEDULE_TIMEZONE
+// (default Asia/Jakarta), falling back to a fixed UTC+7 when the zone database is missing
+func ScanScheduleLocation() *time.Location {
+	scanScheduleLocOnce.Do(func() {
+		name := getenv("SCAN_SCHEDULE_TIMEZONE", "Asia/Jakarta")
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			slog.Warn("Unknown SCAN_SCHEDULE_TIMEZONE, using UTC+7", "timezone", name, "error", err)
+			loc = time.FixedZone("WIB", 7*60*60)
+		}
+		scanScheduleLoc = loc
+	})
+	return scanScheduleLoc
+}
+
+// Get returns the user's schedule, or ErrNoScanSchedule
+func (ss *ScanScheduleService) Get(userID uint) (*models.ScanSchedule, error) {
+	schedule, err := ss.repositories().ScanSchedules.FindByUser(ss.queryContext(), userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoScanSchedule
+	}
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// Save creates or updates the user's schedule and computes its next run from now
+func (ss *ScanScheduleService) Save(userID uint, input ScanScheduleInput, now time.Time) (*models.ScanSchedule, error) {
+	schedule, err := ss.Get(userID)
+	if errors.Is(err, ErrNoScanSchedule) {
+		local := now.In(ScanScheduleLocation())
+		day := local.Day()
+		if day > 28 {
+			day = 28
+		}
+		schedule = &models.ScanSchedule{
+			UserID:     userID,
+			Enabled:    true,
+			Frequency:  models.ScanFrequencyWeekly,
+			Weekday:    int(local.Weekday()),
+			DayOfMonth: day,
+			Hour:       9,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if input.Frequency != "" {
+		if input.Frequency != models.ScanFrequencyWeekly && input.Frequency != models.ScanFrequencyMonthly {
+			return nil, fmt.Errorf("%w: frequency must be weekly or monthly", ErrInvalidScanSchedule)
+		}
+		schedule.Frequency = input.Frequency
+	}
+	if input.Weekday != nil {
+		if *input.Weekday < 0 || *input.Weekday > 6 {
+			return nil, fmt.Errorf("%w: weekday must be 0 (Sunday) to 6", ErrInvalidScanSchedule)
+		}
+		schedule.Weekday = *input.Weekday
+	}
+	if input.DayOfMonth != nil {
+		// Days 29-31 do not exist in every month
+		if *input.DayOfMonth < 1 || *input.DayOfMonth > 28 {
+			return nil, fmt.Errorf("%w: day_of_month must be 1 to 28", ErrInvalidScanSchedule)
+		}
+		schedule.DayOfMonth = *input.DayOfMonth
+	}
+	if input.Hour != nil {
+		if *input.Hour < 0 || *input.Hour > 23 {
+			return nil, fmt.Errorf("%w: hour must be 0 to 23", ErrInvalidScanSchedule)
+		}
+		schedule.Hour = *input.Hour
+	}
+	if input.Enabled != nil {
+		schedule.Enabled = *input.Enabled
+	}
+	if input.EmailSummary != nil {
+		schedule.EmailSummary = *input.EmailSummary
+	}
+	schedule.NextRunAt = schedule.NextRun(now, ScanScheduleLocation())
+
+	if err := ss.repositories().ScanSchedules.Save(ss.queryContext(), schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// Delete removes the user's schedule
+func (ss *ScanScheduleService) Delete(userID uint) error {
+	deleted, err := ss.repositories().ScanSchedules.DeleteByUser(ss.queryContext(), userID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNoScanSchedule
+	}
+	return nil
+}
+
+// Due returns up to limit enabled schedules whose next run is at or before now, oldest first
+func (ss *ScanScheduleService) Due(now time.Time, limit int) ([]models.ScanSchedule, error) {
+	return ss.repositories().ScanSchedules.ListDue(ss.queryContext(), now, limit)
+}
+
+// Claim moves the schedule's next run past now. It returns false when another instance
+// claimed the same run first (its next_run_at no longer matches).
+func (ss *ScanScheduleService) Claim(schedule *models.ScanSchedule, now time.Time) (bool, error) {
+	next := schedule.NextRun(now, ScanScheduleLocation())
+	claimed, err := ss.repositories().ScanSchedules.Claim(ss.queryContext(), schedule.ID, schedule.NextRunAt, next, now)
+	if err != nil || !claimed {
+		return false, err
+	}
+	schedule.NextRunAt = next
+	schedule.LastRunAt = &now
+	return true, nil
+}
+
+// RecordRun stores the outcome of the schedule's last run (last_status, last_error, last_job_id, last_analysis_id)
+func (ss *ScanScheduleService) RecordRun(scheduleID uint, columns map[string]interface{}) error {
+	return ss.repositories().ScanSchedules.UpdateColumns(ss.queryContext(), scheduleID, columns)
+}
+
+// SendSummary emails the analysis report of a scheduled run; every other analysis is reported
+// through SendAnalysisReportAsync
+func (ss *ScanScheduleService) SendSummary(result *models.AnalysisResult) error {
+	result.Scheduled = true
+	return sendAnalysisReport(ss.queryContext(), ss.repositories(), result)
+}
+
+// ScanScheduleInterval returns SCAN_SCHEDULE_CHECK_MINUTES (default 5), how often due schedules
+// are looked up; 0 or less disables the scheduler
+func ScanScheduleInterval() time.Duration {
+	return time.Duration(getIntEnv("SCAN_SCHEDULE_CHECK_MINUTES", 5)) * time.Minute
+}
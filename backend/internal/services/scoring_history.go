@@ -0,0 +1,128 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"back_wa/internal/models"
+)
+
+// ScoringRuleset is one version of the scoring rules and the period analyses were scored with it
+type ScoringRuleset struct {
+	Version       uint                      `json:"version"`        // scoring_configs id, 0 = built-in defaults
+	EffectiveFrom *time.Time                `json:"effective_from"` // nil for the defaults (in effect from the start)
+	EffectiveTo   *time.Time                `json:"effective_to"`   // nil while active
+	Active        bool                      `json:"active"`
+	GoodAverage   float64                   `json:"good_average"`
+	FairAverage   float64                   `json:"fair_average"`
+	Parameters    []models.ScoringParameter `json:"parameters"`
+	Changes       []ScoringChange           `json:"changes"` // compared with the version before, empty for the first
+}
+
+// ScoringChange is one setting that differs from the previous ruleset
+type ScoringChange struct {
+	Key       string      `json:"key,omitempty"` // scoring parameter, empty for the overall averages
+	Parameter string      `json:"parameter,omitempty"`
+	Field     string      `json:"field"` // good, fair, weight, lower_is_better, label, good_average or fair_average
+	From      interface{} `json:"from"`
+	To        interface{} `json:"to"`
+}
+
+// History returns every scoring ruleset newest first, starting with the built-in defaults that
+// applied until the first configuration was saved
+func (ss *ScoringService) History() ([]ScoringRuleset, error) {
+	configs, err := ss.scoringRepo().List(ss.queryContext())
+	if err != nil {
+		return nil, err
+	}
+
+	defaults := models.DefaultScoringConfig()
+	rulesets := []ScoringRuleset{{
+		Version:     0,
+		GoodAverage: defaults.GoodAverage,
+		FairAverage: defaults.FairAverage,
+		Parameters:  defaults.Parameters,
+		Changes:     []ScoringChange{},
+	}}
+	previous := defaults
+	for i := range configs {
+		config := &configs[i]
+		if err := config.DecodeParameters(); err != nil {
+			return nil, fmt.Errorf("failed to decode scoring parameters of version %d: %v", config.ID, err)
+		}
+		from := config.CreatedAt
+		// Versions archived before effective_to was recorded ended when the next one was saved
+		if rulesets[len(rulesets)-1].EffectiveTo == nil {
+			rulesets[len(rulesets)-1].EffectiveTo = &from
+		}
+		rulesets = append(rulesets, ScoringRuleset{
+			Version:       config.ID,
+			EffectiveFrom: &from,
+			EffectiveTo:   config.EffectiveTo,
+			GoodAverage:   config.GoodAverage,
+			FairAverage:   config.FairAverage,
+			Parameters:    config.Parameters,
+			Changes:       diffScoringConfigs(previous, config),
+		})
+		previous = config
+	}
+	last := &rulesets[len(rulesets)-1]
+	last.EffectiveTo, last.Active = nil, true
+
+	// Newest first
+	for i, j := 0, len(rulesets)-1; i < j; i, j = i+1, j-1 {
+		rulesets[i], rulesets[j] = rulesets[j], rulesets[i]
+	}
+	return rulesets, nil
+}
+
+// ScoringVersionAt returns the version of history (newest first) in effect at t
+func ScoringVersionAt(history []ScoringRuleset, t time.Time) uint {
+	for _, ruleset := range history {
+		if ruleset.EffectiveFrom == nil || !t.Before(*ruleset.EffectiveFrom) {
+			return ruleset.Version
+		}
+	}
+	return 0
+}
+
+// diffScoringConfigs lists the settings of next that differ from previous
+func diffScoringConfigs(previous, next *models.ScoringConfig) []ScoringChange {
+	changes := []ScoringChange{}
+	if previous.GoodAverage != next.GoodAverage {
+		changes = append(changes, ScoringChange{Field: "good_average", From: previous.GoodAverage, To: next.GoodAverage})
+	}
+	if previous.FairAverage != next.FairAverage {
+		changes = append(changes, ScoringChange{Field: "fair_average", From: previous.FairAverage, To: next.FairAverage})
+	}
+
+	before := make(map[string]models.ScoringParameter, len(previous.Parameters))
+	for _, p := range previous.Parameters {
+		before[p.Key] = p
+	}
+	for _, p := range next.Parameters {
+		old, ok := before[p.Key]
+		if !ok {
+			continue
+		}
+		change := func(field string, from, to interface{}) {
+			changes = append(changes, ScoringChange{Key: p.Key, Parameter: p.Label, Field: field, From: from, To: to})
+		}
+		if old.Label != p.Label {
+			change("label", old.Label, p.Label)
+		}
+		if old.Good != p.Good {
+			change("good", old.Good, p.Good)
+		}
+		if old.Fair != p.Fair {
+			change("fair", old.Fair, p.Fair)
+		}
+		if old.LowerIsBetter != p.LowerIsBetter {
+			change("lower_is_better", old.LowerIsBetter, p.LowerIsBetter)
+		}
+		if old.Weight != p.Weight {
+			change("weight", old.Weight, p.Weight)
+		}
+	}
+	return changes
+}
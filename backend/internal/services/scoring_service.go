@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// scoringCache keeps the active scoring configuration between analyses. Updates through
+// this process refresh it at once; other instances pick them up after the TTL.
+var scoringCache struct {
+	mu       sync.Mutex
+	config   *models.ScoringConfig
+	loadedAt time.Time
+}
+
+// ScoringService reads and updates the scoring configuration used by CalculateStrength
+type ScoringService struct {
+	ctx      context.Context
+	versions repository.ScoringRepo
+}
+
+// NewScoringService creates a scoring service on the given repository.
+// The zero value ScoringService{} uses the default repository.
+func NewScoringService(versions repository.ScoringRepo) *ScoringService {
+	return &ScoringService{versions: versions}
+}
+
+// WithContext returns a copy of the service bound to the request context
+func (ss *ScoringService) WithContext(ctx context.Context) *ScoringService {
+	return &ScoringService{ctx: ctx, versions: ss.versions}
+}
+
+// scoringRepo returns the injected scoring repository or the default one
+func (ss *ScoringService) scoringRepo() repository.ScoringRepo {
+	if ss.versions != nil {
+		return ss.versions
+	}
+	return repository.Default().Scoring
+}
+
+// queryContext returns the service context (background when unset)
+func (ss *ScoringService) queryContext() context.Context {
+	if ss.ctx != nil {
+		return ss.ctx
+	}
+	return context.Background()
+}
+
+// Get returns the active scoring configuration, the built-in defaults when none was saved
+func (ss *ScoringService) Get() (*models.ScoringConfig, error) {
+	config, err := ss.scoringRepo().Latest(ss.queryContext())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.DefaultScoringConfig(), nil
+		}
+		return nil, err
+	}
+	if err := config.DecodeParameters(); err != nil {
+		return nil, fmt.Errorf("failed to decode scoring parameters: %v", err)
+	}
+	return config, nil
+}
+
+// Update validates and stores a new scoring configuration. The previous version is kept,
+// archived with the time it stopped being in effect (see History).
+func (ss *ScoringService) Update(config *models.ScoringConfig, adminID uint) (*models.ScoringConfig, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	saved := &models.ScoringConfig{
+		GoodAverage: config.GoodAverage,
+		FairAverage: config.FairAverage,
+		Parameters:  config.Parameters,
+		UpdatedBy:   &adminID,
+		CreatedAt:   now,
+	}
+	if err := saved.EncodeParameters(); err != nil {
+		return nil, err
+	}
+	if err := ss.scoringRepo().Replace(ss.queryContext(), saved, now); err != nil {
+		return nil, err
+	}
+
+	scoringCache.mu.Lock()
+	scoringCache.config, scoringCache.loadedAt = saved, time.Now()
+	scoringCache.mu.Unlock()
+	return saved, nil
+}
+
+// ActiveScoringConfig returns the configuration for the next analysis, cached for
+// SCORING_CONFIG_CACHE_SECONDS (default 60). Falls back to the defaults when the
+// database can't be read, so analyses never fail on scoring.
+func ActiveScoringConfig() *models.ScoringConfig {
+	scoringCache.mu.Lock()
+	defer scoringCache.mu.Unlock()
+
+	ttl := time.Duration(getIntEnv("SCORING_CONFIG_CACHE_SECONDS", 60)) * time.Second
+	if scoringCache.config != nil && time.Since(scoringCache.loadedAt) < ttl {
+		return scoringCache.config
+	}
+
+	config, err := NewScoringService(nil).Get()
+	if err != nil {
+		slog.Warn("Failed to load scoring configuration, using defaults", "error", err)
+		if scoringCache.config != nil {
+			return scoringCache.config
+		}
+		return models.DefaultScoringConfig()
+	}
+	scoringCache.config, scoringCache.loadedAt = config, time.Now()
+	return config
+}
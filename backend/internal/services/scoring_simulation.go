@@ -0,0 +1,119 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	"back_wa/internal/models"
+)
+
+// ScoringInput is the body of POST /api/analysis/simulate: the eight analysis parameters.
+// Scoring (admins only) scores with a draft configuration instead of the active one.
+type ScoringInput struct {
+	TotalChats            *int `json:"total_chats"`
+	TotalContacts         *int `json:"total_contacts"`
+	AccountAgeDays        *int `json:"account_age_days"`
+	TotalGroups           *int `json:"total_groups"`
+	TotalChatWithContact  *int `json:"total_chat_with_contact"`
+	SensitiveContentCount *int `json:"sensitive_content_count"`
+	TotalUnsavedChats     *int `json:"total_unsaved_chats"`
+	UnknownNumberChats    *int `json:"unknown_number_chats"`
+
+	Scoring *models.ScoringConfig `json:"scoring,omitempty"`
+}
+
+// Values returns the parameters keyed by scoring parameter; every one is required and must not be negative
+func (in *ScoringInput) Values() (map[string]int, error) {
+	fields := []struct {
+		key   string
+		value *int
+	}{
+		{models.ScoreTotalChats, in.TotalChats},
+		{models.ScoreTotalContacts, in.TotalContacts},
+		{models.ScoreAccountAgeDays, in.AccountAgeDays},
+		{models.ScoreTotalGroups, in.TotalGroups},
+		{models.ScoreTotalChatWithContact, in.TotalChatWithContact},
+		{models.ScoreSensitiveContent, in.SensitiveContentCount},
+		{models.ScoreTotalUnsavedChats, in.TotalUnsavedChats},
+		{models.ScoreUnknownNumberChats, in.UnknownNumberChats},
+	}
+	values := make(map[string]int, len(fields))
+	for _, field := range fields {
+		if field.value == nil {
+			return nil, fmt.Errorf("%s is required", field.key)
+		}
+		if *field.value < 0 {
+			return nil, fmt.Errorf("%s must not be negative", field.key)
+		}
+		values[field.key] = *field.value
+	}
+	return values, nil
+}
+
+// ScoringRecommendation is the change that would move one parameter to "Baik"
+type ScoringRecommendation struct {
+	Key       string `json:"key"`
+	Parameter string `json:"parameter"`
+	Status    string `json:"status"`
+	Value     int    `json:"value"`
+	Target    int    `json:"target"` // minimum (or, for lower-is-better parameters, maximum) value for "Baik"
+	Message   string `json:"message"`
+}
+
+// ScoringSimulation is the outcome of scoring user-supplied parameters
+type ScoringSimulation struct {
+	Strength        string                       `json:"strength"`
+	AverageScore    float64                      `json:"average_score"` // weighted, 1-3
+	Summary         string                       `json:"summary"`
+	Parameters      []models.ParameterEvaluation `json:"parameters"`
+	Recommendations []ScoringRecommendation      `json:"recommendations"`
+	ScoringVersion  uint                         `json:"scoring_version"` // 0 for the defaults or a draft configuration
+}
+
+// SimulateScoring scores the parameters exactly like an analysis does, and lists what would
+// bring every parameter below "Baik" up to it, highest weight first
+func SimulateScoring(config *models.ScoringConfig, values map[string]int) *ScoringSimulation {
+	strength, summary, evaluations := models.ScoreStrength(config, nil,
+		values[models.ScoreTotalChats], values[models.ScoreTotalContacts], values[models.ScoreAccountAgeDays],
+		values[models.ScoreTotalGroups], values[models.ScoreTotalChatWithContact], values[models.ScoreSensitiveContent],
+		values[models.ScoreTotalUnsavedChats], values[models.ScoreUnknownNumberChats])
+
+	simulation := &ScoringSimulation{
+		Strength:        strength,
+		Summary:         summary,
+		Parameters:      evaluations,
+		Recommendations: []ScoringRecommendation{},
+		ScoringVersion:  config.ID,
+	}
+
+	var weightedScore, totalWeight float64
+	weights := make(map[string]float64, len(config.Parameters))
+	for i, param := range config.Parameters {
+		weights[param.Key] = param.Weight
+		weightedScore += float64(evaluations[i].Score) * param.Weight
+		totalWeight += param.Weight
+		if evaluations[i].Status == "Baik" {
+			continue
+		}
+
+		verb, bound := "Naikkan", "minimal"
+		if param.LowerIsBetter {
+			verb, bound = "Turunkan", "maksimal"
+		}
+		simulation.Recommendations = append(simulation.Recommendations, ScoringRecommendation{
+			Key:       param.Key,
+			Parameter: param.Label,
+			Status:    evaluations[i].Status,
+			Value:     evaluations[i].Value,
+			Target:    param.Good,
+			Message:   fmt.Sprintf("%s %s dari %d ke %s %d agar berstatus Baik", verb, param.Label, evaluations[i].Value, bound, param.Good),
+		})
+	}
+	if totalWeight > 0 {
+		simulation.AverageScore = weightedScore / totalWeight
+	}
+	sort.SliceStable(simulation.Recommendations, func(i, j int) bool {
+		return weights[simulation.Recommendations[i].Key] > weights[simulation.Recommendations[j].Key]
+	})
+	return simulation
+}
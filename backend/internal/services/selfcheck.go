@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"back_wa/internal/database"
+	"back_wa/internal/repository"
+	"back_wa/internal/server"
+)
+
+// Self-check outcomes
+const (
+	SelfCheckPass = "pass"
+	SelfCheckWarn = "warn" // works, but not as production should run (e.g. development defaults)
+	SelfCheckFail = "fail"
+	SelfCheckSkip = "skip" // not configured and not required
+)
+
+// SelfCheckResult is the outcome of one check
+type SelfCheckResult struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Detail     string `json:"detail,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// SelfCheckReport is the outcome of RunSelfCheck; Passed is false when any check failed
+type SelfCheckReport struct {
+	Passed    bool              `json:"passed"`
+	CheckedAt time.Time         `json:"checked_at"`
+	Checks    []SelfCheckResult `json:"checks"`
+}
+
+// selfCheckTimeout bounds every network check
+const selfCheckTimeout = 10 * time.Second
+
+// RunSelfCheck validates the configuration and every external dependency: JWT and signing keys,
+// TLS, field encryption keys, the database, a read-only Xendit call, SMTP login, and writable
+// session/spool directories. It never writes data. The database is connected (without
+// migrating) when the caller has not initialized it.
+func RunSelfCheck(ctx context.Context, dataKeyRepo repository.DataKeyRepo) *SelfCheckReport {
+	report := &SelfCheckReport{Passed: true, CheckedAt: time.Now()}
+	run := func(name string, check func() (string, string)) {
+		start := time.Now()
+		status, detail := check()
+		report.Checks = append(report.Checks, SelfCheckResult{Name: name, Status: status, Detail: detail, DurationMs: time.Since(start).Milliseconds()})
+		if status == SelfCheckFail {
+			report.Passed = false
+		}
+	}
+
+	run("jwt_keys", checkJWTKeys)
+	run("tls", checkTLSConfig)
+	run("database", checkDatabase)
+	run("field_encryption", func() (string, string) { return checkFieldEncryption(ctx, dataKeyRepo) })
+	run("xendit", func() (string, string) { return checkXendit(ctx) })
+	run("smtp", checkSMTP)
+	run("session_directory", checkSessionDirectory)
+	run("spool_directory", func() (string, string) { return checkWritableDir(analysisSpoolDir()) })
+	return report
+}
+
+// Print writes the report as one line per check, followed by the overall result
+func (r *SelfCheckReport) Print(w io.Writer) {
+	for _, check := range r.Checks {
+		line := fmt.Sprintf("[%s] %-18s %5dms", strings.ToUpper(check.Status), check.Name, check.DurationMs)
+		if check.Detail != "" {
+			line += "  " + check.Detail
+		}
+		fmt.Fprintln(w, line)
+	}
+	if r.Passed {
+		fmt.Fprintln(w, "Self-check passed")
+	} else {
+		fmt.Fprintln(w, "Self-check FAILED")
+	}
+}
+
+func checkJWTKeys() (string, string) {
+	keys, err := LoadJWTKeySet()
+	if err != nil {
+		return SelfCheckFail, err.Error()
+	}
+	if err := CheckSigningSecrets(); err != nil {
+		return SelfCheckFail, err.Error()
+	}
+	if keys.ActiveKeyID() == legacyJWTKeyID && os.Getenv("JWT_SECRET") == "" && os.Getenv("JWT_KEYS") == "" {
+		return SelfCheckWarn, "using the built-in development key"
+	}
+	return SelfCheckPass, fmt.Sprintf("signing with key id %q", keys.ActiveKeyID())
+}
+
+func checkTLSConfig() (string, string) {
+	config, err := server.LoadTLSConfig()
+	if err != nil {
+		return SelfCheckFail, err.Error()
+	}
+	if !config.Enabled() {
+		return SelfCheckSkip, "serving plain HTTP (TLS terminated elsewhere)"
+	}
+	return SelfCheckPass, ""
+}
+
+func checkDatabase() (string, string) {
+	if database.GetDB() == nil {
+		if err := database.Connect(); err != nil {
+			return SelfCheckFail, err.Error()
+		}
+	}
+	sqlDB, err := database.GetDB().DB()
+	if err != nil {
+		return SelfCheckFail, err.Error()
+	}
+	if err := sqlDB.Ping(); err != nil {
+		return SelfCheckFail, err.Error()
+	}
+	return SelfCheckPass, ""
+}
+
+// checkFieldEncryption loads the master keys and unwraps every data key with them, without creating keys
+func checkFieldEncryption(ctx context.Context, dataKeyRepo repository.DataKeyRepo) (string, string) {
+	provider, err := LoadFieldMasterKeys()
+	if err != nil {
+		return SelfCheckFail, err.Error()
+	}
+	if database.GetDB() == nil {
+		return SelfCheckSkip, "database unavailable"
+	}
+	keys, err := dataKeyRepo.List(ctx)
+	if err != nil {
+		// The table does not exist before the first start
+		return SelfCheckWarn, fmt.Sprintf("failed to load data keys: %v", err)
+	}
+	for _, key := range keys {
+		if _, err := provider.Unwrap(key.MasterKeyID, key.WrappedKey); err != nil {
+			return SelfCheckFail, fmt.Sprintf("data key %d: %v", key.ID, err)
+		}
+	}
+	if len(keys) == 0 {
+		return SelfCheckPass, "data keys are created on first start"
+	}
+	if provider.KeyID() == devFieldMasterKeyID {
+		return SelfCheckWarn, "using the built-in development master key"
+	}
+	return SelfCheckPass, fmt.Sprintf("%d data keys unwrap with the configured master keys", len(keys))
+}
+
+// checkXendit authenticates with a read-only balance request
+func checkXendit(ctx context.Context) (string, string) {
+	if os.Getenv("XENDIT_SECRET_KEY") == "" {
+		if IsDevelopment() {
+			return SelfCheckWarn, "XENDIT_SECRET_KEY not set, using the development key"
+		}
+		return SelfCheckFail, "XENDIT_SECRET_KEY is not set"
+	}
+	xs := NewXenditService()
+
+	ctx, cancel := context.WithTimeout(ctx, selfCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, xs.BaseURL+"/balance", nil)
+	if err != nil {
+		return SelfCheckFail, err.Error()
+	}
+	req.SetBasicAuth(xs.SecretKey, "")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return SelfCheckFail, fmt.Sprintf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return SelfCheckPass, ""
+	case resp.StatusCode == http.StatusUnauthorized:
+		return SelfCheckFail, "secret key rejected (401)"
+	case resp.StatusCode == http.StatusForbidden:
+		// Authenticated, but the key may not read the balance
+		return SelfCheckPass, "authenticated (key lacks balance permission)"
+	}
+	return SelfCheckFail, fmt.Sprintf("unexpected status %d", resp.StatusCode)
+}
+
+// checkSMTP logs in to the SMTP server the way SendEmail does, without sending anything
+func checkSMTP() (string, string) {
+	username, password := os.Getenv("EMAIL_USERNAME"), os.Getenv("EMAIL_PASSWORD")
+	if username == "" || password == "" {
+		return SelfCheckWarn, "EMAIL_USERNAME/EMAIL_PASSWORD not set, OTP and notification emails will fail"
+	}
+	host := getenv("EMAIL_HOST", "smtp.gmail.com")
+	addr := net.JoinHostPort(host, getenv("EMAIL_PORT", "587"))
+
+	conn, err := net.DialTimeout("tcp", addr, selfCheckTimeout)
+	if err != nil {
+		return SelfCheckFail, err.Error()
+	}
+	conn.SetDeadline(time.Now().Add(selfCheckTimeout))
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return SelfCheckFail, err.Error()
+	}
+	defer client.Close()
+
+	if err := client.Hello("localhost"); err != nil {
+		return SelfCheckFail, err.Error()
+	}
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return SelfCheckFail, fmt.Sprintf("STARTTLS failed: %v", err)
+		}
+	}
+	if ok, _ := client.Extension("AUTH"); ok {
+		if err := client.Auth(smtp.PlainAuth("", username, password, host)); err != nil {
+			return SelfCheckFail, fmt.Sprintf("login failed: %v", err)
+		}
+	}
+	client.Quit()
+	return SelfCheckPass, addr
+}
+
+// checkSessionDirectory checks where whatsmeow keeps device sessions: per-user SQLite files in
+// the working directory, or the Postgres store of WA_STORE_DSN
+func checkSessionDirectory() (string, string) {
+	switch os.Getenv("WA_STORE_DRIVER") {
+	case "postgres", "pgx":
+		if os.Getenv("WA_STORE_DSN") == "" {
+			return SelfCheckFail, "WA_STORE_DSN is required when WA_STORE_DRIVER=postgres"
+		}
+		return SelfCheckSkip, "sessions are stored in Postgres"
+	}
+	return checkWritableDir(".")
+}
+
+// checkWritableDir creates dir if needed and writes and removes a probe file in it
+func checkWritableDir(dir string) (string, string) {
+	abs, _ := filepath.Abs(dir)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return SelfCheckFail, err.Error()
+	}
+	probe, err := os.CreateTemp(dir, ".selfcheck-*")
+	if err != nil {
+		return SelfCheckFail, fmt.Sprintf("%s is not writable: %v", abs, err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return SelfCheckPass, abs
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Auth modes selectable with AUTH_MODE
+const (
+	AuthModeHeader = "header" // Authorization: Bearer <jwt> (default)
+	AuthModeCookie = "cookie" // HttpOnly session cookie + CSRF token
+)
+
+// CSRFHeaderName carries the CSRF token on state-changing requests in cookie mode
+const CSRFHeaderName = "X-CSRF-Token"
+
+// SessionCookieConfig controls optional cookie based sessions
+type SessionCookieConfig struct {
+	Mode           string
+	CookieName     string
+	CSRFCookieName string
+	Domain         string
+	Secure         bool
+	SameSite       http.SameSite
+	MaxAge         time.Duration
+}
+
+// LoadSessionCookieConfig reads AUTH_MODE and the SESSION_COOKIE_* settings
+func LoadSessionCookieConfig() *SessionCookieConfig {
+	cfg := &SessionCookieConfig{
+		Mode:           AuthModeHeader,
+		CookieName:     os.Getenv("SESSION_COOKIE_NAME"),
+		CSRFCookieName: os.Getenv("CSRF_COOKIE_NAME"),
+		Domain:         os.Getenv("SESSION_COOKIE_DOMAIN"),
+		Secure:         os.Getenv("SESSION_COOKIE_SECURE") != "false",
+		SameSite:       http.SameSiteLaxMode,
+		MaxAge:         24 * time.Hour, // matches the JWT lifetime
+	}
+	if strings.EqualFold(os.Getenv("AUTH_MODE"), AuthModeCookie) {
+		cfg.Mode = AuthModeCookie
+	}
+	if cfg.CookieName == "" {
+		cfg.CookieName = "cekwa_session"
+	}
+	if cfg.CSRFCookieName == "" {
+		cfg.CSRFCookieName = "cekwa_csrf"
+	}
+
+	switch strings.ToLower(os.Getenv("SESSION_COOKIE_SAMESITE")) {
+	case "strict":
+		cfg.SameSite = http.SameSiteStrictMode
+	case "none":
+		// Browsers drop SameSite=None cookies that are not Secure
+		cfg.SameSite = http.SameSiteNoneMode
+		cfg.Secure = true
+	}
+	return cfg
+}
+
+// CookieMode reports whether cookie sessions are enabled
+func (c *SessionCookieConfig) CookieMode() bool {
+	return c.Mode == AuthModeCookie
+}
+
+// IssueSession sets the HttpOnly session cookie holding the JWT plus a readable CSRF cookie.
+// Returns the CSRF token so it can also be sent in the response body.
+func (c *SessionCookieConfig) IssueSession(w http.ResponseWriter, token string) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	csrfToken := base64.RawURLEncoding.EncodeToString(buf)
+
+	http.SetCookie(w, c.cookie(c.CookieName, token, true, int(c.MaxAge.Seconds())))
+	// Readable by the frontend so it can echo it back in X-CSRF-Token
+	http.SetCookie(w, c.cookie(c.CSRFCookieName, csrfToken, false, int(c.MaxAge.Seconds())))
+	return csrfToken, nil
+}
+
+// ClearSession expires both session cookies
+func (c *SessionCookieConfig) ClearSession(w http.ResponseWriter) {
+	http.SetCookie(w, c.cookie(c.CookieName, "", true, -1))
+	http.SetCookie(w, c.cookie(c.CSRFCookieName, "", false, -1))
+}
+
+func (c *SessionCookieConfig) cookie(name, value string, httpOnly bool, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   c.Domain,
+		MaxAge:   maxAge,
+		Secure:   c.Secure,
+		HttpOnly: httpOnly,
+		SameSite: c.SameSite,
+	}
+}
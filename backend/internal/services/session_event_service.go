@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log/slog"
+	"time"
+
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+)
+
+// SessionEventService records WhatsApp account events and tells the user about them
+type SessionEventService struct {
+	ctx     context.Context
+	events  repository.SessionEventRepo
+	users   repository.UserRepo
+	tenants repository.TenantRepo
+}
+
+// NewSessionEventService creates a session event service on the given repositories.
+// The zero value SessionEventService{} uses the default repositories.
+func NewSessionEventService(events repository.SessionEventRepo, users repository.UserRepo, tenants repository.TenantRepo) *SessionEventService {
+	return &SessionEventService{events: events, users: users, tenants: tenants}
+}
+
+// WithContext returns a copy of the service bound to the request context
+func (ses *SessionEventService) WithContext(ctx context.Context) *SessionEventService {
+	return &SessionEventService{ctx: ctx, events: ses.events, users: ses.users, tenants: ses.tenants}
+}
+
+// eventRepo returns the injected session event repository or the default one
+func (ses *SessionEventService) eventRepo() repository.SessionEventRepo {
+	if ses.events != nil {
+		return ses.events
+	}
+	return repository.Default().SessionEvents
+}
+
+// userRepo returns the injected user repository or the default one
+func (ses *SessionEventService) userRepo() repository.UserRepo {
+	if ses.users != nil {
+		return ses.users
+	}
+	return repository.Default().Users
+}
+
+// tenantRepo returns the injected tenant repository or the default one
+func (ses *SessionEventService) tenantRepo() repository.TenantRepo {
+	if ses.tenants != nil {
+		return ses.tenants
+	}
+	return repository.Default().Tenants
+}
+
+// queryContext returns the service context (background when unset)
+func (ses *SessionEventService) queryContext() context.Context {
+	if ses.ctx != nil {
+		return ses.ctx
+	}
+	return context.Background()
+}
+
+// RecordRestriction stores a ban/restriction event and emails the account owner
+func (ses *SessionEventService) RecordRestriction(event *models.WhatsAppSessionEvent) error {
+	if err := ses.eventRepo().Create(ses.queryContext(), event); err != nil {
+		return fmt.Errorf("failed to record session event: %v", err)
+	}
+
+	go ses.emailRestriction(event)
+	return nil
+}
+
+// emailRestriction sends the ban/restriction notice using the user's tenant sender identity
+func (ses *SessionEventService) emailRestriction(event *models.WhatsAppSessionEvent) {
+	ctx := ses.queryContext()
+	user, err := ses.userRepo().FindByID(ctx, event.UserID)
+	if err != nil || user.Email == "" {
+		slog.Warn("No email for restriction notice", "user_id", event.UserID, "error", err)
+		return
+	}
+
+	var tenant *models.Tenant
+	if user.TenantID != nil {
+		if t, err := ses.tenantRepo().FindByID(ctx, *user.TenantID); err == nil {
+			tenant = t
+		}
+	}
+
+	subject := "Akun WhatsApp kamu diblokir"
+	detail := "WhatsApp memblokir atau mengunci nomor ini, sehingga sesi dihentikan dan tidak akan disambungkan ulang otomatis."
+	if event.Type == models.SessionEventTempBanned {
+		subject = "Akun WhatsApp kamu dibatasi sementara"
+		detail = "WhatsApp membatasi nomor ini untuk sementara. Sesi dihentikan sampai pembatasan berakhir."
+		if event.ExpiresAt != nil {
+			detail += fmt.Sprintf(" Pembatasan berlaku hingga %s.", event.ExpiresAt.Format("02 Jan 2006 15:04 MST"))
+		}
+	}
+
+	body := fmt.Sprintf(`<h2>%s</h2><p>Nomor: <strong>%s</strong></p><p>%s</p><p>Alasan: %s</p><p>Dicatat pada %s.</p>`,
+		subject, html.EscapeString(event.PhoneNumber), detail, html.EscapeString(event.Reason),
+		event.CreatedAt.Format(time.RFC1123))
+	if err := EmailServiceFor(tenant).SendEmail(user.Email, subject, body); err != nil {
+		slog.Warn("Failed to send restriction email", "user_id", event.UserID, "error", err)
+	}
+}
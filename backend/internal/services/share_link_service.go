@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+)
+
+var (
+	// ErrShareLinkInvalid is returned for unknown links or bad signatures
+	ErrShareLinkInvalid = errors.New("share_link_invalid")
+	// ErrShareLinkExpired is returned once a link is past its expiry or revoked
+	ErrShareLinkExpired = errors.New("share_link_expired")
+	// ErrShareAnalysisNotFound is returned when sharing an analysis the user does not own
+	ErrShareAnalysisNotFound = errors.New("analysis not found")
+)
+
+// ShareLinkService mints and verifies signed URLs for analysis results
+type ShareLinkService struct {
+	ctx      context.Context
+	secret   []byte
+	links    repository.ShareLinkRepo
+	analyses repository.AnalysisRepo
+}
+
+// NewShareLinkService creates a share link service on the given repositories, signing with
+// SIGNED_URL_SECRET (falls back to the JWT key)
+func NewShareLinkService(links repository.ShareLinkRepo, analyses repository.AnalysisRepo) *ShareLinkService {
+	secret := fallbackSigningSecret()
+	if configured := os.Getenv("SIGNED_URL_SECRET"); configured != "" {
+		secret = []byte(configured)
+	}
+	return &ShareLinkService{secret: secret, links: links, analyses: analyses}
+}
+
+// WithContext returns a copy of the service bound to the request context
+func (ss *ShareLinkService) WithContext(ctx context.Context) *ShareLinkService {
+	return &ShareLinkService{ctx: ctx, secret: ss.secret, links: ss.links, analyses: ss.analyses}
+}
+
+// linkRepo returns the injected share link repository or the default one
+func (ss *ShareLinkService) linkRepo() repository.ShareLinkRepo {
+	if ss.links != nil {
+		return ss.links
+	}
+	return repository.Default().ShareLinks
+}
+
+// analysisRepo returns the injected analysis repository or the default one
+func (ss *ShareLinkService) analysisRepo() repository.AnalysisRepo {
+	if ss.analyses != nil {
+		return ss.analyses
+	}
+	return repository.Default().Analyses
+}
+
+// queryContext returns the service context (background when unset)
+func (ss *ShareLinkService) queryContext() context.Context {
+	if ss.ctx != nil {
+		return ss.ctx
+	}
+	return context.Background()
+}
+
+// Create mints a link for one of the user's analyses and returns it with its signed URL
+func (ss *ShareLinkService) Create(userID, analysisID uint, resource string, ttl time.Duration, baseURL string) (*models.AnalysisShareLink, string, error) {
+	if resource != models.ShareResourceJSON && resource != models.ShareResourcePDF {
+		return nil, "", fmt.Errorf("unsupported resource %q", resource)
+	}
+
+	maxTTL := time.Duration(getIntEnv("SIGNED_URL_MAX_TTL_MINUTES", 7*24*60)) * time.Minute
+	if ttl <= 0 {
+		ttl = time.Duration(getIntEnv("SIGNED_URL_DEFAULT_TTL_MINUTES", 60)) * time.Minute
+	}
+	if ttl > maxTTL {
+		ttl = maxTTL
+	}
+
+	// The analysis must belong to the user
+	if _, err := ss.analysisRepo().FindForUser(ss.queryContext(), analysisID, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", ErrShareAnalysisNotFound
+		}
+		return nil, "", err
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", err
+	}
+
+	link := models.AnalysisShareLink{
+		LinkID:     hex.EncodeToString(buf),
+		UserID:     userID,
+		AnalysisID: analysisID,
+		Resource:   resource,
+		ExpiresAt:  time.Now().Add(ttl).Truncate(time.Second),
+	}
+	if err := ss.linkRepo().Create(ss.queryContext(), &link); err != nil {
+		return nil, "", err
+	}
+
+	return &link, ss.signedURL(baseURL, &link), nil
+}
+
+// Revoke invalidates a link owned by the user
+func (ss *ShareLinkService) Revoke(userID uint, linkID string) error {
+	revoked, err := ss.linkRepo().Revoke(ss.queryContext(), userID, linkID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrShareLinkInvalid
+	}
+	return nil
+}
+
+// Resolve verifies the signature and expiry of a link and returns the shared analysis. Anyone
+// holding the link can read it, so the phone number is masked like in the logs.
+func (ss *ShareLinkService) Resolve(linkID string, expires string, signature string) (*models.AnalysisShareLink, *models.SharedAnalysis, error) {
+	expiresUnix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return nil, nil, ErrShareLinkInvalid
+	}
+	if !hmac.Equal([]byte(signature), []byte(ss.sign(linkID, expiresUnix))) {
+		return nil, nil, ErrShareLinkInvalid
+	}
+	if time.Now().Unix() > expiresUnix {
+		return nil, nil, ErrShareLinkExpired
+	}
+
+	link, err := ss.linkRepo().FindByLinkID(ss.queryContext(), linkID)
+	if err != nil {
+		return nil, nil, ErrShareLinkInvalid
+	}
+	if link.RevokedAt != nil || time.Now().After(link.ExpiresAt) {
+		return nil, nil, ErrShareLinkExpired
+	}
+
+	result, err := ss.analysisRepo().FindDetailForUser(ss.queryContext(), link.AnalysisID, link.UserID)
+	if err != nil {
+		return nil, nil, ErrShareLinkInvalid
+	}
+
+	return link, &models.SharedAnalysis{
+		ID:                    result.ID,
+		PhoneNumber:           RedactPhone(result.ScanHistory.PhoneNumber),
+		TotalChats:            result.TotalChats,
+		TotalContacts:         result.TotalContacts,
+		AccountAgeDays:        result.AccountAgeDays,
+		TotalGroups:           result.TotalGroups,
+		TotalChatWithContact:  result.TotalChatWithContact,
+		SensitiveContentCount: result.SensitiveContentCount,
+		TotalUnsavedChats:     result.TotalUnsavedChats,
+		UnknownNumberChats:    result.UnknownNumberChats,
+		Strength:              result.Strength,
+		Summary:               result.Summary,
+		ScanDate:              result.ScanDate,
+		Checksum:              result.Checksum,
+		Parameters:            result.Parameters,
+	}, nil
+}
+
+// signedURL builds <baseURL>/api/shared/analysis/<link_id>?expires=<unix>&signature=<hmac>
+func (ss *ShareLinkService) signedURL(baseURL string, link *models.AnalysisShareLink) string {
+	expires := link.ExpiresAt.Unix()
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("signature", ss.sign(link.LinkID, expires))
+	return fmt.Sprintf("%s/api/shared/analysis/%s?%s", baseURL, link.LinkID, q.Encode())
+}
+
+func (ss *ShareLinkService) sign(linkID string, expires int64) string {
+	mac := hmac.New(sha256.New, ss.secret)
+	mac.Write([]byte(linkID + "|" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrPlanNotFound is returned for unknown or inactive plans
+	ErrPlanNotFound = errors.New("plan not found")
+	// ErrNoSubscription is returned when the user has no subscription covering now
+	ErrNoSubscription = errors.New("no active subscription")
+	// ErrSubscriptionLimitReached is returned when the monthly scan allowance is used up
+	ErrSubscriptionLimitReached = errors.New("subscription scan limit reached")
+)
+
+// SubscriptionService manages plans and the recurring access users buy with them.
+// A subscription is created pending, paid through a regular transaction (Transaction.SubscriptionID)
+// and activated by UpdateTransactionStatus once that transaction is paid.
+type SubscriptionService struct {
+	ctx           context.Context
+	subscriptions repository.SubscriptionRepo
+}
+
+// NewSubscriptionService creates a subscription service on the given repository.
+// The zero value SubscriptionService{} uses the default repository.
+func NewSubscriptionService(subscriptions repository.SubscriptionRepo) *SubscriptionService {
+	return &SubscriptionService{subscriptions: subscriptions}
+}
+
+// WithContext returns a copy of the service bound to the request context
+func (ss *SubscriptionService) WithContext(ctx context.Context) *SubscriptionService {
+	return &SubscriptionService{ctx: ctx, subscriptions: ss.subscriptions}
+}
+
+// subscriptionRepo returns the injected subscription repository or the default one
+func (ss *SubscriptionService) subscriptionRepo() repository.SubscriptionRepo {
+	if ss.subscriptions != nil {
+		return ss.subscriptions
+	}
+	return repository.Default().Subscriptions
+}
+
+// queryContext returns the service context (background when unset)
+func (ss *SubscriptionService) queryContext() context.Context {
+	if ss.ctx != nil {
+		return ss.ctx
+	}
+	return context.Background()
+}
+
+// ListPlans returns the plans ordered by price; inactive plans only when includeInactive is set
+func (ss *SubscriptionService) ListPlans(includeInactive bool) ([]models.Plan, error) {
+	return ss.subscriptionRepo().ListPlans(ss.queryContext(), includeInactive)
+}
+
+// CreatePlan validates and stores a new plan
+func (ss *SubscriptionService) CreatePlan(plan *models.Plan) error {
+	if err := plan.Validate(); err != nil {
+		return err
+	}
+
+	plan.ID = 0
+	return ss.subscriptionRepo().CreatePlan(ss.queryContext(), plan)
+}
+
+// UpdatePlan replaces the editable fields of a plan. Running subscriptions keep the quota they were bought with.
+func (ss *SubscriptionService) UpdatePlan(id uint, changes *models.Plan) (*models.Plan, error) {
+	if err := changes.Validate(); err != nil {
+		return nil, err
+	}
+
+	subscriptions := ss.subscriptionRepo()
+	plan, err := subscriptions.FindPlan(ss.queryContext(), id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPlanNotFound
+		}
+		return nil, err
+	}
+	plan.Code = changes.Code
+	plan.Name = changes.Name
+	plan.Description = changes.Description
+	plan.Price = changes.Price
+	plan.Currency = changes.Currency
+	plan.ScansPerMonth = changes.ScansPerMonth
+	plan.Months = changes.Months
+	plan.IsActive = changes.IsActive
+	if err := subscriptions.UpdatePlan(ss.queryContext(), plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// Subscribe returns the user's pending subscription for the plan, creating it when there is none.
+// The caller creates the payment for it with CreatePaymentRequest.SubscriptionID set.
+func (ss *SubscriptionService) Subscribe(userID uint, planCode string) (*models.Subscription, *models.Plan, error) {
+	subscriptions := ss.subscriptionRepo()
+	plan, err := subscriptions.FindActivePlan(ss.queryContext(), planCode)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrPlanNotFound
+		}
+		return nil, nil, err
+	}
+
+	pending, err := subscriptions.FindPending(ss.queryContext(), userID, plan.ID)
+	if err == nil {
+		return pending, plan, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, err
+	}
+
+	subscription := &models.Subscription{
+		UserID:        userID,
+		PlanID:        plan.ID,
+		Status:        models.SubscriptionPending,
+		ScansPerMonth: plan.ScansPerMonth,
+		Months:        plan.Months,
+	}
+	if err := subscriptions.Create(ss.queryContext(), subscription); err != nil {
+		return nil, nil, err
+	}
+	return subscription, plan, nil
+}
+
+// Activate starts a paid subscription. A renewal bought while another subscription is running
+// starts when that one ends. Activating an already active subscription is a no-op.
+func (ss *SubscriptionService) Activate(subscriptionID uint) (*models.Subscription, error) {
+	var subscription *models.Subscription
+	err := ss.subscriptionRepo().Transaction(ss.queryContext(), func(tx repository.SubscriptionRepo) error {
+		var err error
+		if subscription, err = tx.FindByID(ss.queryContext(), subscriptionID); err != nil {
+			return err
+		}
+		if subscription.Status != models.SubscriptionPending {
+			return nil
+		}
+
+		start := time.Now()
+		latest, err := tx.FindLastEnding(ss.queryContext(), subscription.UserID, start)
+		if err == nil && latest.EndsAt != nil {
+			start = *latest.EndsAt
+		} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		months := subscription.Months
+		if months < 1 {
+			months = 1
+		}
+		end := start.AddDate(0, months, 0)
+		subscription.Status = models.SubscriptionActive
+		subscription.StartsAt = &start
+		subscription.EndsAt = &end
+		subscription.CycleStart = &start
+		subscription.ScansUsed = 0
+		return tx.SaveActivation(ss.queryContext(), subscription)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to activate subscription: %v", err)
+	}
+	return subscription, nil
+}
+
+// Cancel ends a pending or active subscription, e.g. when its payment was refunded. Cancelling
+// an already ended subscription is a no-op.
+func (ss *SubscriptionService) Cancel(subscriptionID uint) error {
+	if err := ss.subscriptionRepo().Cancel(ss.queryContext(), subscriptionID); err != nil {
+		return fmt.Errorf("failed to cancel subscription: %v", err)
+	}
+	return nil
+}
+
+// Current returns the subscription covering now, or ErrNoSubscription
+func (ss *SubscriptionService) Current(userID uint) (*models.SubscriptionStatus, error) {
+	subscription, err := ss.current(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := ss.subscriptionRepo().FindPlan(ss.queryContext(), subscription.PlanID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		plan, err = &models.Plan{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return subscriptionStatus(subscription, plan), nil
+}
+
+// PlanExists reports whether a plan (active or not) has code
+func (ss *SubscriptionService) PlanExists(code string) (bool, error) {
+	return ss.subscriptionRepo().PlanExists(ss.queryContext(), code)
+}
+
+// ConsumeScan counts one analysis against the current cycle of the user's subscription
+func (ss *SubscriptionService) ConsumeScan(userID uint) error {
+	// Concurrent analyses race on the counter; the conditional update makes the limit hold
+	for attempt := 0; attempt < 3; attempt++ {
+		subscription, err := ss.current(userID)
+		if err != nil {
+			return err
+		}
+		if subscription.ScansPerMonth > 0 && subscription.ScansUsed >= subscription.ScansPerMonth {
+			return ErrSubscriptionLimitReached
+		}
+
+		counted, err := ss.subscriptionRepo().ConsumeScan(ss.queryContext(), subscription.ID, subscription.CycleStart)
+		if err != nil {
+			return err
+		}
+		if counted {
+			return nil
+		}
+	}
+	return ErrSubscriptionLimitReached
+}
+
+// current loads the active subscription covering now, with its monthly cycle rolled forward
+func (ss *SubscriptionService) current(userID uint) (*models.Subscription, error) {
+	now := time.Now()
+	subscription, err := ss.subscriptionRepo().FindCurrent(ss.queryContext(), userID, now)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNoSubscription
+		}
+		return nil, err
+	}
+
+	return ss.rollCycle(subscription, now)
+}
+
+// rollCycle moves the cycle start to the monthly cycle containing now and resets the counter
+func (ss *SubscriptionService) rollCycle(subscription *models.Subscription, now time.Time) (*models.Subscription, error) {
+	if subscription.CycleStart == nil || subscription.StartsAt == nil {
+		return subscription, nil
+	}
+
+	cycle := *subscription.CycleStart
+	for !cycle.AddDate(0, 1, 0).After(now) {
+		cycle = cycle.AddDate(0, 1, 0)
+	}
+	if cycle.Equal(*subscription.CycleStart) {
+		return subscription, nil
+	}
+
+	// Only the first request of the new cycle resets the counter
+	subscriptions := ss.subscriptionRepo()
+	rolled, err := subscriptions.RollCycle(ss.queryContext(), subscription.ID, *subscription.CycleStart, cycle)
+	if err != nil {
+		return nil, err
+	}
+	if !rolled {
+		return subscriptions.FindByID(ss.queryContext(), subscription.ID)
+	}
+	subscription.CycleStart = &cycle
+	subscription.ScansUsed = 0
+	return subscription, nil
+}
+
+// subscriptionStatus builds the user-facing view of a subscription
+func subscriptionStatus(subscription *models.Subscription, plan *models.Plan) *models.SubscriptionStatus {
+	status := &models.SubscriptionStatus{
+		ID:            subscription.ID,
+		PlanCode:      plan.Code,
+		PlanName:      plan.Name,
+		Status:        subscription.Status,
+		StartsAt:      subscription.StartsAt,
+		EndsAt:        subscription.EndsAt,
+		ScansPerMonth: subscription.ScansPerMonth,
+		ScansUsed:     subscription.ScansUsed,
+	}
+	if subscription.Status == models.SubscriptionActive && subscription.EndsAt != nil && !subscription.EndsAt.After(time.Now()) {
+		status.Status = models.SubscriptionExpired
+	}
+	if subscription.ScansPerMonth > 0 {
+		remaining := subscription.ScansPerMonth - subscription.ScansUsed
+		if remaining < 0 {
+			remaining = 0
+		}
+		status.ScansRemaining = &remaining
+	}
+	if subscription.CycleStart != nil {
+		cycleEnd := subscription.CycleStart.AddDate(0, 1, 0)
+		if subscription.EndsAt != nil && cycleEnd.After(*subscription.EndsAt) {
+			cycleEnd = *subscription.EndsAt
+		}
+		status.CycleEndsAt = &cycleEnd
+	}
+	return status
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+)
+
+// TenantService resolves white-label tenants from incoming requests
+type TenantService struct {
+	ctx     context.Context
+	tenants repository.TenantRepo
+}
+
+// NewTenantService creates a tenant service on the given repository.
+// The zero value TenantService{} uses the default repository.
+func NewTenantService(tenants repository.TenantRepo) *TenantService {
+	return &TenantService{tenants: tenants}
+}
+
+// WithContext returns a copy of the service bound to the request context
+func (ts *TenantService) WithContext(ctx context.Context) *TenantService {
+	return &TenantService{ctx: ctx, tenants: ts.tenants}
+}
+
+// tenantRepo returns the injected tenant repository or the default one
+func (ts *TenantService) tenantRepo() repository.TenantRepo {
+	if ts.tenants != nil {
+		return ts.tenants
+	}
+	return repository.Default().Tenants
+}
+
+// queryContext returns the service context (background when unset)
+func (ts *TenantService) queryContext() context.Context {
+	if ts.ctx != nil {
+		return ts.ctx
+	}
+	return context.Background()
+}
+
+// HashAPIKey returns the hex SHA-256 digest stored in tenants.api_key_hash
+func HashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// ResolveRequest finds the tenant for a request, preferring the X-API-Key header over the Host header.
+// Returns nil when the request belongs to the default (first-party) brand.
+func (ts *TenantService) ResolveRequest(r *http.Request) *models.Tenant {
+	if apiKey := strings.TrimSpace(r.Header.Get("X-API-Key")); apiKey != "" {
+		if tenant, err := ts.GetByAPIKey(apiKey); err == nil {
+			return tenant
+		}
+	}
+
+	if tenant, err := ts.GetByHost(r.Host); err == nil {
+		return tenant
+	}
+
+	return nil
+}
+
+// GetByAPIKey looks up an active tenant by its partner API key
+func (ts *TenantService) GetByAPIKey(apiKey string) (*models.Tenant, error) {
+	return ts.tenantRepo().FindActiveByAPIKeyHash(ts.queryContext(), HashAPIKey(apiKey))
+}
+
+// GetByHost looks up an active tenant by request host (port is ignored)
+func (ts *TenantService) GetByHost(host string) (*models.Tenant, error) {
+	host = normalizeHost(host)
+	if host == "" {
+		return nil, fmt.Errorf("empty host")
+	}
+
+	return ts.tenantRepo().FindActiveByHost(ts.queryContext(), host)
+}
+
+// GetByID looks up an active tenant by id
+func (ts *TenantService) GetByID(id uint) (*models.Tenant, error) {
+	return ts.tenantRepo().FindActiveByID(ts.queryContext(), id)
+}
+
+// GetActiveTenants returns all active tenants
+func (ts *TenantService) GetActiveTenants() ([]models.Tenant, error) {
+	return ts.tenantRepo().ListActive(ts.queryContext())
+}
+
+// DefaultBranding returns the first-party brand used when no tenant matches
+func DefaultBranding() models.TenantBranding {
+	return models.TenantBranding{
+		Slug:            "default",
+		BrandName:       getenv("BRAND_NAME", "Cekwa.id"),
+		LogoURL:         os.Getenv("BRAND_LOGO_URL"),
+		FrontendBaseURL: getenv("FRONTEND_BASE_URL", "http://localhost:3000"),
+	}
+}
+
+// EmailServiceFor returns an email sender using the tenant's sender identity when configured
+func EmailServiceFor(tenant *models.Tenant) EmailServiceInterface {
+	if os.Getenv("EMAIL_USERNAME") == "" || os.Getenv("EMAIL_PASSWORD") == "" {
+		return &DevEmailService{}
+	}
+	if tenant == nil {
+		return &EmailService{}
+	}
+	return &EmailService{FromName: tenant.EmailFromName, FromAddress: tenant.EmailFromAddress}
+}
+
+// tenantIDOf returns the tenant's ID, or nil for the default brand
+func tenantIDOf(tenant *models.Tenant) *uint {
+	if tenant == nil {
+		return nil
+	}
+	id := tenant.ID
+	return &id
+}
+
+func normalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return host
+}
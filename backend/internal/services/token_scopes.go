@@ -0,0 +1,90 @@
+package services
+
+import (
+	"errors"
+	"strings"
+)
+
+// Route-group scopes granted at login
+const (
+	ScopeWA       = "wa"
+	ScopePayments = "payments"
+	ScopeAdmin    = "admin"
+)
+
+// ErrInvalidScope is returned when a login requests a scope that does not exist
+var ErrInvalidScope = errors.New("invalid scope requested")
+
+// defaultLoginScopes are granted when a login does not ask for specific scopes
+var defaultLoginScopes = []string{ScopeWA, ScopePayments}
+
+// validateRequestedScopes rejects unknown scope names in a login request
+func validateRequestedScopes(requested []string) error {
+	for _, scope := range requested {
+		switch strings.ToLower(strings.TrimSpace(scope)) {
+		case ScopeWA, ScopePayments, ScopeAdmin:
+		default:
+			return ErrInvalidScope
+		}
+	}
+	return nil
+}
+
+// grantScopes returns the scopes a login receives: the requested ones (default wa + payments)
+// that the role allows. admin is only granted to admins, and always when they use the default set.
+func grantScopes(role string, requested []string) []string {
+	if len(requested) == 0 {
+		requested = defaultLoginScopes
+		if role == "admin" {
+			requested = append(append([]string{}, defaultLoginScopes...), ScopeAdmin)
+		}
+	}
+
+	var granted []string
+	seen := map[string]bool{}
+	for _, scope := range requested {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if seen[scope] || (scope == ScopeAdmin && role != "admin") {
+			continue
+		}
+		seen[scope] = true
+		granted = append(granted, scope)
+	}
+	return granted
+}
+
+// TokenScopes returns the route-group scopes the claims grant. Purpose-scoped tokens (wa:qr)
+// only reach the wa group; tokens minted before scopes existed keep full access until they expire.
+func (c *JWTClaims) TokenScopes() []string {
+	if c.Scope == ScopeWAQR {
+		return []string{ScopeWA}
+	}
+	if len(c.Scopes) == 0 {
+		return grantScopes(c.Role, nil)
+	}
+	return c.Scopes
+}
+
+// HasScope reports whether the claims grant scope
+func (c *JWTClaims) HasScope(scope string) bool {
+	for _, granted := range c.TokenScopes() {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseClaims verifies a token (full or purpose-scoped) and returns its claims
+func (as *AuthService) ParseClaims(tokenString string) (*JWTClaims, error) {
+	return as.parseToken(tokenString)
+}
+
+// TokenScopes verifies a token and returns the route-group scopes it grants
+func (as *AuthService) TokenScopes(tokenString string) ([]string, error) {
+	claims, err := as.parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	return claims.TokenScopes(), nil
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"back_wa/internal/database"
+	"back_wa/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrSessionRevoked is returned for a token whose session was revoked (logout, device removed, password changed)
+	ErrSessionRevoked = errors.New("session has been revoked")
+	// ErrSessionNotFound is returned when revoking a session that is not one of the user's active sessions
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// LoginClient is the device a login comes from, recorded with the session
+type LoginClient struct {
+	IP        string
+	UserAgent string
+}
+
+// sessionTouchInterval limits how often last_used_at is written for one session
+const sessionTouchInterval = 5 * time.Minute
+
+// expiredSessionRetention is how long expired sessions are kept before they are purged
+const expiredSessionRetention = 7 * 24 * time.Hour
+
+// newTokenID returns a random jti
+func newTokenID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// recordSession stores an issued token. Failures are logged only: the token then works but
+// is not listed, like tokens issued before sessions were recorded.
+func (as *AuthService) recordSession(session *models.AuthSession) {
+	sessions := as.authSessionRepo()
+	session.Device = describeUserAgent(session.UserAgent)
+	if len(session.UserAgent) > 255 {
+		session.UserAgent = session.UserAgent[:255]
+	}
+	if err := sessions.Create(as.queryContext(), session); err != nil {
+		slog.Warn("Failed to record auth session", "user_id", session.UserID, "error", err)
+		return
+	}
+	// Opportunistic cleanup of the user's long expired sessions
+	sessions.PurgeExpired(as.queryContext(), session.UserID, time.Now().Add(-expiredSessionRetention))
+}
+
+// ListSessions returns the user's signed-in devices (unrevoked, unexpired account tokens),
+// most recently used first; currentJTI marks the caller's own session
+func (as *AuthService) ListSessions(userID uint, currentJTI string) ([]SessionView, error) {
+	sessions, err := as.authSessionRepo().ListActive(as.queryContext(), userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	views := make([]SessionView, len(sessions))
+	for i := range sessions {
+		views[i] = SessionView{AuthSession: sessions[i], Current: currentJTI != "" && sessions[i].JTI == currentJTI}
+	}
+	return views, nil
+}
+
+// SessionView is a session as listed to its user
+type SessionView struct {
+	models.AuthSession
+	Current bool `json:"current"` // the session of the token making the request
+}
+
+// RevokeSession revokes one of the user's sessions and the scoped tokens minted from it
+func (as *AuthService) RevokeSession(userID, sessionID uint) error {
+	session, err := as.authSessionRepo().FindActive(as.queryContext(), userID, sessionID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	_, err = as.revoked(as.authSessionRepo().RevokeFamilies(as.queryContext(), []string{session.JTI}, time.Now()))
+	return err
+}
+
+// RevokeSessions revokes all of the user's sessions except the one with keepJTI (empty = all)
+// and returns how many were revoked
+func (as *AuthService) RevokeSessions(userID uint, keepJTI string) (int64, error) {
+	return as.revoked(as.authSessionRepo().RevokeUser(as.queryContext(), userID, keepJTI, time.Now()))
+}
+
+// RevokeToken revokes the session of a token the caller holds (logout); tokens without a jti are ignored
+func (as *AuthService) RevokeToken(claims *JWTClaims) error {
+	if claims.ID == "" {
+		return nil
+	}
+	_, err := as.revoked(as.authSessionRepo().RevokeFamilies(as.queryContext(), []string{claims.ID}, time.Now()))
+	return err
+}
+
+// revoked drops the sessions just revoked from the cache and returns how many there were
+func (as *AuthService) revoked(jtis []string, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	for _, jti := range jtis {
+		sessionStates.set(jti, true)
+	}
+	return int64(len(jtis)), nil
+}
+
+// checkSession rejects tokens whose session was revoked. The state is cached for
+// AUTH_SESSION_CACHE_SECONDS (default 30), so a revocation on another instance takes effect
+// within that time; revocations on this instance apply at once. Tokens without a jti (issued
+// before sessions were recorded) and unknown sessions are accepted until they expire.
+func (as *AuthService) checkSession(jti string) error {
+	if jti == "" {
+		return nil
+	}
+	if revoked, ok := sessionStates.get(jti); ok {
+		if revoked {
+			return ErrSessionRevoked
+		}
+		return nil
+	}
+
+	if database.IsDegraded() {
+		return nil
+	}
+	sessions := as.authSessionRepo()
+	session, err := sessions.FindByJTI(as.queryContext(), jti)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.Warn("Failed to check auth session", "error", err)
+			return nil
+		}
+		sessionStates.set(jti, false)
+		return nil
+	}
+
+	revoked := session.RevokedAt != nil
+	sessionStates.set(jti, revoked)
+	if revoked {
+		return ErrSessionRevoked
+	}
+	now := time.Now()
+	if session.LastUsedAt == nil || now.Sub(*session.LastUsedAt) >= sessionTouchInterval {
+		sessions.Touch(as.queryContext(), session.ID, now)
+	}
+	return nil
+}
+
+// sessionStateCache remembers per jti whether the session is revoked
+type sessionStateCache struct {
+	mu      sync.Mutex
+	entries map[string]sessionState
+}
+
+type sessionState struct {
+	revoked   bool
+	checkedAt time.Time
+}
+
+var sessionStates = &sessionStateCache{entries: make(map[string]sessionState)}
+
+func (c *sessionStateCache) ttl() time.Duration {
+	return time.Duration(getIntEnv("AUTH_SESSION_CACHE_SECONDS", 30)) * time.Second
+}
+
+func (c *sessionStateCache) get(jti string) (bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[jti]
+	if !ok || time.Since(entry.checkedAt) >= c.ttl() {
+		return false, false
+	}
+	return entry.revoked, true
+}
+
+func (c *sessionStateCache) set(jti string, revoked bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Drop stale entries once the map grows, it only holds tokens seen within the TTL
+	if len(c.entries) >= 10000 {
+		ttl := c.ttl()
+		for key, entry := range c.entries {
+			if time.Since(entry.checkedAt) >= ttl {
+				delete(c.entries, key)
+			}
+		}
+	}
+	c.entries[jti] = sessionState{revoked: revoked, checkedAt: time.Now()}
+}
+
+// describeUserAgent turns a User-Agent header into a short device label such as "Chrome on Android"
+func describeUserAgent(ua string) string {
+	if ua == "" {
+		return "Perangkat tidak dikenal"
+	}
+
+	os := ""
+	switch {
+	case strings.Contains(ua, "Android"):
+		os = "Android"
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPad"), strings.Contains(ua, "iOS"):
+		os = "iOS"
+	case strings.Contains(ua, "Windows"):
+		os = "Windows"
+	case strings.Contains(ua, "Mac OS"), strings.Contains(ua, "Macintosh"):
+		os = "macOS"
+	case strings.Contains(ua, "Linux"):
+		os = "Linux"
+	}
+
+	client := ""
+	switch {
+	case strings.Contains(ua, "Edg/"):
+		client = "Edge"
+	case strings.Contains(ua, "OPR/"):
+		client = "Opera"
+	case strings.Contains(ua, "Firefox/"):
+		client = "Firefox"
+	case strings.Contains(ua, "Chrome/"):
+		client = "Chrome"
+	case strings.Contains(ua, "Safari/"):
+		client = "Safari"
+	case strings.Contains(ua, "okhttp"), strings.Contains(ua, "Dart/"), strings.Contains(ua, "CFNetwork"):
+		client = "Aplikasi"
+	}
+
+	switch {
+	case client != "" && os != "":
+		return client + " on " + os
+	case client != "":
+		return client
+	case os != "":
+		return os
+	}
+	if len(ua) > 100 {
+		return ua[:100]
+	}
+	return ua
+}
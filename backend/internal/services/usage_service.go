@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+)
+
+// UsageService meters partner (API-key) usage per tenant per month
+type UsageService struct {
+	ctx          context.Context
+	usage        repository.UsageRepo
+	transactions repository.TransactionRepo
+}
+
+// NewUsageService creates a usage service on the given repositories.
+// The zero value UsageService{} uses the default repositories.
+func NewUsageService(usage repository.UsageRepo, transactions repository.TransactionRepo) *UsageService {
+	return &UsageService{usage: usage, transactions: transactions}
+}
+
+// WithContext returns a copy of the service bound to the request context
+func (us *UsageService) WithContext(ctx context.Context) *UsageService {
+	return &UsageService{ctx: ctx, usage: us.usage, transactions: us.transactions}
+}
+
+// usageRepo returns the injected usage repository or the default one
+func (us *UsageService) usageRepo() repository.UsageRepo {
+	if us.usage != nil {
+		return us.usage
+	}
+	return repository.Default().Usage
+}
+
+// transactionRepo returns the injected transaction repository or the default one
+func (us *UsageService) transactionRepo() repository.TransactionRepo {
+	if us.transactions != nil {
+		return us.transactions
+	}
+	return repository.Default().Transactions
+}
+
+// queryContext returns the service context (background when unset)
+func (us *UsageService) queryContext() context.Context {
+	if us.ctx != nil {
+		return us.ctx
+	}
+	return context.Background()
+}
+
+// CurrentPeriod returns the billing period (YYYY-MM) for t
+func CurrentPeriod(t time.Time) string {
+	return t.Format("2006-01")
+}
+
+// ValidPeriod reports whether period is in YYYY-MM form
+func ValidPeriod(period string) bool {
+	_, err := time.Parse("2006-01", period)
+	return err == nil
+}
+
+// Record increments the tenant's counter for metric in the current period
+func (us *UsageService) Record(tenantID uint, metric string) error {
+	now := time.Now()
+	return us.usageRepo().IncrementPartner(us.queryContext(), tenantID, CurrentPeriod(now), metric, now)
+}
+
+// GetUsage returns the tenant's counters for a period
+func (us *UsageService) GetUsage(tenantID uint, period string) ([]models.PartnerUsage, error) {
+	return us.usageRepo().ListPartner(us.queryContext(), tenantID, period)
+}
+
+// RecordUser increments the user's own counter for metric in the current period
+func (us *UsageService) RecordUser(userID uint, metric string) error {
+	now := time.Now()
+	return us.usageRepo().IncrementUser(us.queryContext(), userID, CurrentPeriod(now), metric, now)
+}
+
+// GetUserUsage returns the user's API requests, scans and credits for a period
+func (us *UsageService) GetUserUsage(userID uint, period string) (*models.UserUsageReport, error) {
+	start, err := time.ParseInLocation("2006-01", period, time.Local)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := us.usageRepo().ListUser(us.queryContext(), userID, period)
+	if err != nil {
+		return nil, err
+	}
+	report := &models.UserUsageReport{Period: period, Currency: "IDR"}
+	for _, u := range usage {
+		switch u.Metric {
+		case models.UsageMetricAPIRequest:
+			report.APIRequests = u.Count
+		case models.UsageMetricAnalysis:
+			report.Scans = u.Count
+		}
+	}
+	report.CreditsConsumed = report.Scans
+
+	report.CreditsBought, report.AmountPaid, err = us.transactionRepo().PaidBetween(us.queryContext(), int(userID), start, start.AddDate(0, 1, 0))
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// GetInvoiceLines prices the usage of a period for invoicing.
+// tenantID == nil returns lines for all tenants (billing export).
+func (us *UsageService) GetInvoiceLines(tenantID *uint, period string) ([]models.UsageInvoiceLine, error) {
+	var lines []models.UsageInvoiceLine
+	err := us.EachInvoiceLine(tenantID, period, func(line models.UsageInvoiceLine) error {
+		lines = append(lines, line)
+		return nil
+	})
+	return lines, err
+}
+
+// EachInvoiceLine streams the priced invoice lines of a period to fn, one row at a time
+func (us *UsageService) EachInvoiceLine(tenantID *uint, period string, fn func(models.UsageInvoiceLine) error) error {
+	return us.usageRepo().EachInvoiceLine(us.queryContext(), tenantID, period, func(line models.UsageInvoiceLine) error {
+		line.UnitPrice = usageUnitPrice(line.Metric)
+		line.Amount = line.UnitPrice * float64(line.Quantity)
+		line.Currency = "IDR"
+		return fn(line)
+	})
+}
+
+// usageUnitPrice reads the per-unit price of a metric, e.g. PARTNER_PRICE_ANALYSIS=2500
+func usageUnitPrice(metric string) float64 {
+	var key string
+	switch metric {
+	case models.UsageMetricAPIRequest:
+		key = "PARTNER_PRICE_API_REQUEST"
+	case models.UsageMetricAnalysis:
+		key = "PARTNER_PRICE_ANALYSIS"
+	default:
+		return 0
+	}
+
+	price, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return 0
+	}
+	return price
+}
@@ -0,0 +1,312 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrGoalEmpty is returned when a goal sets neither a target strength nor a parameter target
+	ErrGoalEmpty = errors.New("set target_strength or at least one parameter goal")
+	// ErrInvalidGoal wraps validation failures of a goal request
+	ErrInvalidGoal = errors.New("invalid goal")
+)
+
+// GoalRequest is the body of POST /api/user/goals; it replaces the user's current goal
+type GoalRequest struct {
+	TargetStrength string                 `json:"target_strength"`
+	ParameterGoals []models.ParameterGoal `json:"parameter_goals"`
+}
+
+// GoalProgress compares the latest analysis with the goal and the baseline it was set at
+type GoalProgress struct {
+	Goal             *models.UserGoal        `json:"goal"`
+	LatestAnalysisID *uint                   `json:"latest_analysis_id"`
+	Strength         *StrengthGoalProgress   `json:"strength,omitempty"`
+	Parameters       []ParameterGoalProgress `json:"parameters"`
+	Met              int                     `json:"met"`     // targets reached by the latest analysis
+	Total            int                     `json:"total"`   // targets in the goal
+	Percent          int                     `json:"percent"` // average progress over the targets, 0-100
+	Achieved         bool                    `json:"achieved"`
+}
+
+// StrengthGoalProgress is the progress towards the target strength
+type StrengthGoalProgress struct {
+	Target   string `json:"target"`
+	Baseline string `json:"baseline,omitempty"`
+	Current  string `json:"current,omitempty"`
+	Percent  int    `json:"percent"`
+	Met      bool   `json:"met"`
+}
+
+// ParameterGoalProgress is the progress towards one parameter target
+type ParameterGoalProgress struct {
+	Key           string `json:"key"`
+	Parameter     string `json:"parameter"` // label from the scoring configuration
+	Target        int    `json:"target"`
+	LowerIsBetter bool   `json:"lower_is_better"`
+	Baseline      *int   `json:"baseline"`
+	Current       *int   `json:"current"`
+	Percent       int    `json:"percent"`
+	Met           bool   `json:"met"`
+}
+
+// GoalService stores the users' strength and parameter goals and measures progress
+type GoalService struct {
+	ctx      context.Context
+	goals    repository.GoalRepo
+	analyses repository.AnalysisRepo
+}
+
+// NewGoalService creates a goal service on the given repositories.
+// The zero value GoalService{} uses the default repositories.
+func NewGoalService(goals repository.GoalRepo, analyses repository.AnalysisRepo) *GoalService {
+	return &GoalService{goals: goals, analyses: analyses}
+}
+
+// WithContext returns a copy of the service bound to the request context
+func (gs *GoalService) WithContext(ctx context.Context) *GoalService {
+	return &GoalService{ctx: ctx, goals: gs.goals, analyses: gs.analyses}
+}
+
+// goalRepo returns the injected goal repository or the default one
+func (gs *GoalService) goalRepo() repository.GoalRepo {
+	if gs.goals != nil {
+		return gs.goals
+	}
+	return repository.Default().Goals
+}
+
+// analysisRepo returns the injected analysis repository or the default one
+func (gs *GoalService) analysisRepo() repository.AnalysisRepo {
+	if gs.analyses != nil {
+		return gs.analyses
+	}
+	return repository.Default().Analyses
+}
+
+// queryContext returns the service context (background when unset)
+func (gs *GoalService) queryContext() context.Context {
+	if gs.ctx != nil {
+		return gs.ctx
+	}
+	return context.Background()
+}
+
+// SetGoal validates and stores the user's goal, replacing the previous one. The latest
+// analysis becomes the baseline progress is measured from.
+func (gs *GoalService) SetGoal(userID uint, req GoalRequest) (*GoalProgress, error) {
+	if req.TargetStrength == "" && len(req.ParameterGoals) == 0 {
+		return nil, ErrGoalEmpty
+	}
+	if req.TargetStrength != "" && req.TargetStrength != "Baik" && req.TargetStrength != "Cukup" {
+		return nil, fmt.Errorf("%w: target_strength must be Baik or Cukup", ErrInvalidGoal)
+	}
+	params := scoringParameters()
+	seen := map[string]bool{}
+	for _, goal := range req.ParameterGoals {
+		if _, ok := params[goal.Key]; !ok {
+			return nil, fmt.Errorf("%w: unknown parameter %q", ErrInvalidGoal, goal.Key)
+		}
+		if seen[goal.Key] {
+			return nil, fmt.Errorf("%w: parameter %q is set twice", ErrInvalidGoal, goal.Key)
+		}
+		if goal.Target < 0 {
+			return nil, fmt.Errorf("%w: target of %q must not be negative", ErrInvalidGoal, goal.Key)
+		}
+		seen[goal.Key] = true
+	}
+
+	goal := models.UserGoal{
+		UserID:         userID,
+		TargetStrength: req.TargetStrength,
+		ParameterGoals: req.ParameterGoals,
+	}
+	if goal.ParameterGoals == nil {
+		goal.ParameterGoals = []models.ParameterGoal{}
+	}
+	latest, err := gs.latestAnalysis(userID)
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil {
+		goal.BaselineAnalysisID = &latest.ID
+	}
+
+	if err := gs.goalRepo().Upsert(gs.queryContext(), &goal); err != nil {
+		return nil, err
+	}
+	return gs.Progress(userID)
+}
+
+// Progress returns the user's goal with its progress, nil when no goal is set
+func (gs *GoalService) Progress(userID uint) (*GoalProgress, error) {
+	goal, err := gs.goalRepo().Find(gs.queryContext(), userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	latest, err := gs.latestAnalysis(userID)
+	if err != nil {
+		return nil, err
+	}
+	var baseline *models.AnalysisResult
+	if goal.BaselineAnalysisID != nil {
+		if result, err := gs.analysisRepo().FindForUser(gs.queryContext(), *goal.BaselineAnalysisID, userID); err == nil {
+			baseline = result
+		}
+	}
+	return measureGoal(goal, latest, baseline), nil
+}
+
+// DeleteGoal removes the user's goal; reports whether there was one
+func (gs *GoalService) DeleteGoal(userID uint) (bool, error) {
+	return gs.goalRepo().Delete(gs.queryContext(), userID)
+}
+
+// checkAchieved stamps achieved_at and notifies the user through notifications the first time an
+// analysis meets every target of their goal
+func (gs *GoalService) checkAchieved(result *models.AnalysisResult, notifications *NotificationService) {
+	goal, err := gs.goalRepo().FindUnachieved(gs.queryContext(), result.UserID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.Warn("Failed to load goal", "user_id", result.UserID, "error", err)
+		}
+		return
+	}
+
+	progress := measureGoal(goal, result, nil)
+	if !progress.Achieved {
+		return
+	}
+	marked, err := gs.goalRepo().MarkAchieved(gs.queryContext(), goal.ID, time.Now())
+	if err != nil || !marked {
+		return
+	}
+	notifications.NotifyAsync(result.UserID, models.NotificationGoalAchieved,
+		"Target tercapai",
+		"Selamat! Hasil analisis terbaru memenuhi semua target akun WhatsApp kamu.",
+		map[string]interface{}{"analysis_id": result.ID, "goal_id": goal.ID})
+}
+
+// latestAnalysis returns the user's newest analysis, nil when there is none
+func (gs *GoalService) latestAnalysis(userID uint) (*models.AnalysisResult, error) {
+	result, err := gs.analysisRepo().Latest(gs.queryContext(), userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// measureGoal compares latest (nil = no analysis yet) with the goal; baseline may be nil
+func measureGoal(goal *models.UserGoal, latest, baseline *models.AnalysisResult) *GoalProgress {
+	progress := &GoalProgress{Goal: goal, Parameters: []ParameterGoalProgress{}}
+	if latest != nil {
+		progress.LatestAnalysisID = &latest.ID
+	}
+	percentSum := 0
+
+	if goal.TargetStrength != "" {
+		strength := &StrengthGoalProgress{Target: goal.TargetStrength}
+		if baseline != nil {
+			strength.Baseline = baseline.Strength
+		}
+		if latest != nil {
+			strength.Current = latest.Strength
+			strength.Met = strengthRank[latest.Strength] >= strengthRank[goal.TargetStrength]
+			start := strengthRank[latest.Strength]
+			if baseline != nil {
+				start = strengthRank[baseline.Strength]
+			}
+			strength.Percent = goalPercent(strength.Met, start, strengthRank[latest.Strength], strengthRank[goal.TargetStrength])
+		}
+		progress.Strength = strength
+		progress.Total++
+		percentSum += strength.Percent
+		if strength.Met {
+			progress.Met++
+		}
+	}
+
+	params := scoringParameters()
+	for _, target := range goal.ParameterGoals {
+		param := params[target.Key]
+		entry := ParameterGoalProgress{Key: target.Key, Parameter: param.Label, Target: target.Target, LowerIsBetter: param.LowerIsBetter}
+		if entry.Parameter == "" {
+			entry.Parameter = target.Key
+		}
+		if baseline != nil {
+			if value, ok := baseline.ParameterValue(target.Key); ok {
+				entry.Baseline = &value
+			}
+		}
+		if latest != nil {
+			if value, ok := latest.ParameterValue(target.Key); ok {
+				entry.Current = &value
+				entry.Met = value >= target.Target
+				if param.LowerIsBetter {
+					entry.Met = value <= target.Target
+				}
+				start := value
+				if entry.Baseline != nil {
+					start = *entry.Baseline
+				}
+				entry.Percent = goalPercent(entry.Met, start, value, target.Target)
+			}
+		}
+		progress.Parameters = append(progress.Parameters, entry)
+		progress.Total++
+		percentSum += entry.Percent
+		if entry.Met {
+			progress.Met++
+		}
+	}
+
+	if progress.Total > 0 {
+		progress.Percent = percentSum / progress.Total
+		progress.Achieved = progress.Met == progress.Total
+	}
+	return progress
+}
+
+// goalPercent is how far current has moved from start towards target, 100 once met and
+// at most 99 before; moving away from the target counts as 0
+func goalPercent(met bool, start, current, target int) int {
+	if met {
+		return 100
+	}
+	if target == start {
+		return 0
+	}
+	percent := (current - start) * 100 / (target - start)
+	if percent < 0 {
+		return 0
+	}
+	if percent > 99 {
+		return 99
+	}
+	return percent
+}
+
+// scoringParameters indexes the active scoring parameters by key
+func scoringParameters() map[string]models.ScoringParameter {
+	params := map[string]models.ScoringParameter{}
+	for _, param := range ActiveScoringConfig().Parameters {
+		params[param.Key] = param
+	}
+	return params
+}
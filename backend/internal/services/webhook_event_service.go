@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrWebhookEventNotFound is returned when no webhook event has the given ID
+	ErrWebhookEventNotFound = errors.New("webhook event not found")
+	// ErrWebhookEventInProgress is returned when reprocessing an event another request is applying
+	ErrWebhookEventInProgress = errors.New("webhook event is being processed")
+)
+
+// webhookEventStaleAfter is how long an event may stay received before a redelivery or a
+// reprocess takes it over (the request applying it died)
+const webhookEventStaleAfter = 5 * time.Minute
+
+// WebhookEventFilter narrows GET /api/admin/webhook-events
+type WebhookEventFilter = repository.WebhookEventFilter
+
+// WebhookEventService records inbound payment webhooks so each event is applied once
+type WebhookEventService struct {
+	ctx    context.Context
+	events repository.WebhookEventRepo
+}
+
+// NewWebhookEventService creates a webhook event service on the given repository.
+// The zero value WebhookEventService{} uses the default repository.
+func NewWebhookEventService(events repository.WebhookEventRepo) *WebhookEventService {
+	return &WebhookEventService{events: events}
+}
+
+// WithContext returns a copy of the service bound to the request context
+func (es *WebhookEventService) WithContext(ctx context.Context) *WebhookEventService {
+	return &WebhookEventService{ctx: ctx, events: es.events}
+}
+
+// eventRepo returns the injected webhook event repository or the default one
+func (es *WebhookEventService) eventRepo() repository.WebhookEventRepo {
+	if es.events != nil {
+		return es.events
+	}
+	return repository.Default().WebhookEvents
+}
+
+// queryContext returns the service context (background when unset)
+func (es *WebhookEventService) queryContext() context.Context {
+	if es.ctx != nil {
+		return es.ctx
+	}
+	return context.Background()
+}
+
+// XenditEventID keys a Xendit invoice callback: Xendit retries deliver the same invoice ID and
+// status, while a later status change of the invoice is a new event
+func XenditEventID(payload models.WebhookPayload) string {
+	id := payload.ID
+	if id == "" {
+		id = payload.ExternalID
+	}
+	return id + ":" + strings.ToLower(payload.Status)
+}
+
+// Begin records a received event and claims it for processing. It returns false when the event
+// was already applied or another delivery is applying it; the skipped redelivery is counted.
+// Events that failed before are claimed again.
+func (es *WebhookEventService) Begin(gateway, eventID, externalID, status string, payload []byte) (*models.WebhookEvent, bool, error) {
+	ctx := es.queryContext()
+	event := models.WebhookEvent{
+		Gateway:    gateway,
+		EventID:    eventID,
+		ExternalID: externalID,
+		Status:     status,
+		State:      models.WebhookEventReceived,
+		Attempts:   1,
+		Payload:    string(payload),
+	}
+	inserted, err := es.eventRepo().InsertIfNew(ctx, &event)
+	if err != nil {
+		return nil, false, err
+	}
+	if inserted {
+		return &event, true, nil
+	}
+
+	existing, err := es.eventRepo().FindByEventID(ctx, gateway, eventID)
+	if err != nil {
+		return nil, false, err
+	}
+	claimed, err := es.claim(existing.ID, false)
+	if err != nil {
+		return nil, false, err
+	}
+	if claimed {
+		existing.State = models.WebhookEventReceived
+		existing.Attempts++
+		return existing, true, nil
+	}
+	if err := es.eventRepo().CountDuplicate(ctx, existing.ID); err != nil {
+		return nil, false, err
+	}
+	return existing, false, nil
+}
+
+// claim marks the event received for one more attempt unless it is being applied right now.
+// Processed events are only claimed when force is set (admin reprocess).
+func (es *WebhookEventService) claim(id uint, force bool) (bool, error) {
+	now := time.Now()
+	return es.eventRepo().Claim(es.queryContext(), id, force, now.Add(-webhookEventStaleAfter), now)
+}
+
+// ApplyXendit updates the transaction of a claimed Xendit event and records the outcome
+func (es *WebhookEventService) ApplyXendit(event *models.WebhookEvent, payments *PaymentService) error {
+	var payload models.WebhookPayload
+	err := json.Unmarshal([]byte(event.Payload), &payload)
+	if err != nil {
+		err = fmt.Errorf("invalid stored payload: %w", err)
+	} else {
+		err = payments.WithContext(es.ctx).UpdateTransactionStatus(payload.ExternalID, payload.Status, payload.PaymentChannel)
+	}
+	if finishErr := es.finish(event, err); finishErr != nil && err == nil {
+		return finishErr
+	}
+	return err
+}
+
+// finish records the outcome of an attempt
+func (es *WebhookEventService) finish(event *models.WebhookEvent, applyErr error) error {
+	now := time.Now()
+	updates := map[string]interface{}{"updated_at": now}
+	if applyErr == nil {
+		updates["state"] = models.WebhookEventProcessed
+		updates["processed_at"] = now
+		updates["last_error"] = ""
+		event.State, event.ProcessedAt, event.LastError = models.WebhookEventProcessed, &now, ""
+	} else {
+		updates["state"] = models.WebhookEventFailed
+		updates["last_error"] = truncateRunes(applyErr.Error(), 500)
+		event.State, event.LastError = models.WebhookEventFailed, truncateRunes(applyErr.Error(), 500)
+	}
+	return es.eventRepo().Update(es.queryContext(), event.ID, updates)
+}
+
+// List returns one page of webhook events, newest first, without their payloads
+func (es *WebhookEventService) List(filter WebhookEventFilter, page AdminPage) ([]models.WebhookEvent, int64, error) {
+	return es.eventRepo().List(es.queryContext(), filter, page.listQuery())
+}
+
+// Get returns one webhook event with its raw payload
+func (es *WebhookEventService) Get(id uint) (*models.WebhookEvent, error) {
+	event, err := es.eventRepo().FindByID(es.queryContext(), id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookEventNotFound
+		}
+		return nil, err
+	}
+	return event, nil
+}
+
+// Reprocess applies a stored event again, whatever its state, unless it is being applied right
+// now. The returned event carries the outcome; err is the error of applying it.
+func (es *WebhookEventService) Reprocess(id uint, payments *PaymentService) (*models.WebhookEvent, error) {
+	event, err := es.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if event.Gateway != PaymentGatewayXendit {
+		return nil, fmt.Errorf("reprocessing %s webhook events is not supported", event.Gateway)
+	}
+	claimed, err := es.claim(event.ID, true)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrWebhookEventInProgress
+	}
+	event.Attempts++
+	return event, es.ApplyXendit(event, payments)
+}
@@ -0,0 +1,319 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrWebhookNotFound is returned when the user has no webhook configured
+	ErrWebhookNotFound = errors.New("webhook not configured")
+	// ErrInvalidWebhookURL is returned for URLs that are not absolute https URLs
+	ErrInvalidWebhookURL = errors.New("webhook url must be an absolute https URL")
+)
+
+// webhookBackoff is the wait before each retry; its length plus one is the number of attempts
+var webhookBackoff = []time.Duration{30 * time.Second, 2 * time.Minute, 10 * time.Minute, time.Hour, 6 * time.Hour}
+
+// WebhookService manages the users' outbound webhooks and delivers signed events to them.
+// Receivers verify X-Cekwa-Signature: "t=<unix>,v1=<hex HMAC-SHA256(secret, t + "." + body)>".
+type WebhookService struct {
+	ctx      context.Context
+	client   *http.Client
+	webhooks repository.WebhookRepo
+}
+
+var (
+	webhookClientOnce sync.Once
+	webhookClient     *http.Client
+)
+
+// NewWebhookService creates a webhook service on the given repository (nil = default repository).
+// The HTTP client is created once and shared.
+func NewWebhookService(webhooks repository.WebhookRepo) *WebhookService {
+	webhookClientOnce.Do(func() {
+		dialer := &net.Dialer{Timeout: 5 * time.Second, Control: webhookDialControl}
+		webhookClient = &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 5 * time.Second},
+			// A redirect would bypass the URL the user registered
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+	})
+	return &WebhookService{client: webhookClient, webhooks: webhooks}
+}
+
+// WithContext returns a copy of the service bound to the request context
+func (ws *WebhookService) WithContext(ctx context.Context) *WebhookService {
+	return &WebhookService{ctx: ctx, client: ws.client, webhooks: ws.webhooks}
+}
+
+// webhookRepo returns the injected webhook repository or the default one
+func (ws *WebhookService) webhookRepo() repository.WebhookRepo {
+	if ws.webhooks != nil {
+		return ws.webhooks
+	}
+	return repository.Default().Webhooks
+}
+
+// queryContext returns the service context (background when unset)
+func (ws *WebhookService) queryContext() context.Context {
+	if ws.ctx != nil {
+		return ws.ctx
+	}
+	return context.Background()
+}
+
+// webhookDialControl refuses connections to loopback, private and link-local addresses so a
+// webhook cannot be pointed at internal services (allowed in development for local receivers)
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+	if IsDevelopment() {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("webhook address %s is not allowed", host)
+	}
+	return nil
+}
+
+// validateWebhookURL accepts absolute https URLs (http too in development)
+func validateWebhookURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" || len(raw) > 500 {
+		return "", ErrInvalidWebhookURL
+	}
+	if parsed.Scheme != "https" && !(parsed.Scheme == "http" && IsDevelopment()) {
+		return "", ErrInvalidWebhookURL
+	}
+	return raw, nil
+}
+
+// Get returns the user's webhook or ErrWebhookNotFound
+func (ws *WebhookService) Get(userID uint) (*models.UserWebhook, error) {
+	webhook, err := ws.webhookRepo().FindByUser(ws.queryContext(), userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// Save creates or updates the user's webhook. A secret is generated for new webhooks and when
+// rotateSecret is set; it is returned only then (empty otherwise).
+func (ws *WebhookService) Save(userID uint, rawURL string, active, rotateSecret bool) (*models.UserWebhook, string, error) {
+	webhookURL, err := validateWebhookURL(rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	webhook, err := ws.Get(userID)
+	if err != nil && !errors.Is(err, ErrWebhookNotFound) {
+		return nil, "", err
+	}
+	if webhook == nil {
+		webhook = &models.UserWebhook{UserID: userID}
+		rotateSecret = true
+	}
+
+	secret := ""
+	if rotateSecret {
+		buf := make([]byte, 24)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, "", err
+		}
+		secret = "whsec_" + hex.EncodeToString(buf)
+		webhook.Secret = secret
+	}
+	webhook.URL = webhookURL
+	webhook.Active = active
+	if err := ws.webhookRepo().Save(ws.queryContext(), webhook); err != nil {
+		return nil, "", err
+	}
+	return webhook, secret, nil
+}
+
+// Delete removes the user's webhook; its delivery log is kept
+func (ws *WebhookService) Delete(userID uint) error {
+	deleted, err := ws.webhookRepo().DeleteByUser(ws.queryContext(), userID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// Deliveries returns one page of the user's delivery log, newest first
+func (ws *WebhookService) Deliveries(userID uint, page AdminPage) ([]models.WebhookDelivery, int64, error) {
+	return ws.webhookRepo().ListDeliveries(ws.queryContext(), userID, page.listQuery())
+}
+
+// NotifyAnalysisCompleted queues an analysis.completed event for the user's active webhook and
+// makes the first attempt in the background. Users without a webhook are skipped.
+func (ws *WebhookService) NotifyAnalysisCompleted(result *models.AnalysisResult) {
+	webhook, err := ws.Get(result.UserID)
+	if err != nil {
+		if !errors.Is(err, ErrWebhookNotFound) {
+			slog.Warn("Failed to load webhook", "user_id", result.UserID, "error", err)
+		}
+		return
+	}
+	if !webhook.Active {
+		return
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"event":      models.WebhookEventAnalysisCompleted,
+		"created_at": time.Now().UTC().Format(time.RFC3339),
+		"data":       models.NewAnalysisResponse(result),
+	})
+	if err != nil {
+		slog.Warn("Failed to encode webhook payload", "user_id", result.UserID, "error", err)
+		return
+	}
+
+	now := time.Now()
+	delivery := &models.WebhookDelivery{
+		WebhookID:     webhook.ID,
+		UserID:        webhook.UserID,
+		Event:         models.WebhookEventAnalysisCompleted,
+		Payload:       string(payload),
+		Status:        models.WebhookDeliveryPending,
+		NextAttemptAt: &now,
+	}
+	if err := ws.webhookRepo().CreateDelivery(ws.queryContext(), delivery); err != nil {
+		slog.Warn("Failed to queue webhook delivery", "user_id", result.UserID, "error", err)
+		return
+	}
+	go ws.WithContext(detachedContext(ws.ctx)).attempt(delivery, webhook)
+}
+
+// RetryDue attempts the pending deliveries whose next attempt is due, returning how many were tried
+func (ws *WebhookService) RetryDue(limit int) int {
+	ctx := ws.queryContext()
+	deliveries, err := ws.webhookRepo().ListDueDeliveries(ctx, time.Now(), limit)
+	if err != nil {
+		slog.Warn("Failed to load due webhook deliveries", "error", err)
+		return 0
+	}
+
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		webhook, err := ws.webhookRepo().FindByID(ctx, delivery.WebhookID)
+		if err != nil || !webhook.Active {
+			// Webhook deleted or switched off meanwhile: stop retrying
+			_ = ws.webhookRepo().UpdateDelivery(ctx, delivery.ID, map[string]interface{}{
+				"status": models.WebhookDeliveryFailed, "last_error": "webhook removed or inactive", "next_attempt_at": nil,
+			})
+			continue
+		}
+		ws.attempt(delivery, webhook)
+	}
+	return len(deliveries)
+}
+
+// attempt POSTs the delivery once and schedules the next retry (or gives up) on failure
+func (ws *WebhookService) attempt(delivery *models.WebhookDelivery, webhook *models.UserWebhook) {
+	// Claim the attempt so two workers (or instances) don't send it twice
+	claimed, err := ws.webhookRepo().ClaimAttempt(ws.queryContext(), delivery.ID, delivery.Attempts)
+	if err != nil || !claimed {
+		return
+	}
+	delivery.Attempts++
+
+	code, err := ws.post(webhook, delivery)
+	updates := map[string]interface{}{"response_code": code, "last_error": ""}
+	switch {
+	case err == nil:
+		now := time.Now()
+		updates["status"] = models.WebhookDeliveryDelivered
+		updates["delivered_at"] = now
+		updates["next_attempt_at"] = nil
+	case delivery.Attempts > len(webhookBackoff):
+		updates["status"] = models.WebhookDeliveryFailed
+		updates["last_error"] = truncateRunes(err.Error(), 500)
+		updates["next_attempt_at"] = nil
+		slog.Warn(fmt.Sprintf("Webhook delivery %d failed after %d attempts", delivery.ID, delivery.Attempts), "user_id", delivery.UserID, "error", err)
+	default:
+		updates["last_error"] = truncateRunes(err.Error(), 500)
+		updates["next_attempt_at"] = time.Now().Add(webhookBackoff[delivery.Attempts-1])
+	}
+	if err := ws.webhookRepo().UpdateDelivery(ws.queryContext(), delivery.ID, updates); err != nil {
+		slog.Warn(fmt.Sprintf("Failed to update webhook delivery %d", delivery.ID), "error", err)
+	}
+}
+
+// post sends the signed payload; any non-2xx response counts as a failure
+func (ws *WebhookService) post(webhook *models.UserWebhook, delivery *models.WebhookDelivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(webhook.Secret))
+	mac.Write([]byte(timestamp + "." + delivery.Payload))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader([]byte(delivery.Payload)))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Cekwa-Webhooks/1.0")
+	req.Header.Set("X-Cekwa-Event", delivery.Event)
+	req.Header.Set("X-Cekwa-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set("X-Cekwa-Signature", "t="+timestamp+",v1="+hex.EncodeToString(mac.Sum(nil)))
+
+	resp, err := ws.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// StartWebhookRetryWorker retries due deliveries every WEBHOOK_RETRY_SECONDS (default 30)
+func StartWebhookRetryWorker(webhooks repository.WebhookRepo) {
+	interval := time.Duration(getIntEnv("WEBHOOK_RETRY_SECONDS", 30)) * time.Second
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			NewWebhookService(webhooks).RetryDue(50)
+		}
+	}()
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"time"
@@ -13,6 +14,26 @@ import (
 	"back_wa/internal/models"
 )
 
+// XenditAPIError is a response from Xendit other than success
+type XenditAPIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *XenditAPIError) Error() string {
+	return fmt.Sprintf("xendit API error (status %d): %s", e.StatusCode, e.Body)
+}
+
+// Rejected reports whether Xendit refused the request for good. Timeouts, conflicts, rate limits
+// and server errors leave the outcome open; the request can be retried under the same idempotency key.
+func (e *XenditAPIError) Rejected() bool {
+	switch e.StatusCode {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests:
+		return false
+	}
+	return e.StatusCode >= 400 && e.StatusCode < 500
+}
+
 type XenditService struct {
 	BaseURL      string
 	SecretKey    string
@@ -52,6 +73,21 @@ func NewXenditService() *XenditService {
 	}
 }
 
+// NewXenditServiceForTenant returns a Xendit client using the tenant's own keys when configured
+func NewXenditServiceForTenant(tenant *models.Tenant) *XenditService {
+	xs := NewXenditService()
+	if tenant == nil {
+		return xs
+	}
+	if tenant.XenditSecretKey != "" {
+		xs.SecretKey = tenant.XenditSecretKey
+	}
+	if tenant.XenditWebhookToken != "" {
+		xs.WebhookToken = tenant.XenditWebhookToken
+	}
+	return xs
+}
+
 func (xs *XenditService) CreateInvoice(req models.XenditInvoiceRequest) (*models.XenditInvoiceResponse, error) {
 	// Validate Xendit service configuration
 	if xs.SecretKey == "" {
@@ -62,14 +98,14 @@ func (xs *XenditService) CreateInvoice(req models.XenditInvoiceRequest) (*models
 	}
 
 	url := fmt.Sprintf("%s/v2/invoices", xs.BaseURL)
-	fmt.Printf("🔗 Creating Xendit invoice at: %s\n", url)
+	slog.Debug(fmt.Sprintf("Creating Xendit invoice at: %s", url))
 
 	jsonData, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %v", err)
 	}
 
-	fmt.Printf("📤 Xendit request data: %s\n", string(jsonData))
+	slog.Debug(fmt.Sprintf("Xendit request data: %s", string(jsonData)))
 
 	httpReq, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
@@ -91,8 +127,8 @@ func (xs *XenditService) CreateInvoice(req models.XenditInvoiceRequest) (*models
 		return nil, fmt.Errorf("failed to read Xendit response: %v", err)
 	}
 
-	fmt.Printf("📥 Xendit response status: %d\n", resp.StatusCode)
-	fmt.Printf("📥 Xendit response body: %s\n", string(body))
+	slog.Debug(fmt.Sprintf("Xendit response status: %d", resp.StatusCode))
+	slog.Debug(fmt.Sprintf("Xendit response body: %s", string(body)))
 
 	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("xendit API error (status %d): %s", resp.StatusCode, string(body))
@@ -103,7 +139,7 @@ func (xs *XenditService) CreateInvoice(req models.XenditInvoiceRequest) (*models
 		return nil, fmt.Errorf("failed to unmarshal Xendit response: %v", err)
 	}
 
-	fmt.Printf("✅ Xendit invoice created successfully: %s\n", invoiceResp.ID)
+	slog.Info(fmt.Sprintf("Xendit invoice created successfully: %s", invoiceResp.ID))
 	return &invoiceResp, nil
 }
 
@@ -141,8 +177,151 @@ func (xs *XenditService) GetInvoice(invoiceID string) (*models.XenditInvoiceResp
 	return &invoiceResp, nil
 }
 
+// CreateRefund refunds an invoice payment. Xendit returns the refund it already created for a
+// repeated idempotencyKey, so a retried request never refunds twice.
+func (xs *XenditService) CreateRefund(req models.XenditRefundRequest, idempotencyKey string) (*models.XenditRefundResponse, error) {
+	if xs.SecretKey == "" {
+		return nil, fmt.Errorf("xendit secret key is not configured")
+	}
+
+	url := fmt.Sprintf("%s/refunds", xs.BaseURL)
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	httpReq, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(xs.SecretKey+":")))
+	httpReq.Header.Set("Idempotency-key", idempotencyKey)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to Xendit: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Xendit response: %v", err)
+	}
+
+	slog.Debug(fmt.Sprintf("Xendit refund response status: %d", resp.StatusCode))
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, &XenditAPIError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var refundResp models.XenditRefundResponse
+	if err := json.Unmarshal(body, &refundResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal Xendit response: %v", err)
+	}
+
+	slog.Info(fmt.Sprintf("Xendit refund created: %s (%s)", refundResp.ID, refundResp.Status))
+	return &refundResp, nil
+}
+
+// GetRefund fetches the current state of a refund
+func (xs *XenditService) GetRefund(refundID string) (*models.XenditRefundResponse, error) {
+	url := fmt.Sprintf("%s/refunds/%s", xs.BaseURL, refundID)
+
+	httpReq, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	httpReq.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(xs.SecretKey+":")))
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("xendit API error: %s", string(body))
+	}
+
+	var refundResp models.XenditRefundResponse
+	if err := json.Unmarshal(body, &refundResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+
+	return &refundResp, nil
+}
+
 func (xs *XenditService) VerifyWebhookSignature(payload []byte, signature string) bool {
 	// In production, you should implement proper webhook signature verification
 	// For now, we'll use a simple token-based verification
 	return signature == xs.WebhookToken
 }
+
+func (xs *XenditService) Name() string { return PaymentGatewayXendit }
+
+// CreateCheckout creates a Xendit invoice; the hosted page lets the user pick the payment channel
+func (xs *XenditService) CreateCheckout(req models.GatewayCheckoutRequest) (*models.GatewayCheckout, error) {
+	xenditReq := models.XenditInvoiceRequest{
+		ExternalID:      req.ExternalID,
+		Amount:          req.Amount,
+		Description:     req.Description,
+		InvoiceDuration: 24, // 24 hours
+		Customer: models.XenditCustomer{
+			GivenNames: "Customer",
+			Email:      req.Email,
+		},
+		CustomerNotificationPreference: models.XenditNotificationPreference{
+			InvoiceCreated:  []string{"email"},
+			InvoiceReminder: []string{"email"},
+			InvoicePaid:     []string{"email"},
+			InvoiceExpired:  []string{"email"},
+		},
+		SuccessRedirectURL: req.SuccessRedirectURL,
+		FailureRedirectURL: req.FailureRedirectURL,
+		ShouldSendEmail:    true,
+		Items: []models.XenditItem{
+			{
+				Name:     req.Description,
+				Quantity: 1,
+				Price:    req.Amount,
+				Category: req.Description,
+			},
+		},
+	}
+
+	slog.Debug(fmt.Sprintf("Xendit request prepared: %+v", xenditReq))
+	invoice, err := xs.CreateInvoice(xenditReq)
+	if err != nil {
+		return nil, err
+	}
+	return &models.GatewayCheckout{
+		ID:         invoice.ID,
+		URL:        invoice.InvoiceURL,
+		Status:     invoice.Status,
+		ExpiryDate: invoice.ExpiryDate,
+	}, nil
+}
+
+// CheckoutStatus fetches the transaction's invoice
+func (xs *XenditService) CheckoutStatus(transaction *models.Transaction) (*models.GatewayCheckout, error) {
+	invoice, err := xs.GetInvoice(transaction.InvoiceID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.GatewayCheckout{
+		ID:         invoice.ID,
+		URL:        invoice.InvoiceURL,
+		Status:     invoice.Status,
+		ExpiryDate: invoice.ExpiryDate,
+	}, nil
+}
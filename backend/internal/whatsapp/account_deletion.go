@@ -0,0 +1,76 @@
+package whatsapp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/services"
+)
+
+// wipeUser unlinks and disconnects the user's WhatsApp client and removes its session store,
+// whether or not the session is in memory
+func (m *MultiUserWhatsAppManager) wipeUser(userID uint) error {
+	if err := m.Logout(userID); err != nil {
+		return err
+	}
+	removeSessionStore(userID)
+	return nil
+}
+
+// HandleDeleteAccount handles DELETE /api/user/account. The body confirms the request with
+// {"password": "..."} or {"otp": "..."}; the WhatsApp device is unlinked first so nothing is
+// written for the account while its data is purged.
+func (h *MultiUserWhatsAppHandler) HandleDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := h.extractUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var req services.AccountDeletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	deletion := services.NewAccountDeletionService(h.repos.Users, h.repos.Accounts).WithContext(r.Context())
+	err = deletion.Confirm(userID, req)
+	if errors.Is(err, services.ErrDeletionConfirmationRequired) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, services.ErrDeletionConfirmationInvalid) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to confirm account deletion", "user_id", userID, "error", err)
+		http.Error(w, "Failed to delete account", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.waManager.wipeUser(userID); err != nil {
+		logging.FromContext(r.Context()).Warn("Failed to wipe WhatsApp session before account deletion", "user_id", userID, "error", err)
+	}
+
+	summary, err := deletion.DeleteAccount(userID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to delete account", "user_id", userID, "error", err)
+		http.Error(w, "Failed to delete account", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Account deleted",
+		"data":    summary,
+	})
+}
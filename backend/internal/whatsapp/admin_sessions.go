@@ -0,0 +1,110 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+// ForceDisconnect drops the user's WhatsApp connection. The linked device is kept, so the user can
+// reconnect without scanning again, unless logout also unlinks it (same as the user's own logout).
+// Returns false when the user had no session in memory.
+func (m *MultiUserWhatsAppManager) ForceDisconnect(userID uint, logout bool) (bool, error) {
+	if logout {
+		m.mu.RLock()
+		_, exists := m.userSessions[userID]
+		m.mu.RUnlock()
+		return exists, m.Logout(userID)
+	}
+
+	m.mu.Lock()
+	session, exists := m.userSessions[userID]
+	delete(m.userSessions, userID)
+	m.mu.Unlock()
+
+	if !exists {
+		forgetSessionState(userID)
+		return false, m.repos.Sessions.UpdateStatus(context.Background(), userID, "disconnected")
+	}
+
+	session.mu.Lock()
+	client := session.Client
+	session.Client = nil
+	session.Status = "disconnected"
+	session.Ready = false
+	session.QRCode = ""
+	session.QRExpiresAt = time.Time{}
+	session.clearPairingLocked()
+	session.resetWarmupLocked()
+	session.mu.Unlock()
+	session.ClearAnalysisCache()
+	forgetSessionState(userID)
+
+	if client != nil {
+		// Ignore panics from the underlying client, like Logout does
+		func() { defer func() { recover() }(); client.Disconnect() }()
+	}
+	return true, m.repos.Sessions.UpdateStatus(context.Background(), userID, "disconnected")
+}
+
+// HandleAdminDisconnect handles POST /api/admin/sessions/{user_id}/disconnect (admin only).
+// Body {"logout": true} also unlinks the device.
+func (h *MultiUserWhatsAppHandler) HandleAdminDisconnect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims := middleware.ClaimsFromContext(r.Context())
+
+	userID, err := strconv.ParseUint(mux.Vars(r)["user_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Logout bool `json:"logout"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	wasActive, err := h.waManager.ForceDisconnect(uint(userID), req.Logout)
+	if err != nil {
+		logging.FromContext(r.Context()).Error(fmt.Sprintf("Admin %d failed to disconnect session", claims.UserID), "user_id", userID, "error", err, "admin_id", claims.UserID)
+		http.Error(w, "Failed to disconnect session", http.StatusInternalServerError)
+		return
+	}
+	logging.FromContext(r.Context()).Info(fmt.Sprintf("Session force-disconnected by admin %d (logout=%t)", claims.UserID, req.Logout), "user_id", userID, "admin_id", claims.UserID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"user_id":    userID,
+		"was_active": wasActive,
+		"logged_out": req.Logout,
+	})
+}
+
+// isAdminRequest reports whether the request carries an admin token
+func (h *MultiUserWhatsAppHandler) isAdminRequest(r *http.Request) bool {
+	authHeader := r.Header.Get("Authorization")
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if authHeader == "" || tokenString == authHeader {
+		return false
+	}
+	claims, err := h.authService.ValidateToken(tokenString)
+	return err == nil && claims.Role == "admin"
+}
@@ -0,0 +1,389 @@
+package whatsapp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
+	"back_wa/internal/services"
+)
+
+// Stages reported by analyze() to async jobs
+const (
+	analysisStageQueued     = "queued"
+	analysisStageContacts   = "contacts"
+	analysisStageChats      = "chats"
+	analysisStageScoring    = "scoring"
+	analysisStagePersisting = "persisting"
+	analysisStageDone       = "done"
+)
+
+// analysisProgressFunc receives the stage an analysis reached, its progress (0-100)
+// and the metrics known so far (nil keeps the previous partial result)
+type analysisProgressFunc func(stage string, progress int, partial map[string]interface{})
+
+// analysisPriorities lists the job lanes; ties in the weighted schedule go to the earlier one
+var analysisPriorities = []string{models.AnalysisPriorityPaid, models.AnalysisPriorityTrial, models.AnalysisPriorityBulk}
+
+// analysisJobQueue runs analysis jobs on a fixed number of workers (ANALYSIS_JOB_WORKERS).
+// Each user has at most one queued or running job, so a session never analyzes twice at once.
+// Jobs wait in one lane per priority; when several lanes have work the workers take from them
+// in proportion to their weights, so paying users are served first without starving the rest.
+type analysisJobQueue struct {
+	manager *MultiUserWhatsAppManager
+	jobs    repository.AnalysisJobRepo
+
+	enqueueMu sync.Mutex // serializes the one-active-job check with the insert
+	mu        sync.Mutex
+	cond      *sync.Cond
+	lanes     map[string]*analysisJobLane
+}
+
+// analysisJobLane holds the waiting jobs of one priority and its scheduling counters
+type analysisJobLane struct {
+	pending []queuedAnalysisJob
+	weight  int
+	credit  int // smooth weighted round-robin state
+
+	running    int
+	dispatched int64
+	waitTotal  time.Duration
+}
+
+// queuedAnalysisJob is a job waiting for a worker; onFinish (optional) receives its final status
+type queuedAnalysisJob struct {
+	job      models.AnalysisJob
+	onFinish func(status string)
+	queuedAt time.Time
+}
+
+// AnalysisQueueLane is the queue depth of one priority in GET /api/admin/metrics/whatsapp
+type AnalysisQueueLane struct {
+	Priority   string  `json:"priority"`
+	Weight     int     `json:"weight"`
+	Queued     int     `json:"queued"`
+	Running    int     `json:"running"`
+	Dispatched int64   `json:"dispatched"`
+	AvgWaitMs  float64 `json:"avg_wait_ms"`
+	// Wait of the oldest queued job, 0 when the lane is empty
+	OldestWaitMs int64 `json:"oldest_wait_ms"`
+}
+
+// analysisJobWorkers returns ANALYSIS_JOB_WORKERS (default 4)
+func analysisJobWorkers() int {
+	if v, err := strconv.Atoi(os.Getenv("ANALYSIS_JOB_WORKERS")); err == nil && v > 0 {
+		return v
+	}
+	return 4
+}
+
+// analysisPriorityWeight returns ANALYSIS_PRIORITY_WEIGHT_<PRIORITY> (defaults paid 6, trial 2, bulk 1)
+func analysisPriorityWeight(priority string) int {
+	def := map[string]int{
+		models.AnalysisPriorityPaid:  6,
+		models.AnalysisPriorityTrial: 2,
+		models.AnalysisPriorityBulk:  1,
+	}[priority]
+	if weight := envInt("ANALYSIS_PRIORITY_WEIGHT_"+strings.ToUpper(priority), def); weight > 0 {
+		return weight
+	}
+	return def
+}
+
+func newAnalysisJobQueue(manager *MultiUserWhatsAppManager, jobs repository.AnalysisJobRepo) *analysisJobQueue {
+	q := &analysisJobQueue{manager: manager, jobs: jobs, lanes: make(map[string]*analysisJobLane)}
+	for _, priority := range analysisPriorities {
+		q.lanes[priority] = &analysisJobLane{weight: analysisPriorityWeight(priority)}
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// start fails jobs a previous process left unfinished and launches the workers
+func (q *analysisJobQueue) start() {
+	ctx := context.Background()
+	if unfinished, err := q.jobs.ListUnfinished(ctx); err != nil {
+		slog.Warn("Failed to load unfinished analysis jobs", "error", err)
+	} else {
+		for _, job := range unfinished {
+			q.finish(job.JobID, models.AnalysisJobFailed, map[string]interface{}{"error": "interrupted by server restart"})
+		}
+	}
+
+	for i := 0; i < analysisJobWorkers(); i++ {
+		go q.work()
+	}
+}
+
+// Enqueue creates a job for the user, or returns the queued/running one (created=false).
+// force stores a new result even when it duplicates a recent one.
+func (q *analysisJobQueue) Enqueue(ctx context.Context, userID uint, force bool) (*models.AnalysisJob, bool, error) {
+	q.enqueueMu.Lock()
+	defer q.enqueueMu.Unlock()
+
+	active, err := q.jobs.ActiveForUser(ctx, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	if active != nil {
+		return active, false, nil
+	}
+	if q.Queued() >= analysisMaxQueuedJobs() {
+		return nil, false, ErrAnalysisBusy
+	}
+
+	jobID, err := newAnalysisJobID()
+	if err != nil {
+		return nil, false, err
+	}
+	job := &models.AnalysisJob{
+		JobID:    jobID,
+		UserID:   userID,
+		Status:   models.AnalysisJobQueued,
+		Stage:    analysisStageQueued,
+		Priority: q.priorityFor(ctx, userID),
+		Force:    force,
+	}
+	if err := q.jobs.Create(ctx, job); err != nil {
+		return nil, false, err
+	}
+
+	q.push(*job, nil)
+	return job, true, nil
+}
+
+// priorityFor derives the job priority from the user's entitlement tier. A failed lookup
+// falls back to the paid lane, the user already passed the payment check.
+func (q *analysisJobQueue) priorityFor(ctx context.Context, userID uint) string {
+	tier, err := services.NewEntitlementService(q.manager.repos).WithContext(ctx).Tier(userID)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Failed to determine job priority", "user_id", userID, "error", err)
+		return models.AnalysisPriorityPaid
+	}
+	if tier == services.EntitlementTierTrial {
+		return models.AnalysisPriorityTrial
+	}
+	return models.AnalysisPriorityPaid
+}
+
+// push hands a stored job to the workers
+func (q *analysisJobQueue) push(job models.AnalysisJob, onFinish func(status string)) {
+	q.mu.Lock()
+	lane, ok := q.lanes[job.Priority]
+	if !ok {
+		lane = q.lanes[models.AnalysisPriorityPaid]
+	}
+	lane.pending = append(lane.pending, queuedAnalysisJob{job: job, onFinish: onFinish, queuedAt: time.Now()})
+	q.mu.Unlock()
+	q.cond.Signal()
+}
+
+// next picks the lane to serve with smooth weighted round-robin over the non-empty lanes.
+// The caller must hold q.mu; nil means nothing is queued.
+func (q *analysisJobQueue) next() *analysisJobLane {
+	var best *analysisJobLane
+	total := 0
+	for _, priority := range analysisPriorities {
+		lane := q.lanes[priority]
+		if len(lane.pending) == 0 {
+			continue
+		}
+		lane.credit += lane.weight
+		total += lane.weight
+		if best == nil || lane.credit > best.credit {
+			best = lane
+		}
+	}
+	if best != nil {
+		best.credit -= total
+	}
+	return best
+}
+
+// work runs queued jobs one at a time
+func (q *analysisJobQueue) work() {
+	for {
+		q.mu.Lock()
+		lane := q.next()
+		for lane == nil {
+			q.cond.Wait()
+			lane = q.next()
+		}
+		item := lane.pending[0]
+		lane.pending = lane.pending[1:]
+		lane.running++
+		lane.dispatched++
+		lane.waitTotal += time.Since(item.queuedAt)
+		q.mu.Unlock()
+
+		status := q.run(item.job)
+
+		q.mu.Lock()
+		lane.running--
+		q.mu.Unlock()
+		if item.onFinish != nil {
+			item.onFinish(status)
+		}
+	}
+}
+
+// Queued returns the number of jobs waiting for a worker
+func (q *analysisJobQueue) Queued() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	queued := 0
+	for _, lane := range q.lanes {
+		queued += len(lane.pending)
+	}
+	return queued
+}
+
+// Depth reports queued and running jobs per priority
+func (q *analysisJobQueue) Depth() []AnalysisQueueLane {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	depth := make([]AnalysisQueueLane, 0, len(analysisPriorities))
+	for _, priority := range analysisPriorities {
+		lane := q.lanes[priority]
+		entry := AnalysisQueueLane{
+			Priority:   priority,
+			Weight:     lane.weight,
+			Queued:     len(lane.pending),
+			Running:    lane.running,
+			Dispatched: lane.dispatched,
+		}
+		if lane.dispatched > 0 {
+			entry.AvgWaitMs = float64(lane.waitTotal.Milliseconds()) / float64(lane.dispatched)
+		}
+		if len(lane.pending) > 0 {
+			entry.OldestWaitMs = now.Sub(lane.pending[0].queuedAt).Milliseconds()
+		}
+		depth = append(depth, entry)
+	}
+	return depth
+}
+
+// run executes one job, records its progress, partial metrics and outcome, and returns its final status
+func (q *analysisJobQueue) run(job models.AnalysisJob) string {
+	// Jobs share the global analysis slots with sync requests; they wait without a timeout
+	release, err := analysisSlots.Acquire(context.Background(), false)
+	if err != nil {
+		q.finish(job.JobID, models.AnalysisJobFailed, map[string]interface{}{"error": err.Error()})
+		return models.AnalysisJobFailed
+	}
+	defer release()
+
+	startedAt := time.Now()
+	q.update(job.JobID, map[string]interface{}{
+		"status":     models.AnalysisJobRunning,
+		"started_at": startedAt,
+	})
+
+	session, err := q.manager.GetOrCreateSession(job.UserID)
+	if err != nil {
+		q.finish(job.JobID, models.AnalysisJobFailed, map[string]interface{}{"error": err.Error()})
+		return models.AnalysisJobFailed
+	}
+
+	trigger := models.ScanTriggerManual
+	if job.BulkScanID != nil {
+		trigger = models.ScanTriggerBulk
+	} else if job.ScheduleID != nil {
+		trigger = models.ScanTriggerScheduled
+	}
+	result, err := session.analyze(trigger, job.Force, func(stage string, progress int, partial map[string]interface{}) {
+		columns := map[string]interface{}{"stage": stage, "progress": progress}
+		if partial != nil {
+			if encoded, err := json.Marshal(partial); err == nil {
+				columns["partial"] = string(encoded)
+			}
+		}
+		q.update(job.JobID, columns)
+	})
+	if err != nil {
+		slog.Error(fmt.Sprintf("Analysis job %s failed", job.JobID), "user_id", job.UserID, "error", err)
+		q.finish(job.JobID, models.AnalysisJobFailed, map[string]interface{}{"error": truncateJobError(err.Error())})
+		return models.AnalysisJobFailed
+	}
+
+	columns := map[string]interface{}{"stage": analysisStageDone, "progress": 100}
+	if encoded, err := json.Marshal(models.NewAnalysisResponse(&result)); err == nil {
+		columns["partial"] = string(encoded)
+	}
+	if result.ID != 0 {
+		columns["analysis_id"] = result.ID
+	}
+	q.finish(job.JobID, models.AnalysisJobCompleted, columns)
+	slog.Debug(fmt.Sprintf("Analysis job %s completed in %s", job.JobID, time.Since(startedAt).Round(time.Millisecond)), "user_id", job.UserID)
+	return models.AnalysisJobCompleted
+}
+
+func (q *analysisJobQueue) finish(jobID, status string, columns map[string]interface{}) {
+	columns["status"] = status
+	columns["finished_at"] = time.Now()
+	q.update(jobID, columns)
+}
+
+func (q *analysisJobQueue) update(jobID string, columns map[string]interface{}) {
+	if err := q.jobs.Update(context.Background(), jobID, columns); err != nil {
+		slog.Warn(fmt.Sprintf("Failed to update analysis job %s", jobID), "error", err)
+	}
+}
+
+// newAnalysisJobID returns a random 32-character job id
+func newAnalysisJobID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate job id: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func truncateJobError(msg string) string {
+	if len(msg) > 500 {
+		return msg[:500]
+	}
+	return msg
+}
+
+// analysisJobView is the API form of a job: partial metrics while running, the result once completed
+func analysisJobView(job *models.AnalysisJob) map[string]interface{} {
+	view := map[string]interface{}{
+		"job_id":      job.JobID,
+		"status":      job.Status,
+		"priority":    job.Priority,
+		"stage":       job.Stage,
+		"progress":    job.Progress,
+		"analysis_id": job.AnalysisID,
+		"created_at":  job.CreatedAt,
+		"started_at":  job.StartedAt,
+		"finished_at": job.FinishedAt,
+	}
+	if job.Error != "" {
+		view["error"] = job.Error
+	}
+
+	var data map[string]interface{}
+	if job.Partial != "" && json.Unmarshal([]byte(job.Partial), &data) == nil {
+		if job.Status == models.AnalysisJobCompleted {
+			view["result"] = data
+		} else {
+			view["partial"] = data
+		}
+	}
+	return view
+}
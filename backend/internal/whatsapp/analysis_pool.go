@@ -0,0 +1,169 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrAnalysisBusy is returned when all analysis slots are taken and the wait queue is full
+	ErrAnalysisBusy = errors.New("too many analyses in progress, try again later")
+	// ErrAnalysisQueueTimeout is returned when no analysis slot freed up within the queue timeout
+	ErrAnalysisQueueTimeout = errors.New("timed out waiting for an analysis slot")
+)
+
+// defaultAnalysisDuration seeds the wait estimate until the first analyses finished
+const defaultAnalysisDuration = 20 * time.Second
+
+// analysisPool bounds how many analyses run at once across all users and entry points
+// (sync requests, async jobs, automatic analysis), protecting CPU and the database.
+// Callers over the limit wait in a bounded queue.
+type analysisPool struct {
+	slots    chan struct{}
+	maxQueue int
+	timeout  time.Duration
+
+	mu      sync.Mutex
+	waiting int
+	avg     time.Duration // moving average of analysis durations
+}
+
+var analysisSlots = newAnalysisPool()
+
+func newAnalysisPool() *analysisPool {
+	concurrency := envInt("ANALYSIS_MAX_CONCURRENCY", 4)
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+	timeout := envInt("ANALYSIS_QUEUE_TIMEOUT_SECONDS", 30)
+	if timeout <= 0 {
+		timeout = 30
+	}
+	return &analysisPool{
+		slots:    make(chan struct{}, concurrency),
+		maxQueue: envInt("ANALYSIS_MAX_QUEUE", 20),
+		timeout:  time.Duration(timeout) * time.Second,
+		avg:      defaultAnalysisDuration,
+	}
+}
+
+// Acquire takes an analysis slot, waiting up to the queue timeout. bounded=false is for
+// background work (jobs, automatic analysis) that waits as long as needed and is never
+// rejected. The returned release must be called once the analysis finished.
+func (p *analysisPool) Acquire(ctx context.Context, bounded bool) (func(), error) {
+	select {
+	case p.slots <- struct{}{}:
+		return p.release(time.Now()), nil
+	default:
+	}
+
+	p.mu.Lock()
+	if bounded && p.waiting >= p.maxQueue {
+		p.mu.Unlock()
+		return nil, ErrAnalysisBusy
+	}
+	p.waiting++
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.waiting--
+		p.mu.Unlock()
+	}()
+
+	var timeout <-chan time.Time
+	if bounded {
+		timer := time.NewTimer(p.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case p.slots <- struct{}{}:
+		return p.release(time.Now()), nil
+	case <-timeout:
+		return nil, ErrAnalysisQueueTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (p *analysisPool) release(startedAt time.Time) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			elapsed := time.Since(startedAt)
+			p.mu.Lock()
+			p.avg = (p.avg*4 + elapsed) / 5
+			p.mu.Unlock()
+			<-p.slots
+		})
+	}
+}
+
+// EstimatedWait guesses how long a new analysis waits for a slot when ahead more
+// analyses are queued elsewhere (e.g. async jobs) besides the pool's own waiters
+func (p *analysisPool) EstimatedWait(ahead int) time.Duration {
+	p.mu.Lock()
+	waiting, avg := p.waiting, p.avg
+	p.mu.Unlock()
+
+	queued := waiting + ahead
+	if len(p.slots) < cap(p.slots) && queued == 0 {
+		return 0
+	}
+	rounds := math.Ceil(float64(queued+1) / float64(cap(p.slots)))
+	return time.Duration(rounds) * avg
+}
+
+// estimatedWaitSeconds rounds EstimatedWait up to whole seconds
+func (p *analysisPool) estimatedWaitSeconds(ahead int) int {
+	return int(math.Ceil(p.EstimatedWait(ahead).Seconds()))
+}
+
+// analysisMaxQueuedJobs returns ANALYSIS_MAX_QUEUED_JOBS (default 100), the async job backlog
+// above which new jobs are refused
+func analysisMaxQueuedJobs() int {
+	if v := envInt("ANALYSIS_MAX_QUEUED_JOBS", 100); v > 0 {
+		return v
+	}
+	return 100
+}
+
+// writeAnalysisBusy answers 429 with Retry-After and the estimated wait when no analysis can be taken on
+func writeAnalysisBusy(w http.ResponseWriter, userID uint, err error, ahead int) {
+	seconds := analysisSlots.estimatedWaitSeconds(ahead)
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":                false,
+		"error":                  err.Error(),
+		"error_type":             "analysis_busy",
+		"user_id":                userID,
+		"estimated_wait_seconds": seconds,
+	})
+}
+
+// Stats reports slot usage for the admin metrics
+func (p *analysisPool) Stats() map[string]interface{} {
+	p.mu.Lock()
+	waiting, avg := p.waiting, p.avg
+	p.mu.Unlock()
+	return map[string]interface{}{
+		"concurrency":       cap(p.slots),
+		"running":           len(p.slots),
+		"waiting":           waiting,
+		"max_queue":         p.maxQueue,
+		"queue_timeout_ms":  p.timeout.Milliseconds(),
+		"avg_duration_ms":   avg.Milliseconds(),
+		"estimated_wait_ms": p.EstimatedWait(0).Milliseconds(),
+	}
+}
@@ -2,9 +2,10 @@ package whatsapp
 
 import (
 	"back_wa/internal/models"
+	"back_wa/internal/services"
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	"go.mau.fi/whatsmeow"
@@ -12,7 +13,7 @@ import (
 )
 
 func (w *WhatsApp) Analyze() (models.AnalysisResult, error) {
-	log.Println("DEBUG: Starting WhatsApp analysis...")
+	slog.Debug("Starting WhatsApp analysis...")
 
 	client := w.GetClient()
 	if client == nil {
@@ -21,7 +22,7 @@ func (w *WhatsApp) Analyze() (models.AnalysisResult, error) {
 
 	// Check if WhatsApp client is ready (logged in)
 	if !w.IsReady() {
-		log.Println("DEBUG: WhatsApp client not ready, cannot analyze without login")
+		slog.Debug("WhatsApp client not ready, cannot analyze without login")
 		return models.AnalysisResult{}, fmt.Errorf("WhatsApp not logged in. Please scan QR code first")
 	}
 
@@ -34,16 +35,16 @@ func (w *WhatsApp) Analyze() (models.AnalysisResult, error) {
 		if result, ok := cachedData.(models.AnalysisResult); ok {
 			// Check if this is from the same session
 			if w.isValidCache(result) {
-				log.Println("DEBUG: Using valid cached analysis data")
+				slog.Debug("Using valid cached analysis data")
 				return result, nil
 			} else {
-				log.Println("DEBUG: Cache invalid, clearing and re-analyzing")
+				slog.Debug("Cache invalid, clearing and re-analyzing")
 				w.ClearAnalysisCache()
 			}
 		}
 	}
 
-	log.Println("DEBUG: Getting contacts from WhatsApp...")
+	slog.Debug("Getting contacts from WhatsApp...")
 
 	// Get contacts with timeout (reduced from 10s to 5s)
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -51,15 +52,15 @@ func (w *WhatsApp) Analyze() (models.AnalysisResult, error) {
 
 	allContacts, err := client.Store.Contacts.GetAllContacts(ctx)
 	if err != nil {
-		log.Printf("DEBUG: Error getting contacts: %v", err)
+		slog.Debug("Error getting contacts", "error", err)
 		return models.AnalysisResult{}, fmt.Errorf("failed to get contacts: %v", err)
 	}
 
-	log.Printf("DEBUG: Total contacts found: %d", len(allContacts))
+	slog.Debug(fmt.Sprintf("Total contacts found: %d", len(allContacts)))
 
 	// If no contacts found, return specific error message
 	if len(allContacts) == 0 {
-		log.Println("DEBUG: No contacts found, cannot analyze empty contact list")
+		slog.Debug("No contacts found, cannot analyze empty contact list")
 		return models.AnalysisResult{}, fmt.Errorf("contacts not loaded yet. Please wait a moment and try again")
 	}
 
@@ -76,37 +77,40 @@ func (w *WhatsApp) Analyze() (models.AnalysisResult, error) {
 			savedContacts[jid] = contact
 			contactCount++
 			if contactCount <= 10 { // Log first 10 saved contacts
-				log.Printf("DEBUG: Saved Contact %d: %s (Server: %s, Name: %s, BusinessName: %s)",
-					contactCount, jid.String(), jid.Server, contact.FullName, contact.BusinessName)
+				slog.Debug(fmt.Sprintf("Saved Contact %d: %s (Server: %s, Name: %s, BusinessName: %s)", contactCount, jid.String(), jid.Server, contact.FullName, contact.BusinessName))
 			}
 			if jid.Server == "g.us" {
 				groupCount++
-				log.Printf("DEBUG: Group found: %s (Name: %s)", jid.String(), contact.FullName)
+				slog.Debug(fmt.Sprintf("Group found: %s (Name: %s)", jid.String(), contact.FullName))
 			}
 		} else {
 			// Count unsaved contacts (no name or unknown name)
 			unsavedContacts[jid] = contact
 			unsavedCount++
 			if unsavedCount <= 5 { // Log first 5 unsaved contacts
-				log.Printf("DEBUG: Unsaved Contact %d: %s (Server: %s)",
-					unsavedCount, jid.String(), jid.Server)
+				slog.Debug(fmt.Sprintf("Unsaved Contact %d: %s (Server: %s)", unsavedCount, jid.String(), jid.Server))
 			}
 		}
 	}
 
-	log.Printf("DEBUG: Total saved contacts: %d, Total unsaved contacts: %d, Total groups found: %d",
-		len(savedContacts), len(unsavedContacts), groupCount)
+	slog.Debug(fmt.Sprintf("Total saved contacts: %d, Total unsaved contacts: %d, Total groups found: %d", len(savedContacts), len(unsavedContacts), groupCount))
 
 	// Use savedContacts for main analysis
 	contacts := savedContacts
 
 	// Calculate the 8 required parameters
 	totalContacts := len(contacts)
-	totalChats := w.estimateTotalChats(contacts)
 	totalGroups := w.calculateTotalGroups(contacts)
-	totalChatWithContact := w.estimateChatsWithContacts(contacts)
-	totalUnsavedChats := len(unsavedContacts)
-	unknownNumberChats := len(unsavedContacts)
+
+	// Chat metrics come from the conversations actually seen on the device
+	chats := w.chats.counts(savedContacts, unsavedContacts)
+	if chats.Total == 0 {
+		slog.Debug("No conversations received from history sync yet")
+	}
+	totalChats := chats.Total
+	totalChatWithContact := chats.WithContact
+	totalUnsavedChats := chats.Unsaved
+	unknownNumberChats := chats.UnknownNumber
 
 	// Estimate sensitive content (for now, using a reasonable default)
 	sensitiveContentCount := w.estimateSensitiveContent(contacts)
@@ -114,19 +118,26 @@ func (w *WhatsApp) Analyze() (models.AnalysisResult, error) {
 	// Get account age
 	accountAgeDays := w.estimateAccountAge(client)
 
-	log.Printf("DEBUG: Calculated parameters:")
-	log.Printf("  Total Chats: %d", totalChats)
-	log.Printf("  Total Contacts: %d", totalContacts)
-	log.Printf("  Account Age: %d days", accountAgeDays)
-	log.Printf("  Total Groups: %d", totalGroups)
-	log.Printf("  Chat with Contact: %d", totalChatWithContact)
-	log.Printf("  Sensitive Content: %d", sensitiveContentCount)
-	log.Printf("  Total Unsaved Chats: %d", totalUnsavedChats)
-	log.Printf("  Unknown Number Chats: %d", unknownNumberChats)
+	// Correct values that contradict each other before they are scored
+	inputs := models.AnalysisInputs{TotalChats: totalChats, TotalContacts: totalContacts, AccountAgeDays: accountAgeDays, TotalGroups: totalGroups,
+		TotalChatWithContact: totalChatWithContact, SensitiveContentCount: sensitiveContentCount, TotalUnsavedChats: totalUnsavedChats, UnknownNumberChats: unknownNumberChats}
+	anomalies := inputs.Sanitize()
+	totalChats, totalContacts, accountAgeDays, totalGroups, totalChatWithContact, sensitiveContentCount, totalUnsavedChats, unknownNumberChats = inputs.Values()
+	services.RecordInputAnomalies(0, anomalies)
+
+	slog.Debug("Calculated parameters:")
+	slog.Debug(fmt.Sprintf("Total Chats: %d", totalChats))
+	slog.Debug(fmt.Sprintf("Total Contacts: %d", totalContacts))
+	slog.Debug(fmt.Sprintf("Account Age: %d days", accountAgeDays))
+	slog.Debug(fmt.Sprintf("Total Groups: %d", totalGroups))
+	slog.Debug(fmt.Sprintf("Chat with Contact: %d", totalChatWithContact))
+	slog.Debug(fmt.Sprintf("Sensitive Content: %d", sensitiveContentCount))
+	slog.Debug(fmt.Sprintf("Total Unsaved Chats: %d", totalUnsavedChats))
+	slog.Debug(fmt.Sprintf("Unknown Number Chats: %d", unknownNumberChats))
 
 	// Calculate strength dengan parameter baru sesuai tabel indikator
-	log.Println("DEBUG: Calling CalculateStrength...")
-	rating, summary := models.CalculateStrength(totalChats, totalContacts, accountAgeDays, totalGroups, totalChatWithContact, sensitiveContentCount, totalUnsavedChats, unknownNumberChats)
+	slog.Debug("Calling CalculateStrength...")
+	rating, summary, parameters := models.ScoreStrength(services.ActiveScoringConfig(), nil, totalChats, totalContacts, accountAgeDays, totalGroups, totalChatWithContact, sensitiveContentCount, totalUnsavedChats, unknownNumberChats)
 
 	result := models.AnalysisResult{
 		TotalChats:            totalChats,
@@ -139,15 +150,17 @@ func (w *WhatsApp) Analyze() (models.AnalysisResult, error) {
 		UnknownNumberChats:    unknownNumberChats,
 		Strength:              rating,
 		Summary:               summary,
+		Parameters:            parameters,
+		Anomalies:             anomalies,
 	}
 
-	log.Printf("DEBUG: Analysis result - Strength: %s", rating)
+	slog.Debug(fmt.Sprintf("Analysis result - Strength: %s", rating))
 
 	// Cache the analysis result for current session
 	w.analysisMu.Lock()
 	w.analysisData["current_session"] = result
 	w.analysisMu.Unlock()
-	log.Println("DEBUG: Analysis data cached for current session")
+	slog.Debug("Analysis data cached for current session")
 
 	return result, nil
 }
@@ -156,17 +169,17 @@ func (w *WhatsApp) Analyze() (models.AnalysisResult, error) {
 func (w *WhatsApp) isValidCache(result models.AnalysisResult) bool {
 	// Check if we have meaningful data (not all zeros)
 	if result.TotalContacts == 0 && result.TotalChats == 0 {
-		log.Println("DEBUG: Cache contains zero data, invalid")
+		slog.Debug("Cache contains zero data, invalid")
 		return false
 	}
 
 	// Check if client ID matches (basic session validation)
 	if w.client != nil && w.client.Store.ID != nil {
-		log.Println("DEBUG: Cache validation passed")
+		slog.Debug("Cache validation passed")
 		return true
 	}
 
-	log.Println("DEBUG: Cache validation failed - no client ID")
+	slog.Debug("Cache validation failed - no client ID")
 	return false
 }
 
@@ -175,18 +188,7 @@ func (w *WhatsApp) ClearAnalysisCache() {
 	w.analysisMu.Lock()
 	w.analysisData = make(map[string]interface{})
 	w.analysisMu.Unlock()
-	log.Println("DEBUG: Analysis cache cleared")
-}
-
-func (w *WhatsApp) estimateTotalChats(contacts map[types.JID]types.ContactInfo) int {
-	// Estimate total chats based on saved contacts only
-	savedContactsCount := len(contacts)
-
-	// Estimate total chats as saved contacts + some additional chats
-	totalChats := savedContactsCount + int(float64(savedContactsCount)*0.3) // 30% additional chats
-
-	log.Printf("DEBUG: Estimated total chats: %d (from %d saved contacts)", totalChats, savedContactsCount)
-	return totalChats
+	slog.Debug("Analysis cache cleared")
 }
 
 func (w *WhatsApp) calculateTotalGroups(contacts map[types.JID]types.ContactInfo) int {
@@ -197,7 +199,7 @@ func (w *WhatsApp) calculateTotalGroups(contacts map[types.JID]types.ContactInfo
 	for jid := range contacts {
 		if jid.Server == "g.us" {
 			contactGroups++
-			log.Printf("DEBUG: Found group in contacts: %s", jid.String())
+			slog.Debug(fmt.Sprintf("Found group in contacts: %s", jid.String()))
 		}
 	}
 
@@ -205,10 +207,10 @@ func (w *WhatsApp) calculateTotalGroups(contacts map[types.JID]types.ContactInfo
 	if w.client != nil {
 		groups, err := w.client.GetJoinedGroups()
 		if err != nil {
-			log.Printf("DEBUG: Error getting groups from client: %v", err)
+			slog.Debug("Error getting groups from client", "error", err)
 		} else {
 			totalGroups = len(groups)
-			log.Printf("DEBUG: Found %d groups from GetJoinedGroups()", totalGroups)
+			slog.Debug(fmt.Sprintf("Found %d groups from GetJoinedGroups()", totalGroups))
 		}
 	}
 
@@ -218,7 +220,7 @@ func (w *WhatsApp) calculateTotalGroups(contacts map[types.JID]types.ContactInfo
 	w.groupsMu.RUnlock()
 
 	if storedGroups > 0 {
-		log.Printf("DEBUG: Found %d groups in stored data", storedGroups)
+		slog.Debug(fmt.Sprintf("Found %d groups in stored data", storedGroups))
 		if storedGroups > totalGroups {
 			totalGroups = storedGroups
 		}
@@ -229,21 +231,10 @@ func (w *WhatsApp) calculateTotalGroups(contacts map[types.JID]types.ContactInfo
 		totalGroups = contactGroups
 	}
 
-	log.Printf("DEBUG: Final total groups count: %d (contacts: %d, stored: %d)", totalGroups, contactGroups, storedGroups)
+	slog.Debug(fmt.Sprintf("Final total groups count: %d (contacts: %d, stored: %d)", totalGroups, contactGroups, storedGroups))
 	return totalGroups
 }
 
-func (w *WhatsApp) estimateChatsWithContacts(contacts map[types.JID]types.ContactInfo) int {
-	// Estimate chats with saved contacts
-	savedContactsCount := len(contacts)
-
-	// Estimate that about 80% of saved contacts have active chats
-	chatsWithContacts := int(float64(savedContactsCount) * 0.8)
-
-	log.Printf("DEBUG: Estimated chats with contacts: %d (from %d saved contacts)", chatsWithContacts, savedContactsCount)
-	return chatsWithContacts
-}
-
 func (w *WhatsApp) estimateSensitiveContent(contacts map[types.JID]types.ContactInfo) int {
 	// For now, estimate sensitive content based on contacts
 	// In real implementation, you would analyze message content
@@ -252,14 +243,14 @@ func (w *WhatsApp) estimateSensitiveContent(contacts map[types.JID]types.Contact
 	// Estimate 5-15% of contacts might have sensitive content
 	sensitiveEstimate := int(float64(totalContacts) * 0.1) // 10% average
 
-	log.Printf("DEBUG: Estimated sensitive content count: %d (from %d contacts)", sensitiveEstimate, totalContacts)
+	slog.Debug(fmt.Sprintf("Estimated sensitive content count: %d (from %d contacts)", sensitiveEstimate, totalContacts))
 	return sensitiveEstimate
 }
 
 func (w *WhatsApp) estimateAccountAge(client *whatsmeow.Client) int {
 	// Estimate account age based on multiple data points for better accuracy
 	if client.Store.ID == nil {
-		log.Printf("DEBUG: No client ID, using default account age: 365 days")
+		slog.Debug("No client ID, using default account age: 365 days")
 		return 365 // Default to 1 year if no client ID
 	}
 
@@ -339,38 +330,31 @@ func (w *WhatsApp) estimateAccountAge(client *whatsmeow.Client) int {
 		estimatedAge = baseAge
 		confidenceScore = 85 // High confidence for contact-based estimation
 
-		log.Printf("DEBUG: Contact-based age estimation: %d days (contacts: %d, saved: %d, groups: %d)",
-			estimatedAge, contactCount, savedContacts, groupContacts)
+		slog.Debug(fmt.Sprintf("Contact-based age estimation: %d days (contacts: %d, saved: %d, groups: %d)", estimatedAge, contactCount, savedContacts, groupContacts))
 
 	} else {
 		// Method 2: Fallback to client ID hash with more realistic range
-		clientID := client.Store.ID.String()
-		hash := 0
-		for _, char := range clientID {
-			hash += int(char)
-		}
+		hash := accountSeed(*client.Store.ID)
 
 		// More realistic range: 90 days to 2 years
 		estimatedAge = 90 + (hash % 640) // 90 days to ~2 years
 		confidenceScore = 30             // Low confidence for hash-based estimation
 
-		log.Printf("DEBUG: Hash-based fallback age estimation: %d days", estimatedAge)
+		slog.Debug(fmt.Sprintf("Hash-based fallback age estimation: %d days", estimatedAge))
 	}
 
 	// Apply confidence-based adjustments
 	if confidenceScore >= 80 {
 		// High confidence: keep as is
-		log.Printf("DEBUG: High confidence estimation, keeping age: %d days", estimatedAge)
+		slog.Debug(fmt.Sprintf("High confidence estimation, keeping age: %d days", estimatedAge))
 	} else if confidenceScore >= 50 {
-		// Medium confidence: apply some randomization to avoid patterns
-		variation := estimatedAge / 10 // ±10% variation
-		estimatedAge = estimatedAge + (variation * 2) - variation
-		log.Printf("DEBUG: Medium confidence, applied variation: %d days", estimatedAge)
+		// Medium confidence: apply some variation to avoid patterns
+		estimatedAge = varyAccountAge(estimatedAge, 10, *client.Store.ID) // ±10% variation
+		slog.Debug(fmt.Sprintf("Medium confidence, applied variation: %d days", estimatedAge))
 	} else {
-		// Low confidence: more randomization
-		variation := estimatedAge / 5 // ±20% variation
-		estimatedAge = estimatedAge + (variation * 2) - variation
-		log.Printf("DEBUG: Low confidence, applied higher variation: %d days", estimatedAge)
+		// Low confidence: more variation
+		estimatedAge = varyAccountAge(estimatedAge, 20, *client.Store.ID) // ±20% variation
+		slog.Debug(fmt.Sprintf("Low confidence, applied higher variation: %d days", estimatedAge))
 	}
 
 	// Ensure reasonable bounds (minimum 30 days, maximum 5 years)
@@ -380,8 +364,7 @@ func (w *WhatsApp) estimateAccountAge(client *whatsmeow.Client) int {
 		estimatedAge = 1825
 	}
 
-	log.Printf("DEBUG: Final estimated account age: %d days (%.1f years) with confidence: %d%%",
-		estimatedAge, float64(estimatedAge)/365.0, confidenceScore)
+	slog.Debug(fmt.Sprintf("Final estimated account age: %d days (%.1f years) with confidence: %d%%", estimatedAge, float64(estimatedAge)/365.0, confidenceScore))
 
 	return estimatedAge
 }
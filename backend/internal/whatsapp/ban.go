@@ -0,0 +1,104 @@
+package whatsapp
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"back_wa/internal/models"
+)
+
+// StatusBanned is the session status once WhatsApp banned or restricted the account
+const StatusBanned = "banned"
+
+// ErrAccountBanned is returned by connect while a ban/restriction is in effect
+var ErrAccountBanned = errors.New("WhatsApp account is banned or restricted")
+
+// Restriction describes an active ban, surfaced in /api/wa/status
+type Restriction struct {
+	Type   string     `json:"type"` // banned | temp_banned
+	Code   int        `json:"code"`
+	Reason string     `json:"reason"`
+	Until  *time.Time `json:"until,omitempty"`
+}
+
+// markBanned stops the session after a ban event: no reconnects, user emailed, event recorded
+func (s *UserWhatsAppSession) markBanned(eventType string, code int, reason string, expire time.Duration) {
+	s.mu.Lock()
+	if s.Status == StatusBanned && s.Restriction != nil && s.Restriction.Type == eventType && s.Restriction.Code == code {
+		// whatsmeow may report the same ban more than once (event + connect failure)
+		s.mu.Unlock()
+		return
+	}
+	restriction := &Restriction{Type: eventType, Code: code, Reason: reason}
+	if expire > 0 {
+		until := time.Now().Add(expire)
+		restriction.Until = &until
+	}
+	s.Status = StatusBanned
+	s.Ready = false
+	s.Restriction = restriction
+	s.LastActivity = time.Now()
+	s.resetWarmupLocked()
+	client := s.Client
+	s.mu.Unlock()
+
+	s.logger().Debug(fmt.Sprintf("WhatsApp account %s (code=%d, reason=%s)", eventType, code, reason))
+
+	phoneNumber := ""
+	if client != nil {
+		if client.Store != nil && client.Store.ID != nil {
+			phoneNumber = client.Store.ID.User
+		}
+		// Disconnecting explicitly keeps whatsmeow from retrying the connection
+		go func() { defer func() { recover() }(); client.Disconnect() }()
+	}
+
+	_ = saveSessionRecord(s.sessions, &UserWhatsAppSession{UserID: s.UserID, Status: StatusBanned, LastActivity: time.Now()})
+	publishStatus(s.UserID, StatusBanned)
+
+	event := &models.WhatsAppSessionEvent{
+		UserID:      s.UserID,
+		PhoneNumber: phoneNumber,
+		Type:        eventType,
+		Code:        code,
+		Reason:      reason,
+		ExpiresAt:   restriction.Until,
+	}
+	if err := s.sessionEvents.RecordRestriction(event); err != nil {
+		s.logger().Warn("Failed to record session restriction", "error", err)
+	}
+}
+
+// bannedLocked reports whether a ban still blocks connecting; expired temporary bans are lifted.
+// The caller must hold s.mu.
+func (s *UserWhatsAppSession) bannedLocked() bool {
+	if s.Status != StatusBanned {
+		return false
+	}
+	if s.Restriction != nil && s.Restriction.Until != nil && time.Now().After(*s.Restriction.Until) {
+		s.logger().Debug("Temporary ban expired, allowing reconnect")
+		s.Status = "disconnected"
+		s.Restriction = nil
+		return false
+	}
+	return true
+}
+
+// GetRestriction returns the active ban/restriction for the user, nil when none
+func (m *MultiUserWhatsAppManager) GetRestriction(userID uint) *Restriction {
+	m.mu.RLock()
+	session, exists := m.userSessions[userID]
+	m.mu.RUnlock()
+	if !exists {
+		return nil
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if !session.bannedLocked() {
+		return nil
+	}
+	restriction := *session.Restriction
+	return &restriction
+}
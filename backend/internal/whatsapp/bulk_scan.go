@@ -0,0 +1,366 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/models"
+	"back_wa/internal/services"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// ErrBulkScanNoMembers is returned when none of the organization's members has a connected session
+var ErrBulkScanNoMembers = errors.New("no member has a connected WhatsApp session")
+
+// bulkScanConcurrency returns BULK_SCAN_CONCURRENCY (default 2): how many member jobs one bulk
+// scan keeps in the analysis queue at a time, so overnight org scans don't starve interactive users
+func bulkScanConcurrency() int {
+	if v, err := strconv.Atoi(os.Getenv("BULK_SCAN_CONCURRENCY")); err == nil && v > 0 {
+		return v
+	}
+	return 2
+}
+
+// StartBulkScan creates an analysis job for every member of the tenant with a connected session
+// and feeds them to the job queue in the background. A running bulk scan of the tenant is
+// returned instead of starting another one (created=false).
+func (m *MultiUserWhatsAppManager) StartBulkScan(ctx context.Context, tenantID, requestedBy uint) (*models.BulkScan, bool, error) {
+	// The one-active-job-per-user check must not race with POST /api/wa/analyze
+	m.jobs.enqueueMu.Lock()
+	defer m.jobs.enqueueMu.Unlock()
+
+	active, err := m.repos.BulkScans.ActiveForTenant(ctx, tenantID)
+	if err != nil {
+		return nil, false, err
+	}
+	if active != nil {
+		return active, false, nil
+	}
+
+	members, err := m.repos.Users.ListByTenant(ctx, tenantID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Members without a connected session are not part of the scan at all; connected ones
+	// that can't be analyzed right now are recorded as skipped
+	var queued []models.AnalysisJob
+	var skipped []models.AnalysisJob
+	for _, member := range members {
+		m.mu.RLock()
+		session := m.userSessions[member.ID]
+		m.mu.RUnlock()
+		if session == nil || !session.IsReady() {
+			continue
+		}
+		client := session.GetClient()
+		if client == nil || client.Store.ID == nil {
+			continue
+		}
+
+		job := models.AnalysisJob{
+			UserID:   member.ID,
+			Status:   models.AnalysisJobQueued,
+			Stage:    analysisStageQueued,
+			Priority: models.AnalysisPriorityBulk,
+		}
+		if running, err := m.repos.Jobs.ActiveForUser(ctx, member.ID); err != nil {
+			return nil, false, err
+		} else if running != nil {
+			job.Status, job.Error = models.AnalysisJobSkipped, "analysis already in progress"
+		} else if entitlement, err := session.entitlements.Check(member.ID, client.Store.ID.User); err != nil {
+			return nil, false, err
+		} else if !entitlement.Entitled {
+			job.Status, job.Error = models.AnalysisJobSkipped, "payment required: "+entitlement.Reason
+		}
+
+		if job.Status == models.AnalysisJobSkipped {
+			skipped = append(skipped, job)
+		} else {
+			queued = append(queued, job)
+		}
+	}
+	if len(queued)+len(skipped) == 0 {
+		return nil, false, ErrBulkScanNoMembers
+	}
+
+	scan := &models.BulkScan{
+		TenantID:    tenantID,
+		RequestedBy: requestedBy,
+		Status:      models.BulkScanRunning,
+		Total:       len(queued) + len(skipped),
+		Skipped:     len(skipped),
+	}
+	if err := m.repos.BulkScans.Create(ctx, scan); err != nil {
+		return nil, false, err
+	}
+
+	now := time.Now()
+	for _, list := range [][]models.AnalysisJob{skipped, queued} {
+		for i := range list {
+			jobID, err := newAnalysisJobID()
+			if err != nil {
+				return nil, false, err
+			}
+			list[i].JobID = jobID
+			list[i].BulkScanID = &scan.ID
+			if list[i].Status == models.AnalysisJobSkipped {
+				list[i].FinishedAt = &now
+			}
+			if err := m.repos.Jobs.Create(ctx, &list[i]); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+
+	logging.FromContext(ctx).Debug(fmt.Sprintf("Tenant %d - Bulk scan %d started by user %d (%d queued, %d skipped)", tenantID, scan.ID, requestedBy, len(queued), len(skipped)), "user_id", requestedBy)
+	go m.runBulkScan(scan.ID, queued)
+	return scan, true, nil
+}
+
+// runBulkScan keeps at most bulkScanConcurrency() of the scan's jobs in the queue and
+// writes the consolidated report once all of them finished
+func (m *MultiUserWhatsAppManager) runBulkScan(scanID uint, jobs []models.AnalysisJob) {
+	slots := make(chan struct{}, bulkScanConcurrency())
+	var wg sync.WaitGroup
+
+	for _, job := range jobs {
+		slots <- struct{}{}
+		wg.Add(1)
+		m.jobs.push(job, func(status string) {
+			column := "failed"
+			if status == models.AnalysisJobCompleted {
+				column = "completed"
+			}
+			if err := m.repos.BulkScans.Increment(context.Background(), scanID, column); err != nil {
+				slog.Warn(fmt.Sprintf("Failed to update bulk scan %d progress", scanID), "error", err)
+			}
+			<-slots
+			wg.Done()
+		})
+	}
+	wg.Wait()
+
+	m.finishBulkScan(scanID)
+}
+
+// finishBulkScan builds the report from the member jobs and marks the scan completed
+func (m *MultiUserWhatsAppManager) finishBulkScan(scanID uint) {
+	ctx := context.Background()
+	jobs, err := m.repos.BulkScans.Jobs(ctx, scanID)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Failed to load jobs of bulk scan %d", scanID), "error", err)
+		return
+	}
+
+	report := m.bulkScanReport(ctx, jobs)
+	columns := map[string]interface{}{
+		"status":      models.BulkScanCompleted,
+		"completed":   report.Completed,
+		"failed":      report.Failed,
+		"skipped":     report.Skipped,
+		"finished_at": time.Now(),
+	}
+	if encoded, err := json.Marshal(report); err == nil {
+		columns["report"] = string(encoded)
+	}
+	if err := m.repos.BulkScans.Update(ctx, scanID, columns); err != nil {
+		slog.Error(fmt.Sprintf("Failed to complete bulk scan %d", scanID), "error", err)
+		return
+	}
+	slog.Debug(fmt.Sprintf("Bulk scan %d completed (%d completed, %d failed, %d skipped)", scanID, report.Completed, report.Failed, report.Skipped))
+}
+
+// finishInterruptedBulkScans closes bulk scans a previous process left running. Their
+// unfinished jobs were already failed by the job queue on start.
+func (m *MultiUserWhatsAppManager) finishInterruptedBulkScans() {
+	scans, err := m.repos.BulkScans.ListUnfinished(context.Background())
+	if err != nil {
+		slog.Warn("Failed to load unfinished bulk scans", "error", err)
+		return
+	}
+	for _, scan := range scans {
+		m.finishBulkScan(scan.ID)
+	}
+}
+
+// bulkScanReport consolidates the member jobs of a bulk scan
+func (m *MultiUserWhatsAppManager) bulkScanReport(ctx context.Context, jobs []models.AnalysisJob) models.BulkScanReport {
+	report := models.BulkScanReport{
+		Members:  len(jobs),
+		Strength: map[string]int{},
+		Results:  make([]models.BulkScanMemberResult, 0, len(jobs)),
+	}
+	for _, job := range jobs {
+		line := models.BulkScanMemberResult{
+			UserID:     job.UserID,
+			Status:     job.Status,
+			AnalysisID: job.AnalysisID,
+			Error:      job.Error,
+		}
+		if user, err := m.repos.Users.FindByID(ctx, job.UserID); err == nil {
+			line.Username = user.Username
+		}
+
+		switch job.Status {
+		case models.AnalysisJobCompleted:
+			report.Completed++
+			var result models.AnalysisResponse
+			if json.Unmarshal([]byte(job.Partial), &result) == nil {
+				line.Strength = result.Strength
+				line.TotalChats = result.TotalChats
+				line.TotalContacts = result.TotalContacts
+				line.TotalGroups = result.TotalGroups
+				report.Strength[result.Strength]++
+			}
+		case models.AnalysisJobSkipped:
+			report.Skipped++
+		default:
+			report.Failed++
+		}
+		report.Results = append(report.Results, line)
+	}
+	return report
+}
+
+// bulkScanView is the API form of a bulk scan: progress while running, the report once completed
+func bulkScanView(scan *models.BulkScan) map[string]interface{} {
+	view := map[string]interface{}{
+		"id":           scan.ID,
+		"org_id":       scan.TenantID,
+		"status":       scan.Status,
+		"total":        scan.Total,
+		"completed":    scan.Completed,
+		"failed":       scan.Failed,
+		"skipped":      scan.Skipped,
+		"progress":     scan.Progress(),
+		"requested_by": scan.RequestedBy,
+		"created_at":   scan.CreatedAt,
+		"finished_at":  scan.FinishedAt,
+	}
+	var report models.BulkScanReport
+	if scan.Report != "" && json.Unmarshal([]byte(scan.Report), &report) == nil {
+		view["report"] = report
+	}
+	return view
+}
+
+// authorizeOrg resolves {id} to an organization (tenant) the caller owns. Admins may act on any
+// organization. Writes the error response and returns nil when not allowed.
+func (h *MultiUserWhatsAppHandler) authorizeOrg(w http.ResponseWriter, r *http.Request) (*models.Tenant, uint) {
+	authHeader := r.Header.Get("Authorization")
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if authHeader == "" || tokenString == authHeader {
+		http.Error(w, "Authorization header required", http.StatusUnauthorized)
+		return nil, 0
+	}
+	claims, err := h.authService.ValidateToken(tokenString)
+	if err != nil {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return nil, 0
+	}
+
+	orgID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid organization id", http.StatusBadRequest)
+		return nil, 0
+	}
+	tenant, err := services.NewTenantService(h.repos.Tenants).GetByID(uint(orgID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return nil, 0
+	}
+	if err != nil {
+		http.Error(w, "Failed to load organization", http.StatusInternalServerError)
+		return nil, 0
+	}
+
+	isOwner := tenant.OwnerUserID != nil && *tenant.OwnerUserID == claims.UserID
+	if !isOwner && claims.Role != "admin" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, 0
+	}
+	return tenant, claims.UserID
+}
+
+// HandleStartBulkScan handles POST /api/orgs/{id}/bulk-scan
+func (h *MultiUserWhatsAppHandler) HandleStartBulkScan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tenant, userID := h.authorizeOrg(w, r)
+	if tenant == nil {
+		return
+	}
+
+	// The organization is addressed explicitly, so the request's tenant scope does not apply
+	scan, created, err := h.waManager.StartBulkScan(context.Background(), tenant.ID, userID)
+	if errors.Is(err, ErrBulkScanNoMembers) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error(fmt.Sprintf("Tenant %d - Failed to start bulk scan", tenant.ID), "error", err)
+		http.Error(w, "Failed to start bulk scan", http.StatusInternalServerError)
+		return
+	}
+
+	message := "Bulk scan queued"
+	if !created {
+		message = "Bulk scan already in progress"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"message":    message,
+		"bulk_scan":  bulkScanView(scan),
+		"status_url": "/api/orgs/" + strconv.FormatUint(uint64(tenant.ID), 10) + "/bulk-scan/" + strconv.FormatUint(uint64(scan.ID), 10),
+	})
+}
+
+// HandleBulkScanStatus handles GET /api/orgs/{id}/bulk-scan/{scan_id}
+func (h *MultiUserWhatsAppHandler) HandleBulkScanStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tenant, _ := h.authorizeOrg(w, r)
+	if tenant == nil {
+		return
+	}
+
+	scanID, err := strconv.ParseUint(mux.Vars(r)["scan_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid bulk scan id", http.StatusBadRequest)
+		return
+	}
+	scan, err := h.waManager.repos.BulkScans.FindForTenant(context.Background(), uint(scanID), tenant.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Bulk scan not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load bulk scan", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"bulk_scan": bulkScanView(scan),
+	})
+}
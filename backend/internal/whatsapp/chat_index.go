@@ -0,0 +1,345 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"back_wa/internal/contentscan"
+	"back_wa/internal/models"
+	"back_wa/internal/services"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/proto/waHistorySync"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// chatIndex tracks the conversations of a linked device from history sync and live message
+// events, so the chat metrics of an analysis are counted instead of derived from contacts.
+// While message scanning is enabled it also counts the recent messages matching a sensitive
+// content rule; the message text itself is never kept.
+type chatIndex struct {
+	mu    sync.Mutex
+	chats map[types.JID]*chatStat
+	dirty map[types.JID]bool
+
+	rules  *contentscan.Ruleset // nil while the user has not consented to message scanning
+	window services.MessageScanWindow
+}
+
+type chatStat struct {
+	messages      int
+	lastMessageAt time.Time
+
+	sensitive  int                    // messages matching at least one category
+	categories models.SensitiveCounts // non-nil once messages of the chat have been scanned
+}
+
+// chatCounts are the conversation metrics that feed the analysis
+type chatCounts struct {
+	Total         int // 1:1 and group conversations
+	WithContact   int // 1:1 conversations with saved contacts
+	Unsaved       int // 1:1 conversations with numbers that are not saved
+	UnknownNumber int // unsaved numbers without push or business name either
+	Groups        int
+
+	Scanned             bool                   // messages were scanned with the user's consent, Sensitive is measured
+	Sensitive           int                    // messages matching at least one sensitive content category
+	SensitiveCategories models.SensitiveCounts // messages per category
+}
+
+func newChatIndex() *chatIndex {
+	return &chatIndex{
+		chats: make(map[types.JID]*chatStat),
+		dirty: make(map[types.JID]bool),
+	}
+}
+
+// handleEvent applies chat-related whatsmeow events; it reports whether a history sync arrived
+func (c *chatIndex) handleEvent(evt interface{}) bool {
+	switch v := evt.(type) {
+	case *events.HistorySync:
+		c.applyHistorySync(v.Data)
+		return true
+	case *events.Message:
+		c.applyMessage(v.Info, v.Message)
+	}
+	return false
+}
+
+// applyHistorySync records the conversations of one history sync payload. Payloads overlap, so the
+// message (and sensitive message) counts keep the largest batch seen rather than adding them up.
+func (c *chatIndex) applyHistorySync(data *waHistorySync.HistorySync) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	since := time.Now().Add(-c.window.MaxAge)
+
+	for _, conv := range data.GetConversations() {
+		jid, err := types.ParseJID(conv.GetID())
+		if err != nil || !countableChat(jid) {
+			continue
+		}
+		stat := c.statLocked(jid.ToNonAD())
+		if n := len(conv.GetMessages()); n > stat.messages {
+			stat.messages = n
+		}
+		if ts := conv.GetConversationTimestamp(); ts > 0 {
+			if at := time.Unix(int64(ts), 0); at.After(stat.lastMessageAt) {
+				stat.lastMessageAt = at
+			}
+		}
+		if c.rules != nil {
+			c.scanHistoryLocked(stat, conv.GetMessages(), since)
+		}
+	}
+}
+
+// scanHistoryLocked classifies the latest messages of one history sync conversation that are
+// newer than since, and keeps the counts if they exceed what is already known for the chat
+func (c *chatIndex) scanHistoryLocked(stat *chatStat, messages []*waHistorySync.HistorySyncMsg, since time.Time) {
+	recent := make([]*waHistorySync.HistorySyncMsg, 0, len(messages))
+	for _, msg := range messages {
+		if time.Unix(int64(msg.GetMessage().GetMessageTimestamp()), 0).After(since) {
+			recent = append(recent, msg)
+		}
+	}
+	sort.Slice(recent, func(i, j int) bool {
+		return recent[i].GetMessage().GetMessageTimestamp() > recent[j].GetMessage().GetMessageTimestamp()
+	})
+	if c.window.MaxPerChat > 0 && len(recent) > c.window.MaxPerChat {
+		recent = recent[:c.window.MaxPerChat]
+	}
+
+	sensitive, categories := 0, make(models.SensitiveCounts)
+	for _, msg := range recent {
+		matched := c.rules.Classify(messageText(msg.GetMessage().GetMessage()))
+		if len(matched) == 0 {
+			continue
+		}
+		sensitive++
+		for _, category := range matched {
+			categories[category]++
+		}
+	}
+	if sensitive > stat.sensitive || stat.categories == nil {
+		stat.sensitive = sensitive
+		stat.categories = categories
+	}
+}
+
+// applyMessage counts one live message in its chat
+func (c *chatIndex) applyMessage(info types.MessageInfo, msg *waE2E.Message) {
+	if !countableChat(info.Chat) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stat := c.statLocked(info.Chat.ToNonAD())
+	stat.messages++
+	if info.Timestamp.After(stat.lastMessageAt) {
+		stat.lastMessageAt = info.Timestamp
+	}
+	if c.rules == nil {
+		return
+	}
+	if stat.categories == nil {
+		stat.categories = make(models.SensitiveCounts)
+	}
+	if matched := c.rules.Classify(messageText(msg)); len(matched) > 0 {
+		stat.sensitive++
+		for _, category := range matched {
+			stat.categories[category]++
+		}
+	}
+}
+
+// messageText returns the text a user wrote in a message: the body or the caption of a media message
+func messageText(msg *waE2E.Message) string {
+	switch {
+	case msg == nil:
+		return ""
+	case msg.GetConversation() != "":
+		return msg.GetConversation()
+	case msg.GetExtendedTextMessage() != nil:
+		return msg.GetExtendedTextMessage().GetText()
+	case msg.GetImageMessage() != nil:
+		return msg.GetImageMessage().GetCaption()
+	case msg.GetVideoMessage() != nil:
+		return msg.GetVideoMessage().GetCaption()
+	case msg.GetDocumentMessage() != nil:
+		return msg.GetDocumentMessage().GetCaption()
+	}
+	return ""
+}
+
+// setScanning enables message scanning with rules, or disables it (nil) and forgets the
+// sensitive counts collected so far
+func (c *chatIndex) setScanning(rules *contentscan.Ruleset, window services.MessageScanWindow) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.rules = rules
+	c.window = window
+	if rules != nil {
+		return
+	}
+	for jid, stat := range c.chats {
+		if stat.sensitive > 0 || stat.categories != nil {
+			stat.sensitive = 0
+			stat.categories = nil
+			c.dirty[jid] = true
+		}
+	}
+}
+
+// statLocked returns the entry for jid and marks it for persisting. The caller must hold c.mu.
+func (c *chatIndex) statLocked(jid types.JID) *chatStat {
+	stat, ok := c.chats[jid]
+	if !ok {
+		stat = &chatStat{}
+		c.chats[jid] = stat
+	}
+	c.dirty[jid] = true
+	return stat
+}
+
+// load seeds the index with persisted chats (entries already seen in this process win)
+func (c *chatIndex) load(chats []models.WhatsAppChat) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, chat := range chats {
+		jid, err := types.ParseJID(chat.ChatJID)
+		if err != nil {
+			continue
+		}
+		if _, ok := c.chats[jid]; ok {
+			continue
+		}
+		stat := &chatStat{messages: chat.MessageCount, sensitive: chat.SensitiveMessages, categories: chat.SensitiveCounts}
+		if chat.LastMessageAt != nil {
+			stat.lastMessageAt = *chat.LastMessageAt
+		}
+		c.chats[jid] = stat
+	}
+}
+
+// drainDirty returns the chats changed since the last call as rows for userID
+func (c *chatIndex) drainDirty(userID uint) []models.WhatsAppChat {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rows := make([]models.WhatsAppChat, 0, len(c.dirty))
+	for jid := range c.dirty {
+		stat := c.chats[jid]
+		row := models.WhatsAppChat{
+			UserID:       userID,
+			ChatJID:      jid.String(),
+			IsGroup:      jid.Server == types.GroupServer,
+			MessageCount: stat.messages,
+
+			SensitiveMessages: stat.sensitive,
+			SensitiveCounts:   stat.categories,
+		}
+		if !stat.lastMessageAt.IsZero() {
+			last := stat.lastMessageAt
+			row.LastMessageAt = &last
+		}
+		rows = append(rows, row)
+	}
+	c.dirty = make(map[types.JID]bool)
+	return rows
+}
+
+// markDirty puts rows that failed to persist back in the queue
+func (c *chatIndex) markDirty(rows []models.WhatsAppChat) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, row := range rows {
+		if jid, err := types.ParseJID(row.ChatJID); err == nil {
+			c.dirty[jid] = true
+		}
+	}
+}
+
+// reset forgets every chat (device unlinked)
+func (c *chatIndex) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.chats = make(map[types.JID]*chatStat)
+	c.dirty = make(map[types.JID]bool)
+}
+
+// counts splits the known conversations by whether the other side is a saved contact
+func (c *chatIndex) counts(saved, unsaved map[types.JID]types.ContactInfo) chatCounts {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var counts chatCounts
+	for jid, stat := range c.chats {
+		counts.Total++
+		if c.rules != nil && stat.categories != nil {
+			if !counts.Scanned {
+				counts.Scanned = true
+				counts.SensitiveCategories = make(models.SensitiveCounts)
+			}
+			counts.Sensitive += stat.sensitive
+			counts.SensitiveCategories.Add(stat.categories)
+		}
+		if jid.Server == types.GroupServer {
+			counts.Groups++
+			continue
+		}
+		if _, ok := saved[jid]; ok {
+			counts.WithContact++
+			continue
+		}
+		counts.Unsaved++
+		if contact, ok := unsaved[jid]; !ok || (contact.PushName == "" && contact.BusinessName == "") {
+			counts.UnknownNumber++
+		}
+	}
+	return counts
+}
+
+// countableChat skips status updates, broadcast lists and server notices
+func countableChat(jid types.JID) bool {
+	return !jid.IsEmpty() && jid.Server != types.BroadcastServer && jid.User != ""
+}
+
+// flushChats persists the chats changed since the last flush
+func (s *UserWhatsAppSession) flushChats() {
+	if s.chatRepo == nil {
+		return
+	}
+	rows := s.chats.drainDirty(s.UserID)
+	if len(rows) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.chatRepo.Upsert(ctx, rows); err != nil {
+		s.logger().Warn(fmt.Sprintf("Failed to persist %d chats", len(rows)), "error", err)
+		s.chats.markDirty(rows)
+	}
+}
+
+// loadChats restores the chat metadata persisted for this user
+func (s *UserWhatsAppSession) loadChats() {
+	if s.chatRepo == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	chats, err := s.chatRepo.ListByUser(ctx, s.UserID)
+	if err != nil {
+		s.logger().Warn("Failed to load chats", "error", err)
+		return
+	}
+	s.chats.load(chats)
+}
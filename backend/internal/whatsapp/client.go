@@ -4,7 +4,7 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
 	"time"
 
@@ -29,6 +29,8 @@ type WhatsApp struct {
 	// Group data storage
 	groups   map[types.JID]string
 	groupsMu sync.RWMutex
+	// Conversations seen through history sync and live messages (in memory only)
+	chats *chatIndex
 }
 
 func NewWhatsApp() *WhatsApp {
@@ -37,6 +39,7 @@ func NewWhatsApp() *WhatsApp {
 		stopChan:     make(chan bool),
 		analysisData: make(map[string]interface{}),
 		groups:       make(map[types.JID]string),
+		chats:        newChatIndex(),
 	}
 }
 
@@ -44,7 +47,7 @@ func (w *WhatsApp) Connect() error {
 	// Create database connection with proper configuration
 	db, err := sqlstore.New(context.Background(), "sqlite", "file:whatsapp_session.db?_pragma=foreign_keys(1)&_pragma=journal_mode=WAL&_pragma=synchronous=NORMAL", nil)
 	if err != nil {
-		log.Printf("Database error: %v", err)
+		slog.Error("Database error", "error", err)
 		return err
 	}
 
@@ -53,29 +56,30 @@ func (w *WhatsApp) Connect() error {
 	// Get the first device (will be empty for new session)
 	deviceStore, err := db.GetFirstDevice(context.Background())
 	if err != nil {
-		log.Printf("Device store error: %v", err)
+		slog.Error("Device store error", "error", err)
 		return err
 	}
 
 	// Create client
 	client := whatsmeow.NewClient(deviceStore, nil)
+	client.AddEventHandler(func(evt interface{}) { w.chats.handleEvent(evt) })
 
 	// Check if we have a stored session
 	if deviceStore.ID != nil {
-		log.Println("DEBUG: Found existing session, attempting to restore...")
+		slog.Debug("Found existing session, attempting to restore...")
 		// Try to restore existing session
 		err = client.Connect()
 		if err != nil {
-			log.Printf("DEBUG: Failed to restore session: %v", err)
+			slog.Debug("Failed to restore session", "error", err)
 			// Clear the invalid session
 			if clearErr := w.clearInvalidSession(); clearErr != nil {
-				log.Printf("DEBUG: Error clearing invalid session: %v", clearErr)
+				slog.Debug("Error clearing invalid session", "error", clearErr)
 			}
 			// Fall through to QR generation
 		} else {
 			// Session restored successfully, but wait for contacts to be loaded
 			w.client = client
-			log.Println("DEBUG: Session restored successfully, waiting for contacts to load...")
+			slog.Debug("Session restored successfully, waiting for contacts to load...")
 
 			// Clear any existing cache since we have a new session
 			w.ClearAnalysisCache()
@@ -90,11 +94,11 @@ func (w *WhatsApp) Connect() error {
 	}
 
 	// No valid session found, start QR generation
-	log.Println("DEBUG: No valid session found, starting QR generation...")
+	slog.Debug("No valid session found, starting QR generation...")
 	qrChan, _ := client.GetQRChannel(context.Background())
 	err = client.Connect()
 	if err != nil {
-		log.Printf("Client connect error: %v", err)
+		slog.Error("Client connect error", "error", err)
 		return err
 	}
 
@@ -102,7 +106,7 @@ func (w *WhatsApp) Connect() error {
 	w.qrChan = qrChan
 	w.ready = false
 
-	log.Println("WhatsApp client connected successfully")
+	slog.Info("WhatsApp client connected successfully")
 
 	// Start QR monitoring in background
 	go w.monitorQR()
@@ -115,7 +119,7 @@ func (w *WhatsApp) Connect() error {
 
 // waitForContactsAndSetReady waits for contacts to be loaded before setting ready status
 func (w *WhatsApp) waitForContactsAndSetReady() {
-	log.Println("DEBUG: Starting contact loading check...")
+	slog.Debug("Starting contact loading check...")
 
 	// Wait for client to be fully connected (reduced from 3s to 1s)
 	time.Sleep(1 * time.Second)
@@ -125,33 +129,33 @@ func (w *WhatsApp) waitForContactsAndSetReady() {
 
 	for attempt < maxAttempts {
 		if w.client == nil {
-			log.Println("DEBUG: Client is nil, stopping contact check")
+			slog.Debug("Client is nil, stopping contact check")
 			return
 		}
 
 		// Try to get contacts
 		contacts, err := w.client.Store.Contacts.GetAllContacts(context.Background())
 		if err != nil {
-			log.Printf("DEBUG: Error getting contacts (attempt %d): %v", attempt+1, err)
+			slog.Debug(fmt.Sprintf("Error getting contacts (attempt %d)", attempt+1), "error", err)
 		} else {
-			log.Printf("DEBUG: Found %d contacts (attempt %d)", len(contacts), attempt+1)
+			slog.Debug(fmt.Sprintf("Found %d contacts (attempt %d)", len(contacts), attempt+1))
 
 			// If we have contacts, set ready and stop checking
 			if len(contacts) > 0 {
 				w.mu.Lock()
 				w.ready = true
 				w.mu.Unlock()
-				log.Println("DEBUG: Contacts loaded successfully, WhatsApp is now ready!")
+				slog.Debug("Contacts loaded successfully, WhatsApp is now ready!")
 				return
 			}
 		}
 
 		attempt++
-		log.Printf("DEBUG: Waiting for contacts to load... (attempt %d/%d)", attempt, maxAttempts)
+		slog.Debug(fmt.Sprintf("Waiting for contacts to load... (attempt %d/%d)", attempt, maxAttempts))
 		time.Sleep(1 * time.Second) // Reduced from 2s to 1s
 	}
 
-	log.Println("DEBUG: Contact loading timeout, setting ready anyway")
+	slog.Debug("Contact loading timeout, setting ready anyway")
 	w.mu.Lock()
 	w.ready = true
 	w.mu.Unlock()
@@ -170,10 +174,10 @@ func (w *WhatsApp) clearInvalidSession() error {
 	}
 
 	for _, device := range devices {
-		log.Printf("DEBUG: Clearing invalid device: %s", device.ID)
+		slog.Debug(fmt.Sprintf("Clearing invalid device: %s", device.ID))
 		err := w.db.DeleteDevice(ctx, device)
 		if err != nil {
-			log.Printf("DEBUG: Error deleting device: %v", err)
+			slog.Debug("Error deleting device", "error", err)
 		}
 	}
 
@@ -181,14 +185,14 @@ func (w *WhatsApp) clearInvalidSession() error {
 }
 
 func (w *WhatsApp) monitorQR() {
-	log.Println("DEBUG: Starting QR monitoring...")
+	slog.Debug("Starting QR monitoring...")
 	timeoutCount := 0
 	maxTimeouts := 5 // Maximum QR timeouts before giving up
 
 	for {
 		select {
 		case evt := <-w.qrChan:
-			log.Printf("DEBUG: QR event received: %s", evt.Event)
+			slog.Debug(fmt.Sprintf("QR event received: %s", evt.Event))
 			if evt.Event == "code" {
 				// Reset timeout count on successful QR generation
 				timeoutCount = 0
@@ -196,17 +200,17 @@ func (w *WhatsApp) monitorQR() {
 				// Generate QR code using the library with the actual data
 				qrBytes, err := qrcode.Encode(evt.Code, qrcode.Medium, 256)
 				if err != nil {
-					log.Printf("DEBUG: Error generating QR code: %v", err)
+					slog.Debug("Error generating QR code", "error", err)
 					continue
 				}
 
 				w.mu.Lock()
 				w.qrCode = "data:image/png;base64," + base64.StdEncoding.EncodeToString(qrBytes)
 				w.mu.Unlock()
-				log.Printf("DEBUG: New QR code generated successfully with data: %s", evt.Code[:20]+"...")
+				slog.Debug(fmt.Sprintf("New QR code generated successfully with data: %s", evt.Code[:20]+"..."))
 			} else if evt.Event == "timeout" {
 				timeoutCount++
-				log.Printf("DEBUG: QR code timeout #%d, will generate new one", timeoutCount)
+				slog.Debug(fmt.Sprintf("QR code timeout #%d, will generate new one", timeoutCount))
 
 				w.mu.Lock()
 				w.qrCode = ""
@@ -214,7 +218,7 @@ func (w *WhatsApp) monitorQR() {
 
 				// Check if we've hit the maximum timeout limit
 				if timeoutCount >= maxTimeouts {
-					log.Printf("DEBUG: Maximum QR timeouts reached (%d), stopping QR generation", maxTimeouts)
+					slog.Debug(fmt.Sprintf("Maximum QR timeouts reached (%d), stopping QR generation", maxTimeouts))
 					return
 				}
 
@@ -224,21 +228,21 @@ func (w *WhatsApp) monitorQR() {
 					backoffDelay = 30 * time.Second // Cap at 30 seconds
 				}
 
-				log.Printf("DEBUG: Waiting %v before retrying QR generation", backoffDelay)
+				slog.Debug(fmt.Sprintf("Waiting %v before retrying QR generation", backoffDelay))
 
 				// Attempt automatic QR refresh with exponential backoff
 				go func() {
 					time.Sleep(backoffDelay)
 					if err := w.RefreshQR(); err != nil {
-						log.Printf("DEBUG: Error refreshing QR after timeout: %v", err)
+						slog.Debug("Error refreshing QR after timeout", "error", err)
 					}
 				}()
 			} else if evt.Event == "error" {
-				log.Printf("DEBUG: QR error event received: %v", evt)
+				slog.Debug(fmt.Sprintf("QR error event received: %v", evt))
 				// Don't increment timeout count for errors, just log them
 			}
 		case <-w.stopChan:
-			log.Println("DEBUG: QR monitoring stopped")
+			slog.Debug("QR monitoring stopped")
 			return
 		}
 	}
@@ -257,9 +261,9 @@ func (w *WhatsApp) monitorStatus() {
 				// Check if user is actually logged in (has valid session)
 				w.ready = w.client.Store.ID != nil && w.client.IsConnected()
 				if oldReady != w.ready {
-					log.Printf("DEBUG: Status changed: %v -> %v", oldReady, w.ready)
+					slog.Debug(fmt.Sprintf("Status changed: %v -> %v", oldReady, w.ready))
 					if w.ready {
-						log.Println("DEBUG: WhatsApp login detected!")
+						slog.Debug("WhatsApp login detected!")
 						// Clear QR code when logged in
 						w.qrCode = ""
 
@@ -270,7 +274,7 @@ func (w *WhatsApp) monitorStatus() {
 				w.mu.Unlock()
 			}
 		case <-w.stopChan:
-			log.Println("DEBUG: Status monitoring stopped")
+			slog.Debug("Status monitoring stopped")
 			return
 		}
 	}
@@ -296,7 +300,7 @@ func (w *WhatsApp) Reset() {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	log.Println("DEBUG: WhatsApp session reset - clearing all data")
+	slog.Debug("WhatsApp session reset - clearing all data")
 
 	// Stop monitoring
 	close(w.stopChan)
@@ -304,7 +308,7 @@ func (w *WhatsApp) Reset() {
 
 	// Disconnect client
 	if w.client != nil {
-		log.Println("DEBUG: Disconnecting WhatsApp client...")
+		slog.Debug("Disconnecting WhatsApp client...")
 		w.client.Disconnect()
 	}
 
@@ -313,13 +317,13 @@ func (w *WhatsApp) Reset() {
 		ctx := context.Background()
 		devices, err := w.db.GetAllDevices(ctx)
 		if err != nil {
-			log.Printf("DEBUG: Error getting devices: %v", err)
+			slog.Debug("Error getting devices", "error", err)
 		} else {
 			for _, device := range devices {
-				log.Printf("DEBUG: Deleting device: %s", device.ID)
+				slog.Debug(fmt.Sprintf("Deleting device: %s", device.ID))
 				err := w.db.DeleteDevice(ctx, device)
 				if err != nil {
-					log.Printf("DEBUG: Error deleting device: %v", err)
+					slog.Debug("Error deleting device", "error", err)
 				}
 			}
 		}
@@ -329,13 +333,16 @@ func (w *WhatsApp) Reset() {
 	w.analysisMu.Lock()
 	w.analysisData = make(map[string]interface{})
 	w.analysisMu.Unlock()
-	log.Println("DEBUG: Analysis data cache cleared")
+	slog.Debug("Analysis data cache cleared")
 
 	// Clear group data cache
 	w.groupsMu.Lock()
 	w.groups = make(map[types.JID]string)
 	w.groupsMu.Unlock()
-	log.Println("DEBUG: Group data cache cleared")
+	slog.Debug("Group data cache cleared")
+
+	// Clear chat metadata of the unlinked device
+	w.chats.reset()
 
 	// Reset state
 	w.client = nil
@@ -343,16 +350,9 @@ func (w *WhatsApp) Reset() {
 	w.ready = false
 	w.db = nil
 
-	log.Println("DEBUG: WhatsApp session reset completed - waiting for frontend to reconnect")
+	slog.Debug("WhatsApp session reset completed - waiting for frontend to reconnect")
 
-	// ❌ REMOVED: Auto-restart yang menyebabkan conflict
-	// Sekarang backend akan menunggu frontend untuk reconnect
-	// go func() {
-	// 	log.Println("DEBUG: Restarting WhatsApp connection...")
-	// 	if err := w.Connect(); err != nil {
-	// 		log.Printf("DEBUG: Error reconnecting: %v", err)
-	// 	}
-	// }()
+	// No auto-restart here (it conflicted with the frontend): the frontend reconnects
 }
 
 // ✅ NEW: Manual reconnect function untuk frontend control
@@ -360,16 +360,16 @@ func (w *WhatsApp) ManualReconnect() error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	log.Println("DEBUG: Manual reconnect requested by frontend...")
+	slog.Debug("Manual reconnect requested by frontend...")
 
 	// Check if already connected
 	if w.client != nil && w.ready {
-		log.Println("DEBUG: Already connected, no need to reconnect")
+		slog.Debug("Already connected, no need to reconnect")
 		return nil
 	}
 
 	// Start fresh connection
-	log.Println("DEBUG: Starting fresh WhatsApp connection...")
+	slog.Debug("Starting fresh WhatsApp connection...")
 	return w.Connect()
 }
 
@@ -385,15 +385,15 @@ func (w *WhatsApp) RefreshQR() error {
 
 	// If already logged in and connected, no QR is needed
 	if w.client.Store.ID != nil && w.client.IsConnected() {
-		log.Println("DEBUG: RefreshQR skipped: client already logged in and connected")
+		slog.Debug("RefreshQR skipped: client already logged in and connected")
 		return nil
 	}
 
-	log.Println("DEBUG: Starting QR refresh...")
+	slog.Debug("Starting QR refresh...")
 
 	// Disconnect if connected (to restart QR flow)
 	if w.client.IsConnected() {
-		log.Println("DEBUG: Disconnecting client for QR refresh...")
+		slog.Debug("Disconnecting client for QR refresh...")
 		w.client.Disconnect()
 		time.Sleep(1 * time.Second) // Give more time for disconnect
 	}
@@ -416,6 +416,6 @@ func (w *WhatsApp) RefreshQR() error {
 		return fmt.Errorf("failed to reconnect for QR: %w", err)
 	}
 
-	log.Println("DEBUG: QR refresh triggered successfully")
+	slog.Debug("QR refresh triggered successfully")
 	return nil
 }
@@ -0,0 +1,132 @@
+package whatsapp
+
+import (
+	"fmt"
+	"time"
+
+	"back_wa/internal/models"
+	"back_wa/internal/services"
+)
+
+// analysisSourceRetryDelay is the wait before the first retry of a failed data source;
+// later attempts wait proportionally longer
+const analysisSourceRetryDelay = 30 * time.Second
+
+// analysisSourceRetries returns ANALYSIS_SOURCE_RETRIES (default 3)
+func analysisSourceRetries() int {
+	return envInt("ANALYSIS_SOURCE_RETRIES", 3)
+}
+
+// retryGroupsInBackground retries GetJoinedGroups after an analysis fell back to
+// contact-derived group counts, and upgrades the result once it succeeds
+func (s *UserWhatsAppSession) retryGroupsInBackground(result models.AnalysisResult) {
+	if !s.groupRetrying.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		defer s.groupRetrying.Store(false)
+
+		retries := analysisSourceRetries()
+		for attempt := 1; attempt <= retries; attempt++ {
+			time.Sleep(time.Duration(attempt) * analysisSourceRetryDelay)
+			if !s.IsReady() {
+				s.logger().Debug("Session not ready, stopping group fetch retries")
+				return
+			}
+
+			groups, err := s.joinedGroups()
+			if err != nil {
+				s.logger().Debug(fmt.Sprintf("Group fetch retry %d/%d failed", attempt, retries), "error", err)
+				continue
+			}
+			s.upgradeGroups(result, len(groups))
+			return
+		}
+		s.logger().Warn("Giving up on group fetch, analysis stays degraded")
+	}()
+}
+
+// upgradeGroups rescores the result with the fetched group count and replaces the cached
+// and stored result, unless a newer analysis superseded it meanwhile
+func (s *UserWhatsAppSession) upgradeGroups(result models.AnalysisResult, joinedGroups int) {
+	s.GroupsMu.RLock()
+	storedGroups := len(s.Groups)
+	s.GroupsMu.RUnlock()
+
+	result.TotalGroups = joinedGroups
+	if storedGroups > result.TotalGroups {
+		result.TotalGroups = storedGroups
+	}
+	confidences := make(models.ParameterConfidences, len(result.ParameterConfidence))
+	for key, confidence := range result.ParameterConfidence {
+		if key != models.ScoreTotalGroups {
+			confidences[key] = confidence
+		}
+	}
+
+	// Only the group count changed; check it again and keep the other anomalies
+	inputs := result.Inputs()
+	var anomalies, groupAnomalies []models.InputAnomaly
+	for _, anomaly := range inputs.Sanitize() {
+		if anomaly.Key == models.ScoreTotalGroups {
+			groupAnomalies = append(groupAnomalies, anomaly)
+		}
+	}
+	for _, anomaly := range result.Anomalies {
+		if anomaly.Key != models.ScoreTotalGroups {
+			anomalies = append(anomalies, anomaly)
+		}
+	}
+	result.TotalGroups = inputs.TotalGroups
+	result.Anomalies = append(anomalies, groupAnomalies...)
+	confidences.Downgrade(groupAnomalies)
+	services.RecordInputAnomalies(s.UserID, groupAnomalies)
+
+	scoring := services.ActiveScoringConfig()
+	scoringVersion := scoring.ID
+	result.ScoringVersion = &scoringVersion
+	result.ParameterConfidence = confidences
+	result.Confidence = confidences.Overall(scoring)
+	result.Strength, result.Summary, result.Parameters = models.ScoreStrength(scoring, confidences,
+		result.TotalChats, result.TotalContacts, result.AccountAgeDays, result.TotalGroups, result.TotalChatWithContact,
+		result.SensitiveContentCount, result.TotalUnsavedChats, result.UnknownNumberChats)
+
+	now := time.Now()
+	quality := *result.DataQuality
+	quality.Groups = models.DataSourceOK
+	quality.Errors = nil
+	for source, msg := range result.DataQuality.Errors {
+		if source != "groups" {
+			if quality.Errors == nil {
+				quality.Errors = make(map[string]string)
+			}
+			quality.Errors[source] = msg
+		}
+	}
+	quality.UpgradedAt = &now
+	quality.Refresh()
+	result.DataQuality = &quality
+
+	s.AnalysisMu.Lock()
+	cached, ok := s.AnalysisCache["current_session"].(models.AnalysisResult)
+	current := ok && cached.ScanDate.Truncate(time.Second).Equal(result.ScanDate.Truncate(time.Second))
+	if current {
+		s.AnalysisCache["current_session"] = result
+	}
+	s.AnalysisMu.Unlock()
+	if !current {
+		s.logger().Debug("Analysis was superseded, not upgrading it")
+		return
+	}
+	s.publishAnalysis(result)
+
+	// Results still in the spool have no row yet and are replayed as recorded
+	if result.ID != 0 {
+		if err := s.analysisService.UpgradeAnalysisResult(&result); err != nil {
+			s.logger().Warn(fmt.Sprintf("Failed to store upgraded analysis %d", result.ID), "error", err)
+			return
+		}
+	}
+	s.logger().Debug(fmt.Sprintf("Analysis upgraded with %d groups from WhatsApp (strength=%s)", result.TotalGroups, result.Strength))
+}
@@ -0,0 +1,45 @@
+package whatsapp
+
+import (
+	"hash/fnv"
+	"os"
+	"strconv"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// analysisDeterministic reports ANALYSIS_DETERMINISTIC (default true). In deterministic mode
+// estimates depend only on the account's data and phone number, so two scans of the same
+// account minutes apart rate it the same; false restores the legacy per-device variation.
+func analysisDeterministic() bool {
+	deterministic, err := strconv.ParseBool(os.Getenv("ANALYSIS_DETERMINISTIC"))
+	return err != nil || deterministic
+}
+
+// accountSeed is a stable per-account number for estimates without data. Deterministic mode
+// hashes the phone number, which survives relinking; legacy mode sums the device JID.
+func accountSeed(id types.JID) int {
+	if !analysisDeterministic() {
+		seed := 0
+		for _, char := range id.String() {
+			seed += int(char)
+		}
+		return seed
+	}
+	h := fnv.New32a()
+	h.Write([]byte(id.User))
+	return int(h.Sum32() & 0x7fffffff)
+}
+
+// varyAccountAge shifts a low-confidence age estimate by up to pct percent so estimates don't
+// cluster on the same few values. Deterministic mode picks the offset from the account seed.
+func varyAccountAge(age, pct int, id types.JID) int {
+	span := age * pct / 100
+	if !analysisDeterministic() {
+		return age + (span * 2) - span
+	}
+	if span == 0 {
+		return age
+	}
+	return age + accountSeed(id)%(2*span+1) - span
+}
@@ -0,0 +1,84 @@
+package whatsapp
+
+import (
+	"testing"
+
+	"back_wa/internal/models"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func phoneJID(user string, device uint16) types.JID {
+	return types.JID{User: user, Server: types.DefaultUserServer, Device: device}
+}
+
+func TestAccountSeedStableAcrossRelinks(t *testing.T) {
+	t.Setenv("ANALYSIS_DETERMINISTIC", "true")
+
+	want := accountSeed(phoneJID("6281234567890", 12))
+	for run := 0; run < 100; run++ {
+		if got := accountSeed(phoneJID("6281234567890", 12)); got != want {
+			t.Fatalf("run %d: seed %d, want %d", run, got, want)
+		}
+	}
+	// Relinking gives the account a new device number, the seed must not change with it
+	if got := accountSeed(phoneJID("6281234567890", 37)); got != want {
+		t.Fatalf("seed after relink %d, want %d", got, want)
+	}
+	if want < 0 {
+		t.Fatalf("seed %d is negative", want)
+	}
+}
+
+func TestAccountSeedDiffersPerAccount(t *testing.T) {
+	t.Setenv("ANALYSIS_DETERMINISTIC", "true")
+
+	phones := []string{"6281234567890", "6281234567891", "6281298765432", "6285700011122", "6289900000001"}
+	seen := map[int]string{}
+	for _, phone := range phones {
+		seed := accountSeed(phoneJID(phone, 1))
+		if other, ok := seen[seed]; ok {
+			t.Fatalf("%s and %s share seed %d", phone, other, seed)
+		}
+		seen[seed] = phone
+	}
+}
+
+func TestVaryAccountAgeDeterministic(t *testing.T) {
+	t.Setenv("ANALYSIS_DETERMINISTIC", "true")
+
+	for _, pct := range []int{10, 20} {
+		for _, phone := range []string{"6281234567890", "6285700011122", "6289900000001"} {
+			id := phoneJID(phone, 3)
+			want := varyAccountAge(400, pct, id)
+			span := 400 * pct / 100
+			if want < 400-span || want > 400+span {
+				t.Fatalf("%s ±%d%%: age %d outside [%d, %d]", phone, pct, want, 400-span, 400+span)
+			}
+			for run := 0; run < 50; run++ {
+				if got := varyAccountAge(400, pct, phoneJID(phone, uint16(run))); got != want {
+					t.Fatalf("%s ±%d%% run %d: age %d, want %d", phone, pct, run, got, want)
+				}
+			}
+		}
+	}
+	if got := varyAccountAge(4, 10, phoneJID("6281234567890", 1)); got != 4 {
+		t.Fatalf("age without room to vary changed to %d", got)
+	}
+}
+
+func TestSameInputsScoreIdentically(t *testing.T) {
+	t.Setenv("ANALYSIS_DETERMINISTIC", "true")
+
+	score := func(device uint16) (string, string) {
+		age := varyAccountAge(365, 20, phoneJID("6281234567890", device))
+		return models.CalculateStrength(350, 220, age, 12, 180, 3, 40, 15)
+	}
+	wantStrength, wantSummary := score(1)
+	for run := 0; run < 20; run++ {
+		strength, summary := score(uint16(run))
+		if strength != wantStrength || summary != wantSummary {
+			t.Fatalf("run %d: %s / %q, want %s / %q", run, strength, summary, wantStrength, wantSummary)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package whatsapp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/services"
+)
+
+// HandleEntitlement handles GET /api/wa/entitlement?phone= so the frontend can gate the QR screen
+// before connecting. It answers the same precheck as GET /api/payments/check (PaymentService.Precheck).
+func (h *MultiUserWhatsAppHandler) HandleEntitlement(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Method not allowed"})
+		return
+	}
+
+	// The scan widget asks before showing the QR, so its wa:qr token is accepted too
+	userID, err := h.extractUserIDForScope(r, services.ScopeWAQR)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	phone := strings.TrimSpace(r.URL.Query().Get("phone"))
+	if services.NormalizePhoneNumber(phone) == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "phone is required"})
+		return
+	}
+
+	precheck, err := services.NewPaymentService(h.repos).WithContext(r.Context()).Precheck(userID, phone)
+	if err != nil {
+		logging.FromContext(r.Context()).Error(fmt.Sprintf("Entitlement check failed for phone %s", phone), "user_id", userID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Failed to verify payment status"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": precheck})
+}
@@ -4,15 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"time"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/models"
 )
 
 func (w *WhatsApp) HandleQR(wr http.ResponseWriter, r *http.Request) {
 	qrCode := w.GetQRCode()
 
-	log.Printf("QR request received, QR available: %v", qrCode != "")
+	logging.FromContext(r.Context()).Info(fmt.Sprintf("QR request received, QR available: %v", qrCode != ""))
 
 	if qrCode == "" {
 		// QR code belum tersedia
@@ -47,7 +49,7 @@ func (w *WhatsApp) HandleStatus(wr http.ResponseWriter, r *http.Request) {
 			contactCount = len(contacts)
 			contactsReady = contactCount > 0
 		} else {
-			log.Printf("DEBUG: Error getting contacts in status check: %v", err)
+			logging.FromContext(r.Context()).Debug("Error getting contacts in status check", "error", err)
 		}
 	}
 
@@ -59,19 +61,18 @@ func (w *WhatsApp) HandleStatus(wr http.ResponseWriter, r *http.Request) {
 		"timestamp":        time.Now().Format(time.RFC3339),
 	}
 
-	log.Printf("DEBUG: Status request - ready: %v, contacts_ready: %v, contact_count: %d",
-		status, contactsReady, contactCount)
+	logging.FromContext(r.Context()).Debug(fmt.Sprintf("Status request - ready: %v, contacts_ready: %v, contact_count: %d", status, contactsReady, contactCount), "status", status)
 
 	wr.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(wr).Encode(response)
 }
 
 func (w *WhatsApp) HandleAnalyze(wr http.ResponseWriter, r *http.Request) {
-	log.Println("DEBUG: HandleAnalyze called - starting analysis...")
+	logging.FromContext(r.Context()).Debug("HandleAnalyze called - starting analysis...")
 
 	// Check if WhatsApp client exists
 	if w.GetClient() == nil {
-		log.Println("DEBUG: WhatsApp client not available")
+		logging.FromContext(r.Context()).Debug("WhatsApp client not available")
 		response := map[string]interface{}{
 			"error": "WhatsApp client not available",
 			"status": map[string]interface{}{
@@ -88,7 +89,7 @@ func (w *WhatsApp) HandleAnalyze(wr http.ResponseWriter, r *http.Request) {
 
 	// Check if WhatsApp is ready
 	if !w.IsReady() {
-		log.Println("DEBUG: WhatsApp not ready, cannot analyze")
+		logging.FromContext(r.Context()).Debug("WhatsApp not ready, cannot analyze")
 		response := map[string]interface{}{
 			"error": "WhatsApp not logged in. Please scan QR code first",
 			"status": map[string]interface{}{
@@ -108,7 +109,7 @@ func (w *WhatsApp) HandleAnalyze(wr http.ResponseWriter, r *http.Request) {
 	if client != nil {
 		contacts, err := client.Store.Contacts.GetAllContacts(context.Background())
 		if err != nil || len(contacts) == 0 {
-			log.Println("DEBUG: Contacts not ready, cannot analyze")
+			logging.FromContext(r.Context()).Debug("Contacts not ready, cannot analyze")
 			response := map[string]interface{}{
 				"error": "Contacts not loaded yet. Please wait a moment and try again",
 				"status": map[string]interface{}{
@@ -127,7 +128,7 @@ func (w *WhatsApp) HandleAnalyze(wr http.ResponseWriter, r *http.Request) {
 
 	res, err := w.Analyze()
 	if err != nil {
-		log.Printf("DEBUG: Analysis error: %v", err)
+		logging.FromContext(r.Context()).Debug("Analysis error", "error", err)
 		response := map[string]interface{}{
 			"error": err.Error(),
 			"status": map[string]interface{}{
@@ -142,20 +143,20 @@ func (w *WhatsApp) HandleAnalyze(wr http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("DEBUG: Analysis completed successfully")
-	log.Printf("DEBUG: Response data - Strength: %s", res.Strength)
-	log.Printf("DEBUG: Response data - Total Chats: %d", res.TotalChats)
-	log.Printf("DEBUG: Response data - Total Contacts: %d", res.TotalContacts)
-	log.Printf("DEBUG: Response data - Account Age: %d days", res.AccountAgeDays)
-	log.Printf("DEBUG: Response data - Total Groups: %d", res.TotalGroups)
-	log.Printf("DEBUG: Response data - Chat with Contact: %d", res.TotalChatWithContact)
-	log.Printf("DEBUG: Response data - Sensitive Content: %d", res.SensitiveContentCount)
-	log.Printf("DEBUG: Response data - Total Unsaved Chats: %d", res.TotalUnsavedChats)
-	log.Printf("DEBUG: Response data - Unknown Number Chats: %d", res.UnknownNumberChats)
+	logging.FromContext(r.Context()).Debug("Analysis completed successfully")
+	logging.FromContext(r.Context()).Debug(fmt.Sprintf("Response data - Strength: %s", res.Strength))
+	logging.FromContext(r.Context()).Debug(fmt.Sprintf("Response data - Total Chats: %d", res.TotalChats))
+	logging.FromContext(r.Context()).Debug(fmt.Sprintf("Response data - Total Contacts: %d", res.TotalContacts))
+	logging.FromContext(r.Context()).Debug(fmt.Sprintf("Response data - Account Age: %d days", res.AccountAgeDays))
+	logging.FromContext(r.Context()).Debug(fmt.Sprintf("Response data - Total Groups: %d", res.TotalGroups))
+	logging.FromContext(r.Context()).Debug(fmt.Sprintf("Response data - Chat with Contact: %d", res.TotalChatWithContact))
+	logging.FromContext(r.Context()).Debug(fmt.Sprintf("Response data - Sensitive Content: %d", res.SensitiveContentCount))
+	logging.FromContext(r.Context()).Debug(fmt.Sprintf("Response data - Total Unsaved Chats: %d", res.TotalUnsavedChats))
+	logging.FromContext(r.Context()).Debug(fmt.Sprintf("Response data - Unknown Number Chats: %d", res.UnknownNumberChats))
 
 	// Add status information to response
 	response := map[string]interface{}{
-		"analysis": res,
+		"analysis": models.NewAnalysisResponse(&res),
 		"status": map[string]interface{}{
 			"whatsapp_ready":   w.IsReady(),
 			"client_available": w.GetClient() != nil,
@@ -168,7 +169,7 @@ func (w *WhatsApp) HandleAnalyze(wr http.ResponseWriter, r *http.Request) {
 }
 
 func (w *WhatsApp) HandleLogout(wr http.ResponseWriter, r *http.Request) {
-	log.Println("DEBUG: Logout request received - clearing session and analysis data")
+	logging.FromContext(r.Context()).Debug("Logout request received - clearing session and analysis data")
 
 	// Clear analysis cache before reset
 	w.ClearAnalysisCache()
@@ -176,7 +177,7 @@ func (w *WhatsApp) HandleLogout(wr http.ResponseWriter, r *http.Request) {
 	// Reset session untuk user baru
 	w.Reset()
 
-	log.Println("DEBUG: Logout completed successfully - session cleared and ready for new login")
+	logging.FromContext(r.Context()).Debug("Logout completed successfully - session cleared and ready for new login")
 
 	response := map[string]interface{}{
 		"message": "Logged out successfully and analysis data cleared",
@@ -193,9 +194,9 @@ func (w *WhatsApp) HandleLogout(wr http.ResponseWriter, r *http.Request) {
 
 // HandleRefreshQR triggers QR regeneration without full logout
 func (w *WhatsApp) HandleRefreshQR(wr http.ResponseWriter, r *http.Request) {
-	log.Println("QR refresh request received")
+	logging.FromContext(r.Context()).Info("QR refresh request received")
 	if err := w.RefreshQR(); err != nil {
-		log.Printf("QR refresh error: %v", err)
+		logging.FromContext(r.Context()).Error("QR refresh error", "error", err)
 		http.Error(wr, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -204,7 +205,7 @@ func (w *WhatsApp) HandleRefreshQR(wr http.ResponseWriter, r *http.Request) {
 
 // ✅ NEW: Manual reconnect endpoint untuk frontend control
 func (w *WhatsApp) HandleManualReconnect(wr http.ResponseWriter, r *http.Request) {
-	log.Println("DEBUG: Manual reconnect request received from frontend")
+	logging.FromContext(r.Context()).Debug("Manual reconnect request received from frontend")
 
 	if r.Method != "POST" {
 		http.Error(wr, "Method not allowed", http.StatusMethodNotAllowed)
@@ -214,7 +215,7 @@ func (w *WhatsApp) HandleManualReconnect(wr http.ResponseWriter, r *http.Request
 	// Attempt manual reconnect
 	err := w.ManualReconnect()
 	if err != nil {
-		log.Printf("DEBUG: Manual reconnect failed: %v", err)
+		logging.FromContext(r.Context()).Debug("Manual reconnect failed", "error", err)
 		response := map[string]interface{}{
 			"error": fmt.Sprintf("Failed to reconnect: %v", err),
 			"status": map[string]interface{}{
@@ -229,7 +230,7 @@ func (w *WhatsApp) HandleManualReconnect(wr http.ResponseWriter, r *http.Request
 		return
 	}
 
-	log.Println("DEBUG: Manual reconnect completed successfully")
+	logging.FromContext(r.Context()).Debug("Manual reconnect completed successfully")
 	response := map[string]interface{}{
 		"message": "Reconnected successfully",
 		"status": map[string]interface{}{
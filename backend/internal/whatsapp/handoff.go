@@ -0,0 +1,105 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Session handoff during rolling deploys: the stopping instance marks each connected session as
+// handed off before disconnecting it and raises the handoff signal once its clients are closed.
+// Instances still running pick the signal up and restore those sessions straight away instead of
+// waiting for their next start, and statuses read as "reconnecting" until a session is reclaimed.
+
+// handoffPollInterval is how often running instances look for a handoff signal
+const handoffPollInterval = 2 * time.Second
+
+// handoffSignalKey holds the time of the last handoff of any instance
+const handoffSignalKey = "wa:handoff:signal"
+
+func handoffCacheKey(userID uint) string { return fmt.Sprintf("wa:handoff:%d", userID) }
+
+// cachedHandoff is the stored form of a handoff marker and of the handoff signal
+type cachedHandoff struct {
+	At time.Time `json:"at"`
+}
+
+// handoffWindow returns WA_HANDOFF_WINDOW_SECONDS (default 120), how long a handed-off session
+// reads as "reconnecting" before it falls back to its last status
+func handoffWindow() time.Duration {
+	seconds := envInt("WA_HANDOFF_WINDOW_SECONDS", 120)
+	if seconds <= 0 {
+		seconds = 120
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// markHandoff flags the user's session as being handed over to another instance
+func markHandoff(userID uint) {
+	putCached(handoffCacheKey(userID), cachedHandoff{At: time.Now()}, handoffWindow())
+}
+
+// pendingHandoff reports whether the user's session was handed off and not reclaimed yet
+func pendingHandoff(userID uint) bool {
+	var marker cachedHandoff
+	return getCached(handoffCacheKey(userID), &marker)
+}
+
+// clearHandoff removes the marker once the session was reclaimed (or failed to be)
+func clearHandoff(userID uint) {
+	deleteCached(handoffCacheKey(userID))
+}
+
+// signalHandoff tells the other instances to reclaim the handed-off sessions
+func signalHandoff() {
+	putCached(handoffSignalKey, cachedHandoff{At: time.Now()}, handoffWindow())
+}
+
+// watchHandoffs restores sessions handed off by stopping instances until this one shuts down.
+// WA_RESTORE_SESSIONS=false disables it together with the restore on startup.
+func (m *MultiUserWhatsAppManager) watchHandoffs() {
+	if os.Getenv("WA_RESTORE_SESSIONS") == "false" {
+		return
+	}
+
+	// Signals raised before this instance started are covered by restoreConnectedSessions
+	lastSeen := time.Now()
+	ticker := time.NewTicker(handoffPollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if m.stopping.Load() {
+			return
+		}
+		var signal cachedHandoff
+		if !getCached(handoffSignalKey, &signal) || !signal.At.After(lastSeen) {
+			continue
+		}
+		lastSeen = signal.At
+		m.reclaimHandedOffSessions()
+	}
+}
+
+// reclaimHandedOffSessions restores the connected sessions that carry a handoff marker
+func (m *MultiUserWhatsAppManager) reclaimHandedOffSessions() {
+	ctx, cancel := context.WithTimeout(context.Background(), restoreLoadTimeout)
+	records, err := m.repos.Sessions.ListByStatus(ctx, "connected")
+	cancel()
+	if err != nil {
+		slog.Warn("Failed to load handed-off WhatsApp sessions", "error", err)
+		return
+	}
+
+	var userIDs []uint
+	for _, record := range records {
+		if pendingHandoff(record.UserID) {
+			userIDs = append(userIDs, record.UserID)
+		}
+	}
+	if len(userIDs) == 0 {
+		return
+	}
+	restored := m.restoreSessions(userIDs)
+	slog.Info("Reclaimed handed-off WhatsApp sessions", "restored", restored, "handed_off", len(userIDs))
+}
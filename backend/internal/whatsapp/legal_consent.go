@@ -0,0 +1,34 @@
+package whatsapp
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"back_wa/internal/services"
+)
+
+// consentRequired answers 403 (error_type consent_required) and returns true when a new major
+// version of a legal document is in effect that the user has not accepted yet
+func (h *MultiUserWhatsAppHandler) consentRequired(w http.ResponseWriter, userID uint) bool {
+	pending, err := services.NewLegalService(h.waManager.repos.Legal).RequiresReconsent(userID)
+	if err != nil {
+		// Don't lock users out of analysis because the consent lookup failed
+		slog.Error("Failed to check legal consent", "user_id", userID, "error", err)
+		return false
+	}
+	if len(pending) == 0 {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    false,
+		"error":      "Consent required",
+		"error_type": "consent_required",
+		"message":    "Syarat & ketentuan atau kebijakan privasi telah diperbarui. Silakan setujui versi terbaru sebelum melakukan analisis.",
+		"documents":  pending,
+	})
+	return true
+}
@@ -0,0 +1,93 @@
+package whatsapp
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/services"
+)
+
+// loadMessageScan enables sensitive content scanning of incoming history sync and live messages
+// when the user consents to it
+func (s *UserWhatsAppSession) loadMessageScan() {
+	enabled, err := services.NewMessageScanService(s.chatRepo).Enabled(s.UserID)
+	if err != nil {
+		s.logger().Warn("Failed to load message scan consent", "error", err)
+		return
+	}
+	s.setMessageScan(enabled)
+}
+
+// setMessageScan switches message scanning on or off; switching it off drops the sensitive counts
+func (s *UserWhatsAppSession) setMessageScan(enabled bool) {
+	if enabled {
+		s.chats.setScanning(services.SensitiveContentRules(), services.ActiveMessageScanWindow())
+		return
+	}
+	s.chats.setScanning(nil, services.MessageScanWindow{})
+	s.flushChats()
+}
+
+// SetMessageScan applies a changed consent to the user's live session, if there is one
+func (m *MultiUserWhatsAppManager) SetMessageScan(userID uint, enabled bool) {
+	m.mu.RLock()
+	session, exists := m.userSessions[userID]
+	m.mu.RUnlock()
+	if exists {
+		session.setMessageScan(enabled)
+	}
+}
+
+// HandleMessageScan handles GET and PUT /api/wa/message-scan: the user's consent to scanning
+// their recent messages for sensitive content. PUT body: {"enabled": bool}
+func (h *MultiUserWhatsAppHandler) HandleMessageScan(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Method not allowed"})
+		return
+	}
+
+	userID, err := h.extractUserIDFromToken(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	scans := services.NewMessageScanService(h.repos.Chats)
+	if r.Method == http.MethodGet {
+		consent, err := scans.GetConsent(userID)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Failed to get message scan consent", "user_id", userID, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Failed to get message scan consent"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": consent})
+		return
+	}
+
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "enabled is required"})
+		return
+	}
+
+	consent, err := scans.SetConsent(userID, *req.Enabled)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to update message scan consent", "user_id", userID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Failed to update message scan consent"})
+		return
+	}
+	h.waManager.SetMessageScan(userID, consent.Enabled)
+	logging.FromContext(r.Context()).Info("Message scan consent changed", "user_id", userID, "enabled", consent.Enabled)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": consent})
+}
@@ -1,15 +1,22 @@
 package whatsapp
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"strings"
 	"time"
 
 	"back_wa/internal/database"
+	"back_wa/internal/logging"
+	"back_wa/internal/models"
+	"back_wa/internal/repository"
 	"back_wa/internal/services"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
 )
 
 // MultiUserWhatsAppHandler handles WhatsApp operations for multiple users
@@ -17,19 +24,27 @@ type MultiUserWhatsAppHandler struct {
 	waManager       *MultiUserWhatsAppManager
 	authService     *services.AuthService
 	analysisService *services.AnalysisService
+	repos           *repository.Repositories
 }
 
-// NewMultiUserWhatsAppHandler creates a new multi-user WhatsApp handler
-func NewMultiUserWhatsAppHandler() *MultiUserWhatsAppHandler {
+// NewMultiUserWhatsAppHandler creates a new multi-user WhatsApp handler on the given repositories
+func NewMultiUserWhatsAppHandler(repos *repository.Repositories) *MultiUserWhatsAppHandler {
 	return &MultiUserWhatsAppHandler{
-		waManager:       NewMultiUserWhatsAppManager(),
-		authService:     &services.AuthService{},
-		analysisService: &services.AnalysisService{},
+		waManager:       NewMultiUserWhatsAppManager(repos),
+		authService:     services.NewAuthService(repos.Users, repos.AuthSessions),
+		analysisService: services.NewAnalysisService(repos),
+		repos:           repos,
 	}
 }
 
 // extractUserIDFromToken extracts user ID from JWT token
 func (h *MultiUserWhatsAppHandler) extractUserIDFromToken(r *http.Request) (uint, error) {
+	return h.extractUserIDForScope(r, "")
+}
+
+// extractUserIDForScope is extractUserIDFromToken for endpoints that also accept a purpose-scoped token.
+// An empty scope only accepts full account tokens.
+func (h *MultiUserWhatsAppHandler) extractUserIDForScope(r *http.Request, scope string) (uint, error) {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
 		return 0, fmt.Errorf("authorization header required")
@@ -40,7 +55,13 @@ func (h *MultiUserWhatsAppHandler) extractUserIDFromToken(r *http.Request) (uint
 		return 0, fmt.Errorf("invalid authorization header format")
 	}
 
-	claims, err := h.authService.ValidateToken(tokenString)
+	var claims *services.JWTClaims
+	var err error
+	if scope == "" {
+		claims, err = h.authService.ValidateToken(tokenString)
+	} else {
+		claims, err = h.authService.ValidateTokenForScope(tokenString, scope)
+	}
 	if err != nil {
 		return 0, fmt.Errorf("invalid token: %v", err)
 	}
@@ -67,7 +88,7 @@ func (h *MultiUserWhatsAppHandler) HandleQR(w http.ResponseWriter, r *http.Reque
 	}
 
 	// Extract user ID from token
-	userID, err := h.extractUserIDFromToken(r)
+	userID, err := h.extractUserIDForScope(r, services.ScopeWAQR)
 	if err != nil {
 		response := map[string]interface{}{
 			"error": err.Error(),
@@ -86,9 +107,12 @@ func (h *MultiUserWhatsAppHandler) HandleQR(w http.ResponseWriter, r *http.Reque
 	}
 
 	// Get QR code for user
-	qrCode, err := h.waManager.GetQRCode(userID)
+	qrCode, qrExpiresAt, err := h.waManager.GetQRCodeWithExpiry(userID)
+	if writeStoreUpgradeError(w, userID, err) {
+		return
+	}
 	if err != nil {
-		log.Printf("ERROR: User %d - Failed to get QR code: %v", userID, err)
+		logging.FromContext(r.Context()).Error("Failed to get QR code", "user_id", userID, "error", err)
 		response := map[string]interface{}{
 			"error": "Failed to get QR code",
 			"success": false,
@@ -116,7 +140,10 @@ func (h *MultiUserWhatsAppHandler) HandleQR(w http.ResponseWriter, r *http.Reque
 	}
 
 	// Return QR code untuk user
-	json.NewEncoder(w).Encode(map[string]string{"qr": qrCode})
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"qr":            qrCode,
+		"qr_expires_at": qrExpiresAt.Format(time.RFC3339),
+	})
 }
 
 // HandleStatus returns status for specific user
@@ -137,7 +164,7 @@ func (h *MultiUserWhatsAppHandler) HandleStatus(w http.ResponseWriter, r *http.R
 	}
 
 	// Extract user ID from token
-	userID, err := h.extractUserIDFromToken(r)
+	userID, err := h.extractUserIDForScope(r, services.ScopeWAQR)
 	if err != nil {
 		response := map[string]interface{}{
 			"error": err.Error(),
@@ -156,19 +183,25 @@ func (h *MultiUserWhatsAppHandler) HandleStatus(w http.ResponseWriter, r *http.R
 
 	status := h.waManager.IsReady(userID)
 	waStatus, err := h.waManager.GetStatus(userID)
-	if err != nil {
+	if storeStatus, ok := storeUpgradeStatus(err); ok {
+		waStatus = storeStatus
+	} else if err != nil {
 		waStatus = "disconnected"
 	}
 
 	// Check if analysis is ready
 	analysisReady := h.waManager.IsAnalysisReady(userID)
+	warmup, analysisAllowed := h.waManager.GetWarmup(userID)
 
 	response := map[string]interface{}{
-		"ready":           status,
-		"whatsapp_status": waStatus,
-		"analysis_ready":  analysisReady,
-		"user_id":         userID,
-		"timestamp":       time.Now().Format(time.RFC3339),
+		"ready":            status,
+		"whatsapp_status":  waStatus,
+		"analysis_ready":   analysisReady,
+		"warmup":           warmup,
+		"analysis_allowed": analysisAllowed,
+		"restriction":      h.waManager.GetRestriction(userID),
+		"user_id":          userID,
+		"timestamp":        time.Now().Format(time.RFC3339),
 	}
 
 	// If WhatsApp is connected, check for phone number mismatch
@@ -177,43 +210,10 @@ func (h *MultiUserWhatsAppHandler) HandleStatus(w http.ResponseWriter, r *http.R
 		if client != nil && client.Store.ID != nil {
 			whatsappPhoneNumber := client.Store.ID.User
 			if whatsappPhoneNumber != "" {
-				log.Printf("DEBUG: User %d - Checking phone number %s in status endpoint", userID, whatsappPhoneNumber)
+				logging.FromContext(r.Context()).Debug(fmt.Sprintf("Checking phone number %s in status endpoint", whatsappPhoneNumber), "user_id", userID)
 				
-				// Check payment for this phone number
-				db := database.GetDB()
-				if db != nil {
-					paymentService := services.NewPaymentService(db)
-					if paymentService != nil {
-						hasPaidForPhone, err := paymentService.CheckIfUserPaidForPhone(int(userID), whatsappPhoneNumber)
-						if err != nil {
-							log.Printf("ERROR: User %d - Failed to check payment for phone %s in status: %v", userID, whatsappPhoneNumber, err)
-						} else if !hasPaidForPhone {
-							// Check if user has any paid transactions for other phone numbers
-							hasAnyPaidTransaction, err := paymentService.CheckIfUserHasAnyPaidTransaction(int(userID))
-							if err != nil {
-								log.Printf("ERROR: User %d - Failed to check if user has any paid transactions in status: %v", userID, err)
-								hasAnyPaidTransaction = false
-							}
-
-							if hasAnyPaidTransaction {
-								// User has paid for different phone number
-								response["phone_mismatch"] = map[string]interface{}{
-									"error_type":    "wrong_phone_number",
-									"scanned_phone": whatsappPhoneNumber,
-									"message":       fmt.Sprintf("Anda sudah membayar untuk nomor lain, tapi mencoba scan nomor %s. Silakan bayar untuk nomor ini atau scan nomor yang sudah dibayar.", whatsappPhoneNumber),
-								}
-								log.Printf("DEBUG: User %d - Phone number mismatch detected in status: %s", userID, whatsappPhoneNumber)
-							} else {
-								// User has no paid transactions at all
-								response["phone_mismatch"] = map[string]interface{}{
-									"error_type":   "no_payment",
-									"phone_number": whatsappPhoneNumber,
-									"message":      fmt.Sprintf("Pembayaran diperlukan untuk nomor %s. Silakan lakukan pembayaran terlebih dahulu.", whatsappPhoneNumber),
-								}
-								log.Printf("DEBUG: User %d - No payment detected in status for phone: %s", userID, whatsappPhoneNumber)
-							}
-						}
-					}
+				if requirement := h.paymentRequirement(r.Context(), userID, whatsappPhoneNumber); requirement != nil {
+					response["phone_mismatch"] = requirement
 				}
 			}
 		}
@@ -240,6 +240,146 @@ func (h *MultiUserWhatsAppHandler) HandleAnalyze(w http.ResponseWriter, r *http.
 		return
 	}
 
+	userID, session, ok := h.prepareAnalysis(w, r)
+	if !ok {
+		return
+	}
+
+	// Analyses share a bounded number of global slots; answer 429 with an estimate when saturated
+	release, err := analysisSlots.Acquire(r.Context(), true)
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Debug("No analysis slot available", "user_id", userID, "error", err)
+		writeAnalysisBusy(w, userID, err, h.waManager.jobs.Queued())
+		return
+	}
+	defer release()
+
+	// Use the SAME analysis method as single-user
+	// ?force=true stores a new result even when it duplicates one stored moments before
+	analysisResult, err := session.Analyze(r.URL.Query().Get("force") == "true")
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Analysis failed", "user_id", userID, "error", err)
+		response := map[string]interface{}{
+			"error":   err.Error(),
+			"user_id": userID,
+			"status": map[string]interface{}{
+				"whatsapp_ready": true,
+				"timestamp":      time.Now().Format(time.RFC3339),
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	logging.FromContext(r.Context()).Debug("Analysis completed successfully", "user_id", userID)
+
+	// Return analysis result
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Analysis completed successfully",
+		"user_id": userID,
+		"result":  analysisResponse(r, &analysisResult),
+		"cached":  false,
+		"status": map[string]interface{}{
+			"whatsapp_ready": true,
+			"timestamp":      time.Now().Format(time.RFC3339),
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleAnalyzeAsync queues an analysis job (POST /api/wa/analyze) and returns its id right away;
+// poll GET /api/wa/analyze/jobs/{id} for progress. A cached result is returned directly as in GET.
+func (h *MultiUserWhatsAppHandler) HandleAnalyzeAsync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, _, ok := h.prepareAnalysis(w, r)
+	if !ok {
+		return
+	}
+
+	job, created, err := h.waManager.jobs.Enqueue(r.Context(), userID, r.URL.Query().Get("force") == "true")
+	if errors.Is(err, ErrAnalysisBusy) {
+		logging.FromContext(r.Context()).Debug("Analysis job queue is full", "user_id", userID)
+		writeAnalysisBusy(w, userID, err, h.waManager.jobs.Queued())
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to queue analysis job", "user_id", userID, "error", err)
+		http.Error(w, "Failed to queue analysis", http.StatusInternalServerError)
+		return
+	}
+
+	message := "Analysis queued"
+	if !created {
+		message = "Analysis already in progress"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"message":    message,
+		"user_id":    userID,
+		"job_id":     job.JobID,
+		"job":        analysisJobView(job),
+		"status_url": "/api/wa/analyze/jobs/" + job.JobID,
+		"queued":     job.Status == models.AnalysisJobQueued,
+		// Rough estimate from the jobs ahead and recent analysis durations
+		"estimated_wait_seconds": analysisSlots.estimatedWaitSeconds(h.waManager.jobs.Queued() - 1),
+	})
+}
+
+// analysisResponse is the result as returned to its owner, limited to the request's ?fields=
+// (validated before the analysis starts)
+func analysisResponse(r *http.Request, result *models.AnalysisResult) interface{} {
+	fields, _ := models.ParseAnalysisFields(r.URL.Query().Get("fields"))
+	return models.NewAnalysisResponse(result).Sparse(fields)
+}
+
+// HandleAnalyzeJob returns status, progress and partial or final results of an analysis job
+func (h *MultiUserWhatsAppHandler) HandleAnalyzeJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := h.extractUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	job, err := h.waManager.repos.Jobs.FindForUser(r.Context(), mux.Vars(r)["id"], userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load job", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"job":     analysisJobView(job),
+	})
+}
+
+// prepareAnalysis runs the checks shared by the sync and async analyze endpoints (token, client,
+// payment, cache, readiness, warm-up). It writes the response and returns ok=false when the
+// request is already answered, including with a cached result.
+func (h *MultiUserWhatsAppHandler) prepareAnalysis(w http.ResponseWriter, r *http.Request) (uint, *UserWhatsAppSession, bool) {
 	// Extract user ID from token
 	userID, err := h.extractUserIDFromToken(r)
 	if err != nil {
@@ -255,15 +395,26 @@ func (h *MultiUserWhatsAppHandler) HandleAnalyze(w http.ResponseWriter, r *http.
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusUnauthorized)
 		json.NewEncoder(w).Encode(response)
-		return
+		return 0, nil, false
 	}
 
-	log.Printf("DEBUG: User %d - HandleAnalyze called - starting analysis... (VERSION: FIXED) - REQUEST ID: %d", userID, time.Now().UnixNano())
+	logging.FromContext(r.Context()).Debug("HandleAnalyze called - starting analysis...", "user_id", userID)
+
+	// ?fields= selects the result fields returned, checked before any work is done
+	if _, err := models.ParseAnalysisFields(r.URL.Query().Get("fields")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return 0, nil, false
+	}
+
+	// A new major ToS/privacy version must be accepted before analyzing
+	if h.consentRequired(w, userID) {
+		return 0, nil, false
+	}
 
 	// Get WhatsApp phone number from client
 	client := h.waManager.GetClient(userID)
 	if client == nil || client.Store.ID == nil {
-		log.Printf("ERROR: User %d - WhatsApp client not available for phone number check", userID)
+		logging.FromContext(r.Context()).Error("WhatsApp client not available for phone number check", "user_id", userID)
 		response := map[string]interface{}{
 			"error": "WhatsApp client not available",
 			"success": false,
@@ -277,13 +428,13 @@ func (h *MultiUserWhatsAppHandler) HandleAnalyze(w http.ResponseWriter, r *http.
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusServiceUnavailable)
 		json.NewEncoder(w).Encode(response)
-		return
+		return 0, nil, false
 	}
 
 	// Extract phone number from WhatsApp client
 	whatsappPhoneNumber := client.Store.ID.User
 	if whatsappPhoneNumber == "" {
-		log.Printf("ERROR: User %d - Could not extract phone number from WhatsApp client", userID)
+		logging.FromContext(r.Context()).Error("Could not extract phone number from WhatsApp client", "user_id", userID)
 		response := map[string]interface{}{
 			"error": "Could not extract phone number from WhatsApp",
 			"success": false,
@@ -297,60 +448,36 @@ func (h *MultiUserWhatsAppHandler) HandleAnalyze(w http.ResponseWriter, r *http.
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(response)
-		return
+		return 0, nil, false
 	}
 
-	log.Printf("DEBUG: User %d - WhatsApp phone number: %s", userID, whatsappPhoneNumber)
+	logging.FromContext(r.Context()).Debug(fmt.Sprintf("WhatsApp phone number: %s", whatsappPhoneNumber), "user_id", userID)
 
 	// Enforce payment: user must have PAID transaction for this specific phone number
-	db := database.GetDB()
-	if db == nil {
-		log.Printf("ERROR: User %d - Database connection is nil", userID)
-		response := map[string]interface{}{
-			"error": "Database not initialized",
-			"success": false,
-			"user_id": userID,
-			"status": map[string]interface{}{
-				"whatsapp_ready": false,
-				"database_ready": false,
-				"timestamp": time.Now().Format(time.RFC3339),
-			},
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(response)
-		return
-	}
-	log.Printf("DEBUG: User %d - Database connection obtained successfully", userID)
-
-	// Initialize PaymentService with proper database connection
-	log.Printf("DEBUG: User %d - Initializing PaymentService with database connection", userID)
-	paymentService := services.NewPaymentService(db)
-	if paymentService == nil {
-		log.Printf("ERROR: User %d - Failed to initialize PaymentService", userID)
-		response := map[string]interface{}{
-			"error": "Failed to initialize payment service",
-			"success": false,
-			"user_id": userID,
-			"status": map[string]interface{}{
-				"whatsapp_ready": false,
-				"payment_service_ready": false,
-				"timestamp": time.Now().Format(time.RFC3339),
-			},
+	entitlement, err := services.NewEntitlementService(h.repos).WithContext(r.Context()).Check(userID, whatsappPhoneNumber)
+	if err != nil && database.IsDegraded() {
+		// Payment can't be verified while the database is down; a result cached in this
+		// session was already paid for, so it can still be served read-only
+		if cachedResult, exists := h.waManager.GetCachedAnalysis(userID); exists {
+			logging.FromContext(r.Context()).Debug("Database degraded, serving cached analysis", "user_id", userID)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success":  true,
+				"message":  "Cached analysis result (read-only mode)",
+				"user_id":  userID,
+				"result":   analysisResponse(r, cachedResult),
+				"cached":   true,
+				"degraded": true,
+				"status": map[string]interface{}{
+					"whatsapp_ready": true,
+					"timestamp":      time.Now().Format(time.RFC3339),
+				},
+			})
+			return 0, nil, false
 		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(response)
-		return
 	}
-	log.Printf("DEBUG: User %d - PaymentService initialized successfully", userID)
-
-	// Debug: PaymentService initialized, proceeding with payment check
-	log.Printf("DEBUG: User %d - PaymentService initialized, proceeding with payment check", userID)
-
-	hasPaidForPhone, err := paymentService.CheckIfUserPaidForPhone(int(userID), whatsappPhoneNumber)
 	if err != nil {
-		log.Printf("ERROR: User %d - Failed to check payment for phone %s: %v", userID, whatsappPhoneNumber, err)
+		logging.FromContext(r.Context()).Error(fmt.Sprintf("Failed to check payment for phone %s", whatsappPhoneNumber), "user_id", userID, "error", err)
 		response := map[string]interface{}{
 			"error": "Failed to verify payment status",
 			"success": false,
@@ -364,58 +491,55 @@ func (h *MultiUserWhatsAppHandler) HandleAnalyze(w http.ResponseWriter, r *http.
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(response)
-		return
+		return 0, nil, false
 	}
 
-	if !hasPaidForPhone {
-		log.Printf("DEBUG: User %d - No payment found for phone number %s", userID, whatsappPhoneNumber)
+	if !entitlement.Entitled {
+		logging.FromContext(r.Context()).Debug(fmt.Sprintf("No payment found for phone number %s", whatsappPhoneNumber), "user_id", userID)
 
-		// Check if user has any paid transactions for other phone numbers
-		hasAnyPaidTransaction, err := paymentService.CheckIfUserHasAnyPaidTransaction(int(userID))
-		if err != nil {
-			log.Printf("ERROR: User %d - Failed to check if user has any paid transactions: %v", userID, err)
-			// If we can't check, assume no payment to be safe
-			hasAnyPaidTransaction = false
-		}
+		// Ready-to-use checkout payload so the frontend can jump straight into payment
+		bootstrap := h.paymentBootstrap(r.Context(), userID, whatsappPhoneNumber)
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusPaymentRequired)
 
-		if hasAnyPaidTransaction {
+		if entitlement.Reason == services.EntitlementWrongPhoneNumber {
 			// User has paid for different phone number
 			json.NewEncoder(w).Encode(map[string]interface{}{
 				"error":         "Payment required for different phone number",
 				"success":       false,
 				"user_id":       userID,
 				"scanned_phone": whatsappPhoneNumber,
-				"message":       fmt.Sprintf("Anda sudah membayar untuk nomor lain, tapi mencoba scan nomor %s. Silakan bayar untuk nomor ini atau scan nomor yang sudah dibayar.", whatsappPhoneNumber),
-				"error_type":    "wrong_phone_number",
+				"message":       entitlement.Message(),
+				"error_type":    services.EntitlementWrongPhoneNumber,
+				"payment":       bootstrap,
 			})
 		} else {
-			// User has no paid transactions at all
+			// User has no paid transactions at all, or used up this month's subscription scans
 			json.NewEncoder(w).Encode(map[string]interface{}{
 				"error":        "Payment required",
 				"success":      false,
 				"user_id":      userID,
 				"phone_number": whatsappPhoneNumber,
-				"message":      fmt.Sprintf("Pembayaran diperlukan untuk nomor %s. Silakan lakukan pembayaran terlebih dahulu.", whatsappPhoneNumber),
-				"error_type":   "no_payment",
+				"message":      entitlement.Message(),
+				"error_type":   entitlement.Reason,
+				"payment":      bootstrap,
 			})
 		}
-		log.Printf("DEBUG: User %d - Payment validation failed, returning error 402 - REQUEST ID: %d", userID, time.Now().UnixNano())
-		return
+		logging.FromContext(r.Context()).Debug("Payment validation failed, returning error 402", "user_id", userID)
+		return 0, nil, false
 	}
 
-	log.Printf("DEBUG: User %d - Payment verified for phone number %s - REQUEST ID: %d", userID, whatsappPhoneNumber, time.Now().UnixNano())
+	logging.FromContext(r.Context()).Debug(fmt.Sprintf("Payment verified for phone number %s", whatsappPhoneNumber), "user_id", userID)
 
 	// Check if we have cached analysis result first (but only after payment validation)
 	if cachedResult, exists := h.waManager.GetCachedAnalysis(userID); exists {
-		log.Printf("DEBUG: User %d - Payment verified, returning cached analysis result", userID)
+		logging.FromContext(r.Context()).Debug("Payment verified, returning cached analysis result", "user_id", userID)
 		response := map[string]interface{}{
 			"success": true,
 			"message": "Cached analysis result",
 			"user_id": userID,
-			"result":  cachedResult,
+			"result":  analysisResponse(r, cachedResult),
 			"cached":  true,
 			"status": map[string]interface{}{
 				"whatsapp_ready": true,
@@ -424,12 +548,34 @@ func (h *MultiUserWhatsAppHandler) HandleAnalyze(w http.ResponseWriter, r *http.
 		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(response)
-		return
+		return 0, nil, false
+	}
+
+	// A new analysis counts against the daily scan limit of the user's tier
+	if err := services.NewEntitlementService(h.repos).WithContext(r.Context()).CheckDailyScans(userID); err != nil {
+		status := http.StatusInternalServerError
+		response := map[string]interface{}{
+			"error":   "Failed to check daily scan limit",
+			"success": false,
+			"user_id": userID,
+		}
+		if errors.Is(err, services.ErrDailyScanLimit) {
+			status = http.StatusTooManyRequests
+			response["error"] = err.Error()
+			response["message"] = services.DailyScanLimitMessage()
+			response["error_type"] = "daily_scan_limit"
+		} else {
+			logging.FromContext(r.Context()).Error("Failed to check daily scan limit", "user_id", userID, "error", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(response)
+		return 0, nil, false
 	}
 
 	// Check if WhatsApp is ready for user
 	if !h.waManager.IsReady(userID) {
-		log.Printf("DEBUG: User %d - WhatsApp not ready, cannot analyze", userID)
+		logging.FromContext(r.Context()).Debug("WhatsApp not ready, cannot analyze", "user_id", userID)
 		response := map[string]interface{}{
 			"error": "WhatsApp not logged in. Please scan QR code first",
 			"status": map[string]interface{}{
@@ -441,14 +587,14 @@ func (h *MultiUserWhatsAppHandler) HandleAnalyze(w http.ResponseWriter, r *http.
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusServiceUnavailable)
 		json.NewEncoder(w).Encode(response)
-		return
+		return 0, nil, false
 	}
 
 	// Client already validated above for phone number check
 
 	// Additional client validation
 	if !client.IsConnected() {
-		log.Printf("DEBUG: User %d - WhatsApp client not connected", userID)
+		logging.FromContext(r.Context()).Debug("WhatsApp client not connected", "user_id", userID)
 		response := map[string]interface{}{
 			"error": "WhatsApp client not connected. Please reconnect and try again",
 			"status": map[string]interface{}{
@@ -460,33 +606,33 @@ func (h *MultiUserWhatsAppHandler) HandleAnalyze(w http.ResponseWriter, r *http.
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusServiceUnavailable)
 		json.NewEncoder(w).Encode(response)
-		return
+		return 0, nil, false
 	}
 
-	log.Printf("DEBUG: User %d - Client validation passed, starting analysis...", userID)
-
-	// Get session and perform analysis using the SAME logic as single-user
-	session, err := h.waManager.GetOrCreateSession(userID)
-	if err != nil {
-		log.Printf("ERROR: User %d - Failed to get session: %v", userID, err)
+	// Contacts/groups may still be syncing right after pairing
+	if warmup, allowed := h.waManager.GetWarmup(userID); !allowed && r.URL.Query().Get("override_warmup") != "true" {
+		logging.FromContext(r.Context()).Debug(fmt.Sprintf("Warm-up at %d%% (%s), refusing analysis", warmup.Progress, warmup.Phase), "user_id", userID)
 		response := map[string]interface{}{
-			"error":   err.Error(),
-			"user_id": userID,
-			"status": map[string]interface{}{
-				"whatsapp_ready": true,
-				"timestamp":      time.Now().Format(time.RFC3339),
-			},
+			"error":        "WhatsApp data is still syncing",
+			"error_type":   "warming_up",
+			"success":      false,
+			"user_id":      userID,
+			"warmup":       warmup,
+			"min_progress": warmupMinProgress(),
+			"message":      "Kontak dan grup WhatsApp kamu masih disinkronkan. Tunggu sebentar atau lanjutkan dengan override_warmup=true.",
 		}
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(http.StatusConflict)
 		json.NewEncoder(w).Encode(response)
-		return
+		return 0, nil, false
 	}
 
-	// Use the SAME analysis method as single-user
-	analysisResult, err := session.Analyze()
+	logging.FromContext(r.Context()).Debug("Client validation passed, starting analysis...", "user_id", userID)
+
+	// Get session and perform analysis using the SAME logic as single-user
+	session, err := h.waManager.GetOrCreateSession(userID)
 	if err != nil {
-		log.Printf("ERROR: User %d - Analysis failed: %v", userID, err)
+		logging.FromContext(r.Context()).Error("Failed to get session", "user_id", userID, "error", err)
 		response := map[string]interface{}{
 			"error":   err.Error(),
 			"user_id": userID,
@@ -498,26 +644,10 @@ func (h *MultiUserWhatsAppHandler) HandleAnalyze(w http.ResponseWriter, r *http.
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(response)
-		return
-	}
-
-	log.Printf("DEBUG: User %d - Analysis completed successfully", userID)
-
-	// Return analysis result
-	response := map[string]interface{}{
-		"success": true,
-		"message": "Analysis completed successfully",
-		"user_id": userID,
-		"result":  analysisResult,
-		"cached":  false,
-		"status": map[string]interface{}{
-			"whatsapp_ready": true,
-			"timestamp":      time.Now().Format(time.RFC3339),
-		},
+		return 0, nil, false
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	return userID, session, true
 }
 
 // HandleLogout logs out WhatsApp for specific user
@@ -555,12 +685,12 @@ func (h *MultiUserWhatsAppHandler) HandleLogout(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	log.Printf("DEBUG: User %d - Logout request received", userID)
+	logging.FromContext(r.Context()).Debug("Logout request received", "user_id", userID)
 
 	// Check if user has an active session before logout
 	client := h.waManager.GetClient(userID)
 	if client == nil {
-		log.Printf("DEBUG: User %d - No active session found, logout successful", userID)
+		logging.FromContext(r.Context()).Debug("No active session found, logout successful", "user_id", userID)
 		response := map[string]interface{}{
 			"success": true,
 			"message": "No active session found",
@@ -573,7 +703,7 @@ func (h *MultiUserWhatsAppHandler) HandleLogout(w http.ResponseWriter, r *http.R
 
 	// Logout WhatsApp for user
 	if err := h.waManager.Logout(userID); err != nil {
-		log.Printf("ERROR: User %d - Failed to logout: %v", userID, err)
+		logging.FromContext(r.Context()).Error("Failed to logout", "user_id", userID, "error", err)
 		response := map[string]interface{}{
 			"error":   err.Error(),
 			"success": false,
@@ -585,7 +715,7 @@ func (h *MultiUserWhatsAppHandler) HandleLogout(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	log.Printf("DEBUG: User %d - Logout completed successfully", userID)
+	logging.FromContext(r.Context()).Debug("Logout completed successfully", "user_id", userID)
 
 	response := map[string]interface{}{
 		"success": true,
@@ -615,7 +745,7 @@ func (h *MultiUserWhatsAppHandler) HandleRefreshQR(w http.ResponseWriter, r *htt
 	}
 
 	// Extract user ID from token
-	userID, err := h.extractUserIDFromToken(r)
+	userID, err := h.extractUserIDForScope(r, services.ScopeWAQR)
 	if err != nil {
 		response := map[string]interface{}{
 			"error": err.Error(),
@@ -632,7 +762,7 @@ func (h *MultiUserWhatsAppHandler) HandleRefreshQR(w http.ResponseWriter, r *htt
 		return
 	}
 
-	log.Printf("DEBUG: User %d - Refresh QR request received", userID)
+	logging.FromContext(r.Context()).Debug("Refresh QR request received", "user_id", userID)
 
 	// TODO: Implement QR refresh logic
 	// For now, return success response
@@ -681,11 +811,14 @@ func (h *MultiUserWhatsAppHandler) HandleManualReconnect(w http.ResponseWriter,
 		return
 	}
 
-	log.Printf("DEBUG: User %d - Manual reconnect request received", userID)
+	logging.FromContext(r.Context()).Debug("Manual reconnect request received", "user_id", userID)
 
 	// Connect WhatsApp for user
 	if err := h.waManager.Connect(userID); err != nil {
-		log.Printf("ERROR: User %d - Failed to reconnect: %v", userID, err)
+		if writeStoreUpgradeError(w, userID, err) {
+			return
+		}
+		logging.FromContext(r.Context()).Error("Failed to reconnect", "user_id", userID, "error", err)
 		http.Error(w, "Failed to reconnect WhatsApp", http.StatusInternalServerError)
 		return
 	}
@@ -735,7 +868,16 @@ func (h *MultiUserWhatsAppHandler) HandleForceAnalysis(w http.ResponseWriter, r
 		return
 	}
 
-	log.Printf("DEBUG: User %d - Force analysis request received", userID)
+	logging.FromContext(r.Context()).Debug("Force analysis request received", "user_id", userID)
+
+	if _, err := models.ParseAnalysisFields(r.URL.Query().Get("fields")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if h.consentRequired(w, userID) {
+		return
+	}
 
 	// Check if WhatsApp is ready
 	if !h.waManager.IsReady(userID) {
@@ -776,7 +918,7 @@ func (h *MultiUserWhatsAppHandler) HandleForceAnalysis(w http.ResponseWriter, r
 	}
 
 	// Use the SAME analysis method as single-user
-	result, err := session.Analyze()
+	result, err := session.Analyze(r.URL.Query().Get("force") == "true")
 	if err != nil {
 		response := map[string]interface{}{
 			"error":   err.Error(),
@@ -791,7 +933,7 @@ func (h *MultiUserWhatsAppHandler) HandleForceAnalysis(w http.ResponseWriter, r
 	response := map[string]interface{}{
 		"success": true,
 		"message": "Analysis completed successfully",
-		"result":  result,
+		"result":  analysisResponse(r, &result),
 		"user_id": userID,
 	}
 
@@ -838,6 +980,9 @@ func (h *MultiUserWhatsAppHandler) HandleDebug(w http.ResponseWriter, r *http.Re
 	status := h.waManager.IsReady(userID)
 	waStatus, _ := h.waManager.GetStatus(userID)
 
+	// JIDs are masked unless an admin asks for the full value with ?reveal=true
+	reveal := r.URL.Query().Get("reveal") == "true" && h.isAdminRequest(r)
+
 	debugInfo := map[string]interface{}{
 		"user_id":         userID,
 		"client_exists":   client != nil,
@@ -849,7 +994,10 @@ func (h *MultiUserWhatsAppHandler) HandleDebug(w http.ResponseWriter, r *http.Re
 	if client != nil {
 		debugInfo["client_connected"] = client.IsConnected()
 		if client.Store.ID != nil {
-			debugInfo["client_id"] = client.Store.ID.String()
+			debugInfo["client_id"] = services.RedactPhone(client.Store.ID.String())
+			if reveal {
+				debugInfo["client_id"] = client.Store.ID.String()
+			}
 		}
 		// Add more detailed client info
 		debugInfo["client_store_exists"] = client.Store != nil
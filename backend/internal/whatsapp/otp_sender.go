@@ -0,0 +1,156 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"back_wa/internal/services"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/store/sqlstore"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// ErrOTPSenderNotReady is returned while the system number is disconnected
+var ErrOTPSenderNotReady = errors.New("WhatsApp OTP sender is not connected")
+
+const opSendOTP = "send_otp"
+
+// otpSender sends OTP codes from a dedicated system number, never from a user's session. It is
+// linked once with "link-otp-sender <phone>" and kept in its own sqlite store (WA_OTP_SENDER_DB).
+type otpSender struct {
+	client  *whatsmeow.Client
+	limiter *sessionLimiter
+}
+
+// otpSenderStore opens the system number's store, separate from the user session stores
+func otpSenderStore() (*sqlstore.Container, error) {
+	path := os.Getenv("WA_OTP_SENDER_DB")
+	if path == "" {
+		path = "whatsapp_session_otp_sender.db"
+	}
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=journal_mode=WAL&_pragma=synchronous=NORMAL", path)
+	return sqlstore.New(context.Background(), "sqlite", dsn, nil)
+}
+
+// StartOTPSender connects the linked system number and registers it as the WhatsApp OTP channel.
+// It does nothing unless WA_OTP_SENDER_ENABLED=true.
+func StartOTPSender() {
+	if os.Getenv("WA_OTP_SENDER_ENABLED") != "true" {
+		return
+	}
+
+	db, err := otpSenderStore()
+	if err != nil {
+		slog.Error("Failed to open the WhatsApp OTP sender store", "error", err)
+		return
+	}
+	deviceStore, err := db.GetFirstDevice(context.Background())
+	if err != nil {
+		slog.Error("Failed to load the WhatsApp OTP sender device", "error", err)
+		return
+	}
+	if deviceStore.ID == nil {
+		slog.Warn("WhatsApp OTP sender is not linked, run the server with: link-otp-sender <phone>")
+		return
+	}
+
+	client := whatsmeow.NewClient(deviceStore, nil)
+	client.AddEventHandler(func(evt interface{}) {
+		if _, ok := evt.(*events.LoggedOut); ok {
+			slog.Error("WhatsApp OTP sender was logged out, OTPs fall back to email until it is linked again")
+			services.RegisterOTPSender(services.OTPChannelWhatsApp, nil)
+		}
+	})
+	if err := client.Connect(); err != nil {
+		slog.Error("Failed to connect the WhatsApp OTP sender", "error", err)
+		return
+	}
+
+	services.RegisterOTPSender(services.OTPChannelWhatsApp, &otpSender{client: client, limiter: newSessionLimiter()})
+	slog.Info("WhatsApp OTP sender connected", "jid", deviceStore.ID.User)
+}
+
+// SendOTP sends the code as a text message to the recipient's WhatsApp number
+func (s *otpSender) SendOTP(ctx context.Context, to services.OTPRecipient, code string, expiryMinutes int) error {
+	phone := services.NormalizePhoneNumber(to.PhoneNumber)
+	if phone == "" {
+		return fmt.Errorf("no phone number")
+	}
+	if !s.client.IsConnected() || !s.client.IsLoggedIn() {
+		return ErrOTPSenderNotReady
+	}
+
+	text := services.OTPMessage(code, expiryMinutes)
+	return s.limiter.Do(ctx, opSendOTP, func() error {
+		_, err := s.client.SendMessage(ctx, types.NewJID(phone, types.DefaultUserServer), &waE2E.Message{Conversation: &text})
+		return err
+	})
+}
+
+// LinkOTPSender links the system number by pairing code: the code is written to out and must be
+// entered on the phone under Linked devices → Link with phone number before it expires
+func LinkOTPSender(ctx context.Context, phone string, out io.Writer) error {
+	phone, err := normalizePairPhone(phone)
+	if err != nil {
+		return err
+	}
+
+	db, err := otpSenderStore()
+	if err != nil {
+		return err
+	}
+	deviceStore, err := db.GetFirstDevice(ctx)
+	if err != nil {
+		return err
+	}
+	if deviceStore.ID != nil {
+		return ErrAlreadyPaired
+	}
+
+	client := whatsmeow.NewClient(deviceStore, nil)
+	paired := make(chan struct{}, 1)
+	client.AddEventHandler(func(evt interface{}) {
+		if _, ok := evt.(*events.PairSuccess); ok {
+			paired <- struct{}{}
+		}
+	})
+
+	// PairPhone is only accepted once the linking socket emitted its first QR code
+	qrChan, err := client.GetQRChannel(ctx)
+	if err != nil {
+		return err
+	}
+	if err := client.Connect(); err != nil {
+		return err
+	}
+	defer client.Disconnect()
+
+	select {
+	case <-qrChan:
+	case <-time.After(pairingReadyTimeout):
+		return ErrPairingNotReady
+	}
+	code, err := client.PairPhone(ctx, phone, true, whatsmeow.PairClientChrome, pairingClientName)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Enter this code on %s under Linked devices → Link with phone number: %s\n", phone, code)
+
+	select {
+	case <-paired:
+		fmt.Fprintln(out, "WhatsApp OTP sender linked, set WA_OTP_SENDER_ENABLED=true to use it")
+		return nil
+	case <-time.After(pairingCodeTTL):
+		return fmt.Errorf("pairing code expired")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
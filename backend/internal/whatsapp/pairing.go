@@ -0,0 +1,219 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/services"
+
+	"go.mau.fi/whatsmeow"
+)
+
+var (
+	ErrInvalidPairPhone = errors.New("invalid phone number for pairing")
+	ErrAlreadyPaired    = errors.New("WhatsApp is already connected")
+	// ErrPairingNotReady means the linking socket did not come up in time; the client may retry
+	ErrPairingNotReady = errors.New("WhatsApp pairing is not ready yet, try again in a few seconds")
+)
+
+const (
+	opPairPhone = "pair_phone"
+
+	// pairingCodeTTL is how long a code can be entered; whatsmeow keeps the linking
+	// socket (shared with the QR flow) open for about 160s after connecting
+	pairingCodeTTL = 150 * time.Second
+	// pairingReadyTimeout bounds the wait for the linking socket to emit its first QR event,
+	// PairPhone is rejected by WhatsApp before that
+	pairingReadyTimeout = 15 * time.Second
+	// shown to the user on the phone's "Link with phone number" notification
+	pairingClientName = "Chrome (Linux)"
+)
+
+// normalizePairPhone converts the number to the international form PairPhone expects
+func normalizePairPhone(phone string) (string, error) {
+	normalized := services.NormalizePhoneNumber(phone)
+	if len(normalized) < 8 || len(normalized) > 15 {
+		return "", ErrInvalidPairPhone
+	}
+	return normalized, nil
+}
+
+// clearPairingLocked forgets the pairing code. The caller must hold s.mu.
+func (s *UserWhatsAppSession) clearPairingLocked() {
+	s.PairingCode = ""
+	s.PairingPhone = ""
+	s.PairingExpiresAt = time.Time{}
+}
+
+// clearExpiredPairing drops the pairing code once it can no longer be entered
+func (s *UserWhatsAppSession) clearExpiredPairing(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.PairingCode == "" || now.Before(s.PairingExpiresAt) {
+		return false
+	}
+	s.clearPairingLocked()
+	return true
+}
+
+// RequestPairingCode links the user's session by phone number instead of a QR scan. The
+// returned 8-character code is entered on the phone under Linked devices → Link with
+// phone number. A still valid code for the same number is returned again.
+func (m *MultiUserWhatsAppManager) RequestPairingCode(ctx context.Context, userID uint, phone string) (string, time.Time, error) {
+	phone, err := normalizePairPhone(phone)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	session, err := m.GetOrCreateSession(userID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	session.clearExpiredPairing(time.Now())
+
+	session.mu.RLock()
+	ready := session.Ready
+	code, codePhone, expiresAt := session.PairingCode, session.PairingPhone, session.PairingExpiresAt
+	session.mu.RUnlock()
+	if ready {
+		return "", time.Time{}, ErrAlreadyPaired
+	}
+	if code != "" && codePhone == phone {
+		return code, expiresAt, nil
+	}
+
+	// Pairing rides on the same linking socket as the QR flow
+	if err := session.connect(); err != nil {
+		return "", time.Time{}, err
+	}
+	if !session.waitForLinkingSocket(ctx) {
+		return "", time.Time{}, ErrPairingNotReady
+	}
+
+	client := session.GetClient()
+	if client == nil {
+		return "", time.Time{}, ErrPairingNotReady
+	}
+	err = session.limiter.Do(ctx, opPairPhone, func() error {
+		var err error
+		session.waCalls.Add(1)
+		code, err = client.PairPhone(ctx, phone, true, whatsmeow.PairClientChrome, pairingClientName)
+		return err
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	session.mu.Lock()
+	session.PairingCode = code
+	session.PairingPhone = phone
+	session.PairingExpiresAt = time.Now().Add(pairingCodeTTL)
+	expiresAt = session.PairingExpiresAt
+	session.mu.Unlock()
+
+	logging.FromContext(ctx).Debug(fmt.Sprintf("Pairing code issued for %s (expires in %s)", phone, pairingCodeTTL), "user_id", userID)
+	return code, expiresAt, nil
+}
+
+// waitForLinkingSocket waits until the session shows a QR code, which is when whatsmeow
+// accepts PairPhone. Returns false on timeout or when the session connected meanwhile.
+func (s *UserWhatsAppSession) waitForLinkingSocket(ctx context.Context) bool {
+	deadline := time.Now().Add(pairingReadyTimeout)
+	for {
+		s.mu.RLock()
+		qrReady, ready := s.QRCode != "", s.Ready
+		s.mu.RUnlock()
+		if qrReady || ready {
+			return qrReady && !ready
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// GetPairing returns the pending pairing code and its expiry, empty when there is none
+func (m *MultiUserWhatsAppManager) GetPairing(userID uint) (string, time.Time) {
+	session, err := m.GetOrCreateSession(userID)
+	if err != nil {
+		return "", time.Time{}
+	}
+	session.clearExpiredPairing(time.Now())
+
+	session.mu.RLock()
+	defer session.mu.RUnlock()
+	return session.PairingCode, session.PairingExpiresAt
+}
+
+// HandlePair handles POST /api/wa/pair: issues a pairing code for {"phone_number": "..."}
+func (h *MultiUserWhatsAppHandler) HandlePair(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Method not allowed"})
+		return
+	}
+
+	userID, err := h.extractUserIDForScope(r, services.ScopeWAQR)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	var req struct {
+		PhoneNumber string `json:"phone_number"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.PhoneNumber) == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "phone_number is required"})
+		return
+	}
+
+	code, expiresAt, err := h.waManager.RequestPairingCode(r.Context(), userID, req.PhoneNumber)
+	if writeStoreUpgradeError(w, userID, err) {
+		return
+	}
+	if err != nil {
+		status, message := http.StatusInternalServerError, err.Error()
+		switch {
+		case errors.Is(err, ErrInvalidPairPhone):
+			status = http.StatusBadRequest
+		case errors.Is(err, ErrAlreadyPaired):
+			status = http.StatusConflict
+		case errors.Is(err, ErrAccountBanned):
+			status = http.StatusForbidden
+		case errors.Is(err, ErrWhatsAppRateLimited):
+			status = http.StatusTooManyRequests
+		case errors.Is(err, ErrPairingNotReady):
+			status = http.StatusServiceUnavailable
+			w.Header().Set("Retry-After", "5")
+		default:
+			logging.FromContext(r.Context()).Error("Failed to request pairing code", "user_id", userID, "error", err)
+			message = "Failed to request pairing code"
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": message})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":      true,
+		"pairing_code": code,
+		"expires_at":   expiresAt.Format(time.RFC3339),
+		"user_id":      userID,
+	})
+}
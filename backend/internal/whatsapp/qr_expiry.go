@@ -0,0 +1,57 @@
+package whatsapp
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// defaultQRTimeout is used when whatsmeow does not report how long a code stays valid
+const defaultQRTimeout = 20 * time.Second
+
+// qrJanitorInterval is how often expired QR codes are purged from memory and the database
+const qrJanitorInterval = 30 * time.Second
+
+// clearExpiredQR drops the QR image once its pairing window has passed
+func (s *UserWhatsAppSession) clearExpiredQR(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.QRCode == "" || s.QRExpiresAt.IsZero() || now.Before(s.QRExpiresAt) {
+		return false
+	}
+	s.QRCode = ""
+	s.QRExpiresAt = time.Time{}
+	return true
+}
+
+// runQRJanitor periodically frees expired QR codes (large base64 PNGs) held by idle sessions
+// and clears stale expiry markers left in whatsapp_sessions.
+func (m *MultiUserWhatsAppManager) runQRJanitor() {
+	ticker := time.NewTicker(qrJanitorInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+
+		m.mu.RLock()
+		sessions := make([]*UserWhatsAppSession, 0, len(m.userSessions))
+		for _, session := range m.userSessions {
+			sessions = append(sessions, session)
+		}
+		m.mu.RUnlock()
+
+		for _, session := range sessions {
+			if session.clearExpiredQR(now) {
+				slog.Debug("Expired QR code cleared", "user_id", session.UserID)
+			}
+			if session.clearExpiredPairing(now) {
+				slog.Debug("Expired pairing code cleared", "user_id", session.UserID)
+			}
+		}
+
+		if err := m.repos.Sessions.ClearExpiredQR(context.Background(), now); err != nil {
+			slog.Warn("Failed to clear expired QR markers", "error", err)
+		}
+	}
+}
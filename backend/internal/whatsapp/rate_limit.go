@@ -0,0 +1,219 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// ErrWhatsAppRateLimited is returned when too many whatsmeow calls are already queued for a session
+var ErrWhatsAppRateLimited = errors.New("too many WhatsApp operations queued for this session")
+
+// Operation names used for limiter metrics
+const (
+	opGetJoinedGroups = "get_joined_groups"
+)
+
+// sessionLimiter spaces out whatsmeow calls of one session (token bucket) so bulk
+// operations don't trip WhatsApp's own rate limits and get the account flagged.
+// Calls over the burst wait in a bounded queue instead of failing.
+type sessionLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration // one token per interval
+	burst    int
+	maxQueue int
+	next     time.Time // virtual time of the next free token
+	queued   int
+}
+
+func newSessionLimiter() *sessionLimiter {
+	perMinute := envInt("WA_RATE_LIMIT_PER_MINUTE", 30)
+	if perMinute <= 0 {
+		perMinute = 30
+	}
+	burst := envInt("WA_RATE_LIMIT_BURST", 5)
+	if burst <= 0 {
+		burst = 1
+	}
+	return &sessionLimiter{
+		interval: time.Minute / time.Duration(perMinute),
+		burst:    burst,
+		maxQueue: envInt("WA_RATE_LIMIT_MAX_QUEUE", 20),
+	}
+}
+
+// Do runs fn once a token is available, waiting in the queue if needed
+func (l *sessionLimiter) Do(ctx context.Context, op string, fn func() error) error {
+	now := time.Now()
+
+	l.mu.Lock()
+	// Unused tokens accumulate up to the burst size
+	if floor := now.Add(-time.Duration(l.burst-1) * l.interval); l.next.Before(floor) {
+		l.next = floor
+	}
+	wait := l.next.Sub(now)
+	if wait > 0 && l.queued >= l.maxQueue {
+		l.mu.Unlock()
+		waMetrics.rejected(op)
+		return ErrWhatsAppRateLimited
+	}
+	l.next = l.next.Add(l.interval)
+	if wait > 0 {
+		l.queued++
+	}
+	l.mu.Unlock()
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			l.mu.Lock()
+			l.queued--
+			l.mu.Unlock()
+			return ctx.Err()
+		}
+		l.mu.Lock()
+		l.queued--
+		l.mu.Unlock()
+	}
+
+	err := fn()
+	waMetrics.observe(op, wait, err)
+	return err
+}
+
+// Queued returns the number of calls currently waiting for a token
+func (l *sessionLimiter) Queued() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.queued
+}
+
+// WhatsAppOpStats are the limiter counters for one whatsmeow operation
+type WhatsAppOpStats struct {
+	Calls     int64 `json:"calls"`
+	Throttled int64 `json:"throttled"` // calls that had to wait for a token
+	Rejected  int64 `json:"rejected"`  // calls refused because the queue was full
+	Errors    int64 `json:"errors"`
+	TotalWait int64 `json:"total_wait_ms"`
+	MaxWait   int64 `json:"max_wait_ms"`
+}
+
+type whatsAppOpMetrics struct {
+	mu  sync.Mutex
+	ops map[string]*WhatsAppOpStats
+}
+
+var waMetrics = &whatsAppOpMetrics{ops: make(map[string]*WhatsAppOpStats)}
+
+func (m *whatsAppOpMetrics) stats(op string) *WhatsAppOpStats {
+	stats, ok := m.ops[op]
+	if !ok {
+		stats = &WhatsAppOpStats{}
+		m.ops[op] = stats
+	}
+	return stats
+}
+
+func (m *whatsAppOpMetrics) observe(op string, wait time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := m.stats(op)
+	stats.Calls++
+	if wait > 0 {
+		stats.Throttled++
+		stats.TotalWait += wait.Milliseconds()
+		if wait.Milliseconds() > stats.MaxWait {
+			stats.MaxWait = wait.Milliseconds()
+		}
+	}
+	if err != nil {
+		stats.Errors++
+	}
+}
+
+func (m *whatsAppOpMetrics) rejected(op string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats(op).Rejected++
+}
+
+func (m *whatsAppOpMetrics) snapshot() map[string]WhatsAppOpStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]WhatsAppOpStats, len(m.ops))
+	for op, stats := range m.ops {
+		out[op] = *stats
+	}
+	return out
+}
+
+// joinedGroups is client.GetJoinedGroups behind the session limiter
+func (s *UserWhatsAppSession) joinedGroups() ([]*types.GroupInfo, error) {
+	client := s.GetClient()
+	if client == nil {
+		return nil, errors.New("WhatsApp client not available")
+	}
+	var groups []*types.GroupInfo
+	err := s.limiter.Do(context.Background(), opGetJoinedGroups, func() error {
+		var err error
+		s.waCalls.Add(1)
+		groups, err = client.GetJoinedGroups()
+		return err
+	})
+	return groups, err
+}
+
+// RateLimitQueues returns the number of queued whatsmeow calls per user with a non-empty queue
+func (m *MultiUserWhatsAppManager) RateLimitQueues() map[uint]int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	queues := make(map[uint]int)
+	for userID, session := range m.userSessions {
+		if session.limiter == nil {
+			continue
+		}
+		if queued := session.limiter.Queued(); queued > 0 {
+			queues[userID] = queued
+		}
+	}
+	return queues
+}
+
+// HandleRateLimitMetrics handles GET /api/admin/metrics/whatsapp (admin only)
+func (h *MultiUserWhatsAppHandler) HandleRateLimitMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"operations": waMetrics.snapshot(),
+		"queued":     h.waManager.RateLimitQueues(),
+		"analysis_jobs": map[string]interface{}{
+			"workers": analysisJobWorkers(),
+			"lanes":   h.waManager.jobs.Depth(),
+		},
+		"analysis_pool":  analysisSlots.Stats(),
+		"session_stores": SessionStoreStats(50),
+	})
+}
+
+// envInt reads an integer environment variable, falling back to def
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
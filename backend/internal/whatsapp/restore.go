@@ -0,0 +1,140 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+)
+
+// restoreLoadTimeout bounds loading the session rows to restore
+const restoreLoadTimeout = 10 * time.Second
+
+// restoreConnectedSessions reconnects, in the background, every session that was connected when the
+// previous process stopped, so users do not show as disconnected until they call an endpoint.
+// WA_RESTORE_SESSIONS=false disables it; WA_RESTORE_CONCURRENCY (default 4) caps parallel logins.
+func (m *MultiUserWhatsAppManager) restoreConnectedSessions() {
+	if os.Getenv("WA_RESTORE_SESSIONS") == "false" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), restoreLoadTimeout)
+	records, err := m.repos.Sessions.ListByStatus(ctx, "connected")
+	cancel()
+	if err != nil {
+		slog.Warn("Failed to load connected WhatsApp sessions to restore", "error", err)
+		return
+	}
+	if len(records) == 0 {
+		return
+	}
+
+	userIDs := make([]uint, 0, len(records))
+	for _, record := range records {
+		userIDs = append(userIDs, record.UserID)
+	}
+	restored := m.restoreSessions(userIDs)
+	slog.Debug(fmt.Sprintf("Restored %d of %d WhatsApp sessions", restored, len(records)))
+}
+
+// restoreSessions restores the given users' sessions with at most WA_RESTORE_CONCURRENCY
+// (default 4) parallel logins and returns how many were restored
+func (m *MultiUserWhatsAppManager) restoreSessions(userIDs []uint) int {
+	concurrency := envInt("WA_RESTORE_CONCURRENCY", 4)
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+	slog.Debug(fmt.Sprintf("Restoring %d connected WhatsApp sessions (concurrency %d)", len(userIDs), concurrency))
+
+	var (
+		wg       sync.WaitGroup
+		restored atomic.Int64
+		slots    = make(chan struct{}, concurrency)
+	)
+	for _, userID := range userIDs {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(userID uint) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if err := m.restoreSession(userID); err != nil {
+				slog.Warn("WhatsApp session not restored", "user_id", userID, "error", err)
+				return
+			}
+			restored.Add(1)
+		}(userID)
+	}
+	wg.Wait()
+	return int(restored.Load())
+}
+
+// restoreSession logs the user's stored device back in. Unlike Connect it never falls back to a
+// QR code: a session that cannot be restored is left disconnected for the user to scan again.
+func (m *MultiUserWhatsAppManager) restoreSession(userID uint) error {
+	session, err := m.GetOrCreateSession(userID)
+	if err != nil {
+		return err
+	}
+	defer clearHandoff(userID)
+	if err := session.restore(); err != nil {
+		_ = saveSessionRecord(session.sessions, &UserWhatsAppSession{UserID: userID, Status: "disconnected", LastActivity: time.Now()})
+		publishStatus(userID, "disconnected")
+		return err
+	}
+	return nil
+}
+
+// restore connects the session's stored device, if there is one
+func (s *UserWhatsAppSession) restore() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A request may have connected the session in the meantime
+	if s.Status == "connected" || s.Status == "scanning" || s.Status == "connecting" {
+		return nil
+	}
+	if s.bannedLocked() {
+		return ErrAccountBanned
+	}
+
+	deviceStore, err := s.SessionDB.GetFirstDevice(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to get device store: %v", err)
+	}
+	if deviceStore.ID == nil {
+		return fmt.Errorf("no linked device in the session store")
+	}
+
+	s.LastConnectAttempt = time.Now()
+	client := whatsmeow.NewClient(deviceStore, nil)
+	client.AddEventHandler(s.handleEvent)
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("failed to connect client: %v", err)
+	}
+
+	s.adoptRestoredClientLocked(client)
+	s.logger().Debug("Session restored on startup")
+	return nil
+}
+
+// adoptRestoredClientLocked marks the session connected on a client logged in from the stored
+// device and starts the warm-up. The caller must hold s.mu.
+func (s *UserWhatsAppSession) adoptRestoredClientLocked(client *whatsmeow.Client) {
+	s.Client = client
+	s.Status = "connected"
+	s.Ready = true
+	s.QRCode = ""
+	s.QRExpiresAt = time.Time{}
+	s.clearPairingLocked()
+	s.LastActivity = time.Now()
+	s.startWarmupLocked()
+	go func(userID uint, status string, ts time.Time) {
+		_ = saveSessionRecord(s.sessions, &UserWhatsAppSession{UserID: userID, Status: status, LastActivity: ts})
+		publishStatus(userID, status)
+	}(s.UserID, s.Status, s.LastActivity)
+}
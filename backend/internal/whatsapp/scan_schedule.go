@@ -0,0 +1,293 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"back_wa/internal/database"
+	"back_wa/internal/logging"
+	"back_wa/internal/models"
+	"back_wa/internal/services"
+)
+
+// scanScheduleBatch is how many due schedules one scheduler pass handles
+const scanScheduleBatch = 100
+
+// scanScheduleGrace is how long a due schedule waits for the instance holding the user's session
+// before another instance records the run as skipped (not connected)
+const scanScheduleGrace = time.Hour
+
+// watchScanSchedules queues the analyses of due scan schedules every SCAN_SCHEDULE_CHECK_MINUTES
+// until this instance shuts down
+func (m *MultiUserWhatsAppManager) watchScanSchedules() {
+	interval := services.ScanScheduleInterval()
+	if interval <= 0 {
+		slog.Debug("Scan scheduler disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if m.stopping.Load() {
+			return
+		}
+		if database.IsDegraded() {
+			slog.Debug("Scan scheduler skipped while the database is degraded")
+			continue
+		}
+		m.runDueScanSchedules(time.Now())
+	}
+}
+
+// runDueScanSchedules starts every due schedule whose user has a ready session on this instance.
+// Schedules of sessions held nowhere are skipped once scanScheduleGrace has passed.
+func (m *MultiUserWhatsAppManager) runDueScanSchedules(now time.Time) {
+	schedules := services.NewScanScheduleService(m.repos)
+	due, err := schedules.Due(now, scanScheduleBatch)
+	if err != nil {
+		slog.Warn("Failed to load due scan schedules", "error", err)
+		return
+	}
+
+	for i := range due {
+		schedule := &due[i]
+		m.mu.RLock()
+		session := m.userSessions[schedule.UserID]
+		m.mu.RUnlock()
+		ready := session != nil && session.IsReady() && session.GetClient() != nil && session.GetClient().Store.ID != nil
+		if !ready && now.Sub(schedule.NextRunAt) < scanScheduleGrace {
+			continue
+		}
+
+		claimed, err := schedules.Claim(schedule, now)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("Failed to claim scan schedule %d", schedule.ID), "user_id", schedule.UserID, "error", err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		if !ready {
+			m.recordScheduleSkipped(schedule, "WhatsApp not connected")
+			continue
+		}
+		if err := m.startScheduledScan(schedule, session); err != nil {
+			slog.Error(fmt.Sprintf("Failed to start scheduled scan %d", schedule.ID), "user_id", schedule.UserID, "error", err)
+			m.recordScheduleSkipped(schedule, truncateJobError(err.Error()))
+		}
+	}
+}
+
+// startScheduledScan queues a background-priority analysis job for the schedule, or records the
+// run as skipped when the number is not paid for or an analysis is already in progress
+func (m *MultiUserWhatsAppManager) startScheduledScan(schedule *models.ScanSchedule, session *UserWhatsAppSession) error {
+	ctx := context.Background()
+
+	// The one-active-job-per-user check must not race with POST /api/wa/analyze
+	m.jobs.enqueueMu.Lock()
+	defer m.jobs.enqueueMu.Unlock()
+
+	if running, err := m.repos.Jobs.ActiveForUser(ctx, schedule.UserID); err != nil {
+		return err
+	} else if running != nil {
+		m.recordScheduleSkipped(schedule, "analysis already in progress")
+		return nil
+	}
+	entitlement, err := session.entitlements.Check(schedule.UserID, session.GetClient().Store.ID.User)
+	if err != nil {
+		return err
+	}
+	if !entitlement.Entitled {
+		m.recordScheduleSkipped(schedule, "payment required: "+entitlement.Reason)
+		return nil
+	}
+	if err := session.entitlements.WithContext(ctx).CheckDailyScans(schedule.UserID); errors.Is(err, services.ErrDailyScanLimit) {
+		m.recordScheduleSkipped(schedule, "daily scan limit reached")
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	jobID, err := newAnalysisJobID()
+	if err != nil {
+		return err
+	}
+	job := models.AnalysisJob{
+		JobID:      jobID,
+		UserID:     schedule.UserID,
+		ScheduleID: &schedule.ID,
+		Status:     models.AnalysisJobQueued,
+		Stage:      analysisStageQueued,
+		Priority:   models.AnalysisPriorityBulk,
+	}
+	if err := m.repos.Jobs.Create(ctx, &job); err != nil {
+		return err
+	}
+	m.recordScheduleRun(schedule.ID, map[string]interface{}{
+		"last_status":      models.AnalysisJobQueued,
+		"last_error":       "",
+		"last_job_id":      jobID,
+		"last_analysis_id": nil,
+	})
+
+	slog.Debug(fmt.Sprintf("Scheduled scan %d queued as job %s", schedule.ID, jobID), "user_id", schedule.UserID)
+	emailSummary := schedule.EmailSummary
+	m.jobs.push(job, func(status string) {
+		m.finishScheduledScan(schedule.ID, job, status, emailSummary)
+	})
+	return nil
+}
+
+// finishScheduledScan records the job outcome on the schedule and emails the summary when asked for
+func (m *MultiUserWhatsAppManager) finishScheduledScan(scheduleID uint, job models.AnalysisJob, status string, emailSummary bool) {
+	ctx := context.Background()
+	columns := map[string]interface{}{"last_status": status}
+	finished, err := m.repos.Jobs.FindForUser(ctx, job.JobID, job.UserID)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Failed to load job %s of scan schedule %d", job.JobID, scheduleID), "error", err)
+	} else {
+		columns["last_error"] = finished.Error
+		columns["last_analysis_id"] = finished.AnalysisID
+	}
+	m.recordScheduleRun(scheduleID, columns)
+
+	if !emailSummary || status != models.AnalysisJobCompleted || finished == nil || finished.AnalysisID == nil {
+		return
+	}
+	result, err := m.repos.Analyses.FindForUser(ctx, *finished.AnalysisID, job.UserID)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Failed to load analysis %d for the scan schedule summary", *finished.AnalysisID), "user_id", job.UserID, "error", err)
+		return
+	}
+	// SMTP must not hold up the job worker
+	go func() {
+		if err := services.NewScanScheduleService(m.repos).SendSummary(result); err != nil {
+			slog.Warn("Failed to email scheduled scan summary", "user_id", job.UserID, "error", err)
+		}
+	}()
+}
+
+// recordScheduleSkipped records a run that did not analyze anything
+func (m *MultiUserWhatsAppManager) recordScheduleSkipped(schedule *models.ScanSchedule, reason string) {
+	slog.Debug(fmt.Sprintf("Scheduled scan %d skipped: %s", schedule.ID, reason), "user_id", schedule.UserID)
+	m.recordScheduleRun(schedule.ID, map[string]interface{}{
+		"last_status":      models.AnalysisJobSkipped,
+		"last_error":       reason,
+		"last_job_id":      "",
+		"last_analysis_id": nil,
+	})
+}
+
+func (m *MultiUserWhatsAppManager) recordScheduleRun(scheduleID uint, columns map[string]interface{}) {
+	if err := services.NewScanScheduleService(m.repos).RecordRun(scheduleID, columns); err != nil {
+		slog.Warn(fmt.Sprintf("Failed to update scan schedule %d", scheduleID), "error", err)
+	}
+}
+
+// HandleScanSchedule handles GET, PUT and DELETE /api/wa/schedule: the user's automatic weekly or
+// monthly re-analysis of their connected number. Enabling it requires a connected, paid number.
+// PUT body: services.ScanScheduleInput
+func (h *MultiUserWhatsAppHandler) HandleScanSchedule(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := h.extractUserIDFromToken(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	schedules := services.NewScanScheduleService(h.repos)
+	switch r.Method {
+	case http.MethodGet:
+		schedule, err := schedules.Get(userID)
+		if errors.Is(err, services.ErrNoScanSchedule) {
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": nil})
+			return
+		}
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Failed to get scan schedule", "user_id", userID, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Failed to get scan schedule"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": schedule})
+
+	case http.MethodPut:
+		var input services.ScanScheduleInput
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid request body"})
+			return
+		}
+		if input.Enabled == nil || *input.Enabled {
+			if status, message := h.scheduleEntitlement(r.Context(), userID); status != http.StatusOK {
+				w.WriteHeader(status)
+				json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": message})
+				return
+			}
+		}
+
+		schedule, err := schedules.Save(userID, input, time.Now())
+		if errors.Is(err, services.ErrInvalidScanSchedule) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+			return
+		}
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Failed to save scan schedule", "user_id", userID, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Failed to save scan schedule"})
+			return
+		}
+		logging.FromContext(r.Context()).Info("Scan schedule saved", "user_id", userID, "enabled", schedule.Enabled, "frequency", schedule.Frequency)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": schedule})
+
+	case http.MethodDelete:
+		err := schedules.Delete(userID)
+		if errors.Is(err, services.ErrNoScanSchedule) {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "No scan schedule"})
+			return
+		}
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Failed to delete scan schedule", "user_id", userID, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Failed to delete scan schedule"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "message": "Scan schedule deleted"})
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Method not allowed"})
+	}
+}
+
+// scheduleEntitlement checks that the user's connected number may be analyzed. It returns
+// http.StatusOK, or the status and message to reject enabling a schedule with.
+func (h *MultiUserWhatsAppHandler) scheduleEntitlement(ctx context.Context, userID uint) (int, string) {
+	h.waManager.mu.RLock()
+	session := h.waManager.userSessions[userID]
+	h.waManager.mu.RUnlock()
+	if session == nil || session.GetClient() == nil || session.GetClient().Store.ID == nil {
+		return http.StatusConflict, "Connect WhatsApp before scheduling scans"
+	}
+
+	entitlement, err := session.entitlements.WithContext(ctx).Check(userID, session.GetClient().Store.ID.User)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to check entitlement for scan schedule", "user_id", userID, "error", err)
+		return http.StatusInternalServerError, "Failed to verify payment status"
+	}
+	if !entitlement.Entitled {
+		return http.StatusPaymentRequired, entitlement.Message()
+	}
+	return http.StatusOK, ""
+}
@@ -0,0 +1,166 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/models"
+	"back_wa/internal/services"
+)
+
+// paymentRequirement checks whether the user may analyze the scanned number.
+// Returns nil when paid, otherwise the phone_mismatch payload (wrong_phone_number / no_payment /
+// subscription_limit_reached).
+func (h *MultiUserWhatsAppHandler) paymentRequirement(ctx context.Context, userID uint, phoneNumber string) map[string]interface{} {
+	entitlement, err := services.NewEntitlementService(h.repos).WithContext(ctx).Check(userID, phoneNumber)
+	if err != nil {
+		logging.FromContext(ctx).Error(fmt.Sprintf("Failed to check payment for phone %s", phoneNumber), "user_id", userID, "error", err)
+		return nil
+	}
+	if entitlement.Entitled {
+		return nil
+	}
+
+	if entitlement.Reason == services.EntitlementWrongPhoneNumber {
+		logging.FromContext(ctx).Debug(fmt.Sprintf("Phone number mismatch detected: %s", phoneNumber), "user_id", userID)
+		return map[string]interface{}{
+			"error_type":    services.EntitlementWrongPhoneNumber,
+			"scanned_phone": phoneNumber,
+			"message":       entitlement.Message(),
+			"payment":       h.paymentBootstrap(ctx, userID, phoneNumber),
+		}
+	}
+
+	logging.FromContext(ctx).Debug(fmt.Sprintf("No payment detected for phone: %s", phoneNumber), "user_id", userID)
+	return map[string]interface{}{
+		"error_type":   entitlement.Reason,
+		"phone_number": phoneNumber,
+		"message":      entitlement.Message(),
+		"payment":      h.paymentBootstrap(ctx, userID, phoneNumber),
+	}
+}
+
+// paymentBootstrap builds the checkout payload (plan, amount, create_payment_token) for a 402.
+// Returns nil if it cannot be built; the frontend then falls back to its own payment form.
+func (h *MultiUserWhatsAppHandler) paymentBootstrap(ctx context.Context, userID uint, phoneNumber string) *models.PaymentBootstrap {
+	paymentService := services.NewPaymentService(h.repos).WithContext(ctx)
+	bootstrap, err := paymentService.NewPaymentBootstrap(int(userID), phoneNumber)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to build payment bootstrap", "user_id", userID, "error", err)
+		return nil
+	}
+	return bootstrap
+}
+
+// contactSyncProgress reports how many contacts whatsmeow has synced so far
+func (h *MultiUserWhatsAppHandler) contactSyncProgress(userID uint) map[string]interface{} {
+	progress := map[string]interface{}{
+		"contacts_loaded": 0,
+		"synced":          false,
+	}
+
+	client := h.waManager.GetClient(userID)
+	if client == nil || client.Store == nil || client.Store.Contacts == nil || !client.IsConnected() {
+		return progress
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	contacts, err := client.Store.Contacts.GetAllContacts(ctx)
+	if err != nil {
+		slog.Debug("Could not count contacts for state", "user_id", userID, "error", err)
+		return progress
+	}
+
+	progress["contacts_loaded"] = len(contacts)
+	progress["synced"] = len(contacts) > 0
+	return progress
+}
+
+// HandleState returns everything the scan page polls for in a single call:
+// session status, QR, contact sync progress, payment requirement and analysis cache presence
+func (h *MultiUserWhatsAppHandler) HandleState(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Method not allowed"})
+		return
+	}
+
+	userID, err := h.extractUserIDForScope(r, services.ScopeWAQR)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+			"status": map[string]interface{}{
+				"whatsapp_ready": false,
+				"authenticated":  false,
+				"timestamp":      time.Now().Format(time.RFC3339),
+			},
+		})
+		return
+	}
+
+	// Also kicks off QR generation when the session is idle, like /api/wa/qr
+	qrCode, qrExpiresAt, err := h.waManager.GetQRCodeWithExpiry(userID)
+	if _, upgrading := storeUpgradeStatus(err); err != nil && !upgrading {
+		logging.FromContext(r.Context()).Error("Failed to get QR code for state", "user_id", userID, "error", err)
+	}
+	waStatus, err := h.waManager.GetStatus(userID)
+	if storeStatus, ok := storeUpgradeStatus(err); ok {
+		waStatus = storeStatus
+	} else if err != nil {
+		waStatus = "disconnected"
+	}
+	ready := h.waManager.IsReady(userID)
+	warmup, analysisAllowed := h.waManager.GetWarmup(userID)
+
+	state := map[string]interface{}{
+		"success":          true,
+		"user_id":          userID,
+		"whatsapp_status":  waStatus,
+		"ready":            ready,
+		"qr_available":     qrCode != "",
+		"qr":               qrCode,
+		"qr_expires_at":    nil,
+		"analysis_ready":   h.waManager.IsAnalysisReady(userID),
+		"contact_sync":     h.contactSyncProgress(userID),
+		"warmup":           warmup,
+		"analysis_allowed": analysisAllowed,
+		"restriction":      h.waManager.GetRestriction(userID),
+		"phone_number":     "",
+		"payment": map[string]interface{}{
+			"required": false,
+		},
+		"timestamp": time.Now().Format(time.RFC3339),
+	}
+	if code, expiresAt := h.waManager.GetPairing(userID); code != "" {
+		state["pairing_code"] = code
+		state["pairing_expires_at"] = expiresAt.Format(time.RFC3339)
+	}
+	if qrCode != "" && !qrExpiresAt.IsZero() {
+		state["qr_expires_at"] = qrExpiresAt.Format(time.RFC3339)
+	}
+
+	if ready {
+		if client := h.waManager.GetClient(userID); client != nil && client.Store.ID != nil && client.Store.ID.User != "" {
+			phoneNumber := client.Store.ID.User
+			state["phone_number"] = phoneNumber
+			if requirement := h.paymentRequirement(r.Context(), userID, phoneNumber); requirement != nil {
+				requirement["required"] = true
+				state["payment"] = requirement
+			} else {
+				state["payment"] = map[string]interface{}{"required": false, "paid": true}
+			}
+		}
+	}
+
+	json.NewEncoder(w).Encode(state)
+}
@@ -0,0 +1,160 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"back_wa/internal/cache"
+	"back_wa/internal/models"
+)
+
+// sessionCacheTimeout bounds each cache call so a slow Redis never stalls a session
+const sessionCacheTimeout = 2 * time.Second
+
+// stateCache mirrors QR codes, statuses and cached analysis results outside process memory
+// (Redis when REDIS_URL is set), so they survive restarts and are shared between instances
+var stateCache = cache.New()
+
+// cachedQR is the stored form of a pending QR code
+type cachedQR struct {
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// cachedStatus is the stored form of a session status
+type cachedStatus struct {
+	Status    string    `json:"status"`
+	Ready     bool      `json:"ready"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// cachedAnalysis is a cached analysis result with the device it was computed on
+type cachedAnalysis struct {
+	Device string                `json:"device"`
+	Result models.AnalysisResult `json:"result"`
+}
+
+func qrCacheKey(userID uint) string       { return fmt.Sprintf("wa:qr:%d", userID) }
+func statusCacheKey(userID uint) string   { return fmt.Sprintf("wa:status:%d", userID) }
+func analysisCacheKey(userID uint) string { return fmt.Sprintf("wa:analysis:%d", userID) }
+
+// analysisCacheTTL returns ANALYSIS_CACHE_TTL_HOURS (default 24)
+func analysisCacheTTL() time.Duration {
+	hours := envInt("ANALYSIS_CACHE_TTL_HOURS", 24)
+	if hours <= 0 {
+		hours = 24
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// putCached stores value as JSON; failures are logged, the in-memory state stays authoritative
+func putCached(key string, value interface{}, ttl time.Duration) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Failed to encode cache entry %s", key), "error", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sessionCacheTimeout)
+	defer cancel()
+	if err := stateCache.Set(ctx, key, encoded, ttl); err != nil {
+		slog.Warn(fmt.Sprintf("Failed to write cache entry %s", key), "error", err)
+	}
+}
+
+// getCached loads a JSON entry into dest and reports whether it was found
+func getCached(key string, dest interface{}) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), sessionCacheTimeout)
+	defer cancel()
+	encoded, ok, err := stateCache.Get(ctx, key)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Failed to read cache entry %s", key), "error", err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	if err := json.Unmarshal(encoded, dest); err != nil {
+		slog.Warn(fmt.Sprintf("Ignoring malformed cache entry %s", key), "error", err)
+		return false
+	}
+	return true
+}
+
+func deleteCached(key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), sessionCacheTimeout)
+	defer cancel()
+	if err := stateCache.Delete(ctx, key); err != nil {
+		slog.Warn(fmt.Sprintf("Failed to delete cache entry %s", key), "error", err)
+	}
+}
+
+// publishStatus records a status change, next to each saveSessionRecord of a transition
+func publishStatus(userID uint, status string) {
+	slog.Info("WhatsApp session status changed", "user_id", userID, "status", status)
+	putCached(statusCacheKey(userID), cachedStatus{Status: status, Ready: status == "connected", UpdatedAt: time.Now()}, 0)
+}
+
+// storedStatus returns the last published status, e.g. from before a restart or another instance
+func storedStatus(userID uint) (cachedStatus, bool) {
+	var status cachedStatus
+	return status, getCached(statusCacheKey(userID), &status)
+}
+
+// publishQR stores a pending QR code until it expires; an empty code removes it
+func publishQR(userID uint, code string, expiresAt time.Time) {
+	ttl := time.Until(expiresAt)
+	if code == "" || ttl <= 0 {
+		deleteCached(qrCacheKey(userID))
+		return
+	}
+	putCached(qrCacheKey(userID), cachedQR{Code: code, ExpiresAt: expiresAt}, ttl)
+}
+
+// storedQR returns a pending QR code that has not expired yet
+func storedQR(userID uint) (cachedQR, bool) {
+	var qr cachedQR
+	if !getCached(qrCacheKey(userID), &qr) || !time.Now().Before(qr.ExpiresAt) {
+		return cachedQR{}, false
+	}
+	return qr, true
+}
+
+// publishAnalysis stores the current analysis result with the device that produced it
+func (s *UserWhatsAppSession) publishAnalysis(result models.AnalysisResult) {
+	putCached(analysisCacheKey(s.UserID), cachedAnalysis{Device: s.deviceID(), Result: result}, analysisCacheTTL())
+}
+
+// storedAnalysis returns the stored analysis result. When the session knows its device, a result
+// computed on another device (the number was relinked) is ignored.
+func (s *UserWhatsAppSession) storedAnalysis() (models.AnalysisResult, bool) {
+	var entry cachedAnalysis
+	if !getCached(analysisCacheKey(s.UserID), &entry) {
+		return models.AnalysisResult{}, false
+	}
+	if device := s.deviceID(); device != "" && device != entry.Device {
+		return models.AnalysisResult{}, false
+	}
+	if entry.Result.TotalContacts == 0 && entry.Result.TotalChats == 0 {
+		return models.AnalysisResult{}, false
+	}
+	return entry.Result, true
+}
+
+// deviceID returns the linked device JID, or "" when the session has no logged-in client
+func (s *UserWhatsAppSession) deviceID() string {
+	client := s.GetClient()
+	if client == nil || client.Store == nil || client.Store.ID == nil {
+		return ""
+	}
+	return client.Store.ID.String()
+}
+
+// forgetSessionState removes everything cached for the user, on logout and forced disconnect
+func forgetSessionState(userID uint) {
+	deleteCached(qrCacheKey(userID))
+	deleteCached(statusCacheKey(userID))
+	deleteCached(analysisCacheKey(userID))
+}
@@ -0,0 +1,63 @@
+package whatsapp
+
+import (
+	"fmt"
+	"time"
+
+	"back_wa/internal/models"
+
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// handleEvent reacts to whatsmeow connection and chat events for this user's session
+func (s *UserWhatsAppSession) handleEvent(evt interface{}) {
+	// History sync batches are persisted right away; live message counts go out with the next flush
+	if s.chats.handleEvent(evt) {
+		s.flushChats()
+		return
+	}
+
+	switch v := evt.(type) {
+	case *events.TemporaryBan:
+		s.markBanned(models.SessionEventTempBanned, int(v.Code), v.Code.String(), v.Expire)
+	case *events.ConnectFailure:
+		if v.Reason == events.ConnectFailureTempBanned || v.Reason == events.ConnectFailureMainDeviceGone {
+			s.markBanned(models.SessionEventBanned, int(v.Reason), v.Message, 0)
+		}
+	case *events.LoggedOut:
+		s.logger().Debug(fmt.Sprintf("WhatsApp logged out by server (on_connect=%v, reason=%d)", v.OnConnect, int(v.Reason)))
+		// 403 is WhatsApp locking the account, not the user unlinking the device
+		if v.Reason == events.ConnectFailureMainDeviceGone {
+			s.markBanned(models.SessionEventBanned, int(v.Reason), v.Reason.String(), 0)
+			return
+		}
+		s.markDisconnected("Perangkat WhatsApp kamu telah keluar. Silakan scan QR code lagi untuk menghubungkan ulang.")
+	case *events.Disconnected:
+		s.logger().Debug("WhatsApp connection closed by server")
+		s.markDisconnected("Koneksi WhatsApp kamu terputus. Buka dashboard untuk menghubungkan ulang.")
+	}
+}
+
+// markDisconnected persists the disconnected state and notifies the user once per connected period
+func (s *UserWhatsAppSession) markDisconnected(message string) {
+	s.mu.Lock()
+	if s.Status == StatusBanned {
+		// Disconnect that follows a ban; keep the banned status
+		s.mu.Unlock()
+		return
+	}
+	wasConnected := s.Status == "connected"
+	s.Status = "disconnected"
+	s.Ready = false
+	s.LastActivity = time.Now()
+	s.resetWarmupLocked()
+	s.mu.Unlock()
+
+	_ = saveSessionRecord(s.sessions, &UserWhatsAppSession{UserID: s.UserID, Status: "disconnected", LastActivity: time.Now()})
+	publishStatus(s.UserID, "disconnected")
+
+	if wasConnected {
+		s.notifications.NotifyAsync(s.UserID, models.NotificationSessionDisconnected,
+			"WhatsApp terputus", message, nil)
+	}
+}
@@ -0,0 +1,98 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"back_wa/internal/logging"
+)
+
+// Shutdown disconnects the WhatsApp clients of all sessions before the server exits
+func (h *MultiUserWhatsAppHandler) Shutdown(ctx context.Context) {
+	h.waManager.Shutdown(ctx)
+}
+
+// Shutdown disconnects every WhatsApp client without unlinking its device, flushes pending chat
+// metadata and persists each session's status as it was, so connected sessions can be restored
+// on the next start. Connected sessions are handed off: they read as "reconnecting" and running
+// instances reclaim them as soon as the clients are closed. Sessions still busy when ctx expires
+// are abandoned.
+func (m *MultiUserWhatsAppManager) Shutdown(ctx context.Context) {
+	m.stopping.Store(true)
+
+	m.mu.RLock()
+	sessions := make([]*UserWhatsAppSession, 0, len(m.userSessions))
+	for _, session := range m.userSessions {
+		sessions = append(sessions, session)
+	}
+	m.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, session := range sessions {
+		wg.Add(1)
+		go func(s *UserWhatsAppSession) {
+			defer wg.Done()
+			s.shutdown()
+		}(session)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		logging.FromContext(ctx).Debug(fmt.Sprintf("%d WhatsApp sessions shut down", len(sessions)))
+	case <-ctx.Done():
+		logging.FromContext(ctx).Warn(fmt.Sprintf("Shutdown deadline reached before all %d WhatsApp sessions were closed", len(sessions)))
+	}
+	// Only now that this instance no longer holds the connections may another one take them
+	signalHandoff()
+}
+
+// shutdown closes one session for a server stop
+func (s *UserWhatsAppSession) shutdown() {
+	s.flushChats()
+
+	s.mu.Lock()
+	client := s.Client
+	status := s.Status
+	lastActivity := s.LastActivity
+	s.Ready = false
+	s.QRCode = ""
+	s.QRExpiresAt = time.Time{}
+	s.clearPairingLocked()
+	s.mu.Unlock()
+
+	// Read as "reconnecting" until another instance (or the restart) restores the session
+	if status == "connected" {
+		markHandoff(s.UserID)
+	}
+
+	if client != nil {
+		// Ignore panics from the underlying client, like Logout does
+		func() { defer func() { recover() }(); client.Disconnect() }()
+	}
+
+	// A pending QR dies with this process; anything else is kept for the restart
+	if status == "scanning" || status == "connecting" {
+		status = "disconnected"
+	}
+	if lastActivity.IsZero() {
+		lastActivity = time.Now()
+	}
+	_ = saveSessionRecord(s.sessions, &UserWhatsAppSession{UserID: s.UserID, Status: status, LastActivity: lastActivity})
+	if status != "connected" {
+		publishStatus(s.UserID, status)
+	}
+	publishQR(s.UserID, "", time.Time{})
+
+	if s.SessionDB != nil {
+		if err := s.SessionDB.Close(); err != nil {
+			s.logger().Warn("Failed to close WhatsApp store", "error", err)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package whatsapp
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"back_wa/internal/services"
+)
+
+// storeMaintenanceWait bounds how long opening a session store waits for its VACUUM
+const storeMaintenanceWait = 30 * time.Second
+
+// storeVacuumMinFree is the share of free pages a store needs before VACUUM is worth it
+const storeVacuumMinFree = 0.2
+
+// StoreSize is the disk usage of one sqlite session store as seen by the last maintenance pass
+type StoreSize struct {
+	UserID         uint       `json:"user_id"`
+	DBBytes        int64      `json:"db_bytes"`
+	WALBytes       int64      `json:"wal_bytes"`
+	Idle           bool       `json:"idle"`
+	LastCheckpoint *time.Time `json:"last_checkpoint,omitempty"`
+	LastVacuum     *time.Time `json:"last_vacuum,omitempty"`
+	ReclaimedBytes int64      `json:"reclaimed_bytes"` // by the last VACUUM
+	LastError      string     `json:"last_error,omitempty"`
+}
+
+// storeMaintenance keeps the size of every store and the stores being vacuumed or removed;
+// openSessionStore waits on the channel of a claimed store
+var storeMaintenance = struct {
+	mu        sync.Mutex
+	sizes     map[uint]*StoreSize
+	vacuuming map[uint]chan struct{}
+	lastRun   time.Time
+}{sizes: make(map[uint]*StoreSize), vacuuming: make(map[uint]chan struct{})}
+
+// storeMaintenanceInterval is WA_STORE_MAINTENANCE_MINUTES (default 360, 0 disables)
+func storeMaintenanceInterval() time.Duration {
+	return time.Duration(envInt("WA_STORE_MAINTENANCE_MINUTES", 360)) * time.Minute
+}
+
+// storeIdleAfter is WA_STORE_IDLE_MINUTES (default 60): a store not loaded on this instance and
+// unchanged for that long is vacuumed
+func storeIdleAfter() time.Duration {
+	return time.Duration(envInt("WA_STORE_IDLE_MINUTES", 60)) * time.Minute
+}
+
+// storeAlertBytes is WA_STORE_ALERT_MB (default 200, 0 disables): stores larger than that,
+// WAL included, raise an ops alert
+func storeAlertBytes() int64 {
+	return int64(envInt("WA_STORE_ALERT_MB", 200)) << 20
+}
+
+// watchSessionStores checkpoints and vacuums the sqlite session stores every
+// WA_STORE_MAINTENANCE_MINUTES until this instance shuts down
+func (m *MultiUserWhatsAppManager) watchSessionStores() {
+	interval := storeMaintenanceInterval()
+	if interval <= 0 {
+		slog.Debug("Session store maintenance disabled")
+		return
+	}
+	switch os.Getenv("WA_STORE_DRIVER") {
+	case "postgres", "pgx":
+		// Postgres is maintained by its own autovacuum
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.maintainSessionStores()
+		<-ticker.C
+		if m.stopping.Load() {
+			return
+		}
+	}
+}
+
+// maintainSessionStores checkpoints the WAL of every store, vacuums the idle ones with enough
+// free pages and alerts on stores over WA_STORE_ALERT_MB
+func (m *MultiUserWhatsAppManager) maintainSessionStores() {
+	userIDs, err := sqliteSessionStores()
+	if err != nil {
+		slog.Warn("Failed to list WhatsApp session stores", "error", err)
+		return
+	}
+
+	started := time.Now()
+	sizes := make(map[uint]*StoreSize, len(userIDs))
+	var checkpointed, vacuumed int
+	var reclaimed int64
+	for _, userID := range userIDs {
+		if m.stopping.Load() {
+			return
+		}
+		size := m.maintainSessionStore(userID)
+		sizes[userID] = size
+		if size.LastCheckpoint != nil {
+			checkpointed++
+		}
+		if size.LastVacuum != nil {
+			vacuumed++
+			reclaimed += size.ReclaimedBytes
+		}
+	}
+
+	storeMaintenance.mu.Lock()
+	// Keep the last vacuum of stores that were not vacuumed this time
+	for userID, size := range sizes {
+		if previous := storeMaintenance.sizes[userID]; previous != nil && size.LastVacuum == nil {
+			size.LastVacuum, size.ReclaimedBytes = previous.LastVacuum, previous.ReclaimedBytes
+		}
+	}
+	storeMaintenance.sizes = sizes
+	storeMaintenance.lastRun = started
+	storeMaintenance.mu.Unlock()
+
+	slog.Info(fmt.Sprintf("Session store maintenance: %d stores, %d checkpointed, %d vacuumed (%d bytes reclaimed) in %s",
+		len(userIDs), checkpointed, vacuumed, reclaimed, time.Since(started).Round(time.Millisecond)))
+	alertLargeSessionStores(sizes)
+}
+
+// maintainSessionStore checkpoints one store and vacuums it when idle. Stores of loaded sessions
+// only get a passive checkpoint so the running client is never blocked.
+func (m *MultiUserWhatsAppManager) maintainSessionStore(userID uint) *StoreSize {
+	size := &StoreSize{UserID: userID}
+	size.DBBytes, size.WALBytes = sessionStoreBytes(userID)
+
+	if storeUpgradePending(userID) {
+		return size
+	}
+
+	release, idle := m.claimIdleStore(userID)
+	size.Idle = idle
+	if idle {
+		defer release()
+	}
+
+	_, dsn, _ := sessionStoreDriver(userID)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		size.LastError = err.Error()
+		return size
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), storeMaintenanceWait)
+	defer cancel()
+
+	mode := "PASSIVE"
+	if idle {
+		mode = "TRUNCATE"
+	}
+	if _, err := db.ExecContext(ctx, "PRAGMA wal_checkpoint("+mode+")"); err != nil {
+		size.LastError = fmt.Sprintf("checkpoint failed: %v", err)
+		slog.Warn("Session store checkpoint failed", "user_id", userID, "error", err)
+		return size
+	}
+	now := time.Now()
+	size.LastCheckpoint = &now
+
+	if idle {
+		var pages, free int64
+		if err := db.QueryRowContext(ctx, "PRAGMA page_count").Scan(&pages); err == nil && pages > 0 {
+			if err := db.QueryRowContext(ctx, "PRAGMA freelist_count").Scan(&free); err == nil && float64(free)/float64(pages) >= storeVacuumMinFree {
+				before := size.DBBytes
+				if _, err := db.ExecContext(ctx, "VACUUM"); err != nil {
+					size.LastError = fmt.Sprintf("vacuum failed: %v", err)
+					slog.Warn("Session store vacuum failed", "user_id", userID, "error", err)
+				} else {
+					vacuumedAt := time.Now()
+					size.LastVacuum = &vacuumedAt
+					_, _ = db.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)")
+					size.DBBytes, _ = sessionStoreBytes(userID)
+					size.ReclaimedBytes = before - size.DBBytes
+				}
+			}
+		}
+	}
+	_, size.WALBytes = sessionStoreBytes(userID)
+	return size
+}
+
+// storeUpgradePending reports whether the user's store is being upgraded or failed its upgrade
+func storeUpgradePending(userID uint) bool {
+	storeUpgrades.mu.Lock()
+	defer storeUpgrades.mu.Unlock()
+	return storeUpgrades.running[userID] || storeUpgrades.failed[userID] != nil
+}
+
+// claimIdleStore claims the user's store when no session is loaded for it and it was not written
+// for WA_STORE_IDLE_MINUTES, so a login opening it waits until release is called
+func (m *MultiUserWhatsAppManager) claimIdleStore(userID uint) (func(), bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if _, loaded := m.userSessions[userID]; loaded || !sessionStoreUnchangedSince(userID, time.Now().Add(-storeIdleAfter())) {
+		return nil, false
+	}
+
+	storeMaintenance.mu.Lock()
+	defer storeMaintenance.mu.Unlock()
+	if storeMaintenance.vacuuming[userID] != nil {
+		return nil, false
+	}
+	done := make(chan struct{})
+	storeMaintenance.vacuuming[userID] = done
+	return func() {
+		storeMaintenance.mu.Lock()
+		close(done)
+		delete(storeMaintenance.vacuuming, userID)
+		storeMaintenance.mu.Unlock()
+	}, true
+}
+
+// waitStoreMaintenance blocks while the user's store is claimed by maintenance, up to storeMaintenanceWait
+func waitStoreMaintenance(userID uint) {
+	storeMaintenance.mu.Lock()
+	done := storeMaintenance.vacuuming[userID]
+	storeMaintenance.mu.Unlock()
+	if done == nil {
+		return
+	}
+	select {
+	case <-done:
+	case <-time.After(storeMaintenanceWait):
+		slog.Warn("Opening session store while it is still being vacuumed", "user_id", userID)
+	}
+}
+
+// sessionStoreBytes returns the size of the store file and of its WAL file
+func sessionStoreBytes(userID uint) (int64, int64) {
+	var db, wal int64
+	if info, err := os.Stat(sessionStoreFile(userID)); err == nil {
+		db = info.Size()
+	}
+	if info, err := os.Stat(sessionStoreFile(userID) + "-wal"); err == nil {
+		wal = info.Size()
+	}
+	return db, wal
+}
+
+// sessionStoreUnchangedSince reports whether neither the store nor its WAL was written after t
+func sessionStoreUnchangedSince(userID uint, t time.Time) bool {
+	for _, file := range []string{sessionStoreFile(userID), sessionStoreFile(userID) + "-wal"} {
+		if info, err := os.Stat(file); err == nil && info.ModTime().After(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// alertLargeSessionStores raises one ops alert for all stores over WA_STORE_ALERT_MB
+func alertLargeSessionStores(sizes map[uint]*StoreSize) {
+	limit := storeAlertBytes()
+	if limit <= 0 {
+		return
+	}
+	var over []*StoreSize
+	for _, size := range sizes {
+		if size.DBBytes+size.WALBytes > limit {
+			over = append(over, size)
+		}
+	}
+	if len(over) == 0 {
+		return
+	}
+	sort.Slice(over, func(i, j int) bool {
+		return over[i].DBBytes+over[i].WALBytes > over[j].DBBytes+over[j].WALBytes
+	})
+	largest := over[0]
+	services.NewOpsAlerter().Alert("wa_store_size", fmt.Sprintf("%d WhatsApp session stores exceed %d MB; largest is user %d with %d MB (WAL %d MB)",
+		len(over), limit>>20, largest.UserID, (largest.DBBytes+largest.WALBytes)>>20, largest.WALBytes>>20))
+}
+
+// SessionStoreStats returns the store sizes of the last maintenance pass, largest first, capped
+// at limit stores, with the totals over all of them
+func SessionStoreStats(limit int) map[string]interface{} {
+	storeMaintenance.mu.Lock()
+	count := len(storeMaintenance.sizes)
+	stores := make([]StoreSize, 0, count)
+	var dbBytes, walBytes int64
+	for _, size := range storeMaintenance.sizes {
+		stores = append(stores, *size)
+		dbBytes += size.DBBytes
+		walBytes += size.WALBytes
+	}
+	lastRun := storeMaintenance.lastRun
+	storeMaintenance.mu.Unlock()
+
+	sort.Slice(stores, func(i, j int) bool {
+		return stores[i].DBBytes+stores[i].WALBytes > stores[j].DBBytes+stores[j].WALBytes
+	})
+	if len(stores) > limit {
+		stores = stores[:limit]
+	}
+	stats := map[string]interface{}{
+		"count":       count,
+		"db_bytes":    dbBytes,
+		"wal_bytes":   walBytes,
+		"alert_bytes": storeAlertBytes(),
+		"largest":     stores,
+	}
+	if !lastRun.IsZero() {
+		stats["last_run"] = lastRun
+	}
+	return stats
+}
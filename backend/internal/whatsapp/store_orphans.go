@@ -0,0 +1,291 @@
+package whatsapp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"back_wa/internal/logging"
+	"back_wa/internal/middleware"
+)
+
+// Why a session store is an orphan
+const (
+	OrphanUserMissing    = "user_missing"    // the user was deleted
+	OrphanUserAnonymized = "user_anonymized" // the account was deleted or anonymized
+	OrphanNoSession      = "no_session"      // no session row: logged out or wiped while no instance held the session
+	OrphanNoDevice       = "no_device"       // the store holds no linked device
+)
+
+// OrphanStore is a session store file that no user or session refers to any more
+type OrphanStore struct {
+	UserID   uint      `json:"user_id"`
+	Reason   string    `json:"reason"`
+	Files    []string  `json:"files"` // store, WAL/SHM and upgrade backups
+	Bytes    int64     `json:"bytes"`
+	Modified time.Time `json:"modified"`
+	Removed  bool      `json:"removed"`
+	Archived string    `json:"archived,omitempty"` // directory the files were moved to
+	Error    string    `json:"error,omitempty"`
+}
+
+// storeOrphanInterval is WA_STORE_ORPHAN_CLEANUP_HOURS (default 24, 0 disables the job)
+func storeOrphanInterval() time.Duration {
+	return time.Duration(envInt("WA_STORE_ORPHAN_CLEANUP_HOURS", 24)) * time.Hour
+}
+
+// watchOrphanStores removes orphaned session stores every WA_STORE_ORPHAN_CLEANUP_HOURS until
+// this instance shuts down. Only sqlite stores are per user; the Postgres store is shared.
+func (m *MultiUserWhatsAppManager) watchOrphanStores() {
+	interval := storeOrphanInterval()
+	if interval <= 0 {
+		slog.Debug("Orphaned session store cleanup disabled")
+		return
+	}
+	switch os.Getenv("WA_STORE_DRIVER") {
+	case "postgres", "pgx":
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if m.stopping.Load() {
+			return
+		}
+		orphans, err := m.CleanupOrphanStores(context.Background(), false)
+		if err != nil {
+			slog.Warn("Orphaned session store cleanup failed", "error", err)
+			continue
+		}
+		if len(orphans) > 0 {
+			slog.Info(fmt.Sprintf("Removed %d orphaned session stores", countRemoved(orphans)), "found", len(orphans))
+		}
+	}
+}
+
+// CleanupOrphanStores finds the session stores of deleted users and of sessions that no longer
+// exist, and removes them (moved to WA_STORE_ORPHAN_ARCHIVE_DIR when set) unless dryRun.
+// Stores of sessions loaded here or written in the last WA_STORE_IDLE_MINUTES are never touched.
+func (m *MultiUserWhatsAppManager) CleanupOrphanStores(ctx context.Context, dryRun bool) ([]OrphanStore, error) {
+	userIDs, err := sqliteSessionStores()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list session stores: %w", err)
+	}
+	if len(userIDs) == 0 {
+		return []OrphanStore{}, nil
+	}
+
+	users, err := m.repos.Users.ListByIDs(ctx, userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load users: %w", err)
+	}
+	withSession, err := m.repos.Sessions.UserIDsWithSession(ctx, userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sessions: %w", err)
+	}
+	anonymized := make(map[uint]bool, len(users))
+	for _, user := range users {
+		anonymized[user.ID] = user.AnonymizedAt != nil
+	}
+	hasSession := make(map[uint]bool, len(withSession))
+	for _, userID := range withSession {
+		hasSession[userID] = true
+	}
+
+	archiveDir := os.Getenv("WA_STORE_ORPHAN_ARCHIVE_DIR")
+	orphans := []OrphanStore{}
+	for _, userID := range userIDs {
+		if storeUpgradePending(userID) {
+			continue
+		}
+		release, idle := m.claimIdleStore(userID)
+		if !idle {
+			continue
+		}
+
+		reason := ""
+		isAnonymized, exists := anonymized[userID]
+		switch {
+		case !exists:
+			reason = OrphanUserMissing
+		case isAnonymized:
+			reason = OrphanUserAnonymized
+		case !hasSession[userID]:
+			reason = OrphanNoSession
+		case !sessionStoreHasDevice(ctx, userID):
+			reason = OrphanNoDevice
+		}
+		if reason == "" {
+			release()
+			continue
+		}
+
+		orphan := OrphanStore{UserID: userID, Reason: reason, Files: sessionStoreFiles(userID)}
+		for _, file := range orphan.Files {
+			if info, err := os.Stat(file); err == nil {
+				orphan.Bytes += info.Size()
+				if info.ModTime().After(orphan.Modified) {
+					orphan.Modified = info.ModTime()
+				}
+			}
+		}
+		if !dryRun {
+			orphan.Archived, err = removeOrphanStore(orphan.Files, archiveDir)
+			if err != nil {
+				orphan.Error = err.Error()
+				slog.Warn("Failed to remove orphaned session store", "user_id", userID, "error", err)
+			} else {
+				orphan.Removed = true
+				slog.Info("Orphaned session store removed", "user_id", userID, "reason", reason, "bytes", orphan.Bytes, "archived", orphan.Archived)
+			}
+		}
+		release()
+		orphans = append(orphans, orphan)
+	}
+
+	if !dryRun {
+		// The sizes of removed stores are stale now
+		storeMaintenance.mu.Lock()
+		for _, orphan := range orphans {
+			if orphan.Removed {
+				delete(storeMaintenance.sizes, orphan.UserID)
+			}
+		}
+		storeMaintenance.mu.Unlock()
+	}
+	return orphans, nil
+}
+
+// sessionStoreFiles returns the store file of the user with its WAL/SHM files and upgrade backups
+func sessionStoreFiles(userID uint) []string {
+	store := sessionStoreFile(userID)
+	files := []string{store}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if _, err := os.Stat(store + suffix); err == nil {
+			files = append(files, store+suffix)
+		}
+	}
+	if backups, err := filepath.Glob(store + ".v*.bak"); err == nil {
+		files = append(files, backups...)
+	}
+	return files
+}
+
+// sessionStoreHasDevice reports whether the store holds a linked device. Unreadable stores
+// count as having one so they are left for an operator.
+func sessionStoreHasDevice(ctx context.Context, userID uint) bool {
+	_, dsn, _ := sessionStoreDriver(userID)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return true
+	}
+	defer db.Close()
+
+	var tables int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'whatsmeow_device'").Scan(&tables); err != nil {
+		return true
+	}
+	if tables == 0 {
+		return false
+	}
+	var devices int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM whatsmeow_device").Scan(&devices); err != nil {
+		return true
+	}
+	return devices > 0
+}
+
+// removeOrphanStore deletes the files, or moves them to a dated subdirectory of archiveDir
+func removeOrphanStore(files []string, archiveDir string) (string, error) {
+	if archiveDir == "" {
+		for _, file := range files {
+			if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+				return "", err
+			}
+		}
+		return "", nil
+	}
+
+	dir := filepath.Join(archiveDir, time.Now().Format("2006-01-02"))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	for _, file := range files {
+		if err := os.Rename(file, filepath.Join(dir, filepath.Base(file))); err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+func countRemoved(orphans []OrphanStore) int {
+	removed := 0
+	for _, orphan := range orphans {
+		if orphan.Removed {
+			removed++
+		}
+	}
+	return removed
+}
+
+// HandleOrphanStores handles GET and POST /api/admin/session-stores/orphans (admin only).
+// GET is a dry run listing the orphaned stores; POST removes them, or only lists them with
+// {"dry_run": true}.
+func (h *MultiUserWhatsAppHandler) HandleOrphanStores(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims := middleware.ClaimsFromContext(r.Context())
+
+	switch os.Getenv("WA_STORE_DRIVER") {
+	case "postgres", "pgx":
+		http.Error(w, "Orphan cleanup only applies to sqlite session stores", http.StatusConflict)
+		return
+	}
+
+	dryRun := r.Method == http.MethodGet
+	if r.Method == http.MethodPost && r.ContentLength != 0 {
+		var req struct {
+			DryRun bool `json:"dry_run"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		dryRun = req.DryRun
+	}
+
+	orphans, err := h.waManager.CleanupOrphanStores(r.Context(), dryRun)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to clean up orphaned session stores", "error", err)
+		http.Error(w, "Failed to clean up orphaned session stores", http.StatusInternalServerError)
+		return
+	}
+	var bytes int64
+	for _, orphan := range orphans {
+		bytes += orphan.Bytes
+	}
+	if !dryRun {
+		logging.FromContext(r.Context()).Info(fmt.Sprintf("Admin %d removed %d orphaned session stores", claims.UserID, countRemoved(orphans)),
+			"audit", true, "admin_id", claims.UserID, "found", len(orphans), "bytes", bytes)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"dry_run": dryRun,
+		"data":    orphans,
+		"count":   len(orphans),
+		"bytes":   bytes,
+		"removed": countRemoved(orphans),
+	})
+}
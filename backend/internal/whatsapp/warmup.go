@@ -0,0 +1,185 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Warm-up phases after a session connects. Contacts and groups arrive gradually
+// after pairing, so analysis is gated on the estimated completeness.
+const (
+	WarmupPairing         = "pairing"
+	WarmupSyncingContacts = "syncing_contacts"
+	WarmupSyncingGroups   = "syncing_groups"
+	WarmupReady           = "ready"
+)
+
+const (
+	warmupPollInterval = 2 * time.Second
+	// contact count must stay unchanged for this many polls to count as synced
+	warmupStablePolls = 3
+)
+
+// WarmupState is the warm-up progress surfaced in /api/wa/status
+type WarmupState struct {
+	Phase     string    `json:"phase"`
+	Progress  int       `json:"progress"` // estimated completeness, 0-100
+	Contacts  int       `json:"contacts"`
+	Groups    int       `json:"groups"`
+	StartedAt time.Time `json:"started_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// warmupMinProgress is the completeness required before analysis is allowed without override
+func warmupMinProgress() int {
+	if v, err := strconv.Atoi(os.Getenv("WA_WARMUP_MIN_PROGRESS")); err == nil && v >= 0 && v <= 100 {
+		return v
+	}
+	return 80
+}
+
+// warmupMaxDuration bounds how long the warm-up keeps polling
+func warmupMaxDuration() time.Duration {
+	if v, err := strconv.Atoi(os.Getenv("WA_WARMUP_MAX_SECONDS")); err == nil && v > 0 {
+		return time.Duration(v) * time.Second
+	}
+	return 2 * time.Minute
+}
+
+// startWarmupLocked resets the warm-up state and starts tracking sync progress in the background.
+// The caller must hold s.mu.
+func (s *UserWhatsAppSession) startWarmupLocked() {
+	now := time.Now()
+	s.Warmup = WarmupState{Phase: WarmupPairing, StartedAt: now, UpdatedAt: now}
+	s.warmupRun++
+	go s.runWarmup(s.warmupRun)
+}
+
+// resetWarmupLocked clears the warm-up state and stops a running warm-up. The caller must hold s.mu.
+func (s *UserWhatsAppSession) resetWarmupLocked() {
+	s.Warmup = WarmupState{}
+	s.warmupRun++
+}
+
+// setWarmup updates the state unless a newer warm-up run has replaced this one
+func (s *UserWhatsAppSession) setWarmup(run int, phase string, progress, contacts, groups int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.warmupRun != run {
+		return false
+	}
+	s.Warmup.Phase = phase
+	s.Warmup.Progress = progress
+	s.Warmup.Contacts = contacts
+	s.Warmup.Groups = groups
+	s.Warmup.UpdatedAt = time.Now()
+	return true
+}
+
+// runWarmup walks pairing → syncing_contacts → syncing_groups → ready
+func (s *UserWhatsAppSession) runWarmup(run int) {
+	deadline := time.Now().Add(warmupMaxDuration())
+	lastCount, stable := -1, 0
+
+	for ; time.Now().Before(deadline); time.Sleep(warmupPollInterval) {
+		client := s.GetClient()
+		if client == nil || client.Store == nil || client.Store.Contacts == nil || !client.IsConnected() || client.Store.ID == nil {
+			if !s.setWarmup(run, WarmupPairing, 5, 0, 0) {
+				return
+			}
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		s.waCalls.Add(1)
+		contacts, err := client.Store.Contacts.GetAllContacts(ctx)
+		cancel()
+		if err != nil {
+			s.logger().Debug("Warm-up could not count contacts", "error", err)
+			continue
+		}
+
+		count := len(contacts)
+		if count > 0 && count == lastCount {
+			stable++
+		} else {
+			stable = 0
+		}
+		lastCount = count
+
+		if count == 0 || stable < warmupStablePolls {
+			// 10% once paired, up to 60% as the contact count settles
+			progress := 10
+			if count > 0 {
+				progress = 20 + 40*stable/warmupStablePolls
+			}
+			if !s.setWarmup(run, WarmupSyncingContacts, progress, count, 0) {
+				return
+			}
+			continue
+		}
+
+		if !s.setWarmup(run, WarmupSyncingGroups, 70, count, 0) {
+			return
+		}
+		s.finishWarmup(run, count)
+		return
+	}
+
+	// Sync never settled; whatever arrived is treated as complete if there is anything at all
+	s.mu.RLock()
+	contacts := s.Warmup.Contacts
+	s.mu.RUnlock()
+	if contacts > 0 {
+		s.logger().Debug(fmt.Sprintf("Warm-up timed out with %d contacts, marking ready", contacts))
+		s.finishWarmup(run, contacts)
+		return
+	}
+	s.logger().Debug("Warm-up timed out without contacts")
+}
+
+// finishWarmup fetches joined groups into the session and marks the warm-up ready
+func (s *UserWhatsAppSession) finishWarmup(run int, contacts int) {
+	groups := 0
+	joined, err := s.joinedGroups()
+	if err != nil {
+		s.logger().Debug("Warm-up could not fetch groups", "error", err)
+	} else {
+		s.GroupsMu.Lock()
+		s.Groups = make(map[types.JID]types.GroupInfo, len(joined))
+		for _, group := range joined {
+			if group != nil {
+				s.Groups[group.JID] = *group
+			}
+		}
+		s.GroupsMu.Unlock()
+		groups = len(joined)
+	}
+
+	if s.setWarmup(run, WarmupReady, 100, contacts, groups) {
+		s.logger().Debug(fmt.Sprintf("Warm-up complete (contacts=%d, groups=%d)", contacts, groups))
+		s.triggerAutomaticAnalysis()
+	}
+}
+
+// GetWarmup returns the user's warm-up state and whether analysis is allowed without override
+func (m *MultiUserWhatsAppManager) GetWarmup(userID uint) (WarmupState, bool) {
+	session, err := m.GetOrCreateSession(userID)
+	if err != nil {
+		return WarmupState{}, false
+	}
+
+	session.mu.RLock()
+	defer session.mu.RUnlock()
+	state := session.Warmup
+	if state.Phase == "" && session.Ready {
+		// Connected before warm-up tracking existed for this session
+		state.Phase, state.Progress = WarmupReady, 100
+	}
+	return state, state.Phase == WarmupReady || state.Progress >= warmupMinProgress()
+}
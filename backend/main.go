@@ -2,13 +2,24 @@ package main
 
 import (
 	"bufio"
-	"log"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
+	"back_wa/api"
+	"back_wa/internal/config"
 	"back_wa/internal/database"
 	"back_wa/internal/handlers"
+	"back_wa/internal/logging"
+	"back_wa/internal/middleware"
+	"back_wa/internal/repository"
+	"back_wa/internal/server"
 	"back_wa/internal/services"
 	"back_wa/internal/whatsapp"
 
@@ -40,17 +51,30 @@ func loadEnvFile(filename string) {
 		}
 	}
 
-	log.Printf("DEBUG: Loaded environment from %s", filename)
+	slog.Debug(fmt.Sprintf("Loaded environment from %s", filename))
 }
 
 // CORS middleware
 func corsMiddleware(next http.Handler) http.Handler {
+	// Cookie sessions need credentialed CORS, which cannot use a wildcard origin
+	cookieMode := services.LoadSessionCookieConfig().CookieMode()
+	allowedOrigins := services.NewRedirectAllowList()
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 		// Set CORS headers
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, ngrok-skip-browser-warning")
+		if origin := r.Header.Get("Origin"); cookieMode && origin != "" {
+			if allowedOrigins.IsAllowed(origin) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+			w.Header().Add("Vary", "Origin")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		}
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-CSRF-Token, Idempotency-Key, X-Request-ID, ngrok-skip-browser-warning")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 		w.Header().Set("Access-Control-Max-Age", "86400") // 24 hours
 
 		// Handle preflight requests
@@ -64,105 +88,648 @@ func corsMiddleware(next http.Handler) http.Handler {
 }
 
 func main() {
-	log.Println("DEBUG: Starting WhatsApp API server...")
+	slog.Debug("Starting WhatsApp API server")
 
 	// Load environment variables from .env file
 	loadEnvFile(".env")
 	loadEnvFile("env.production")
 	loadEnvFile("env.local")
 
+	// Mask phone numbers and JIDs in everything logged from here on
+	services.InstallLogRedaction()
+	// Leveled structured logs (LOG_LEVEL, LOG_FORMAT), still going through the redaction
+	logging.Setup(services.RedactingWriter(os.Stderr))
+
+	// Variables holding vault://, awssm:// or gcpsm:// references are replaced with the secret
+	// before anything reads them
+	secrets, err := config.LoadSecrets(context.Background())
+	if err != nil {
+		fatal("Failed to load secrets", err)
+	}
+
+	// Repositories shared by handlers, services and the WhatsApp manager. They resolve the
+	// database connection per call, so they can be created before the database is initialized.
+	repos := repository.Default()
+
+	// "selfcheck" (or --check) validates the configuration, the database, Xendit, SMTP and the
+	// session directories, prints a pass/fail report and exits without starting the server
+	if len(os.Args) > 1 && (os.Args[1] == "selfcheck" || os.Args[1] == "--check") {
+		report := services.RunSelfCheck(context.Background(), repos.DataKeys)
+		report.Print(os.Stdout)
+		if !report.Passed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "link-otp-sender <phone>" links the system WhatsApp number OTPs are sent from and exits
+	if len(os.Args) > 2 && os.Args[1] == "link-otp-sender" {
+		if err := whatsapp.LinkOTPSender(context.Background(), os.Args[2], os.Stdout); err != nil {
+			fatal("Failed to link the WhatsApp OTP sender", err)
+		}
+		return
+	}
+
+	// Validate TLS settings before doing any work
+	tlsConfig, err := server.LoadTLSConfig()
+	if err != nil {
+		fatal("Invalid TLS configuration", err)
+	}
+
+	// JWT signing keys are required outside development mode
+	jwtKeys, err := services.JWTKeys()
+	if err != nil {
+		fatal("Invalid JWT key configuration", err)
+	}
+	if err := services.CheckSigningSecrets(); err != nil {
+		fatal("Invalid signing configuration", err)
+	}
+	slog.Debug(fmt.Sprintf("Signing JWTs with key id %q", jwtKeys.ActiveKeyID()))
+
+	// Master keys wrapping the data keys of the encrypted columns, required outside development mode
+	fieldMasterKeys, err := services.LoadFieldMasterKeys()
+	if err != nil {
+		fatal("Invalid field encryption configuration", err)
+	}
+
+	// Optional source IP allow-lists for admin and webhook endpoints
+	adminAllowList, err := middleware.NewIPAllowList("/api/admin/", "ADMIN_ALLOWED_CIDRS")
+	if err != nil {
+		fatal("Invalid admin IP allow-list", err)
+	}
+	webhookAllowList, err := middleware.NewIPAllowList("/api/webhooks/", "WEBHOOK_ALLOWED_CIDRS")
+	if err != nil {
+		fatal("Invalid webhook IP allow-list", err)
+	}
+
 	// Initialize database
-	log.Println("DEBUG: Initializing database...")
+	slog.Debug("Initializing database")
 	database.InitDatabase()
-	log.Println("DEBUG: Database initialized successfully")
+	slog.Debug("Database initialized")
+
+	// Phone numbers, OTP/reset tokens and session data are encrypted with data keys stored in the database
+	if err := services.InitFieldEncryption(repos.DataKeys, fieldMasterKeys); err != nil {
+		fatal("Failed to load field encryption keys", err)
+	}
+
+	// "encrypt-backfill" encrypts the rows written before field encryption, rewraps the data keys
+	// with the active master key and exits
+	if len(os.Args) > 1 && os.Args[1] == "encrypt-backfill" {
+		summary, err := services.BackfillFieldEncryption(repos.DataKeys, fieldMasterKeys)
+		if err != nil {
+			fatal("Field encryption backfill failed", err)
+		}
+		slog.Info("Field encryption backfill completed", "rewrapped_keys", summary.RewrappedKeys, "rows", summary.Rows)
+		return
+	}
+
+	// Watch the database and switch to read-only mode while it is unreachable
+	database.StartHealthMonitor()
+
+	// Pick up rotated secrets (SECRETS_REFRESH_SECONDS). Xendit keys and SMTP credentials are read
+	// per use; JWT keys and the database pool are reloaded here.
+	secrets.OnChange(func(changed []string) {
+		var jwtChanged, dbChanged bool
+		for _, name := range changed {
+			jwtChanged = jwtChanged || strings.HasPrefix(name, "JWT_")
+			dbChanged = dbChanged || strings.HasPrefix(name, "DB_")
+		}
+		if jwtChanged {
+			if err := services.ReloadJWTKeys(); err != nil {
+				slog.Error("Failed to reload JWT keys after secret rotation", "error", err)
+			}
+		}
+		if dbChanged {
+			if err := database.Reopen(); err != nil {
+				slog.Error("Failed to reopen database after secret rotation", "error", err)
+			}
+		}
+	})
+	secrets.StartRefreshWorker()
+
+	// Persist analysis results spooled to disk while the database was unavailable
+	services.StartAnalysisSpoolWorker(services.NewAnalysisService(repos))
+
+	// Hard delete analyses removed by a bulk delete once their undo window has passed
+	services.StartAnalysisPurgeWorker(services.NewAnalysisService(repos))
+
+	// Warn, then anonymize accounts inactive longer than the retention policy (INACTIVE_ACCOUNT_MONTHS)
+	services.StartInactiveAccountWorker(repos, services.LoadInactiveAccountPolicy())
+
+	// Retry outbound webhook deliveries that failed (WEBHOOK_RETRY_SECONDS)
+	services.StartWebhookRetryWorker(repos.Webhooks)
+
+	// Weekly/monthly email digests for users who opted in through their notification preferences
+	services.StartDigestWorker(repos, services.LoadDigestPolicy())
 
 	// Initialize user handler
-	userHandler := handlers.NewUserHandler()
+	userHandler := handlers.NewUserHandler(repos)
 
 	// Initialize multi-user WhatsApp handler
-	waHandler := whatsapp.NewMultiUserWhatsAppHandler()
+	waHandler := whatsapp.NewMultiUserWhatsAppHandler(repos)
+
+	// Dedicated system number for OTPs sent by WhatsApp (WA_OTP_SENDER_ENABLED)
+	go whatsapp.StartOTPSender()
 
 	// Initialize payment handler
-	paymentService := services.NewPaymentService(database.GetDB())
-	paymentHandler := handlers.NewPaymentHandler(paymentService)
-	webhookHandler := handlers.NewWebhookHandler(paymentService)
+	paymentService := services.NewPaymentService(repos)
+	paymentHandler := handlers.NewPaymentHandler(repos, paymentService)
+	webhookHandler := handlers.NewWebhookHandler(repos, paymentService)
+	webhookEventHandler := handlers.NewWebhookEventHandler(repos, paymentService)
+
+	// Settle transactions left pending by a missed webhook (PAYMENT_RECONCILE_MINUTES)
+	services.StartPaymentReconcileWorker(paymentService, services.LoadPaymentReconcilePolicy())
+
+	// Initialize tenant (white-label) handler
+	tenantHandler := handlers.NewTenantHandler(repos)
+
+	// Initialize legal documents / consent handler
+	legalHandler := handlers.NewLegalHandler(repos)
+
+	// Initialize signed share link handler
+	shareHandler := handlers.NewShareHandler(repos)
+
+	// Initialize public checksum verification handler
+	verifyHandler := handlers.NewVerifyHandler(repos)
+
+	// Initialize JWKS handler (public keys for RS256/EdDSA tokens)
+	jwksHandler := handlers.NewJWKSHandler()
+
+	// Initialize notification center handler
+	notificationHandler := handlers.NewNotificationHandler(repos)
+
+	// Initialize user usage handler
+	usageHandler := handlers.NewUsageHandler(repos)
+
+	// Initialize push notification handler
+	pushHandler := handlers.NewPushHandler(repos)
+
+	// Initialize account goal handler
+	goalHandler := handlers.NewGoalHandler(repos)
+
+	// Initialize outbound webhook handler
+	userWebhookHandler := handlers.NewUserWebhookHandler(repos)
+
+	// Initialize legal hold (admin) handler
+	legalHoldHandler := handlers.NewLegalHoldHandler(repos)
+
+	// Initialize data access request (admin) handler
+	dataAccessHandler := handlers.NewDataAccessHandler(repos)
+
+	// Initialize account merge (admin) handler
+	accountMergeHandler := handlers.NewAccountMergeHandler(repos)
+
+	// Initialize admin (users, transactions, sessions) handler
+	adminHandler := handlers.NewAdminHandler(repos)
+
+	// Initialize scoring configuration (admin) handler
+	scoringHandler := handlers.NewScoringHandler(repos)
+
+	// Initialize product limits handler
+	limitsHandler := handlers.NewLimitsHandler(repos)
+
+	// Initialize admin metrics handler
+	metricsHandler := handlers.NewMetricsHandler(repos)
+
+	// Initialize analysis feedback handler
+	feedbackHandler := handlers.NewFeedbackHandler(repos)
+
+	// Initialize announcement handler
+	announcementHandler := handlers.NewAnnouncementHandler(repos)
+
+	// Initialize subscription handler
+	subscriptionHandler := handlers.NewSubscriptionHandler(repos, paymentService)
+
+	// Initialize coupon handler
+	couponHandler := handlers.NewCouponHandler(repos)
+
+	// Initialize payment category handler
+	paymentCategoryHandler := handlers.NewPaymentCategoryHandler(repos)
+
+	// Initialize partner usage handler
+	partnerHandler := handlers.NewPartnerHandler(repos)
 
 	r := mux.NewRouter()
 
 	// User management endpoints
 	r.HandleFunc("/api/auth/register", userHandler.Register).Methods("POST")
 	r.HandleFunc("/api/auth/login", userHandler.Login).Methods("POST")
+	r.HandleFunc("/api/auth/logout", userHandler.Logout).Methods("POST")
 	r.HandleFunc("/api/auth/check-phone", userHandler.CheckPhoneNumber).Methods("GET")
 	r.HandleFunc("/api/auth/profile", userHandler.GetProfile).Methods("GET")
+	r.HandleFunc("/api/auth/session", userHandler.GetSession).Methods("GET")
+	r.HandleFunc("/api/auth/scoped-token", userHandler.CreateScopedToken).Methods("POST")
+	r.HandleFunc("/api/auth/sessions", userHandler.ListSessions).Methods("GET")
+	r.HandleFunc("/api/auth/sessions", userHandler.RevokeSessions).Methods("DELETE")
+	r.HandleFunc("/api/auth/sessions/{id:[0-9]+}", userHandler.RevokeSession).Methods("DELETE")
 	// OTP & Password reset
 	r.HandleFunc("/api/auth/send-otp", userHandler.SendOTP).Methods("POST")
 	r.HandleFunc("/api/auth/verify-otp", userHandler.VerifyOTP).Methods("POST")
 	r.HandleFunc("/api/auth/forgot-password", userHandler.ForgotPassword).Methods("POST")
 	r.HandleFunc("/api/auth/reset-password", userHandler.ResetPassword).Methods("POST")
+	r.HandleFunc("/api/auth/unlock-account", userHandler.UnlockAccount).Methods("POST")
 	// Analysis endpoints
 	r.HandleFunc("/api/analysis/history", userHandler.GetAnalysisHistory).Methods("GET")
 	// Register static and collection routes BEFORE parameterized routes to avoid conflicts
 	r.HandleFunc("/api/analysis", userHandler.DeleteAllAnalyses).Methods("DELETE")
 	r.HandleFunc("/api/analysis/bulk", userHandler.DeleteAnalysesBulk).Methods("DELETE")
+	r.HandleFunc("/api/analysis/undo-delete", userHandler.UndoDeleteAnalyses).Methods("POST")
+	r.HandleFunc("/api/analysis/simulate", scoringHandler.SimulateAnalysis).Methods("POST")
+	r.HandleFunc("/api/analysis/scoring-rules/history", scoringHandler.GetScoringHistory).Methods("GET")
+	r.HandleFunc("/api/analysis/share/{link_id}", shareHandler.RevokeShareLink).Methods("DELETE")
+	r.HandleFunc("/api/analysis/{id}/share", shareHandler.CreateShareLink).Methods("POST")
+	r.HandleFunc("/api/analysis/{id}/feedback", feedbackHandler.SubmitFeedback).Methods("POST")
 	r.HandleFunc("/api/analysis/{id}", userHandler.GetAnalysisDetail).Methods("GET")
+	r.HandleFunc("/api/analysis/{id}", userHandler.UpdateAnalysis).Methods("PATCH")
 	r.HandleFunc("/api/analysis/{id}", userHandler.DeleteAnalysis).Methods("DELETE")
 
 	// User settings endpoints
 	r.HandleFunc("/api/user/change-password", userHandler.ChangePassword).Methods("POST")
 	r.HandleFunc("/api/user/change-username", userHandler.ChangeUsername).Methods("POST")
+	r.HandleFunc("/api/user/otp-channel", userHandler.OTPChannel).Methods("GET", "PUT")
+	r.HandleFunc("/api/user/account", waHandler.HandleDeleteAccount).Methods("DELETE")
+
+	// Own API usage, scans and credits this month
+	r.HandleFunc("/api/user/usage", usageHandler.GetUsage).Methods("GET")
+
+	// Limits of the user's tier (sessions, scans per day, history size) with current usage
+	r.HandleFunc("/api/user/limits", limitsHandler.GetUserLimits).Methods("GET")
+
+	// Announcement banners for the frontend (public; Bearer token adds the user's tier)
+	r.HandleFunc("/api/announcements", announcementHandler.ListCurrent).Methods("GET")
+
+	// Notification center endpoints
+	r.HandleFunc("/api/user/notifications", notificationHandler.ListNotifications).Methods("GET")
+	r.HandleFunc("/api/user/notifications/read", notificationHandler.MarkNotificationsRead).Methods("POST")
+
+	// Push notification endpoints
+	r.HandleFunc("/api/user/push-tokens", pushHandler.RegisterPushToken).Methods("POST")
+	r.HandleFunc("/api/user/push-tokens", pushHandler.DeletePushToken).Methods("DELETE")
+	r.HandleFunc("/api/user/push-preferences", pushHandler.GetPushPreferences).Methods("GET")
+	r.HandleFunc("/api/user/push-preferences", pushHandler.UpdatePushPreferences).Methods("PUT")
+	r.HandleFunc("/api/user/goals", goalHandler.Goals).Methods("GET", "POST", "DELETE")
+
+	// Outbound webhook endpoints
+	r.HandleFunc("/api/user/webhook", userWebhookHandler.GetWebhook).Methods("GET")
+	r.HandleFunc("/api/user/webhook", userWebhookHandler.SaveWebhook).Methods("PUT")
+	r.HandleFunc("/api/user/webhook", userWebhookHandler.DeleteWebhook).Methods("DELETE")
+	r.HandleFunc("/api/user/webhook/deliveries", userWebhookHandler.ListDeliveries).Methods("GET")
 
 	// WhatsApp endpoints (multi-user)
 	r.HandleFunc("/api/wa/qr", waHandler.HandleQR).Methods("GET")
 	r.HandleFunc("/api/wa/status", waHandler.HandleStatus).Methods("GET")
+	r.HandleFunc("/api/wa/state", waHandler.HandleState).Methods("GET")
+	r.HandleFunc("/api/wa/entitlement", waHandler.HandleEntitlement).Methods("GET")
 	r.HandleFunc("/api/wa/analyze", waHandler.HandleAnalyze).Methods("GET")
+	r.HandleFunc("/api/wa/analyze", waHandler.HandleAnalyzeAsync).Methods("POST")
+	r.HandleFunc("/api/wa/analyze/jobs/{id}", waHandler.HandleAnalyzeJob).Methods("GET")
 	r.HandleFunc("/api/wa/analyze/force", waHandler.HandleForceAnalysis).Methods("POST")
 	r.HandleFunc("/api/wa/logout", waHandler.HandleLogout).Methods("POST")
 	r.HandleFunc("/api/wa/qr/refresh", waHandler.HandleRefreshQR).Methods("POST")
+	r.HandleFunc("/api/wa/pair", waHandler.HandlePair).Methods("POST")
+	r.HandleFunc("/api/wa/message-scan", waHandler.HandleMessageScan).Methods("GET", "PUT")
+	r.HandleFunc("/api/wa/schedule", waHandler.HandleScanSchedule).Methods("GET", "PUT", "DELETE")
+	r.HandleFunc("/api/orgs/{id}/bulk-scan", waHandler.HandleStartBulkScan).Methods("POST")
+	r.HandleFunc("/api/orgs/{id}/bulk-scan/{scan_id}", waHandler.HandleBulkScanStatus).Methods("GET")
 	r.HandleFunc("/api/wa/debug", waHandler.HandleDebug).Methods("GET")
 	r.HandleFunc("/api/wa/reconnect", waHandler.HandleManualReconnect).Methods("POST")
 
 	// Payment endpoints
 	r.HandleFunc("/api/payments/create", paymentHandler.CreatePayment).Methods("POST")
+	r.HandleFunc("/api/payments/check", paymentHandler.CheckPayment).Methods("GET")
 	r.HandleFunc("/api/payments/{external_id}/status", paymentHandler.GetPaymentStatus).Methods("GET")
 	r.HandleFunc("/api/transactions", paymentHandler.GetTransactionHistory).Methods("GET")
+	if services.IsDevelopment() {
+		// Pays a pending transaction without the gateway, for frontend and end-to-end testing
+		r.HandleFunc("/api/dev/simulate-payment", paymentHandler.SimulatePayment).Methods("POST")
+	}
+	r.HandleFunc("/api/plans", subscriptionHandler.ListPlans).Methods("GET")
+	r.HandleFunc("/api/coupons/validate", couponHandler.ValidateCoupon).Methods("POST")
+	r.HandleFunc("/api/subscriptions", subscriptionHandler.Subscribe).Methods("POST")
+	r.HandleFunc("/api/subscriptions/current", subscriptionHandler.GetCurrent).Methods("GET")
 
 	// Webhook endpoints
 	r.HandleFunc("/api/webhooks/xendit", webhookHandler.HandleXenditWebhook).Methods("POST")
+	r.HandleFunc("/api/webhooks/midtrans", webhookHandler.HandleMidtransWebhook).Methods("POST")
 	r.HandleFunc("/api/webhooks/test", webhookHandler.HandleWebhookTest).Methods("GET")
 
+	// Public signed links (no login, signature is the credential)
+	r.HandleFunc("/api/shared/analysis/{link_id}", shareHandler.GetSharedAnalysis).Methods("GET")
+	r.HandleFunc("/api/public/verify/{checksum}", verifyHandler.VerifyChecksum).Methods("GET")
+
+	// Public keys for validating tokens outside this service
+	r.HandleFunc("/.well-known/jwks.json", jwksHandler.GetJWKS).Methods("GET")
+	r.HandleFunc("/api/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(api.OpenAPISpec)
+	}).Methods("GET")
+
+	// Tenant branding endpoint
+	r.HandleFunc("/api/tenant/branding", tenantHandler.GetBranding).Methods("GET")
+
+	// Legal documents and consent
+	r.HandleFunc("/api/legal", legalHandler.GetDocuments).Methods("GET")
+	r.HandleFunc("/api/legal/consent", legalHandler.GetConsent).Methods("GET")
+	r.HandleFunc("/api/legal/consent", legalHandler.AcceptConsent).Methods("POST")
+
+	// Admin legal hold endpoints
+	r.HandleFunc("/api/admin/analysis/{id}/hold", legalHoldHandler.SetAnalysisHold).Methods("POST")
+	r.HandleFunc("/api/admin/analysis/{id}/hold", legalHoldHandler.ReleaseAnalysisHold).Methods("DELETE")
+	r.HandleFunc("/api/admin/transactions/{id}/hold", legalHoldHandler.SetTransactionHold).Methods("POST")
+	r.HandleFunc("/api/admin/transactions/{id}/hold", legalHoldHandler.ReleaseTransactionHold).Methods("DELETE")
+	r.HandleFunc("/api/admin/users", adminHandler.ListUsers).Methods("GET")
+	r.HandleFunc("/api/admin/users/{id:[0-9]+}/deactivate", adminHandler.DeactivateUser).Methods("POST")
+	r.HandleFunc("/api/admin/users/{id:[0-9]+}/activate", adminHandler.ActivateUser).Methods("POST")
+	r.HandleFunc("/api/admin/users/{id:[0-9]+}/analyses", adminHandler.UserAnalyses).Methods("GET")
+	r.HandleFunc("/api/admin/users/{id:[0-9]+}/lockout", adminHandler.UserLockout).Methods("GET", "DELETE")
+	r.HandleFunc("/api/admin/transactions", adminHandler.ListTransactions).Methods("GET")
+	r.HandleFunc("/api/admin/data-access-requests", dataAccessHandler.ListRequests).Methods("GET")
+	r.HandleFunc("/api/admin/data-access-requests", dataAccessHandler.CreateRequest).Methods("POST")
+	r.HandleFunc("/api/admin/data-access-requests/{id:[0-9]+}/revoke", dataAccessHandler.RevokeRequest).Methods("POST")
+	r.HandleFunc("/api/admin/data-access-requests/{id:[0-9]+}/log", dataAccessHandler.GetAuditLog).Methods("GET")
+	r.HandleFunc("/api/admin/sessions", adminHandler.ListSessions).Methods("GET")
+	r.HandleFunc("/api/admin/sessions/{user_id:[0-9]+}/disconnect", waHandler.HandleAdminDisconnect).Methods("POST")
+	r.HandleFunc("/api/admin/session-stores/orphans", waHandler.HandleOrphanStores).Methods("GET", "POST")
+	r.HandleFunc("/api/admin/scoring", scoringHandler.GetScoringConfig).Methods("GET")
+	r.HandleFunc("/api/admin/scoring", scoringHandler.UpdateScoringConfig).Methods("PUT")
+	r.HandleFunc("/api/admin/limits", limitsHandler.ListLimits).Methods("GET")
+	r.HandleFunc("/api/admin/limits/{tier}", limitsHandler.UpdateLimits).Methods("PUT")
+	r.HandleFunc("/api/admin/feedback/summary", feedbackHandler.GetSummary).Methods("GET")
+	r.HandleFunc("/api/admin/reports/churn", adminHandler.ChurnReport).Methods("GET")
+	r.HandleFunc("/api/admin/log-redaction", adminHandler.LogRedaction).Methods("GET", "PUT")
+	r.HandleFunc("/api/admin/plans", subscriptionHandler.AdminListPlans).Methods("GET")
+	r.HandleFunc("/api/admin/plans", subscriptionHandler.CreatePlan).Methods("POST")
+	r.HandleFunc("/api/admin/plans/{id:[0-9]+}", subscriptionHandler.UpdatePlan).Methods("PUT")
+	r.HandleFunc("/api/admin/coupons", couponHandler.ListCoupons).Methods("GET")
+	r.HandleFunc("/api/admin/coupons", couponHandler.CreateCoupon).Methods("POST")
+	r.HandleFunc("/api/admin/coupons/{id:[0-9]+}", couponHandler.UpdateCoupon).Methods("PUT")
+	r.HandleFunc("/api/admin/payment-categories", paymentCategoryHandler.ListCategories).Methods("GET")
+	r.HandleFunc("/api/admin/payment-categories", paymentCategoryHandler.CreateCategory).Methods("POST")
+	r.HandleFunc("/api/admin/payment-categories/{id:[0-9]+}", paymentCategoryHandler.UpdateCategory).Methods("PUT")
+	r.HandleFunc("/api/admin/payment-categories/{id:[0-9]+}", paymentCategoryHandler.DeleteCategory).Methods("DELETE")
+	r.HandleFunc("/api/admin/announcements", announcementHandler.AdminList).Methods("GET")
+	r.HandleFunc("/api/admin/announcements", announcementHandler.Create).Methods("POST")
+	r.HandleFunc("/api/admin/announcements/{id:[0-9]+}", announcementHandler.Update).Methods("PUT")
+	r.HandleFunc("/api/admin/announcements/{id:[0-9]+}", announcementHandler.Delete).Methods("DELETE")
+	r.HandleFunc("/api/admin/users/merge", accountMergeHandler.MergeAccounts).Methods("POST")
+	r.HandleFunc("/api/admin/users/merges", accountMergeHandler.ListMerges).Methods("GET")
+	r.HandleFunc("/api/admin/users/merges/{id}/rollback", accountMergeHandler.RollbackMerge).Methods("POST")
+
+	// Admin payment reconciliation
+	r.HandleFunc("/api/admin/payments/reconcile", paymentHandler.ReconcilePending).Methods("POST")
+	r.HandleFunc("/api/admin/payments/{external_id}/refund", paymentHandler.RefundPayment).Methods("POST")
+	r.HandleFunc("/api/admin/payments/{external_id}/refunds", paymentHandler.ListRefunds).Methods("GET")
+
+	// Admin inbound payment webhook event log
+	r.HandleFunc("/api/admin/webhook-events", webhookEventHandler.ListEvents).Methods("GET")
+	r.HandleFunc("/api/admin/webhook-events/{id:[0-9]+}", webhookEventHandler.GetEvent).Methods("GET")
+	r.HandleFunc("/api/admin/webhook-events/{id:[0-9]+}/reprocess", webhookEventHandler.ReprocessEvent).Methods("POST")
+
+	// Admin analysis latency metrics
+	r.HandleFunc("/api/admin/metrics/analysis", metricsHandler.GetAnalysisMetrics).Methods("GET")
+	r.HandleFunc("/api/admin/metrics/whatsapp", waHandler.HandleRateLimitMetrics).Methods("GET")
+	r.HandleFunc("/api/admin/metrics/database", metricsHandler.GetDatabaseMetrics).Methods("GET")
+	r.HandleFunc("/api/admin/selfcheck", metricsHandler.GetSelfCheck).Methods("GET")
+
+	// Partner usage metering endpoints
+	r.HandleFunc("/api/partner/usage", partnerHandler.GetUsage).Methods("GET")
+	r.HandleFunc("/api/partner/usage/export", partnerHandler.ExportUsage).Methods("GET")
+	r.HandleFunc("/api/admin/usage/export", partnerHandler.ExportUsage).Methods("GET")
+
 	// Health check endpoint
 	r.HandleFunc("/api/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
+		if health := database.Health(); health.Degraded {
+			// Still 200: the API is up and serving reads
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status":   "degraded",
+				"message":  "Database unavailable, API is read-only",
+				"database": health,
+			})
+			return
+		}
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(`{"status":"ok","message":"Backend is running"}`))
 	}).Methods("GET")
 
+	// Tag every request with X-Request-ID so its log lines can be correlated
+	r.Use(middleware.RequestID)
+
+	// Reject admin/webhook calls from outside the configured CIDRs before any other work
+	r.Use(adminAllowList.Middleware())
+	r.Use(webhookAllowList.Middleware())
+
+	// Throttle login/OTP/registration per client IP and /api/wa/* per user (429 + Retry-After)
+	rateLimiter, err := middleware.NewRateLimiter(services.NewAuthService(repos.Users, repos.AuthSessions), middleware.LoadRateLimitRules())
+	if err != nil {
+		fatal("Invalid rate limit configuration", err)
+	}
+	r.Use(rateLimiter.Middleware())
+
+	// Reject writes with 503 + Retry-After while the database is down
+	r.Use(middleware.ReadOnlyWhenDegraded())
+
+	// Scope every request to its tenant (X-API-Key or Host)
+	r.Use(middleware.TenantScope(services.NewTenantService(repos.Tenants)))
+	r.Use(middleware.PartnerUsage(services.NewUsageService(repos.Usage, repos.Transactions)))
+	// Optional cookie sessions (AUTH_MODE=cookie) with CSRF checks on state-changing routes
+	r.Use(middleware.CookieSession(services.LoadSessionCookieConfig()))
+	// Tokens of revoked sessions (logout, device removed, password changed) get 401 session_revoked
+	r.Use(middleware.RejectRevokedSessions(services.NewAuthService(repos.Users, repos.AuthSessions)))
+	// Per-account request counts for GET /api/user/usage
+	r.Use(middleware.UserUsage(services.NewAuthService(repos.Users, repos.AuthSessions), services.NewUsageService(repos.Usage, repos.Transactions)))
+	// Tokens only reach the route groups they were scoped to at login
+	r.Use(middleware.RequireScopes(services.NewAuthService(repos.Users, repos.AuthSessions),
+		middleware.ScopeRule{Prefix: "/api/wa/", Scope: services.ScopeWA},
+		middleware.ScopeRule{Prefix: "/api/payments/", Scope: services.ScopePayments},
+		middleware.ScopeRule{Prefix: "/api/transactions", Scope: services.ScopePayments},
+		middleware.ScopeRule{Prefix: "/api/coupons/", Scope: services.ScopePayments},
+		middleware.ScopeRule{Prefix: "/api/dev/simulate-payment", Scope: services.ScopePayments},
+		middleware.ScopeRule{Prefix: "/api/admin/", Scope: services.ScopeAdmin},
+	))
+	// Every /api/admin/* route requires an admin account token
+	r.Use(middleware.RequireRole(services.NewAuthService(repos.Users, repos.AuthSessions), "/api/admin/", "admin"))
+	// Viewing one user's analyses or transactions needs an open data access request, and is audited
+	r.Use(middleware.RequireDataAccess(services.NewDataAccessService(repos.DataAccess, repos.Users),
+		middleware.DataAccessRule{Route: "/api/admin/users/{id:[0-9]+}/analyses", UserParam: "id"},
+		middleware.DataAccessRule{Route: "/api/admin/transactions", UserParam: "user_id"},
+	))
+
 	// Apply CORS middleware
 	handler := corsMiddleware(r)
 
-	log.Println("🚀 WhatsApp Defender Backend started on :9090")
-	log.Println("📡 Available endpoints:")
-	log.Println("   🔐 AUTH:")
-	log.Println("      POST /api/auth/register     - User registration")
-	log.Println("      POST /api/auth/login        - User login")
-	log.Println("      GET  /api/auth/check-phone  - Check phone number")
-	log.Println("      GET  /api/auth/profile      - Get user profile")
-	log.Println("   📱 WHATSAPP:")
-	log.Println("      GET  /api/wa/qr             - Get QR code")
-	log.Println("      GET  /api/wa/status         - Get WhatsApp status")
-	log.Println("      GET  /api/wa/analyze        - Analyze WhatsApp data")
-	log.Println("      POST /api/wa/analyze/force  - Force analysis")
-	log.Println("      POST /api/wa/logout         - Logout WhatsApp")
-	log.Println("      POST /api/wa/qr/refresh     - Refresh QR code")
-	log.Println("      GET  /api/wa/debug          - Debug status")
-	log.Println("      POST /api/wa/reconnect      - Manual reconnect")
-	log.Println("   💳 PAYMENT:")
-	log.Println("      POST /api/payments/create   - Create payment")
-	log.Println("      GET  /api/payments/{id}/status - Get payment status")
-	log.Println("      GET  /api/transactions     - Get transaction history")
-	log.Println("   🔗 WEBHOOK:")
-	log.Println("      POST /api/webhooks/xendit   - Xendit webhook")
-	log.Println("      GET  /api/webhooks/test     - Test webhook")
-
-	log.Fatal(http.ListenAndServe(":9090", handler))
+	if tlsConfig.Enabled() {
+		slog.Info(fmt.Sprintf("WhatsApp Defender Backend started on %s (TLS: %s)", tlsConfig.Addr, tlsConfig.Mode))
+	} else {
+		slog.Info("WhatsApp Defender Backend started on :9090")
+	}
+	// The endpoint list is logged at debug level; README.md documents every endpoint
+	logEndpoints("auth",
+		"POST /api/auth/register - User registration",
+		"POST /api/auth/login - User login (429 backoff / 423 locked after failed attempts)",
+		"POST /api/auth/unlock-account - Lift a lockout with the emailed link",
+		"POST /api/auth/logout - Revoke the session and clear session cookies",
+		"GET /api/auth/check-phone - Check phone number",
+		"GET /api/auth/profile - Get user profile",
+		"GET /api/auth/session - Token expiry, scopes, refresh hint",
+		"POST /api/auth/scoped-token - Short-lived wa:qr token for embeds",
+		"GET /api/auth/sessions - Signed-in devices",
+		"DELETE /api/auth/sessions - Sign out other devices (?include_current=true for all)",
+		"DELETE /api/auth/sessions/{id} - Sign out one device",
+		"GET /api/user/usage - Own API calls, scans and credits this month",
+		"GET /api/user/limits - Sessions, scans per day and history size of the user's tier",
+		"GET/PUT /api/user/otp-channel - OTP delivery by email, WhatsApp or SMS",
+		"DELETE /api/user/account - Delete the account and purge its data (password or OTP)",
+		"GET /api/announcements - Current banners (public, tier-targeted with token)",
+	)
+	logEndpoints("notifications",
+		"GET /api/user/notifications - List notifications",
+		"POST /api/user/notifications/read - Mark notifications read",
+		"POST/DELETE /api/user/push-tokens - Register/remove push device",
+		"GET/PUT /api/user/push-preferences - Push toggles per event, email digest",
+		"GET/POST/DELETE /api/user/goals - Strength and parameter goals with progress",
+		"GET/PUT/DELETE /api/user/webhook - Signed analysis.completed webhook",
+		"GET /api/user/webhook/deliveries - Webhook delivery log",
+	)
+	logEndpoints("whatsapp",
+		"GET /api/wa/qr - Get QR code",
+		"GET /api/wa/status - Get WhatsApp status",
+		"GET /api/wa/state - Scan page state in one call",
+		"GET /api/wa/entitlement?phone= - Whether a number can be analyzed, before connecting",
+		"GET /api/wa/analyze - Analyze WhatsApp data",
+		"POST /api/wa/analyze - Queue analysis job (202 + job_id)",
+		"GET /api/wa/analyze/jobs/{id} - Analysis job progress/result",
+		"POST /api/wa/analyze/force - Force analysis",
+		"POST /api/wa/logout - Logout WhatsApp",
+		"POST /api/wa/qr/refresh - Refresh QR code",
+		"POST /api/wa/pair - Pairing code login (alternative to QR)",
+		"GET/PUT /api/wa/message-scan - Consent to sensitive content scanning of messages",
+		"GET/PUT/DELETE /api/wa/schedule - Weekly/monthly automatic re-scan",
+		"POST /api/orgs/{id}/bulk-scan - Analyze all connected member sessions (org owner)",
+		"GET /api/orgs/{id}/bulk-scan/{scan_id} - Bulk scan progress and consolidated report",
+		"GET /api/wa/debug - Debug status (JID masked, admins: ?reveal=true)",
+		"POST /api/wa/reconnect - Manual reconnect",
+	)
+	logEndpoints("payment",
+		"POST /api/payments/create - Create payment",
+		"GET /api/payments/check - Paywall precheck by phone",
+		"GET /api/payments/{id}/status - Get payment status",
+		"GET /api/transactions - Get transaction history",
+		"GET /api/plans - Subscription plans",
+		"POST /api/coupons/validate - Check a promo code and its discount",
+		"POST /api/subscriptions - Subscribe to a plan (returns the invoice)",
+		"GET /api/subscriptions/current - Active subscription and scans left this month",
+	)
+	if services.IsDevelopment() {
+		logEndpoints("payment", "POST /api/dev/simulate-payment - Mark a pending transaction paid (development only)")
+	}
+	logEndpoints("share",
+		"POST /api/analysis/simulate - Score user-supplied parameters (calculator, no WhatsApp)",
+		"GET /api/analysis/scoring-rules/history - Scoring rule versions and what changed",
+		"PATCH /api/analysis/{id} - Pin/unpin and label a result (pinned survive bulk deletes)",
+		"POST /api/analysis/undo-delete - Restore a bulk delete within 30 seconds",
+		"POST /api/analysis/{id}/share - Create signed result link",
+		"POST /api/analysis/{id}/feedback - Rate an analysis result (1-5 + comment)",
+		"DELETE /api/analysis/share/{link_id} - Revoke signed link",
+		"GET /api/shared/analysis/{link_id} - Public signed access (JSON/PDF)",
+		"GET /api/public/verify/{checksum} - Verify report checksum",
+	)
+	logEndpoints("keys",
+		"GET /.well-known/jwks.json - JWT public keys (RS256/EdDSA)",
+		"GET /api/openapi.json - OpenAPI spec of the SDK endpoints",
+	)
+	logEndpoints("webhook",
+		"POST /api/webhooks/xendit - Xendit webhook",
+		"POST /api/webhooks/midtrans - Midtrans payment notification",
+		"GET /api/webhooks/test - Test webhook",
+	)
+	logEndpoints("tenant",
+		"GET /api/tenant/branding - White-label branding",
+	)
+	logEndpoints("legal",
+		"GET /api/legal - Current ToS / privacy policy versions",
+		"GET/POST /api/legal/consent - Consent status / accept current versions",
+	)
+	logEndpoints("admin",
+		"POST/DELETE /api/admin/analysis/{id}/hold - Set/release legal hold",
+		"POST/DELETE /api/admin/transactions/{id}/hold - Set/release legal hold",
+		"GET /api/admin/users - List users (?q=, role, active, page, limit)",
+		"POST /api/admin/users/{id}/deactivate - Deactivate user (activate to undo)",
+		"GET /api/admin/transactions - All transactions (?status=, user_id; user_id needs a data access request)",
+		"GET /api/admin/users/{id}/analyses - A user's analyses (needs a data access request)",
+		"GET/DELETE /api/admin/users/{id}/lockout - Failed-login state / clear lockout",
+		"GET/POST /api/admin/data-access-requests - List/open data access requests (reason, duration)",
+		"POST /api/admin/data-access-requests/{id}/revoke - End a data access request early",
+		"GET /api/admin/data-access-requests/{id}/log - Requests served under a data access request",
+		"GET /api/admin/sessions - All WhatsApp sessions (?status=)",
+		"POST /api/admin/sessions/{user_id}/disconnect - Force-disconnect a WhatsApp session",
+		"GET/POST /api/admin/session-stores/orphans - List (dry run) / remove orphaned session store files",
+		"GET/PUT /api/admin/scoring - Read/update scoring thresholds and weights",
+		"GET /api/admin/limits - Product limits per tier (trial, paid, plan codes)",
+		"PUT /api/admin/limits/{tier} - Set max sessions, scans per day and history size of a tier",
+		"GET /api/admin/feedback/summary - Average feedback rating per strength band",
+		"GET /api/admin/reports/churn - NPS, scan frequency, renewals and at-risk users (JSON/CSV)",
+		"GET/PUT /api/admin/log-redaction - Phone number masking in logs (temporary reveal)",
+		"GET/POST /api/admin/plans - List/create subscription plans",
+		"PUT /api/admin/plans/{id} - Edit a plan (running subscriptions keep their quota)",
+		"GET/POST /api/admin/coupons - List/create promo codes",
+		"PUT /api/admin/coupons/{id} - Edit or deactivate a promo code",
+		"GET/POST /api/admin/payment-categories - List/create payment categories and prices",
+		"PUT/DELETE /api/admin/payment-categories/{id} - Reprice, deactivate or remove a category",
+		"GET/POST /api/admin/announcements - List/publish announcement banners",
+		"PUT/DELETE /api/admin/announcements/{id} - Edit/remove an announcement",
+		"POST /api/admin/users/merge - Merge duplicate account into another",
+		"GET /api/admin/users/merges - Account merge audit log",
+		"POST /api/admin/users/merges/{id}/rollback - Roll back an account merge",
+		"POST /api/admin/payments/reconcile - Reconcile pending transactions with their gateway",
+		"POST /api/admin/payments/{external_id}/refund - Refund a paid Xendit transaction",
+		"GET /api/admin/payments/{external_id}/refunds - Refunds of a transaction (pending ones refreshed)",
+		"GET /api/admin/webhook-events - Received Xendit webhooks (?state=, external_id=, page, limit)",
+		"GET /api/admin/webhook-events/{id} - Webhook event with its raw payload",
+		"POST /api/admin/webhook-events/{id}/reprocess - Apply a stored webhook event again",
+		"GET /api/admin/metrics/analysis - Analysis stage latency (p50/p95), SLO status and serving cost",
+		"GET /api/admin/metrics/whatsapp - whatsmeow rate limiter counters, queues and analysis job lanes",
+		"GET /api/admin/metrics/database - Connection pool stats (in use, idle, waits) and DB health",
+		"GET /api/admin/selfcheck - Config, DB, Xendit, SMTP and session directory checks",
+	)
+	logEndpoints("partner",
+		"GET /api/partner/usage - Monthly API usage",
+		"GET /api/partner/usage/export - Usage invoice export (CSV/JSON)",
+		"GET /api/admin/usage/export - Usage invoice export of every tenant (admin)",
+	)
+	// SIGINT/SIGTERM stop accepting requests and drain the in-flight ones
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	drain := server.ShutdownTimeout()
+	if err := tlsConfig.ListenAndServe(ctx, ":9090", handler, drain); err != nil {
+		if ctx.Err() == nil {
+			fatal("HTTP server failed", err)
+		}
+		slog.Warn("HTTP server did not shut down cleanly", "error", err)
+	}
+
+	// Then disconnect WhatsApp clients, flush pending writes and close the database
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	waHandler.Shutdown(shutdownCtx)
+	services.ReplayAnalysisSpool(services.NewAnalysisService(repos))
+	if err := database.Close(); err != nil {
+		slog.Warn("Failed to close database", "error", err)
+	}
+	slog.Info("Server stopped")
+}
+
+// fatal logs err and exits, for startup failures the server cannot run without
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// logEndpoints logs each "METHOD /path - description" entry of a group at debug level
+func logEndpoints(group string, endpoints ...string) {
+	for _, endpoint := range endpoints {
+		route, description, _ := strings.Cut(endpoint, " - ")
+		slog.Debug("Endpoint", "group", group, "route", route, "description", description)
+	}
 }
@@ -0,0 +1,602 @@
+// Command sdkgen generates the Go and TypeScript client SDKs from api/openapi.json:
+// typed models for every component schema and one client method per operation. The HTTP plumbing
+// (auth header, error decoding) lives in hand-written files next to the generated ones.
+//
+//	go run ./tools/sdkgen -spec api/openapi.json -go ../sdk/go -ts ../sdk/ts/src
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Schema is the subset of an OpenAPI schema object the generator understands
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Description          string             `json:"description"`
+	Enum                 []string           `json:"enum"`
+	Nullable             bool               `json:"nullable"`
+	Items                *Schema            `json:"items"`
+	Properties           map[string]*Schema `json:"properties"`
+	AdditionalProperties *Schema            `json:"additionalProperties"`
+	Required             []string           `json:"required"`
+	AllOf                []*Schema          `json:"allOf"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Required    bool    `json:"required"`
+	Description string  `json:"description"`
+	Schema      *Schema `json:"schema"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Response struct {
+	Ref     string               `json:"$ref"`
+	Content map[string]MediaType `json:"content"`
+}
+
+type Operation struct {
+	OperationID string      `json:"operationId"`
+	Summary     string      `json:"summary"`
+	Parameters  []Parameter `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]MediaType `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]Response `json:"responses"`
+}
+
+type Spec struct {
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+// op is an operation with its path and method resolved, in generation order
+type op struct {
+	*Operation
+	Method string
+	Path   string
+}
+
+// Path params first, then the JSON body, then an optional query/header params struct
+func (o op) pathParams() []Parameter   { return o.paramsIn("path") }
+func (o op) optionParams() []Parameter { return append(o.paramsIn("query"), o.paramsIn("header")...) }
+
+func (o op) paramsIn(in string) []Parameter {
+	var params []Parameter
+	for _, p := range o.Parameters {
+		if p.In == in {
+			params = append(params, p)
+		}
+	}
+	return params
+}
+
+// bodyType is the schema name of the JSON request body, "" without one
+func (o op) bodyType() string {
+	if o.RequestBody == nil {
+		return ""
+	}
+	return refName(o.RequestBody.Content["application/json"].Schema)
+}
+
+// resultType is the schema name of the first 2xx JSON response
+func (o op) resultType() string {
+	codes := make([]string, 0, len(o.Responses))
+	for code := range o.Responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if !strings.HasPrefix(code, "2") {
+			continue
+		}
+		if media, ok := o.Responses[code].Content["application/json"]; ok {
+			return refName(media.Schema)
+		}
+	}
+	return ""
+}
+
+func main() {
+	specPath := flag.String("spec", "api/openapi.json", "OpenAPI document")
+	goOut := flag.String("go", "../sdk/go", "directory of the Go SDK package")
+	tsOut := flag.String("ts", "../sdk/ts/src", "directory of the TypeScript SDK sources")
+	flag.Parse()
+
+	raw, err := os.ReadFile(*specPath)
+	if err != nil {
+		log.Fatalf("❌ Failed to read spec: %v", err)
+	}
+	var spec Spec
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		log.Fatalf("❌ Invalid spec: %v", err)
+	}
+	ops, err := operations(&spec)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	source := filepath.ToSlash(*specPath)
+	writeGo(filepath.Join(*goOut, "models_gen.go"), goModels(&spec, source))
+	writeGo(filepath.Join(*goOut, "operations_gen.go"), goOperations(ops, source))
+	writeFile(filepath.Join(*tsOut, "models.gen.ts"), tsModels(&spec, source))
+	writeFile(filepath.Join(*tsOut, "operations.gen.ts"), tsOperations(ops, source))
+	log.Printf("Generated %d models and %d operations", len(spec.Components.Schemas), len(ops))
+}
+
+// operations lists the operations sorted by path, then GET, POST, PUT, PATCH, DELETE
+func operations(spec *Spec) ([]op, error) {
+	paths := make([]string, 0, len(spec.Paths))
+	for path := range spec.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var ops []op
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		for _, method := range []string{"get", "post", "put", "patch", "delete"} {
+			operation, ok := spec.Paths[path][method]
+			if !ok {
+				continue
+			}
+			if operation.OperationID == "" {
+				return nil, fmt.Errorf("%s %s has no operationId", strings.ToUpper(method), path)
+			}
+			if seen[operation.OperationID] {
+				return nil, fmt.Errorf("duplicate operationId %q", operation.OperationID)
+			}
+			seen[operation.OperationID] = true
+			o := op{Operation: operation, Method: strings.ToUpper(method), Path: path}
+			if o.resultType() == "" {
+				return nil, fmt.Errorf("%s has no JSON 2xx response schema", operation.OperationID)
+			}
+			ops = append(ops, o)
+		}
+	}
+	return ops, nil
+}
+
+func writeGo(path, source string) {
+	formatted, err := format.Source([]byte(source))
+	if err != nil {
+		log.Fatalf("❌ Generated Go for %s does not compile: %v", path, err)
+	}
+	writeFile(path, string(formatted))
+}
+
+func writeFile(path, content string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+}
+
+func refName(s *Schema) string {
+	if s == nil {
+		return ""
+	}
+	if s.Ref == "" && len(s.AllOf) == 1 {
+		s = s.AllOf[0]
+	}
+	return strings.TrimPrefix(s.Ref, "#/components/schemas/")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// initialisms are written in upper case in Go identifiers
+var initialisms = map[string]bool{"id": true, "url": true, "otp": true, "qr": true, "csrf": true}
+
+// goName turns snake_case, camelCase and header names into an exported Go identifier
+func goName(name string) string {
+	var words []string
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '-' }) {
+		start := 0
+		for i := 1; i < len(part); i++ {
+			if part[i] >= 'A' && part[i] <= 'Z' && part[i-1] >= 'a' && part[i-1] <= 'z' {
+				words = append(words, part[start:i])
+				start = i
+			}
+		}
+		words = append(words, part[start:])
+	}
+	var b strings.Builder
+	for _, word := range words {
+		lower := strings.ToLower(word)
+		if initialisms[lower] {
+			b.WriteString(strings.ToUpper(lower))
+			continue
+		}
+		if word == strings.ToUpper(word) && len(word) > 1 {
+			// Already an initialism in the spec (sendOTP, getQR)
+			b.WriteString(word)
+			continue
+		}
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return b.String()
+}
+
+// goParamName is the unexported form of goName for function arguments
+func goParamName(name string) string {
+	exported := goName(name)
+	if initialisms[strings.ToLower(exported)] {
+		return strings.ToLower(exported)
+	}
+	return strings.ToLower(exported[:1]) + exported[1:]
+}
+
+// goType maps a schema to a Go type. Object references and nullable values are pointers.
+func goType(s *Schema) string {
+	if name := refName(s); name != "" {
+		return "*" + name
+	}
+	var t string
+	switch s.Type {
+	case "string":
+		t = "string"
+		if s.Format == "date-time" {
+			t = "time.Time"
+		}
+	case "integer":
+		t = "int"
+	case "number":
+		t = "float64"
+	case "boolean":
+		t = "bool"
+	case "array":
+		return "[]" + strings.TrimPrefix(goType(s.Items), "*")
+	case "object":
+		if s.AdditionalProperties != nil {
+			return "map[string]" + strings.TrimPrefix(goType(s.AdditionalProperties), "*")
+		}
+		return "map[string]any"
+	default:
+		t = "any"
+	}
+	if s.Nullable {
+		return "*" + t
+	}
+	return t
+}
+
+func goComment(b *strings.Builder, indent, text string) {
+	if text != "" {
+		fmt.Fprintf(b, "%s// %s\n", indent, text)
+	}
+}
+
+func goModels(spec *Spec, source string) string {
+	var b strings.Builder
+	for _, name := range sortedKeys(spec.Components.Schemas) {
+		s := spec.Components.Schemas[name]
+		b.WriteString("\n")
+		if s.Description != "" {
+			goComment(&b, "", name+": "+s.Description)
+		}
+		fmt.Fprintf(&b, "type %s struct {\n", name)
+		for _, field := range sortedKeys(s.Properties) {
+			prop := s.Properties[field]
+			comment := prop.Description
+			if len(prop.Enum) > 0 {
+				comment = strings.TrimSpace(comment + " One of: " + strings.Join(prop.Enum, ", "))
+			}
+			goComment(&b, "\t", comment)
+			fmt.Fprintf(&b, "\t%s %s `json:\"%s,omitempty\"`\n", goName(field), goType(prop), field)
+		}
+		b.WriteString("}\n")
+	}
+	return goFile(source, b.String(), "time")
+}
+
+// goFile adds the generated header and imports the given packages the code uses
+func goFile(source, code string, packages ...string) string {
+	var imports []string
+	for _, pkg := range packages {
+		if strings.Contains(code, filepath.Base(pkg)+".") {
+			imports = append(imports, fmt.Sprintf("\t%q\n", pkg))
+		}
+	}
+	header := fmt.Sprintf("// Code generated by sdkgen from %s. DO NOT EDIT.\n\npackage cekwa\n", source)
+	if len(imports) > 0 {
+		header += "\nimport (\n" + strings.Join(imports, "") + ")\n"
+	}
+	return header + code
+}
+
+func goOperations(ops []op, source string) string {
+	var b strings.Builder
+	for _, o := range ops {
+		name := goName(o.OperationID)
+		options := o.optionParams()
+		if len(options) > 0 {
+			fmt.Fprintf(&b, "\n// %sParams are the optional query and header parameters of %s\ntype %sParams struct {\n", name, name, name)
+			for _, p := range options {
+				goComment(&b, "\t", p.Description)
+				fmt.Fprintf(&b, "\t%s %s\n", goName(p.Name), goType(p.Schema))
+			}
+			b.WriteString("}\n")
+		}
+
+		args := []string{"ctx context.Context"}
+		for _, p := range o.pathParams() {
+			args = append(args, goParamName(p.Name)+" "+goType(p.Schema))
+		}
+		if body := o.bodyType(); body != "" {
+			args = append(args, "body *"+body)
+		}
+		if len(options) > 0 {
+			args = append(args, "params *"+name+"Params")
+		}
+
+		fmt.Fprintf(&b, "\n// %s calls %s %s: %s\n", name, o.Method, o.Path, o.Summary)
+		fmt.Fprintf(&b, "func (c *Client) %s(%s) (*%s, error) {\n", name, strings.Join(args, ", "), o.resultType())
+		fmt.Fprintf(&b, "\tpath := %s\n", goPathExpr(o))
+		b.WriteString("\tquery := url.Values{}\n\theader := http.Header{}\n")
+		if len(options) > 0 {
+			b.WriteString("\tif params != nil {\n")
+			for _, p := range options {
+				field := "params." + goName(p.Name)
+				target := fmt.Sprintf("query.Set(%q, %s)", p.Name, goStringExpr(field, p.Schema))
+				if p.In == "header" {
+					target = fmt.Sprintf("header.Set(%q, %s)", p.Name, goStringExpr(field, p.Schema))
+				}
+				fmt.Fprintf(&b, "\t\tif %s {\n\t\t\t%s\n\t\t}\n", goNonZero(field, p.Schema), target)
+			}
+			b.WriteString("\t}\n")
+		}
+		bodyArg := "nil"
+		if o.bodyType() != "" {
+			bodyArg = "body"
+		}
+		fmt.Fprintf(&b, "\tvar out %s\n", o.resultType())
+		fmt.Fprintf(&b, "\tif err := c.do(ctx, %q, path, query, header, %s, &out); err != nil {\n\t\treturn nil, err\n\t}\n\treturn &out, nil\n}\n", o.Method, bodyArg)
+	}
+	return goFile(source, b.String(), "context", "net/http", "net/url", "strconv")
+}
+
+// goPathExpr builds the request path with escaped path parameters
+func goPathExpr(o op) string {
+	params := make(map[string]Parameter)
+	for _, p := range o.pathParams() {
+		params[p.Name] = p
+	}
+	var parts []string
+	rest := o.Path
+	for {
+		start := strings.Index(rest, "{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(rest[start:], "}") + start
+		if rest[:start] != "" {
+			parts = append(parts, fmt.Sprintf("%q", rest[:start]))
+		}
+		p := params[rest[start+1:end]]
+		parts = append(parts, "url.PathEscape("+goStringExpr(goParamName(p.Name), p.Schema)+")")
+		rest = rest[end+1:]
+	}
+	if rest != "" {
+		parts = append(parts, fmt.Sprintf("%q", rest))
+	}
+	return strings.Join(parts, " + ")
+}
+
+func goStringExpr(expr string, s *Schema) string {
+	switch s.Type {
+	case "integer":
+		return "strconv.Itoa(" + expr + ")"
+	case "boolean":
+		return "strconv.FormatBool(" + expr + ")"
+	case "number":
+		return "strconv.FormatFloat(" + expr + ", 'f', -1, 64)"
+	}
+	return expr
+}
+
+func goNonZero(expr string, s *Schema) string {
+	switch s.Type {
+	case "integer", "number":
+		return expr + " != 0"
+	case "boolean":
+		return expr
+	}
+	return expr + ` != ""`
+}
+
+// tsType maps a schema to a TypeScript type; date-times stay ISO strings
+func tsType(s *Schema) string {
+	var t string
+	if name := refName(s); name != "" {
+		t = name
+	} else {
+		switch s.Type {
+		case "string":
+			t = "string"
+			if len(s.Enum) > 0 {
+				quoted := make([]string, len(s.Enum))
+				for i, v := range s.Enum {
+					quoted[i] = fmt.Sprintf("%q", v)
+				}
+				t = strings.Join(quoted, " | ")
+			}
+		case "integer", "number":
+			t = "number"
+		case "boolean":
+			t = "boolean"
+		case "array":
+			item := tsType(s.Items)
+			if strings.Contains(item, " ") {
+				item = "(" + item + ")"
+			}
+			t = item + "[]"
+		case "object":
+			value := "unknown"
+			if s.AdditionalProperties != nil {
+				value = tsType(s.AdditionalProperties)
+			}
+			t = "Record<string, " + value + ">"
+		default:
+			t = "unknown"
+		}
+	}
+	if s.Nullable {
+		return t + " | null"
+	}
+	return t
+}
+
+func tsComment(b *strings.Builder, indent, text string) {
+	if text != "" {
+		fmt.Fprintf(b, "%s/** %s */\n", indent, text)
+	}
+}
+
+func tsModels(spec *Spec, source string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Code generated by sdkgen from %s. DO NOT EDIT.\n", source)
+	for _, name := range sortedKeys(spec.Components.Schemas) {
+		s := spec.Components.Schemas[name]
+		required := make(map[string]bool)
+		for _, field := range s.Required {
+			required[field] = true
+		}
+		b.WriteString("\n")
+		tsComment(&b, "", s.Description)
+		fmt.Fprintf(&b, "export interface %s {\n", name)
+		for _, field := range sortedKeys(s.Properties) {
+			prop := s.Properties[field]
+			tsComment(&b, "  ", prop.Description)
+			optional := "?"
+			if required[field] {
+				optional = ""
+			}
+			fmt.Fprintf(&b, "  %s%s: %s;\n", field, optional, tsType(prop))
+		}
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+func tsOperations(ops []op, source string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Code generated by sdkgen from %s. DO NOT EDIT.\n\n", source)
+
+	types := make(map[string]bool)
+	for _, o := range ops {
+		types[o.resultType()] = true
+		if body := o.bodyType(); body != "" {
+			types[body] = true
+		}
+	}
+	fmt.Fprintf(&b, "import { BaseClient } from \"./client\";\nimport type {\n")
+	for _, name := range sortedKeys(types) {
+		fmt.Fprintf(&b, "  %s,\n", name)
+	}
+	b.WriteString("} from \"./models.gen\";\n")
+
+	for _, o := range ops {
+		options := o.optionParams()
+		if len(options) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\nexport interface %sParams {\n", goName(o.OperationID))
+		for _, p := range options {
+			tsComment(&b, "  ", p.Description)
+			optional := "?"
+			if p.Required {
+				optional = ""
+			}
+			fmt.Fprintf(&b, "  %q%s: %s;\n", p.Name, optional, tsType(p.Schema))
+		}
+		b.WriteString("}\n")
+	}
+
+	b.WriteString("\n/** Client for the CEKWA API, one method per operation of the OpenAPI spec */\nexport class CekwaClient extends BaseClient {\n")
+	for i, o := range ops {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		var args []string
+		path := o.Path
+		for _, p := range o.pathParams() {
+			arg := goParamName(p.Name)
+			args = append(args, arg+": "+tsType(p.Schema))
+			path = strings.ReplaceAll(path, "{"+p.Name+"}", "${encodeURIComponent(String("+arg+"))}")
+		}
+		if body := o.bodyType(); body != "" {
+			args = append(args, "body: "+body)
+		}
+		options := o.optionParams()
+		optional := "?"
+		if len(options) > 0 {
+			for _, p := range options {
+				if p.Required {
+					optional = ""
+				}
+			}
+			args = append(args, "params"+optional+": "+goName(o.OperationID)+"Params")
+		}
+
+		fmt.Fprintf(&b, "  /** %s %s: %s */\n", o.Method, o.Path, o.Summary)
+		fmt.Fprintf(&b, "  %s(%s): Promise<%s> {\n", o.OperationID, strings.Join(args, ", "), o.resultType())
+		access := "params?."
+		if optional == "" {
+			access = "params"
+		}
+		var query, headers, fields []string
+		for _, p := range options {
+			entry := fmt.Sprintf("%q: %s[%q]", p.Name, access, p.Name)
+			if p.In == "header" {
+				headers = append(headers, entry)
+			} else {
+				query = append(query, entry)
+			}
+		}
+		if len(query) > 0 {
+			fields = append(fields, "query: { "+strings.Join(query, ", ")+" }")
+		}
+		if len(headers) > 0 {
+			fields = append(fields, "headers: { "+strings.Join(headers, ", ")+" }")
+		}
+		if o.bodyType() != "" {
+			fields = append(fields, "body")
+		}
+		if len(fields) == 0 {
+			fmt.Fprintf(&b, "    return this.request<%s>(%q, `%s`);\n  }\n", o.resultType(), o.Method, path)
+			continue
+		}
+		fmt.Fprintf(&b, "    return this.request<%s>(%q, `%s`, {\n", o.resultType(), o.Method, path)
+		for _, field := range fields {
+			fmt.Fprintf(&b, "      %s,\n", field)
+		}
+		b.WriteString("    });\n  }\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -0,0 +1,163 @@
+// Package cekwa is the Go client of the CEKWA API: auth, WhatsApp scanning, analysis results and
+// payments. The models and the operation methods are generated from backend/api/openapi.json
+// (see backend/tools/sdkgen); this file holds the HTTP plumbing.
+//
+//	client := cekwa.NewClient("https://api.cekwa.id")
+//	login, err := client.Login(ctx, &cekwa.LoginRequest{Email: email, Password: password})
+//	...
+//	client.SetToken(login.Token)
+//	status, err := client.GetStatus(ctx)
+package cekwa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client calls the CEKWA API. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	userAgent  string
+
+	mu    sync.RWMutex
+	token string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithToken authenticates every request with the JWT (Authorization: Bearer)
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithHTTPClient replaces the default HTTP client (30s timeout)
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithUserAgent sets the User-Agent header, e.g. "partner-app/1.2"
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent }
+}
+
+// NewClient creates a client for the API at baseURL (without the /api prefix)
+func NewClient(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		userAgent:  "cekwa-go",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// SetToken replaces the JWT sent with later requests, e.g. after Login
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+}
+
+// APIError is returned for non-2xx responses
+type APIError struct {
+	StatusCode int
+	// Message is the "error" field of a JSON error, or the plain-text body
+	Message string
+	// ErrorType is the machine-readable reason when the API sends one (e.g. on 402)
+	ErrorType string
+	// Payment is the prefilled checkout attached to 402 Payment Required responses
+	Payment *PaymentBootstrap
+	Body    []byte
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("cekwa: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// IsPaymentRequired reports whether err is a 402 response; its Payment can be passed to CreatePayment
+func IsPaymentRequired(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusPaymentRequired
+}
+
+// do sends the request and decodes the JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, header http.Header, body, out any) error {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("cekwa: encode request: %w", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	c.mu.RLock()
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	c.mu.RUnlock()
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("cekwa: read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newAPIError(resp.StatusCode, raw)
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("cekwa: decode %s %s response: %w", method, path, err)
+	}
+	return nil
+}
+
+// newAPIError decodes a JSON error body, falling back to the plain-text message of http.Error
+func newAPIError(status int, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: status, Body: body}
+	var decoded ErrorResponse
+	if json.Unmarshal(body, &decoded) == nil && (decoded.Error != "" || decoded.Message != "") {
+		apiErr.Message = decoded.Error
+		if apiErr.Message == "" {
+			apiErr.Message = decoded.Message
+		}
+		apiErr.ErrorType = decoded.ErrorType
+		apiErr.Payment = decoded.Payment
+		return apiErr
+	}
+	apiErr.Message = strings.TrimSpace(string(body))
+	return apiErr
+}
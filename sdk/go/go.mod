@@ -0,0 +1,3 @@
+module github.com/Syahruladhim/CEKWA/sdk/go
+
+go 1.21
@@ -0,0 +1,512 @@
+// Code generated by sdkgen from api/openapi.json. DO NOT EDIT.
+
+package cekwa
+
+import (
+	"time"
+)
+
+type AnalysisDetailResponse struct {
+	Data    *AnalysisResult `json:"data,omitempty"`
+	Success bool            `json:"success,omitempty"`
+}
+
+// AnalysisHistoryResponse: The list is streamed; a failure part-way through sets success=false and error
+type AnalysisHistoryResponse struct {
+	Data  []HistoryItem `json:"data,omitempty"`
+	Error string        `json:"error,omitempty"`
+	// History size of the user's tier; only the newest analyses up to it are listed
+	HistoryLimit int `json:"history_limit,omitempty"`
+	// Page size, only with ?limit=
+	Limit int `json:"limit,omitempty"`
+	// Rows skipped, only with ?limit=
+	Offset  int  `json:"offset,omitempty"`
+	Success bool `json:"success,omitempty"`
+	// Rows matching the filters, for paging
+	Total int `json:"total,omitempty"`
+}
+
+// AnalysisJob: result is set once completed, partial while running
+type AnalysisJob struct {
+	AnalysisID *int           `json:"analysis_id,omitempty"`
+	CreatedAt  time.Time      `json:"created_at,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+	JobID      string         `json:"job_id,omitempty"`
+	Partial    map[string]any `json:"partial,omitempty"`
+	Priority   int            `json:"priority,omitempty"`
+	Progress   int            `json:"progress,omitempty"`
+	Result     map[string]any `json:"result,omitempty"`
+	Stage      string         `json:"stage,omitempty"`
+	StartedAt  *time.Time     `json:"started_at,omitempty"`
+	// One of: queued, running, completed, failed, skipped
+	Status string `json:"status,omitempty"`
+}
+
+type AnalysisJobAccepted struct {
+	EstimatedWaitSeconds int          `json:"estimated_wait_seconds,omitempty"`
+	Job                  *AnalysisJob `json:"job,omitempty"`
+	JobID                string       `json:"job_id,omitempty"`
+	Message              string       `json:"message,omitempty"`
+	Queued               bool         `json:"queued,omitempty"`
+	StatusURL            string       `json:"status_url,omitempty"`
+	Success              bool         `json:"success,omitempty"`
+	UserID               int          `json:"user_id,omitempty"`
+}
+
+type AnalysisJobResponse struct {
+	Job     *AnalysisJob `json:"job,omitempty"`
+	Success bool         `json:"success,omitempty"`
+}
+
+type AnalysisResult struct {
+	AccountAgeDays        int                            `json:"accountAgeDays,omitempty"`
+	AutoTriggered         bool                           `json:"auto_triggered,omitempty"`
+	Checksum              string                         `json:"checksum,omitempty"`
+	Confidence            int                            `json:"confidence,omitempty"`
+	CreatedAt             time.Time                      `json:"created_at,omitempty"`
+	DataQuality           *DataQuality                   `json:"data_quality,omitempty"`
+	DurationMs            int                            `json:"duration_ms,omitempty"`
+	FeedbackPrompt        bool                           `json:"feedback_prompt,omitempty"`
+	ID                    int                            `json:"id,omitempty"`
+	Label                 string                         `json:"label,omitempty"`
+	ParameterConfidence   map[string]ParameterConfidence `json:"parameter_confidence,omitempty"`
+	Parameters            []ParameterEvaluation          `json:"parameters,omitempty"`
+	PhoneNumber           string                         `json:"phone_number,omitempty"`
+	Pinned                bool                           `json:"pinned,omitempty"`
+	ScanDate              time.Time                      `json:"scan_date,omitempty"`
+	ScanHistoryID         *int                           `json:"scan_history_id,omitempty"`
+	ScanTrigger           string                         `json:"scan_trigger,omitempty"`
+	SensitiveContentCount int                            `json:"sensitiveContentCount,omitempty"`
+	SensitiveCategories   map[string]int                 `json:"sensitive_categories,omitempty"`
+	Strength              string                         `json:"strength,omitempty"`
+	Summary               string                         `json:"summary,omitempty"`
+	TotalChatWithContact  int                            `json:"totalChatWithContact,omitempty"`
+	TotalChats            int                            `json:"totalChats,omitempty"`
+	TotalContacts         int                            `json:"totalContacts,omitempty"`
+	TotalGroups           int                            `json:"totalGroups,omitempty"`
+	TotalUnsavedChats     int                            `json:"totalUnsavedChats,omitempty"`
+	UnknownNumberChats    int                            `json:"unknownNumberChats,omitempty"`
+	UpdatedAt             time.Time                      `json:"updated_at,omitempty"`
+	UserID                int                            `json:"user_id,omitempty"`
+}
+
+type AnalyzeResponse struct {
+	Cached  bool            `json:"cached,omitempty"`
+	Message string          `json:"message,omitempty"`
+	Result  *AnalysisResult `json:"result,omitempty"`
+	Success bool            `json:"success,omitempty"`
+	UserID  int             `json:"user_id,omitempty"`
+}
+
+type AuthSession struct {
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	// Session of the token making the request
+	Current bool `json:"current,omitempty"`
+	// Browser and OS derived from the user agent
+	Device     string     `json:"device,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at,omitempty"`
+	ID         int        `json:"id,omitempty"`
+	IpAddress  string     `json:"ip_address,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	UserAgent  string     `json:"user_agent,omitempty"`
+	UserID     int        `json:"user_id,omitempty"`
+}
+
+type CouponQuote struct {
+	Amount      float64    `json:"amount,omitempty"`
+	Code        string     `json:"code,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Discount    float64    `json:"discount,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	FinalAmount float64    `json:"final_amount,omitempty"`
+}
+
+// CreatePaymentRequest: With create_payment_token only email (optional) and payment_method (default invoice) are needed
+type CreatePaymentRequest struct {
+	// Ignored: the amount is the price of the payment category
+	Amount float64 `json:"amount,omitempty"`
+	// Name of an active payment category (see category_id)
+	Category string `json:"category,omitempty"`
+	// Payment category ID, takes precedence over category
+	CategoryID int `json:"category_id,omitempty"`
+	// Optional promo code; the discount is taken off the category price before the invoice is created
+	CouponCode         string `json:"coupon_code,omitempty"`
+	CreatePaymentToken string `json:"create_payment_token,omitempty"`
+	Email              string `json:"email,omitempty"`
+	// One of: xendit, midtrans
+	Gateway         string `json:"gateway,omitempty"`
+	PaymentMethod   string `json:"payment_method,omitempty"`
+	PhoneNumber     string `json:"phone_number,omitempty"`
+	RedirectBaseURL string `json:"redirect_base_url,omitempty"`
+}
+
+type CreatePaymentResponse struct {
+	Amount        float64   `json:"amount,omitempty"`
+	CouponCode    string    `json:"coupon_code,omitempty"`
+	CreatedAt     time.Time `json:"created_at,omitempty"`
+	Discount      float64   `json:"discount,omitempty"`
+	Existing      bool      `json:"existing,omitempty"`
+	ExpiryDate    string    `json:"expiry_date,omitempty"`
+	ExternalID    string    `json:"external_id,omitempty"`
+	Gateway       string    `json:"gateway,omitempty"`
+	ID            int       `json:"id,omitempty"`
+	InvoiceID     string    `json:"invoice_id,omitempty"`
+	InvoiceURL    string    `json:"invoice_url,omitempty"`
+	Message       string    `json:"message,omitempty"`
+	PaymentMethod string    `json:"payment_method,omitempty"`
+	Status        string    `json:"status,omitempty"`
+}
+
+// CurrentSubscriptionResponse: data is null without an active subscription
+type CurrentSubscriptionResponse struct {
+	Data    *SubscriptionStatus `json:"data,omitempty"`
+	Success bool                `json:"success,omitempty"`
+}
+
+type DataQuality struct {
+	Chats      string            `json:"chats,omitempty"`
+	Confidence int               `json:"confidence,omitempty"`
+	Contacts   string            `json:"contacts,omitempty"`
+	Degraded   bool              `json:"degraded,omitempty"`
+	Errors     map[string]string `json:"errors,omitempty"`
+	Groups     string            `json:"groups,omitempty"`
+	UpgradedAt time.Time         `json:"upgraded_at,omitempty"`
+}
+
+type DeleteAnalysisResponse struct {
+	Deleted int  `json:"deleted,omitempty"`
+	Success bool `json:"success,omitempty"`
+}
+
+type Entitlement struct {
+	Entitled           bool   `json:"entitled,omitempty"`
+	HasPaidOtherNumber bool   `json:"has_paid_other_number,omitempty"`
+	Message            string `json:"message,omitempty"`
+	// Checkout payload, only when payment_required
+	Payment *PaymentBootstrap `json:"payment,omitempty"`
+	// Whether paying for the number would allow the analysis (false for daily_scan_limit)
+	PaymentRequired bool `json:"payment_required,omitempty"`
+	// Normalized, e.g. 6281234567890
+	PhoneNumber string `json:"phone_number,omitempty"`
+	// Starting price, only when payment_required
+	Price *PaymentPrice `json:"price,omitempty"`
+	// One of: paid, subscription, no_payment, wrong_phone_number, subscription_limit_reached, daily_scan_limit
+	Reason       string              `json:"reason,omitempty"`
+	Subscription *SubscriptionStatus `json:"subscription,omitempty"`
+}
+
+type EntitlementResponse struct {
+	Data    *Entitlement `json:"data,omitempty"`
+	Success bool         `json:"success,omitempty"`
+}
+
+// ErrorResponse: Error body. Most endpoints answer errors as plain text; the WhatsApp endpoints answer JSON like this.
+type ErrorResponse struct {
+	Error string `json:"error,omitempty"`
+	// Machine-readable reason, e.g. payment_required
+	ErrorType string            `json:"error_type,omitempty"`
+	Message   string            `json:"message,omitempty"`
+	Payment   *PaymentBootstrap `json:"payment,omitempty"`
+	Success   bool              `json:"success,omitempty"`
+}
+
+type HistoryItem struct {
+	Checksum    string    `json:"checksum,omitempty"`
+	ID          int       `json:"id,omitempty"`
+	Label       string    `json:"label,omitempty"`
+	PhoneNumber string    `json:"phone_number,omitempty"`
+	Pinned      bool      `json:"pinned,omitempty"`
+	ScanDate    time.Time `json:"scan_date,omitempty"`
+	Strength    string    `json:"strength,omitempty"`
+	// One of: manual, auto
+	Trigger string `json:"trigger,omitempty"`
+}
+
+type LoginRequest struct {
+	Email    string   `json:"email,omitempty"`
+	Password string   `json:"password,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
+}
+
+// LoginResponse: token is omitted in cookie mode, where csrf_token is returned instead
+type LoginResponse struct {
+	CSRFToken string   `json:"csrf_token,omitempty"`
+	Message   string   `json:"message,omitempty"`
+	Scopes    []string `json:"scopes,omitempty"`
+	Success   bool     `json:"success,omitempty"`
+	Token     string   `json:"token,omitempty"`
+	User      *User    `json:"user,omitempty"`
+}
+
+type MessageResponse struct {
+	Message string `json:"message,omitempty"`
+	Success bool   `json:"success,omitempty"`
+}
+
+type ParameterConfidence struct {
+	Confidence int  `json:"confidence,omitempty"`
+	Estimated  bool `json:"estimated,omitempty"`
+}
+
+type ParameterEvaluation struct {
+	Confidence int    `json:"confidence,omitempty"`
+	Estimated  bool   `json:"estimated,omitempty"`
+	Key        string `json:"key,omitempty"`
+	Parameter  string `json:"parameter,omitempty"`
+	Score      int    `json:"score,omitempty"`
+	// Baik, Cukup or Buruk
+	Status string `json:"status,omitempty"`
+	Value  int    `json:"value,omitempty"`
+}
+
+// PaymentBootstrap: Prefilled checkout attached to 402 responses and payment checks
+type PaymentBootstrap struct {
+	Amount             float64   `json:"amount,omitempty"`
+	CreatePaymentToken string    `json:"create_payment_token,omitempty"`
+	Currency           string    `json:"currency,omitempty"`
+	ExpiresAt          time.Time `json:"expires_at,omitempty"`
+	PhoneNumber        string    `json:"phone_number,omitempty"`
+	Plan               string    `json:"plan,omitempty"`
+}
+
+type PaymentCheckResponse struct {
+	Data    *Entitlement `json:"data,omitempty"`
+	Success bool         `json:"success,omitempty"`
+}
+
+type PaymentPrice struct {
+	Amount   float64 `json:"amount,omitempty"`
+	Category string  `json:"category,omitempty"`
+	Currency string  `json:"currency,omitempty"`
+}
+
+type PaymentStatus struct {
+	Amount         float64    `json:"amount,omitempty"`
+	CreatedAt      time.Time  `json:"created_at,omitempty"`
+	ExternalID     string     `json:"external_id,omitempty"`
+	Gateway        string     `json:"gateway,omitempty"`
+	ID             int        `json:"id,omitempty"`
+	InvoiceID      string     `json:"invoice_id,omitempty"`
+	PaidAt         *time.Time `json:"paid_at,omitempty"`
+	PaymentChannel string     `json:"payment_channel,omitempty"`
+	PaymentMethod  string     `json:"payment_method,omitempty"`
+	Status         string     `json:"status,omitempty"`
+	UpdatedAt      time.Time  `json:"updated_at,omitempty"`
+}
+
+type Plan struct {
+	Code        string    `json:"code,omitempty"`
+	CreatedAt   time.Time `json:"created_at,omitempty"`
+	Currency    string    `json:"currency,omitempty"`
+	Description string    `json:"description,omitempty"`
+	ID          int       `json:"id,omitempty"`
+	IsActive    bool      `json:"is_active,omitempty"`
+	Months      int       `json:"months,omitempty"`
+	Name        string    `json:"name,omitempty"`
+	Price       float64   `json:"price,omitempty"`
+	// 0 = unlimited
+	ScansPerMonth int       `json:"scans_per_month,omitempty"`
+	UpdatedAt     time.Time `json:"updated_at,omitempty"`
+}
+
+type PlanListResponse struct {
+	Data    []Plan `json:"data,omitempty"`
+	Success bool   `json:"success,omitempty"`
+}
+
+type ProfileResponse struct {
+	Success bool  `json:"success,omitempty"`
+	User    *User `json:"user,omitempty"`
+}
+
+// QRResponse: qr is empty while the code is being generated
+type QRResponse struct {
+	Message     string    `json:"message,omitempty"`
+	QR          string    `json:"qr,omitempty"`
+	QRExpiresAt time.Time `json:"qr_expires_at,omitempty"`
+	Ready       bool      `json:"ready,omitempty"`
+}
+
+type RegisterRequest struct {
+	Email       string `json:"email,omitempty"`
+	Password    string `json:"password,omitempty"`
+	PhoneNumber string `json:"phone_number,omitempty"`
+	Username    string `json:"username,omitempty"`
+}
+
+type RegisterResponse struct {
+	Message string `json:"message,omitempty"`
+	Success bool   `json:"success,omitempty"`
+	User    *User  `json:"user,omitempty"`
+}
+
+// Restriction: Active ban or temporary ban of the WhatsApp account
+type Restriction struct {
+	Code   int    `json:"code,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	// One of: banned, temp_banned
+	Type  string    `json:"type,omitempty"`
+	Until time.Time `json:"until,omitempty"`
+}
+
+type RevokeSessionsResponse struct {
+	Revoked int  `json:"revoked,omitempty"`
+	Success bool `json:"success,omitempty"`
+}
+
+type SendOTPRequest struct {
+	// One of: email, whatsapp, sms
+	Channel string `json:"channel,omitempty"`
+	Email   string `json:"email,omitempty"`
+}
+
+type SessionListResponse struct {
+	Sessions []AuthSession `json:"sessions,omitempty"`
+	Success  bool          `json:"success,omitempty"`
+}
+
+type StatusResponse struct {
+	AnalysisAllowed bool `json:"analysis_allowed,omitempty"`
+	AnalysisReady   bool `json:"analysis_ready,omitempty"`
+	// Set when the scanned number needs a payment
+	PhoneMismatch map[string]any `json:"phone_mismatch,omitempty"`
+	Ready         bool           `json:"ready,omitempty"`
+	Restriction   *Restriction   `json:"restriction,omitempty"`
+	Timestamp     time.Time      `json:"timestamp,omitempty"`
+	UserID        int            `json:"user_id,omitempty"`
+	Warmup        *WarmupState   `json:"warmup,omitempty"`
+	// One of: connected, disconnected, scanning, connecting, banned, reconnecting, store_upgrading, store_upgrade_failed
+	WhatsappStatus string `json:"whatsapp_status,omitempty"`
+}
+
+type SubscribeRequest struct {
+	Email string `json:"email,omitempty"`
+	// One of: xendit, midtrans
+	Gateway         string `json:"gateway,omitempty"`
+	PlanCode        string `json:"plan_code,omitempty"`
+	RedirectBaseURL string `json:"redirect_base_url,omitempty"`
+}
+
+type SubscribeResponse struct {
+	Data    *SubscribeResult `json:"data,omitempty"`
+	Success bool             `json:"success,omitempty"`
+}
+
+type SubscribeResult struct {
+	Payment      *CreatePaymentResponse `json:"payment,omitempty"`
+	Plan         *Plan                  `json:"plan,omitempty"`
+	Subscription *Subscription          `json:"subscription,omitempty"`
+}
+
+type Subscription struct {
+	CreatedAt     time.Time  `json:"created_at,omitempty"`
+	CycleStart    *time.Time `json:"cycle_start,omitempty"`
+	EndsAt        *time.Time `json:"ends_at,omitempty"`
+	ID            int        `json:"id,omitempty"`
+	Months        int        `json:"months,omitempty"`
+	PlanID        int        `json:"plan_id,omitempty"`
+	ScansPerMonth int        `json:"scans_per_month,omitempty"`
+	ScansUsed     int        `json:"scans_used,omitempty"`
+	StartsAt      *time.Time `json:"starts_at,omitempty"`
+	// One of: pending, active, expired, cancelled
+	Status    string    `json:"status,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	UserID    int       `json:"user_id,omitempty"`
+}
+
+type SubscriptionStatus struct {
+	CycleEndsAt   *time.Time `json:"cycle_ends_at,omitempty"`
+	EndsAt        *time.Time `json:"ends_at,omitempty"`
+	ID            int        `json:"id,omitempty"`
+	PlanCode      string     `json:"plan_code,omitempty"`
+	PlanName      string     `json:"plan_name,omitempty"`
+	ScansPerMonth int        `json:"scans_per_month,omitempty"`
+	// null = unlimited
+	ScansRemaining *int       `json:"scans_remaining,omitempty"`
+	ScansUsed      int        `json:"scans_used,omitempty"`
+	StartsAt       *time.Time `json:"starts_at,omitempty"`
+	Status         string     `json:"status,omitempty"`
+}
+
+type Transaction struct {
+	Amount         float64    `json:"amount,omitempty"`
+	CreatedAt      time.Time  `json:"created_at,omitempty"`
+	Currency       string     `json:"currency,omitempty"`
+	Description    string     `json:"description,omitempty"`
+	ExternalID     string     `json:"external_id,omitempty"`
+	ID             int        `json:"id,omitempty"`
+	PaidAt         *time.Time `json:"paid_at,omitempty"`
+	PaymentChannel string     `json:"payment_channel,omitempty"`
+	PaymentMethod  string     `json:"payment_method,omitempty"`
+	PhoneNumber    string     `json:"phone_number,omitempty"`
+	RefundedAt     *time.Time `json:"refunded_at,omitempty"`
+	Status         string     `json:"status,omitempty"`
+	UpdatedAt      time.Time  `json:"updated_at,omitempty"`
+}
+
+// TransactionListResponse: The list is streamed; a failure part-way through sets success=false and error
+type TransactionListResponse struct {
+	Data  []Transaction `json:"data,omitempty"`
+	Error string        `json:"error,omitempty"`
+	// Page size, only with ?limit=
+	Limit int `json:"limit,omitempty"`
+	// Rows skipped, only with ?limit=
+	Offset  int  `json:"offset,omitempty"`
+	Success bool `json:"success,omitempty"`
+	// Rows matching the filters, for paging
+	Total int `json:"total,omitempty"`
+}
+
+type UpdateAnalysisRequest struct {
+	// Free text, at most 100 characters; empty removes the label
+	Label  string `json:"label,omitempty"`
+	Pinned bool   `json:"pinned,omitempty"`
+}
+
+type UpdateAnalysisResponse struct {
+	Data    map[string]any `json:"data,omitempty"`
+	Success bool           `json:"success,omitempty"`
+}
+
+// User: Account as returned by the auth endpoints
+type User struct {
+	CreatedAt   time.Time `json:"created_at,omitempty"`
+	Email       string    `json:"email,omitempty"`
+	ID          int       `json:"id,omitempty"`
+	PhoneNumber string    `json:"phone_number,omitempty"`
+	// One of: user, admin
+	Role     string `json:"role,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+type ValidateCouponRequest struct {
+	Amount float64 `json:"amount,omitempty"`
+	Code   string  `json:"code,omitempty"`
+}
+
+type ValidateCouponResponse struct {
+	Data    *CouponQuote `json:"data,omitempty"`
+	Success bool         `json:"success,omitempty"`
+}
+
+type VerifyOTPRequest struct {
+	Email string `json:"email,omitempty"`
+	OTP   string `json:"otp,omitempty"`
+}
+
+// WarmupState: Contact/group sync progress after connecting
+type WarmupState struct {
+	Contacts  int       `json:"contacts,omitempty"`
+	Groups    int       `json:"groups,omitempty"`
+	Phase     string    `json:"phase,omitempty"`
+	Progress  int       `json:"progress,omitempty"`
+	StartedAt time.Time `json:"started_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+type WhatsAppLogoutResponse struct {
+	Message string `json:"message,omitempty"`
+	Success bool   `json:"success,omitempty"`
+	UserID  int    `json:"user_id,omitempty"`
+}
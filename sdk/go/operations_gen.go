@@ -0,0 +1,505 @@
+// Code generated by sdkgen from api/openapi.json. DO NOT EDIT.
+
+package cekwa
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ListAnalysisHistoryParams are the optional query and header parameters of ListAnalysisHistory
+type ListAnalysisHistoryParams struct {
+	// Page size (1-200); all rows when omitted
+	Limit int
+	// Rows to skip, requires limit
+	Offset int
+	// First day (YYYY-MM-DD, SCAN_SCHEDULE_TIMEZONE)
+	From string
+	// Last day, inclusive (YYYY-MM-DD)
+	To string
+	// Comma-separated strengths to include (Baik, Cukup, Buruk)
+	Strength string
+	// Sort key
+	Sort string
+	// Sort direction, default desc
+	Order string
+}
+
+// ListAnalysisHistory calls GET /api/analysis/history: Analysis history, pinned first, then newest first (unless sorted)
+func (c *Client) ListAnalysisHistory(ctx context.Context, params *ListAnalysisHistoryParams) (*AnalysisHistoryResponse, error) {
+	path := "/api/analysis/history"
+	query := url.Values{}
+	header := http.Header{}
+	if params != nil {
+		if params.Limit != 0 {
+			query.Set("limit", strconv.Itoa(params.Limit))
+		}
+		if params.Offset != 0 {
+			query.Set("offset", strconv.Itoa(params.Offset))
+		}
+		if params.From != "" {
+			query.Set("from", params.From)
+		}
+		if params.To != "" {
+			query.Set("to", params.To)
+		}
+		if params.Strength != "" {
+			query.Set("strength", params.Strength)
+		}
+		if params.Sort != "" {
+			query.Set("sort", params.Sort)
+		}
+		if params.Order != "" {
+			query.Set("order", params.Order)
+		}
+	}
+	var out AnalysisHistoryResponse
+	if err := c.do(ctx, "GET", path, query, header, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetAnalysisParams are the optional query and header parameters of GetAnalysis
+type GetAnalysisParams struct {
+	// Comma-separated fields to return (e.g. id,strength,summary); unknown fields are rejected with 400
+	Fields string
+}
+
+// GetAnalysis calls GET /api/analysis/{id}: One analysis result
+func (c *Client) GetAnalysis(ctx context.Context, id int, params *GetAnalysisParams) (*AnalysisDetailResponse, error) {
+	path := "/api/analysis/" + url.PathEscape(strconv.Itoa(id))
+	query := url.Values{}
+	header := http.Header{}
+	if params != nil {
+		if params.Fields != "" {
+			query.Set("fields", params.Fields)
+		}
+	}
+	var out AnalysisDetailResponse
+	if err := c.do(ctx, "GET", path, query, header, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateAnalysis calls PATCH /api/analysis/{id}: Pin or label an analysis result
+func (c *Client) UpdateAnalysis(ctx context.Context, id int, body *UpdateAnalysisRequest) (*UpdateAnalysisResponse, error) {
+	path := "/api/analysis/" + url.PathEscape(strconv.Itoa(id))
+	query := url.Values{}
+	header := http.Header{}
+	var out UpdateAnalysisResponse
+	if err := c.do(ctx, "PATCH", path, query, header, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteAnalysis calls DELETE /api/analysis/{id}: Delete one analysis result
+func (c *Client) DeleteAnalysis(ctx context.Context, id int) (*DeleteAnalysisResponse, error) {
+	path := "/api/analysis/" + url.PathEscape(strconv.Itoa(id))
+	query := url.Values{}
+	header := http.Header{}
+	var out DeleteAnalysisResponse
+	if err := c.do(ctx, "DELETE", path, query, header, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Login calls POST /api/auth/login: Log in and get a JWT
+func (c *Client) Login(ctx context.Context, body *LoginRequest) (*LoginResponse, error) {
+	path := "/api/auth/login"
+	query := url.Values{}
+	header := http.Header{}
+	var out LoginResponse
+	if err := c.do(ctx, "POST", path, query, header, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Logout calls POST /api/auth/logout: Revoke the session of the token and clear the session cookies (cookie mode)
+func (c *Client) Logout(ctx context.Context) (*MessageResponse, error) {
+	path := "/api/auth/logout"
+	query := url.Values{}
+	header := http.Header{}
+	var out MessageResponse
+	if err := c.do(ctx, "POST", path, query, header, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetProfile calls GET /api/auth/profile: Profile of the token's user
+func (c *Client) GetProfile(ctx context.Context) (*ProfileResponse, error) {
+	path := "/api/auth/profile"
+	query := url.Values{}
+	header := http.Header{}
+	var out ProfileResponse
+	if err := c.do(ctx, "GET", path, query, header, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Register calls POST /api/auth/register: Create an account; a verification OTP is emailed
+func (c *Client) Register(ctx context.Context, body *RegisterRequest) (*RegisterResponse, error) {
+	path := "/api/auth/register"
+	query := url.Values{}
+	header := http.Header{}
+	var out RegisterResponse
+	if err := c.do(ctx, "POST", path, query, header, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SendOTP calls POST /api/auth/send-otp: Send a verification OTP by email, WhatsApp or SMS
+func (c *Client) SendOTP(ctx context.Context, body *SendOTPRequest) (*MessageResponse, error) {
+	path := "/api/auth/send-otp"
+	query := url.Values{}
+	header := http.Header{}
+	var out MessageResponse
+	if err := c.do(ctx, "POST", path, query, header, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListSessions calls GET /api/auth/sessions: Devices signed in to the account
+func (c *Client) ListSessions(ctx context.Context) (*SessionListResponse, error) {
+	path := "/api/auth/sessions"
+	query := url.Values{}
+	header := http.Header{}
+	var out SessionListResponse
+	if err := c.do(ctx, "GET", path, query, header, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RevokeSessionsParams are the optional query and header parameters of RevokeSessions
+type RevokeSessionsParams struct {
+	// Also revoke the session of this token
+	IncludeCurrent bool
+}
+
+// RevokeSessions calls DELETE /api/auth/sessions: Sign out every other device
+func (c *Client) RevokeSessions(ctx context.Context, params *RevokeSessionsParams) (*RevokeSessionsResponse, error) {
+	path := "/api/auth/sessions"
+	query := url.Values{}
+	header := http.Header{}
+	if params != nil {
+		if params.IncludeCurrent {
+			query.Set("include_current", strconv.FormatBool(params.IncludeCurrent))
+		}
+	}
+	var out RevokeSessionsResponse
+	if err := c.do(ctx, "DELETE", path, query, header, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RevokeSession calls DELETE /api/auth/sessions/{id}: Sign out one device
+func (c *Client) RevokeSession(ctx context.Context, id int) (*MessageResponse, error) {
+	path := "/api/auth/sessions/" + url.PathEscape(strconv.Itoa(id))
+	query := url.Values{}
+	header := http.Header{}
+	var out MessageResponse
+	if err := c.do(ctx, "DELETE", path, query, header, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// VerifyOTP calls POST /api/auth/verify-otp: Verify the emailed OTP
+func (c *Client) VerifyOTP(ctx context.Context, body *VerifyOTPRequest) (*MessageResponse, error) {
+	path := "/api/auth/verify-otp"
+	query := url.Values{}
+	header := http.Header{}
+	var out MessageResponse
+	if err := c.do(ctx, "POST", path, query, header, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ValidateCoupon calls POST /api/coupons/validate: Check a promo code and the discount it gives on an amount
+func (c *Client) ValidateCoupon(ctx context.Context, body *ValidateCouponRequest) (*ValidateCouponResponse, error) {
+	path := "/api/coupons/validate"
+	query := url.Values{}
+	header := http.Header{}
+	var out ValidateCouponResponse
+	if err := c.do(ctx, "POST", path, query, header, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CheckPaymentParams are the optional query and header parameters of CheckPayment
+type CheckPaymentParams struct {
+	// Phone number to analyze
+	Phone string
+}
+
+// CheckPayment calls GET /api/payments/check: Whether analyzing the phone number needs a payment
+func (c *Client) CheckPayment(ctx context.Context, params *CheckPaymentParams) (*PaymentCheckResponse, error) {
+	path := "/api/payments/check"
+	query := url.Values{}
+	header := http.Header{}
+	if params != nil {
+		if params.Phone != "" {
+			query.Set("phone", params.Phone)
+		}
+	}
+	var out PaymentCheckResponse
+	if err := c.do(ctx, "GET", path, query, header, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreatePaymentParams are the optional query and header parameters of CreatePayment
+type CreatePaymentParams struct {
+	// Replays the first invoice when the same key is sent again
+	IdempotencyKey string
+}
+
+// CreatePayment calls POST /api/payments/create: Create (or reuse) an invoice
+func (c *Client) CreatePayment(ctx context.Context, body *CreatePaymentRequest, params *CreatePaymentParams) (*CreatePaymentResponse, error) {
+	path := "/api/payments/create"
+	query := url.Values{}
+	header := http.Header{}
+	if params != nil {
+		if params.IdempotencyKey != "" {
+			header.Set("Idempotency-Key", params.IdempotencyKey)
+		}
+	}
+	var out CreatePaymentResponse
+	if err := c.do(ctx, "POST", path, query, header, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetPaymentStatus calls GET /api/payments/{external_id}/status: Status of a payment, reconciled with the gateway while pending
+func (c *Client) GetPaymentStatus(ctx context.Context, externalID string) (*PaymentStatus, error) {
+	path := "/api/payments/" + url.PathEscape(externalID) + "/status"
+	query := url.Values{}
+	header := http.Header{}
+	var out PaymentStatus
+	if err := c.do(ctx, "GET", path, query, header, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListPlans calls GET /api/plans: Active subscription plans
+func (c *Client) ListPlans(ctx context.Context) (*PlanListResponse, error) {
+	path := "/api/plans"
+	query := url.Values{}
+	header := http.Header{}
+	var out PlanListResponse
+	if err := c.do(ctx, "GET", path, query, header, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Subscribe calls POST /api/subscriptions: Subscribe to a plan and get its invoice
+func (c *Client) Subscribe(ctx context.Context, body *SubscribeRequest) (*SubscribeResponse, error) {
+	path := "/api/subscriptions"
+	query := url.Values{}
+	header := http.Header{}
+	var out SubscribeResponse
+	if err := c.do(ctx, "POST", path, query, header, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetCurrentSubscription calls GET /api/subscriptions/current: Subscription covering now
+func (c *Client) GetCurrentSubscription(ctx context.Context) (*CurrentSubscriptionResponse, error) {
+	path := "/api/subscriptions/current"
+	query := url.Values{}
+	header := http.Header{}
+	var out CurrentSubscriptionResponse
+	if err := c.do(ctx, "GET", path, query, header, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListTransactionsParams are the optional query and header parameters of ListTransactions
+type ListTransactionsParams struct {
+	// Page size (1-200); all rows when omitted
+	Limit int
+	// Rows to skip, requires limit
+	Offset int
+	// First day (YYYY-MM-DD, SCAN_SCHEDULE_TIMEZONE)
+	From string
+	// Last day, inclusive (YYYY-MM-DD)
+	To string
+	// Comma-separated statuses to include (pending, paid, expired, failed, refunded)
+	Status string
+	// Sort key
+	Sort string
+	// Sort direction, default desc
+	Order string
+}
+
+// ListTransactions calls GET /api/transactions: Payment history, newest first (unless sorted)
+func (c *Client) ListTransactions(ctx context.Context, params *ListTransactionsParams) (*TransactionListResponse, error) {
+	path := "/api/transactions"
+	query := url.Values{}
+	header := http.Header{}
+	if params != nil {
+		if params.Limit != 0 {
+			query.Set("limit", strconv.Itoa(params.Limit))
+		}
+		if params.Offset != 0 {
+			query.Set("offset", strconv.Itoa(params.Offset))
+		}
+		if params.From != "" {
+			query.Set("from", params.From)
+		}
+		if params.To != "" {
+			query.Set("to", params.To)
+		}
+		if params.Status != "" {
+			query.Set("status", params.Status)
+		}
+		if params.Sort != "" {
+			query.Set("sort", params.Sort)
+		}
+		if params.Order != "" {
+			query.Set("order", params.Order)
+		}
+	}
+	var out TransactionListResponse
+	if err := c.do(ctx, "GET", path, query, header, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AnalyzeParams are the optional query and header parameters of Analyze
+type AnalyzeParams struct {
+	// Analyze before the contact sync reached WA_WARMUP_MIN_PROGRESS
+	OverrideWarmup bool
+}
+
+// Analyze calls GET /api/wa/analyze: Analyze the linked account and wait for the result
+func (c *Client) Analyze(ctx context.Context, params *AnalyzeParams) (*AnalyzeResponse, error) {
+	path := "/api/wa/analyze"
+	query := url.Values{}
+	header := http.Header{}
+	if params != nil {
+		if params.OverrideWarmup {
+			query.Set("override_warmup", strconv.FormatBool(params.OverrideWarmup))
+		}
+	}
+	var out AnalyzeResponse
+	if err := c.do(ctx, "GET", path, query, header, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// StartAnalysisParams are the optional query and header parameters of StartAnalysis
+type StartAnalysisParams struct {
+	// Analyze before the contact sync reached WA_WARMUP_MIN_PROGRESS
+	OverrideWarmup bool
+}
+
+// StartAnalysis calls POST /api/wa/analyze: Queue an analysis job; a cached result is returned directly
+func (c *Client) StartAnalysis(ctx context.Context, params *StartAnalysisParams) (*AnalysisJobAccepted, error) {
+	path := "/api/wa/analyze"
+	query := url.Values{}
+	header := http.Header{}
+	if params != nil {
+		if params.OverrideWarmup {
+			query.Set("override_warmup", strconv.FormatBool(params.OverrideWarmup))
+		}
+	}
+	var out AnalysisJobAccepted
+	if err := c.do(ctx, "POST", path, query, header, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetAnalysisJob calls GET /api/wa/analyze/jobs/{id}: Progress and result of an analysis job
+func (c *Client) GetAnalysisJob(ctx context.Context, id string) (*AnalysisJobResponse, error) {
+	path := "/api/wa/analyze/jobs/" + url.PathEscape(id)
+	query := url.Values{}
+	header := http.Header{}
+	var out AnalysisJobResponse
+	if err := c.do(ctx, "GET", path, query, header, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetEntitlementParams are the optional query and header parameters of GetEntitlement
+type GetEntitlementParams struct {
+	// Phone number to analyze
+	Phone string
+}
+
+// GetEntitlement calls GET /api/wa/entitlement: Whether the phone number can be analyzed, checked before showing the QR
+func (c *Client) GetEntitlement(ctx context.Context, params *GetEntitlementParams) (*EntitlementResponse, error) {
+	path := "/api/wa/entitlement"
+	query := url.Values{}
+	header := http.Header{}
+	if params != nil {
+		if params.Phone != "" {
+			query.Set("phone", params.Phone)
+		}
+	}
+	var out EntitlementResponse
+	if err := c.do(ctx, "GET", path, query, header, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// LogoutWhatsApp calls POST /api/wa/logout: Unlink the WhatsApp device
+func (c *Client) LogoutWhatsApp(ctx context.Context) (*WhatsAppLogoutResponse, error) {
+	path := "/api/wa/logout"
+	query := url.Values{}
+	header := http.Header{}
+	var out WhatsAppLogoutResponse
+	if err := c.do(ctx, "POST", path, query, header, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetQR calls GET /api/wa/qr: Current QR code to link WhatsApp
+func (c *Client) GetQR(ctx context.Context) (*QRResponse, error) {
+	path := "/api/wa/qr"
+	query := url.Values{}
+	header := http.Header{}
+	var out QRResponse
+	if err := c.do(ctx, "GET", path, query, header, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetStatus calls GET /api/wa/status: Connection, warm-up and analysis readiness
+func (c *Client) GetStatus(ctx context.Context) (*StatusResponse, error) {
+	path := "/api/wa/status"
+	query := url.Values{}
+	header := http.Header{}
+	var out StatusResponse
+	if err := c.do(ctx, "GET", path, query, header, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
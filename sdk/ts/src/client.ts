@@ -0,0 +1,121 @@
+// HTTP plumbing of the CEKWA client; the models and operation methods are generated from
+// backend/api/openapi.json (see backend/tools/sdkgen).
+import type { PaymentBootstrap } from "./models.gen";
+
+export interface ClientOptions {
+  /** API origin without the /api prefix, e.g. "https://api.cekwa.id" */
+  baseUrl: string;
+  /** JWT sent as Authorization: Bearer; can be replaced later with setToken */
+  token?: string;
+  /** Send cookies (cookie-mode sessions); the CSRF token is then sent as X-CSRF-Token */
+  credentials?: RequestCredentials;
+  csrfToken?: string;
+  /** Custom fetch, e.g. for Node < 18 or tests */
+  fetch?: typeof fetch;
+}
+
+export interface RequestOptions {
+  query?: Record<string, string | number | boolean | undefined | null>;
+  headers?: Record<string, string | undefined | null>;
+  body?: unknown;
+}
+
+/** Thrown for non-2xx responses */
+export class ApiError extends Error {
+  readonly status: number;
+  /** Machine-readable reason when the API sends one (e.g. on 402) */
+  readonly errorType?: string;
+  /** Prefilled checkout attached to 402 Payment Required responses */
+  readonly payment?: PaymentBootstrap;
+  readonly body: string;
+
+  constructor(status: number, message: string, body: string, errorType?: string, payment?: PaymentBootstrap) {
+    super(message);
+    this.name = "ApiError";
+    this.status = status;
+    this.body = body;
+    this.errorType = errorType;
+    this.payment = payment;
+  }
+
+  get paymentRequired(): boolean {
+    return this.status === 402;
+  }
+}
+
+export class BaseClient {
+  private readonly baseUrl: string;
+  private readonly credentials?: RequestCredentials;
+  private readonly fetchImpl: typeof fetch;
+  private token?: string;
+  private csrfToken?: string;
+
+  constructor(options: ClientOptions) {
+    this.baseUrl = options.baseUrl.replace(/\/+$/, "");
+    this.token = options.token;
+    this.credentials = options.credentials;
+    this.csrfToken = options.csrfToken;
+    this.fetchImpl = options.fetch ?? globalThis.fetch.bind(globalThis);
+  }
+
+  /** Replaces the JWT sent with later requests, e.g. after login */
+  setToken(token: string | undefined): void {
+    this.token = token;
+  }
+
+  /** Replaces the CSRF token of a cookie-mode session, e.g. after login */
+  setCsrfToken(csrfToken: string | undefined): void {
+    this.csrfToken = csrfToken;
+  }
+
+  protected async request<T>(method: string, path: string, options: RequestOptions = {}): Promise<T> {
+    let url = this.baseUrl + path;
+    const query = new URLSearchParams();
+    for (const [name, value] of Object.entries(options.query ?? {})) {
+      if (value !== undefined && value !== null && value !== "" && value !== false) {
+        query.set(name, String(value));
+      }
+    }
+    if ([...query].length > 0) {
+      url += "?" + query.toString();
+    }
+
+    const headers: Record<string, string> = { Accept: "application/json" };
+    for (const [name, value] of Object.entries(options.headers ?? {})) {
+      if (value) {
+        headers[name] = value;
+      }
+    }
+    if (this.token) {
+      headers["Authorization"] = `Bearer ${this.token}`;
+    }
+    if (this.csrfToken && method !== "GET") {
+      headers["X-CSRF-Token"] = this.csrfToken;
+    }
+    let body: string | undefined;
+    if (options.body !== undefined) {
+      headers["Content-Type"] = "application/json";
+      body = JSON.stringify(options.body);
+    }
+
+    const response = await this.fetchImpl(url, { method, headers, body, credentials: this.credentials });
+    const text = await response.text();
+    if (!response.ok) {
+      throw toApiError(response.status, text);
+    }
+    return JSON.parse(text) as T;
+  }
+}
+
+/** Decodes a JSON error body, falling back to the plain-text message most endpoints send */
+function toApiError(status: number, text: string): ApiError {
+  try {
+    const decoded = JSON.parse(text);
+    if (decoded && typeof decoded === "object" && (decoded.error || decoded.message)) {
+      return new ApiError(status, decoded.error || decoded.message, text, decoded.error_type, decoded.payment);
+    }
+  } catch {
+    // plain text
+  }
+  return new ApiError(status, text.trim() || `HTTP ${status}`, text);
+}
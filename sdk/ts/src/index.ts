@@ -0,0 +1,4 @@
+export * from "./models.gen";
+export * from "./operations.gen";
+export { ApiError } from "./client";
+export type { ClientOptions } from "./client";
@@ -0,0 +1,496 @@
+// Code generated by sdkgen from api/openapi.json. DO NOT EDIT.
+
+export interface AnalysisDetailResponse {
+  data?: AnalysisResult;
+  success?: boolean;
+}
+
+/** The list is streamed; a failure part-way through sets success=false and error */
+export interface AnalysisHistoryResponse {
+  data?: HistoryItem[];
+  error?: string;
+  /** History size of the user's tier; only the newest analyses up to it are listed */
+  history_limit?: number;
+  /** Page size, only with ?limit= */
+  limit?: number;
+  /** Rows skipped, only with ?limit= */
+  offset?: number;
+  success?: boolean;
+  /** Rows matching the filters, for paging */
+  total?: number;
+}
+
+/** result is set once completed, partial while running */
+export interface AnalysisJob {
+  analysis_id?: number | null;
+  created_at?: string;
+  error?: string;
+  finished_at?: string | null;
+  job_id?: string;
+  partial?: Record<string, unknown>;
+  priority?: number;
+  progress?: number;
+  result?: Record<string, unknown>;
+  stage?: string;
+  started_at?: string | null;
+  status?: "queued" | "running" | "completed" | "failed" | "skipped";
+}
+
+export interface AnalysisJobAccepted {
+  estimated_wait_seconds?: number;
+  job?: AnalysisJob;
+  job_id?: string;
+  message?: string;
+  queued?: boolean;
+  status_url?: string;
+  success?: boolean;
+  user_id?: number;
+}
+
+export interface AnalysisJobResponse {
+  job?: AnalysisJob;
+  success?: boolean;
+}
+
+export interface AnalysisResult {
+  accountAgeDays?: number;
+  auto_triggered?: boolean;
+  checksum?: string;
+  confidence?: number;
+  created_at?: string;
+  data_quality?: DataQuality;
+  duration_ms?: number;
+  feedback_prompt?: boolean;
+  id?: number;
+  label?: string;
+  parameter_confidence?: Record<string, ParameterConfidence>;
+  parameters?: ParameterEvaluation[];
+  phone_number?: string;
+  pinned?: boolean;
+  scan_date?: string;
+  scan_history_id?: number | null;
+  scan_trigger?: string;
+  sensitiveContentCount?: number;
+  sensitive_categories?: Record<string, number>;
+  strength?: string;
+  summary?: string;
+  totalChatWithContact?: number;
+  totalChats?: number;
+  totalContacts?: number;
+  totalGroups?: number;
+  totalUnsavedChats?: number;
+  unknownNumberChats?: number;
+  updated_at?: string;
+  user_id?: number;
+}
+
+export interface AnalyzeResponse {
+  cached?: boolean;
+  message?: string;
+  result?: AnalysisResult;
+  success?: boolean;
+  user_id?: number;
+}
+
+export interface AuthSession {
+  created_at?: string;
+  /** Session of the token making the request */
+  current?: boolean;
+  /** Browser and OS derived from the user agent */
+  device?: string;
+  expires_at?: string;
+  id?: number;
+  ip_address?: string;
+  last_used_at?: string | null;
+  user_agent?: string;
+  user_id?: number;
+}
+
+export interface CouponQuote {
+  amount?: number;
+  code?: string;
+  description?: string;
+  discount?: number;
+  expires_at?: string | null;
+  final_amount?: number;
+}
+
+/** With create_payment_token only email (optional) and payment_method (default invoice) are needed */
+export interface CreatePaymentRequest {
+  /** Ignored: the amount is the price of the payment category */
+  amount?: number;
+  /** Name of an active payment category (see category_id) */
+  category?: string;
+  /** Payment category ID, takes precedence over category */
+  category_id?: number;
+  /** Optional promo code; the discount is taken off the category price before the invoice is created */
+  coupon_code?: string;
+  create_payment_token?: string;
+  email?: string;
+  gateway?: "xendit" | "midtrans";
+  payment_method?: string;
+  phone_number?: string;
+  redirect_base_url?: string;
+}
+
+export interface CreatePaymentResponse {
+  amount?: number;
+  coupon_code?: string;
+  created_at?: string;
+  discount?: number;
+  existing?: boolean;
+  expiry_date?: string;
+  external_id?: string;
+  gateway?: string;
+  id?: number;
+  invoice_id?: string;
+  invoice_url?: string;
+  message?: string;
+  payment_method?: string;
+  status?: string;
+}
+
+/** data is null without an active subscription */
+export interface CurrentSubscriptionResponse {
+  data?: SubscriptionStatus | null;
+  success?: boolean;
+}
+
+export interface DataQuality {
+  chats?: string;
+  confidence?: number;
+  contacts?: string;
+  degraded?: boolean;
+  errors?: Record<string, string>;
+  groups?: string;
+  upgraded_at?: string;
+}
+
+export interface DeleteAnalysisResponse {
+  deleted?: number;
+  success?: boolean;
+}
+
+export interface Entitlement {
+  entitled?: boolean;
+  has_paid_other_number?: boolean;
+  message?: string;
+  /** Checkout payload, only when payment_required */
+  payment?: PaymentBootstrap | null;
+  /** Whether paying for the number would allow the analysis (false for daily_scan_limit) */
+  payment_required?: boolean;
+  /** Normalized, e.g. 6281234567890 */
+  phone_number?: string;
+  /** Starting price, only when payment_required */
+  price?: PaymentPrice | null;
+  reason?: "paid" | "subscription" | "no_payment" | "wrong_phone_number" | "subscription_limit_reached" | "daily_scan_limit";
+  subscription?: SubscriptionStatus | null;
+}
+
+export interface EntitlementResponse {
+  data?: Entitlement;
+  success?: boolean;
+}
+
+/** Error body. Most endpoints answer errors as plain text; the WhatsApp endpoints answer JSON like this. */
+export interface ErrorResponse {
+  error?: string;
+  /** Machine-readable reason, e.g. payment_required */
+  error_type?: string;
+  message?: string;
+  payment?: PaymentBootstrap;
+  success?: boolean;
+}
+
+export interface HistoryItem {
+  checksum?: string;
+  id?: number;
+  label?: string;
+  phone_number?: string;
+  pinned?: boolean;
+  scan_date?: string;
+  strength?: string;
+  trigger?: "manual" | "auto";
+}
+
+export interface LoginRequest {
+  email: string;
+  password: string;
+  scopes?: ("wa" | "payments" | "admin")[];
+}
+
+/** token is omitted in cookie mode, where csrf_token is returned instead */
+export interface LoginResponse {
+  csrf_token?: string;
+  message?: string;
+  scopes?: string[];
+  success?: boolean;
+  token?: string;
+  user?: User;
+}
+
+export interface MessageResponse {
+  message?: string;
+  success?: boolean;
+}
+
+export interface ParameterConfidence {
+  confidence?: number;
+  estimated?: boolean;
+}
+
+export interface ParameterEvaluation {
+  confidence?: number;
+  estimated?: boolean;
+  key?: string;
+  parameter?: string;
+  score?: number;
+  /** Baik, Cukup or Buruk */
+  status?: string;
+  value?: number;
+}
+
+/** Prefilled checkout attached to 402 responses and payment checks */
+export interface PaymentBootstrap {
+  amount?: number;
+  create_payment_token?: string;
+  currency?: string;
+  expires_at?: string;
+  phone_number?: string;
+  plan?: string;
+}
+
+export interface PaymentCheckResponse {
+  data?: Entitlement;
+  success?: boolean;
+}
+
+export interface PaymentPrice {
+  amount?: number;
+  category?: string;
+  currency?: string;
+}
+
+export interface PaymentStatus {
+  amount?: number;
+  created_at?: string;
+  external_id?: string;
+  gateway?: string;
+  id?: number;
+  invoice_id?: string;
+  paid_at?: string | null;
+  payment_channel?: string;
+  payment_method?: string;
+  status?: string;
+  updated_at?: string;
+}
+
+export interface Plan {
+  code?: string;
+  created_at?: string;
+  currency?: string;
+  description?: string;
+  id?: number;
+  is_active?: boolean;
+  months?: number;
+  name?: string;
+  price?: number;
+  /** 0 = unlimited */
+  scans_per_month?: number;
+  updated_at?: string;
+}
+
+export interface PlanListResponse {
+  data?: Plan[];
+  success?: boolean;
+}
+
+export interface ProfileResponse {
+  success?: boolean;
+  user?: User;
+}
+
+/** qr is empty while the code is being generated */
+export interface QRResponse {
+  message?: string;
+  qr?: string;
+  qr_expires_at?: string;
+  ready?: boolean;
+}
+
+export interface RegisterRequest {
+  email: string;
+  password: string;
+  phone_number: string;
+  username: string;
+}
+
+export interface RegisterResponse {
+  message?: string;
+  success?: boolean;
+  user?: User;
+}
+
+/** Active ban or temporary ban of the WhatsApp account */
+export interface Restriction {
+  code?: number;
+  reason?: string;
+  type?: "banned" | "temp_banned";
+  until?: string;
+}
+
+export interface RevokeSessionsResponse {
+  revoked?: number;
+  success?: boolean;
+}
+
+export interface SendOTPRequest {
+  channel?: "email" | "whatsapp" | "sms";
+  email: string;
+}
+
+export interface SessionListResponse {
+  sessions?: AuthSession[];
+  success?: boolean;
+}
+
+export interface StatusResponse {
+  analysis_allowed?: boolean;
+  analysis_ready?: boolean;
+  /** Set when the scanned number needs a payment */
+  phone_mismatch?: Record<string, unknown>;
+  ready?: boolean;
+  restriction?: Restriction | null;
+  timestamp?: string;
+  user_id?: number;
+  warmup?: WarmupState;
+  whatsapp_status?: "connected" | "disconnected" | "scanning" | "connecting" | "banned" | "reconnecting" | "store_upgrading" | "store_upgrade_failed";
+}
+
+export interface SubscribeRequest {
+  email?: string;
+  gateway?: "xendit" | "midtrans";
+  plan_code: string;
+  redirect_base_url?: string;
+}
+
+export interface SubscribeResponse {
+  data?: SubscribeResult;
+  success?: boolean;
+}
+
+export interface SubscribeResult {
+  payment?: CreatePaymentResponse;
+  plan?: Plan;
+  subscription?: Subscription;
+}
+
+export interface Subscription {
+  created_at?: string;
+  cycle_start?: string | null;
+  ends_at?: string | null;
+  id?: number;
+  months?: number;
+  plan_id?: number;
+  scans_per_month?: number;
+  scans_used?: number;
+  starts_at?: string | null;
+  status?: "pending" | "active" | "expired" | "cancelled";
+  updated_at?: string;
+  user_id?: number;
+}
+
+export interface SubscriptionStatus {
+  cycle_ends_at?: string | null;
+  ends_at?: string | null;
+  id?: number;
+  plan_code?: string;
+  plan_name?: string;
+  scans_per_month?: number;
+  /** null = unlimited */
+  scans_remaining?: number | null;
+  scans_used?: number;
+  starts_at?: string | null;
+  status?: string;
+}
+
+export interface Transaction {
+  amount?: number;
+  created_at?: string;
+  currency?: string;
+  description?: string;
+  external_id?: string;
+  id?: number;
+  paid_at?: string | null;
+  payment_channel?: string;
+  payment_method?: string;
+  phone_number?: string;
+  refunded_at?: string | null;
+  status?: string;
+  updated_at?: string;
+}
+
+/** The list is streamed; a failure part-way through sets success=false and error */
+export interface TransactionListResponse {
+  data?: Transaction[];
+  error?: string;
+  /** Page size, only with ?limit= */
+  limit?: number;
+  /** Rows skipped, only with ?limit= */
+  offset?: number;
+  success?: boolean;
+  /** Rows matching the filters, for paging */
+  total?: number;
+}
+
+export interface UpdateAnalysisRequest {
+  /** Free text, at most 100 characters; empty removes the label */
+  label?: string;
+  pinned?: boolean;
+}
+
+export interface UpdateAnalysisResponse {
+  data?: Record<string, unknown>;
+  success?: boolean;
+}
+
+/** Account as returned by the auth endpoints */
+export interface User {
+  created_at?: string;
+  email?: string;
+  id?: number;
+  phone_number?: string;
+  role?: "user" | "admin";
+  username?: string;
+}
+
+export interface ValidateCouponRequest {
+  amount: number;
+  code: string;
+}
+
+export interface ValidateCouponResponse {
+  data?: CouponQuote;
+  success?: boolean;
+}
+
+export interface VerifyOTPRequest {
+  email: string;
+  otp: string;
+}
+
+/** Contact/group sync progress after connecting */
+export interface WarmupState {
+  contacts?: number;
+  groups?: number;
+  phase?: string;
+  progress?: number;
+  started_at?: string;
+  updated_at?: string;
+}
+
+export interface WhatsAppLogoutResponse {
+  message?: string;
+  success?: boolean;
+  user_id?: number;
+}
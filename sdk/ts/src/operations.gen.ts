@@ -0,0 +1,283 @@
+// Code generated by sdkgen from api/openapi.json. DO NOT EDIT.
+
+import { BaseClient } from "./client";
+import type {
+  AnalysisDetailResponse,
+  AnalysisHistoryResponse,
+  AnalysisJobAccepted,
+  AnalysisJobResponse,
+  AnalyzeResponse,
+  CreatePaymentRequest,
+  CreatePaymentResponse,
+  CurrentSubscriptionResponse,
+  DeleteAnalysisResponse,
+  EntitlementResponse,
+  LoginRequest,
+  LoginResponse,
+  MessageResponse,
+  PaymentCheckResponse,
+  PaymentStatus,
+  PlanListResponse,
+  ProfileResponse,
+  QRResponse,
+  RegisterRequest,
+  RegisterResponse,
+  RevokeSessionsResponse,
+  SendOTPRequest,
+  SessionListResponse,
+  StatusResponse,
+  SubscribeRequest,
+  SubscribeResponse,
+  TransactionListResponse,
+  UpdateAnalysisRequest,
+  UpdateAnalysisResponse,
+  ValidateCouponRequest,
+  ValidateCouponResponse,
+  VerifyOTPRequest,
+  WhatsAppLogoutResponse,
+} from "./models.gen";
+
+export interface ListAnalysisHistoryParams {
+  /** Page size (1-200); all rows when omitted */
+  "limit"?: number;
+  /** Rows to skip, requires limit */
+  "offset"?: number;
+  /** First day (YYYY-MM-DD, SCAN_SCHEDULE_TIMEZONE) */
+  "from"?: string;
+  /** Last day, inclusive (YYYY-MM-DD) */
+  "to"?: string;
+  /** Comma-separated strengths to include (Baik, Cukup, Buruk) */
+  "strength"?: string;
+  /** Sort key */
+  "sort"?: "scan_date" | "strength";
+  /** Sort direction, default desc */
+  "order"?: "asc" | "desc";
+}
+
+export interface GetAnalysisParams {
+  /** Comma-separated fields to return (e.g. id,strength,summary); unknown fields are rejected with 400 */
+  "fields"?: string;
+}
+
+export interface RevokeSessionsParams {
+  /** Also revoke the session of this token */
+  "include_current"?: boolean;
+}
+
+export interface CheckPaymentParams {
+  /** Phone number to analyze */
+  "phone": string;
+}
+
+export interface CreatePaymentParams {
+  /** Replays the first invoice when the same key is sent again */
+  "Idempotency-Key"?: string;
+}
+
+export interface ListTransactionsParams {
+  /** Page size (1-200); all rows when omitted */
+  "limit"?: number;
+  /** Rows to skip, requires limit */
+  "offset"?: number;
+  /** First day (YYYY-MM-DD, SCAN_SCHEDULE_TIMEZONE) */
+  "from"?: string;
+  /** Last day, inclusive (YYYY-MM-DD) */
+  "to"?: string;
+  /** Comma-separated statuses to include (pending, paid, expired, failed, refunded) */
+  "status"?: string;
+  /** Sort key */
+  "sort"?: "created_at" | "paid_at" | "amount" | "status";
+  /** Sort direction, default desc */
+  "order"?: "asc" | "desc";
+}
+
+export interface AnalyzeParams {
+  /** Analyze before the contact sync reached WA_WARMUP_MIN_PROGRESS */
+  "override_warmup"?: boolean;
+}
+
+export interface StartAnalysisParams {
+  /** Analyze before the contact sync reached WA_WARMUP_MIN_PROGRESS */
+  "override_warmup"?: boolean;
+}
+
+export interface GetEntitlementParams {
+  /** Phone number to analyze */
+  "phone": string;
+}
+
+/** Client for the CEKWA API, one method per operation of the OpenAPI spec */
+export class CekwaClient extends BaseClient {
+  /** GET /api/analysis/history: Analysis history, pinned first, then newest first (unless sorted) */
+  listAnalysisHistory(params?: ListAnalysisHistoryParams): Promise<AnalysisHistoryResponse> {
+    return this.request<AnalysisHistoryResponse>("GET", `/api/analysis/history`, {
+      query: { "limit": params?.["limit"], "offset": params?.["offset"], "from": params?.["from"], "to": params?.["to"], "strength": params?.["strength"], "sort": params?.["sort"], "order": params?.["order"] },
+    });
+  }
+
+  /** GET /api/analysis/{id}: One analysis result */
+  getAnalysis(id: number, params?: GetAnalysisParams): Promise<AnalysisDetailResponse> {
+    return this.request<AnalysisDetailResponse>("GET", `/api/analysis/${encodeURIComponent(String(id))}`, {
+      query: { "fields": params?.["fields"] },
+    });
+  }
+
+  /** PATCH /api/analysis/{id}: Pin or label an analysis result */
+  updateAnalysis(id: number, body: UpdateAnalysisRequest): Promise<UpdateAnalysisResponse> {
+    return this.request<UpdateAnalysisResponse>("PATCH", `/api/analysis/${encodeURIComponent(String(id))}`, {
+      body,
+    });
+  }
+
+  /** DELETE /api/analysis/{id}: Delete one analysis result */
+  deleteAnalysis(id: number): Promise<DeleteAnalysisResponse> {
+    return this.request<DeleteAnalysisResponse>("DELETE", `/api/analysis/${encodeURIComponent(String(id))}`);
+  }
+
+  /** POST /api/auth/login: Log in and get a JWT */
+  login(body: LoginRequest): Promise<LoginResponse> {
+    return this.request<LoginResponse>("POST", `/api/auth/login`, {
+      body,
+    });
+  }
+
+  /** POST /api/auth/logout: Revoke the session of the token and clear the session cookies (cookie mode) */
+  logout(): Promise<MessageResponse> {
+    return this.request<MessageResponse>("POST", `/api/auth/logout`);
+  }
+
+  /** GET /api/auth/profile: Profile of the token's user */
+  getProfile(): Promise<ProfileResponse> {
+    return this.request<ProfileResponse>("GET", `/api/auth/profile`);
+  }
+
+  /** POST /api/auth/register: Create an account; a verification OTP is emailed */
+  register(body: RegisterRequest): Promise<RegisterResponse> {
+    return this.request<RegisterResponse>("POST", `/api/auth/register`, {
+      body,
+    });
+  }
+
+  /** POST /api/auth/send-otp: Send a verification OTP by email, WhatsApp or SMS */
+  sendOTP(body: SendOTPRequest): Promise<MessageResponse> {
+    return this.request<MessageResponse>("POST", `/api/auth/send-otp`, {
+      body,
+    });
+  }
+
+  /** GET /api/auth/sessions: Devices signed in to the account */
+  listSessions(): Promise<SessionListResponse> {
+    return this.request<SessionListResponse>("GET", `/api/auth/sessions`);
+  }
+
+  /** DELETE /api/auth/sessions: Sign out every other device */
+  revokeSessions(params?: RevokeSessionsParams): Promise<RevokeSessionsResponse> {
+    return this.request<RevokeSessionsResponse>("DELETE", `/api/auth/sessions`, {
+      query: { "include_current": params?.["include_current"] },
+    });
+  }
+
+  /** DELETE /api/auth/sessions/{id}: Sign out one device */
+  revokeSession(id: number): Promise<MessageResponse> {
+    return this.request<MessageResponse>("DELETE", `/api/auth/sessions/${encodeURIComponent(String(id))}`);
+  }
+
+  /** POST /api/auth/verify-otp: Verify the emailed OTP */
+  verifyOTP(body: VerifyOTPRequest): Promise<MessageResponse> {
+    return this.request<MessageResponse>("POST", `/api/auth/verify-otp`, {
+      body,
+    });
+  }
+
+  /** POST /api/coupons/validate: Check a promo code and the discount it gives on an amount */
+  validateCoupon(body: ValidateCouponRequest): Promise<ValidateCouponResponse> {
+    return this.request<ValidateCouponResponse>("POST", `/api/coupons/validate`, {
+      body,
+    });
+  }
+
+  /** GET /api/payments/check: Whether analyzing the phone number needs a payment */
+  checkPayment(params: CheckPaymentParams): Promise<PaymentCheckResponse> {
+    return this.request<PaymentCheckResponse>("GET", `/api/payments/check`, {
+      query: { "phone": params["phone"] },
+    });
+  }
+
+  /** POST /api/payments/create: Create (or reuse) an invoice */
+  createPayment(body: CreatePaymentRequest, params?: CreatePaymentParams): Promise<CreatePaymentResponse> {
+    return this.request<CreatePaymentResponse>("POST", `/api/payments/create`, {
+      headers: { "Idempotency-Key": params?.["Idempotency-Key"] },
+      body,
+    });
+  }
+
+  /** GET /api/payments/{external_id}/status: Status of a payment, reconciled with the gateway while pending */
+  getPaymentStatus(externalID: string): Promise<PaymentStatus> {
+    return this.request<PaymentStatus>("GET", `/api/payments/${encodeURIComponent(String(externalID))}/status`);
+  }
+
+  /** GET /api/plans: Active subscription plans */
+  listPlans(): Promise<PlanListResponse> {
+    return this.request<PlanListResponse>("GET", `/api/plans`);
+  }
+
+  /** POST /api/subscriptions: Subscribe to a plan and get its invoice */
+  subscribe(body: SubscribeRequest): Promise<SubscribeResponse> {
+    return this.request<SubscribeResponse>("POST", `/api/subscriptions`, {
+      body,
+    });
+  }
+
+  /** GET /api/subscriptions/current: Subscription covering now */
+  getCurrentSubscription(): Promise<CurrentSubscriptionResponse> {
+    return this.request<CurrentSubscriptionResponse>("GET", `/api/subscriptions/current`);
+  }
+
+  /** GET /api/transactions: Payment history, newest first (unless sorted) */
+  listTransactions(params?: ListTransactionsParams): Promise<TransactionListResponse> {
+    return this.request<TransactionListResponse>("GET", `/api/transactions`, {
+      query: { "limit": params?.["limit"], "offset": params?.["offset"], "from": params?.["from"], "to": params?.["to"], "status": params?.["status"], "sort": params?.["sort"], "order": params?.["order"] },
+    });
+  }
+
+  /** GET /api/wa/analyze: Analyze the linked account and wait for the result */
+  analyze(params?: AnalyzeParams): Promise<AnalyzeResponse> {
+    return this.request<AnalyzeResponse>("GET", `/api/wa/analyze`, {
+      query: { "override_warmup": params?.["override_warmup"] },
+    });
+  }
+
+  /** POST /api/wa/analyze: Queue an analysis job; a cached result is returned directly */
+  startAnalysis(params?: StartAnalysisParams): Promise<AnalysisJobAccepted> {
+    return this.request<AnalysisJobAccepted>("POST", `/api/wa/analyze`, {
+      query: { "override_warmup": params?.["override_warmup"] },
+    });
+  }
+
+  /** GET /api/wa/analyze/jobs/{id}: Progress and result of an analysis job */
+  getAnalysisJob(id: string): Promise<AnalysisJobResponse> {
+    return this.request<AnalysisJobResponse>("GET", `/api/wa/analyze/jobs/${encodeURIComponent(String(id))}`);
+  }
+
+  /** GET /api/wa/entitlement: Whether the phone number can be analyzed, checked before showing the QR */
+  getEntitlement(params: GetEntitlementParams): Promise<EntitlementResponse> {
+    return this.request<EntitlementResponse>("GET", `/api/wa/entitlement`, {
+      query: { "phone": params["phone"] },
+    });
+  }
+
+  /** POST /api/wa/logout: Unlink the WhatsApp device */
+  logoutWhatsApp(): Promise<WhatsAppLogoutResponse> {
+    return this.request<WhatsAppLogoutResponse>("POST", `/api/wa/logout`);
+  }
+
+  /** GET /api/wa/qr: Current QR code to link WhatsApp */
+  getQR(): Promise<QRResponse> {
+    return this.request<QRResponse>("GET", `/api/wa/qr`);
+  }
+
+  /** GET /api/wa/status: Connection, warm-up and analysis readiness */
+  getStatus(): Promise<StatusResponse> {
+    return this.request<StatusResponse>("GET", `/api/wa/status`);
+  }
+}